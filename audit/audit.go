@@ -0,0 +1,236 @@
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+
+	"github.com/NYTimes/gizmo/pubsub"
+)
+
+// Log is the structured logger used throughout the package.
+var Log = logrus.New()
+
+var (
+	// DefaultMaxBodyBytes is the amount of a request or response body that
+	// will be captured in a Record if the Auditor doesn't set MaxBodyBytes.
+	DefaultMaxBodyBytes = 4096
+	// Redacted is the value that will replace any redacted fields.
+	Redacted = "[REDACTED]"
+)
+
+// Record is a single audited request and response.
+type Record struct {
+	Time       time.Time `json:"time"`
+	Identity   string    `json:"identity,omitempty"`
+	Method     string    `json:"method"`
+	Path       string    `json:"path"`
+	RemoteAddr string    `json:"remote_addr"`
+	RequestID  string    `json:"request_id,omitempty"`
+	Status     int       `json:"status"`
+	Duration   float64   `json:"duration_ms"`
+	Request    *Body     `json:"request,omitempty"`
+	Response   *Body     `json:"response,omitempty"`
+}
+
+// Body is a summary of a request or response body.
+type Body struct {
+	// Size is the total number of bytes in the body.
+	Size int `json:"size"`
+	// ContentType is the content type of the body.
+	ContentType string `json:"content_type,omitempty"`
+	// JSON holds the redacted body if it was valid JSON and
+	// within the Auditor's MaxBodyBytes.
+	JSON json.RawMessage `json:"json,omitempty"`
+	// Truncated is true if the body was larger than MaxBodyBytes.
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// Sink is a destination for audit records.
+type Sink interface {
+	Write(*Record) error
+}
+
+// Auditor will record every request passed through its Middleware to a Sink.
+type Auditor struct {
+	// Sink is where all records will be written.
+	Sink Sink
+	// Identity is an optional func to pull the identity of the caller
+	// out of the request.
+	Identity func(*http.Request) string
+	// Redact is a list of JSON field names (case insensitive) that should
+	// have their values replaced in request and response bodies.
+	Redact []string
+	// Inspect is an optional list of hooks that JSON request and response
+	// bodies will be run through after redaction, such as those made by
+	// pubsub.RedactJSONPaths or pubsub.TokenizeJSONPaths. Each hook is given
+	// the request path as the key. If a hook fails, the body is left out of
+	// the record.
+	Inspect []pubsub.PayloadHook
+	// MaxBodyBytes is the max amount of a request or response body that
+	// will be captured. Defaults to DefaultMaxBodyBytes.
+	MaxBodyBytes int
+	// SkipBodies will leave the bodies out of records entirely.
+	SkipBodies bool
+}
+
+// Middleware will write a Record to the Auditor's Sink after each
+// request is handled. Any errors from the Sink will be logged.
+func (a *Auditor) Middleware(h http.Handler) http.Handler {
+	max := a.MaxBodyBytes
+	if max == 0 {
+		max = DefaultMaxBodyBytes
+	}
+	redact := map[string]bool{}
+	for _, field := range a.Redact {
+		redact[strings.ToLower(field)] = true
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &Record{
+			Time:       start.UTC(),
+			Method:     r.Method,
+			Path:       r.URL.Path,
+			RemoteAddr: r.RemoteAddr,
+			RequestID:  r.Header.Get("X-Request-Id"),
+		}
+		if a.Identity != nil {
+			rec.Identity = a.Identity(r)
+		}
+
+		// the request body is captured as the handler reads it
+		var reqBody *capture
+		if !a.SkipBodies && r.Body != nil {
+			reqBody = &capture{max: max}
+			r.Body = &captureReader{ReadCloser: r.Body, capture: reqBody}
+		}
+		aw := &auditWriter{ResponseWriter: w, status: http.StatusOK}
+		if !a.SkipBodies {
+			aw.body = &capture{max: max}
+		}
+
+		h.ServeHTTP(aw, r)
+
+		rec.Status = aw.status
+		rec.Duration = float64(time.Since(start)) / float64(time.Millisecond)
+		if reqBody != nil && reqBody.size > 0 {
+			rec.Request = reqBody.summary(r.Header.Get("Content-Type"), redact)
+			a.inspect(rec.Path, rec.Request)
+		}
+		if aw.body != nil && aw.body.size > 0 {
+			rec.Response = aw.body.summary(w.Header().Get("Content-Type"), redact)
+			a.inspect(rec.Path, rec.Response)
+		}
+
+		if err := a.Sink.Write(rec); err != nil {
+			Log.WithField("path", rec.Path).Error("unable to write audit record: ", err)
+		}
+	})
+}
+
+// inspect will run the body's JSON through the Auditor's hooks.
+func (a *Auditor) inspect(path string, b *Body) {
+	for _, hook := range a.Inspect {
+		if b.JSON == nil {
+			return
+		}
+		js, err := hook(path, b.JSON)
+		if err != nil {
+			Log.WithField("path", path).Warn("unable to inspect audit body, leaving it out: ", err)
+			b.JSON = nil
+			return
+		}
+		b.JSON = js
+	}
+}
+
+// capture holds the first max bytes of a body and counts the rest.
+type capture struct {
+	max  int
+	size int
+	buf  bytes.Buffer
+}
+
+func (c *capture) write(b []byte) {
+	c.size += len(b)
+	if room := c.max - c.buf.Len(); room > 0 {
+		if len(b) > room {
+			b = b[:room]
+		}
+		c.buf.Write(b)
+	}
+}
+
+func (c *capture) summary(contentType string, redact map[string]bool) *Body {
+	b := &Body{
+		Size:        c.size,
+		ContentType: contentType,
+		Truncated:   c.size > c.max,
+	}
+	if b.Truncated || !strings.Contains(contentType, "json") {
+		return b
+	}
+	var v interface{}
+	if err := json.Unmarshal(c.buf.Bytes(), &v); err != nil {
+		return b
+	}
+	if js, err := json.Marshal(RedactFields(v, redact)); err == nil {
+		b.JSON = js
+	}
+	return b
+}
+
+// RedactFields will replace the values of any fields in the given
+// decoded JSON value whose lowercased names are in the redact set.
+func RedactFields(v interface{}, redact map[string]bool) interface{} {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		for k, fv := range val {
+			if redact[strings.ToLower(k)] {
+				val[k] = Redacted
+				continue
+			}
+			val[k] = RedactFields(fv, redact)
+		}
+	case []interface{}:
+		for i, iv := range val {
+			val[i] = RedactFields(iv, redact)
+		}
+	}
+	return v
+}
+
+type captureReader struct {
+	io.ReadCloser
+	capture *capture
+}
+
+func (r *captureReader) Read(b []byte) (int, error) {
+	n, err := r.ReadCloser.Read(b)
+	r.capture.write(b[:n])
+	return n, err
+}
+
+type auditWriter struct {
+	http.ResponseWriter
+	status int
+	body   *capture
+}
+
+func (w *auditWriter) WriteHeader(code int) {
+	w.status = code
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *auditWriter) Write(b []byte) (int, error) {
+	if w.body != nil {
+		w.body.write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
@@ -0,0 +1,133 @@
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/NYTimes/gizmo/pubsub"
+	"github.com/NYTimes/gizmo/pubsub/pubsubtest"
+)
+
+type testSink struct {
+	records []*Record
+}
+
+func (s *testSink) Write(rec *Record) error {
+	s.records = append(s.records, rec)
+	return nil
+}
+
+func TestAuditorMiddleware(t *testing.T) {
+	sink := &testSink{}
+	a := &Auditor{
+		Sink:     sink,
+		Identity: func(r *http.Request) string { return r.Header.Get("X-User") },
+		Redact:   []string{"Password", "token"},
+	}
+	h := a.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ioutil.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"id":1,"auth":[{"token":"abc"}]}`))
+	}))
+
+	r, _ := http.NewRequest("POST", "/admin/users", strings.NewReader(`{"name":"jp","password":"secret"}`))
+	r.Header.Set("Content-Type", "application/json")
+	r.Header.Set("X-User", "admin")
+	h.ServeHTTP(httptest.NewRecorder(), r)
+
+	if len(sink.records) != 1 {
+		t.Fatalf("expected 1 audit record, got %d", len(sink.records))
+	}
+	rec := sink.records[0]
+	if rec.Identity != "admin" || rec.Method != "POST" || rec.Path != "/admin/users" || rec.Status != http.StatusCreated {
+		t.Errorf("expected admin POST /admin/users 201 record, got %#v", rec)
+	}
+
+	tests := []struct {
+		name string
+		body *Body
+
+		want string
+	}{
+		{"request", rec.Request, `{"name":"jp","password":"[REDACTED]"}`},
+		{"response", rec.Response, `{"auth":[{"token":"[REDACTED]"}],"id":1}`},
+	}
+	for _, test := range tests {
+		if test.body == nil {
+			t.Errorf("expected %s body summary", test.name)
+			continue
+		}
+		if got := string(test.body.JSON); got != test.want {
+			t.Errorf("expected %s body of %s, got %s", test.name, test.want, got)
+		}
+	}
+}
+
+func TestAuditorTruncatesBodies(t *testing.T) {
+	sink := &testSink{}
+	a := &Auditor{Sink: sink, MaxBodyBytes: 4}
+	h := a.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"id":1}`))
+	}))
+	h.ServeHTTP(httptest.NewRecorder(), &http.Request{Method: "GET", URL: mustURL("/")})
+
+	body := sink.records[0].Response
+	if body == nil || !body.Truncated || body.Size != 8 || body.JSON != nil {
+		t.Errorf("expected a truncated 8 byte response summary, got %#v", body)
+	}
+}
+
+func TestAuditorInspectsBodies(t *testing.T) {
+	sink := &testSink{}
+	a := &Auditor{
+		Sink:    sink,
+		Inspect: []pubsub.PayloadHook{pubsub.RedactJSONPaths("$.users[*].email")},
+	}
+	h := a.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"users":[{"email":"a@b.com","id":1},{"email":"c@d.com","id":2}]}`))
+	}))
+	h.ServeHTTP(httptest.NewRecorder(), &http.Request{Method: "GET", URL: mustURL("/users")})
+
+	want := `{"users":[{"email":"[REDACTED]","id":1},{"email":"[REDACTED]","id":2}]}`
+	if got := string(sink.records[0].Response.JSON); got != want {
+		t.Errorf("expected response body of %s, got %s", want, got)
+	}
+}
+
+func TestSinks(t *testing.T) {
+	rec := &Record{Method: "GET", Path: "/cats", Status: 200}
+
+	var buf bytes.Buffer
+	if err := NewFileSink(&buf).Write(rec); err != nil {
+		t.Fatal("unexpected file sink error: ", err)
+	}
+	var got Record
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil || got.Path != "/cats" {
+		t.Errorf("expected a JSON record for /cats, got %q", buf.String())
+	}
+	if !strings.HasSuffix(buf.String(), "\n") {
+		t.Error("expected file sink records to end in a newline")
+	}
+
+	pub := &pubsubtest.TestPublisher{}
+	if err := NewPublisherSink(pub, nil).Write(rec); err != nil {
+		t.Fatal("unexpected publisher sink error: ", err)
+	}
+	if len(pub.Published) != 1 || pub.Published[0].Key != "/cats" {
+		t.Errorf("expected 1 record published with key /cats, got %#v", pub.Published)
+	}
+}
+
+func mustURL(path string) *url.URL {
+	u, _ := url.Parse(path)
+	return u
+}
@@ -0,0 +1,25 @@
+/*
+Package audit provides middleware for recording structured audit records of
+requests and responses to compliance-sensitive endpoints.
+
+Each record contains the identity of the caller, the route, the response status
+and duration along with summaries of the request and response bodies. Sensitive
+fields in JSON bodies can be redacted by name, or run through pubsub.PayloadHooks
+like pubsub.RedactJSONPaths, before a record is written to its Sink.
+
+The package offers a few Sink implementations:
+
+  - FileSink will write JSON lines to any io.Writer, ie. an audit log file.
+  - PublisherSink will publish JSON records via a pubsub.Publisher, ie. to SQS via SNS.
+  - SQLSink will insert JSON records into a database table.
+
+A basic setup may look like:
+
+	auditor := &audit.Auditor{
+	    Sink:     audit.NewFileSink(f),
+	    Identity: func(r *http.Request) string { return r.Header.Get("X-User") },
+	    Redact:   []string{"password", "ssn"},
+	}
+	admin := svr.Group("/admin", auditor.Middleware)
+*/
+package audit
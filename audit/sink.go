@@ -0,0 +1,95 @@
+package audit
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/NYTimes/gizmo/pubsub"
+)
+
+// FileSink will write each Record as a line of JSON to an io.Writer.
+type FileSink struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewFileSink will return a Sink that writes JSON lines to the given writer.
+func NewFileSink(w io.Writer) *FileSink {
+	return &FileSink{w: w}
+}
+
+// Write will encode the record and write it as a single line.
+func (s *FileSink) Write(rec *Record) error {
+	b, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err = s.w.Write(append(b, '\n'))
+	return err
+}
+
+// PublisherSink will publish each Record as JSON with a pubsub.Publisher.
+type PublisherSink struct {
+	pub pubsub.Publisher
+	key func(*Record) string
+}
+
+// NewPublisherSink will return a Sink that publishes JSON records. The
+// key func is optional and will default to the record's path.
+func NewPublisherSink(pub pubsub.Publisher, key func(*Record) string) *PublisherSink {
+	if key == nil {
+		key = func(rec *Record) string { return rec.Path }
+	}
+	return &PublisherSink{pub: pub, key: key}
+}
+
+// Write will publish the record.
+func (s *PublisherSink) Write(rec *Record) error {
+	b, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	return s.pub.PublishRaw(s.key(rec), b)
+}
+
+// SQLSink will insert each Record into a database table. The table
+// is expected to have the following columns:
+//
+//	time TIMESTAMP, identity TEXT, method TEXT, path TEXT,
+//	status INTEGER, record TEXT
+//
+// Where 'record' will contain the full JSON encoded Record.
+type SQLSink struct {
+	db    *sql.DB
+	query string
+}
+
+// NewSQLSink will return a Sink that inserts records into the given table.
+// The placeholder func should return the bind parameter for the given index
+// (starting at 1), ie "?" for MySQL or "$1" for Postgres. If nil, "?" is used.
+func NewSQLSink(db *sql.DB, table string, placeholder func(int) string) *SQLSink {
+	if placeholder == nil {
+		placeholder = func(int) string { return "?" }
+	}
+	query := fmt.Sprintf(
+		"INSERT INTO %s (time, identity, method, path, status, record) VALUES (%s, %s, %s, %s, %s, %s)",
+		table, placeholder(1), placeholder(2), placeholder(3),
+		placeholder(4), placeholder(5), placeholder(6),
+	)
+	return &SQLSink{db: db, query: query}
+}
+
+// Write will insert the record.
+func (s *SQLSink) Write(rec *Record) error {
+	b, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec(s.query, rec.Time, rec.Identity, rec.Method, rec.Path, rec.Status, string(b))
+	return err
+}
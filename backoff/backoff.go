@@ -0,0 +1,123 @@
+package backoff
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// The names of the strategies accepted by New.
+const (
+	ConstantStrategy           = "constant"
+	ExponentialStrategy        = "exponential"
+	DecorrelatedJitterStrategy = "decorrelated"
+)
+
+// Strategy decides how long to wait between attempts.
+type Strategy interface {
+	// Delay returns how long to wait before the given retry, starting at 1.
+	Delay(attempt int) time.Duration
+}
+
+// Constant is a Strategy that waits the same duration before every retry.
+type Constant time.Duration
+
+// Delay will return the constant duration.
+func (c Constant) Delay(attempt int) time.Duration {
+	return time.Duration(c)
+}
+
+// Exponential is a Strategy that doubles the wait from Base on each retry,
+// up to Max. Half of each wait is randomized so collectively retrying
+// clients spread out.
+type Exponential struct {
+	Base time.Duration
+	Max  time.Duration
+}
+
+// Delay will return a randomized duration between half and all of
+// Base doubled for each retry, capped at Max.
+func (e Exponential) Delay(attempt int) time.Duration {
+	d := e.Base
+	for i := 1; i < attempt && d < e.Max; i++ {
+		d *= 2
+	}
+	if d > e.Max {
+		d = e.Max
+	}
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+// DecorrelatedJitter is a Strategy that waits a random duration between Base
+// and a cap that triples on each retry, up to Max. Its waits vary more than
+// Exponential's, which better spreads out clients that all failed at once.
+type DecorrelatedJitter struct {
+	Base time.Duration
+	Max  time.Duration
+}
+
+// Delay will return a random duration between Base and Base tripled for
+// each retry, capped at Max.
+func (j DecorrelatedJitter) Delay(attempt int) time.Duration {
+	upper := j.Base
+	for i := 1; i < attempt && upper < j.Max; i++ {
+		upper *= 3
+	}
+	if upper > j.Max {
+		upper = j.Max
+	}
+	if upper <= j.Base {
+		return j.Base
+	}
+	return j.Base + time.Duration(rand.Int63n(int64(upper-j.Base)+1))
+}
+
+// New will return the named Strategy using the given base and max delays.
+// An empty name will return an Exponential strategy. Unknown names will
+// return an error along with an Exponential strategy, so callers that cannot
+// fail may still retry.
+func New(name string, base, max time.Duration) (Strategy, error) {
+	switch name {
+	case "", ExponentialStrategy:
+		return Exponential{Base: base, Max: max}, nil
+	case ConstantStrategy:
+		return Constant(base), nil
+	case DecorrelatedJitterStrategy:
+		return DecorrelatedJitter{Base: base, Max: max}, nil
+	}
+	return Exponential{Base: base, Max: max},
+		fmt.Errorf("backoff: unknown strategy %q", name)
+}
+
+// Sleep will wait for the delay of the given retry, returning the
+// context's error if it is done first.
+func Sleep(ctx context.Context, s Strategy, attempt int) error {
+	t := time.NewTimer(s.Delay(attempt))
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Retry will call fn up to the given number of attempts, waiting between
+// them as the Strategy decides, until it returns nil. The last error from fn
+// will be returned, or the context's error if it is done while waiting.
+func Retry(ctx context.Context, s Strategy, attempts int, fn func() error) error {
+	var err error
+	for attempt := 0; attempt < attempts; attempt++ {
+		if attempt > 0 {
+			if serr := Sleep(ctx, s, attempt); serr != nil {
+				return serr
+			}
+		}
+		if err = fn(); err == nil {
+			return nil
+		}
+	}
+	return err
+}
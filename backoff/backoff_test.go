@@ -0,0 +1,143 @@
+package backoff
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+func TestStrategies(t *testing.T) {
+	base, max := 10*time.Millisecond, 50*time.Millisecond
+	tests := []struct {
+		name     string
+		strategy Strategy
+		attempt  int
+
+		wantMin time.Duration
+		wantMax time.Duration
+	}{
+		{"constant", Constant(base), 1, base, base},
+		{"constant", Constant(base), 5, base, base},
+		{"exponential", Exponential{base, max}, 1, 5 * time.Millisecond, 10 * time.Millisecond},
+		{"exponential", Exponential{base, max}, 2, 10 * time.Millisecond, 20 * time.Millisecond},
+		{"exponential", Exponential{base, max}, 3, 20 * time.Millisecond, 40 * time.Millisecond},
+		{"exponential", Exponential{base, max}, 10, 25 * time.Millisecond, 50 * time.Millisecond},
+		{"decorrelated", DecorrelatedJitter{base, max}, 1, base, base},
+		{"decorrelated", DecorrelatedJitter{base, max}, 2, base, 30 * time.Millisecond},
+		{"decorrelated", DecorrelatedJitter{base, max}, 10, base, max},
+	}
+
+	for _, test := range tests {
+		for i := 0; i < 10; i++ {
+			got := test.strategy.Delay(test.attempt)
+			if got < test.wantMin || got > test.wantMax {
+				t.Errorf("%s attempt %d expected delay between %s and %s, got %s",
+					test.name, test.attempt, test.wantMin, test.wantMax, got)
+			}
+		}
+	}
+}
+
+func TestNew(t *testing.T) {
+	base, max := time.Millisecond, time.Second
+	tests := []struct {
+		name string
+
+		want    Strategy
+		wantErr bool
+	}{
+		{"", Exponential{base, max}, false},
+		{ExponentialStrategy, Exponential{base, max}, false},
+		{ConstantStrategy, Constant(base), false},
+		{DecorrelatedJitterStrategy, DecorrelatedJitter{base, max}, false},
+		{"linear", Exponential{base, max}, true},
+	}
+
+	for _, test := range tests {
+		got, err := New(test.name, base, max)
+		if (err != nil) != test.wantErr {
+			t.Errorf("%q expected error %t, got %v", test.name, test.wantErr, err)
+		}
+		if got != test.want {
+			t.Errorf("%q expected strategy %#v, got %#v", test.name, test.want, got)
+		}
+	}
+}
+
+func TestRetry(t *testing.T) {
+	fail := errors.New("nope")
+
+	var calls int
+	err := Retry(context.Background(), Constant(time.Millisecond), 3, func() error {
+		calls++
+		if calls < 2 {
+			return fail
+		}
+		return nil
+	})
+	if err != nil {
+		t.Errorf("expected no error, got %s", err)
+	}
+	if calls != 2 {
+		t.Errorf("expected 2 calls, got %d", calls)
+	}
+
+	calls = 0
+	err = Retry(context.Background(), Constant(time.Millisecond), 3, func() error {
+		calls++
+		return fail
+	})
+	if err != fail {
+		t.Errorf("expected the last error, got %v", err)
+	}
+	if calls != 3 {
+		t.Errorf("expected 3 calls, got %d", calls)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	calls = 0
+	err = Retry(ctx, Constant(time.Hour), 3, func() error {
+		calls++
+		return fail
+	})
+	if err != context.Canceled {
+		t.Errorf("expected the context's error, got %v", err)
+	}
+	if calls != 1 {
+		t.Errorf("expected 1 call, got %d", calls)
+	}
+}
+
+func TestBudget(t *testing.T) {
+	tests := []struct {
+		ratio    float64
+		min      int
+		window   time.Duration
+		requests int
+
+		wantRetries int
+	}{
+		{0.5, 0, 10 * time.Second, 4, 2},
+		{0.1, 1, 10 * time.Second, 5, 10},
+		{0.1, 1, time.Second, 5, 1},
+		{0.1, 1, 0, 5, 1},
+	}
+
+	for _, test := range tests {
+		b := NewBudget(test.ratio, test.min, test.window)
+		for i := 0; i < test.requests; i++ {
+			b.Request()
+		}
+		var got int
+		for i := 0; i < 100 && b.TryRetry(); i++ {
+			got++
+		}
+		if got != test.wantRetries {
+			t.Errorf("expected %d retries with ratio %f, min %d, window %s and %d requests, got %d",
+				test.wantRetries, test.ratio, test.min, test.window, test.requests, got)
+		}
+	}
+}
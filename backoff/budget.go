@@ -0,0 +1,79 @@
+package backoff
+
+import (
+	"sync"
+	"time"
+)
+
+// Budget limits retries, and hedged requests, to a ratio of the requests
+// made over a sliding window, so retries cannot multiply the load on an
+// upstream that is already failing. It is safe for concurrent use.
+type Budget struct {
+	ratio        float64
+	minPerSecond int
+
+	mu sync.Mutex
+	// per second counts over the window
+	requests, retries []int
+	// the unix second of each bucket
+	seconds []int64
+}
+
+// NewBudget will return a Budget that allows retries up to the given ratio
+// of the requests made over the window, plus a minimum number of retries per
+// second so clients with low traffic can still retry.
+func NewBudget(ratio float64, minPerSecond int, window time.Duration) *Budget {
+	n := int(window / time.Second)
+	if n < 1 {
+		n = 1
+	}
+	return &Budget{
+		ratio:        ratio,
+		minPerSecond: minPerSecond,
+		requests:     make([]int, n),
+		retries:      make([]int, n),
+		seconds:      make([]int64, n),
+	}
+}
+
+// Request will record a request against the budget.
+func (b *Budget) Request() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.requests[b.bucket(time.Now())]++
+}
+
+// TryRetry will record and return true if a retry is allowed by the budget.
+func (b *Budget) TryRetry() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := time.Now()
+	i := b.bucket(now)
+
+	var requests, retries int
+	for j := range b.seconds {
+		if now.Unix()-b.seconds[j] < int64(len(b.seconds)) {
+			requests += b.requests[j]
+			retries += b.retries[j]
+		}
+	}
+	allowed := b.ratio*float64(requests) + float64(b.minPerSecond*len(b.seconds))
+	if float64(retries+1) > allowed {
+		return false
+	}
+	b.retries[i]++
+	return true
+}
+
+// bucket will return the index of the bucket for the given
+// time, resetting it if it holds an older second.
+func (b *Budget) bucket(now time.Time) int {
+	sec := now.Unix()
+	i := int(sec % int64(len(b.seconds)))
+	if b.seconds[i] != sec {
+		b.seconds[i] = sec
+		b.requests[i] = 0
+		b.retries[i] = 0
+	}
+	return i
+}
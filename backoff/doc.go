@@ -0,0 +1,24 @@
+/*
+Package backoff holds the retry strategies shared by the httpclient, grpcclient,
+sqldb, webhook, jobs and pubsub packages, so retry behavior is consistent and
+can be tuned in one place. A Strategy returns how long to wait before each
+attempt:
+
+  - Constant waits the same duration every time
+  - Exponential doubles a randomized wait up to a cap
+  - DecorrelatedJitter spreads waits randomly between the base and a growing cap
+
+Strategies can be picked by name from config structs with New:
+
+	strategy, err := backoff.New(cfg.RetryStrategy, cfg.RetryBaseDelay, cfg.RetryMaxDelay)
+
+and used with Retry, which stops early if the context is done:
+
+	err := backoff.Retry(ctx, strategy, 3, func() error {
+	    return s.fetchCat(ctx, id)
+	})
+
+A Budget limits retries to a ratio of the requests made over a sliding window,
+so retries cannot multiply the load on an upstream that is already failing.
+*/
+package backoff
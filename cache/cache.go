@@ -0,0 +1,100 @@
+package cache
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"golang.org/x/net/context"
+
+	"github.com/NYTimes/gizmo/singleflight"
+)
+
+// Log is the structured logger used by the cache package.
+var Log = logrus.New()
+
+// ErrNotFound is returned when a key is not in the cache or has expired.
+var ErrNotFound = errors.New("cache: key not found")
+
+// Cache is a key/value store with per-key expiry, shared by handlers and
+// consumers to avoid repeating expensive lookups.
+type Cache interface {
+	// Get will return the value of the key or ErrNotFound.
+	Get(key string) ([]byte, error)
+	// Set will store the value of the key. If ttl is 0, it will not expire.
+	Set(key string, value []byte, ttl time.Duration) error
+	// Delete will remove the key, if present.
+	Delete(key string) error
+	// TTL will return how long the key has left before it expires, 0 if
+	// it does not expire, or ErrNotFound.
+	TTL(key string) (time.Duration, error)
+}
+
+// Filler is a Cache that will fill missing keys, making sure concurrent
+// misses for the same key only call the fill func once.
+type Filler struct {
+	Cache
+
+	group singleflight.Group
+}
+
+// NewFiller will return a Filler around the given Cache.
+func NewFiller(c Cache) *Filler {
+	return &Filler{Cache: c}
+}
+
+// GetOrFill will return the value of the key or, on a miss, call fill and
+// store its value for the ttl. Callers missing the same key while it is
+// being filled will wait for and share the result. Errors from fill are not
+// cached.
+func (f *Filler) GetOrFill(key string, ttl time.Duration, fill func() ([]byte, error)) ([]byte, error) {
+	return f.GetOrFillContext(context.Background(), key, ttl, func(context.Context) ([]byte, error) {
+		return fill()
+	})
+}
+
+// GetOrFillContext is like GetOrFill, but will stop waiting for the value
+// once ctx is done. The fill will only be cancelled once every caller
+// waiting on it has given up. See singleflight.Group.
+func (f *Filler) GetOrFillContext(ctx context.Context, key string, ttl time.Duration, fill func(ctx context.Context) ([]byte, error)) ([]byte, error) {
+	value, err := f.Get(key)
+	if err == nil {
+		return value, nil
+	}
+	if err != ErrNotFound {
+		// carry on to the source if the cache is unavailable
+		Log.Warnf("unable to get %s from cache: %s", key, err)
+	}
+
+	v, _, err := f.group.Do(ctx, key, func(ctx context.Context) (interface{}, error) {
+		value, err := fill(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if err := f.Set(key, value, ttl); err != nil {
+			Log.Warnf("unable to set %s in cache: %s", key, err)
+		}
+		return value, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v.([]byte), nil
+}
+
+// GetOrFillJSON is like GetOrFill, but will store the value returned by
+// fill as JSON and decode the cached value into v.
+func (f *Filler) GetOrFillJSON(key string, ttl time.Duration, v interface{}, fill func() (interface{}, error)) error {
+	b, err := f.GetOrFill(key, ttl, func() ([]byte, error) {
+		value, err := fill()
+		if err != nil {
+			return nil, err
+		}
+		return json.Marshal(value)
+	})
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
@@ -0,0 +1,214 @@
+package cache
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// testCache will run the behavior every Cache should share.
+func testCache(t *testing.T, c Cache) {
+	if _, err := c.Get("cat"); err != ErrNotFound {
+		t.Errorf("expected ErrNotFound for a missing key, got %v", err)
+	}
+	if err := c.Set("cat", []byte("tom"), time.Minute); err != nil {
+		t.Fatal("unexpected error setting key: ", err)
+	}
+	if v, err := c.Get("cat"); err != nil || string(v) != "tom" {
+		t.Errorf("expected 'tom', got %q, %v", v, err)
+	}
+	if ttl, err := c.TTL("cat"); err != nil || ttl <= 0 || ttl > time.Minute {
+		t.Errorf("expected a ttl of up to a minute, got %s, %v", ttl, err)
+	}
+	if err := c.Set("forever", []byte("felix"), 0); err != nil {
+		t.Fatal("unexpected error setting key: ", err)
+	}
+	if ttl, err := c.TTL("forever"); err != nil || ttl != 0 {
+		t.Errorf("expected no ttl, got %s, %v", ttl, err)
+	}
+	if err := c.Delete("cat"); err != nil {
+		t.Fatal("unexpected error deleting key: ", err)
+	}
+	if _, err := c.Get("cat"); err != ErrNotFound {
+		t.Errorf("expected ErrNotFound for a deleted key, got %v", err)
+	}
+	if _, err := c.TTL("cat"); err != ErrNotFound {
+		t.Errorf("expected ErrNotFound for the ttl of a deleted key, got %v", err)
+	}
+}
+
+func TestLRU(t *testing.T) {
+	testCache(t, NewLRU(10))
+
+	l := NewLRU(2)
+	l.Set("a", []byte("a"), 0)
+	l.Set("b", []byte("b"), 0)
+	l.Get("a")
+	l.Set("c", []byte("c"), 0)
+	if _, err := l.Get("b"); err != ErrNotFound {
+		t.Error("expected the least recently used key to be evicted")
+	}
+	if _, err := l.Get("a"); err != nil {
+		t.Error("expected the recently used key to be kept")
+	}
+	if l.Len() != 2 {
+		t.Errorf("expected 2 keys, got %d", l.Len())
+	}
+
+	l.Set("short", []byte("lived"), time.Millisecond)
+	time.Sleep(2 * time.Millisecond)
+	if _, err := l.Get("short"); err != ErrNotFound {
+		t.Error("expected an expired key to be missing")
+	}
+}
+
+// testRedisConn implements the commands used by the Redis cache
+// against a shared map.
+type testRedisConn struct {
+	mu      *sync.Mutex
+	keys    map[string][]byte
+	expires map[string]time.Time
+}
+
+func newTestRedisConn() testRedisConn {
+	return testRedisConn{mu: &sync.Mutex{}, keys: map[string][]byte{}, expires: map[string]time.Time{}}
+}
+
+func (c testRedisConn) Do(cmd string, args ...interface{}) (interface{}, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	key := args[0].(string)
+	switch cmd {
+	case "GET":
+		if v, ok := c.keys[key]; ok {
+			return v, nil
+		}
+		return nil, nil
+	case "SET":
+		c.keys[key] = args[1].([]byte)
+		delete(c.expires, key)
+		if len(args) == 4 {
+			c.expires[key] = time.Now().Add(time.Duration(args[3].(int64)) * time.Millisecond)
+		}
+		return "OK", nil
+	case "DEL":
+		delete(c.keys, key)
+		delete(c.expires, key)
+		return int64(1), nil
+	case "PTTL":
+		if _, ok := c.keys[key]; !ok {
+			return int64(-2), nil
+		}
+		if exp, ok := c.expires[key]; ok {
+			return int64(exp.Sub(time.Now()) / time.Millisecond), nil
+		}
+		return int64(-1), nil
+	}
+	return nil, errors.New("unexpected command " + cmd)
+}
+
+func (c testRedisConn) Close() error {
+	return nil
+}
+
+func TestRedis(t *testing.T) {
+	conn := newTestRedisConn()
+	testCache(t, NewRedis(func() RedisConn { return conn }))
+	if _, ok := conn.keys[DefaultRedisKeyPrefix+"forever"]; !ok {
+		t.Errorf("expected keys to be stored under the key prefix, got %v", conn.keys)
+	}
+}
+
+func TestTiered(t *testing.T) {
+	conn := newTestRedisConn()
+	testCache(t, NewTiered(NewLRU(10), NewRedis(func() RedisConn { return conn }), time.Minute))
+
+	local, remote := NewLRU(10), NewLRU(10)
+	c := NewTiered(local, remote, time.Minute)
+	remote.Set("cat", []byte("tom"), time.Second)
+	if v, err := c.Get("cat"); err != nil || string(v) != "tom" {
+		t.Errorf("expected 'tom' from the remote tier, got %q, %v", v, err)
+	}
+	if ttl, err := local.TTL("cat"); err != nil || ttl > time.Second {
+		t.Errorf("expected the local copy to expire with the remote key, got %s, %v", ttl, err)
+	}
+
+	c.Set("dog", []byte("rex"), time.Hour)
+	if ttl, _ := local.TTL("dog"); ttl > time.Minute {
+		t.Errorf("expected the local ttl to be capped at the LocalTTL, got %s", ttl)
+	}
+}
+
+func TestFillerGetOrFill(t *testing.T) {
+	f := NewFiller(NewLRU(10))
+	var (
+		fills   int32
+		release = make(chan struct{})
+		wg      sync.WaitGroup
+	)
+	fill := func() ([]byte, error) {
+		atomic.AddInt32(&fills, 1)
+		<-release
+		return []byte("tom"), nil
+	}
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if v, err := f.GetOrFill("cat", time.Minute, fill); err != nil || string(v) != "tom" {
+				t.Errorf("expected 'tom', got %q, %v", v, err)
+			}
+		}()
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	if fills != 1 {
+		t.Errorf("expected concurrent misses to fill once, got %d", fills)
+	}
+	if v, _ := f.Get("cat"); string(v) != "tom" {
+		t.Errorf("expected the filled value to be cached, got %q", v)
+	}
+
+	errFill := errors.New("db is down")
+	if _, err := f.GetOrFill("dog", time.Minute, func() ([]byte, error) { return nil, errFill }); err != errFill {
+		t.Errorf("expected the fill error, got %v", err)
+	}
+	if _, err := f.Get("dog"); err != ErrNotFound {
+		t.Error("expected fill errors to not be cached")
+	}
+}
+
+func TestFillerGetOrFillJSON(t *testing.T) {
+	f := NewFiller(NewLRU(10))
+	type cat struct{ Name string }
+	for i := 0; i < 2; i++ {
+		var got cat
+		err := f.GetOrFillJSON("cat", time.Minute, &got, func() (interface{}, error) {
+			if i > 0 {
+				t.Error("expected the second call to hit the cache")
+			}
+			return cat{Name: "tom"}, nil
+		})
+		if err != nil || got.Name != "tom" {
+			t.Errorf("expected tom, got %v, %v", got, err)
+		}
+	}
+}
+
+func TestFillerGetOrFillContext(t *testing.T) {
+	f := NewFiller(NewLRU(10))
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := f.GetOrFillContext(ctx, "cat", time.Minute, func(ctx context.Context) ([]byte, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+	if err != context.Canceled {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+}
@@ -0,0 +1,24 @@
+/*
+Package cache offers a Cache interface shared by handlers and consumers, with
+an in-memory LRU, a Redis implementation and a Tiered read-through cache
+combining the two.
+
+A Filler will fill missing keys from their source, making sure concurrent
+misses for the same key only hit the source once:
+
+	c := cache.NewFiller(cache.NewTiered(
+		cache.NewLRU(10000),
+		cache.NewRedis(func() cache.RedisConn { return pool.Get() }),
+		10*time.Second,
+	))
+
+	func (s *Service) GetCat(r *http.Request) (int, interface{}, error) {
+	    id := web.Vars(r)["id"]
+	    var cat Cat
+	    err := s.cache.GetOrFillJSON("cat:"+id, time.Minute, &cat, func() (interface{}, error) {
+	        return s.db.GetCat(id)
+	    })
+	    ...
+	}
+*/
+package cache
@@ -0,0 +1,118 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// LRU is an in-memory Cache that holds up to a fixed number of keys,
+// evicting the least recently used key when full.
+type LRU struct {
+	size int
+
+	mu    sync.Mutex
+	order *list.List
+	items map[string]*list.Element
+}
+
+type lruEntry struct {
+	key     string
+	value   []byte
+	expires time.Time
+}
+
+// NewLRU will return an empty LRU that holds up to size keys.
+func NewLRU(size int) *LRU {
+	return &LRU{
+		size:  size,
+		order: list.New(),
+		items: map[string]*list.Element{},
+	}
+}
+
+// Get will return the value of the key, marking it as recently used.
+func (l *LRU) Get(key string) ([]byte, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	e, ok := l.entry(key)
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return e.value, nil
+}
+
+// Set will store the value of the key, evicting the least recently
+// used key if the LRU is full.
+func (l *LRU) Set(key string, value []byte, ttl time.Duration) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var expires time.Time
+	if ttl > 0 {
+		expires = time.Now().Add(ttl)
+	}
+	if el, ok := l.items[key]; ok {
+		e := el.Value.(*lruEntry)
+		e.value, e.expires = value, expires
+		l.order.MoveToFront(el)
+		return nil
+	}
+	l.items[key] = l.order.PushFront(&lruEntry{key: key, value: value, expires: expires})
+	if l.order.Len() > l.size {
+		l.remove(l.order.Back())
+	}
+	return nil
+}
+
+// Delete will remove the key, if present.
+func (l *LRU) Delete(key string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if el, ok := l.items[key]; ok {
+		l.remove(el)
+	}
+	return nil
+}
+
+// TTL will return how long the key has left before it expires.
+func (l *LRU) TTL(key string) (time.Duration, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	e, ok := l.entry(key)
+	if !ok {
+		return 0, ErrNotFound
+	}
+	if e.expires.IsZero() {
+		return 0, nil
+	}
+	return e.expires.Sub(time.Now()), nil
+}
+
+// Len will return the number of keys held, including any
+// that have expired but not yet been evicted.
+func (l *LRU) Len() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.order.Len()
+}
+
+// entry will return the unexpired entry of the key, removing
+// it if it has expired. The lock must be held.
+func (l *LRU) entry(key string) (*lruEntry, bool) {
+	el, ok := l.items[key]
+	if !ok {
+		return nil, false
+	}
+	e := el.Value.(*lruEntry)
+	if !e.expires.IsZero() && time.Now().After(e.expires) {
+		l.remove(el)
+		return nil, false
+	}
+	l.order.MoveToFront(el)
+	return e, true
+}
+
+func (l *LRU) remove(el *list.Element) {
+	l.order.Remove(el)
+	delete(l.items, el.Value.(*lruEntry).key)
+}
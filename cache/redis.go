@@ -0,0 +1,94 @@
+package cache
+
+import (
+	"errors"
+	"time"
+)
+
+// RedisConn is the subset of a Redis connection used by the
+// Redis cache. It is satisfied by redigo's redis.Conn.
+type RedisConn interface {
+	Do(cmd string, args ...interface{}) (interface{}, error)
+	Close() error
+}
+
+// DefaultRedisKeyPrefix is prepended to each key stored by a Redis cache.
+const DefaultRedisKeyPrefix = "gizmo:cache:"
+
+// Redis is a Cache backed by Redis keys with an expiry, shared by
+// all instances of a service.
+type Redis struct {
+	// KeyPrefix will override the DefaultRedisKeyPrefix.
+	KeyPrefix string
+
+	conn func() RedisConn
+}
+
+// NewRedis will return a Cache that uses the given func to get a
+// connection for each operation, such as a redigo Pool's Get method:
+//
+//	cache.NewRedis(func() cache.RedisConn { return pool.Get() })
+func NewRedis(conn func() RedisConn) *Redis {
+	return &Redis{KeyPrefix: DefaultRedisKeyPrefix, conn: conn}
+}
+
+// Get will return the value of the key.
+func (r *Redis) Get(key string) ([]byte, error) {
+	c := r.conn()
+	defer c.Close()
+	reply, err := c.Do("GET", r.KeyPrefix+key)
+	if err != nil {
+		return nil, err
+	}
+	switch v := reply.(type) {
+	case nil:
+		return nil, ErrNotFound
+	case []byte:
+		return v, nil
+	case string:
+		return []byte(v), nil
+	}
+	return nil, errors.New("cache: unexpected redis reply")
+}
+
+// Set will store the value of the key with the ttl in milliseconds.
+func (r *Redis) Set(key string, value []byte, ttl time.Duration) error {
+	c := r.conn()
+	defer c.Close()
+	var err error
+	if ms := int64(ttl / time.Millisecond); ms > 0 {
+		_, err = c.Do("SET", r.KeyPrefix+key, value, "PX", ms)
+	} else {
+		_, err = c.Do("SET", r.KeyPrefix+key, value)
+	}
+	return err
+}
+
+// Delete will remove the key.
+func (r *Redis) Delete(key string) error {
+	c := r.conn()
+	defer c.Close()
+	_, err := c.Do("DEL", r.KeyPrefix+key)
+	return err
+}
+
+// TTL will return how long the key has left before it expires.
+func (r *Redis) TTL(key string) (time.Duration, error) {
+	c := r.conn()
+	defer c.Close()
+	reply, err := c.Do("PTTL", r.KeyPrefix+key)
+	if err != nil {
+		return 0, err
+	}
+	ms, ok := reply.(int64)
+	if !ok {
+		return 0, errors.New("cache: unexpected redis reply")
+	}
+	switch {
+	case ms == -2:
+		return 0, ErrNotFound
+	case ms < 0:
+		return 0, nil
+	}
+	return time.Duration(ms) * time.Millisecond, nil
+}
@@ -0,0 +1,70 @@
+package cache
+
+import "time"
+
+// Tiered is a read-through Cache with a fast local tier, like an LRU, in
+// front of a shared remote tier, like Redis. Hits in the remote tier are
+// copied to the local tier for up to the LocalTTL, so the local tier may
+// serve a value for that long after it has changed in the remote tier.
+type Tiered struct {
+	// LocalTTL is the longest a value will be held in the local tier.
+	LocalTTL time.Duration
+
+	local  Cache
+	remote Cache
+}
+
+// NewTiered will return a Tiered cache reading through the local tier to
+// the remote tier. Values will be held locally for up to localTTL.
+func NewTiered(local, remote Cache, localTTL time.Duration) *Tiered {
+	return &Tiered{LocalTTL: localTTL, local: local, remote: remote}
+}
+
+// Get will return the value from the local tier or, on a miss, from the
+// remote tier, copying it to the local tier.
+func (t *Tiered) Get(key string) ([]byte, error) {
+	if value, err := t.local.Get(key); err == nil {
+		return value, nil
+	}
+	value, err := t.remote.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	ttl := t.LocalTTL
+	if rttl, err := t.remote.TTL(key); err == nil && rttl > 0 && rttl < ttl {
+		ttl = rttl
+	}
+	if err := t.local.Set(key, value, ttl); err != nil {
+		Log.Warnf("unable to set %s in local cache: %s", key, err)
+	}
+	return value, nil
+}
+
+// Set will store the value in both tiers.
+func (t *Tiered) Set(key string, value []byte, ttl time.Duration) error {
+	if err := t.remote.Set(key, value, ttl); err != nil {
+		return err
+	}
+	return t.local.Set(key, value, t.localTTL(ttl))
+}
+
+// Delete will remove the key from both tiers. Other instances may
+// continue to serve it from their local tier for up to the LocalTTL.
+func (t *Tiered) Delete(key string) error {
+	if err := t.remote.Delete(key); err != nil {
+		return err
+	}
+	return t.local.Delete(key)
+}
+
+// TTL will return how long the key has left in the remote tier.
+func (t *Tiered) TTL(key string) (time.Duration, error) {
+	return t.remote.TTL(key)
+}
+
+func (t *Tiered) localTTL(ttl time.Duration) time.Duration {
+	if ttl == 0 || ttl > t.LocalTTL {
+		return t.LocalTTL
+	}
+	return ttl
+}
@@ -0,0 +1,386 @@
+package clientgen
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"go/token"
+	"io"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"unicode"
+
+	"github.com/NYTimes/gizmo/server"
+)
+
+// Generator writes the source of a client for a set of routes.
+type Generator struct {
+	// Package is the name of the package the client will be generated in.
+	Package string
+	// ImportPath is the import path of the package the client will be
+	// generated in. Types from this package will not be qualified.
+	ImportPath string
+	// Client is the name of the generated client type.
+	Client string
+}
+
+// GenerateService will write a client for all the routes of the given service.
+func (g *Generator) GenerateService(w io.Writer, svc server.Service) error {
+	return g.Generate(w, server.ServiceRoutes(svc))
+}
+
+// Generate will write a client with a method for each of the given routes.
+func (g *Generator) Generate(w io.Writer, routes []server.Route) error {
+	if g.Package == "" || g.Client == "" {
+		return fmt.Errorf("clientgen: Package and Client are required")
+	}
+
+	f := &file{
+		Generator: g,
+		imports: map[string]bool{
+			"bytes":                               true,
+			"encoding/json":                       true,
+			"fmt":                                 true,
+			"io":                                  true,
+			"io/ioutil":                           true,
+			"net/http":                            true,
+			"net/url":                             true,
+			"strings":                             true,
+			"github.com/NYTimes/gizmo/httpclient": true,
+		},
+	}
+
+	prefix := commonPrefix(routes)
+	names := map[string]bool{}
+	for _, route := range routes {
+		m, err := f.newMethod(route, prefix)
+		if err != nil {
+			return err
+		}
+		if names[m.Name] {
+			return fmt.Errorf("clientgen: duplicate method name %q for %s %s", m.Name, route.Method, route.Path)
+		}
+		names[m.Name] = true
+		f.Methods = append(f.Methods, m)
+	}
+
+	for imp := range f.imports {
+		// keep the standard library imports in their own group
+		if strings.Contains(strings.SplitN(imp, "/", 2)[0], ".") {
+			f.Imports = append(f.Imports, imp)
+		} else {
+			f.StdImports = append(f.StdImports, imp)
+		}
+	}
+	sort.Strings(f.StdImports)
+	sort.Strings(f.Imports)
+
+	var buf bytes.Buffer
+	if err := clientTmpl.Execute(&buf, f); err != nil {
+		return err
+	}
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		return fmt.Errorf("clientgen: unable to format generated source: %s", err)
+	}
+	_, err = w.Write(src)
+	return err
+}
+
+type file struct {
+	*Generator
+	StdImports []string
+	Imports    []string
+	Methods    []*method
+
+	imports map[string]bool
+}
+
+type method struct {
+	Name       string
+	Method     string
+	Path       string
+	PathExpr   string
+	Summary    string
+	Deprecated bool
+	Params     []string
+	Request    string
+	Response   string
+	Result     string
+}
+
+func (f *file) newMethod(route server.Route, prefix []string) (*method, error) {
+	m := &method{
+		Method: route.Method,
+		Path:   route.Path,
+	}
+
+	// build up the path expression, swapping params for escaped args
+	var static []string
+	parts := []string{}
+	lit := ""
+	for i, seg := range strings.Split(route.Path, "/") {
+		if i > 0 {
+			lit += "/"
+		}
+		params := server.PathParams(seg)
+		if len(params) == 0 {
+			lit += seg
+			if seg != "" {
+				static = append(static, seg)
+			}
+			continue
+		}
+		if lit != "" {
+			parts = append(parts, strconv.Quote(lit))
+			lit = ""
+		}
+		arg := identifier(params[0])
+		m.Params = append(m.Params, arg)
+		parts = append(parts, "c.escape("+arg+")")
+	}
+	if lit != "" {
+		parts = append(parts, strconv.Quote(lit))
+	}
+	m.PathExpr = strings.Join(parts, " + ")
+
+	if route.Doc != nil {
+		m.Name = route.Doc.Name
+		m.Summary = route.Doc.Summary
+		m.Deprecated = route.Doc.Deprecated
+		if route.Doc.Request != nil {
+			m.Request = f.typeExpr(reflect.TypeOf(route.Doc.Request))
+		}
+		if route.Doc.Response != nil {
+			t := reflect.TypeOf(route.Doc.Response)
+			if t.Kind() == reflect.Ptr {
+				t = t.Elem()
+			}
+			m.Response = f.typeExpr(t)
+			// slices and maps are returned as is, everything else by pointer
+			m.Result = "*" + m.Response
+			if t.Kind() == reflect.Slice || t.Kind() == reflect.Map {
+				m.Result = m.Response
+			}
+		}
+	}
+	if m.Name == "" {
+		m.Name = methodName(route.Method, trimPrefix(static, prefix))
+	}
+	if !isIdentifier(m.Name) {
+		return nil, fmt.Errorf("clientgen: invalid method name %q for %s %s", m.Name, route.Method, route.Path)
+	}
+	return m, nil
+}
+
+// typeExpr will return the Go expression for the given type and
+// record any imports it requires.
+func (f *file) typeExpr(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Ptr:
+		return "*" + f.typeExpr(t.Elem())
+	case reflect.Slice:
+		if t.Name() == "" {
+			return "[]" + f.typeExpr(t.Elem())
+		}
+	case reflect.Array:
+		if t.Name() == "" {
+			return fmt.Sprintf("[%d]%s", t.Len(), f.typeExpr(t.Elem()))
+		}
+	case reflect.Map:
+		if t.Name() == "" {
+			return "map[" + f.typeExpr(t.Key()) + "]" + f.typeExpr(t.Elem())
+		}
+	}
+	if t.PkgPath() == "" {
+		// builtin or unnamed type
+		return t.String()
+	}
+	if t.PkgPath() == f.ImportPath {
+		return t.Name()
+	}
+	f.imports[t.PkgPath()] = true
+	return t.String()
+}
+
+// commonPrefix will return the static path segments shared by all routes.
+// At least one segment of each route is left for naming methods.
+func commonPrefix(routes []server.Route) []string {
+	var prefix []string
+	for i, route := range routes {
+		var segs []string
+		for _, seg := range strings.Split(route.Path, "/") {
+			if seg == "" {
+				continue
+			}
+			if len(server.PathParams(seg)) > 0 {
+				break
+			}
+			segs = append(segs, seg)
+		}
+		if len(segs) > 0 {
+			segs = segs[:len(segs)-1]
+		}
+		if i == 0 {
+			prefix = segs
+			continue
+		}
+		n := 0
+		for n < len(prefix) && n < len(segs) && prefix[n] == segs[n] {
+			n++
+		}
+		prefix = prefix[:n]
+	}
+	return prefix
+}
+
+func trimPrefix(segs, prefix []string) []string {
+	if len(segs) >= len(prefix) {
+		return segs[len(prefix):]
+	}
+	return segs
+}
+
+// methodName will combine the HTTP method and path segments into
+// an exported identifier, ie GET /cats/search => GetCatsSearch.
+func methodName(method string, segs []string) string {
+	name := camel(strings.ToLower(method))
+	for _, seg := range segs {
+		name += camel(seg)
+	}
+	return name
+}
+
+func camel(s string) string {
+	var out []rune
+	upper := true
+	for _, r := range s {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			upper = true
+			continue
+		}
+		if upper {
+			r = unicode.ToUpper(r)
+			upper = false
+		}
+		out = append(out, r)
+	}
+	return string(out)
+}
+
+func isIdentifier(name string) bool {
+	if name == "" || token.Lookup(name).IsKeyword() {
+		return false
+	}
+	for i, r := range name {
+		if !unicode.IsLetter(r) && r != '_' && (i == 0 || !unicode.IsDigit(r)) {
+			return false
+		}
+	}
+	return true
+}
+
+// identifier will turn a path param name into a usable argument name.
+func identifier(name string) string {
+	id := camel(name)
+	if id == "" {
+		return "param"
+	}
+	r := []rune(id)
+	r[0] = unicode.ToLower(r[0])
+	id = string(r)
+	if token.Lookup(id).IsKeyword() || id == "body" || id == "c" {
+		id += "Param"
+	}
+	return id
+}
+
+var clientTmpl = template.Must(template.New("client").Parse(`// Code generated by clientgen. DO NOT EDIT.
+
+package {{.Package}}
+
+import (
+{{range .StdImports}}	"{{.}}"
+{{end}}
+{{range .Imports}}	"{{.}}"
+{{end}})
+
+// {{.Client}} is a client for calling the service's JSON endpoints.
+type {{.Client}} struct {
+	// Host is the scheme and host of the service, ie "http://localhost:8080".
+	Host string
+	// Client will be used for all requests.
+	Client *http.Client
+}
+
+// New{{.Client}} will return a new {{.Client}} for the given host. If client is nil,
+// one will be created with httpclient.New and the default config.
+func New{{.Client}}(host string, client *http.Client) *{{.Client}} {
+	if client == nil {
+		client = httpclient.New(nil)
+	}
+	return &{{.Client}}{Host: strings.TrimRight(host, "/"), Client: client}
+}
+
+// {{.Client}}Error is returned when the service responds with a non-2xx status code.
+type {{.Client}}Error struct {
+	StatusCode int
+	Body       []byte
+}
+
+func (e *{{.Client}}Error) Error() string {
+	return fmt.Sprintf("unexpected status code %d: %s", e.StatusCode, strings.TrimSpace(string(e.Body)))
+}
+{{range .Methods}}
+// {{.Name}} will make a {{.Method}} request to {{.Path}}.{{if .Summary}}
+// {{.Summary}}{{end}}{{if .Deprecated}}
+//
+// Deprecated: the service has deprecated this endpoint.{{end}}
+func (c *{{$.Client}}) {{.Name}}({{range .Params}}{{.}} string, {{end}}{{if .Request}}body {{.Request}}{{end}}) ({{if .Response}}{{.Result}}, {{end}}error) {
+	{{if .Response}}var out {{.Response}}
+	err := c.do("{{.Method}}", {{.PathExpr}}, {{if .Request}}body{{else}}nil{{end}}, &out)
+	if err != nil {
+		return nil, err
+	}
+	return {{if eq .Result .Response}}out{{else}}&out{{end}}, nil{{else}}return c.do("{{.Method}}", {{.PathExpr}}, {{if .Request}}body{{else}}nil{{end}}, nil){{end}}
+}
+{{end}}
+func (c *{{.Client}}) escape(s string) string {
+	return strings.Replace(url.QueryEscape(s), "+", "%20", -1)
+}
+
+func (c *{{.Client}}) do(method, path string, in, out interface{}) error {
+	var body io.Reader
+	if in != nil {
+		b, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(b)
+	}
+	req, err := http.NewRequest(method, c.Host+path, body)
+	if err != nil {
+		return err
+	}
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := c.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		b, _ := ioutil.ReadAll(resp.Body)
+		return &{{.Client}}Error{StatusCode: resp.StatusCode, Body: b}
+	}
+	if out == nil {
+		_, err = io.Copy(ioutil.Discard, resp.Body)
+		return err
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+`))
@@ -0,0 +1,124 @@
+package clientgen
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/NYTimes/gizmo/server"
+)
+
+type testCat struct {
+	Name string
+}
+
+type testCatsService struct{}
+
+func (s *testCatsService) Prefix() string {
+	return "/svc/cats"
+}
+
+func (s *testCatsService) JSONEndpoints() map[string]map[string]server.JSONEndpoint {
+	noop := func(r *http.Request) (int, interface{}, error) { return http.StatusOK, nil, nil }
+	return map[string]map[string]server.JSONEndpoint{
+		"/cats/{id:[0-9]+}": map[string]server.JSONEndpoint{
+			"GET": noop,
+			"PUT": noop,
+		},
+		"/search": map[string]server.JSONEndpoint{
+			"GET": noop,
+		},
+	}
+}
+
+func (s *testCatsService) EndpointDocs() map[string]map[string]*server.EndpointDoc {
+	return map[string]map[string]*server.EndpointDoc{
+		"/cats/{id:[0-9]+}": map[string]*server.EndpointDoc{
+			"GET": &server.EndpointDoc{
+				Name:     "GetCat",
+				Summary:  "GetCat returns a single cat.",
+				Response: &testCat{},
+			},
+			"PUT": &server.EndpointDoc{
+				Request:    &testCat{},
+				Response:   []*testCat{},
+				Deprecated: true,
+			},
+		},
+	}
+}
+
+func (s *testCatsService) JSONMiddleware(ep server.JSONEndpoint) server.JSONEndpoint {
+	return ep
+}
+
+func (s *testCatsService) Middleware(h http.Handler) http.Handler {
+	return h
+}
+
+func TestGenerateService(t *testing.T) {
+	g := &Generator{Package: "catsclient", Client: "CatsClient"}
+	var buf bytes.Buffer
+	if err := g.GenerateService(&buf, &testCatsService{}); err != nil {
+		t.Fatal("unexpected error generating client: ", err)
+	}
+	src := buf.String()
+
+	tests := []string{
+		"package catsclient",
+		`"github.com/NYTimes/gizmo/clientgen"`,
+		"func NewCatsClient(host string, client *http.Client) *CatsClient {",
+		"func (c *CatsClient) GetCat(id string) (*clientgen.testCat, error) {",
+		`c.do("GET", "/svc/cats/cats/"+c.escape(id), nil, &out)`,
+		"// Deprecated: the service has deprecated this endpoint.",
+		"func (c *CatsClient) PutCats(id string, body *clientgen.testCat) ([]*clientgen.testCat, error) {",
+		"func (c *CatsClient) GetSearch() error {",
+	}
+	for _, want := range tests {
+		if !strings.Contains(src, want) {
+			t.Errorf("expected generated client to contain %q, got:\n%s", want, src)
+		}
+	}
+
+	// types from the client's own package should not be qualified
+	g.ImportPath = "github.com/NYTimes/gizmo/clientgen"
+	buf.Reset()
+	if err := g.GenerateService(&buf, &testCatsService{}); err != nil {
+		t.Fatal("unexpected error generating client: ", err)
+	}
+	if want := "GetCat(id string) (*testCat, error)"; !strings.Contains(buf.String(), want) {
+		t.Errorf("expected generated client to contain %q, got:\n%s", want, buf.String())
+	}
+}
+
+func TestMethodName(t *testing.T) {
+	tests := []struct {
+		routes []server.Route
+
+		want []string
+	}{
+		{
+			[]server.Route{{Method: "GET", Path: "/svc/v1/cats"}, {Method: "POST", Path: "/svc/v1/cats/{id}/toys"}},
+			[]string{"GetCats", "PostCatsToys"},
+		},
+		{
+			[]server.Route{{Method: "DELETE", Path: "/svc/v1/cat-toys/:id"}},
+			[]string{"DeleteCatToys"},
+		},
+	}
+
+	for _, test := range tests {
+		prefix := commonPrefix(test.routes)
+		f := &file{Generator: &Generator{}, imports: map[string]bool{}}
+		for i, route := range test.routes {
+			m, err := f.newMethod(route, prefix)
+			if err != nil {
+				t.Fatalf("unexpected error for %s %s: %s", route.Method, route.Path, err)
+			}
+			if m.Name != test.want[i] {
+				t.Errorf("expected method name of %q for %s %s, got %q", test.want[i], route.Method, route.Path, m.Name)
+			}
+		}
+	}
+}
@@ -0,0 +1,39 @@
+/*
+Package clientgen generates typed Go clients for the routes registered by
+gizmo services, so internal callers get compile-checked clients instead of
+hand-written URL strings.
+
+Services describe the request and response types of their endpoints by implementing
+the optional `server.DocumentedService` interface:
+
+	func (s *CatsService) EndpointDocs() map[string]map[string]*server.EndpointDoc {
+	    return map[string]map[string]*server.EndpointDoc{
+	        "/cats/{id}": map[string]*server.EndpointDoc{
+	            "GET": &server.EndpointDoc{
+	                Name:     "GetCat",
+	                Response: &Cat{},
+	            },
+	        },
+	    }
+	}
+
+A small program, usually run via `go generate`, can then write the client:
+
+	g := &clientgen.Generator{
+	    Package:    "catsclient",
+	    ImportPath: "github.com/nytimes/catsclient",
+	    Client:     "Client",
+	}
+	f, _ := os.Create("client.go")
+	err := g.GenerateService(f, &cats.CatsService{})
+
+Which will produce methods like:
+
+	func (c *Client) GetCat(id string) (*cats.Cat, error)
+
+Routes without an EndpointDoc will still get a method, but it will send
+no request body and discard the response body.
+
+Generated clients use the `httpclient` package for their default *http.Client.
+*/
+package clientgen
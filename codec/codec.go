@@ -0,0 +1,141 @@
+package codec
+
+import (
+	"encoding/json"
+	"errors"
+	"mime"
+	"strconv"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+	ugorji "github.com/ugorji/go/codec"
+)
+
+// The content types of the codecs offered by this package.
+const (
+	JSONContentType     = "application/json"
+	ProtobufContentType = "application/x-protobuf"
+	MsgPackContentType  = "application/x-msgpack"
+	CBORContentType     = "application/cbor"
+)
+
+// ErrNotProtobuf is returned by the Protobuf codec when given a value
+// that is not a proto.Message.
+var ErrNotProtobuf = errors.New("codec: value is not a proto.Message")
+
+// Codec is a generic interface for encoding and decoding values to and from
+// a wire format.
+type Codec interface {
+	// ContentType is the media type of the encoded values.
+	ContentType() string
+	// Marshal will encode the value.
+	Marshal(interface{}) ([]byte, error)
+	// Unmarshal will decode the bytes into the value.
+	Unmarshal([]byte, interface{}) error
+}
+
+var (
+	// JSON encodes values with encoding/json.
+	JSON Codec = jsonCodec{}
+	// Protobuf encodes proto.Messages with github.com/golang/protobuf.
+	Protobuf Codec = protobufCodec{}
+	// MsgPack encodes values as MessagePack.
+	MsgPack Codec = &ugorjiCodec{MsgPackContentType, &ugorji.MsgpackHandle{}}
+	// CBOR encodes values as CBOR (RFC 7049).
+	CBOR Codec = &ugorjiCodec{CBORContentType, &ugorji.CborHandle{}}
+
+	// Codecs are the codecs that ForContentType and Negotiate will choose from.
+	Codecs = []Codec{JSON, Protobuf, MsgPack, CBOR}
+)
+
+// ForContentType will return the codec for the given Content-Type
+// header value, if any.
+func ForContentType(contentType string) (Codec, bool) {
+	mt, _, err := mime.ParseMediaType(strings.TrimSpace(contentType))
+	if err != nil {
+		return nil, false
+	}
+	for _, c := range Codecs {
+		if c.ContentType() == mt {
+			return c, true
+		}
+	}
+	return nil, false
+}
+
+// Negotiate will return the codec for the most preferred media type listed
+// in the given Accept header value, if any. Media types are ranked by their
+// 'q' parameter and then by the order they are listed.
+func Negotiate(accept string) (Codec, bool) {
+	var (
+		best  Codec
+		bestQ float64
+	)
+	for _, part := range strings.Split(accept, ",") {
+		mt, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if qs, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(qs, 64); err != nil {
+				continue
+			}
+		}
+		if q <= bestQ {
+			continue
+		}
+		for _, c := range Codecs {
+			if c.ContentType() == mt {
+				best, bestQ = c, q
+				break
+			}
+		}
+	}
+	return best, best != nil
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) ContentType() string { return JSONContentType }
+
+func (jsonCodec) Marshal(v interface{}) ([]byte, error) { return json.Marshal(v) }
+
+func (jsonCodec) Unmarshal(b []byte, v interface{}) error { return json.Unmarshal(b, v) }
+
+type protobufCodec struct{}
+
+func (protobufCodec) ContentType() string { return ProtobufContentType }
+
+func (protobufCodec) Marshal(v interface{}) ([]byte, error) {
+	pb, ok := v.(proto.Message)
+	if !ok {
+		return nil, ErrNotProtobuf
+	}
+	return proto.Marshal(pb)
+}
+
+func (protobufCodec) Unmarshal(b []byte, v interface{}) error {
+	pb, ok := v.(proto.Message)
+	if !ok {
+		return ErrNotProtobuf
+	}
+	return proto.Unmarshal(b, pb)
+}
+
+type ugorjiCodec struct {
+	contentType string
+	handle      ugorji.Handle
+}
+
+func (c *ugorjiCodec) ContentType() string { return c.contentType }
+
+func (c *ugorjiCodec) Marshal(v interface{}) ([]byte, error) {
+	var b []byte
+	err := ugorji.NewEncoderBytes(&b, c.handle).Encode(v)
+	return b, err
+}
+
+func (c *ugorjiCodec) Unmarshal(b []byte, v interface{}) error {
+	return ugorji.NewDecoderBytes(b, c.handle).Decode(v)
+}
@@ -0,0 +1,63 @@
+package codec
+
+import "testing"
+
+func TestNegotiate(t *testing.T) {
+	tests := []struct {
+		accept string
+
+		want   Codec
+		wantOK bool
+	}{
+		{"", nil, false},
+		{"text/html", nil, false},
+		{"application/json", JSON, true},
+		{"application/json, application/x-msgpack", JSON, true},
+		{"application/json;q=0.8, application/x-msgpack", MsgPack, true},
+		{"text/html, application/cbor;q=0.1", CBOR, true},
+		{"application/x-protobuf;q=0, application/json;q=0.2", JSON, true},
+	}
+
+	for _, test := range tests {
+		got, ok := Negotiate(test.accept)
+		if ok != test.wantOK || got != test.want {
+			t.Errorf("%q: expected %v (%t), got %v (%t)", test.accept, test.want, test.wantOK, got, ok)
+		}
+	}
+}
+
+func TestForContentType(t *testing.T) {
+	if c, ok := ForContentType("application/json; charset=UTF-8"); !ok || c != JSON {
+		t.Errorf("expected JSON codec, got %v", c)
+	}
+	if c, ok := ForContentType("application/x-protobuf"); !ok || c != Protobuf {
+		t.Errorf("expected Protobuf codec, got %v", c)
+	}
+	if _, ok := ForContentType("text/plain"); ok {
+		t.Error("expected no codec for text/plain")
+	}
+}
+
+func TestCodecs(t *testing.T) {
+	type cat struct {
+		Name  string
+		Lives int
+	}
+	for _, c := range []Codec{JSON, MsgPack, CBOR} {
+		b, err := c.Marshal(cat{"tom", 9})
+		if err != nil {
+			t.Fatalf("%s: unexpected error encoding: %s", c.ContentType(), err)
+		}
+		var got cat
+		if err = c.Unmarshal(b, &got); err != nil {
+			t.Fatalf("%s: unexpected error decoding: %s", c.ContentType(), err)
+		}
+		if got.Name != "tom" || got.Lives != 9 {
+			t.Errorf("%s: expected tom with 9 lives, got %+v", c.ContentType(), got)
+		}
+	}
+
+	if _, err := Protobuf.Marshal(struct{}{}); err != ErrNotProtobuf {
+		t.Errorf("expected ErrNotProtobuf, got %v", err)
+	}
+}
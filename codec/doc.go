@@ -0,0 +1,28 @@
+/*
+Package codec contains the wire formats that gizmo servers and pubsub
+messages can be encoded with. There are codecs for:
+
+  - JSON
+  - Protobuf
+  - MessagePack
+  - CBOR
+
+MessagePack and CBOR are useful for bandwidth-sensitive mobile and IoT clients
+that want a more compact format than JSON without generating protobuf types.
+
+Codecs can be chosen by a request's headers:
+
+	c, ok := codec.Negotiate(r.Header.Get("Accept"))
+	if !ok {
+	    c = codec.JSON
+	}
+	b, err := c.Marshal(res)
+
+To migrate producers to a new payload format without consumer downtime, a
+DualRead codec decodes with the new codec first and falls back to the legacy
+one, counting which path was used in metrics:
+
+	c := codec.NewDualRead("cats", codec.JSON, codec.Base64(codec.Protobuf), nil)
+	err := pubsub.DecodeMessage(c, msg, &cat)
+*/
+package codec
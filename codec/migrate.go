@@ -0,0 +1,95 @@
+package codec
+
+import (
+	"encoding/base64"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/rcrowley/go-metrics"
+)
+
+// Base64 will return a Codec that base64 encodes the output of c, like the
+// protobufs published to SNS by the pubsub package.
+func Base64(c Codec) Codec {
+	return base64Codec{c}
+}
+
+type base64Codec struct {
+	Codec
+}
+
+func (c base64Codec) Marshal(v interface{}) ([]byte, error) {
+	b, err := c.Codec.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]byte, base64.StdEncoding.EncodedLen(len(b)))
+	base64.StdEncoding.Encode(out, b)
+	return out, nil
+}
+
+func (c base64Codec) Unmarshal(b []byte, v interface{}) error {
+	dec := make([]byte, base64.StdEncoding.DecodedLen(len(b)))
+	n, err := base64.StdEncoding.Decode(dec, b)
+	if err != nil {
+		return err
+	}
+	return c.Codec.Unmarshal(dec[:n], v)
+}
+
+// DualRead is a Codec for migrating producers from a legacy payload format
+// without consumer downtime. It encodes with the Current codec and decodes
+// with Current first, falling back to Legacy if that fails. Current must
+// reject legacy payloads for the fallback to happen.
+//
+// Each decode is counted in the 'codec.<name>.CURRENT', 'codec.<name>.LEGACY'
+// or, if neither codec succeeds, 'codec.<name>.ERROR' metrics so the legacy
+// path can be removed once producers have moved over.
+type DualRead struct {
+	Current Codec
+	Legacy  Codec
+
+	current, legacy, failed metrics.Counter
+}
+
+// NewDualRead will return a DualRead codec that records its metrics under
+// the given name in the registry. If registry is nil, the default metrics
+// registry is used.
+func NewDualRead(name string, current, legacy Codec, registry metrics.Registry) *DualRead {
+	return &DualRead{
+		Current: current,
+		Legacy:  legacy,
+		current: metrics.GetOrRegisterCounter("codec."+name+".CURRENT", registry),
+		legacy:  metrics.GetOrRegisterCounter("codec."+name+".LEGACY", registry),
+		failed:  metrics.GetOrRegisterCounter("codec."+name+".ERROR", registry),
+	}
+}
+
+// ContentType will return the content type of the Current codec.
+func (d *DualRead) ContentType() string {
+	return d.Current.ContentType()
+}
+
+// Marshal will encode the value with the Current codec.
+func (d *DualRead) Marshal(v interface{}) ([]byte, error) {
+	return d.Current.Marshal(v)
+}
+
+// Unmarshal will decode the bytes with the Current codec or, if that fails,
+// the Legacy codec. If both fail, the Current codec's error is returned.
+func (d *DualRead) Unmarshal(b []byte, v interface{}) error {
+	err := d.Current.Unmarshal(b, v)
+	if err == nil {
+		d.current.Inc(1)
+		return nil
+	}
+	// clear anything the failed decode may have set
+	if pb, ok := v.(proto.Message); ok {
+		pb.Reset()
+	}
+	if lerr := d.Legacy.Unmarshal(b, v); lerr == nil {
+		d.legacy.Inc(1)
+		return nil
+	}
+	d.failed.Inc(1)
+	return err
+}
@@ -0,0 +1,50 @@
+package codec
+
+import (
+	"testing"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+func TestDualRead(t *testing.T) {
+	type cat struct {
+		Name  string `json:"name" codec:"name"`
+		Lives int    `json:"lives" codec:"lives"`
+	}
+	legacy := Base64(MsgPack)
+	registry := metrics.NewRegistry()
+	c := NewDualRead("cats", JSON, legacy, registry)
+
+	want := cat{"tabby", 9}
+	old, err := legacy.Marshal(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	current, err := c.Marshal(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(current) != `{"name":"tabby","lives":9}` {
+		t.Errorf("expected to encode with the current codec, got %s", current)
+	}
+
+	for _, b := range [][]byte{current, old} {
+		var got cat
+		if err := c.Unmarshal(b, &got); err != nil {
+			t.Fatalf("unexpected error decoding %q: %s", b, err)
+		}
+		if got != want {
+			t.Errorf("expected %+v, got %+v", want, got)
+		}
+	}
+	var got cat
+	if err := c.Unmarshal([]byte("nope!"), &got); err == nil {
+		t.Error("expected an error when neither codec can decode")
+	}
+
+	for name, want := range map[string]int64{"CURRENT": 1, "LEGACY": 1, "ERROR": 1} {
+		if got := metrics.GetOrRegisterCounter("codec.cats."+name, registry).Count(); got != want {
+			t.Errorf("expected %d %s decodes, got %d", want, name, got)
+		}
+	}
+}
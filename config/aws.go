@@ -1,15 +1,8 @@
 package config
 
 import (
-	"fmt"
-	"log"
+	"strings"
 	"time"
-
-	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/aws/credentials"
-	"github.com/aws/aws-sdk-go/aws/session"
-	"github.com/aws/aws-sdk-go/service/elasticache"
-	"github.com/bradfitz/gomemcache/memcache"
 )
 
 const (
@@ -26,12 +19,39 @@ type (
 		AccessKey string `envconfig:"AWS_ACCESS_KEY"`
 
 		Region string `envconfig:"AWS_REGION"`
+
+		// MaxIdleConnsPerHost is the size of the idle connection pool kept
+		// for each AWS endpoint by clients that support transport tuning,
+		// like the SNS publisher and SQS subscriber. Raising it avoids
+		// connection churn under high throughput. If 0, the Go default of 2
+		// is used.
+		MaxIdleConnsPerHost int `envconfig:"AWS_HTTP_MAX_IDLE_CONNS_PER_HOST"`
+		// TLSHandshakeTimeout is the time limit for completing a TLS
+		// handshake. Defaults to 10 seconds.
+		TLSHandshakeTimeout time.Duration `envconfig:"AWS_HTTP_TLS_HANDSHAKE_TIMEOUT"`
+		// KeepAlive is the period between TCP keep-alive probes on open
+		// connections. Defaults to 30 seconds.
+		KeepAlive time.Duration `envconfig:"AWS_HTTP_KEEP_ALIVE"`
+
+		// UseFIPSEndpoint will make clients use FIPS 140-2 validated
+		// endpoints, as required in GovCloud regions.
+		UseFIPSEndpoint bool `envconfig:"AWS_USE_FIPS_ENDPOINT"`
+		// UseDualStackEndpoint will make clients use endpoints that
+		// accept both IPv4 and IPv6 connections.
+		UseDualStackEndpoint bool `envconfig:"AWS_USE_DUALSTACK_ENDPOINT"`
+		// STSRegionalEndpoint will make clients fetch temporary
+		// credentials from the STS endpoint of their Region rather than
+		// the global one.
+		STSRegionalEndpoint bool `envconfig:"AWS_USE_STS_REGIONAL_ENDPOINT"`
 	}
 
 	// SQS holds the info required to work with Amazon SQS
 	SQS struct {
 		AWS
 		QueueName string `envconfig:"AWS_SQS_NAME"`
+		// Endpoint will override the default SQS endpoint, for use with
+		// SQS compatible queues like localstack or ElasticMQ.
+		Endpoint string `envconfig:"AWS_SQS_ENDPOINT"`
 		// MaxMessages will override the DefaultSQSMaxMessages.
 		MaxMessages *int64 `envconfig:"AWS_SQS_MAX_MESSAGES"`
 		// TimeoutSeconds will override the DefaultSQSTimeoutSeconds.
@@ -44,18 +64,144 @@ type (
 		// before returning it. If it is not set in the config, the flag will default
 		// to 'true'.
 		ConsumeBase64 *bool `envconfig:"AWS_SQS_CONSUME_BASE64"`
+		// MaxDecodedSize is the most bytes a base64 message body will be
+		// decoded into. Larger messages are logged and have an empty body.
+		// Defaults to the pubsub.DefaultMaxDecodedSize, and a negative size
+		// has no limit.
+		MaxDecodedSize int64 `envconfig:"AWS_SQS_MAX_DECODED_SIZE"`
+		// AttributeNames are the system attributes, like 'SentTimestamp'
+		// and 'ApproximateReceiveCount', that will be requested with each
+		// message. If nil, the subscriber's defaults are requested.
+		AttributeNames []string
+		// AttributeNamesString is used when loading the list from environment
+		// variables. If loaded via the LoadAWSFromEnv() func, AttributeNames
+		// will get updated with these values.
+		AttributeNamesString string `envconfig:"AWS_SQS_ATTRIBUTE_NAMES"`
+		// MessageAttributeNames are the message attributes that will be
+		// requested with each message. If nil, all are requested. The
+		// transport encoding attribute is always requested.
+		MessageAttributeNames []string
+		// MessageAttributeNamesString is used when loading the list from
+		// environment variables. If loaded via the LoadAWSFromEnv() func,
+		// MessageAttributeNames will get updated with these values.
+		MessageAttributeNamesString string `envconfig:"AWS_SQS_MESSAGE_ATTRIBUTE_NAMES"`
+		// FailoverRegions are the other regions the queue is replicated
+		// to, for consuming every region at once.
+		FailoverRegions []string
+		// FailoverRegionsString is used when loading the list from
+		// environment variables. If loaded via the LoadAWSFromEnv() func,
+		// FailoverRegions will get updated with these values.
+		FailoverRegionsString string `envconfig:"AWS_SQS_FAILOVER_REGIONS"`
+		// SubscribedTopic is the ARN of the SNS topic the queue is
+		// subscribed to, for exporting the queue's infrastructure.
+		SubscribedTopic string `envconfig:"AWS_SQS_SUBSCRIBED_TOPIC"`
+		// DeadLetterQueueName is the queue messages are moved to once they
+		// have been received MaxReceiveCount times, for exporting the
+		// queue's infrastructure.
+		DeadLetterQueueName string `envconfig:"AWS_SQS_DEAD_LETTER_QUEUE_NAME"`
+		// MaxReceiveCount is how many times a message is received before it
+		// is moved to the DeadLetterQueueName.
+		MaxReceiveCount int `envconfig:"AWS_SQS_MAX_RECEIVE_COUNT"`
 	}
 
 	// SNS holds the info required to work with Amazon SNS.
 	SNS struct {
 		AWS
 		Topic string `envconfig:"AWS_SNS_TOPIC"`
+		// Endpoint will override the default SNS endpoint, for use with
+		// SNS compatible topics like localstack or goaws.
+		Endpoint string `envconfig:"AWS_SNS_ENDPOINT"`
+		// TransportEncoding is how message bodies are encoded. 'base64',
+		// the default, encodes every body. 'auto' sends JSON bodies as they
+		// are and only base64 encodes binary ones, marking each message with
+		// the encoding used so subscribers can decode it. Queues subscribed
+		// to the topic need raw message delivery for the mark to reach them.
+		TransportEncoding string `envconfig:"AWS_SNS_TRANSPORT_ENCODING"`
+		// SubjectMode is how message keys are sent, which is as the SNS
+		// Subject by default. SNS rejects subjects over 100 characters or
+		// with non-ASCII or control characters, so 'omit' sends no subject,
+		// 'truncate' makes such keys valid subjects with a hash suffix and
+		// 'attribute' sends them in the 'key' message attribute instead.
+		SubjectMode string `envconfig:"AWS_SNS_SUBJECT_MODE"`
+		// MaxMessageSize is the most bytes a message may be published with
+		// by pubsub.NewSNSSizeLimitPublisher. Defaults to the SNS limit.
+		MaxMessageSize int `envconfig:"AWS_SNS_MAX_MESSAGE_SIZE"`
+		// SizeStrategy is how larger messages are handled: 'error', the
+		// default, fails the publish, 'compress' gzips them and 'offload'
+		// stores them in S3 and publishes their key instead.
+		SizeStrategy string `envconfig:"AWS_SNS_SIZE_STRATEGY"`
+		// OffloadPrefix is prepended to the S3 keys of offloaded messages.
+		OffloadPrefix string `envconfig:"AWS_SNS_OFFLOAD_PREFIX"`
+		// FailoverTopics are the ARNs of the same topic in other regions
+		// that a regional publisher will route to by health and latency.
+		FailoverTopics []string
+		// FailoverTopicsString is used when loading the list from environment
+		// variables. If loaded via the LoadAWSFromEnv() func, FailoverTopics
+		// will get updated with these values.
+		FailoverTopicsString string `envconfig:"AWS_SNS_FAILOVER_TOPICS"`
+	}
+
+	// SES holds the info required to send email notifications
+	// with Amazon SES.
+	SES struct {
+		AWS
+		// Source is the address emails will be sent from.
+		Source string `envconfig:"AWS_SES_SOURCE"`
+		// ReplyTo is an optional address replies will be sent to.
+		ReplyTo string `envconfig:"AWS_SES_REPLY_TO"`
+	}
+
+	// SMS holds the info required to send SMS notifications
+	// with Amazon SNS.
+	SMS struct {
+		AWS
+		// SMSType is either 'Transactional' or 'Promotional'.
+		SMSType string `envconfig:"AWS_SNS_SMS_TYPE"`
+		// SenderID is an optional name shown as the sender in
+		// countries that support it.
+		SenderID string `envconfig:"AWS_SNS_SMS_SENDER_ID"`
+	}
+
+	// EventBridge holds the info required to publish events to an
+	// Amazon EventBridge event bus and to consume them from an SQS
+	// queue targeted by an EventBridge rule.
+	EventBridge struct {
+		AWS
+		// EventBus is the name or ARN of the event bus. Defaults
+		// to the account's 'default' bus.
+		EventBus string `envconfig:"AWS_EVENTBRIDGE_BUS"`
+		// Source is the source of published events.
+		Source string `envconfig:"AWS_EVENTBRIDGE_SOURCE"`
+		// DetailType is the detail-type of published events. If
+		// empty, the key each event is published with is used.
+		DetailType string `envconfig:"AWS_EVENTBRIDGE_DETAIL_TYPE"`
+		// RuleName is the rule that routes events to the QueueName
+		// for subscribers.
+		RuleName string `envconfig:"AWS_EVENTBRIDGE_RULE_NAME"`
+		// EventPattern is the JSON event pattern of the rule.
+		EventPattern string `envconfig:"AWS_EVENTBRIDGE_EVENT_PATTERN"`
+		// QueueName is the SQS queue the rule targets and subscribers
+		// read from.
+		QueueName string `envconfig:"AWS_EVENTBRIDGE_QUEUE_NAME"`
+		// CreateRule will make subscribers create or update the rule,
+		// its target and the queue's policy at startup. Otherwise they
+		// only verify that the rule targets the queue.
+		CreateRule bool `envconfig:"AWS_EVENTBRIDGE_CREATE_RULE"`
 	}
 
 	// S3 holds the info required to work with Amazon S3.
 	S3 struct {
 		AWS
 		Bucket string `envconfig:"AWS_S3_BUCKET_NAME"`
+		// Endpoint will override the default S3 endpoint, for use with
+		// S3 compatible stores.
+		Endpoint string `envconfig:"AWS_S3_ENDPOINT"`
+		// UploadPartSize is the size in bytes of each part of a multipart
+		// upload. Defaults to the objectstore.DefaultPartSize.
+		UploadPartSize int64 `envconfig:"AWS_S3_UPLOAD_PART_SIZE"`
+		// UploadConcurrency is how many parts of a multipart upload will be
+		// sent at once. Defaults to the objectstore.DefaultUploadConcurrency.
+		UploadConcurrency int `envconfig:"AWS_S3_UPLOAD_CONCURRENCY"`
 	}
 
 	// DynamoDB holds some basic info required to work with Amazon DynamoDB.
@@ -70,42 +216,49 @@ type (
 		AWS
 		ClusterID string `envconfig:"AWS_ELASTICACHE_CLUSTER_ID"`
 	}
-)
 
-// MustClient will use the cache cluster ID to describe
-// the cache cluster and instantiate a memcache.Client
-// with the cache nodes returned from AWS.
-func (e *ElastiCache) MustClient() *memcache.Client {
-	var creds *credentials.Credentials
-	if e.AccessKey != "" {
-		creds = credentials.NewStaticCredentials(e.AccessKey, e.SecretKey, "")
-	} else {
-		creds = credentials.NewEnvCredentials()
-	}
-
-	ecclient := elasticache.New(session.New(&aws.Config{
-		Credentials: creds,
-		Region:      &e.Region,
-	}))
-
-	resp, err := ecclient.DescribeCacheClusters(&elasticache.DescribeCacheClustersInput{
-		CacheClusterId:    &e.ClusterID,
-		ShowCacheNodeInfo: aws.Bool(true),
-	})
-	if err != nil {
-		log.Fatalf("unable to describe cache cluster: %s", err)
+	// CloudWatch holds the info required to publish custom
+	// metrics to Amazon CloudWatch.
+	CloudWatch struct {
+		AWS
+		Namespace string `envconfig:"AWS_CLOUDWATCH_NAMESPACE"`
+		// Dimensions are 'name:value' pairs that will be added
+		// to every metric.
+		Dimensions []string
+		// DimensionsString is used when loading the list from environment
+		// variables. If loaded via the LoadCloudWatchFromEnv() func,
+		// Dimensions will get updated with these values.
+		DimensionsString string `envconfig:"AWS_CLOUDWATCH_DIMENSIONS"`
+		// Interval is how often metrics will be published.
+		// Defaults to 1 minute.
+		Interval time.Duration `envconfig:"AWS_CLOUDWATCH_INTERVAL"`
 	}
 
-	var nodes []string
-	for _, cluster := range resp.CacheClusters {
-		for _, cnode := range cluster.CacheNodes {
-			addr := fmt.Sprintf("%s:%d", *cnode.Endpoint.Address, *cnode.Endpoint.Port)
-			nodes = append(nodes, addr)
-		}
+	// DynamoDBStream holds the info required to consume the change
+	// records of a table from Amazon DynamoDB Streams.
+	DynamoDBStream struct {
+		AWS
+		StreamARN string `envconfig:"AWS_DYNAMODB_STREAM_ARN"`
+		// LeaseTable is the DynamoDB table used to coordinate which
+		// instance reads each shard and to checkpoint its progress.
+		LeaseTable string `envconfig:"AWS_DYNAMODB_STREAM_LEASE_TABLE"`
+		// WorkerID identifies this instance in the lease table.
+		// Defaults to the hostname and process ID.
+		WorkerID string `envconfig:"AWS_DYNAMODB_STREAM_WORKER_ID"`
+		// LeaseDuration is how long a shard lease is held without
+		// being renewed. Defaults to 30 seconds.
+		LeaseDuration time.Duration `envconfig:"AWS_DYNAMODB_STREAM_LEASE_DURATION"`
+		// PollInterval is how long to wait before reading a shard
+		// again when no records were found. Defaults to 1 second.
+		PollInterval time.Duration `envconfig:"AWS_DYNAMODB_STREAM_POLL_INTERVAL"`
+		// MaxRecords is the max number of records read from a shard
+		// at once. Defaults to 100.
+		MaxRecords int64 `envconfig:"AWS_DYNAMODB_STREAM_MAX_RECORDS"`
+		// StartFromOldest will read shards without a checkpoint from
+		// the oldest record rather than from the latest.
+		StartFromOldest bool `envconfig:"AWS_DYNAMODB_STREAM_START_FROM_OLDEST"`
 	}
-
-	return memcache.New(nodes...)
-}
+)
 
 // LoadAWSFromEnv will attempt to load the AWS structs
 // from environment variables. If not populated, nil
@@ -126,10 +279,22 @@ func LoadAWSFromEnv() (*AWS, *SNS, *SQS, *S3, *DynamoDB, *ElastiCache) {
 	LoadEnvConfig(&sns)
 	if sns.Topic == "" {
 		sns = nil
+	} else if sns.FailoverTopicsString != "" {
+		sns.FailoverTopics = strings.Split(sns.FailoverTopicsString, ",")
 	}
 	LoadEnvConfig(&sqs)
 	if sqs.QueueName == "" {
 		sqs = nil
+	} else {
+		if sqs.AttributeNamesString != "" {
+			sqs.AttributeNames = strings.Split(sqs.AttributeNamesString, ",")
+		}
+		if sqs.MessageAttributeNamesString != "" {
+			sqs.MessageAttributeNames = strings.Split(sqs.MessageAttributeNamesString, ",")
+		}
+		if sqs.FailoverRegionsString != "" {
+			sqs.FailoverRegions = strings.Split(sqs.FailoverRegionsString, ",")
+		}
 	}
 	LoadEnvConfig(&s3)
 	if s3.Bucket == "" {
@@ -145,3 +310,66 @@ func LoadAWSFromEnv() (*AWS, *SNS, *SQS, *S3, *DynamoDB, *ElastiCache) {
 	}
 	return aws, sns, sqs, s3, ddb, ec
 }
+
+// LoadCloudWatchFromEnv will attempt to load a CloudWatch object
+// from environment variables. If not populated, nil
+// is returned.
+func LoadCloudWatchFromEnv() *CloudWatch {
+	var cw CloudWatch
+	LoadEnvConfig(&cw)
+	if cw.Namespace == "" {
+		return nil
+	}
+	if cw.DimensionsString != "" {
+		cw.Dimensions = strings.Split(cw.DimensionsString, ",")
+	}
+	return &cw
+}
+
+// LoadSESFromEnv will attempt to load an SES object
+// from environment variables. If not populated, nil
+// is returned.
+func LoadSESFromEnv() *SES {
+	var ses SES
+	LoadEnvConfig(&ses)
+	if ses.Source == "" {
+		return nil
+	}
+	return &ses
+}
+
+// LoadSMSFromEnv will attempt to load an SMS object
+// from environment variables. If not populated, nil
+// is returned.
+func LoadSMSFromEnv() *SMS {
+	var sms SMS
+	LoadEnvConfig(&sms)
+	if sms.SMSType == "" {
+		return nil
+	}
+	return &sms
+}
+
+// LoadDynamoDBStreamFromEnv will attempt to load a DynamoDBStream object
+// from environment variables. If not populated, nil
+// is returned.
+func LoadDynamoDBStreamFromEnv() *DynamoDBStream {
+	var ds DynamoDBStream
+	LoadEnvConfig(&ds)
+	if ds.StreamARN == "" {
+		return nil
+	}
+	return &ds
+}
+
+// LoadEventBridgeFromEnv will attempt to load an EventBridge object
+// from environment variables. If not populated, nil
+// is returned.
+func LoadEventBridgeFromEnv() *EventBridge {
+	var eb EventBridge
+	LoadEnvConfig(&eb)
+	if eb.Source == "" && eb.RuleName == "" {
+		return nil
+	}
+	return &eb
+}
@@ -19,15 +19,25 @@ type (
 	Config struct {
 		Server *Server
 
-		AWS         *AWS
-		SQS         *SQS
-		SNS         *SNS
-		S3          *S3
-		DynamoDB    *DynamoDB
-		ElastiCache *ElastiCache
+		AWS            *AWS
+		SQS            *SQS
+		SNS            *SNS
+		S3             *S3
+		DynamoDB       *DynamoDB
+		DynamoDBStream *DynamoDBStream
+		ElastiCache    *ElastiCache
+		CloudWatch     *CloudWatch
+		SES            *SES
+		SMS            *SMS
+		EventBridge    *EventBridge
+
+		GCS *GCS
+		GCP *GCP
 
 		Kafka *Kafka
 
+		PubSub *PubSub
+
 		Oracle *Oracle
 
 		MySQL      *MySQL
@@ -35,8 +45,25 @@ type (
 
 		MongoDB *MongoDB
 
+		SQL *SQL
+
 		Cookie *Cookie
 
+		HTTPClient *HTTPClient
+		GRPCClient *GRPCClient
+
+		Webhook *Webhook
+
+		Consul *Consul
+
+		FeatureFlags *FeatureFlags
+
+		Metrics *Metrics
+
+		ErrorReporting *ErrorReporting
+
+		Notify *Notify
+
 		GraphiteHost *string `envconfig:"GRAPHITE_HOST"`
 
 		LogLevel *string `envconfig:"APP_LOG_LEVEL"`
@@ -69,12 +96,29 @@ func LoadConfigFromEnv() *Config {
 	var app Config
 	LoadEnvConfig(&app)
 	app.AWS, app.SNS, app.SQS, app.S3, app.DynamoDB, app.ElastiCache = LoadAWSFromEnv()
+	app.CloudWatch = LoadCloudWatchFromEnv()
+	app.SES = LoadSESFromEnv()
+	app.SMS = LoadSMSFromEnv()
+	app.EventBridge = LoadEventBridgeFromEnv()
+	app.GCS = LoadGCSFromEnv()
+	app.GCP = LoadGCPFromEnv()
+	app.DynamoDBStream = LoadDynamoDBStreamFromEnv()
 	app.MongoDB = LoadMongoDBFromEnv()
 	app.Kafka = LoadKafkaFromEnv()
+	app.PubSub = LoadPubSubFromEnv()
 	app.MySQL = LoadMySQLFromEnv()
 	app.Oracle = LoadOracleFromEnv()
+	app.SQL = LoadSQLFromEnv()
 	app.Cookie = LoadCookieFromEnv()
 	app.Server = LoadServerFromEnv()
+	app.HTTPClient = LoadHTTPClientFromEnv()
+	app.GRPCClient = LoadGRPCClientFromEnv()
+	app.Webhook = LoadWebhookFromEnv()
+	app.Consul = LoadConsulFromEnv()
+	app.FeatureFlags = LoadFeatureFlagsFromEnv()
+	app.Metrics = LoadMetricsFromEnv()
+	app.ErrorReporting = LoadErrorReportingFromEnv()
+	app.Notify = LoadNotifyFromEnv()
 	return &app
 }
 
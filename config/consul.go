@@ -0,0 +1,59 @@
+package config
+
+import (
+	"strings"
+	"time"
+)
+
+// Consul holds the information required to register a server with a
+// Consul agent via the discovery package.
+type Consul struct {
+	// Address is the host and port of the Consul agent's HTTP API.
+	// Defaults to '127.0.0.1:8500'.
+	Address string `envconfig:"CONSUL_HTTP_ADDR"`
+	// Datacenter will override the datacenter of the agent.
+	Datacenter string `envconfig:"CONSUL_DATACENTER"`
+	// Token is an optional ACL token.
+	Token string `envconfig:"CONSUL_HTTP_TOKEN"`
+
+	// ServiceName is the name the server will be registered under.
+	ServiceName string `envconfig:"CONSUL_SERVICE_NAME"`
+	// ServiceID is a unique ID for this instance of the service. Defaults
+	// to the ServiceName and the hostname.
+	ServiceID string `envconfig:"CONSUL_SERVICE_ID"`
+	// ServiceAddress is the address other services should use to reach
+	// this instance. Defaults to the first non-loopback IP of the host.
+	ServiceAddress string `envconfig:"CONSUL_SERVICE_ADDRESS"`
+	// Tags are added to the registration of the service.
+	Tags []string
+	// TagsString is used when loading the list from environment variables.
+	// If loaded via the LoadConsulFromEnv() func, Tags will get updated
+	// with these values.
+	TagsString string `envconfig:"CONSUL_SERVICE_TAGS"`
+
+	// CheckInterval is how often Consul will call the server's health
+	// check. Defaults to 10 seconds.
+	CheckInterval time.Duration `envconfig:"CONSUL_CHECK_INTERVAL"`
+	// CheckTimeout is the time limit for each health check call.
+	// Defaults to 5 seconds.
+	CheckTimeout time.Duration `envconfig:"CONSUL_CHECK_TIMEOUT"`
+	// DeregisterCriticalAfter will have Consul remove an instance that has
+	// been failing its health check for this long, such as one that was
+	// killed before it could deregister. If 0, it will never be removed.
+	DeregisterCriticalAfter time.Duration `envconfig:"CONSUL_DEREGISTER_CRITICAL_AFTER"`
+}
+
+// LoadConsulFromEnv will attempt to load a Consul object
+// from environment variables. If not populated, nil
+// is returned.
+func LoadConsulFromEnv() *Consul {
+	var consul Consul
+	LoadEnvConfig(&consul)
+	if consul.ServiceName == "" {
+		return nil
+	}
+	if consul.TagsString != "" {
+		consul.Tags = strings.Split(consul.TagsString, ",")
+	}
+	return &consul
+}
@@ -4,10 +4,22 @@ Package config contains a handful of structs meant for managing common configura
     * MySQL
     * MongoDB
     * Oracle
-    * AWS (SNS, SQS, S3, DynamoDB)
+    * Instrumented database/sql connections
+    * AWS (SNS, SQS, S3, DynamoDB, DynamoDB Streams, CloudWatch, SES, SNS SMS, EventBridge)
+    * Google Cloud Storage
+    * Google Cloud Pub/Sub
     * Kafka
+    * Pub/sub backends selected by name
     * Gorilla's `securecookie`
     * Gizmo Servers
+    * Outbound HTTP clients
+    * Outbound gRPC clients
+    * Outbound webhooks
+    * Consul service registration
+    * Feature flags
+    * Metrics providers
+    * Error reporting (Sentry, Rollbar)
+    * Operational notifications (Slack, webhooks)
 
 The package also has a generic `Config` type that contains all of the above types. It's meant to be a 'catch all' struct that most applications should be able to use.
 
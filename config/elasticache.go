@@ -0,0 +1,49 @@
+// +build !noaws
+
+package config
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/elasticache"
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+// MustClient will use the cache cluster ID to describe
+// the cache cluster and instantiate a memcache.Client
+// with the cache nodes returned from AWS.
+func (e *ElastiCache) MustClient() *memcache.Client {
+	var creds *credentials.Credentials
+	if e.AccessKey != "" {
+		creds = credentials.NewStaticCredentials(e.AccessKey, e.SecretKey, "")
+	} else {
+		creds = credentials.NewEnvCredentials()
+	}
+
+	ecclient := elasticache.New(session.New(&aws.Config{
+		Credentials: creds,
+		Region:      &e.Region,
+	}))
+
+	resp, err := ecclient.DescribeCacheClusters(&elasticache.DescribeCacheClustersInput{
+		CacheClusterId:    &e.ClusterID,
+		ShowCacheNodeInfo: aws.Bool(true),
+	})
+	if err != nil {
+		log.Fatalf("unable to describe cache cluster: %s", err)
+	}
+
+	var nodes []string
+	for _, cluster := range resp.CacheClusters {
+		for _, cnode := range cluster.CacheNodes {
+			addr := fmt.Sprintf("%s:%d", *cnode.Endpoint.Address, *cnode.Endpoint.Port)
+			nodes = append(nodes, addr)
+		}
+	}
+
+	return memcache.New(nodes...)
+}
@@ -0,0 +1,29 @@
+package config
+
+// ErrorReporting holds the information required to create a
+// Reporter from the reporting package.
+type ErrorReporting struct {
+	// Provider is the error reporting service to use: 'sentry' or 'rollbar'.
+	Provider string `envconfig:"ERROR_REPORTING_PROVIDER"`
+	// Environment is the name of the environment reports will be filed under.
+	Environment string `envconfig:"ERROR_REPORTING_ENVIRONMENT"`
+	// Release is an optional version of the application to include in reports.
+	Release string `envconfig:"ERROR_REPORTING_RELEASE"`
+
+	// SentryDSN is the client key DSN of a Sentry project.
+	SentryDSN string `envconfig:"SENTRY_DSN"`
+	// RollbarToken is a server side access token of a Rollbar project.
+	RollbarToken string `envconfig:"ROLLBAR_TOKEN"`
+}
+
+// LoadErrorReportingFromEnv will attempt to load an ErrorReporting
+// object from environment variables. If not populated, nil
+// is returned.
+func LoadErrorReportingFromEnv() *ErrorReporting {
+	var reporting ErrorReporting
+	LoadEnvConfig(&reporting)
+	if reporting.Provider == "" {
+		return nil
+	}
+	return &reporting
+}
@@ -0,0 +1,40 @@
+package config
+
+import (
+	"strings"
+	"time"
+)
+
+// FeatureFlags holds the information required to configure a provider
+// from the flags package.
+type FeatureFlags struct {
+	// Enabled is the list of flag keys that are on for a static provider.
+	Enabled []string
+	// EnabledString is used when loading the list from environment variables.
+	// If loaded via the LoadFeatureFlagsFromEnv() func, Enabled will get updated
+	// with these values.
+	EnabledString string `envconfig:"FEATURE_FLAGS_ENABLED"`
+
+	// UnleashURL is the base URL of an Unleash server's API,
+	// ie "http://unleash.example.com/api".
+	UnleashURL string `envconfig:"UNLEASH_URL"`
+	// UnleashAppName is the name the client will identify itself with.
+	UnleashAppName string `envconfig:"UNLEASH_APP_NAME"`
+	// UnleashRefreshInterval is how often features will be fetched from Unleash.
+	UnleashRefreshInterval time.Duration `envconfig:"UNLEASH_REFRESH_INTERVAL"`
+}
+
+// LoadFeatureFlagsFromEnv will attempt to load a FeatureFlags object
+// from environment variables. If not populated, nil
+// is returned.
+func LoadFeatureFlagsFromEnv() *FeatureFlags {
+	var flags FeatureFlags
+	LoadEnvConfig(&flags)
+	if flags.EnabledString == "" && flags.UnleashURL == "" {
+		return nil
+	}
+	if flags.EnabledString != "" {
+		flags.Enabled = strings.Split(flags.EnabledString, ",")
+	}
+	return &flags
+}
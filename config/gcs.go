@@ -0,0 +1,30 @@
+package config
+
+// GCS holds the info required to work with a Google Cloud Storage bucket.
+type GCS struct {
+	Bucket string `envconfig:"GCS_BUCKET_NAME"`
+	// CredentialsFile is the path to a service account's JSON key. If empty,
+	// the application default credentials will be used.
+	CredentialsFile string `envconfig:"GCS_CREDENTIALS_FILE"`
+	// AccessID is the email of the service account used to sign
+	// URLs. It is required to create presigned URLs.
+	AccessID string `envconfig:"GCS_ACCESS_ID"`
+	// PrivateKey is the PEM encoded private key of the service account
+	// used to sign URLs. It is required to create presigned URLs.
+	PrivateKey string `envconfig:"GCS_PRIVATE_KEY"`
+	// UploadChunkSize is the size in bytes of each chunk of a resumable
+	// upload. Defaults to the objectstore.DefaultPartSize.
+	UploadChunkSize int `envconfig:"GCS_UPLOAD_CHUNK_SIZE"`
+}
+
+// LoadGCSFromEnv will attempt to load a GCS object
+// from environment variables. If not populated, nil
+// is returned.
+func LoadGCSFromEnv() *GCS {
+	var gcs GCS
+	LoadEnvConfig(&gcs)
+	if gcs.Bucket == "" {
+		return nil
+	}
+	return &gcs
+}
@@ -0,0 +1,69 @@
+package config
+
+import (
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+// GRPCClient holds the info required to dial an outbound gRPC connection
+// via the grpcclient package. Any zero values will fall back to the
+// grpcclient package defaults.
+type GRPCClient struct {
+	// Target is the address of the service to dial. A comma separated list
+	// of addresses will be balanced across using the Balancer.
+	Target string `envconfig:"GRPC_CLIENT_TARGET"`
+	// Balancer is the load balancing policy used across the Target's
+	// addresses: 'round_robin' or 'pick_first'. Defaults to 'pick_first'.
+	Balancer string `envconfig:"GRPC_CLIENT_BALANCER"`
+
+	// Insecure will disable transport security for the connection.
+	Insecure bool `envconfig:"GRPC_CLIENT_INSECURE"`
+	// TLSCAFile is the path to a PEM encoded CA certificate used to verify
+	// the server. If empty, the host's root CAs are used.
+	TLSCAFile string `envconfig:"GRPC_CLIENT_TLS_CA_FILE"`
+	// TLSServerName will override the server name used to verify the
+	// server's certificate.
+	TLSServerName string `envconfig:"GRPC_CLIENT_TLS_SERVER_NAME"`
+
+	// DialTimeout is the time limit for establishing the connection. If set,
+	// dialing will block until the connection is ready.
+	DialTimeout time.Duration `envconfig:"GRPC_CLIENT_DIAL_TIMEOUT"`
+	// KeepaliveTime is how long the connection may be idle before the client
+	// pings the server. If 0, no keepalive pings are sent.
+	KeepaliveTime time.Duration `envconfig:"GRPC_CLIENT_KEEPALIVE_TIME"`
+	// KeepaliveTimeout is how long the client will wait for a ping to be
+	// acknowledged before closing the connection.
+	KeepaliveTimeout time.Duration `envconfig:"GRPC_CLIENT_KEEPALIVE_TIMEOUT"`
+
+	// MaxRetries is the number of additional attempts made on calls that
+	// fail with a retryable status code, like Unavailable.
+	MaxRetries int `envconfig:"GRPC_CLIENT_MAX_RETRIES"`
+	// RetryBaseDelay is the initial backoff between retries. Each retry will
+	// wait up to twice as long as the previous.
+	RetryBaseDelay time.Duration `envconfig:"GRPC_CLIENT_RETRY_BASE_DELAY"`
+	// RetryMaxDelay is the cap on the backoff between retries.
+	RetryMaxDelay time.Duration `envconfig:"GRPC_CLIENT_RETRY_MAX_DELAY"`
+	// RetryStrategy is the backoff strategy used between retries:
+	// 'exponential', 'decorrelated' or 'constant'. Defaults to 'exponential'.
+	RetryStrategy string `envconfig:"GRPC_CLIENT_RETRY_STRATEGY"`
+
+	// StartSpan is an optional hook for tracing each call. It will be called
+	// with the full method name before the call is made and the returned func
+	// will be called with its error, if any, once it is done.
+	StartSpan func(method string) func(error)
+	// MetricsRegistry will override the default metrics registry if set.
+	MetricsRegistry metrics.Registry
+}
+
+// LoadGRPCClientFromEnv will attempt to load a GRPCClient object
+// from environment variables. If not populated, nil
+// is returned.
+func LoadGRPCClientFromEnv() *GRPCClient {
+	var client GRPCClient
+	LoadEnvConfig(&client)
+	if client.Target == "" {
+		return nil
+	}
+	return &client
+}
@@ -0,0 +1,64 @@
+package config
+
+import (
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+// HTTPClient holds the info required to configure an outbound http.Client
+// via the httpclient package. Any zero values will fall back to the
+// httpclient package defaults.
+type HTTPClient struct {
+	// Timeout is the overall time limit for a request, including any retries.
+	Timeout time.Duration `envconfig:"HTTP_CLIENT_TIMEOUT"`
+	// DialTimeout is the time limit for establishing a connection.
+	DialTimeout time.Duration `envconfig:"HTTP_CLIENT_DIAL_TIMEOUT"`
+	// TLSHandshakeTimeout is the time limit for completing a TLS handshake.
+	TLSHandshakeTimeout time.Duration `envconfig:"HTTP_CLIENT_TLS_HANDSHAKE_TIMEOUT"`
+	// ResponseHeaderTimeout is the time limit for receiving response headers
+	// after writing a request.
+	ResponseHeaderTimeout time.Duration `envconfig:"HTTP_CLIENT_RESPONSE_HEADER_TIMEOUT"`
+	// MaxIdleConnsPerHost is the size of the idle connection pool kept for each host.
+	MaxIdleConnsPerHost int `envconfig:"HTTP_CLIENT_MAX_IDLE_CONNS_PER_HOST"`
+	// MaxRetries is the number of additional attempts made on replayable
+	// requests that fail or receive a 5xx response.
+	MaxRetries int `envconfig:"HTTP_CLIENT_MAX_RETRIES"`
+	// RetryBaseDelay is the initial backoff between retries. Each retry will
+	// wait up to twice as long as the previous.
+	RetryBaseDelay time.Duration `envconfig:"HTTP_CLIENT_RETRY_BASE_DELAY"`
+	// RetryMaxDelay is the cap on the backoff between retries.
+	RetryMaxDelay time.Duration `envconfig:"HTTP_CLIENT_RETRY_MAX_DELAY"`
+	// RetryStrategy is the backoff strategy used between retries:
+	// 'exponential', 'decorrelated' or 'constant'. Defaults to, and unknown
+	// strategies will fall back to, 'exponential'.
+	RetryStrategy string `envconfig:"HTTP_CLIENT_RETRY_STRATEGY"`
+	// RetryBudget is the max ratio of retries and hedged requests to requests
+	// made to each host over a 10 second window. If 0, retries are
+	// only limited by MaxRetries.
+	RetryBudget float64 `envconfig:"HTTP_CLIENT_RETRY_BUDGET"`
+	// RetryBudgetMinPerSecond is the number of retries per second that
+	// will always be allowed by the RetryBudget.
+	RetryBudgetMinPerSecond int `envconfig:"HTTP_CLIENT_RETRY_BUDGET_MIN_PER_SECOND"`
+	// HedgeDelay is how long to wait on a replayable request before sending a
+	// second attempt and using whichever response arrives first. If 0 and no
+	// HedgePercentile is set, requests will not be hedged.
+	HedgeDelay time.Duration `envconfig:"HTTP_CLIENT_HEDGE_DELAY"`
+	// HedgePercentile is the latency percentile (0-1) of each host to wait for
+	// before hedging a request. HedgeDelay is used as the minimum wait.
+	HedgePercentile float64 `envconfig:"HTTP_CLIENT_HEDGE_PERCENTILE"`
+	// ResolveSRV will send requests for hosts named like a DNS SRV record,
+	// such as '_cats._tcp.service.consul', to a target of that record.
+	ResolveSRV bool `envconfig:"HTTP_CLIENT_RESOLVE_SRV"`
+	// MetricsRegistry will override the default metrics registry if set.
+	MetricsRegistry metrics.Registry
+}
+
+// LoadHTTPClientFromEnv will attempt to load an HTTPClient object
+// from environment variables. Since the zero value is a valid
+// HTTPClient config, this will never return nil.
+func LoadHTTPClientFromEnv() *HTTPClient {
+	var client HTTPClient
+	LoadEnvConfig(&client)
+	return &client
+}
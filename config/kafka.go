@@ -1,6 +1,9 @@
 package config
 
-import "strings"
+import (
+	"strings"
+	"time"
+)
 
 // Kafka holds the basic information for working with Kafka.
 type Kafka struct {
@@ -14,6 +17,24 @@ type Kafka struct {
 	Topic     string `envconfig:"KAFKA_TOPIC"`
 
 	MaxRetry int `envconfig:"KAFKA_MAX_RETRY"`
+
+	// ResumeFrom is where a checkpointed subscriber will start consuming:
+	// 'checkpoint' to resume after the saved checkpoint, 'latest',
+	// 'earliest' or an RFC 3339 timestamp. Defaults to 'checkpoint', which
+	// starts from the latest offset if no checkpoint has been saved.
+	ResumeFrom string `envconfig:"KAFKA_RESUME_FROM"`
+	// CheckpointInterval is how often a checkpointed subscriber will
+	// save its progress. Defaults to 5 seconds.
+	CheckpointInterval time.Duration `envconfig:"KAFKA_CHECKPOINT_INTERVAL"`
+
+	// HeaderNames are the record headers subscribers will decode into each
+	// message's attributes. Names ending in '.*' match any header with that
+	// prefix. If empty, all headers are decoded.
+	HeaderNames []string
+	// HeaderNamesString is used when loading the list from environment
+	// variables. If loaded via the LoadKafkaFromEnv() func, HeaderNames will
+	// get updated with these values.
+	HeaderNamesString string `envconfig:"KAFKA_HEADER_NAMES"`
 }
 
 // LoadKafkaFromEnv will attempt to load an Kafka object
@@ -26,5 +47,8 @@ func LoadKafkaFromEnv() *Kafka {
 		return nil
 	}
 	kafka.BrokerHosts = strings.Split(kafka.BrokerHostsString, ",")
+	if kafka.HeaderNamesString != "" {
+		kafka.HeaderNames = strings.Split(kafka.HeaderNamesString, ",")
+	}
 	return &kafka
 }
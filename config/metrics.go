@@ -0,0 +1,45 @@
+package config
+
+import (
+	"strings"
+	"time"
+)
+
+// Metrics holds the information required to create a Provider from
+// the metrics package.
+type Metrics struct {
+	// Provider is the metrics backend to use: 'prometheus', 'statsd',
+	// 'dogstatsd' or 'cloudwatch'.
+	Provider string `envconfig:"METRICS_PROVIDER"`
+	// Namespace will prefix all metric names. For CloudWatch, it is the
+	// metric namespace.
+	Namespace string `envconfig:"METRICS_NAMESPACE"`
+	// StatsDAddr is the host and port of a StatsD or DogStatsD agent.
+	StatsDAddr string `envconfig:"METRICS_STATSD_ADDR"`
+	// Tags are 'key:value' pairs that will be added to all metrics by
+	// providers that support them (DogStatsD and CloudWatch dimensions).
+	Tags []string
+	// TagsString is used when loading the list from environment variables.
+	// If loaded via the LoadMetricsFromEnv() func, Tags will get updated
+	// with these values.
+	TagsString string `envconfig:"METRICS_TAGS"`
+	// Interval is how often buffered metrics will be flushed by providers
+	// that buffer (CloudWatch) and how often a go-metrics Registry will
+	// be reported. Defaults to 1 minute.
+	Interval time.Duration `envconfig:"METRICS_INTERVAL"`
+}
+
+// LoadMetricsFromEnv will attempt to load a Metrics object
+// from environment variables. If not populated, nil
+// is returned.
+func LoadMetricsFromEnv() *Metrics {
+	var metrics Metrics
+	LoadEnvConfig(&metrics)
+	if metrics.Provider == "" {
+		return nil
+	}
+	if metrics.TagsString != "" {
+		metrics.Tags = strings.Split(metrics.TagsString, ",")
+	}
+	return &metrics
+}
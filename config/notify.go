@@ -0,0 +1,35 @@
+package config
+
+import "time"
+
+// Notify holds the info required to send operational notifications with the
+// notify package. Notifications will be sent to every destination that is
+// configured.
+type Notify struct {
+	// SlackWebhookURL is the URL of a Slack incoming webhook.
+	SlackWebhookURL string `envconfig:"NOTIFY_SLACK_WEBHOOK_URL"`
+	// SlackChannel will override the incoming webhook's default channel.
+	SlackChannel string `envconfig:"NOTIFY_SLACK_CHANNEL"`
+	// SlackUsername will override the incoming webhook's default username.
+	SlackUsername string `envconfig:"NOTIFY_SLACK_USERNAME"`
+	// WebhookURL is a URL that each notification will be POSTed to as JSON.
+	WebhookURL string `envconfig:"NOTIFY_WEBHOOK_URL"`
+	// MinInterval is the shortest time between two notifications with the
+	// same title. Repeats within it are dropped. If 0, nothing is dropped.
+	MinInterval time.Duration `envconfig:"NOTIFY_MIN_INTERVAL"`
+	// Timeout is the time limit for sending a notification.
+	// Defaults to 5 seconds.
+	Timeout time.Duration `envconfig:"NOTIFY_TIMEOUT"`
+}
+
+// LoadNotifyFromEnv will attempt to load a Notify object
+// from environment variables. If not populated, nil
+// is returned.
+func LoadNotifyFromEnv() *Notify {
+	var notify Notify
+	LoadEnvConfig(&notify)
+	if notify.SlackWebhookURL == "" && notify.WebhookURL == "" {
+		return nil
+	}
+	return &notify
+}
@@ -0,0 +1,72 @@
+package config
+
+// GCP holds the info required to work with Google Cloud Pub/Sub.
+type GCP struct {
+	ProjectID string `envconfig:"GCP_PROJECT_ID"`
+	// Topic is the Pub/Sub topic messages are published to.
+	Topic string `envconfig:"GCP_PUBSUB_TOPIC"`
+	// Subscription is the Pub/Sub subscription messages are received from.
+	Subscription string `envconfig:"GCP_PUBSUB_SUBSCRIPTION"`
+	// CredentialsFile is the path to a service account's JSON key. If empty,
+	// the application default credentials will be used.
+	CredentialsFile string `envconfig:"GCP_CREDENTIALS_FILE"`
+}
+
+// PubSub holds the info required to create a pubsub.Publisher or
+// pubsub.Subscriber by name with pubsub.NewPublisher and
+// pubsub.NewSubscriber, so the backend can be switched with config alone.
+type PubSub struct {
+	// Provider is the backend to use: 'sns', 'sqs', 'kafka', 'gcp', 'mem' or
+	// the name of a backend added with pubsub.RegisterProvider. SNS only
+	// publishes and SQS only subscribes.
+	Provider string `envconfig:"PUBSUB_PROVIDER"`
+	// MemTopic is the name of the in-process topic used by the 'mem'
+	// provider. Publishers and subscribers with the same name are connected.
+	MemTopic string `envconfig:"PUBSUB_MEM_TOPIC"`
+	// Options holds the settings of backends added with
+	// pubsub.RegisterProvider. In the environment, it is a comma separated
+	// list of 'key:value' pairs.
+	Options map[string]string `envconfig:"PUBSUB_OPTIONS"`
+	// Verify will make pubsub.NewPublisher and pubsub.NewSubscriber check
+	// that their topic or queue exists and is accessible before returning,
+	// so misconfigurations fail at boot rather than at the first publish.
+	Verify bool `envconfig:"PUBSUB_VERIFY"`
+	// Service, ServiceVersion and Environment will be stamped on every
+	// message published by pubsub.NewPublisher, if set, along with the
+	// time it was published.
+	Service        string `envconfig:"PUBSUB_SERVICE"`
+	ServiceVersion string `envconfig:"PUBSUB_SERVICE_VERSION"`
+	Environment    string `envconfig:"PUBSUB_ENVIRONMENT"`
+
+	SNS   *SNS
+	SQS   *SQS
+	Kafka *Kafka
+	GCP   *GCP
+}
+
+// LoadGCPFromEnv will attempt to load a GCP object
+// from environment variables. If not populated, nil
+// is returned.
+func LoadGCPFromEnv() *GCP {
+	var gcp GCP
+	LoadEnvConfig(&gcp)
+	if gcp.ProjectID == "" {
+		return nil
+	}
+	return &gcp
+}
+
+// LoadPubSubFromEnv will attempt to load a PubSub object, along with the
+// config of each backend, from environment variables. If no Provider is
+// set, nil is returned.
+func LoadPubSubFromEnv() *PubSub {
+	var ps PubSub
+	LoadEnvConfig(&ps)
+	if ps.Provider == "" {
+		return nil
+	}
+	_, ps.SNS, ps.SQS, _, _, _ = LoadAWSFromEnv()
+	ps.Kafka = LoadKafkaFromEnv()
+	ps.GCP = LoadGCPFromEnv()
+	return &ps
+}
@@ -2,6 +2,7 @@ package config
 
 import (
 	"net/http"
+	"time"
 
 	"github.com/rcrowley/go-metrics"
 )
@@ -12,7 +13,8 @@ type Server struct {
 	// empty, this will default to 'simple'.
 	ServerType string `envconfig:"GIZMO_SERVER_TYPE"`
 	// HealthCheckType is used by server to init the proper HealthCheckHandler.
-	// If empty, this will default to 'simple'.
+	// If empty, this will default to 'simple'. If 'custom', the
+	// CustomHealthCheckHandler will be served at the HealthCheckPath.
 	HealthCheckType string `envconfig:"GIZMO_HEALTH_CHECK_TYPE"`
 	// RouterType is used by the server to init the proper Router implementation.
 	// If empty, this will default to 'gorilla'.
@@ -20,6 +22,15 @@ type Server struct {
 	// HealthCheckPath is used by server to init the proper HealthCheckHandler.
 	// If empty, this will default to '/status.txt'.
 	HealthCheckPath string `envconfig:"GIZMO_HEALTH_CHECK_PATH"`
+	// HealthCheckWarmup is how long the health check will fail after the server
+	// starts so load balancers don't send traffic to a cold instance.
+	HealthCheckWarmup time.Duration `envconfig:"GIZMO_HEALTH_CHECK_WARMUP"`
+	// LameDuckDuration is how long the server will keep serving requests with a
+	// failing health check after it begins shutting down.
+	LameDuckDuration time.Duration `envconfig:"GIZMO_LAME_DUCK_DURATION"`
+	// LameDuckPath is an optional path for an admin endpoint to manually enter
+	// (PUT) or leave (DELETE) lame duck mode.
+	LameDuckPath string `envconfig:"GIZMO_LAME_DUCK_PATH"`
 	// JSONContentType can be used to override the default JSONContentType.
 	JSONContentType *string `envconfig:"GIZMO_JSON_CONTENT_TYPE"`
 	// MaxHeaderBytes can be used to override the default MaxHeaderBytes (1<<20).
@@ -30,6 +41,24 @@ type Server struct {
 	// WriteTimeout can be used to override the default http server timeout of 10s.
 	// The string should be formatted like a time.Duration string.
 	WriteTimeout *string `envconfig:"GIZMO_WRITE_TIMEOUT"`
+	// MaxConcurrentRequests is the max number of requests a simple server will
+	// handle at once. If 0, the number of concurrent requests is unlimited.
+	MaxConcurrentRequests int `envconfig:"GIZMO_MAX_CONCURRENT_REQUESTS"`
+	// MaxQueuedRequests is the number of requests that may wait for a turn
+	// once MaxConcurrentRequests is reached. Any requests over this will get a 503.
+	MaxQueuedRequests int `envconfig:"GIZMO_MAX_QUEUED_REQUESTS"`
+	// MaxQueueWait is the max amount of time a queued request will wait for a
+	// turn before getting a 503. If 0, queued requests will wait indefinitely.
+	MaxQueueWait time.Duration `envconfig:"GIZMO_MAX_QUEUE_WAIT"`
+	// MaxConnsPerClient is the max number of open TCP connections a simple
+	// server will accept from a single client IP. If 0, it is unlimited.
+	MaxConnsPerClient int `envconfig:"GIZMO_MAX_CONNS_PER_CLIENT"`
+	// LoadShedLatency is the p99 latency over which a simple server will start
+	// shedding low priority requests. If 0, latency will not be considered.
+	LoadShedLatency time.Duration `envconfig:"GIZMO_LOAD_SHED_LATENCY"`
+	// LoadShedCPU is the fraction of total CPU (0-1) over which a simple server
+	// will start shedding low priority requests. If 0, CPU will not be considered.
+	LoadShedCPU float64 `envconfig:"GIZMO_LOAD_SHED_CPU"`
 	// GOMAXPROCS can be used to override the default GOMAXPROCS (runtime.NumCPU).
 	GOMAXPROCS *int `envconfig:"GIZMO_SERVER_GOMAXPROCS"`
 	// HTTPAccessLog is the location of the http access log. If it is empty,
@@ -40,14 +69,54 @@ type Server struct {
 	RPCAccessLog string `envconfig:"RPC_ACCESS_LOG"`
 	// HTTPPort is the port the server implementation will serve HTTP over.
 	HTTPPort int `envconfig:"HTTP_PORT"`
+	// HTTPSPort is an optional port the server implementation will serve HTTPS
+	// over alongside the HTTPPort. If set, TLSCertFile and TLSKeyFile are
+	// required and the HTTPPort will serve plain HTTP.
+	HTTPSPort int `envconfig:"HTTPS_PORT"`
+	// HTTPSocket is an optional path to a unix domain socket the server
+	// implementation will serve HTTP over alongside the HTTPPort.
+	HTTPSocket string `envconfig:"HTTP_SOCKET"`
 	// RPCPort is the port the server implementation will serve RPC over.
 	RPCPort int `envconfig:"RPC_PORT"`
 	// Log is the path to the application log.
 	Log string `envconfig:"APP_LOG"`
 	// LogLevel will override the default log level of 'info'.
 	LogLevel string `envconfig:"APP_LOG_LEVEL"`
+	// GracefulRestart will make server.Run start a new copy of the process on
+	// a SIGUSR2 and pass it the server's listeners so binaries can be upgraded
+	// without dropping connections. Off by default.
+	GracefulRestart bool `envconfig:"GIZMO_GRACEFUL_RESTART"`
 	// Enable pprof Profiling. Off by default.
 	EnablePProf bool `envconfig:"ENABLE_PPROF"`
+	// ProfileBucket is an optional S3 bucket that heap, goroutine and CPU
+	// profiles will be uploaded to when server.Run receives a SIGUSR1 or
+	// when the ProfilePath admin endpoint gets a POST.
+	ProfileBucket string `envconfig:"GIZMO_PROFILE_BUCKET"`
+	// ProfileRegion is the AWS region of the ProfileBucket.
+	ProfileRegion string `envconfig:"GIZMO_PROFILE_REGION"`
+	// ProfilePrefix is an optional prefix for the S3 keys of captured profiles.
+	ProfilePrefix string `envconfig:"GIZMO_PROFILE_PREFIX"`
+	// ProfileCPUDuration is how long CPU profiles will be recorded for.
+	// Defaults to 30 seconds.
+	ProfileCPUDuration time.Duration `envconfig:"GIZMO_PROFILE_CPU_DURATION"`
+	// ProfilePath is an optional path for an admin endpoint that will
+	// capture and upload profiles to the ProfileBucket on a POST.
+	ProfilePath string `envconfig:"GIZMO_PROFILE_PATH"`
+	// MessageTracePath is an optional path for an admin endpoint that will
+	// show the last messages received by each pubsub.Consumer recording to
+	// the pubsub.DefaultMessageTracer, if the server/pubsubhttp package is
+	// imported.
+	MessageTracePath string `envconfig:"GIZMO_MESSAGE_TRACE_PATH"`
+	// EnableOpenAPI will serve an OpenAPI document of all registered routes
+	// at /openapi.json and a Swagger UI at /openapi/. Off by default.
+	EnableOpenAPI bool `envconfig:"ENABLE_OPENAPI"`
+	// OpenAPITitle is the title of the served OpenAPI document.
+	OpenAPITitle string `envconfig:"OPENAPI_TITLE"`
+	// OpenAPIVersion is the API version of the served OpenAPI document.
+	OpenAPIVersion string `envconfig:"OPENAPI_VERSION"`
+	// EnableGraphQLPlayground will serve a GraphiQL playground under the
+	// endpoint of each GraphQLService. Off by default.
+	EnableGraphQLPlayground bool `envconfig:"ENABLE_GRAPHQL_PLAYGROUND"`
 	// GraphiteHost should be the host and port of an available graphite cluster.
 	// If not set, the server will not emit metrics.
 	GraphiteHost string `envconfig:"GRAPHITE_HOST"`
@@ -57,6 +126,9 @@ type Server struct {
 	TLSKeyFile *string `envconfig:"TLS_KEY"`
 	// NotFoundHandler will override the default server NotfoundHandler if set.
 	NotFoundHandler http.Handler
+	// CustomHealthCheckHandler will be used as the health check handler
+	// if the HealthCheckType is 'custom'.
+	CustomHealthCheckHandler http.Handler
 	// MetricsRegistry will override the default server metrics registry if set.
 	MetricsRegistry metrics.Registry
 }
@@ -67,7 +139,7 @@ type Server struct {
 func LoadServerFromEnv() *Server {
 	var server Server
 	LoadEnvConfig(&server)
-	if server.HTTPPort != 0 || server.RPCPort != 0 ||
+	if server.HTTPPort != 0 || server.RPCPort != 0 || server.HTTPSocket != "" ||
 		server.HTTPAccessLog != "" || server.RPCAccessLog != "" ||
 		server.HealthCheckType != "" || server.HealthCheckPath != "" {
 		return &server
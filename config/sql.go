@@ -0,0 +1,68 @@
+package config
+
+import (
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+// SQL holds the information required to open an instrumented *sql.DB
+// with the sqldb package.
+type SQL struct {
+	// Driver is the name of a registered database/sql driver, such as
+	// 'mysql' or 'postgres'. Users must import the driver in their main.
+	Driver string `envconfig:"SQL_DRIVER"`
+	// DSN is the data source name passed to the driver. For MySQL, it can
+	// be built with MySQL.String.
+	DSN string `envconfig:"SQL_DSN"`
+	// Name is used in metric names to tell databases apart. Defaults to
+	// the Driver.
+	Name string `envconfig:"SQL_NAME"`
+
+	// MaxOpenConns is the max number of open connections. If 0, it is unlimited.
+	MaxOpenConns int `envconfig:"SQL_MAX_OPEN_CONNS"`
+	// MaxIdleConns is the size of the idle connection pool. If 0, the
+	// database/sql default is used.
+	MaxIdleConns int `envconfig:"SQL_MAX_IDLE_CONNS"`
+
+	// SlowQueryThreshold is the duration over which queries will be logged
+	// as slow. If 0, no queries will be logged.
+	SlowQueryThreshold time.Duration `envconfig:"SQL_SLOW_QUERY_THRESHOLD"`
+	// MaxRetries is the number of additional attempts made on statements that
+	// fail with a transient error, like a deadlock, outside of a transaction.
+	MaxRetries int `envconfig:"SQL_MAX_RETRIES"`
+	// RetryBaseDelay is the initial backoff between retries. Each retry will
+	// wait up to twice as long as the previous.
+	RetryBaseDelay time.Duration `envconfig:"SQL_RETRY_BASE_DELAY"`
+
+	// Dialect is the SQL dialect used by the migrate package: 'postgres'
+	// or 'mysql'. Defaults to the Driver.
+	Dialect string `envconfig:"SQL_DIALECT"`
+	// MigrateOnStart will have migrate.Startup apply any pending migrations
+	// found in the MigrationsDir.
+	MigrateOnStart bool `envconfig:"SQL_MIGRATE_ON_START"`
+	// MigrationsDir is the directory of the migration files.
+	MigrationsDir string `envconfig:"SQL_MIGRATIONS_DIR"`
+	// MigrationsTable will override the migrate.DefaultTable applied
+	// migrations are recorded in.
+	MigrationsTable string `envconfig:"SQL_MIGRATIONS_TABLE"`
+
+	// StartSpan is an optional hook for tracing each statement. It will be
+	// called with the statement's name and query before it is run and the
+	// returned func will be called with its error, if any, once it is done.
+	StartSpan func(name, query string) func(error)
+	// MetricsRegistry will override the default metrics registry if set.
+	MetricsRegistry metrics.Registry
+}
+
+// LoadSQLFromEnv will attempt to load a SQL object
+// from environment variables. If not populated, nil
+// is returned.
+func LoadSQLFromEnv() *SQL {
+	var sql SQL
+	LoadEnvConfig(&sql)
+	if sql.Driver == "" {
+		return nil
+	}
+	return &sql
+}
@@ -0,0 +1,35 @@
+package config
+
+import (
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+// Webhook holds the info required to deliver webhooks with the webhook
+// package. Any zero values will fall back to the webhook package defaults.
+type Webhook struct {
+	// Timeout is the time limit for each delivery attempt.
+	Timeout time.Duration `envconfig:"WEBHOOK_TIMEOUT"`
+	// MaxAttempts is how many times a delivery will be attempted before it
+	// is sent to the dead letter store.
+	MaxAttempts int `envconfig:"WEBHOOK_MAX_ATTEMPTS"`
+	// RetryBaseDelay is the initial backoff between attempts. Each attempt
+	// will wait up to twice as long as the previous.
+	RetryBaseDelay time.Duration `envconfig:"WEBHOOK_RETRY_BASE_DELAY"`
+	// RetryMaxDelay is the cap on the backoff between attempts.
+	RetryMaxDelay time.Duration `envconfig:"WEBHOOK_RETRY_MAX_DELAY"`
+	// UserAgent will be sent with each delivery.
+	UserAgent string `envconfig:"WEBHOOK_USER_AGENT"`
+	// MetricsRegistry will override the default metrics registry if set.
+	MetricsRegistry metrics.Registry
+}
+
+// LoadWebhookFromEnv will attempt to load a Webhook object
+// from environment variables. Since the zero value is a valid
+// Webhook config, this will never return nil.
+func LoadWebhookFromEnv() *Webhook {
+	var webhook Webhook
+	LoadEnvConfig(&webhook)
+	return &webhook
+}
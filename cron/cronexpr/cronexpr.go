@@ -0,0 +1,231 @@
+package cronexpr
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule describes when a job should run.
+type Schedule interface {
+	// Next will return the next time after t the job should run,
+	// or the zero time if it should never run again.
+	Next(t time.Time) time.Time
+}
+
+// descriptors are the shorthand schedules accepted by Parse.
+var descriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// Parse will parse a standard 5 field cron spec ('minute hour day-of-month
+// month day-of-week'), a descriptor like '@hourly' or '@daily' or an interval
+// like '@every 5m'. Fields accept '*', lists, ranges, steps and, for months
+// and days of the week, 3 letter names. Intervals are aligned to the Unix
+// epoch so every instance of a service agrees on when jobs run.
+func Parse(spec string) (Schedule, error) {
+	spec = strings.TrimSpace(spec)
+	if strings.HasPrefix(spec, "@every ") {
+		d, err := time.ParseDuration(strings.TrimSpace(spec[len("@every "):]))
+		if err != nil {
+			return nil, fmt.Errorf("invalid interval in cron spec %q: %s", spec, err)
+		}
+		if d < time.Second {
+			return nil, fmt.Errorf("cron interval must be at least 1s: %q", spec)
+		}
+		return every(d), nil
+	}
+	if d, ok := descriptors[spec]; ok {
+		spec = d
+	}
+
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("expected 5 fields in cron spec %q, got %d", spec, len(fields))
+	}
+	var (
+		s   = &cronSchedule{}
+		err error
+	)
+	if s.minute, err = parseField(fields[0], minutes); err != nil {
+		return nil, err
+	}
+	if s.hour, err = parseField(fields[1], hours); err != nil {
+		return nil, err
+	}
+	if s.dom, err = parseField(fields[2], daysOfMonth); err != nil {
+		return nil, err
+	}
+	if s.month, err = parseField(fields[3], months); err != nil {
+		return nil, err
+	}
+	if s.dow, err = parseField(fields[4], daysOfWeek); err != nil {
+		return nil, err
+	}
+	// 7 is also Sunday
+	if s.dow&(1<<7) != 0 {
+		s.dow |= 1
+	}
+	s.domStar = strings.HasPrefix(fields[2], "*")
+	s.dowStar = strings.HasPrefix(fields[4], "*")
+	return s, nil
+}
+
+// MustParse is like Parse but will panic if the spec is invalid.
+func MustParse(spec string) Schedule {
+	s, err := Parse(spec)
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+// every is a Schedule that runs on a fixed interval.
+type every time.Duration
+
+func (e every) Next(t time.Time) time.Time {
+	d := int64(e)
+	ns := t.UnixNano()
+	return time.Unix(0, ns-ns%d+d).In(t.Location())
+}
+
+type bounds struct {
+	min, max int
+	names    map[string]int
+}
+
+var (
+	minutes     = bounds{0, 59, nil}
+	hours       = bounds{0, 23, nil}
+	daysOfMonth = bounds{1, 31, nil}
+	months      = bounds{1, 12, map[string]int{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}}
+	daysOfWeek = bounds{0, 7, map[string]int{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}}
+)
+
+// parseField will return a bitset of the values matched by the field.
+func parseField(field string, b bounds) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			var err error
+			if step, err = strconv.Atoi(part[i+1:]); err != nil || step < 1 {
+				return 0, fmt.Errorf("invalid step in cron field %q", field)
+			}
+			part = part[:i]
+		}
+
+		lo, hi := b.min, b.max
+		if part != "*" {
+			var err error
+			bounds := strings.SplitN(part, "-", 2)
+			if lo, err = parseValue(bounds[0], b); err != nil {
+				return 0, err
+			}
+			hi = lo
+			if len(bounds) == 2 {
+				if hi, err = parseValue(bounds[1], b); err != nil {
+					return 0, err
+				}
+			} else if step > 1 {
+				// 'n/step' means from n to the max
+				hi = b.max
+			}
+			if lo > hi {
+				return 0, fmt.Errorf("invalid range in cron field %q", field)
+			}
+		}
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+func parseValue(s string, b bounds) (int, error) {
+	if v, ok := b.names[strings.ToLower(s)]; ok {
+		return v, nil
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, errors.New("invalid cron value: " + s)
+	}
+	if v < b.min || v > b.max {
+		return 0, fmt.Errorf("cron value %d out of range %d-%d", v, b.min, b.max)
+	}
+	return v, nil
+}
+
+// cronSchedule is a Schedule parsed from a standard cron spec.
+type cronSchedule struct {
+	minute, hour, dom, month, dow uint64
+	// if either day field is unrestricted, both must match.
+	// otherwise, either may match.
+	domStar, dowStar bool
+}
+
+// maxYears is how far ahead Next will look for a matching time.
+const maxYears = 5
+
+func (s *cronSchedule) Next(t time.Time) time.Time {
+	loc := t.Location()
+	// start at the following minute
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.Year() + maxYears
+
+wrap:
+	if t.Year() > limit {
+		return time.Time{}
+	}
+	for !has(s.month, int(t.Month())) {
+		t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc)
+		if t.Month() == time.January {
+			goto wrap
+		}
+	}
+	for !s.dayMatches(t) {
+		t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+		if t.Day() == 1 {
+			goto wrap
+		}
+	}
+	for !has(s.hour, t.Hour()) {
+		t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, loc)
+		if t.Hour() == 0 {
+			goto wrap
+		}
+	}
+	for !has(s.minute, t.Minute()) {
+		t = t.Add(time.Minute)
+		if t.Minute() == 0 {
+			goto wrap
+		}
+	}
+	return t
+}
+
+func (s *cronSchedule) dayMatches(t time.Time) bool {
+	dom := has(s.dom, t.Day())
+	dow := has(s.dow, int(t.Weekday()))
+	if s.domStar || s.dowStar {
+		return dom && dow
+	}
+	return dom || dow
+}
+
+func has(bits uint64, v int) bool {
+	return bits&(1<<uint(v)) != 0
+}
@@ -0,0 +1,75 @@
+package cronexpr
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	from := time.Date(2016, time.March, 14, 10, 31, 20, 0, time.UTC)
+
+	tests := []struct {
+		spec string
+
+		want    time.Time
+		wantErr bool
+	}{
+		{"* * * * *", time.Date(2016, time.March, 14, 10, 32, 0, 0, time.UTC), false},
+		{"*/15 * * * *", time.Date(2016, time.March, 14, 10, 45, 0, 0, time.UTC), false},
+		{"5/10 * * * *", time.Date(2016, time.March, 14, 10, 35, 0, 0, time.UTC), false},
+		{"0 9-17 * * *", time.Date(2016, time.March, 14, 11, 0, 0, 0, time.UTC), false},
+		{"30 2 * * *", time.Date(2016, time.March, 15, 2, 30, 0, 0, time.UTC), false},
+		{"0 0 1 * *", time.Date(2016, time.April, 1, 0, 0, 0, 0, time.UTC), false},
+		{"0 0 * * sun", time.Date(2016, time.March, 20, 0, 0, 0, 0, time.UTC), false},
+		{"0 0 * * 7", time.Date(2016, time.March, 20, 0, 0, 0, 0, time.UTC), false},
+		{"0 0 * * MON-FRI", time.Date(2016, time.March, 15, 0, 0, 0, 0, time.UTC), false},
+		// either day field may match when both are restricted
+		{"0 0 1 * fri", time.Date(2016, time.March, 18, 0, 0, 0, 0, time.UTC), false},
+		{"0 0 29 feb *", time.Date(2020, time.February, 29, 0, 0, 0, 0, time.UTC), false},
+		{"0,30 12 * jan,jun *", time.Date(2016, time.June, 1, 12, 0, 0, 0, time.UTC), false},
+		{"@hourly", time.Date(2016, time.March, 14, 11, 0, 0, 0, time.UTC), false},
+		{"@daily", time.Date(2016, time.March, 15, 0, 0, 0, 0, time.UTC), false},
+		{"@yearly", time.Date(2017, time.January, 1, 0, 0, 0, 0, time.UTC), false},
+		{"@every 5m", time.Date(2016, time.March, 14, 10, 35, 0, 0, time.UTC), false},
+		{"@every 90s", time.Date(2016, time.March, 14, 10, 31, 30, 0, time.UTC), false},
+
+		{"0 0 31 feb *", time.Time{}, false},
+
+		{"* * * *", time.Time{}, true},
+		{"60 * * * *", time.Time{}, true},
+		{"* 5-1 * * *", time.Time{}, true},
+		{"*/0 * * * *", time.Time{}, true},
+		{"* * * smarch *", time.Time{}, true},
+		{"@every cats", time.Time{}, true},
+		{"@every 1ms", time.Time{}, true},
+	}
+
+	for _, test := range tests {
+		s, err := Parse(test.spec)
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("expected an error for spec %q, got none", test.spec)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("unexpected error for spec %q: %s", test.spec, err)
+			continue
+		}
+		if got := s.Next(from); !got.Equal(test.want) {
+			t.Errorf("expected spec %q to next run at %s, got %s", test.spec, test.want, got)
+		}
+	}
+}
+
+func TestScheduleLocation(t *testing.T) {
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip("unable to load time zone: ", err)
+	}
+	from := time.Date(2016, time.March, 14, 10, 31, 0, 0, ny)
+	want := time.Date(2016, time.March, 15, 9, 0, 0, 0, ny)
+	if got := MustParse("0 9 * * *").Next(from); !got.Equal(want) {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+}
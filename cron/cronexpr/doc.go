@@ -0,0 +1,10 @@
+/*
+Package cronexpr parses cron specs into Schedules. It has no dependencies
+outside the standard library, so packages that only need to evaluate cron
+specs, like pubsub's ConsumptionSchedule, do not link in the lockers used by
+the cron package's Scheduler.
+
+	s, err := cronexpr.Parse("0 3 * * *")
+	next := s.Next(time.Now())
+*/
+package cronexpr
@@ -0,0 +1,28 @@
+/*
+Package cron runs scheduled jobs with distributed locking so only one instance of
+a service runs each job, alongside its servers and queue consumers.
+
+Jobs are registered on a Scheduler with a cron spec, a descriptor like '@hourly'
+or an interval like '@every 5m', and optional jitter, timeout and overlap policy:
+
+	locker, err := cron.NewDynamoDBLocker(cfg.DynamoDB)
+	scheduler := cron.NewScheduler(locker)
+	err = scheduler.Register("nightly-report", "0 3 * * *", report, cron.JobOptions{
+		Jitter:  time.Minute,
+		Timeout: 30 * time.Minute,
+	})
+
+	scheduler.Start()
+	defer scheduler.Stop()
+
+	func report(ctx context.Context) error {
+		...
+	}
+
+Locks can be held in DynamoDB, Redis or Postgres advisory locks using any Locker
+from the lock package. Without a Locker, every instance will run every job.
+
+Specs are parsed by the cronexpr package, which can be used on its own to
+evaluate cron specs without linking in any of the lockers.
+*/
+package cron
@@ -0,0 +1,43 @@
+package cron
+
+import (
+	"database/sql"
+
+	"github.com/NYTimes/gizmo/config"
+	"github.com/NYTimes/gizmo/lock"
+)
+
+// DefaultRedisKeyPrefix is prepended to the name of each lock
+// stored by a Redis locker created with NewRedisLocker.
+const DefaultRedisKeyPrefix = "gizmo:cron:"
+
+// Locker is a distributed lock used by a Scheduler so only one instance
+// of a service runs each scheduled job. It is satisfied by all of the
+// lockers in the lock package.
+type Locker lock.Locker
+
+// NewLocalLocker will return an in-memory Locker for services with a
+// single instance and for tests.
+func NewLocalLocker() *lock.LocalLocker {
+	return lock.NewLocalLocker()
+}
+
+// NewDynamoDBLocker will return a Locker backed by the DynamoDB table
+// in the config. See lock.NewDynamoDBLocker.
+func NewDynamoDBLocker(cfg *config.DynamoDB) (*lock.DynamoDBLocker, error) {
+	return lock.NewDynamoDBLocker(cfg)
+}
+
+// NewRedisLocker will return a Locker backed by Redis keys under the
+// DefaultRedisKeyPrefix. See lock.NewRedisLocker.
+func NewRedisLocker(conn func() lock.RedisConn) *lock.RedisLocker {
+	l := lock.NewRedisLocker(conn)
+	l.KeyPrefix = DefaultRedisKeyPrefix
+	return l
+}
+
+// NewPostgresLocker will return a Locker backed by Postgres advisory
+// locks. See lock.NewPostgresLocker.
+func NewPostgresLocker(db *sql.DB) *lock.PostgresLocker {
+	return lock.NewPostgresLocker(db)
+}
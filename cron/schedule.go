@@ -0,0 +1,29 @@
+package cron
+
+import (
+	"time"
+
+	"github.com/NYTimes/gizmo/cron/cronexpr"
+)
+
+// Schedule describes when a job should run.
+type Schedule interface {
+	// Next will return the next time after t the job should run,
+	// or the zero time if it should never run again.
+	Next(t time.Time) time.Time
+}
+
+// Parse will parse a cron spec, a descriptor like '@hourly' or an interval
+// like '@every 5m'. See cronexpr.Parse for the accepted specs.
+func Parse(spec string) (Schedule, error) {
+	s, err := cronexpr.Parse(spec)
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// MustParse is like Parse but will panic if the spec is invalid.
+func MustParse(spec string) Schedule {
+	return cronexpr.MustParse(spec)
+}
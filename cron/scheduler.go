@@ -0,0 +1,247 @@
+package cron
+
+import (
+	"fmt"
+	"math/rand"
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/nu7hatch/gouuid"
+	"golang.org/x/net/context"
+)
+
+// Log is the structured logger used throughout the package.
+var Log = logrus.New()
+
+var (
+	// DefaultLockTTL is how long a job's lock will be held if its holder
+	// never releases it, such as when an instance crashes mid-run.
+	DefaultLockTTL = 10 * time.Minute
+	// DefaultClockSkew is the minimum time a job's lock will be held so
+	// instances with slightly different clocks don't run the same tick.
+	// It should be shorter than the interval of any locked job.
+	DefaultClockSkew = 30 * time.Second
+)
+
+// Job is a func for a scheduled job. The context will be cancelled after
+// the job's Timeout or once the Scheduler is stopped.
+type Job func(context.Context) error
+
+// OverlapPolicy is what a Scheduler should do when a job is
+// due while a previous run of it has not finished.
+type OverlapPolicy int
+
+const (
+	// OverlapSkip will skip the new run. With a Locker, a run still
+	// going on any instance will cause the new run to be skipped.
+	OverlapSkip OverlapPolicy = iota
+	// OverlapAllow will start the new run alongside the previous one.
+	OverlapAllow
+)
+
+// JobOptions are the optional settings of a scheduled job.
+type JobOptions struct {
+	// Jitter is the max random delay before each run, so jobs on the
+	// same schedule don't all hit their dependencies at once.
+	Jitter time.Duration
+	// Overlap is what to do if a run is due while the
+	// previous one is still going. Defaults to OverlapSkip.
+	Overlap OverlapPolicy
+	// Timeout will cancel the job's context if a run takes longer.
+	Timeout time.Duration
+	// LockTTL will override the Scheduler's LockTTL. It should be longer
+	// than the job's longest run to avoid runs overlapping.
+	LockTTL time.Duration
+}
+
+// Scheduler will run registered jobs on their schedules. If it has a Locker,
+// only one instance of a service will run each scheduled tick of a job.
+type Scheduler struct {
+	// Locker is used to pick which instance runs each job. If nil, every
+	// instance will run every job.
+	Locker Locker
+	// Owner identifies this instance to the Locker. Defaults to a random ID.
+	Owner string
+	// Location is the time zone schedules are evaluated in. Defaults to UTC.
+	Location *time.Location
+	// LockTTL will override the DefaultLockTTL.
+	LockTTL time.Duration
+	// ClockSkew will override the DefaultClockSkew.
+	ClockSkew time.Duration
+
+	mu   sync.Mutex
+	jobs []*scheduledJob
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+type scheduledJob struct {
+	name     string
+	schedule Schedule
+	job      Job
+	opts     JobOptions
+
+	// runs in progress on this instance
+	running int32
+}
+
+// NewScheduler will return a Scheduler that uses the given
+// Locker, which may be nil.
+func NewScheduler(locker Locker) *Scheduler {
+	owner, _ := uuid.NewV4()
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Scheduler{
+		Locker:    locker,
+		Owner:     owner.String(),
+		Location:  time.UTC,
+		LockTTL:   DefaultLockTTL,
+		ClockSkew: DefaultClockSkew,
+		ctx:       ctx,
+		cancel:    cancel,
+	}
+}
+
+// Register will add a job to run on the given cron spec. See Parse for the
+// accepted specs. The name must be unique as it is used to lock the job.
+// Jobs must be registered before the Scheduler is started.
+func (s *Scheduler) Register(name, spec string, job Job, opts JobOptions) error {
+	schedule, err := Parse(spec)
+	if err != nil {
+		return err
+	}
+	return s.RegisterSchedule(name, schedule, job, opts)
+}
+
+// RegisterSchedule will add a job to run on the given Schedule.
+func (s *Scheduler) RegisterSchedule(name string, schedule Schedule, job Job, opts JobOptions) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, j := range s.jobs {
+		if j.name == name {
+			return fmt.Errorf("cron job %q is already registered", name)
+		}
+	}
+	s.jobs = append(s.jobs, &scheduledJob{name: name, schedule: schedule, job: job, opts: opts})
+	return nil
+}
+
+// Start will begin running all registered jobs on their schedules.
+func (s *Scheduler) Start() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, j := range s.jobs {
+		s.wg.Add(1)
+		go s.loop(j)
+	}
+}
+
+// Stop will cancel any running jobs and block until they return.
+func (s *Scheduler) Stop() {
+	s.cancel()
+	s.wg.Wait()
+}
+
+func (s *Scheduler) loop(j *scheduledJob) {
+	defer s.wg.Done()
+	for {
+		next := j.schedule.Next(time.Now().In(s.Location))
+		if next.IsZero() {
+			Log.Warnf("cron job %s has no more scheduled runs", j.name)
+			return
+		}
+		timer := time.NewTimer(next.Sub(time.Now()))
+		select {
+		case <-s.ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		s.wg.Add(1)
+		go s.run(j, next)
+	}
+}
+
+// run will run the job for the scheduled tick if this
+// instance is the one to acquire its lock.
+func (s *Scheduler) run(j *scheduledJob, tick time.Time) {
+	defer s.wg.Done()
+	if j.opts.Overlap == OverlapSkip {
+		if !atomic.CompareAndSwapInt32(&j.running, 0, 1) {
+			Log.Infof("cron job %s is still running, skipping", j.name)
+			return
+		}
+		defer atomic.StoreInt32(&j.running, 0)
+	}
+
+	if j.opts.Jitter > 0 {
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-time.After(time.Duration(rand.Int63n(int64(j.opts.Jitter)))):
+		}
+	}
+
+	if s.Locker != nil {
+		// with overlaps allowed, each tick is locked separately
+		lock := j.name
+		if j.opts.Overlap == OverlapAllow {
+			lock += "@" + tick.UTC().Format(time.RFC3339Nano)
+		}
+		ttl := j.opts.LockTTL
+		if ttl == 0 {
+			ttl = s.LockTTL
+		}
+		ok, err := s.Locker.Acquire(lock, s.Owner, ttl)
+		if err != nil {
+			Log.Errorf("unable to acquire lock for cron job %s: %s", j.name, err)
+			return
+		}
+		if !ok {
+			Log.Debugf("cron job %s is locked by another instance, skipping", j.name)
+			return
+		}
+		defer s.release(lock, time.Now())
+	}
+
+	ctx := s.ctx
+	if j.opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, j.opts.Timeout)
+		defer cancel()
+	}
+	start := time.Now()
+	if err := safelyRun(ctx, j.job); err != nil {
+		Log.Errorf("cron job %s failed after %s: %s", j.name, time.Since(start), err)
+		return
+	}
+	Log.Infof("cron job %s completed in %s", j.name, time.Since(start))
+}
+
+// release will give up the lock once it has been held for at least the
+// ClockSkew, or right away if the Scheduler is stopped.
+func (s *Scheduler) release(lock string, acquired time.Time) {
+	if wait := s.ClockSkew - time.Since(acquired); wait > 0 {
+		select {
+		case <-s.ctx.Done():
+		case <-time.After(wait):
+		}
+	}
+	if err := s.Locker.Release(lock, s.Owner); err != nil {
+		Log.Warnf("unable to release lock %s: %s", lock, err)
+	}
+}
+
+// safelyRun will prevent a panicking job from bringing the service down.
+func safelyRun(ctx context.Context, job Job) (err error) {
+	defer func() {
+		if x := recover(); x != nil {
+			err = fmt.Errorf("panic: %v\n%s", x, debug.Stack())
+		}
+	}()
+	return job(ctx)
+}
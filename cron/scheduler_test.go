@@ -0,0 +1,149 @@
+package cron
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// testSchedule runs on a fixed, epoch aligned interval shorter
+// than Parse allows.
+type testSchedule time.Duration
+
+func (s testSchedule) Next(t time.Time) time.Time {
+	d := int64(s)
+	ns := t.UnixNano()
+	return time.Unix(0, ns-ns%d+d).In(t.Location())
+}
+
+func TestSchedulerLocking(t *testing.T) {
+	var (
+		mu   sync.Mutex
+		runs = map[string][]time.Time{}
+	)
+	locker := NewLocalLocker()
+	newScheduler := func(owner string) *Scheduler {
+		s := NewScheduler(locker)
+		s.Owner = owner
+		s.ClockSkew = 5 * time.Millisecond
+		err := s.RegisterSchedule("report", testSchedule(20*time.Millisecond), func(ctx context.Context) error {
+			mu.Lock()
+			runs[owner] = append(runs[owner], time.Now())
+			mu.Unlock()
+			return nil
+		}, JobOptions{})
+		if err != nil {
+			t.Fatal("unexpected error registering job: ", err)
+		}
+		return s
+	}
+
+	a, b := newScheduler("a"), newScheduler("b")
+	a.Start()
+	b.Start()
+	time.Sleep(210 * time.Millisecond)
+	a.Stop()
+	b.Stop()
+
+	mu.Lock()
+	defer mu.Unlock()
+	total := len(runs["a"]) + len(runs["b"])
+	// roughly 10 ticks, each run by only one instance
+	if total < 8 || total > 11 {
+		t.Errorf("expected each tick to run once across instances, got %d runs", total)
+	}
+}
+
+func TestSchedulerOverlap(t *testing.T) {
+	tests := []struct {
+		overlap OverlapPolicy
+
+		wantMax int32
+	}{
+		{OverlapSkip, 1},
+		{OverlapAllow, 2},
+	}
+
+	for _, test := range tests {
+		var running, max int32
+		s := NewScheduler(nil)
+		s.RegisterSchedule("slow", testSchedule(10*time.Millisecond), func(ctx context.Context) error {
+			n := atomic.AddInt32(&running, 1)
+			defer atomic.AddInt32(&running, -1)
+			for {
+				m := atomic.LoadInt32(&max)
+				if n <= m || atomic.CompareAndSwapInt32(&max, m, n) {
+					break
+				}
+			}
+			select {
+			case <-ctx.Done():
+			case <-time.After(25 * time.Millisecond):
+			}
+			return nil
+		}, JobOptions{Overlap: test.overlap})
+		s.Start()
+		time.Sleep(100 * time.Millisecond)
+		s.Stop()
+
+		if got := atomic.LoadInt32(&max); (test.overlap == OverlapSkip && got != test.wantMax) || got < test.wantMax {
+			t.Errorf("expected max %d concurrent runs with policy %d, got %d", test.wantMax, test.overlap, got)
+		}
+	}
+}
+
+func TestSchedulerStopCancelsJobs(t *testing.T) {
+	started := make(chan struct{}, 1)
+	var cancelled int32
+	s := NewScheduler(nil)
+	s.RegisterSchedule("forever", testSchedule(10*time.Millisecond), func(ctx context.Context) error {
+		select {
+		case started <- struct{}{}:
+		default:
+		}
+		<-ctx.Done()
+		atomic.StoreInt32(&cancelled, 1)
+		return ctx.Err()
+	}, JobOptions{})
+	s.Start()
+	<-started
+	s.Stop()
+	if atomic.LoadInt32(&cancelled) != 1 {
+		t.Error("expected Stop to cancel the running job")
+	}
+}
+
+func TestSchedulerRegister(t *testing.T) {
+	s := NewScheduler(nil)
+	noop := func(context.Context) error { return nil }
+	if err := s.Register("job", "@hourly", noop, JobOptions{}); err != nil {
+		t.Errorf("unexpected error registering job: %s", err)
+	}
+	if err := s.Register("job", "@daily", noop, JobOptions{}); err == nil {
+		t.Error("expected an error registering a duplicate job name")
+	}
+	if err := s.Register("bad", "every day", noop, JobOptions{}); err == nil {
+		t.Error("expected an error registering an invalid spec")
+	}
+}
+
+func TestSafelyRun(t *testing.T) {
+	tests := []struct {
+		job Job
+
+		wantErr bool
+	}{
+		{func(context.Context) error { return nil }, false},
+		{func(context.Context) error { return errors.New("failed") }, true},
+		{func(context.Context) error { panic("oh no") }, true},
+	}
+	for i, test := range tests {
+		if err := safelyRun(context.Background(), test.job); (err != nil) != test.wantErr {
+			t.Errorf("test %d: expected error %v, got %v", i, test.wantErr, err)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package discovery
+
+import (
+	"github.com/hashicorp/consul/api"
+
+	"github.com/NYTimes/gizmo/config"
+)
+
+// ConsulRegistry is a Registry backed by the service catalog of
+// the local Consul agent.
+type ConsulRegistry struct {
+	agent *api.Agent
+}
+
+// NewConsulRegistry will return a ConsulRegistry for the agent in the
+// given config. A nil config will use the agent at 127.0.0.1:8500.
+func NewConsulRegistry(cfg *config.Consul) (*ConsulRegistry, error) {
+	ccfg := api.DefaultConfig()
+	if cfg != nil {
+		if cfg.Address != "" {
+			ccfg.Address = cfg.Address
+		}
+		ccfg.Datacenter = cfg.Datacenter
+		ccfg.Token = cfg.Token
+	}
+	client, err := api.NewClient(ccfg)
+	if err != nil {
+		return nil, err
+	}
+	return &ConsulRegistry{agent: client.Agent()}, nil
+}
+
+// Register will add the instance to the agent with an HTTP check
+// against its HealthCheckURL.
+func (c *ConsulRegistry) Register(reg *Registration) error {
+	asr := &api.AgentServiceRegistration{
+		ID:      reg.ID,
+		Name:    reg.Name,
+		Tags:    reg.Tags,
+		Address: reg.Address,
+		Port:    reg.Port,
+	}
+	if reg.HealthCheckURL != "" {
+		asr.Check = &api.AgentServiceCheck{
+			HTTP:     reg.HealthCheckURL,
+			Interval: reg.CheckInterval.String(),
+			Timeout:  reg.CheckTimeout.String(),
+		}
+		if reg.DeregisterCriticalAfter > 0 {
+			asr.Check.DeregisterCriticalServiceAfter = reg.DeregisterCriticalAfter.String()
+		}
+	}
+	return c.agent.ServiceRegister(asr)
+}
+
+// Deregister will remove the instance from the agent.
+func (c *ConsulRegistry) Deregister(id string) error {
+	return c.agent.ServiceDeregister(id)
+}
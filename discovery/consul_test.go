@@ -0,0 +1,65 @@
+package discovery
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/NYTimes/gizmo/config"
+)
+
+func TestConsulRegistry(t *testing.T) {
+	var (
+		registered   map[string]interface{}
+		deregistered string
+	)
+	agent := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/v1/agent/service/register":
+			if err := json.NewDecoder(r.Body).Decode(&registered); err != nil {
+				t.Error("unable to decode registration: ", err)
+			}
+		case strings.HasPrefix(r.URL.Path, "/v1/agent/service/deregister/"):
+			deregistered = strings.TrimPrefix(r.URL.Path, "/v1/agent/service/deregister/")
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer agent.Close()
+
+	r, err := NewConsulRegistry(&config.Consul{Address: strings.TrimPrefix(agent.URL, "http://")})
+	if err != nil {
+		t.Fatal("unexpected error creating registry: ", err)
+	}
+	err = r.Register(&Registration{
+		ID:                      "cats-1",
+		Name:                    "cats",
+		Address:                 "10.0.0.1",
+		Port:                    8080,
+		HealthCheckURL:          "http://10.0.0.1:8080/status.txt",
+		CheckInterval:           10 * time.Second,
+		CheckTimeout:            time.Second,
+		DeregisterCriticalAfter: time.Minute,
+	})
+	if err != nil {
+		t.Fatal("unexpected error registering: ", err)
+	}
+	if registered["ID"] != "cats-1" || registered["Name"] != "cats" || registered["Port"] != float64(8080) {
+		t.Errorf("unexpected registration: %v", registered)
+	}
+	check, _ := registered["Check"].(map[string]interface{})
+	if check["HTTP"] != "http://10.0.0.1:8080/status.txt" || check["Interval"] != "10s" ||
+		check["DeregisterCriticalServiceAfter"] != "1m0s" {
+		t.Errorf("unexpected health check: %v", check)
+	}
+
+	if err = r.Deregister("cats-1"); err != nil {
+		t.Fatal("unexpected error deregistering: ", err)
+	}
+	if deregistered != "cats-1" {
+		t.Errorf("expected cats-1 to be deregistered, got %q", deregistered)
+	}
+}
@@ -0,0 +1,162 @@
+package discovery
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+
+	"github.com/NYTimes/gizmo/config"
+	"github.com/NYTimes/gizmo/server"
+)
+
+// Log is the structured logger used by the discovery package.
+var Log = logrus.New()
+
+var (
+	// DefaultCheckInterval is how often a registered server's health check
+	// will be called if no CheckInterval is given.
+	DefaultCheckInterval = 10 * time.Second
+	// DefaultCheckTimeout is the time limit of each health check call
+	// if no CheckTimeout is given.
+	DefaultCheckTimeout = 5 * time.Second
+)
+
+// Registration describes an instance of a service to be registered.
+type Registration struct {
+	// ID is unique to this instance of the service.
+	ID string
+	// Name is the name of the service.
+	Name string
+	// Address and Port are where the instance can be reached.
+	Address string
+	Port    int
+	// Tags are optional labels for the instance.
+	Tags []string
+
+	// HealthCheckURL is called every CheckInterval and must respond with
+	// a 2xx status for the instance to receive traffic.
+	HealthCheckURL string
+	// CheckInterval is how often the HealthCheckURL will be called.
+	CheckInterval time.Duration
+	// CheckTimeout is the time limit of each health check call.
+	CheckTimeout time.Duration
+	// DeregisterCriticalAfter is how long the health check may fail before
+	// the instance is removed. If 0, it will never be removed.
+	DeregisterCriticalAfter time.Duration
+}
+
+// Registry is a service catalog servers can register themselves with.
+type Registry interface {
+	// Register will add or update the instance.
+	Register(*Registration) error
+	// Deregister will remove the instance with the given ID.
+	Deregister(id string) error
+}
+
+// NewRegistration will build a Registration for the HTTPPort and health check
+// of the given server config.
+func NewRegistration(scfg *config.Server, cfg *config.Consul) (*Registration, error) {
+	if cfg == nil || cfg.ServiceName == "" {
+		return nil, errors.New("consul service name is required")
+	}
+	if scfg.HTTPPort == 0 {
+		return nil, errors.New("server HTTP port is required")
+	}
+	addr := cfg.ServiceAddress
+	if addr == "" {
+		var err error
+		if addr, err = localIP(); err != nil {
+			return nil, err
+		}
+	}
+	id := cfg.ServiceID
+	if id == "" {
+		host, err := os.Hostname()
+		if err != nil {
+			return nil, err
+		}
+		id = cfg.ServiceName + "-" + host
+	}
+	path := scfg.HealthCheckPath
+	if path == "" {
+		path = "/status.txt"
+	}
+	interval := cfg.CheckInterval
+	if interval == 0 {
+		interval = DefaultCheckInterval
+	}
+	timeout := cfg.CheckTimeout
+	if timeout == 0 {
+		timeout = DefaultCheckTimeout
+	}
+	return &Registration{
+		ID:                      id,
+		Name:                    cfg.ServiceName,
+		Address:                 addr,
+		Port:                    scfg.HTTPPort,
+		Tags:                    cfg.Tags,
+		HealthCheckURL:          fmt.Sprintf("http://%s%s", net.JoinHostPort(addr, strconv.Itoa(scfg.HTTPPort)), path),
+		CheckInterval:           interval,
+		CheckTimeout:            timeout,
+		DeregisterCriticalAfter: cfg.DeregisterCriticalAfter,
+	}, nil
+}
+
+// localIP will return the first non-loopback IPv4 address of the host.
+func localIP() (string, error) {
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return "", err
+	}
+	for _, a := range addrs {
+		if ipnet, ok := a.(*net.IPNet); ok && !ipnet.IP.IsLoopback() && ipnet.IP.To4() != nil {
+			return ipnet.IP.String(), nil
+		}
+	}
+	return "", errors.New("unable to find a non-loopback address to register")
+}
+
+// RegisteredServer is a server.Server that will register itself with a
+// Registry once started and deregister as it stops.
+type RegisteredServer struct {
+	server.Server
+
+	registry Registry
+	reg      *Registration
+}
+
+// Registered will wrap the server so the Registration is added to the
+// Registry on Start and removed on Stop.
+func Registered(srv server.Server, r Registry, reg *Registration) *RegisteredServer {
+	return &RegisteredServer{Server: srv, registry: r, reg: reg}
+}
+
+// Start will start the server and then register it. If it cannot be
+// registered, the server will be stopped and the error returned.
+func (s *RegisteredServer) Start() error {
+	if err := s.Server.Start(); err != nil {
+		return err
+	}
+	if err := s.registry.Register(s.reg); err != nil {
+		if serr := s.Server.Stop(); serr != nil {
+			Log.Warn("unable to stop unregistered server: ", serr)
+		}
+		return err
+	}
+	Log.Infof("registered %s as %s at %s:%d", s.reg.Name, s.reg.ID, s.reg.Address, s.reg.Port)
+	return nil
+}
+
+// Stop will deregister the server and then stop it. The server will be
+// stopped even if it cannot be deregistered.
+func (s *RegisteredServer) Stop() error {
+	if err := s.registry.Deregister(s.reg.ID); err != nil {
+		Log.Warnf("unable to deregister %s: %s", s.reg.ID, err)
+	}
+	return s.Server.Stop()
+}
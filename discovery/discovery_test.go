@@ -0,0 +1,113 @@
+package discovery
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/NYTimes/gizmo/config"
+	"github.com/NYTimes/gizmo/server"
+)
+
+type testRegistry struct {
+	calls []string
+	err   error
+}
+
+func (r *testRegistry) Register(reg *Registration) error {
+	r.calls = append(r.calls, "register "+reg.ID)
+	return r.err
+}
+
+func (r *testRegistry) Deregister(id string) error {
+	r.calls = append(r.calls, "deregister "+id)
+	return r.err
+}
+
+type testServer struct {
+	calls *[]string
+}
+
+func (s testServer) Register(server.Service) error { return nil }
+
+func (s testServer) Start() error {
+	*s.calls = append(*s.calls, "start")
+	return nil
+}
+
+func (s testServer) Stop() error {
+	*s.calls = append(*s.calls, "stop")
+	return nil
+}
+
+func TestRegisteredServer(t *testing.T) {
+	r := &testRegistry{}
+	srv := Registered(testServer{calls: &r.calls}, r, &Registration{ID: "cats-1"})
+	if err := srv.Start(); err != nil {
+		t.Fatal("unexpected error starting server: ", err)
+	}
+	if err := srv.Stop(); err != nil {
+		t.Fatal("unexpected error stopping server: ", err)
+	}
+	want := []string{"start", "register cats-1", "deregister cats-1", "stop"}
+	if !reflect.DeepEqual(r.calls, want) {
+		t.Errorf("expected calls %v, got %v", want, r.calls)
+	}
+
+	r = &testRegistry{err: errors.New("consul is down")}
+	srv = Registered(testServer{calls: &r.calls}, r, &Registration{ID: "cats-1"})
+	if err := srv.Start(); err != r.err {
+		t.Errorf("expected registration error, got %v", err)
+	}
+	want = []string{"start", "register cats-1", "stop"}
+	if !reflect.DeepEqual(r.calls, want) {
+		t.Errorf("expected calls %v, got %v", want, r.calls)
+	}
+}
+
+func TestNewRegistration(t *testing.T) {
+	reg, err := NewRegistration(
+		&config.Server{HTTPPort: 8080, HealthCheckPath: "/health"},
+		&config.Consul{ServiceName: "cats", ServiceID: "cats-1", ServiceAddress: "10.0.0.1", Tags: []string{"v1"}},
+	)
+	if err != nil {
+		t.Fatal("unexpected error: ", err)
+	}
+	want := &Registration{
+		ID:             "cats-1",
+		Name:           "cats",
+		Address:        "10.0.0.1",
+		Port:           8080,
+		Tags:           []string{"v1"},
+		HealthCheckURL: "http://10.0.0.1:8080/health",
+		CheckInterval:  DefaultCheckInterval,
+		CheckTimeout:   DefaultCheckTimeout,
+	}
+	if !reflect.DeepEqual(reg, want) {
+		t.Errorf("expected registration %#v, got %#v", want, reg)
+	}
+
+	if _, err = NewRegistration(&config.Server{}, &config.Consul{ServiceName: "cats"}); err == nil {
+		t.Error("expected an error without an HTTP port")
+	}
+	if _, err = NewRegistration(&config.Server{HTTPPort: 8080}, &config.Consul{}); err == nil {
+		t.Error("expected an error without a service name")
+	}
+
+	reg, err = NewRegistration(&config.Server{HTTPPort: 8080}, &config.Consul{
+		ServiceName:             "cats",
+		ServiceAddress:          "10.0.0.1",
+		CheckInterval:           time.Second,
+		DeregisterCriticalAfter: time.Minute,
+	})
+	if err != nil {
+		t.Fatal("unexpected error: ", err)
+	}
+	if reg.HealthCheckURL != "http://10.0.0.1:8080/status.txt" {
+		t.Errorf("expected default health check path, got %s", reg.HealthCheckURL)
+	}
+	if reg.CheckInterval != time.Second || reg.DeregisterCriticalAfter != time.Minute {
+		t.Errorf("expected configured check timings, got %s and %s", reg.CheckInterval, reg.DeregisterCriticalAfter)
+	}
+}
@@ -0,0 +1,22 @@
+/*
+Package discovery registers gizmo servers with Consul for environments without
+the service discovery of a scheduler like Kubernetes.
+
+A Registration is built from the server config and registered once the server
+has started listening, with an HTTP check against the server's health check.
+It is deregistered as the server stops, before its listeners are closed, so
+no new requests are routed to it while it shuts down:
+
+	registry, err := discovery.NewConsulRegistry(cfg.Consul)
+	reg, err := discovery.NewRegistration(cfg.Server, cfg.Consul)
+	srv := discovery.Registered(server.NewSimpleServer(cfg.Server), registry, reg)
+	err = srv.Register(&MyService{})
+	err = srv.Start()
+	...
+	err = srv.Stop()
+
+Clients can find registered services through Consul's DNS interface by setting
+ResolveSRV in the config.HTTPClient and calling hosts like
+'_cats._tcp.service.consul'.
+*/
+package discovery
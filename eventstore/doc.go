@@ -0,0 +1,31 @@
+/*
+Package eventstore is an append-only store of event streams for services
+using event sourcing, with DynamoDB, Postgres and in-memory implementations.
+
+Events are appended to a stream, usually one per aggregate, with the version the
+stream is expected to be at so concurrent writers cannot interleave:
+
+	store := eventstore.NewPostgresStore(db)
+	e, err := eventstore.NewEvent("deposited", Deposited{Amount: 10})
+	events, err := store.Append("account-123", version, e)
+	if err == eventstore.ErrWrongVersion {
+		// reload the aggregate and try again
+	}
+
+	events, err = store.Read("account-123", 1, 0)
+
+A Subscription will emit every event in a stream from a given version and then
+poll for new ones:
+
+	sub := eventstore.Subscribe(store, "account-123", lastSeen+1, time.Second)
+	defer sub.Stop()
+	for e := range sub.Events() {
+		...
+	}
+
+A PublishingStore will publish each committed event through any pubsub
+Publisher so other services can react to them:
+
+	store = eventstore.NewPublishingStore(store, pub)
+*/
+package eventstore
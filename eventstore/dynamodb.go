@@ -0,0 +1,186 @@
+package eventstore
+
+import (
+	"encoding/json"
+	"errors"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+
+	"github.com/NYTimes/gizmo/config"
+)
+
+// maxAppendAttempts is how many times an Append with AnyVersion
+// will be tried when it races with another Append.
+const maxAppendAttempts = 5
+
+// DynamoDBStore is a Store backed by a DynamoDB table. Each Append is written
+// as a single item holding all of its events so it is atomic. The table must
+// have a string hash key named 'stream' and a number range key named 'version'.
+type DynamoDBStore struct {
+	db    dynamodbiface.DynamoDBAPI
+	table string
+}
+
+// NewDynamoDBStore will initiate the DynamoDB client for the table in the
+// config. If no credentials are passed in with the config, the store is
+// instantiated with the AWS_ACCESS_KEY and the AWS_SECRET_KEY environment
+// variables.
+func NewDynamoDBStore(cfg *config.DynamoDB) (*DynamoDBStore, error) {
+	if cfg.TableName == "" {
+		return nil, errors.New("dynamodb table name is required")
+	}
+	if cfg.Region == "" {
+		return nil, errors.New("dynamodb region is required")
+	}
+
+	var creds *credentials.Credentials
+	if cfg.AccessKey != "" {
+		creds = credentials.NewStaticCredentials(cfg.AccessKey, cfg.SecretKey, "")
+	} else {
+		creds = credentials.NewEnvCredentials()
+	}
+	return &DynamoDBStore{
+		db: dynamodb.New(session.New(&aws.Config{
+			Credentials: creds,
+			Region:      &cfg.Region,
+		})),
+		table: cfg.TableName,
+	}, nil
+}
+
+// Append will write the events as an item keyed by the version of the first
+// event, on the condition that no other Append has taken that version.
+func (d *DynamoDBStore) Append(stream string, expectedVersion int64, events ...Event) ([]Event, error) {
+	if len(events) == 0 {
+		return nil, nil
+	}
+	for attempt := 1; ; attempt++ {
+		current, err := d.currentVersion(stream)
+		if err != nil {
+			return nil, err
+		}
+		if expectedVersion != AnyVersion && expectedVersion != current {
+			return nil, ErrWrongVersion
+		}
+
+		prepared := prepare(stream, current, events)
+		b, err := json.Marshal(prepared)
+		if err != nil {
+			return nil, err
+		}
+		_, err = d.db.PutItem(&dynamodb.PutItemInput{
+			TableName: &d.table,
+			Item: map[string]*dynamodb.AttributeValue{
+				"stream":  {S: &stream},
+				"version": {N: aws.String(strconv.FormatInt(current+1, 10))},
+				"last":    {N: aws.String(strconv.FormatInt(current+int64(len(events)), 10))},
+				"events":  {S: aws.String(string(b))},
+			},
+			ConditionExpression:      aws.String("attribute_not_exists(#version)"),
+			ExpressionAttributeNames: map[string]*string{"#version": aws.String("version")},
+		})
+		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == "ConditionalCheckFailedException" {
+			if expectedVersion == AnyVersion && attempt < maxAppendAttempts {
+				continue
+			}
+			return nil, ErrWrongVersion
+		}
+		if err != nil {
+			return nil, err
+		}
+		return prepared, nil
+	}
+}
+
+// currentVersion will return the version of the last event in the stream.
+func (d *DynamoDBStore) currentVersion(stream string) (int64, error) {
+	items, _, err := d.query(stream, "", "", false, 1, nil)
+	if err != nil || len(items) == 0 {
+		return 0, err
+	}
+	if last := items[0]["last"]; last != nil && last.N != nil {
+		return strconv.ParseInt(*last.N, 10, 64)
+	}
+	return 0, errors.New("dynamodb item is missing its last version")
+}
+
+// Read will query the items holding events at or after the given version.
+func (d *DynamoDBStore) Read(stream string, from int64, limit int) ([]Event, error) {
+	if from < 1 {
+		from = 1
+	}
+	// find the item holding the first event, which may have
+	// been appended along with earlier events
+	start := strconv.FormatInt(from, 10)
+	items, _, err := d.query(stream, "<=", start, false, 1, nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(items) > 0 {
+		start = *items[0]["version"].N
+	}
+
+	var (
+		events  []Event
+		lastKey map[string]*dynamodb.AttributeValue
+	)
+	for {
+		items, lastKey, err = d.query(stream, ">=", start, true, 0, lastKey)
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range items {
+			var batch []Event
+			if err := json.Unmarshal([]byte(*item["events"].S), &batch); err != nil {
+				return nil, err
+			}
+			for _, e := range batch {
+				if e.Version < from {
+					continue
+				}
+				events = append(events, e)
+				if limit > 0 && len(events) == limit {
+					return events, nil
+				}
+			}
+		}
+		if len(lastKey) == 0 {
+			return events, nil
+		}
+	}
+}
+
+// query will return a page of the stream's items, optionally with a condition
+// on their version.
+func (d *DynamoDBStore) query(stream, op, version string, forward bool, limit int64, startKey map[string]*dynamodb.AttributeValue) ([]map[string]*dynamodb.AttributeValue, map[string]*dynamodb.AttributeValue, error) {
+	in := &dynamodb.QueryInput{
+		TableName:                &d.table,
+		ConsistentRead:           aws.Bool(true),
+		KeyConditionExpression:   aws.String("#stream = :stream"),
+		ExpressionAttributeNames: map[string]*string{"#stream": aws.String("stream")},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":stream": {S: &stream},
+		},
+		ScanIndexForward:  aws.Bool(forward),
+		ExclusiveStartKey: startKey,
+	}
+	if op != "" {
+		in.KeyConditionExpression = aws.String("#stream = :stream AND #version " + op + " :version")
+		in.ExpressionAttributeNames["#version"] = aws.String("version")
+		in.ExpressionAttributeValues[":version"] = &dynamodb.AttributeValue{N: &version}
+	}
+	if limit > 0 {
+		in.Limit = &limit
+	}
+	out, err := d.db.Query(in)
+	if err != nil {
+		return nil, nil, err
+	}
+	return out.Items, out.LastEvaluatedKey, nil
+}
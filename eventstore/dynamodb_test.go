@@ -0,0 +1,99 @@
+package eventstore
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+// testDynamoDBAPI will evaluate the store's conditions and queries against
+// an in-memory table, returning pages of a single item to exercise
+// pagination. All other DynamoDBAPI methods will panic.
+type testDynamoDBAPI struct {
+	dynamodbiface.DynamoDBAPI
+
+	// stream => version => item
+	items map[string]map[int64]map[string]*dynamodb.AttributeValue
+}
+
+func itemVersion(item map[string]*dynamodb.AttributeValue) int64 {
+	v, _ := strconv.ParseInt(*item["version"].N, 10, 64)
+	return v
+}
+
+func (d *testDynamoDBAPI) PutItem(i *dynamodb.PutItemInput) (*dynamodb.PutItemOutput, error) {
+	stream := *i.Item["stream"].S
+	version := itemVersion(i.Item)
+	if d.items[stream] == nil {
+		d.items[stream] = map[int64]map[string]*dynamodb.AttributeValue{}
+	}
+	if _, ok := d.items[stream][version]; ok {
+		return nil, awserr.New("ConditionalCheckFailedException", "condition failed", nil)
+	}
+	d.items[stream][version] = i.Item
+	return &dynamodb.PutItemOutput{}, nil
+}
+
+func (d *testDynamoDBAPI) Query(i *dynamodb.QueryInput) (*dynamodb.QueryOutput, error) {
+	stream := *i.ExpressionAttributeValues[":stream"].S
+	var versions []int64
+	for v := range d.items[stream] {
+		versions = append(versions, v)
+	}
+	sort.Sort(int64s(versions))
+	if !*i.ScanIndexForward {
+		for l, r := 0, len(versions)-1; l < r; l, r = l+1, r-1 {
+			versions[l], versions[r] = versions[r], versions[l]
+		}
+	}
+
+	var match func(int64) bool
+	switch cond := *i.KeyConditionExpression; {
+	case cond == "#stream = :stream":
+		match = func(int64) bool { return true }
+	case strings.HasSuffix(cond, "<= :version"):
+		max, _ := strconv.ParseInt(*i.ExpressionAttributeValues[":version"].N, 10, 64)
+		match = func(v int64) bool { return v <= max }
+	case strings.HasSuffix(cond, ">= :version"):
+		min, _ := strconv.ParseInt(*i.ExpressionAttributeValues[":version"].N, 10, 64)
+		match = func(v int64) bool { return v >= min }
+	}
+
+	out := &dynamodb.QueryOutput{}
+	started := i.ExclusiveStartKey == nil
+	for _, v := range versions {
+		if !started {
+			started = v == itemVersion(i.ExclusiveStartKey)
+			continue
+		}
+		if !match(v) {
+			continue
+		}
+		item := d.items[stream][v]
+		out.Items = append(out.Items, item)
+		out.LastEvaluatedKey = map[string]*dynamodb.AttributeValue{
+			"stream":  item["stream"],
+			"version": item["version"],
+		}
+		break
+	}
+	return out, nil
+}
+
+type int64s []int64
+
+func (s int64s) Len() int           { return len(s) }
+func (s int64s) Less(i, j int) bool { return s[i] < s[j] }
+func (s int64s) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+
+func TestDynamoDBStore(t *testing.T) {
+	testStore(t, &DynamoDBStore{
+		db:    &testDynamoDBAPI{items: map[string]map[int64]map[string]*dynamodb.AttributeValue{}},
+		table: "events",
+	})
+}
@@ -0,0 +1,117 @@
+package eventstore
+
+import (
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+)
+
+// Log is the structured logger used throughout the package.
+var Log = logrus.New()
+
+// AnyVersion can be given to Append to skip the check of the
+// stream's current version.
+const AnyVersion int64 = -1
+
+// ErrWrongVersion is returned by Append when the stream is not at
+// the expected version.
+var ErrWrongVersion = errors.New("stream is not at the expected version")
+
+// Event is a single immutable fact recorded in a stream.
+type Event struct {
+	// Stream is the ID of the stream, usually an aggregate, the event belongs to.
+	Stream string `json:"stream"`
+	// Version is the position of the event in its stream, starting at 1.
+	Version int64 `json:"version"`
+	// Type is the name of the kind of event.
+	Type string `json:"type"`
+	// Data is the JSON encoded body of the event.
+	Data json.RawMessage `json:"data,omitempty"`
+	// Metadata is an optional set of extra info like correlation IDs.
+	Metadata map[string]string `json:"metadata,omitempty"`
+	// Time is when the event was appended.
+	Time time.Time `json:"time"`
+}
+
+// Decode will unmarshal the event's Data into v.
+func (e *Event) Decode(v interface{}) error {
+	return json.Unmarshal(e.Data, v)
+}
+
+// NewEvent will return an Event of the given type with v as its JSON Data.
+func NewEvent(typ string, v interface{}) (Event, error) {
+	b, err := json.Marshal(v)
+	return Event{Type: typ, Data: b}, err
+}
+
+// Store is an append-only store of event streams.
+type Store interface {
+	// Append will atomically add the events to the end of the stream if
+	// it is at the expected version, 0 for a new stream, or return
+	// ErrWrongVersion. It will return the events with their Stream,
+	// Version and Time set.
+	Append(stream string, expectedVersion int64, events ...Event) ([]Event, error)
+	// Read will return up to limit events from the stream starting at
+	// the given version. A limit of 0 will return all of them.
+	Read(stream string, from int64, limit int) ([]Event, error)
+}
+
+// prepare will set the stream, version and time of events being appended
+// to a stream at the given version.
+func prepare(stream string, version int64, events []Event) []Event {
+	now := time.Now().UTC()
+	out := make([]Event, len(events))
+	for i, e := range events {
+		e.Stream = stream
+		e.Version = version + int64(i) + 1
+		e.Time = now
+		out[i] = e
+	}
+	return out
+}
+
+// MemoryStore is an in-memory Store for services with a single
+// instance and for tests.
+type MemoryStore struct {
+	mu      sync.RWMutex
+	streams map[string][]Event
+}
+
+// NewMemoryStore will return an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{streams: map[string][]Event{}}
+}
+
+// Append will add the events to the stream.
+func (m *MemoryStore) Append(stream string, expectedVersion int64, events ...Event) ([]Event, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	current := int64(len(m.streams[stream]))
+	if expectedVersion != AnyVersion && expectedVersion != current {
+		return nil, ErrWrongVersion
+	}
+	events = prepare(stream, current, events)
+	m.streams[stream] = append(m.streams[stream], events...)
+	return events, nil
+}
+
+// Read will return a copy of the events in the stream.
+func (m *MemoryStore) Read(stream string, from int64, limit int) ([]Event, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	events := m.streams[stream]
+	if from < 1 {
+		from = 1
+	}
+	if from > int64(len(events)) {
+		return nil, nil
+	}
+	events = events[from-1:]
+	if limit > 0 && limit < len(events) {
+		events = events[:limit]
+	}
+	return append([]Event(nil), events...), nil
+}
@@ -0,0 +1,188 @@
+package eventstore
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/NYTimes/gizmo/pubsub/pubsubtest"
+)
+
+type deposited struct {
+	Amount int
+}
+
+func testStore(t *testing.T, s Store) {
+	e1, _ := NewEvent("opened", nil)
+	e2, _ := NewEvent("deposited", deposited{10})
+	e2.Metadata = map[string]string{"request_id": "abc"}
+	e3, _ := NewEvent("deposited", deposited{20})
+
+	got, err := s.Append("account-1", 0, e1, e2)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(got) != 2 || got[0].Version != 1 || got[1].Version != 2 || got[1].Stream != "account-1" || got[1].Time.IsZero() {
+		t.Errorf("expected appended events to have stream, version and time set, got %#v", got)
+	}
+
+	if _, err := s.Append("account-1", 1, e3); err != ErrWrongVersion {
+		t.Errorf("expected ErrWrongVersion appending at a stale version, got %v", err)
+	}
+	if _, err := s.Append("account-1", 0, e3); err != ErrWrongVersion {
+		t.Errorf("expected ErrWrongVersion appending a new stream that exists, got %v", err)
+	}
+	if _, err := s.Append("account-1", 2, e3); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if got, err = s.Append("account-1", AnyVersion, e1); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(got) != 1 || got[0].Version != 4 {
+		t.Errorf("expected event appended at any version to be version 4, got %#v", got)
+	}
+	if _, err := s.Append("account-2", 0, e1); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	tests := []struct {
+		from, limit  int
+		wantVersions []int64
+	}{
+		{0, 0, []int64{1, 2, 3, 4}},
+		{1, 0, []int64{1, 2, 3, 4}},
+		{2, 0, []int64{2, 3, 4}},
+		{2, 2, []int64{2, 3}},
+		{5, 0, nil},
+	}
+	for _, test := range tests {
+		events, err := s.Read("account-1", int64(test.from), test.limit)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		var versions []int64
+		for _, e := range events {
+			versions = append(versions, e.Version)
+		}
+		if len(versions) != len(test.wantVersions) {
+			t.Errorf("from %d limit %d: expected versions %v, got %v", test.from, test.limit, test.wantVersions, versions)
+			continue
+		}
+		for i := range versions {
+			if versions[i] != test.wantVersions[i] {
+				t.Errorf("from %d limit %d: expected versions %v, got %v", test.from, test.limit, test.wantVersions, versions)
+				break
+			}
+		}
+	}
+
+	events, err := s.Read("account-1", 2, 1)
+	if err != nil || len(events) != 1 {
+		t.Fatalf("expected 1 event, got %d: %v", len(events), err)
+	}
+	var d deposited
+	if err := events[0].Decode(&d); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if d.Amount != 10 || events[0].Type != "deposited" || events[0].Metadata["request_id"] != "abc" {
+		t.Errorf("expected deposited event with metadata, got %#v", events[0])
+	}
+}
+
+func TestMemoryStore(t *testing.T) {
+	testStore(t, NewMemoryStore())
+}
+
+func TestSubscribe(t *testing.T) {
+	s := NewMemoryStore()
+	e, _ := NewEvent("test", nil)
+	s.Append("stream", AnyVersion, e, e, e)
+
+	sub := Subscribe(s, "stream", 2, time.Millisecond)
+	var got []int64
+	for len(got) < 4 {
+		select {
+		case ev := <-sub.Events():
+			got = append(got, ev.Version)
+			if len(got) == 2 {
+				// should be picked up on the next poll
+				s.Append("stream", AnyVersion, e, e)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("timed out waiting for events, got %v", got)
+		}
+	}
+	if err := sub.Stop(); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+	if _, ok := <-sub.Events(); ok {
+		t.Error("expected events channel to be closed after stop")
+	}
+
+	want := []int64{2, 3, 4, 5}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("expected versions %v, got %v", want, got)
+			break
+		}
+	}
+}
+
+type errStore struct {
+	Store
+	err error
+}
+
+func (s errStore) Read(string, int64, int) ([]Event, error) {
+	return nil, s.err
+}
+
+func TestSubscribeError(t *testing.T) {
+	wantErr := errors.New("nope")
+	sub := Subscribe(errStore{err: wantErr}, "stream", 1, time.Millisecond)
+	if _, ok := <-sub.Events(); ok {
+		t.Error("expected events channel to be closed on error")
+	}
+	if err := sub.Err(); err != wantErr {
+		t.Errorf("expected read error, got %v", err)
+	}
+}
+
+func TestPublishingStore(t *testing.T) {
+	pub := &pubsubtest.TestPublisher{}
+	s := NewPublishingStore(NewMemoryStore(), pub)
+	e, _ := NewEvent("test", deposited{5})
+
+	if _, err := s.Append("stream", 0, e, e); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if _, err := s.Append("stream", 0, e); err != ErrWrongVersion {
+		t.Errorf("expected ErrWrongVersion, got %v", err)
+	}
+	if len(pub.Published) != 2 {
+		t.Fatalf("expected 2 published events, got %d", len(pub.Published))
+	}
+	for i, msg := range pub.Published {
+		var got Event
+		if err := json.Unmarshal(msg.Body, &got); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if msg.Key != "stream" || got.Version != int64(i+1) || got.Type != "test" {
+			t.Errorf("expected event %d of stream, got key %q and %#v", i+1, msg.Key, got)
+		}
+	}
+
+	pub.GivenError = errors.New("nope")
+	events, err := s.Append("stream", 2, e)
+	perr, ok := err.(*PublishError)
+	if !ok {
+		t.Fatalf("expected *PublishError, got %v", err)
+	}
+	if len(events) != 1 || len(perr.Events) != 1 || perr.Events[0].Version != 3 {
+		t.Errorf("expected committed event 3 to be returned as unpublished, got %#v and %#v", events, perr.Events)
+	}
+	if got, _ := s.Read("stream", 3, 0); len(got) != 1 {
+		t.Errorf("expected event to be committed despite publish error, got %d events", len(got))
+	}
+}
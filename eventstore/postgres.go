@@ -0,0 +1,140 @@
+package eventstore
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// PostgresSchema is the schema of the table used by a PostgresStore.
+const PostgresSchema = `CREATE TABLE IF NOT EXISTS events (
+	stream   TEXT NOT NULL,
+	version  BIGINT NOT NULL,
+	type     TEXT NOT NULL,
+	data     TEXT,
+	metadata TEXT,
+	time     TIMESTAMPTZ NOT NULL,
+	PRIMARY KEY (stream, version)
+)`
+
+// PostgresStore is a Store backed by a Postgres table with the PostgresSchema.
+// The primary key on stream and version keeps concurrent appends from
+// writing the same version.
+type PostgresStore struct {
+	// Table is the name of the events table. It defaults to 'events'
+	// and must not come from user input.
+	Table string
+
+	db *sql.DB
+}
+
+// NewPostgresStore will return a Store using the given database.
+func NewPostgresStore(db *sql.DB) *PostgresStore {
+	return &PostgresStore{Table: "events", db: db}
+}
+
+// Append will insert the events in a single transaction.
+func (p *PostgresStore) Append(stream string, expectedVersion int64, events ...Event) ([]Event, error) {
+	if len(events) == 0 {
+		return nil, nil
+	}
+	for attempt := 1; ; attempt++ {
+		prepared, err := p.append(stream, expectedVersion, events)
+		if err != nil && isUniqueViolation(err) {
+			if expectedVersion == AnyVersion && attempt < maxAppendAttempts {
+				continue
+			}
+			return nil, ErrWrongVersion
+		}
+		return prepared, err
+	}
+}
+
+func (p *PostgresStore) append(stream string, expectedVersion int64, events []Event) (prepared []Event, err error) {
+	tx, err := p.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+			return
+		}
+		err = tx.Commit()
+	}()
+
+	var current int64
+	err = tx.QueryRow(fmt.Sprintf("SELECT COALESCE(MAX(version), 0) FROM %s WHERE stream = $1", p.Table), stream).Scan(&current)
+	if err != nil {
+		return nil, err
+	}
+	if expectedVersion != AnyVersion && expectedVersion != current {
+		return nil, ErrWrongVersion
+	}
+
+	prepared = prepare(stream, current, events)
+	insert := fmt.Sprintf("INSERT INTO %s (stream, version, type, data, metadata, time) VALUES ($1, $2, $3, $4, $5, $6)", p.Table)
+	for _, e := range prepared {
+		var metadata []byte
+		if len(e.Metadata) > 0 {
+			if metadata, err = json.Marshal(e.Metadata); err != nil {
+				return nil, err
+			}
+		}
+		if _, err = tx.Exec(insert, e.Stream, e.Version, e.Type, string(e.Data), string(metadata), e.Time); err != nil {
+			return nil, err
+		}
+	}
+	return prepared, nil
+}
+
+// Read will select the events in version order.
+func (p *PostgresStore) Read(stream string, from int64, limit int) ([]Event, error) {
+	query := fmt.Sprintf("SELECT version, type, data, metadata, time FROM %s WHERE stream = $1 AND version >= $2 ORDER BY version", p.Table)
+	args := []interface{}{stream, from}
+	if limit > 0 {
+		query += " LIMIT $3"
+		args = append(args, limit)
+	}
+	rows, err := p.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []Event
+	for rows.Next() {
+		var (
+			e              = Event{Stream: stream}
+			data, metadata sql.NullString
+			t              time.Time
+		)
+		if err := rows.Scan(&e.Version, &e.Type, &data, &metadata, &t); err != nil {
+			return nil, err
+		}
+		if data.String != "" {
+			e.Data = json.RawMessage(data.String)
+		}
+		if metadata.String != "" {
+			if err := json.Unmarshal([]byte(metadata.String), &e.Metadata); err != nil {
+				return nil, err
+			}
+		}
+		e.Time = t.UTC()
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
+
+// isUniqueViolation will check for the unique_violation error code
+// from drivers like lib/pq, falling back to the error message.
+func isUniqueViolation(err error) bool {
+	if perr, ok := err.(interface {
+		Get(byte) string
+	}); ok {
+		return perr.Get('C') == "23505"
+	}
+	return strings.Contains(err.Error(), "duplicate key")
+}
@@ -0,0 +1,175 @@
+package eventstore
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testPGDriver is a database/sql driver that answers the PostgresStore's
+// queries from an in-memory table. Inserts are only visible to other
+// connections once their transaction is committed.
+type testPGDriver struct {
+	mu     sync.Mutex
+	events map[string]map[int64][]driver.Value
+}
+
+func (d *testPGDriver) Open(string) (driver.Conn, error) {
+	return &testPGConn{d: d}, nil
+}
+
+type testPGConn struct {
+	d       *testPGDriver
+	pending [][]driver.Value
+}
+
+func (c *testPGConn) Prepare(query string) (driver.Stmt, error) {
+	return &testPGStmt{c: c, query: query}, nil
+}
+func (c *testPGConn) Close() error              { return nil }
+func (c *testPGConn) Begin() (driver.Tx, error) { return c, nil }
+
+func (c *testPGConn) Commit() error {
+	c.d.mu.Lock()
+	defer c.d.mu.Unlock()
+	for _, row := range c.pending {
+		stream := row[0].(string)
+		if c.d.events[stream] == nil {
+			c.d.events[stream] = map[int64][]driver.Value{}
+		}
+		c.d.events[stream][row[1].(int64)] = row
+	}
+	c.pending = nil
+	return nil
+}
+
+func (c *testPGConn) Rollback() error {
+	c.pending = nil
+	return nil
+}
+
+type testPGStmt struct {
+	c     *testPGConn
+	query string
+}
+
+func (s *testPGStmt) Close() error  { return nil }
+func (s *testPGStmt) NumInput() int { return -1 }
+
+func (s *testPGStmt) Exec(args []driver.Value) (driver.Result, error) {
+	if !strings.HasPrefix(s.query, "INSERT INTO events ") {
+		return nil, errors.New("unexpected exec: " + s.query)
+	}
+	d := s.c.d
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if _, ok := d.events[args[0].(string)][args[1].(int64)]; ok {
+		return nil, errors.New(`pq: duplicate key value violates unique constraint "events_pkey"`)
+	}
+	s.c.pending = append(s.c.pending, args)
+	return driver.RowsAffected(1), nil
+}
+
+func (s *testPGStmt) Query(args []driver.Value) (driver.Rows, error) {
+	d := s.c.d
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	stream := args[0].(string)
+	switch {
+	case strings.HasPrefix(s.query, "SELECT COALESCE(MAX(version), 0) FROM events "):
+		var max int64
+		for v := range d.events[stream] {
+			if v > max {
+				max = v
+			}
+		}
+		return &testPGRows{columns: []string{"max"}, rows: [][]driver.Value{{max}}}, nil
+	case strings.HasPrefix(s.query, "SELECT version, type, data, metadata, time FROM events "):
+		var versions []int64
+		for v := range d.events[stream] {
+			if v >= args[1].(int64) {
+				versions = append(versions, v)
+			}
+		}
+		sort.Sort(int64s(versions))
+		if len(args) > 2 && int(args[2].(int64)) < len(versions) {
+			versions = versions[:args[2].(int64)]
+		}
+		rows := &testPGRows{columns: []string{"version", "type", "data", "metadata", "time"}}
+		for _, v := range versions {
+			rows.rows = append(rows.rows, d.events[stream][v][1:])
+		}
+		return rows, nil
+	}
+	return nil, errors.New("unexpected query: " + s.query)
+}
+
+type testPGRows struct {
+	columns []string
+	rows    [][]driver.Value
+}
+
+func (r *testPGRows) Columns() []string { return r.columns }
+func (r *testPGRows) Close() error      { return nil }
+func (r *testPGRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
+
+func init() {
+	sql.Register("eventstore-test-pg", &testPGDriver{events: map[string]map[int64][]driver.Value{}})
+}
+
+func TestPostgresStore(t *testing.T) {
+	db, err := sql.Open("eventstore-test-pg", "")
+	if err != nil {
+		t.Fatal("unable to open test db: ", err)
+	}
+	defer db.Close()
+	// the driver's table outlives each run of the test
+	db.Driver().(*testPGDriver).events = map[string]map[int64][]driver.Value{}
+
+	testStore(t, NewPostgresStore(db))
+}
+
+func TestPostgresStoreUniqueViolation(t *testing.T) {
+	db, err := sql.Open("eventstore-test-pg", "")
+	if err != nil {
+		t.Fatal("unable to open test db: ", err)
+	}
+	defer db.Close()
+	d := db.Driver().(*testPGDriver)
+	d.events = map[string]map[int64][]driver.Value{
+		// another writer already committed version 1
+		"dup": {1: {"dup", int64(1), "test", "", "", time.Now()}},
+	}
+
+	s := NewPostgresStore(db)
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal("unable to begin: ", err)
+	}
+	if _, err := tx.Exec("INSERT INTO events (stream, version) VALUES ($1, $2)", "dup", int64(1)); err == nil || !isUniqueViolation(err) {
+		t.Errorf("expected unique violation, got %v", err)
+	}
+	tx.Rollback()
+
+	e, _ := NewEvent("test", nil)
+	got, err := s.Append("dup", AnyVersion, e)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if got[0].Version != 2 {
+		t.Errorf("expected version 2, got %d", got[0].Version)
+	}
+}
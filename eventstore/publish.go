@@ -0,0 +1,59 @@
+package eventstore
+
+import (
+	"encoding/json"
+
+	"github.com/NYTimes/gizmo/pubsub"
+)
+
+// PublishError is returned by a PublishingStore when events have been
+// appended but could not all be published.
+type PublishError struct {
+	// Events are the committed events that were not published.
+	Events []Event
+	Err    error
+}
+
+func (e *PublishError) Error() string {
+	return "events were appended but not published: " + e.Err.Error()
+}
+
+// PublishingStore is a Store that will publish each event as JSON, keyed
+// by its stream, once it has been committed to the underlying Store.
+type PublishingStore struct {
+	Store
+	pub pubsub.Publisher
+}
+
+// NewPublishingStore will return a Store that publishes
+// the events appended to s with the Publisher.
+func NewPublishingStore(s Store, pub pubsub.Publisher) *PublishingStore {
+	return &PublishingStore{Store: s, pub: pub}
+}
+
+// Append will append the events and then publish them in order. If the events
+// are appended but any fail to publish, the committed events will be returned
+// along with a *PublishError holding the unpublished events. They can be
+// published again with Publish.
+func (p *PublishingStore) Append(stream string, expectedVersion int64, events ...Event) ([]Event, error) {
+	events, err := p.Store.Append(stream, expectedVersion, events...)
+	if err != nil {
+		return nil, err
+	}
+	return events, p.Publish(events...)
+}
+
+// Publish will publish the events in order, stopping at the first failure.
+func (p *PublishingStore) Publish(events ...Event) error {
+	for i, e := range events {
+		b, err := json.Marshal(e)
+		if err == nil {
+			err = p.pub.PublishRaw(e.Stream, b)
+		}
+		if err != nil {
+			Log.WithField("stream", e.Stream).Errorf("unable to publish event %d: %s", e.Version, err)
+			return &PublishError{Events: events[i:], Err: err}
+		}
+	}
+	return nil
+}
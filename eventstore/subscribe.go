@@ -0,0 +1,108 @@
+package eventstore
+
+import (
+	"sync"
+	"time"
+)
+
+var (
+	// DefaultPollInterval is how often a Subscription will read
+	// new events if no interval is given.
+	DefaultPollInterval = time.Second
+	// subscribeBatchSize is the max number of events read per poll.
+	subscribeBatchSize = 100
+)
+
+// Subscription will emit the events appended to a stream
+// by polling its Store.
+type Subscription struct {
+	store    Store
+	stream   string
+	next     int64
+	interval time.Duration
+
+	events   chan Event
+	stop     chan struct{}
+	stopOnce sync.Once
+	done     chan struct{}
+
+	mu  sync.Mutex
+	err error
+}
+
+// Subscribe will return a Subscription that emits every event in the stream
+// starting at the given version, and then each new event as it is appended.
+// Use 1 to read a stream from the start or the version after the last event
+// handled to pick up where a previous subscription left off.
+func Subscribe(s Store, stream string, from int64, interval time.Duration) *Subscription {
+	if from < 1 {
+		from = 1
+	}
+	if interval <= 0 {
+		interval = DefaultPollInterval
+	}
+	sub := &Subscription{
+		store:    s,
+		stream:   stream,
+		next:     from,
+		interval: interval,
+		events:   make(chan Event),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	go sub.poll()
+	return sub
+}
+
+// Events will return the channel of events. It will be closed once the
+// Subscription is stopped or it encounters an error.
+func (s *Subscription) Events() <-chan Event {
+	return s.events
+}
+
+// Stop will stop polling and close the Events channel.
+func (s *Subscription) Stop() error {
+	s.stopOnce.Do(func() {
+		close(s.stop)
+	})
+	<-s.done
+	return nil
+}
+
+// Err will return the error that stopped the Subscription, if any.
+func (s *Subscription) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+func (s *Subscription) poll() {
+	defer close(s.done)
+	defer close(s.events)
+	for {
+		events, err := s.store.Read(s.stream, s.next, subscribeBatchSize)
+		if err != nil {
+			s.mu.Lock()
+			s.err = err
+			s.mu.Unlock()
+			return
+		}
+		for _, e := range events {
+			select {
+			case <-s.stop:
+				return
+			case s.events <- e:
+				s.next = e.Version + 1
+			}
+		}
+		if len(events) == subscribeBatchSize {
+			// there may be more waiting
+			continue
+		}
+		select {
+		case <-s.stop:
+			return
+		case <-time.After(s.interval):
+		}
+	}
+}
@@ -0,0 +1,36 @@
+/*
+Package flags offers a small integration point for feature flags so handlers and
+pubsub consumers can check flags without depending on a particular provider.
+
+A Provider answers whether a flag is on for the user in a context. Flags can come
+from the static list in config.FeatureFlags, an Unleash server or a LaunchDarkly
+client:
+
+	var provider flags.Provider = flags.NewStaticFromConfig(cfg.FeatureFlags)
+	if cfg.FeatureFlags.UnleashURL != "" {
+		provider, err = flags.NewUnleash(cfg.FeatureFlags, nil)
+	}
+
+The Provider can then be exposed to HTTP handlers with Middleware:
+
+	svr.Group("/", flags.Middleware(provider, func(r *http.Request) string {
+		return r.Header.Get("X-User-ID")
+	})).Register(svc)
+
+	func (s *svc) Get(w http.ResponseWriter, r *http.Request) {
+		if flags.RequestEnabled(r, "new-layout") {
+			...
+		}
+	}
+
+And to consumers with ConsumerHandler:
+
+	consumer := pubsub.NewConsumer(sub, flags.ConsumerHandler(provider, handle))
+
+	func handle(ctx context.Context, msg pubsub.SubscriberMessage) error {
+		if flags.Enabled(ctx, "new-pipeline") {
+			...
+		}
+	}
+*/
+package flags
@@ -0,0 +1,166 @@
+package flags
+
+import (
+	"net/http"
+	"sync"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/gorilla/context"
+	netContext "golang.org/x/net/context"
+
+	"github.com/NYTimes/gizmo/config"
+	"github.com/NYTimes/gizmo/pubsub"
+	"github.com/NYTimes/gizmo/server"
+)
+
+// Log is the structured logger used throughout the package.
+var Log = logrus.New()
+
+// Provider is the interface for checking whether a feature flag is on.
+// The context may contain a user added via WithUser for providers
+// that target flags to specific users.
+type Provider interface {
+	Enabled(ctx netContext.Context, key string) bool
+}
+
+// ProviderFunc is a func that implements the Provider interface.
+type ProviderFunc func(netContext.Context, string) bool
+
+// Enabled will call the func.
+func (f ProviderFunc) Enabled(ctx netContext.Context, key string) bool {
+	return f(ctx, key)
+}
+
+// Static is a Provider with a fixed set of flags. It is
+// safe for concurrent use.
+type Static struct {
+	mu    sync.RWMutex
+	flags map[string]bool
+}
+
+// NewStatic will return a Static provider with the given flags.
+func NewStatic(flags map[string]bool) *Static {
+	s := &Static{flags: map[string]bool{}}
+	for key, on := range flags {
+		s.flags[key] = on
+	}
+	return s
+}
+
+// NewStaticFromConfig will return a Static provider with all
+// the flags in the config's Enabled list turned on.
+func NewStaticFromConfig(cfg *config.FeatureFlags) *Static {
+	s := NewStatic(nil)
+	if cfg == nil {
+		return s
+	}
+	for _, key := range cfg.Enabled {
+		s.flags[key] = true
+	}
+	return s
+}
+
+// Enabled will return true if the flag is set and on.
+func (s *Static) Enabled(_ netContext.Context, key string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.flags[key]
+}
+
+// Set will turn a flag on or off.
+func (s *Static) Set(key string, on bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.flags[key] = on
+}
+
+type contextKey int
+
+const (
+	providerKey contextKey = iota
+	userKey
+)
+
+// NewContext will return a context containing the given Provider.
+func NewContext(ctx netContext.Context, p Provider) netContext.Context {
+	return netContext.WithValue(ctx, providerKey, p)
+}
+
+// FromContext will return the Provider in the context or nil.
+func FromContext(ctx netContext.Context) Provider {
+	p, _ := ctx.Value(providerKey).(Provider)
+	return p
+}
+
+// WithUser will return a context containing the given user key for
+// providers that target flags to specific users.
+func WithUser(ctx netContext.Context, user string) netContext.Context {
+	return netContext.WithValue(ctx, userKey, user)
+}
+
+// UserFromContext will return the user key in the context or
+// an empty string.
+func UserFromContext(ctx netContext.Context) string {
+	user, _ := ctx.Value(userKey).(string)
+	return user
+}
+
+// Enabled will check the flag with the Provider in the context. If the
+// context has no Provider, Enabled will return false.
+func Enabled(ctx netContext.Context, key string) bool {
+	p := FromContext(ctx)
+	if p == nil {
+		return false
+	}
+	return p.Enabled(ctx, key)
+}
+
+// Middleware will return middleware that exposes the Provider to handlers via
+// the request context. The user func is optional and will be used to pull a
+// user key out of each request for targeting. Handlers can then check flags
+// with RequestEnabled or grab a context with RequestContext.
+func Middleware(p Provider, user func(*http.Request) string) func(http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := NewContext(netContext.Background(), p)
+			if user != nil {
+				ctx = WithUser(ctx, user(r))
+			}
+			context.Set(r, providerKey, ctx)
+			h.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RequestContext will return the flag context set by Middleware for the
+// request or a background context if there is none.
+func RequestContext(r *http.Request) netContext.Context {
+	if ctx, ok := context.Get(r, providerKey).(netContext.Context); ok {
+		return ctx
+	}
+	return netContext.Background()
+}
+
+// RequestEnabled will check the flag with the Provider set on the request
+// by Middleware. If there is no Provider, it will return false.
+func RequestEnabled(r *http.Request, key string) bool {
+	return Enabled(RequestContext(r), key)
+}
+
+// ContextMiddleware will return middleware that adds the Provider to
+// the context passed to a server.ContextHandler.
+func ContextMiddleware(p Provider) func(server.ContextHandler) server.ContextHandler {
+	return func(h server.ContextHandler) server.ContextHandler {
+		return server.ContextHandlerFunc(func(ctx netContext.Context, w http.ResponseWriter, r *http.Request) {
+			h.ServeHTTPContext(NewContext(ctx, p), w, r)
+		})
+	}
+}
+
+// ConsumerHandler will add the Provider to the context passed to the
+// given pubsub.MessageHandler so consumers can check flags with Enabled.
+func ConsumerHandler(p Provider, h pubsub.MessageHandler) pubsub.MessageHandler {
+	return func(ctx netContext.Context, msg pubsub.SubscriberMessage) error {
+		return h(NewContext(ctx, p), msg)
+	}
+}
@@ -0,0 +1,211 @@
+package flags
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/context"
+	netContext "golang.org/x/net/context"
+
+	"github.com/NYTimes/gizmo/config"
+	"github.com/NYTimes/gizmo/pubsub"
+	"github.com/NYTimes/gizmo/server"
+)
+
+func TestStatic(t *testing.T) {
+	s := NewStaticFromConfig(&config.FeatureFlags{Enabled: []string{"cats", "dogs"}})
+	s.Set("dogs", false)
+	s.Set("birds", true)
+
+	tests := []struct {
+		key  string
+		want bool
+	}{
+		{"cats", true},
+		{"dogs", false},
+		{"birds", true},
+		{"fish", false},
+	}
+
+	ctx := netContext.Background()
+	for _, test := range tests {
+		if got := s.Enabled(ctx, test.key); got != test.want {
+			t.Errorf("expected %s to be %t, got %t", test.key, test.want, got)
+		}
+	}
+}
+
+func TestEnabled(t *testing.T) {
+	ctx := netContext.Background()
+	if Enabled(ctx, "cats") {
+		t.Error("expected cats to be off without a provider, got on")
+	}
+
+	var gotUser string
+	p := ProviderFunc(func(ctx netContext.Context, key string) bool {
+		gotUser = UserFromContext(ctx)
+		return key == "cats"
+	})
+	ctx = WithUser(NewContext(ctx, p), "jp")
+	if !Enabled(ctx, "cats") {
+		t.Error("expected cats to be on, got off")
+	}
+	if Enabled(ctx, "dogs") {
+		t.Error("expected dogs to be off, got on")
+	}
+	if gotUser != "jp" {
+		t.Errorf("expected user 'jp', got '%s'", gotUser)
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	p := ProviderFunc(func(ctx netContext.Context, key string) bool {
+		return UserFromContext(ctx) == "jp"
+	})
+	var got bool
+	h := Middleware(p, func(r *http.Request) string {
+		return r.Header.Get("X-User")
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = RequestEnabled(r, "cats")
+	}))
+
+	tests := []struct {
+		user string
+		want bool
+	}{
+		{"jp", true},
+		{"nobody", false},
+		{"", false},
+	}
+
+	for _, test := range tests {
+		r, _ := http.NewRequest("GET", "/", nil)
+		r.Header.Set("X-User", test.user)
+		h.ServeHTTP(httptest.NewRecorder(), r)
+		context.Clear(r)
+		if got != test.want {
+			t.Errorf("expected %t for user '%s', got %t", test.want, test.user, got)
+		}
+	}
+
+	r, _ := http.NewRequest("GET", "/", nil)
+	if RequestEnabled(r, "cats") {
+		t.Error("expected cats to be off without middleware, got on")
+	}
+}
+
+func TestContextMiddleware(t *testing.T) {
+	var got bool
+	h := ContextMiddleware(NewStatic(map[string]bool{"cats": true}))(
+		server.ContextHandlerFunc(func(ctx netContext.Context, w http.ResponseWriter, r *http.Request) {
+			got = Enabled(ctx, "cats")
+		}))
+
+	r, _ := http.NewRequest("GET", "/", nil)
+	h.ServeHTTPContext(netContext.Background(), httptest.NewRecorder(), r)
+	if !got {
+		t.Error("expected cats to be on, got off")
+	}
+}
+
+type testMessage struct{}
+
+func (testMessage) Message() []byte { return nil }
+func (testMessage) Done() error     { return nil }
+
+func TestConsumerHandler(t *testing.T) {
+	var got bool
+	h := ConsumerHandler(NewStatic(map[string]bool{"cats": true}), func(ctx netContext.Context, msg pubsub.SubscriberMessage) error {
+		got = Enabled(ctx, "cats")
+		return nil
+	})
+	if err := h(netContext.Background(), testMessage{}); err != nil {
+		t.Errorf("expected no error, got %s", err)
+	}
+	if !got {
+		t.Error("expected cats to be on, got off")
+	}
+}
+
+func TestLaunchDarkly(t *testing.T) {
+	ld := NewLaunchDarkly(func(key, user string, def bool) (bool, error) {
+		switch user {
+		case "jp":
+			return true, nil
+		case "broken":
+			return false, errors.New("nope")
+		}
+		return false, nil
+	})
+	ld.Default = true
+
+	tests := []struct {
+		user string
+		want bool
+	}{
+		{"jp", true},
+		{"nobody", false},
+		{"broken", true},
+		{"", true},
+	}
+
+	for _, test := range tests {
+		ctx := WithUser(netContext.Background(), test.user)
+		if got := ld.Enabled(ctx, "cats"); got != test.want {
+			t.Errorf("expected %t for user '%s', got %t", test.want, test.user, got)
+		}
+	}
+}
+
+func TestUnleash(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/client/features" {
+			http.NotFound(w, r)
+			return
+		}
+		if got := r.Header.Get("UNLEASH-APPNAME"); got != "test-app" {
+			t.Errorf("expected app name 'test-app', got '%s'", got)
+		}
+		w.Write([]byte(`{"features":[
+			{"name":"cats","enabled":true,"strategies":[{"name":"default"}]},
+			{"name":"dogs","enabled":false,"strategies":[{"name":"default"}]},
+			{"name":"birds","enabled":true,"strategies":[{"name":"userWithId","parameters":{"userIds":"jp, mike"}}]},
+			{"name":"fish","enabled":true,"strategies":[{"name":"gradualRolloutRandom"}]}
+		]}`))
+	}))
+	defer srv.Close()
+
+	u, err := NewUnleash(&config.FeatureFlags{UnleashURL: srv.URL + "/", UnleashAppName: "test-app"}, nil)
+	if err != nil {
+		t.Fatal("unable to create unleash provider: ", err)
+	}
+	defer u.Stop()
+
+	tests := []struct {
+		key  string
+		user string
+		want bool
+	}{
+		{"cats", "", true},
+		{"dogs", "", false},
+		{"birds", "mike", true},
+		{"birds", "jp", true},
+		{"birds", "nobody", false},
+		{"birds", "", false},
+		{"fish", "jp", false},
+		{"turtles", "", false},
+	}
+
+	for _, test := range tests {
+		ctx := WithUser(netContext.Background(), test.user)
+		if got := u.Enabled(ctx, test.key); got != test.want {
+			t.Errorf("expected %s to be %t for user '%s', got %t", test.key, test.want, test.user, got)
+		}
+	}
+
+	if _, err = NewUnleash(&config.FeatureFlags{}, nil); err == nil {
+		t.Error("expected an error without an unleash URL, got none")
+	}
+}
@@ -0,0 +1,43 @@
+package flags
+
+import netContext "golang.org/x/net/context"
+
+// LaunchDarkly adapts a LaunchDarkly client to the Provider interface.
+type LaunchDarkly struct {
+	// Default is the value that will be used if the flag cannot be
+	// evaluated or if no user is found in the context.
+	Default bool
+	// AnonymousUser is the user key used when no user is found in the
+	// context. If empty, Default will be returned for such requests.
+	AnonymousUser string
+
+	variation func(key, user string, def bool) (bool, error)
+}
+
+// NewLaunchDarkly will return a Provider that evaluates flags with the given
+// variation func. Since the LaunchDarkly user type belongs to the SDK, the
+// func is expected to wrap the client's BoolVariation call:
+//
+//	ld := flags.NewLaunchDarkly(func(key, user string, def bool) (bool, error) {
+//	    return client.BoolVariation(key, ldclient.NewUser(user), def)
+//	})
+func NewLaunchDarkly(variation func(key, user string, def bool) (bool, error)) *LaunchDarkly {
+	return &LaunchDarkly{variation: variation}
+}
+
+// Enabled will evaluate the flag for the user in the context.
+func (l *LaunchDarkly) Enabled(ctx netContext.Context, key string) bool {
+	user := UserFromContext(ctx)
+	if user == "" {
+		user = l.AnonymousUser
+	}
+	if user == "" {
+		return l.Default
+	}
+	on, err := l.variation(key, user, l.Default)
+	if err != nil {
+		Log.WithField("flag", key).Warn("unable to evaluate LaunchDarkly flag: ", err)
+		return l.Default
+	}
+	return on
+}
@@ -0,0 +1,161 @@
+package flags
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	netContext "golang.org/x/net/context"
+
+	"github.com/NYTimes/gizmo/config"
+	"github.com/NYTimes/gizmo/httpclient"
+)
+
+// DefaultUnleashRefreshInterval is how often an Unleash provider will fetch
+// features if no UnleashRefreshInterval is configured.
+var DefaultUnleashRefreshInterval = 15 * time.Second
+
+// Unleash is a Provider that polls the features of an Unleash server. Only
+// the 'default' and 'userWithId' strategies are supported; features using
+// any other strategy will be treated as off.
+type Unleash struct {
+	url     string
+	appName string
+	client  *http.Client
+
+	mu       sync.RWMutex
+	features map[string]unleashFeature
+
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+type unleashFeatures struct {
+	Features []unleashFeature `json:"features"`
+}
+
+type unleashFeature struct {
+	Name       string            `json:"name"`
+	Enabled    bool              `json:"enabled"`
+	Strategies []unleashStrategy `json:"strategies"`
+}
+
+type unleashStrategy struct {
+	Name       string            `json:"name"`
+	Parameters map[string]string `json:"parameters"`
+}
+
+// NewUnleash will fetch the features from the Unleash server in the config
+// and keep them up to date in the background until Stop is called. The
+// client is optional and will default to one from the httpclient package.
+func NewUnleash(cfg *config.FeatureFlags, client *http.Client) (*Unleash, error) {
+	if cfg == nil || cfg.UnleashURL == "" {
+		return nil, fmt.Errorf("unleash URL is required")
+	}
+	if client == nil {
+		client = httpclient.New(nil)
+	}
+	interval := cfg.UnleashRefreshInterval
+	if interval == 0 {
+		interval = DefaultUnleashRefreshInterval
+	}
+
+	u := &Unleash{
+		url:     strings.TrimRight(cfg.UnleashURL, "/"),
+		appName: cfg.UnleashAppName,
+		client:  client,
+		stop:    make(chan struct{}),
+	}
+	if err := u.Refresh(); err != nil {
+		return nil, err
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-u.stop:
+				return
+			case <-ticker.C:
+				if err := u.Refresh(); err != nil {
+					Log.Warn("unable to refresh Unleash features: ", err)
+				}
+			}
+		}
+	}()
+	return u, nil
+}
+
+// Refresh will fetch the latest features from the Unleash server.
+func (u *Unleash) Refresh() error {
+	req, err := http.NewRequest("GET", u.url+"/client/features", nil)
+	if err != nil {
+		return err
+	}
+	if u.appName != "" {
+		req.Header.Set("UNLEASH-APPNAME", u.appName)
+	}
+	resp, err := u.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status code from Unleash: %d", resp.StatusCode)
+	}
+
+	var fs unleashFeatures
+	if err = json.NewDecoder(resp.Body).Decode(&fs); err != nil {
+		return err
+	}
+	features := make(map[string]unleashFeature, len(fs.Features))
+	for _, f := range fs.Features {
+		features[f.Name] = f
+	}
+
+	u.mu.Lock()
+	u.features = features
+	u.mu.Unlock()
+	return nil
+}
+
+// Enabled will evaluate the feature for the user in the context.
+func (u *Unleash) Enabled(ctx netContext.Context, key string) bool {
+	u.mu.RLock()
+	f, ok := u.features[key]
+	u.mu.RUnlock()
+	if !ok || !f.Enabled {
+		return false
+	}
+	if len(f.Strategies) == 0 {
+		return true
+	}
+	user := UserFromContext(ctx)
+	for _, s := range f.Strategies {
+		switch s.Name {
+		case "default":
+			return true
+		case "userWithId":
+			if user == "" {
+				continue
+			}
+			for _, id := range strings.Split(s.Parameters["userIds"], ",") {
+				if strings.TrimSpace(id) == user {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// Stop will stop refreshing features.
+func (u *Unleash) Stop() {
+	u.stopOnce.Do(func() {
+		close(u.stop)
+	})
+}
@@ -0,0 +1,22 @@
+/*
+Package grpcclient produces *grpc.ClientConns for calling other services that are
+configured from a config.GRPCClient struct. Connections created by this package offer:
+
+  - TLS, with an optional custom CA, or insecure transport
+  - dial timeouts and keepalive pings
+  - round-robin or pick-first balancing across a list of addresses
+  - retries with a backoff.Strategy, exponential by default, for calls that fail
+    with a RetryCodes status
+  - per-method metrics for call durations, errors and retries
+  - an optional hook for tracing each call
+  - any additional interceptors, which will wrap each attempt of a call
+
+A basic setup may look like:
+
+	conn, err := grpcclient.Dial(cfg.GRPCClient)
+	if err != nil {
+	    server.Log.Fatal("unable to dial cats: ", err)
+	}
+	cats := catspb.NewCatsClient(conn)
+*/
+package grpcclient
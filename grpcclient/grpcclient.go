@@ -0,0 +1,218 @@
+package grpcclient
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io/ioutil"
+	"strings"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/keepalive"
+
+	"github.com/NYTimes/gizmo/backoff"
+	"github.com/NYTimes/gizmo/config"
+)
+
+var (
+	// DefaultRetryBaseDelay is the initial retry backoff used if none is configured.
+	DefaultRetryBaseDelay = 50 * time.Millisecond
+	// DefaultRetryMaxDelay is the retry backoff cap used if none is configured.
+	DefaultRetryMaxDelay = 2 * time.Second
+	// DefaultKeepaliveTimeout is the keepalive ping timeout used if a
+	// KeepaliveTime but no KeepaliveTimeout is configured.
+	DefaultKeepaliveTimeout = 20 * time.Second
+
+	// RetryCodes are the status codes of calls that will be retried.
+	RetryCodes = []codes.Code{codes.Unavailable}
+)
+
+// Dial will create a *grpc.ClientConn to the configured Target. All calls
+// made on the connection will be retried and emit metrics and spans. The
+// given interceptors will wrap each call attempt, in order, within those.
+func Dial(cfg *config.GRPCClient, interceptors ...grpc.UnaryClientInterceptor) (*grpc.ClientConn, error) {
+	opts, err := DialOptions(cfg, interceptors...)
+	if err != nil {
+		return nil, err
+	}
+	return grpc.Dial(dialTarget(cfg), opts...)
+}
+
+// DialOptions will return the grpc.DialOptions that Dial would use for the
+// given config, for users that need to add some of their own.
+func DialOptions(cfg *config.GRPCClient, interceptors ...grpc.UnaryClientInterceptor) ([]grpc.DialOption, error) {
+	if cfg == nil || cfg.Target == "" {
+		return nil, errors.New("grpcclient: a Target is required")
+	}
+
+	var opts []grpc.DialOption
+	if cfg.Insecure {
+		opts = append(opts, grpc.WithInsecure())
+	} else {
+		creds, err := transportCredentials(cfg)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, grpc.WithTransportCredentials(creds))
+	}
+
+	if cfg.DialTimeout > 0 {
+		opts = append(opts, grpc.WithBlock(), grpc.WithTimeout(cfg.DialTimeout))
+	}
+
+	if cfg.KeepaliveTime > 0 {
+		timeout := cfg.KeepaliveTimeout
+		if timeout == 0 {
+			timeout = DefaultKeepaliveTimeout
+		}
+		opts = append(opts, grpc.WithKeepaliveParams(keepalive.ClientParameters{
+			Time:    cfg.KeepaliveTime,
+			Timeout: timeout,
+		}))
+	}
+
+	switch cfg.Balancer {
+	case "", "pick_first":
+		// without a balancer, only the first address is dialed
+	case "round_robin":
+		opts = append(opts, grpc.WithBalancer(grpc.RoundRobin(staticResolver(splitTarget(cfg.Target)))))
+	default:
+		return nil, errors.New("grpcclient: unknown balancer " + cfg.Balancer)
+	}
+
+	interceptor, err := newInterceptor(cfg, interceptors)
+	if err != nil {
+		return nil, err
+	}
+	opts = append(opts, grpc.WithUnaryInterceptor(interceptor))
+	return opts, nil
+}
+
+// dialTarget will return the first address of the Target. When balancing
+// with round_robin, all of the addresses come from its resolver instead.
+func dialTarget(cfg *config.GRPCClient) string {
+	addrs := splitTarget(cfg.Target)
+	if len(addrs) == 0 {
+		return cfg.Target
+	}
+	return addrs[0]
+}
+
+func splitTarget(target string) []string {
+	var addrs []string
+	for _, addr := range strings.Split(target, ",") {
+		if addr = strings.TrimSpace(addr); addr != "" {
+			addrs = append(addrs, addr)
+		}
+	}
+	return addrs
+}
+
+func transportCredentials(cfg *config.GRPCClient) (credentials.TransportCredentials, error) {
+	tlsCfg := &tls.Config{ServerName: cfg.TLSServerName}
+	if cfg.TLSCAFile != "" {
+		pem, err := ioutil.ReadFile(cfg.TLSCAFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, errors.New("grpcclient: no certificates found in " + cfg.TLSCAFile)
+		}
+		tlsCfg.RootCAs = pool
+	}
+	return credentials.NewTLS(tlsCfg), nil
+}
+
+// newInterceptor will return the single grpc.UnaryClientInterceptor
+// installed on every connection.
+func newInterceptor(cfg *config.GRPCClient, interceptors []grpc.UnaryClientInterceptor) (grpc.UnaryClientInterceptor, error) {
+	base := cfg.RetryBaseDelay
+	if base == 0 {
+		base = DefaultRetryBaseDelay
+	}
+	max := cfg.RetryMaxDelay
+	if max == 0 {
+		max = DefaultRetryMaxDelay
+	}
+	strategy, err := backoff.New(cfg.RetryStrategy, base, max)
+	if err != nil {
+		return nil, errors.New("grpcclient: unknown retry strategy " + cfg.RetryStrategy)
+	}
+	registry := cfg.MetricsRegistry
+	if registry == nil {
+		registry = metrics.DefaultRegistry
+	}
+
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		prefix := "grpc.client." + metricName(method)
+		var finish func(error)
+		if cfg.StartSpan != nil {
+			finish = cfg.StartSpan(method)
+		}
+
+		call := chain(interceptors, invoker)
+		start := time.Now()
+		var err error
+		for attempt := 0; ; attempt++ {
+			if attempt > 0 {
+				metrics.GetOrRegisterCounter(prefix+".RETRY", registry).Inc(1)
+				select {
+				case <-ctx.Done():
+					err = ctx.Err()
+				case <-time.After(strategy.Delay(attempt)):
+				}
+				if ctx.Err() != nil {
+					break
+				}
+			}
+			err = call(ctx, method, req, reply, cc, opts...)
+			if err == nil || attempt >= cfg.MaxRetries || !IsRetryable(err) {
+				break
+			}
+		}
+
+		metrics.GetOrRegisterTimer(prefix+".DURATION", registry).UpdateSince(start)
+		if err != nil {
+			metrics.GetOrRegisterCounter(prefix+".ERROR", registry).Inc(1)
+		}
+		if finish != nil {
+			finish(err)
+		}
+		return err
+	}, nil
+}
+
+// chain will wrap the invoker in the interceptors so the first is outermost.
+func chain(interceptors []grpc.UnaryClientInterceptor, invoker grpc.UnaryInvoker) grpc.UnaryInvoker {
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		next, ic := invoker, interceptors[i]
+		invoker = func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+			return ic(ctx, method, req, reply, cc, next, opts...)
+		}
+	}
+	return invoker
+}
+
+// IsRetryable will return true if the error's status code is one of the
+// RetryCodes.
+func IsRetryable(err error) bool {
+	code := grpc.Code(err)
+	for _, c := range RetryCodes {
+		if code == c {
+			return true
+		}
+	}
+	return false
+}
+
+// metricName will turn a full method name like '/cats.Cats/GetCat' into
+// 'cats.Cats.GetCat'.
+func metricName(method string) string {
+	return strings.Replace(strings.TrimPrefix(method, "/"), "/", ".", -1)
+}
@@ -0,0 +1,161 @@
+package grpcclient
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+
+	"github.com/NYTimes/gizmo/config"
+)
+
+func TestInterceptor(t *testing.T) {
+	tests := []struct {
+		name     string
+		failures []error
+
+		wantCalls int
+		wantCode  codes.Code
+	}{
+		{
+			"success",
+			nil,
+			1,
+			codes.OK,
+		},
+		{
+			"retried",
+			[]error{grpc.Errorf(codes.Unavailable, "down"), grpc.Errorf(codes.Unavailable, "down")},
+			3,
+			codes.OK,
+		},
+		{
+			"not retryable",
+			[]error{grpc.Errorf(codes.InvalidArgument, "bad")},
+			1,
+			codes.InvalidArgument,
+		},
+		{
+			"retries exhausted",
+			[]error{
+				grpc.Errorf(codes.Unavailable, "down"),
+				grpc.Errorf(codes.Unavailable, "down"),
+				grpc.Errorf(codes.Unavailable, "down"),
+			},
+			3,
+			codes.Unavailable,
+		},
+	}
+
+	for _, test := range tests {
+		registry := metrics.NewRegistry()
+		var (
+			order []string
+			spans []string
+			calls int
+		)
+		cfg := &config.GRPCClient{
+			MaxRetries:      2,
+			RetryBaseDelay:  time.Millisecond,
+			MetricsRegistry: registry,
+			StartSpan: func(method string) func(error) {
+				spans = append(spans, method)
+				return func(error) {}
+			},
+		}
+		record := func(name string) grpc.UnaryClientInterceptor {
+			return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+				order = append(order, name)
+				return invoker(ctx, method, req, reply, cc, opts...)
+			}
+		}
+		invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+			calls++
+			if calls <= len(test.failures) {
+				return test.failures[calls-1]
+			}
+			return nil
+		}
+
+		ic, err := newInterceptor(cfg, []grpc.UnaryClientInterceptor{record("a"), record("b")})
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %s", test.name, err)
+		}
+		err = ic(context.Background(), "/cats.Cats/GetCat", nil, nil, nil, invoker)
+
+		if got := grpc.Code(err); got != test.wantCode {
+			t.Errorf("%s: expected code %s, got %s", test.name, test.wantCode, got)
+		}
+		if calls != test.wantCalls {
+			t.Errorf("%s: expected %d calls, got %d", test.name, test.wantCalls, calls)
+		}
+		if len(order) != 2*calls || order[0] != "a" || order[1] != "b" {
+			t.Errorf("%s: expected interceptors a then b around each attempt, got %v", test.name, order)
+		}
+		if len(spans) != 1 || spans[0] != "/cats.Cats/GetCat" {
+			t.Errorf("%s: expected a single span for the call, got %v", test.name, spans)
+		}
+
+		timer := registry.Get("grpc.client.cats.Cats.GetCat.DURATION")
+		if timer == nil || timer.(metrics.Timer).Count() != 1 {
+			t.Errorf("%s: expected a single call duration recorded", test.name)
+		}
+		var retries int64
+		if c := registry.Get("grpc.client.cats.Cats.GetCat.RETRY"); c != nil {
+			retries = c.(metrics.Counter).Count()
+		}
+		if retries != int64(test.wantCalls-1) {
+			t.Errorf("%s: expected %d retries counted, got %d", test.name, test.wantCalls-1, retries)
+		}
+	}
+}
+
+func TestDialOptions(t *testing.T) {
+	if _, err := DialOptions(&config.GRPCClient{}); err == nil {
+		t.Error("expected an error without a Target")
+	}
+	if _, err := DialOptions(&config.GRPCClient{Target: "cats:8080", Insecure: true, Balancer: "random"}); err == nil {
+		t.Error("expected an error for an unknown balancer")
+	}
+	if _, err := DialOptions(&config.GRPCClient{Target: "cats:8080", TLSCAFile: "/does/not/exist"}); err == nil {
+		t.Error("expected an error for a missing CA file")
+	}
+	if _, err := DialOptions(&config.GRPCClient{Target: "cats:8080", Insecure: true, RetryStrategy: "linear"}); err == nil {
+		t.Error("expected an error for an unknown retry strategy")
+	}
+	if _, err := DialOptions(&config.GRPCClient{Target: "cats-1:8080,cats-2:8080", Insecure: true, Balancer: "round_robin"}); err != nil {
+		t.Error("unexpected error: ", err)
+	}
+}
+
+func TestStaticResolver(t *testing.T) {
+	w, err := staticResolver(splitTarget("cats-1:8080, cats-2:8080,")).Resolve("")
+	if err != nil {
+		t.Fatal("unexpected error: ", err)
+	}
+	updates, err := w.Next()
+	if err != nil {
+		t.Fatal("unexpected error: ", err)
+	}
+	if len(updates) != 2 || updates[0].Addr != "cats-1:8080" || updates[1].Addr != "cats-2:8080" {
+		t.Errorf("expected both addresses, got %+v", updates)
+	}
+
+	done := make(chan error)
+	go func() {
+		_, err := w.Next()
+		done <- err
+	}()
+	w.Close()
+	select {
+	case err = <-done:
+		if err == nil {
+			t.Error("expected an error from a closed watcher")
+		}
+	case <-time.After(time.Second):
+		t.Error("expected Next to return once closed")
+	}
+}
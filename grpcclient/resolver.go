@@ -0,0 +1,45 @@
+package grpcclient
+
+import (
+	"errors"
+	"sync"
+
+	"google.golang.org/grpc/naming"
+)
+
+// staticResolver is a naming.Resolver for a fixed list of addresses so
+// they may be balanced across without any service discovery.
+type staticResolver []string
+
+// Resolve will return a Watcher that reports all of the addresses once.
+func (r staticResolver) Resolve(target string) (naming.Watcher, error) {
+	return &staticWatcher{addrs: r, closed: make(chan struct{})}, nil
+}
+
+type staticWatcher struct {
+	addrs []string
+
+	once   sync.Once
+	sent   bool
+	closed chan struct{}
+}
+
+// Next will return the addresses on the first call and block until the
+// watcher is closed on any after.
+func (w *staticWatcher) Next() ([]*naming.Update, error) {
+	if !w.sent {
+		w.sent = true
+		updates := make([]*naming.Update, len(w.addrs))
+		for i, addr := range w.addrs {
+			updates[i] = &naming.Update{Op: naming.Add, Addr: addr}
+		}
+		return updates, nil
+	}
+	<-w.closed
+	return nil, errors.New("grpcclient: watcher closed")
+}
+
+// Close will unblock any call to Next.
+func (w *staticWatcher) Close() {
+	w.once.Do(func() { close(w.closed) })
+}
@@ -0,0 +1,25 @@
+package httpclient
+
+import (
+	"time"
+
+	"github.com/NYTimes/gizmo/backoff"
+)
+
+// DefaultRetryBudgetWindow is the sliding window over which a RetryBudget
+// tracks requests and retries.
+var DefaultRetryBudgetWindow = 10 * time.Second
+
+// RetryBudget limits retries and hedged requests to a ratio of the requests
+// made over DefaultRetryBudgetWindow. It is a backoff.Budget and is safe for
+// concurrent use.
+type RetryBudget struct {
+	*backoff.Budget
+}
+
+// NewRetryBudget will return a RetryBudget that allows retries up to the
+// given ratio of requests plus a minimum number of retries per second so
+// clients with low traffic can still retry.
+func NewRetryBudget(ratio float64, minPerSecond int) *RetryBudget {
+	return &RetryBudget{backoff.NewBudget(ratio, minPerSecond, DefaultRetryBudgetWindow)}
+}
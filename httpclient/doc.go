@@ -0,0 +1,28 @@
+/*
+Package httpclient produces *http.Clients for calling other services that are configured
+from a config.HTTPClient struct. Clients created by this package offer:
+
+  - dial, TLS handshake, response header and overall timeouts
+  - connection pool limits
+  - retries with a backoff.Strategy, exponential by default, for replayable requests
+  - hedged requests that send a second attempt after a fixed delay or latency percentile
+  - a retry budget that caps retries and hedges to a ratio of requests per host
+  - per-host metrics for request durations, status codes, errors and retries
+  - propagation of tracing headers from an inbound request
+  - optional DNS SRV resolution of hosts like '_cats._tcp.service.consul'
+
+A basic setup may look like:
+
+	client := httpclient.New(cfg.HTTPClient)
+
+	func (s *Service) GetCats(r *http.Request) (int, interface{}, error) {
+	    req, err := httpclient.NewRequest(r, "GET", "http://cats.example.com/cats", nil)
+	    ...
+	    resp, err := client.Do(req)
+	    ...
+	}
+
+Hedging and retry budgets are set per client, so services calling several upstreams
+with different latency profiles should create a client for each of them.
+*/
+package httpclient
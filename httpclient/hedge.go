@@ -0,0 +1,149 @@
+package httpclient
+
+import (
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// minHedgeSamples is the number of latencies needed before a hedge
+// percentile will be trusted over the fixed HedgeDelay.
+const minHedgeSamples = 20
+
+type attemptResult struct {
+	resp *http.Response
+	err  error
+	// index of the attempt
+	i int
+}
+
+// hedge will make the request and, if no response arrives within the hedge
+// delay, make a second attempt and return whichever response arrives first.
+// The slower attempt will be cancelled.
+func (t *Transport) hedge(r *http.Request, up *upstream) (*http.Response, error) {
+	delay := t.hedgeDelay(up)
+	if delay <= 0 {
+		return t.attempt(r, up)
+	}
+
+	results := make(chan attemptResult, 2)
+	// closed once the winning response has been read
+	done := make(chan struct{})
+	var doneOnce sync.Once
+	finish := func() { doneOnce.Do(func() { close(done) }) }
+
+	var (
+		mu      sync.Mutex
+		cancels []func()
+	)
+	send := func() {
+		req, cancel := cancelable(r)
+		mu.Lock()
+		i := len(cancels)
+		cancels = append(cancels, cancel)
+		mu.Unlock()
+		go func() {
+			resp, err := t.attempt(req, up)
+			results <- attemptResult{resp: resp, err: err, i: i}
+		}()
+	}
+	// pass along any cancellation of the original request
+	go func() {
+		select {
+		case <-r.Cancel:
+			mu.Lock()
+			for _, cancel := range cancels {
+				cancel()
+			}
+			mu.Unlock()
+		case <-done:
+		}
+	}()
+
+	send()
+	sent, pending := 1, 1
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	var res attemptResult
+	for {
+		select {
+		case <-timer.C:
+			if sent == 1 && (up.budget == nil || up.budget.TryRetry()) {
+				up.metrics.hedges.Inc(1)
+				send()
+				sent++
+				pending++
+			}
+			continue
+		case res = <-results:
+			pending--
+		}
+		if !shouldRetry(res.resp, res.err) || pending == 0 {
+			break
+		}
+		// another attempt is still in flight, so wait on it instead
+		if res.resp != nil {
+			res.resp.Body.Close()
+		}
+	}
+
+	// cancel the slower attempt and clean up after it
+	mu.Lock()
+	for i, cancel := range cancels {
+		if i != res.i {
+			cancel()
+		}
+	}
+	mu.Unlock()
+	go func(pending int) {
+		for ; pending > 0; pending-- {
+			if other := <-results; other.resp != nil {
+				other.resp.Body.Close()
+			}
+		}
+	}(pending)
+
+	if res.resp == nil {
+		finish()
+		return nil, res.err
+	}
+	res.resp.Body = &notifyCloser{ReadCloser: res.resp.Body, notify: finish}
+	return res.resp, res.err
+}
+
+// notifyCloser will call notify once the body is closed.
+type notifyCloser struct {
+	io.ReadCloser
+	notify func()
+}
+
+func (n *notifyCloser) Close() error {
+	err := n.ReadCloser.Close()
+	n.notify()
+	return err
+}
+
+// hedgeDelay will return how long to wait before hedging a request to the
+// upstream, or 0 if the request should not be hedged.
+func (t *Transport) hedgeDelay(up *upstream) time.Duration {
+	delay := t.HedgeDelay
+	if t.HedgePercentile > 0 && up.latencies.Count() >= minHedgeSamples {
+		if p := time.Duration(up.latencies.Percentile(t.HedgePercentile)); p > delay {
+			delay = p
+		}
+	}
+	return delay
+}
+
+// cancelable will return a shallow copy of the request with its own Cancel
+// channel so it can be cancelled without affecting other attempts.
+func cancelable(r *http.Request) (*http.Request, func()) {
+	req := new(http.Request)
+	*req = *r
+	ch := make(chan struct{})
+	req.Cancel = ch
+	var once sync.Once
+	return req, func() { once.Do(func() { close(ch) }) }
+}
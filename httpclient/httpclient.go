@@ -0,0 +1,436 @@
+package httpclient
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+
+	"github.com/NYTimes/gizmo/backoff"
+	"github.com/NYTimes/gizmo/config"
+)
+
+var (
+	// DefaultTimeout is the overall request time limit used if none is configured.
+	DefaultTimeout = 30 * time.Second
+	// DefaultDialTimeout is the connection time limit used if none is configured.
+	DefaultDialTimeout = 5 * time.Second
+	// DefaultTLSHandshakeTimeout is the TLS handshake time limit used if none is configured.
+	DefaultTLSHandshakeTimeout = 5 * time.Second
+	// DefaultMaxIdleConnsPerHost is the idle connection pool size used if none is configured.
+	DefaultMaxIdleConnsPerHost = 10
+	// DefaultRetryBaseDelay is the initial retry backoff used if none is configured.
+	DefaultRetryBaseDelay = 50 * time.Millisecond
+	// DefaultRetryMaxDelay is the retry backoff cap used if none is configured.
+	DefaultRetryMaxDelay = 2 * time.Second
+
+	// TraceHeaders are the headers that will be copied from an inbound
+	// request to an outbound request by NewRequest and PropagateHeaders.
+	TraceHeaders = []string{
+		"X-Request-Id",
+		"X-B3-TraceId",
+		"X-B3-SpanId",
+		"X-B3-ParentSpanId",
+		"X-B3-Sampled",
+		"X-Cloud-Trace-Context",
+	}
+)
+
+// New will return an *http.Client that uses the Transport returned by
+// NewTransport. If the given config is nil, all defaults will be used.
+func New(cfg *config.HTTPClient) *http.Client {
+	if cfg == nil {
+		cfg = &config.HTTPClient{}
+	}
+	timeout := cfg.Timeout
+	if timeout == 0 {
+		timeout = DefaultTimeout
+	}
+	return &http.Client{
+		Transport: NewTransport(cfg),
+		Timeout:   timeout,
+	}
+}
+
+// NewTransport will return an http.RoundTripper that will retry failed requests
+// and emit per-host metrics around a pooled http.Transport. If ResolveSRV is
+// set, the http.Transport will be wrapped in an SRVTransport.
+func NewTransport(cfg *config.HTTPClient) http.RoundTripper {
+	if cfg == nil {
+		cfg = &config.HTTPClient{}
+	}
+	dialTimeout := cfg.DialTimeout
+	if dialTimeout == 0 {
+		dialTimeout = DefaultDialTimeout
+	}
+	tlsTimeout := cfg.TLSHandshakeTimeout
+	if tlsTimeout == 0 {
+		tlsTimeout = DefaultTLSHandshakeTimeout
+	}
+	idle := cfg.MaxIdleConnsPerHost
+	if idle == 0 {
+		idle = DefaultMaxIdleConnsPerHost
+	}
+	base := cfg.RetryBaseDelay
+	if base == 0 {
+		base = DefaultRetryBaseDelay
+	}
+	max := cfg.RetryMaxDelay
+	if max == 0 {
+		max = DefaultRetryMaxDelay
+	}
+	// an unknown strategy falls back to exponential backoff
+	strategy, _ := backoff.New(cfg.RetryStrategy, base, max)
+	registry := cfg.MetricsRegistry
+	if registry == nil {
+		registry = metrics.DefaultRegistry
+	}
+
+	var rt http.RoundTripper = &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		Dial: (&net.Dialer{
+			Timeout:   dialTimeout,
+			KeepAlive: 30 * time.Second,
+		}).Dial,
+		TLSHandshakeTimeout:   tlsTimeout,
+		ResponseHeaderTimeout: cfg.ResponseHeaderTimeout,
+		MaxIdleConnsPerHost:   idle,
+	}
+	if cfg.ResolveSRV {
+		// resolve on each attempt so retries may go to another target
+		rt = &SRVTransport{Transport: rt}
+	}
+
+	return &Transport{
+		Transport:               rt,
+		MaxRetries:              cfg.MaxRetries,
+		RetryBaseDelay:          base,
+		RetryMaxDelay:           max,
+		Backoff:                 strategy,
+		RetryBudget:             cfg.RetryBudget,
+		RetryBudgetMinPerSecond: cfg.RetryBudgetMinPerSecond,
+		HedgeDelay:              cfg.HedgeDelay,
+		HedgePercentile:         cfg.HedgePercentile,
+		registry:                registry,
+	}
+}
+
+// Transport is an http.RoundTripper that wraps another RoundTripper with
+// retries and metrics.
+type Transport struct {
+	// Transport is the underlying RoundTripper used to make requests.
+	Transport http.RoundTripper
+
+	// MaxRetries is the number of additional attempts that will be made on
+	// replayable requests that fail or receive a 5xx response. A request is
+	// replayable if it has no body and an idempotent method.
+	MaxRetries int
+	// RetryBaseDelay is the initial backoff between attempts.
+	RetryBaseDelay time.Duration
+	// RetryMaxDelay is the cap on the backoff between attempts.
+	RetryMaxDelay time.Duration
+	// Backoff is the strategy used to wait between attempts. If nil,
+	// exponential backoff between RetryBaseDelay and RetryMaxDelay is used.
+	Backoff backoff.Strategy
+	// RetryBudget is the max ratio of retries and hedges to requests made to each
+	// host over the last DefaultRetryBudgetWindow. If 0, retries are unlimited.
+	RetryBudget float64
+	// RetryBudgetMinPerSecond is the number of retries per second always
+	// allowed by the RetryBudget.
+	RetryBudgetMinPerSecond int
+
+	// HedgeDelay is how long to wait on a replayable request before sending
+	// a second attempt and taking whichever response comes first. If 0, and
+	// no HedgePercentile is set, requests are not hedged.
+	HedgeDelay time.Duration
+	// HedgePercentile is the percentile (0-1) of each host's latency to wait
+	// for before hedging. The HedgeDelay will be used as a minimum.
+	HedgePercentile float64
+
+	registry metrics.Registry
+
+	mu        sync.Mutex
+	upstreams map[string]*upstream
+	// inflight holds the cancel func of each request being made
+	inflight map[*http.Request]func()
+}
+
+// upstream holds the metrics and state tracked for each host.
+type upstream struct {
+	metrics   *hostMetrics
+	budget    *RetryBudget
+	latencies metrics.Histogram
+}
+
+// ErrRetryCanceled is returned when a request is canceled while waiting to be
+// retried.
+var ErrRetryCanceled = errors.New("httpclient: request canceled while waiting to retry")
+
+// RoundTrip will execute the request, retrying if necessary, and record
+// the duration and outcome of the request in metrics keyed by the host.
+func (t *Transport) RoundTrip(r *http.Request) (*http.Response, error) {
+	r, untrack := t.track(r)
+	resp, err := t.roundTrip(r)
+	if resp == nil {
+		untrack()
+		return resp, err
+	}
+	// the request may still be canceled while its body is read
+	resp.Body = &notifyCloser{ReadCloser: resp.Body, notify: untrack}
+	return resp, err
+}
+
+func (t *Transport) roundTrip(r *http.Request) (*http.Response, error) {
+	up := t.upstream(r.URL.Host)
+	m := up.metrics
+	start := time.Now()
+	defer m.duration.UpdateSince(start)
+	if up.budget != nil {
+		up.budget.Request()
+	}
+
+	attempts := 1
+	replayable := isReplayable(r)
+	if replayable {
+		attempts += t.MaxRetries
+	}
+
+	var (
+		resp *http.Response
+		err  error
+	)
+	for i := 0; i < attempts; i++ {
+		if i > 0 {
+			m.retries.Inc(1)
+			if !t.sleep(r, i) {
+				// the failed response has already been closed
+				return nil, ErrRetryCanceled
+			}
+		}
+		if replayable {
+			resp, err = t.hedge(r, up)
+		} else {
+			resp, err = t.attempt(r, up)
+		}
+		if !shouldRetry(resp, err) || i == attempts-1 {
+			break
+		}
+		if up.budget != nil && !up.budget.TryRetry() {
+			m.budgetExhausted.Inc(1)
+			break
+		}
+		// throw away the failed response before trying again
+		if resp != nil {
+			resp.Body.Close()
+		}
+	}
+	return resp, err
+}
+
+// CancelRequest will cancel an in-flight request, along with any retries or
+// hedged attempts of it. It lets an http.Client with a Timeout use the
+// Transport on versions of Go before 1.7.
+func (t *Transport) CancelRequest(r *http.Request) {
+	t.mu.Lock()
+	cancel := t.inflight[r]
+	t.mu.Unlock()
+	if cancel != nil {
+		cancel()
+	}
+}
+
+// track will return a copy of the request whose Cancel channel is closed
+// once the request is canceled, either by its own Cancel channel or by
+// CancelRequest, along with a func to stop tracking it.
+func (t *Transport) track(r *http.Request) (*http.Request, func()) {
+	req, cancel := cancelable(r)
+	done := make(chan struct{})
+	t.mu.Lock()
+	if t.inflight == nil {
+		t.inflight = map[*http.Request]func(){}
+	}
+	t.inflight[r] = cancel
+	t.mu.Unlock()
+	go func() {
+		select {
+		case <-r.Cancel:
+			cancel()
+		case <-done:
+		}
+	}()
+
+	var once sync.Once
+	return req, func() {
+		once.Do(func() {
+			t.mu.Lock()
+			delete(t.inflight, r)
+			t.mu.Unlock()
+			close(done)
+		})
+	}
+}
+
+// canceler is a RoundTripper that can cancel its in-flight requests, like
+// the http.Transport.
+type canceler interface {
+	CancelRequest(*http.Request)
+}
+
+// attempt will make a single request to the underlying Transport
+// and record its outcome.
+func (t *Transport) attempt(r *http.Request, up *upstream) (*http.Response, error) {
+	if c, ok := t.Transport.(canceler); ok {
+		// pass cancellation along to RoundTrippers that ignore r.Cancel
+		done := make(chan struct{})
+		defer close(done)
+		go func() {
+			select {
+			case <-r.Cancel:
+				c.CancelRequest(r)
+			case <-done:
+			}
+		}()
+	}
+	start := time.Now()
+	resp, err := t.Transport.RoundTrip(r)
+	if err != nil {
+		up.metrics.errors.Inc(1)
+		return resp, err
+	}
+	up.latencies.Update(int64(time.Since(start)))
+	up.metrics.countStatus(resp.StatusCode)
+	return resp, err
+}
+
+// sleep will wait for the backoff of the given attempt. It will
+// return false if the request is cancelled while waiting.
+func (t *Transport) sleep(r *http.Request, attempt int) bool {
+	strategy := t.Backoff
+	if strategy == nil {
+		strategy = backoff.Exponential{Base: t.RetryBaseDelay, Max: t.RetryMaxDelay}
+	}
+	select {
+	case <-time.After(strategy.Delay(attempt)):
+		return true
+	case <-r.Cancel:
+		return false
+	}
+}
+
+// Backoff returns a randomized exponential backoff duration for the
+// given attempt (starting at 1) between the base and max delay. It is
+// shorthand for a backoff.Exponential strategy.
+func Backoff(base, max time.Duration, attempt int) time.Duration {
+	return backoff.Exponential{Base: base, Max: max}.Delay(attempt)
+}
+
+func isReplayable(r *http.Request) bool {
+	if r.Body != nil {
+		return false
+	}
+	switch r.Method {
+	case "", "GET", "HEAD", "OPTIONS", "PUT", "DELETE":
+		return true
+	}
+	return false
+}
+
+func shouldRetry(resp *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	return resp.StatusCode >= 500 && resp.StatusCode != http.StatusNotImplemented
+}
+
+// upstream will return the state for the given host.
+func (t *Transport) upstream(host string) *upstream {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if up, ok := t.upstreams[host]; ok {
+		return up
+	}
+	if t.upstreams == nil {
+		t.upstreams = map[string]*upstream{}
+	}
+	up := &upstream{
+		metrics:   t.hostMetrics(host),
+		latencies: metrics.NewHistogram(metrics.NewExpDecaySample(1028, 0.015)),
+	}
+	if t.RetryBudget > 0 {
+		up.budget = NewRetryBudget(t.RetryBudget, t.RetryBudgetMinPerSecond)
+	}
+	t.upstreams[host] = up
+	return up
+}
+
+type hostMetrics struct {
+	duration        metrics.Timer
+	errors          metrics.Counter
+	retries         metrics.Counter
+	hedges          metrics.Counter
+	budgetExhausted metrics.Counter
+	statuses        [5]metrics.Counter
+}
+
+func (t *Transport) hostMetrics(host string) *hostMetrics {
+	registry := t.registry
+	if registry == nil {
+		registry = metrics.DefaultRegistry
+	}
+	name := "httpclient." + metricHostName(host)
+	m := &hostMetrics{
+		duration:        metrics.GetOrRegisterTimer(name+".DURATION", registry),
+		errors:          metrics.GetOrRegisterCounter(name+".ERROR", registry),
+		retries:         metrics.GetOrRegisterCounter(name+".RETRY", registry),
+		hedges:          metrics.GetOrRegisterCounter(name+".HEDGE", registry),
+		budgetExhausted: metrics.GetOrRegisterCounter(name+".RETRY-BUDGET-EXHAUSTED", registry),
+	}
+	for i := range m.statuses {
+		m.statuses[i] = metrics.GetOrRegisterCounter(fmt.Sprintf("%s.STATUS-COUNT-%dxx", name, i+1), registry)
+	}
+	return m
+}
+
+func (m *hostMetrics) countStatus(code int) {
+	i := code/100 - 1
+	if i < 0 {
+		i = 0
+	} else if i > 4 {
+		i = 4
+	}
+	m.statuses[i].Inc(1)
+}
+
+func metricHostName(host string) string {
+	host = strings.Replace(host, ".", "-", -1)
+	return strings.Replace(host, ":", "-", -1)
+}
+
+// NewRequest will create a new outbound request and copy any TraceHeaders
+// found on the given inbound request. The inbound request may be nil.
+func NewRequest(in *http.Request, method, urlStr string, body io.Reader) (*http.Request, error) {
+	out, err := http.NewRequest(method, urlStr, body)
+	if err != nil {
+		return out, err
+	}
+	PropagateHeaders(in, out)
+	return out, nil
+}
+
+// PropagateHeaders will copy any TraceHeaders from one request to another.
+func PropagateHeaders(from, to *http.Request) {
+	if from == nil {
+		return
+	}
+	for _, hdr := range TraceHeaders {
+		if val := from.Header.Get(hdr); val != "" {
+			to.Header.Set(hdr, val)
+		}
+	}
+}
@@ -0,0 +1,332 @@
+package httpclient
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+
+	"github.com/NYTimes/gizmo/config"
+)
+
+func TestClientRetries(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	registry := metrics.NewRegistry()
+	client := New(&config.HTTPClient{
+		MaxRetries:      2,
+		RetryBaseDelay:  time.Millisecond,
+		RetryMaxDelay:   time.Millisecond,
+		MetricsRegistry: registry,
+	})
+
+	tests := []struct {
+		method string
+		body   string
+
+		wantCode  int
+		wantCalls int32
+	}{
+		{"GET", "", http.StatusOK, 3},
+		{"POST", "", http.StatusServiceUnavailable, 1},
+		{"PUT", "body", http.StatusServiceUnavailable, 1},
+	}
+
+	for _, test := range tests {
+		atomic.StoreInt32(&calls, 0)
+		var req *http.Request
+		if test.body != "" {
+			req, _ = http.NewRequest(test.method, srv.URL, strings.NewReader(test.body))
+		} else {
+			req, _ = http.NewRequest(test.method, srv.URL, nil)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("%s request returned unexpected error: %s", test.method, err)
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode != test.wantCode {
+			t.Errorf("%s request expected status code of %d, got %d", test.method, test.wantCode, resp.StatusCode)
+		}
+		if got := atomic.LoadInt32(&calls); got != test.wantCalls {
+			t.Errorf("%s request expected %d calls, got %d", test.method, test.wantCalls, got)
+		}
+	}
+
+	u, _ := url.Parse(srv.URL)
+	name := "httpclient." + metricHostName(u.Host)
+	if got := metrics.GetOrRegisterCounter(name+".RETRY", registry).Count(); got != 2 {
+		t.Errorf("expected 2 retries to be counted, got %d", got)
+	}
+	if got := metrics.GetOrRegisterCounter(name+".STATUS-COUNT-5xx", registry).Count(); got != 4 {
+		t.Errorf("expected 4 5xx responses to be counted, got %d", got)
+	}
+	if got := metrics.GetOrRegisterTimer(name+".DURATION", registry).Count(); got != 3 {
+		t.Errorf("expected 3 request durations to be recorded, got %d", got)
+	}
+}
+
+func TestClientRetryCanceled(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	client := New(&config.HTTPClient{
+		MaxRetries:      2,
+		RetryBaseDelay:  time.Minute,
+		RetryMaxDelay:   time.Minute,
+		MetricsRegistry: metrics.NewRegistry(),
+	})
+
+	req, _ := http.NewRequest("GET", srv.URL, nil)
+	cancel := make(chan struct{})
+	req.Cancel = cancel
+	time.AfterFunc(50*time.Millisecond, func() { close(cancel) })
+
+	resp, err := client.Do(req)
+	if err == nil {
+		t.Fatal("expected an error for a request canceled while waiting to retry, got none")
+	}
+	if resp != nil {
+		t.Errorf("expected no response, got %d", resp.StatusCode)
+	}
+}
+
+func TestClientTimeout(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	client := New(&config.HTTPClient{
+		Timeout:         50 * time.Millisecond,
+		MetricsRegistry: metrics.NewRegistry(),
+	})
+	resp, err := client.Get(srv.URL)
+	if err == nil {
+		resp.Body.Close()
+		t.Fatal("expected an error for a request that timed out, got none")
+	}
+	if strings.Contains(err.Error(), "CancelRequest") {
+		t.Errorf("expected the Transport to support CancelRequest, got %s", err)
+	}
+	if n := len(client.Transport.(*Transport).inflight); n != 0 {
+		t.Errorf("expected no requests in flight, got %d", n)
+	}
+}
+
+func TestTransportCancelRequest(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	tr := NewTransport(&config.HTTPClient{
+		MaxRetries:      2,
+		RetryBaseDelay:  time.Minute,
+		RetryMaxDelay:   time.Minute,
+		MetricsRegistry: metrics.NewRegistry(),
+	}).(*Transport)
+
+	// cancel while waiting to retry, as an http.Client with a Timeout
+	// would before Go 1.7
+	req, _ := http.NewRequest("GET", srv.URL, nil)
+	time.AfterFunc(50*time.Millisecond, func() { tr.CancelRequest(req) })
+	resp, err := tr.RoundTrip(req)
+	if err != ErrRetryCanceled {
+		t.Errorf("expected ErrRetryCanceled, got %v", err)
+	}
+	if resp != nil {
+		t.Errorf("expected no response, got %d", resp.StatusCode)
+	}
+
+	resp, err = tr.RoundTrip(&http.Request{Method: "POST", URL: req.URL, Header: http.Header{}})
+	if err != nil {
+		t.Fatalf("expected no error, got %s", err)
+	}
+	if len(tr.inflight) != 1 {
+		t.Errorf("expected the request to be in flight until its body is closed, got %d", len(tr.inflight))
+	}
+	resp.Body.Close()
+	if len(tr.inflight) != 0 {
+		t.Errorf("expected no requests in flight, got %d", len(tr.inflight))
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	base, max := 10*time.Millisecond, 50*time.Millisecond
+	tests := []struct {
+		attempt int
+
+		wantMin time.Duration
+		wantMax time.Duration
+	}{
+		{1, 5 * time.Millisecond, 10 * time.Millisecond},
+		{2, 10 * time.Millisecond, 20 * time.Millisecond},
+		{3, 20 * time.Millisecond, 40 * time.Millisecond},
+		{10, 25 * time.Millisecond, 50 * time.Millisecond},
+	}
+
+	for _, test := range tests {
+		got := Backoff(base, max, test.attempt)
+		if got < test.wantMin || got > test.wantMax {
+			t.Errorf("attempt %d expected backoff between %s and %s, got %s",
+				test.attempt, test.wantMin, test.wantMax, got)
+		}
+	}
+}
+
+func TestNewRequest(t *testing.T) {
+	in, _ := http.NewRequest("GET", "/", nil)
+	in.Header.Set("X-Request-Id", "abc")
+	in.Header.Set("Cookie", "secret")
+
+	out, err := NewRequest(in, "GET", "http://example.com", nil)
+	if err != nil {
+		t.Fatal("unexpected error creating request: ", err)
+	}
+	if got := out.Header.Get("X-Request-Id"); got != "abc" {
+		t.Errorf("expected request id header of 'abc', got %q", got)
+	}
+	if got := out.Header.Get("Cookie"); got != "" {
+		t.Errorf("expected no cookie header to be propagated, got %q", got)
+	}
+}
+
+func TestClientHedging(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// the first attempt is slow
+		if atomic.AddInt32(&calls, 1) == 1 {
+			select {
+			case <-time.After(2 * time.Second):
+			case <-w.(http.CloseNotifier).CloseNotify():
+			}
+			return
+		}
+		io.WriteString(w, "fast")
+	}))
+	defer srv.Close()
+
+	registry := metrics.NewRegistry()
+	client := New(&config.HTTPClient{
+		HedgeDelay:      20 * time.Millisecond,
+		MetricsRegistry: registry,
+	})
+
+	start := time.Now()
+	resp, err := client.Get(srv.URL)
+	if err != nil {
+		t.Fatal("unexpected error from hedged request: ", err)
+	}
+	body, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != "fast" {
+		t.Errorf("expected the hedged response 'fast', got '%s'", body)
+	}
+	if took := time.Since(start); took > time.Second {
+		t.Errorf("expected the hedged request to return quickly, took %s", took)
+	}
+
+	u, _ := url.Parse(srv.URL)
+	name := "httpclient." + metricHostName(u.Host)
+	if got := metrics.GetOrRegisterCounter(name+".HEDGE", registry).Count(); got != 1 {
+		t.Errorf("expected 1 hedge to be counted, got %d", got)
+	}
+
+	// non-replayable requests are never hedged
+	atomic.StoreInt32(&calls, 1)
+	resp, err = client.Post(srv.URL, "text/plain", strings.NewReader("cats"))
+	if err != nil {
+		t.Fatal("unexpected error from POST request: ", err)
+	}
+	resp.Body.Close()
+	if got := atomic.LoadInt32(&calls); got != 2 {
+		t.Errorf("expected 1 POST call, got %d", got-1)
+	}
+}
+
+func TestClientRetryBudget(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	registry := metrics.NewRegistry()
+	client := New(&config.HTTPClient{
+		MaxRetries:      3,
+		RetryBaseDelay:  time.Millisecond,
+		RetryMaxDelay:   time.Millisecond,
+		RetryBudget:     0.5,
+		MetricsRegistry: registry,
+	})
+
+	for i := 0; i < 4; i++ {
+		resp, err := client.Get(srv.URL)
+		if err != nil {
+			t.Fatal("unexpected error: ", err)
+		}
+		resp.Body.Close()
+	}
+
+	// 4 requests with a 50% budget allows 2 retries in total
+	if got := atomic.LoadInt32(&calls); got != 6 {
+		t.Errorf("expected 6 calls, got %d", got)
+	}
+	u, _ := url.Parse(srv.URL)
+	name := "httpclient." + metricHostName(u.Host)
+	if got := metrics.GetOrRegisterCounter(name+".RETRY-BUDGET-EXHAUSTED", registry).Count(); got != 4 {
+		t.Errorf("expected budget to be exhausted 4 times, got %d", got)
+	}
+}
+
+func TestRetryBudget(t *testing.T) {
+	tests := []struct {
+		ratio    float64
+		min      int
+		requests int
+
+		wantRetries int
+	}{
+		{0.5, 0, 4, 2},
+		{0.1, 0, 5, 0},
+		{0.1, 1, 5, 10},
+		{1, 0, 3, 3},
+	}
+
+	for _, test := range tests {
+		b := NewRetryBudget(test.ratio, test.min)
+		for i := 0; i < test.requests; i++ {
+			b.Request()
+		}
+		var got int
+		for i := 0; i < 100 && b.TryRetry(); i++ {
+			got++
+		}
+		if got != test.wantRetries {
+			t.Errorf("expected %d retries with ratio %f, min %d and %d requests, got %d",
+				test.wantRetries, test.ratio, test.min, test.requests, got)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package httpclient
+
+import (
+	"math/rand"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultSRVRefresh is how long resolved SRV records will be cached if no
+// Refresh is given.
+var DefaultSRVRefresh = 30 * time.Second
+
+// lookupSRV is swapped out in tests.
+var lookupSRV = net.LookupSRV
+
+// SRVTransport is an http.RoundTripper that will send requests for hosts
+// named like a DNS SRV record, such as '_cats._tcp.service.consul', to one
+// of the targets of that record. Targets are picked by priority and weight as
+// described by RFC 2782. Requests for any other host are passed through as-is.
+type SRVTransport struct {
+	// Transport is the underlying RoundTripper used to make requests.
+	Transport http.RoundTripper
+	// Refresh is how long resolved records will be cached for. Defaults
+	// to DefaultSRVRefresh.
+	Refresh time.Duration
+
+	mu      sync.Mutex
+	records map[string]srvRecords
+}
+
+type srvRecords struct {
+	addrs    []*net.SRV
+	resolved time.Time
+}
+
+// RoundTrip will resolve the request's host, if it is an SRV name, and
+// send a copy of the request to the chosen target.
+func (t *SRVTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	if !strings.HasPrefix(r.URL.Host, "_") {
+		return t.Transport.RoundTrip(r)
+	}
+	target, err := t.resolve(r.URL.Host)
+	if err != nil {
+		return nil, err
+	}
+	out := new(http.Request)
+	*out = *r
+	u := *r.URL
+	u.Host = target
+	out.URL = &u
+	// keep the service name for virtual hosting
+	if out.Host == "" {
+		out.Host = r.URL.Host
+	}
+	return t.Transport.RoundTrip(out)
+}
+
+// resolve will return the 'host:port' of a target of the given SRV name.
+func (t *SRVTransport) resolve(name string) (string, error) {
+	refresh := t.Refresh
+	if refresh == 0 {
+		refresh = DefaultSRVRefresh
+	}
+	t.mu.Lock()
+	recs, ok := t.records[name]
+	t.mu.Unlock()
+	if !ok || time.Since(recs.resolved) > refresh {
+		_, addrs, err := lookupSRV("", "", name)
+		if err != nil {
+			// keep using stale records if DNS is unavailable
+			if !ok || len(recs.addrs) == 0 {
+				return "", err
+			}
+		} else {
+			recs = srvRecords{addrs: addrs, resolved: time.Now()}
+			t.mu.Lock()
+			if t.records == nil {
+				t.records = map[string]srvRecords{}
+			}
+			t.records[name] = recs
+			t.mu.Unlock()
+		}
+	}
+	if len(recs.addrs) == 0 {
+		return "", &net.DNSError{Err: "no SRV records", Name: name}
+	}
+	srv := pickSRV(recs.addrs)
+	return net.JoinHostPort(strings.TrimSuffix(srv.Target, "."), strconv.Itoa(int(srv.Port))), nil
+}
+
+// pickSRV will choose a record with the lowest priority, weighted
+// randomly by the weights of the records with that priority.
+func pickSRV(addrs []*net.SRV) *net.SRV {
+	sorted := make([]*net.SRV, len(addrs))
+	copy(sorted, addrs)
+	sort.Sort(byPriority(sorted))
+	var (
+		best  []*net.SRV
+		total int
+	)
+	for _, a := range sorted {
+		if a.Priority != sorted[0].Priority {
+			break
+		}
+		best = append(best, a)
+		total += int(a.Weight)
+	}
+	if total == 0 {
+		return best[rand.Intn(len(best))]
+	}
+	n := rand.Intn(total)
+	for _, a := range best {
+		if n < int(a.Weight) {
+			return a
+		}
+		n -= int(a.Weight)
+	}
+	return best[len(best)-1]
+}
+
+type byPriority []*net.SRV
+
+func (s byPriority) Len() int           { return len(s) }
+func (s byPriority) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s byPriority) Less(i, j int) bool { return s[i].Priority < s[j].Priority }
@@ -0,0 +1,69 @@
+package httpclient
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestSRVTransport(t *testing.T) {
+	var host string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host = r.Host
+	}))
+	defer srv.Close()
+	_, port, _ := net.SplitHostPort(strings.TrimPrefix(srv.URL, "http://"))
+	p, _ := strconv.Atoi(port)
+
+	lookups := 0
+	lookupSRV = func(service, proto, name string) (string, []*net.SRV, error) {
+		lookups++
+		if name != "_cats._tcp.service.consul" {
+			return "", nil, errors.New("no such host")
+		}
+		return name, []*net.SRV{
+			{Target: "127.0.0.1.", Port: uint16(p), Priority: 1, Weight: 1},
+			{Target: "backup.example.com.", Port: 80, Priority: 2, Weight: 1},
+		}, nil
+	}
+	defer func() { lookupSRV = net.LookupSRV }()
+
+	client := &http.Client{Transport: &SRVTransport{Transport: http.DefaultTransport}}
+	for i := 0; i < 2; i++ {
+		resp, err := client.Get("http://_cats._tcp.service.consul/cats")
+		if err != nil {
+			t.Fatal("unexpected error: ", err)
+		}
+		resp.Body.Close()
+	}
+	if host != "_cats._tcp.service.consul" {
+		t.Errorf("expected the SRV name as the Host header, got %s", host)
+	}
+	if lookups != 1 {
+		t.Errorf("expected SRV records to be cached, got %d lookups", lookups)
+	}
+
+	if _, err := client.Get("http://_dogs._tcp.service.consul/dogs"); err == nil {
+		t.Error("expected an error for an unknown SRV name")
+	}
+	if _, err := client.Get(srv.URL); err != nil {
+		t.Error("expected non-SRV hosts to pass through, got error: ", err)
+	}
+}
+
+func TestPickSRV(t *testing.T) {
+	addrs := []*net.SRV{
+		{Target: "c", Priority: 2, Weight: 100},
+		{Target: "a", Priority: 1, Weight: 0},
+		{Target: "b", Priority: 1, Weight: 10},
+	}
+	for i := 0; i < 100; i++ {
+		if got := pickSRV(addrs).Target; got != "b" {
+			t.Fatalf("expected the weighted record of the lowest priority, got %s", got)
+		}
+	}
+}
@@ -0,0 +1,24 @@
+/*
+Package iam generates the minimal AWS IAM policy a service needs for the
+resources in its gizmo config, so its runtime permissions are kept in sync
+with its code instead of being maintained by hand.
+
+Each func returns the Statement granting only the actions gizmo's clients
+call on a resource, like sns:Publish for an SNSPublisher's topics or the
+receive, delete and visibility actions for an SQSSubscriber's queues. The
+statements are combined into a Policy that marshals to the IAM policy JSON:
+
+	_, sns, sqs, s3, _, _ := config.LoadAWSFromEnv()
+	policy := iam.NewPolicy(
+		iam.SNSPublish(sns),
+		iam.SQSConsume(sqs, accountID),
+		iam.S3ReadWrite(s3),
+		iam.KMSEncryptDecrypt(keyARN),
+	)
+	b, err := policy.JSON()
+
+FromEnv will do the same for every resource configured in the environment.
+Resources are named in the partition of their region, so policies for
+GovCloud and China regions use the 'aws-us-gov' and 'aws-cn' ARNs.
+*/
+package iam
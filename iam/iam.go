@@ -0,0 +1,156 @@
+package iam
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/NYTimes/gizmo/config"
+)
+
+// Version is the version of the IAM policy language used by generated
+// policies.
+const Version = "2012-10-17"
+
+// Policy is an IAM policy document.
+type Policy struct {
+	Version   string
+	Statement []Statement
+}
+
+// Statement grants the Actions on the Resources of a Policy.
+type Statement struct {
+	Sid      string `json:",omitempty"`
+	Effect   string
+	Action   []string
+	Resource []string
+}
+
+// NewPolicy will return a Policy with the given statements, dropping any
+// without a resource.
+func NewPolicy(statements ...Statement) *Policy {
+	p := &Policy{Version: Version}
+	for _, s := range statements {
+		if len(s.Resource) == 0 {
+			continue
+		}
+		p.Statement = append(p.Statement, s)
+	}
+	return p
+}
+
+// JSON will return the policy as indented JSON, ready to be attached to a
+// role.
+func (p *Policy) JSON() ([]byte, error) {
+	return json.MarshalIndent(p, "", "  ")
+}
+
+// FromEnv will return the Policy for the SNS topics, SQS queues and S3
+// bucket configured in the environment, as loaded by
+// config.LoadAWSFromEnv, and for any given KMS keys. SQS queues are named
+// by name in the config, so the accountID that owns them is required to
+// build their ARNs. If empty, queues of that name in any account are
+// allowed.
+func FromEnv(accountID string, kmsKeys ...string) *Policy {
+	_, sns, sqs, s3, _, _ := config.LoadAWSFromEnv()
+	var statements []Statement
+	if sns != nil {
+		statements = append(statements, SNSPublish(sns))
+	}
+	if sqs != nil {
+		statements = append(statements, SQSConsume(sqs, accountID))
+	}
+	if s3 != nil {
+		statements = append(statements, S3ReadWrite(s3)...)
+	}
+	statements = append(statements, KMSEncryptDecrypt(kmsKeys...))
+	return NewPolicy(statements...)
+}
+
+// SNSPublish will return the Statement allowing an SNSPublisher or
+// RegionalPublisher to verify and publish to the config's Topic and
+// FailoverTopics.
+func SNSPublish(cfg *config.SNS) Statement {
+	s := Statement{
+		Sid:    "SNSPublish",
+		Effect: "Allow",
+		Action: []string{"sns:GetTopicAttributes", "sns:Publish"},
+	}
+	if cfg.Topic != "" {
+		s.Resource = append(s.Resource, cfg.Topic)
+	}
+	s.Resource = append(s.Resource, cfg.FailoverTopics...)
+	return s
+}
+
+// SQSConsume will return the Statement allowing an SQSSubscriber to
+// consume the config's QueueName in its Region and each of its
+// FailoverRegions, owned by the given account.
+func SQSConsume(cfg *config.SQS, accountID string) Statement {
+	s := Statement{
+		Sid:    "SQSConsume",
+		Effect: "Allow",
+		Action: []string{
+			"sqs:ChangeMessageVisibility",
+			"sqs:DeleteMessage",
+			"sqs:GetQueueAttributes",
+			"sqs:GetQueueUrl",
+			"sqs:ReceiveMessage",
+		},
+	}
+	if cfg.QueueName == "" {
+		return s
+	}
+	if accountID == "" {
+		accountID = "*"
+	}
+	for _, region := range append([]string{cfg.Region}, cfg.FailoverRegions...) {
+		s.Resource = append(s.Resource, strings.Join([]string{
+			"arn", Partition(region), "sqs", region, accountID, cfg.QueueName,
+		}, ":"))
+	}
+	return s
+}
+
+// S3ReadWrite will return the Statements allowing the config's Bucket to
+// be listed and its objects to be read, written and deleted, as by an
+// objectstore.S3 or an S3 file source.
+func S3ReadWrite(cfg *config.S3) []Statement {
+	list := Statement{
+		Sid:    "S3List",
+		Effect: "Allow",
+		Action: []string{"s3:ListBucket"},
+	}
+	objects := Statement{
+		Sid:    "S3Objects",
+		Effect: "Allow",
+		Action: []string{"s3:DeleteObject", "s3:GetObject", "s3:PutObject"},
+	}
+	if cfg.Bucket != "" {
+		bucket := "arn:" + Partition(cfg.Region) + ":s3:::" + cfg.Bucket
+		list.Resource = []string{bucket}
+		objects.Resource = []string{bucket + "/*"}
+	}
+	return []Statement{list, objects}
+}
+
+// KMSEncryptDecrypt will return the Statement allowing messages and
+// objects encrypted with the given KMS key ARNs to be written and read.
+func KMSEncryptDecrypt(keys ...string) Statement {
+	return Statement{
+		Sid:      "KMSEncryptDecrypt",
+		Effect:   "Allow",
+		Action:   []string{"kms:Decrypt", "kms:GenerateDataKey"},
+		Resource: keys,
+	}
+}
+
+// Partition will return the AWS partition of the region, for use in ARNs.
+func Partition(region string) string {
+	switch {
+	case strings.HasPrefix(region, "us-gov-"):
+		return "aws-us-gov"
+	case strings.HasPrefix(region, "cn-"):
+		return "aws-cn"
+	}
+	return "aws"
+}
@@ -0,0 +1,63 @@
+package iam
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/NYTimes/gizmo/config"
+)
+
+func TestNewPolicy(t *testing.T) {
+	sns := &config.SNS{
+		Topic:          "arn:aws-us-gov:sns:us-gov-west-1:123:cats",
+		FailoverTopics: []string{"arn:aws-us-gov:sns:us-gov-east-1:123:cats"},
+	}
+	sqs := &config.SQS{QueueName: "cats", FailoverRegions: []string{"us-gov-east-1"}}
+	sqs.Region = "us-gov-west-1"
+	s3 := &config.S3{}
+
+	p := NewPolicy(append([]Statement{
+		SNSPublish(sns),
+		SQSConsume(sqs, "123"),
+		KMSEncryptDecrypt(),
+	}, S3ReadWrite(s3)...)...)
+
+	if len(p.Statement) != 2 {
+		t.Fatalf("expected 2 statements without an S3 bucket or KMS keys, got %d", len(p.Statement))
+	}
+	if got := p.Statement[0].Resource; !reflect.DeepEqual(got, []string{sns.Topic, sns.FailoverTopics[0]}) {
+		t.Errorf("expected both SNS topics, got %v", got)
+	}
+	want := []string{
+		"arn:aws-us-gov:sqs:us-gov-west-1:123:cats",
+		"arn:aws-us-gov:sqs:us-gov-east-1:123:cats",
+	}
+	if got := p.Statement[1].Resource; !reflect.DeepEqual(got, want) {
+		t.Errorf("expected SQS queues %v, got %v", want, got)
+	}
+
+	b, err := p.JSON()
+	if err != nil {
+		t.Fatalf("expected no error, got %s", err)
+	}
+	var doc map[string]interface{}
+	if err := json.Unmarshal(b, &doc); err != nil {
+		t.Fatalf("expected valid JSON, got %s", err)
+	}
+	if doc["Version"] != Version {
+		t.Errorf("expected version %s, got %v", Version, doc["Version"])
+	}
+}
+
+func TestS3ReadWrite(t *testing.T) {
+	s3 := &config.S3{Bucket: "cat-pics"}
+	s3.Region = "us-east-1"
+	got := S3ReadWrite(s3)
+	if got[0].Resource[0] != "arn:aws:s3:::cat-pics" {
+		t.Errorf("expected the bucket ARN, got %s", got[0].Resource[0])
+	}
+	if got[1].Resource[0] != "arn:aws:s3:::cat-pics/*" {
+		t.Errorf("expected the bucket's objects, got %s", got[1].Resource[0])
+	}
+}
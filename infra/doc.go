@@ -0,0 +1,40 @@
+/*
+Package infra exports the AWS resources implied by a service's pubsub
+config as Terraform or CloudFormation, so infrastructure review can see
+exactly which topics, queues, dead letter queues and subscriptions the
+service expects to exist.
+
+A Manifest lists the SNS topic a service publishes to, the SQS queue it
+consumes, the queue's dead letter queue and redrive policy and the queue's
+subscription to an SNS topic, along with the queue policy that allows the
+topic to deliver to it. Subscriptions use raw message delivery, as the
+SQSSubscriber expects. Resources in the FailoverTopics and FailoverRegions
+of a config are not included, as they are usually exported from the config
+of each region.
+
+Services can offer an 'infra export' command from their main:
+
+	if len(os.Args) > 2 && os.Args[1] == "infra" && os.Args[2] == "export" {
+	    err := infra.FromEnv().Export(os.Stdout, infra.Terraform)
+	    ...
+	}
+
+Which, for a service consuming the 'cats' queue from the 'animals' topic of
+another service, will write:
+
+	resource "aws_sqs_queue" "cats_dlq" {
+	  name = "cats-dlq"
+	}
+
+	resource "aws_sqs_queue" "cats" {
+	  name = "cats"
+	  redrive_policy = jsonencode({
+	    deadLetterTargetArn = aws_sqs_queue.cats_dlq.arn
+	    maxReceiveCount     = 5
+	  })
+	}
+
+	resource "aws_sns_topic_subscription" "cats_animals" {
+	  ...
+*/
+package infra
@@ -0,0 +1,260 @@
+package infra
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/template"
+	"unicode"
+
+	"github.com/NYTimes/gizmo/config"
+)
+
+// DefaultMaxReceiveCount is how many times a message is received before
+// it is moved to a dead letter queue if the config has no MaxReceiveCount.
+var DefaultMaxReceiveCount = 5
+
+// Format is a format a Manifest can be exported in.
+type Format string
+
+const (
+	// Terraform will export resources of the Terraform AWS provider.
+	Terraform Format = "terraform"
+	// CloudFormation will export a CloudFormation template in JSON.
+	CloudFormation Format = "cloudformation"
+)
+
+type (
+	// Manifest lists the resources implied by a service's pubsub config.
+	Manifest struct {
+		Topics        []Topic
+		Queues        []Queue
+		Subscriptions []Subscription
+	}
+
+	// Topic is an SNS topic.
+	Topic struct {
+		Name string
+	}
+
+	// Queue is an SQS queue with an optional dead letter queue.
+	Queue struct {
+		Name            string
+		DeadLetterQueue string
+		MaxReceiveCount int
+	}
+
+	// Subscription subscribes a Queue to an SNS topic, which may be owned
+	// by another service.
+	Subscription struct {
+		TopicARN           string
+		Queue              string
+		RawMessageDelivery bool
+	}
+)
+
+// NewManifest will return the Manifest for the given configs, either of
+// which may be nil.
+func NewManifest(sns *config.SNS, sqs *config.SQS) *Manifest {
+	m := &Manifest{}
+	if sns != nil && sns.Topic != "" {
+		m.Topics = append(m.Topics, Topic{Name: arnName(sns.Topic)})
+	}
+	if sqs != nil && sqs.QueueName != "" {
+		q := Queue{Name: sqs.QueueName, DeadLetterQueue: sqs.DeadLetterQueueName}
+		if q.DeadLetterQueue != "" {
+			q.MaxReceiveCount = sqs.MaxReceiveCount
+			if q.MaxReceiveCount == 0 {
+				q.MaxReceiveCount = DefaultMaxReceiveCount
+			}
+		}
+		m.Queues = append(m.Queues, q)
+		if sqs.SubscribedTopic != "" {
+			m.Subscriptions = append(m.Subscriptions, Subscription{
+				TopicARN: sqs.SubscribedTopic,
+				Queue:    sqs.QueueName,
+				// the SQSSubscriber reads message bodies as published
+				RawMessageDelivery: true,
+			})
+		}
+	}
+	return m
+}
+
+// FromEnv will return the Manifest for the SNS and SQS configs in the
+// environment, as loaded by config.LoadAWSFromEnv.
+func FromEnv() *Manifest {
+	_, sns, sqs, _, _, _ := config.LoadAWSFromEnv()
+	return NewManifest(sns, sqs)
+}
+
+// Export will write the manifest to w in the given format.
+func (m *Manifest) Export(w io.Writer, f Format) error {
+	switch f {
+	case Terraform:
+		return m.Terraform(w)
+	case CloudFormation:
+		return m.CloudFormation(w)
+	}
+	return fmt.Errorf("infra: unknown format %q", f)
+}
+
+// Terraform will write the manifest as resources of the Terraform AWS
+// provider.
+func (m *Manifest) Terraform(w io.Writer) error {
+	var buf bytes.Buffer
+	if err := terraformTmpl.Execute(&buf, m); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, strings.TrimSpace(buf.String())+"\n")
+	return err
+}
+
+// CloudFormation will write the manifest as a CloudFormation template.
+func (m *Manifest) CloudFormation(w io.Writer) error {
+	resources := map[string]interface{}{}
+	for _, t := range m.Topics {
+		resources[logicalID(t.Name, "Topic")] = map[string]interface{}{
+			"Type":       "AWS::SNS::Topic",
+			"Properties": map[string]interface{}{"TopicName": t.Name},
+		}
+	}
+	for _, q := range m.Queues {
+		props := map[string]interface{}{"QueueName": q.Name}
+		if q.DeadLetterQueue != "" {
+			dlq := logicalID(q.DeadLetterQueue, "Queue")
+			resources[dlq] = map[string]interface{}{
+				"Type":       "AWS::SQS::Queue",
+				"Properties": map[string]interface{}{"QueueName": q.DeadLetterQueue},
+			}
+			props["RedrivePolicy"] = map[string]interface{}{
+				"deadLetterTargetArn": getAtt(dlq, "Arn"),
+				"maxReceiveCount":     q.MaxReceiveCount,
+			}
+		}
+		resources[logicalID(q.Name, "Queue")] = map[string]interface{}{
+			"Type":       "AWS::SQS::Queue",
+			"Properties": props,
+		}
+	}
+	for _, s := range m.Subscriptions {
+		queue := logicalID(s.Queue, "Queue")
+		name := s.Queue + "-" + arnName(s.TopicARN)
+		resources[logicalID(name, "Subscription")] = map[string]interface{}{
+			"Type": "AWS::SNS::Subscription",
+			"Properties": map[string]interface{}{
+				"TopicArn":           s.TopicARN,
+				"Protocol":           "sqs",
+				"Endpoint":           getAtt(queue, "Arn"),
+				"RawMessageDelivery": s.RawMessageDelivery,
+			},
+		}
+		resources[logicalID(name, "QueuePolicy")] = map[string]interface{}{
+			"Type": "AWS::SQS::QueuePolicy",
+			"Properties": map[string]interface{}{
+				"Queues": []interface{}{map[string]string{"Ref": queue}},
+				"PolicyDocument": map[string]interface{}{
+					"Version": "2012-10-17",
+					"Statement": []interface{}{map[string]interface{}{
+						"Effect":    "Allow",
+						"Principal": map[string]string{"Service": "sns.amazonaws.com"},
+						"Action":    "sqs:SendMessage",
+						"Resource":  getAtt(queue, "Arn"),
+						"Condition": map[string]interface{}{
+							"ArnEquals": map[string]string{"aws:SourceArn": s.TopicARN},
+						},
+					}},
+				},
+			},
+		}
+	}
+	b, err := json.MarshalIndent(map[string]interface{}{
+		"AWSTemplateFormatVersion": "2010-09-09",
+		"Resources":                resources,
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(b, '\n'))
+	return err
+}
+
+func getAtt(id, attr string) map[string][]string {
+	return map[string][]string{"Fn::GetAtt": {id, attr}}
+}
+
+// arnName will return the resource name at the end of an ARN.
+func arnName(arn string) string {
+	return arn[strings.LastIndex(arn, ":")+1:]
+}
+
+// logicalID will return a CloudFormation logical ID, like 'CatsDlqQueue'
+// for the 'cats-dlq' queue.
+func logicalID(name, kind string) string {
+	words := strings.FieldsFunc(name, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for i, w := range words {
+		words[i] = strings.ToUpper(w[:1]) + w[1:]
+	}
+	return strings.Join(words, "") + kind
+}
+
+// ident will return a Terraform resource name, like 'cats_dlq' for the
+// 'cats-dlq' queue.
+func ident(name string) string {
+	id := strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return r
+		}
+		return '_'
+	}, name)
+	if id == "" || unicode.IsDigit(rune(id[0])) {
+		id = "_" + id
+	}
+	return id
+}
+
+var terraformTmpl = template.Must(template.New("terraform").Funcs(template.FuncMap{
+	"ident":   ident,
+	"arnName": arnName,
+}).Parse(`{{range .Topics}}resource "aws_sns_topic" "{{ident .Name}}" {
+  name = "{{.Name}}"
+}
+
+{{end}}{{range .Queues}}{{if .DeadLetterQueue}}resource "aws_sqs_queue" "{{ident .DeadLetterQueue}}" {
+  name = "{{.DeadLetterQueue}}"
+}
+
+{{end}}resource "aws_sqs_queue" "{{ident .Name}}" {
+  name = "{{.Name}}"{{if .DeadLetterQueue}}
+  redrive_policy = jsonencode({
+    deadLetterTargetArn = aws_sqs_queue.{{ident .DeadLetterQueue}}.arn
+    maxReceiveCount     = {{.MaxReceiveCount}}
+  }){{end}}
+}
+
+{{end}}{{range .Subscriptions}}{{$name := printf "%s_%s" (ident .Queue) (ident (arnName .TopicARN))}}resource "aws_sns_topic_subscription" "{{$name}}" {
+  topic_arn            = "{{.TopicARN}}"
+  protocol             = "sqs"
+  endpoint             = aws_sqs_queue.{{ident .Queue}}.arn
+  raw_message_delivery = {{.RawMessageDelivery}}
+}
+
+resource "aws_sqs_queue_policy" "{{$name}}" {
+  queue_url = aws_sqs_queue.{{ident .Queue}}.id
+  policy = jsonencode({
+    Version = "2012-10-17"
+    Statement = [{
+      Effect    = "Allow"
+      Principal = { Service = "sns.amazonaws.com" }
+      Action    = "sqs:SendMessage"
+      Resource  = aws_sqs_queue.{{ident .Queue}}.arn
+      Condition = { ArnEquals = { "aws:SourceArn" = "{{.TopicARN}}" } }
+    }]
+  })
+}
+
+{{end}}`))
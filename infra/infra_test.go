@@ -0,0 +1,93 @@
+package infra
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/NYTimes/gizmo/config"
+)
+
+func testManifest() *Manifest {
+	return NewManifest(
+		&config.SNS{Topic: "arn:aws:sns:us-east-1:123:cat-events"},
+		&config.SQS{
+			QueueName:           "cats",
+			DeadLetterQueueName: "cats-dlq",
+			SubscribedTopic:     "arn:aws:sns:us-east-1:456:animals",
+		},
+	)
+}
+
+func TestNewManifest(t *testing.T) {
+	m := testManifest()
+	if len(m.Topics) != 1 || m.Topics[0].Name != "cat-events" {
+		t.Errorf("expected the cat-events topic, got %+v", m.Topics)
+	}
+	if len(m.Queues) != 1 || m.Queues[0].MaxReceiveCount != DefaultMaxReceiveCount {
+		t.Errorf("expected the cats queue with the default max receive count, got %+v", m.Queues)
+	}
+	if len(m.Subscriptions) != 1 || !m.Subscriptions[0].RawMessageDelivery {
+		t.Errorf("expected a raw subscription to animals, got %+v", m.Subscriptions)
+	}
+
+	if m := NewManifest(nil, &config.SQS{QueueName: "cats"}); len(m.Queues) != 1 || len(m.Subscriptions) != 0 {
+		t.Errorf("expected only the cats queue, got %+v", m)
+	}
+}
+
+func TestTerraform(t *testing.T) {
+	var buf bytes.Buffer
+	if err := testManifest().Export(&buf, Terraform); err != nil {
+		t.Fatalf("expected no error, got %s", err)
+	}
+	for _, want := range []string{
+		`resource "aws_sns_topic" "cat_events" {`,
+		`resource "aws_sqs_queue" "cats_dlq" {`,
+		`deadLetterTargetArn = aws_sqs_queue.cats_dlq.arn`,
+		`resource "aws_sns_topic_subscription" "cats_animals" {`,
+		`topic_arn            = "arn:aws:sns:us-east-1:456:animals"`,
+		`resource "aws_sqs_queue_policy" "cats_animals" {`,
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("expected terraform to contain %q, got:\n%s", want, buf.String())
+		}
+	}
+}
+
+func TestCloudFormation(t *testing.T) {
+	var buf bytes.Buffer
+	if err := testManifest().Export(&buf, CloudFormation); err != nil {
+		t.Fatalf("expected no error, got %s", err)
+	}
+	var tmpl struct {
+		Resources map[string]struct {
+			Type string
+		}
+	}
+	if err := json.Unmarshal(buf.Bytes(), &tmpl); err != nil {
+		t.Fatalf("expected a JSON template, got %s", err)
+	}
+	want := map[string]string{
+		"CatEventsTopic":          "AWS::SNS::Topic",
+		"CatsQueue":               "AWS::SQS::Queue",
+		"CatsDlqQueue":            "AWS::SQS::Queue",
+		"CatsAnimalsSubscription": "AWS::SNS::Subscription",
+		"CatsAnimalsQueuePolicy":  "AWS::SQS::QueuePolicy",
+	}
+	if len(tmpl.Resources) != len(want) {
+		t.Errorf("expected %d resources, got %d", len(want), len(tmpl.Resources))
+	}
+	for id, typ := range want {
+		if got := tmpl.Resources[id].Type; got != typ {
+			t.Errorf("expected %s to be a %s, got %q", id, typ, got)
+		}
+	}
+}
+
+func TestExportUnknownFormat(t *testing.T) {
+	if err := testManifest().Export(&bytes.Buffer{}, "pulumi"); err == nil {
+		t.Error("expected an error for an unknown format")
+	}
+}
@@ -0,0 +1,37 @@
+/*
+Package jobs is a delayed job queue on top of any pubsub backend. Jobs are
+enqueued with a type, a JSON payload and an optional time to run at:
+
+	client := jobs.NewClient(pub)
+	id, err := client.Enqueue("send-email", email, jobs.EnqueueOptions{
+		Delay: 10 * time.Minute,
+	})
+
+A Worker receives jobs from a Subscriber and runs them with the Handler
+registered for their type, with a limit on how many jobs of each type run at
+once. Failed jobs are retried with a randomized exponential backoff until they
+run out of attempts and are sent to the optional DeadLetter Publisher:
+
+	worker := jobs.NewWorker(sub, pub)
+	err := worker.Register("send-email", sendEmail, jobs.HandlerOptions{
+		Concurrency: 10,
+		Retry:       jobs.RetryPolicy{MaxAttempts: 3},
+		Timeout:     time.Minute,
+	})
+	go worker.Run()
+	defer worker.Stop()
+
+	func sendEmail(ctx context.Context, j *jobs.Job) error {
+		var email Email
+		if err := j.Decode(&email); err != nil {
+			return err
+		}
+		...
+	}
+
+Delayed and retried jobs are published again with the Worker's Publisher, so it
+must publish to the queue the Subscriber reads from. Jobs that are not due yet
+are held for up to MaxHold before being published again, which should be shorter
+than the Subscriber's redelivery timeout.
+*/
+package jobs
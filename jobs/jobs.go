@@ -0,0 +1,99 @@
+package jobs
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/nu7hatch/gouuid"
+
+	"github.com/NYTimes/gizmo/pubsub"
+)
+
+// Log is the structured logger used throughout the package.
+var Log = logrus.New()
+
+// Job is a unit of background work. It is published as JSON with its
+// Type as the message key.
+type Job struct {
+	// ID is a unique ID assigned when the job is enqueued.
+	ID string `json:"id"`
+	// Type is used to pick the Handler the job will be run by.
+	Type string `json:"type"`
+	// Payload is the JSON encoded input of the job.
+	Payload json.RawMessage `json:"payload,omitempty"`
+	// Attempt is the number of times the job has been run and failed.
+	Attempt int `json:"attempt"`
+	// MaxAttempts will override the Handler's RetryPolicy if set.
+	MaxAttempts int `json:"max_attempts,omitempty"`
+	// RunAt is the earliest time the job should be run.
+	RunAt time.Time `json:"run_at"`
+	// EnqueuedAt is when the job was first enqueued.
+	EnqueuedAt time.Time `json:"enqueued_at"`
+	// LastError is the error from the most recent failed attempt.
+	LastError string `json:"last_error,omitempty"`
+}
+
+// Decode will unmarshal the job's Payload into v.
+func (j *Job) Decode(v interface{}) error {
+	return json.Unmarshal(j.Payload, v)
+}
+
+// EnqueueOptions are the optional settings of an enqueued job.
+type EnqueueOptions struct {
+	// RunAt is the earliest time the job should be run.
+	RunAt time.Time
+	// Delay will set the RunAt relative to now if RunAt is not set.
+	Delay time.Duration
+	// MaxAttempts will override the Handler's RetryPolicy if set.
+	MaxAttempts int
+}
+
+// Client will enqueue jobs over any pubsub.Publisher.
+type Client struct {
+	pub pubsub.Publisher
+}
+
+// NewClient will return a Client that publishes jobs with the Publisher.
+func NewClient(pub pubsub.Publisher) *Client {
+	return &Client{pub: pub}
+}
+
+// Enqueue will publish a job of the given type with the JSON encoded
+// payload and return its ID.
+func (c *Client) Enqueue(jobType string, payload interface{}, opts EnqueueOptions) (string, error) {
+	if jobType == "" {
+		return "", errors.New("job type is required")
+	}
+	b, err := json.Marshal(payload)
+	if err != nil {
+		return "", err
+	}
+	id, err := uuid.NewV4()
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	j := &Job{
+		ID:          id.String(),
+		Type:        jobType,
+		Payload:     b,
+		MaxAttempts: opts.MaxAttempts,
+		RunAt:       opts.RunAt,
+		EnqueuedAt:  now,
+	}
+	if j.RunAt.IsZero() {
+		j.RunAt = now.Add(opts.Delay)
+	}
+	return j.ID, c.publish(j)
+}
+
+func (c *Client) publish(j *Job) error {
+	b, err := json.Marshal(j)
+	if err != nil {
+		return err
+	}
+	return c.pub.PublishRaw(j.Type, b)
+}
@@ -0,0 +1,323 @@
+package jobs
+
+import (
+	"encoding/json"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"golang.org/x/net/context"
+
+	"github.com/NYTimes/gizmo/pubsub"
+	"github.com/NYTimes/gizmo/pubsub/pubsubtest"
+)
+
+// testQueue is an in-memory queue that delivers everything
+// published to it and redelivers messages that are not done.
+type testQueue struct {
+	msgs chan pubsub.SubscriberMessage
+
+	mu        sync.Mutex
+	published int
+	done      map[string]int
+	stopped   bool
+}
+
+func newTestQueue() *testQueue {
+	return &testQueue{msgs: make(chan pubsub.SubscriberMessage, 100), done: map[string]int{}}
+}
+
+func (q *testQueue) Publish(key string, msg proto.Message) error {
+	b, err := proto.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	return q.PublishRaw(key, b)
+}
+
+func (q *testQueue) PublishRaw(key string, msg []byte) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.published++
+	if !q.stopped {
+		q.msgs <- &testQueueMsg{q: q, body: msg}
+	}
+	return nil
+}
+
+func (q *testQueue) Start() <-chan pubsub.SubscriberMessage { return q.msgs }
+
+func (q *testQueue) Stop() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if !q.stopped {
+		q.stopped = true
+		close(q.msgs)
+	}
+	return nil
+}
+
+func (q *testQueue) Err() error { return nil }
+
+type testQueueMsg struct {
+	q    *testQueue
+	body []byte
+}
+
+func (m *testQueueMsg) Message() []byte { return m.body }
+
+func (m *testQueueMsg) ExtendDoneDeadline(time.Duration) error { return nil }
+
+func (m *testQueueMsg) Done() error {
+	var j Job
+	json.Unmarshal(m.body, &j)
+	m.q.mu.Lock()
+	m.q.done[j.ID]++
+	m.q.mu.Unlock()
+	return nil
+}
+
+func TestEnqueue(t *testing.T) {
+	runAt := time.Now().Add(time.Hour)
+	tests := []struct {
+		given       EnqueueOptions
+		wantRunAt   func(enqueuedAt time.Time) time.Time
+		wantMaxAtts int
+	}{
+		{
+			EnqueueOptions{},
+			func(e time.Time) time.Time { return e },
+			0,
+		},
+		{
+			EnqueueOptions{Delay: time.Minute, MaxAttempts: 2},
+			func(e time.Time) time.Time { return e.Add(time.Minute) },
+			2,
+		},
+		{
+			EnqueueOptions{RunAt: runAt, Delay: time.Minute},
+			func(time.Time) time.Time { return runAt },
+			0,
+		},
+	}
+
+	for _, test := range tests {
+		pub := &pubsubtest.TestPublisher{}
+		id, err := NewClient(pub).Enqueue("test", map[string]string{"hello": "world"}, test.given)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if len(pub.Published) != 1 {
+			t.Fatalf("expected 1 published message, got %d", len(pub.Published))
+		}
+		if got := pub.Published[0].Key; got != "test" {
+			t.Errorf("expected key 'test', got %q", got)
+		}
+
+		var j Job
+		if err := json.Unmarshal(pub.Published[0].Body, &j); err != nil {
+			t.Fatalf("unable to decode job: %s", err)
+		}
+		if j.ID != id {
+			t.Errorf("expected ID %q, got %q", id, j.ID)
+		}
+		if want := test.wantRunAt(j.EnqueuedAt); !j.RunAt.Equal(want) {
+			t.Errorf("expected run at %s, got %s", want, j.RunAt)
+		}
+		if j.MaxAttempts != test.wantMaxAtts {
+			t.Errorf("expected max attempts %d, got %d", test.wantMaxAtts, j.MaxAttempts)
+		}
+		var payload map[string]string
+		if err := j.Decode(&payload); err != nil {
+			t.Fatalf("unable to decode payload: %s", err)
+		}
+		if payload["hello"] != "world" {
+			t.Errorf("expected payload with hello=world, got %#v", payload)
+		}
+	}
+}
+
+func TestEnqueueErrors(t *testing.T) {
+	wantErr := errors.New("nope")
+	if _, err := NewClient(&pubsubtest.TestPublisher{GivenError: wantErr}).Enqueue("test", nil, EnqueueOptions{}); err != wantErr {
+		t.Errorf("expected publish error, got %v", err)
+	}
+	if _, err := NewClient(&pubsubtest.TestPublisher{}).Enqueue("", nil, EnqueueOptions{}); err == nil {
+		t.Error("expected error for missing job type, got nil")
+	}
+}
+
+func TestWorker(t *testing.T) {
+	tests := []struct {
+		name        string
+		failures    int32
+		panics      bool
+		maxAttempts int
+		delay       time.Duration
+
+		wantRuns       int32
+		wantDeadLetter bool
+	}{
+		{name: "success", wantRuns: 1},
+		{name: "retry", failures: 2, wantRuns: 3},
+		{name: "panic retry", failures: 1, panics: true, wantRuns: 2},
+		{name: "dead letter", failures: 5, maxAttempts: 2, wantRuns: 2, wantDeadLetter: true},
+		{name: "delayed", delay: 30 * time.Millisecond, wantRuns: 1},
+	}
+
+	for _, test := range tests {
+		q := newTestQueue()
+		dead := &pubsubtest.TestPublisher{}
+		w := NewWorker(q, q)
+		w.MaxHold = 10 * time.Millisecond
+		w.DeadLetter = dead
+
+		var (
+			runs    int32
+			ranAt   time.Time
+			mu      sync.Mutex
+			stopped = make(chan struct{})
+		)
+		err := w.Register("test", func(ctx context.Context, j *Job) error {
+			mu.Lock()
+			ranAt = time.Now()
+			mu.Unlock()
+			n := atomic.AddInt32(&runs, 1)
+			if n <= test.failures {
+				if test.panics {
+					panic("boom")
+				}
+				return errors.New("failed")
+			}
+			return nil
+		}, HandlerOptions{Retry: RetryPolicy{BaseDelay: time.Millisecond, MaxDelay: 2 * time.Millisecond}})
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %s", test.name, err)
+		}
+		go func() {
+			w.Run()
+			close(stopped)
+		}()
+
+		enqueuedAt := time.Now()
+		id, err := NewClient(q).Enqueue("test", nil, EnqueueOptions{Delay: test.delay, MaxAttempts: test.maxAttempts})
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %s", test.name, err)
+		}
+
+		deadline := time.Now().Add(2 * time.Second)
+		for time.Now().Before(deadline) {
+			q.mu.Lock()
+			// every published message has been marked done
+			finished := q.published > 0 && q.done[id] == q.published
+			q.mu.Unlock()
+			if finished && atomic.LoadInt32(&runs) >= test.wantRuns {
+				break
+			}
+			time.Sleep(time.Millisecond)
+		}
+		if err := w.Stop(); err != nil {
+			t.Errorf("%s: unexpected error on stop: %s", test.name, err)
+		}
+		<-stopped
+
+		if got := atomic.LoadInt32(&runs); got != test.wantRuns {
+			t.Errorf("%s: expected %d runs, got %d", test.name, test.wantRuns, got)
+		}
+		if got := len(dead.Published) == 1; got != test.wantDeadLetter {
+			t.Errorf("%s: expected dead letter %t, got %d dead letters", test.name, test.wantDeadLetter, len(dead.Published))
+		}
+		if test.wantDeadLetter && len(dead.Published) == 1 {
+			var j Job
+			json.Unmarshal(dead.Published[0].Body, &j)
+			if j.Attempt != test.maxAttempts || j.LastError != "failed" {
+				t.Errorf("%s: expected dead letter with %d attempts and last error 'failed', got %d and %q",
+					test.name, test.maxAttempts, j.Attempt, j.LastError)
+			}
+		}
+		mu.Lock()
+		if ran := ranAt.Sub(enqueuedAt); ran < test.delay {
+			t.Errorf("%s: expected job to run after %s, ran after %s", test.name, test.delay, ran)
+		}
+		mu.Unlock()
+	}
+}
+
+func TestWorkerConcurrency(t *testing.T) {
+	q := newTestQueue()
+	w := NewWorker(q, q)
+
+	var (
+		running, max int32
+		wg           sync.WaitGroup
+	)
+	wg.Add(6)
+	w.Register("limited", func(ctx context.Context, j *Job) error {
+		defer wg.Done()
+		n := atomic.AddInt32(&running, 1)
+		for {
+			m := atomic.LoadInt32(&max)
+			if n <= m || atomic.CompareAndSwapInt32(&max, m, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		atomic.AddInt32(&running, -1)
+		return nil
+	}, HandlerOptions{Concurrency: 2})
+	go w.Run()
+
+	c := NewClient(q)
+	for i := 0; i < 6; i++ {
+		if _, err := c.Enqueue("limited", i, EnqueueOptions{}); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+	wg.Wait()
+	w.Stop()
+
+	if got := atomic.LoadInt32(&max); got != 2 {
+		t.Errorf("expected at most 2 concurrent jobs, got %d", got)
+	}
+}
+
+func TestWorkerRegister(t *testing.T) {
+	w := NewWorker(newTestQueue(), &pubsubtest.TestPublisher{})
+	h := func(context.Context, *Job) error { return nil }
+	if err := w.Register("test", h, HandlerOptions{}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := w.Register("test", h, HandlerOptions{}); err == nil {
+		t.Error("expected error registering a type twice, got nil")
+	}
+
+	got := w.handlers["test"].opts
+	if got.Concurrency != 1 || got.Retry.MaxAttempts != DefaultMaxAttempts ||
+		got.Retry.BaseDelay != DefaultRetryBaseDelay || got.Retry.MaxDelay != DefaultRetryMaxDelay {
+		t.Errorf("expected default options, got %#v", got)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		attempt  int
+		min, max time.Duration
+	}{
+		{1, 500 * time.Millisecond, time.Second},
+		{2, time.Second, 2 * time.Second},
+		{4, 4 * time.Second, 8 * time.Second},
+		{20, 5 * time.Second, 10 * time.Second},
+	}
+
+	for _, test := range tests {
+		for i := 0; i < 10; i++ {
+			got := retryDelay(time.Second, 10*time.Second, test.attempt)
+			if got < test.min || got > test.max {
+				t.Errorf("attempt %d: expected backoff between %s and %s, got %s", test.attempt, test.min, test.max, got)
+			}
+		}
+	}
+}
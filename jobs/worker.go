@@ -0,0 +1,318 @@
+package jobs
+
+import (
+	"encoding/json"
+	"fmt"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"golang.org/x/net/context"
+
+	"github.com/NYTimes/gizmo/backoff"
+	"github.com/NYTimes/gizmo/pubsub"
+)
+
+var (
+	// DefaultMaxAttempts is how many times a job will be run before
+	// giving up if no MaxAttempts is given.
+	DefaultMaxAttempts = 5
+	// DefaultRetryBaseDelay is the initial retry backoff used if none is given.
+	DefaultRetryBaseDelay = time.Second
+	// DefaultRetryMaxDelay is the retry backoff cap used if none is given.
+	DefaultRetryMaxDelay = 5 * time.Minute
+	// DefaultMaxHold is the longest a Worker will hold a job that is not
+	// due yet before publishing it again if no MaxHold is given.
+	DefaultMaxHold = 30 * time.Second
+	// DefaultMaxInFlight is the max number of messages a Worker will hold
+	// at once if no MaxInFlight is given.
+	DefaultMaxInFlight = 100
+)
+
+// Handler is a func for running a Job. If it returns an error, the job will
+// be retried according to its RetryPolicy.
+type Handler func(context.Context, *Job) error
+
+// RetryPolicy is how failed jobs of a type are retried.
+type RetryPolicy struct {
+	// MaxAttempts is how many times a job will be run before giving up.
+	// Defaults to DefaultMaxAttempts.
+	MaxAttempts int
+	// BaseDelay is the initial backoff between attempts. Each attempt
+	// will double it. Defaults to DefaultRetryBaseDelay.
+	BaseDelay time.Duration
+	// MaxDelay is the cap on the backoff between attempts.
+	// Defaults to DefaultRetryMaxDelay.
+	MaxDelay time.Duration
+}
+
+// HandlerOptions are the optional settings of a registered Handler.
+type HandlerOptions struct {
+	// Concurrency is the max number of jobs of the type that will be
+	// run at once. Defaults to 1.
+	Concurrency int
+	// Retry is how failed jobs will be retried.
+	Retry RetryPolicy
+	// Timeout will cancel the job's context if it takes longer.
+	Timeout time.Duration
+}
+
+type registeredHandler struct {
+	handler Handler
+	opts    HandlerOptions
+	sem     chan struct{}
+}
+
+// Worker will run jobs from a pubsub.Subscriber with the Handler registered
+// for their type. Jobs that are delayed or need to be retried are published
+// again with the Publisher, which should publish to the Subscriber's queue.
+type Worker struct {
+	// MaxInFlight is the max number of messages that will be held at once
+	// across all job types. Defaults to DefaultMaxInFlight.
+	MaxInFlight int
+	// MaxHold is the longest a job that is not due yet will be held before
+	// publishing it again. It should be shorter than the Subscriber's
+	// redelivery timeout. Defaults to DefaultMaxHold.
+	MaxHold time.Duration
+	// DeadLetter is an optional Publisher that jobs will be published to
+	// once they have run out of attempts.
+	DeadLetter pubsub.Publisher
+
+	sub    pubsub.Subscriber
+	client *Client
+
+	mu       sync.RWMutex
+	handlers map[string]*registeredHandler
+
+	stop     chan struct{}
+	stopOnce sync.Once
+	done     chan error
+}
+
+// NewWorker will return a Worker that receives jobs from
+// the Subscriber and publishes them again with the Publisher.
+func NewWorker(sub pubsub.Subscriber, pub pubsub.Publisher) *Worker {
+	return &Worker{
+		MaxInFlight: DefaultMaxInFlight,
+		MaxHold:     DefaultMaxHold,
+		sub:         sub,
+		client:      NewClient(pub),
+		handlers:    map[string]*registeredHandler{},
+		stop:        make(chan struct{}),
+		done:        make(chan error, 1),
+	}
+}
+
+// Register will add the Handler for jobs of the given type.
+// Handlers must be registered before Run is called.
+func (w *Worker) Register(jobType string, h Handler, opts HandlerOptions) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if _, ok := w.handlers[jobType]; ok {
+		return fmt.Errorf("a handler for job type %q is already registered", jobType)
+	}
+	if opts.Concurrency < 1 {
+		opts.Concurrency = 1
+	}
+	if opts.Retry.MaxAttempts < 1 {
+		opts.Retry.MaxAttempts = DefaultMaxAttempts
+	}
+	if opts.Retry.BaseDelay == 0 {
+		opts.Retry.BaseDelay = DefaultRetryBaseDelay
+	}
+	if opts.Retry.MaxDelay == 0 {
+		opts.Retry.MaxDelay = DefaultRetryMaxDelay
+	}
+	w.handlers[jobType] = &registeredHandler{
+		handler: h,
+		opts:    opts,
+		sem:     make(chan struct{}, opts.Concurrency),
+	}
+	return nil
+}
+
+// Run will start the Subscriber and run its jobs until Stop is called or
+// the Subscriber's channel is closed. It will block until all in-flight
+// jobs have finished and return the Subscriber's error, if any.
+func (w *Worker) Run() error {
+	err := w.run()
+	w.done <- err
+	return err
+}
+
+func (w *Worker) run() error {
+	max := w.MaxInFlight
+	if max < 1 {
+		max = DefaultMaxInFlight
+	}
+	var (
+		wg       sync.WaitGroup
+		inFlight = make(chan struct{}, max)
+		msgs     = w.sub.Start()
+	)
+	for {
+		select {
+		case <-w.stop:
+			return w.shutdown(msgs, &wg)
+		case msg, ok := <-msgs:
+			if !ok {
+				wg.Wait()
+				return w.sub.Err()
+			}
+			inFlight <- struct{}{}
+			wg.Add(1)
+			go func(msg pubsub.SubscriberMessage) {
+				defer func() {
+					<-inFlight
+					wg.Done()
+				}()
+				w.process(msg)
+			}(msg)
+		}
+	}
+}
+
+// shutdown will stop the Subscriber and wait for any in-flight jobs to
+// complete. Any messages received while stopping will be left for
+// redelivery.
+func (w *Worker) shutdown(msgs <-chan pubsub.SubscriberMessage, wg *sync.WaitGroup) error {
+	stopped := make(chan error, 1)
+	go func() {
+		stopped <- w.sub.Stop()
+	}()
+	for {
+		select {
+		case _, ok := <-msgs:
+			if !ok {
+				msgs = nil
+			}
+		case err := <-stopped:
+			wg.Wait()
+			return err
+		}
+	}
+}
+
+// Stop will stop the Subscriber and block until the Worker has finished
+// running any in-flight jobs. Jobs waiting to be due or for a turn will be
+// left for redelivery. Run must have been called before calling Stop.
+func (w *Worker) Stop() error {
+	w.stopOnce.Do(func() {
+		close(w.stop)
+	})
+	err := <-w.done
+	// let any other callers see the result
+	w.done <- err
+	return err
+}
+
+func (w *Worker) process(msg pubsub.SubscriberMessage) {
+	var j Job
+	if err := json.Unmarshal(msg.Message(), &j); err != nil || j.Type == "" {
+		// it will never decode, so don't let it be redelivered
+		Log.Errorf("unable to decode job, dropping it: %v", err)
+		done(msg)
+		return
+	}
+	log := Log.WithFields(logrus.Fields{"job_type": j.Type, "job_id": j.ID})
+
+	w.mu.RLock()
+	h, ok := w.handlers[j.Type]
+	w.mu.RUnlock()
+	if !ok {
+		// another worker on the queue may be able to run it
+		log.Warn("no handler registered for job type")
+		return
+	}
+
+	if wait := j.RunAt.Sub(time.Now()); wait > 0 {
+		hold := w.MaxHold
+		if hold <= 0 {
+			hold = DefaultMaxHold
+		}
+		if wait > hold {
+			wait = hold
+		}
+		select {
+		case <-w.stop:
+			return
+		case <-time.After(wait):
+		}
+		if j.RunAt.After(time.Now()) {
+			w.requeue(msg, &j, log)
+			return
+		}
+	}
+
+	select {
+	case <-w.stop:
+		return
+	case h.sem <- struct{}{}:
+	}
+	err := h.run(&j)
+	<-h.sem
+	if err == nil {
+		done(msg)
+		return
+	}
+
+	j.Attempt++
+	j.LastError = err.Error()
+	max := j.MaxAttempts
+	if max < 1 {
+		max = h.opts.Retry.MaxAttempts
+	}
+	if j.Attempt >= max {
+		log.Errorf("job failed after %d attempts, giving up: %s", j.Attempt, err)
+		if w.DeadLetter != nil {
+			if err := NewClient(w.DeadLetter).publish(&j); err != nil {
+				log.Error("unable to publish job to dead letter queue: ", err)
+				return
+			}
+		}
+		done(msg)
+		return
+	}
+	j.RunAt = time.Now().Add(retryDelay(h.opts.Retry.BaseDelay, h.opts.Retry.MaxDelay, j.Attempt))
+	log.Warnf("job attempt %d failed, retrying at %s: %s", j.Attempt, j.RunAt, err)
+	w.requeue(msg, &j, log)
+}
+
+// requeue will publish the job again and mark the original message as done.
+func (w *Worker) requeue(msg pubsub.SubscriberMessage, j *Job, log *logrus.Entry) {
+	if err := w.client.publish(j); err != nil {
+		// leave the original to be redelivered
+		log.Error("unable to requeue job: ", err)
+		return
+	}
+	done(msg)
+}
+
+func (h *registeredHandler) run(j *Job) (err error) {
+	defer func() {
+		if x := recover(); x != nil {
+			err = fmt.Errorf("panic: %v", x)
+			Log.Errorf("job handler panic: %v\n%s", x, debug.Stack())
+		}
+	}()
+	ctx := context.Background()
+	if h.opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, h.opts.Timeout)
+		defer cancel()
+	}
+	return h.handler(ctx, j)
+}
+
+func done(msg pubsub.SubscriberMessage) {
+	if err := msg.Done(); err != nil {
+		Log.Error("unable to mark job message as done: ", err)
+	}
+}
+
+// retryDelay returns a randomized exponential backoff duration for the
+// given attempt (starting at 1) between the base and max delay.
+func retryDelay(base, max time.Duration, attempt int) time.Duration {
+	return backoff.Exponential{Base: base, Max: max}.Delay(attempt)
+}
@@ -0,0 +1,150 @@
+package kubernetes
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+)
+
+// Log is the structured logger used by the kubernetes package.
+var Log = logrus.New()
+
+// ServiceAccountPath is where the service account token, CA certificate and
+// namespace are mounted in every pod.
+var ServiceAccountPath = "/var/run/secrets/kubernetes.io/serviceaccount"
+
+var (
+	// ErrNotInCluster is returned by NewInClusterClient when the
+	// API server environment variables are not set.
+	ErrNotInCluster = errors.New("not running in a kubernetes cluster")
+	// ErrNotFound is returned when a resource does not exist.
+	ErrNotFound = errors.New("resource not found")
+	// ErrConflict is returned when a resource has been created or
+	// updated by someone else.
+	ErrConflict = errors.New("resource version conflict")
+)
+
+// Client is a minimal client for the Kubernetes API server.
+type Client struct {
+	// Host is the base URL of the API server.
+	Host string
+	// Token is the bearer token sent with every request.
+	Token string
+	// Namespace is the namespace the pod is running in.
+	Namespace string
+
+	HTTPClient *http.Client
+}
+
+// NewInClusterClient will return a Client for the API server of the cluster
+// the pod is running in, authenticated with the pod's service account.
+func NewInClusterClient() (*Client, error) {
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" || port == "" {
+		return nil, ErrNotInCluster
+	}
+	token, err := ioutil.ReadFile(filepath.Join(ServiceAccountPath, "token"))
+	if err != nil {
+		return nil, err
+	}
+	ca, err := ioutil.ReadFile(filepath.Join(ServiceAccountPath, "ca.crt"))
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(ca) {
+		return nil, errors.New("unable to parse service account CA certificate")
+	}
+	ns, err := ioutil.ReadFile(filepath.Join(ServiceAccountPath, "namespace"))
+	if err != nil {
+		return nil, err
+	}
+
+	return &Client{
+		Host:      "https://" + net.JoinHostPort(host, port),
+		Token:     strings.TrimSpace(string(token)),
+		Namespace: strings.TrimSpace(string(ns)),
+		HTTPClient: &http.Client{
+			Timeout: 10 * time.Second,
+			Transport: &http.Transport{
+				TLSClientConfig:     &tls.Config{RootCAs: pool},
+				TLSHandshakeTimeout: 10 * time.Second,
+			},
+		},
+	}, nil
+}
+
+// statusError is the body of a failed API request.
+type statusError struct {
+	Message string `json:"message"`
+	Reason  string `json:"reason"`
+	Code    int    `json:"code"`
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("kubernetes API error %d (%s): %s", e.Code, e.Reason, e.Message)
+}
+
+// do will send the request with in as its JSON body and decode the JSON
+// response into out.
+func (c *Client) do(method, path string, in, out interface{}) error {
+	var body io.Reader
+	if in != nil {
+		b, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(b)
+	}
+	req, err := http.NewRequest(method, c.Host+path, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+
+	client := c.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return ErrNotFound
+	case resp.StatusCode == http.StatusConflict:
+		return ErrConflict
+	case resp.StatusCode >= 300:
+		serr := &statusError{Code: resp.StatusCode}
+		if err := json.NewDecoder(resp.Body).Decode(serr); err != nil {
+			serr.Message = resp.Status
+		}
+		return serr
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
@@ -0,0 +1,37 @@
+/*
+Package kubernetes contains helpers for running gizmo services in Kubernetes.
+
+Metadata exposed by the downward API can be added to every log entry and
+metric:
+
+	md := kubernetes.LoadMetadataFromEnv()
+	if md != nil {
+		server.Log.Hooks.Add(md.Hook())
+		statsd, err = metrics.NewDogStatsD(addr, "my-service", md.Tags())
+	}
+
+A ReadinessCheck can be used as a server's 'custom' health check and will fail
+while any of its checks are failing, so a pod will not receive traffic while its
+pubsub consumers are down or failing to handle messages:
+
+	ready := kubernetes.NewReadinessCheck()
+	ready.AddConsumer("orders", consumer, 0.5)
+	cfg.HealthCheckType = "custom"
+	cfg.HealthCheckPath = "/ready"
+	cfg.CustomHealthCheckHandler = ready
+
+A LeaderElector will elect a single pod to run components that must only run
+once in a cluster, using a coordination.k8s.io Lease. The pod's service
+account needs permission to get, create and update leases:
+
+	client, err := kubernetes.NewInClusterClient()
+	elector, err := kubernetes.NewLeaderElector(client, "my-cron", "")
+	elector.OnStartedLeading = func(stop <-chan struct{}) {
+		c.Start()
+		<-stop
+		c.Stop()
+	}
+	go elector.Run()
+	defer elector.Stop()
+*/
+package kubernetes
@@ -0,0 +1,292 @@
+package kubernetes
+
+import (
+	"errors"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	// DefaultLeaseDuration is how long a LeaderElector's lease will be
+	// valid for after each renewal if no LeaseDuration is given.
+	DefaultLeaseDuration = 15 * time.Second
+	// DefaultRenewDeadline is how long a leading LeaderElector will keep
+	// retrying to renew its lease before giving up leadership if no
+	// RenewDeadline is given.
+	DefaultRenewDeadline = 10 * time.Second
+	// DefaultRetryPeriod is how often a LeaderElector will try to acquire
+	// or renew its lease if no RetryPeriod is given.
+	DefaultRetryPeriod = 2 * time.Second
+)
+
+// microTimeFormat is the format of the MicroTime fields of a Lease.
+const microTimeFormat = "2006-01-02T15:04:05.000000Z07:00"
+
+type lease struct {
+	APIVersion string    `json:"apiVersion"`
+	Kind       string    `json:"kind"`
+	Metadata   leaseMeta `json:"metadata"`
+	Spec       leaseSpec `json:"spec"`
+}
+
+type leaseMeta struct {
+	Name            string `json:"name"`
+	Namespace       string `json:"namespace,omitempty"`
+	ResourceVersion string `json:"resourceVersion,omitempty"`
+}
+
+type leaseSpec struct {
+	HolderIdentity       string `json:"holderIdentity,omitempty"`
+	LeaseDurationSeconds int    `json:"leaseDurationSeconds,omitempty"`
+	AcquireTime          string `json:"acquireTime,omitempty"`
+	RenewTime            string `json:"renewTime,omitempty"`
+	LeaseTransitions     int    `json:"leaseTransitions,omitempty"`
+}
+
+// LeaderElector uses a coordination.k8s.io Lease to elect a single leader
+// among all the pods running it, for components that must only run once in
+// a cluster. Expiry of another holder's lease is judged by when this
+// LeaderElector last saw the lease change rather than the renew time written
+// by the holder, so it does not depend on the clocks of the pods agreeing.
+type LeaderElector struct {
+	// LeaseDuration is how long non-leaders will wait after the lease
+	// was last renewed before taking it over. Defaults to
+	// DefaultLeaseDuration.
+	LeaseDuration time.Duration
+	// RenewDeadline is how long the leader will keep retrying to renew
+	// the lease before giving up leadership. It must be shorter than the
+	// LeaseDuration. Defaults to DefaultRenewDeadline.
+	RenewDeadline time.Duration
+	// RetryPeriod is how often the lease will be acquired or renewed.
+	// Defaults to DefaultRetryPeriod.
+	RetryPeriod time.Duration
+
+	// OnStartedLeading is an optional hook that will be called in its own
+	// goroutine once leadership has been acquired. The given channel will
+	// be closed once leadership is lost or the LeaderElector is stopped.
+	OnStartedLeading func(stop <-chan struct{})
+	// OnStoppedLeading is an optional hook that will be called once
+	// leadership is lost or given up on Stop.
+	OnStoppedLeading func()
+
+	client   *Client
+	name     string
+	identity string
+
+	leader int32
+
+	// last observed lease version and when it was observed
+	observedRV   string
+	observedTime time.Time
+
+	stop     chan struct{}
+	stopOnce sync.Once
+	done     chan struct{}
+}
+
+// NewLeaderElector will return a LeaderElector for the Lease with the given
+// name in the Client's namespace. The identity must be unique to each pod
+// and will default to the hostname, which is the pod name, if empty.
+func NewLeaderElector(c *Client, name, identity string) (*LeaderElector, error) {
+	if name == "" {
+		return nil, errors.New("lease name is required")
+	}
+	if c.Namespace == "" {
+		return nil, errors.New("client namespace is required")
+	}
+	if identity == "" {
+		var err error
+		if identity, err = os.Hostname(); err != nil {
+			return nil, err
+		}
+	}
+	return &LeaderElector{
+		client:   c,
+		name:     name,
+		identity: identity,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}, nil
+}
+
+// IsLeader will return true while this LeaderElector holds the lease.
+func (l *LeaderElector) IsLeader() bool {
+	return atomic.LoadInt32(&l.leader) == 1
+}
+
+// Run will try to acquire the lease and keep renewing it while leading until
+// Stop is called. If the lease cannot be renewed, leadership will be given up
+// and Run will go back to trying to acquire it.
+func (l *LeaderElector) Run() {
+	defer close(l.done)
+	if l.LeaseDuration == 0 {
+		l.LeaseDuration = DefaultLeaseDuration
+	}
+	if l.RenewDeadline == 0 {
+		l.RenewDeadline = DefaultRenewDeadline
+	}
+	if l.RetryPeriod == 0 {
+		l.RetryPeriod = DefaultRetryPeriod
+	}
+
+	for {
+		if !l.acquire() {
+			return
+		}
+		if stopped := l.lead(); stopped {
+			return
+		}
+	}
+}
+
+// acquire will block until the lease has been acquired. If Stop is called
+// first, false will be returned.
+func (l *LeaderElector) acquire() bool {
+	for !l.tryAcquireOrRenew() {
+		select {
+		case <-l.stop:
+			return false
+		case <-time.After(l.RetryPeriod):
+		}
+	}
+	return true
+}
+
+// lead will keep renewing the lease until it can not be renewed within the
+// RenewDeadline or Stop is called, in which case true will be returned.
+func (l *LeaderElector) lead() (stopped bool) {
+	Log.Infof("acquired lease %s/%s as %s", l.client.Namespace, l.name, l.identity)
+	atomic.StoreInt32(&l.leader, 1)
+	leading := make(chan struct{})
+	if l.OnStartedLeading != nil {
+		go l.OnStartedLeading(leading)
+	}
+	defer func() {
+		atomic.StoreInt32(&l.leader, 0)
+		close(leading)
+		if l.OnStoppedLeading != nil {
+			l.OnStoppedLeading()
+		}
+	}()
+
+	renewed := time.Now()
+	for {
+		select {
+		case <-l.stop:
+			l.release()
+			return true
+		case <-time.After(l.RetryPeriod):
+		}
+		if l.tryAcquireOrRenew() {
+			renewed = time.Now()
+			continue
+		}
+		if time.Since(renewed) > l.RenewDeadline {
+			Log.Warnf("unable to renew lease %s/%s within %s, giving up leadership", l.client.Namespace, l.name, l.RenewDeadline)
+			return false
+		}
+	}
+}
+
+func (l *LeaderElector) path() string {
+	return "/apis/coordination.k8s.io/v1/namespaces/" + l.client.Namespace + "/leases"
+}
+
+// tryAcquireOrRenew will attempt to create the lease, take it over if it has
+// expired or renew it if it is already held and return true if successful.
+func (l *LeaderElector) tryAcquireOrRenew() bool {
+	now := time.Now()
+	spec := leaseSpec{
+		HolderIdentity:       l.identity,
+		LeaseDurationSeconds: int((l.LeaseDuration + time.Second - 1) / time.Second),
+		AcquireTime:          now.UTC().Format(microTimeFormat),
+		RenewTime:            now.UTC().Format(microTimeFormat),
+	}
+
+	var current lease
+	err := l.client.do("GET", l.path()+"/"+l.name, nil, &current)
+	if err == ErrNotFound {
+		created := lease{
+			APIVersion: "coordination.k8s.io/v1",
+			Kind:       "Lease",
+			Metadata:   leaseMeta{Name: l.name, Namespace: l.client.Namespace},
+			Spec:       spec,
+		}
+		if err := l.client.do("POST", l.path(), created, &current); err != nil {
+			Log.Debug("unable to create lease: ", err)
+			return false
+		}
+		l.observe(current, now)
+		return true
+	}
+	if err != nil {
+		Log.Warn("unable to get lease: ", err)
+		return false
+	}
+
+	if current.Metadata.ResourceVersion != l.observedRV {
+		l.observe(current, now)
+	}
+	holder := current.Spec.HolderIdentity
+	if holder != "" && holder != l.identity && now.Before(l.observedTime.Add(l.leaseDuration(current.Spec))) {
+		return false
+	}
+
+	if holder == l.identity {
+		spec.AcquireTime = current.Spec.AcquireTime
+		spec.LeaseTransitions = current.Spec.LeaseTransitions
+	} else {
+		spec.LeaseTransitions = current.Spec.LeaseTransitions + 1
+	}
+	current.Spec = spec
+	if err := l.client.do("PUT", l.path()+"/"+l.name, current, &current); err != nil {
+		if err != ErrConflict {
+			Log.Warn("unable to update lease: ", err)
+		}
+		return false
+	}
+	l.observe(current, now)
+	return true
+}
+
+func (l *LeaderElector) observe(current lease, now time.Time) {
+	l.observedRV = current.Metadata.ResourceVersion
+	l.observedTime = now
+}
+
+func (l *LeaderElector) leaseDuration(spec leaseSpec) time.Duration {
+	if spec.LeaseDurationSeconds > 0 {
+		return time.Duration(spec.LeaseDurationSeconds) * time.Second
+	}
+	return l.LeaseDuration
+}
+
+// release will give up the lease so another pod can acquire it
+// without waiting for it to expire.
+func (l *LeaderElector) release() {
+	var current lease
+	if err := l.client.do("GET", l.path()+"/"+l.name, nil, &current); err != nil {
+		Log.Warn("unable to get lease to release: ", err)
+		return
+	}
+	if current.Spec.HolderIdentity != l.identity {
+		return
+	}
+	current.Spec.HolderIdentity = ""
+	current.Spec.LeaseDurationSeconds = 1
+	current.Spec.RenewTime = time.Now().UTC().Format(microTimeFormat)
+	if err := l.client.do("PUT", l.path()+"/"+l.name, current, nil); err != nil {
+		Log.Warn("unable to release lease: ", err)
+	}
+}
+
+// Stop will give up leadership, if held, and block until Run has returned.
+// Run must have been called before calling Stop.
+func (l *LeaderElector) Stop() {
+	l.stopOnce.Do(func() {
+		close(l.stop)
+	})
+	<-l.done
+}
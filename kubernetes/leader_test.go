@@ -0,0 +1,204 @@
+package kubernetes
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testLeaseAPI is a fake API server holding a single Lease.
+type testLeaseAPI struct {
+	mu      sync.Mutex
+	lease   *lease
+	version int
+	// if set, all requests will fail
+	down bool
+}
+
+func (a *testLeaseAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.down {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	if !strings.HasPrefix(r.URL.Path, "/apis/coordination.k8s.io/v1/namespaces/test/leases") {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	switch r.Method {
+	case "GET":
+		if a.lease == nil {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(a.lease)
+	case "POST", "PUT":
+		var l lease
+		if err := json.NewDecoder(r.Body).Decode(&l); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if r.Method == "POST" && a.lease != nil ||
+			r.Method == "PUT" && (a.lease == nil || l.Metadata.ResourceVersion != a.lease.Metadata.ResourceVersion) {
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+		a.version++
+		l.Metadata.ResourceVersion = strconv.Itoa(a.version)
+		a.lease = &l
+		json.NewEncoder(w).Encode(a.lease)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (a *testLeaseAPI) holder() string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.lease == nil {
+		return ""
+	}
+	return a.lease.Spec.HolderIdentity
+}
+
+func (a *testLeaseAPI) setDown(down bool) {
+	a.mu.Lock()
+	a.down = down
+	a.mu.Unlock()
+}
+
+func newTestElector(t *testing.T, host, identity string) *LeaderElector {
+	l, err := NewLeaderElector(&Client{Host: host, Namespace: "test"}, "singleton", identity)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.LeaseDuration = time.Second
+	l.RenewDeadline = 100 * time.Millisecond
+	l.RetryPeriod = 10 * time.Millisecond
+	return l
+}
+
+func waitFor(cond func() bool) bool {
+	deadline := time.Now().Add(3 * time.Second)
+	for time.Now().Before(deadline) {
+		if cond() {
+			return true
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	return false
+}
+
+func TestLeaderElector(t *testing.T) {
+	api := &testLeaseAPI{}
+	srv := httptest.NewServer(api)
+	defer srv.Close()
+
+	var started, stopped int32
+	a := newTestElector(t, srv.URL, "a")
+	a.OnStartedLeading = func(stop <-chan struct{}) {
+		atomic.AddInt32(&started, 1)
+		<-stop
+		atomic.AddInt32(&stopped, 1)
+	}
+	b := newTestElector(t, srv.URL, "b")
+
+	go a.Run()
+	if !waitFor(a.IsLeader) {
+		t.Fatal("expected a to acquire the lease")
+	}
+	go b.Run()
+	// give b a few chances to steal the lease
+	time.Sleep(50 * time.Millisecond)
+	if b.IsLeader() {
+		t.Error("expected b to not be leader while a holds the lease")
+	}
+	if got := api.holder(); got != "a" {
+		t.Errorf("expected lease holder 'a', got %q", got)
+	}
+
+	a.Stop()
+	if a.IsLeader() {
+		t.Error("expected a to not be leader after Stop")
+	}
+	// the released lease should be taken over without waiting for it to expire
+	if !waitFor(b.IsLeader) {
+		t.Fatal("expected b to acquire the released lease")
+	}
+	b.Stop()
+
+	if !waitFor(func() bool { return atomic.LoadInt32(&stopped) == 1 }) {
+		t.Error("expected OnStartedLeading's stop channel to be closed")
+	}
+	if got := atomic.LoadInt32(&started); got != 1 {
+		t.Errorf("expected OnStartedLeading to be called once, got %d", got)
+	}
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	if got := api.lease.Spec.LeaseTransitions; got != 1 {
+		t.Errorf("expected 1 lease transition, got %d", got)
+	}
+}
+
+func TestLeaderElectorLosesLease(t *testing.T) {
+	api := &testLeaseAPI{}
+	srv := httptest.NewServer(api)
+	defer srv.Close()
+
+	lost := make(chan struct{})
+	var once sync.Once
+	l := newTestElector(t, srv.URL, "a")
+	l.OnStoppedLeading = func() { once.Do(func() { close(lost) }) }
+	go l.Run()
+	defer l.Stop()
+
+	if !waitFor(l.IsLeader) {
+		t.Fatal("expected the lease to be acquired")
+	}
+	api.setDown(true)
+	select {
+	case <-lost:
+	case <-time.After(time.Second):
+		t.Fatal("expected leadership to be lost after the renew deadline")
+	}
+	if l.IsLeader() {
+		t.Error("expected IsLeader to be false after losing the lease")
+	}
+
+	api.setDown(false)
+	if !waitFor(l.IsLeader) {
+		t.Error("expected the lease to be acquired again")
+	}
+}
+
+func TestLeaderElectorTakesOverExpiredLease(t *testing.T) {
+	api := &testLeaseAPI{
+		version: 1,
+		lease: &lease{
+			Metadata: leaseMeta{Name: "singleton", Namespace: "test", ResourceVersion: "1"},
+			Spec:     leaseSpec{HolderIdentity: "gone", LeaseDurationSeconds: 1},
+		},
+	}
+	srv := httptest.NewServer(api)
+	defer srv.Close()
+
+	l := newTestElector(t, srv.URL, "a")
+	start := time.Now()
+	go l.Run()
+	defer l.Stop()
+
+	if !waitFor(l.IsLeader) {
+		t.Fatal("expected the expired lease to be taken over")
+	}
+	if since := time.Since(start); since < time.Second {
+		t.Errorf("expected the lease to be taken over after it expired, took %s", since)
+	}
+}
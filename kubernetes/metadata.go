@@ -0,0 +1,182 @@
+package kubernetes
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Sirupsen/logrus"
+
+	"github.com/NYTimes/gizmo/config"
+)
+
+// DefaultPodInfoPath is where Metadata will look for the labels and
+// annotations files of a downward API volume if no PodInfoPath is given.
+var DefaultPodInfoPath = "/etc/podinfo"
+
+// Metadata holds information about the pod a service is running in that has
+// been exposed by the downward API. The pod fields are expected in environment
+// variables and the labels and annotations in the files of a downward API
+// volume mounted at PodInfoPath:
+//
+//	env:
+//	- name: POD_NAME
+//	  valueFrom:
+//	    fieldRef:
+//	      fieldPath: metadata.name
+//	volumes:
+//	- name: podinfo
+//	  downwardAPI:
+//	    items:
+//	    - path: labels
+//	      fieldRef:
+//	        fieldPath: metadata.labels
+type Metadata struct {
+	PodName        string `envconfig:"POD_NAME"`
+	Namespace      string `envconfig:"POD_NAMESPACE"`
+	PodIP          string `envconfig:"POD_IP"`
+	NodeName       string `envconfig:"NODE_NAME"`
+	ServiceAccount string `envconfig:"POD_SERVICE_ACCOUNT"`
+
+	PodInfoPath string `envconfig:"POD_INFO_PATH"`
+
+	Labels      map[string]string `envconfig:"-"`
+	Annotations map[string]string `envconfig:"-"`
+}
+
+// LoadMetadataFromEnv will attempt to load the pod Metadata from environment
+// variables and the downward API volume. If not running in a pod, nil will
+// be returned.
+func LoadMetadataFromEnv() *Metadata {
+	var m Metadata
+	config.LoadEnvConfig(&m)
+	if m.PodInfoPath == "" {
+		m.PodInfoPath = DefaultPodInfoPath
+	}
+	var err error
+	if m.Labels, err = readPodInfo(filepath.Join(m.PodInfoPath, "labels")); err != nil {
+		Log.Warn("unable to read pod labels: ", err)
+	}
+	if m.Annotations, err = readPodInfo(filepath.Join(m.PodInfoPath, "annotations")); err != nil {
+		Log.Warn("unable to read pod annotations: ", err)
+	}
+	if m.PodName == "" && len(m.Labels) == 0 {
+		return nil
+	}
+	return &m
+}
+
+// Fields will return the non-empty pod fields and all labels, prefixed with
+// "label.", to be added to log entries.
+func (m *Metadata) Fields() logrus.Fields {
+	fields := logrus.Fields{}
+	for k, v := range m.values() {
+		fields[k] = v
+	}
+	return fields
+}
+
+// Hook will return a logrus.Hook that adds the Fields to every log entry:
+//
+//	server.Log.Hooks.Add(md.Hook())
+func (m *Metadata) Hook() logrus.Hook {
+	return metadataHook{fields: m.Fields()}
+}
+
+// Tags will return the non-empty pod fields and all labels as sorted
+// 'key:value' tags for metrics.NewDogStatsD.
+func (m *Metadata) Tags() []string {
+	values := m.values()
+	tags := make([]string, 0, len(values))
+	for k, v := range values {
+		tags = append(tags, k+":"+v)
+	}
+	sort.Strings(tags)
+	return tags
+}
+
+// Dimensions will return the pod namespace and name, if set, to be used as
+// metrics.NewCloudWatchEMF dimensions. Labels are left out to avoid creating
+// a new set of metrics for every label.
+func (m *Metadata) Dimensions() map[string]string {
+	dims := map[string]string{}
+	if m.Namespace != "" {
+		dims["namespace"] = m.Namespace
+	}
+	if m.PodName != "" {
+		dims["pod"] = m.PodName
+	}
+	return dims
+}
+
+func (m *Metadata) values() map[string]string {
+	values := map[string]string{}
+	for k, v := range map[string]string{
+		"pod":             m.PodName,
+		"namespace":       m.Namespace,
+		"pod_ip":          m.PodIP,
+		"node":            m.NodeName,
+		"service_account": m.ServiceAccount,
+	} {
+		if v != "" {
+			values[k] = v
+		}
+	}
+	for k, v := range m.Labels {
+		values["label."+k] = v
+	}
+	return values
+}
+
+type metadataHook struct {
+	fields logrus.Fields
+}
+
+func (h metadataHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+func (h metadataHook) Fire(e *logrus.Entry) error {
+	for k, v := range h.fields {
+		// fields set on the entry take precedence
+		if _, ok := e.Data[k]; !ok {
+			e.Data[k] = v
+		}
+	}
+	return nil
+}
+
+// readPodInfo will parse a downward API file of 'key="value"' lines. If the
+// file does not exist, nil will be returned.
+func readPodInfo(path string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	values := map[string]string{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		i := strings.Index(line, "=")
+		if i < 0 {
+			continue
+		}
+		value, err := strconv.Unquote(line[i+1:])
+		if err != nil {
+			value = line[i+1:]
+		}
+		values[line[:i]] = value
+	}
+	return values, scanner.Err()
+}
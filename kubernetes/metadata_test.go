@@ -0,0 +1,77 @@
+package kubernetes
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/Sirupsen/logrus"
+)
+
+func TestLoadMetadataFromEnv(t *testing.T) {
+	dir, err := ioutil.TempDir("", "podinfo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	labels := "app=\"orders\"\npod-template-hash=\"7d4b9\"\n"
+	if err := ioutil.WriteFile(filepath.Join(dir, "labels"), []byte(labels), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	os.Setenv("POD_NAME", "orders-7d4b9-x2lq")
+	os.Setenv("POD_NAMESPACE", "shop")
+	os.Setenv("POD_INFO_PATH", dir)
+	defer func() {
+		os.Unsetenv("POD_NAME")
+		os.Unsetenv("POD_NAMESPACE")
+		os.Unsetenv("POD_INFO_PATH")
+	}()
+
+	md := LoadMetadataFromEnv()
+	if md == nil {
+		t.Fatal("expected metadata, got nil")
+	}
+	wantLabels := map[string]string{"app": "orders", "pod-template-hash": "7d4b9"}
+	if !reflect.DeepEqual(md.Labels, wantLabels) {
+		t.Errorf("expected labels %v, got %v", wantLabels, md.Labels)
+	}
+	if md.Annotations != nil {
+		t.Errorf("expected no annotations, got %v", md.Annotations)
+	}
+
+	wantTags := []string{
+		"label.app:orders",
+		"label.pod-template-hash:7d4b9",
+		"namespace:shop",
+		"pod:orders-7d4b9-x2lq",
+	}
+	if got := md.Tags(); !reflect.DeepEqual(got, wantTags) {
+		t.Errorf("expected tags %v, got %v", wantTags, got)
+	}
+	wantDims := map[string]string{"namespace": "shop", "pod": "orders-7d4b9-x2lq"}
+	if got := md.Dimensions(); !reflect.DeepEqual(got, wantDims) {
+		t.Errorf("expected dimensions %v, got %v", wantDims, got)
+	}
+
+	entry := logrus.NewEntry(logrus.New()).WithField("pod", "override")
+	if err := md.Hook().Fire(entry); err != nil {
+		t.Fatal(err)
+	}
+	if got := entry.Data["namespace"]; got != "shop" {
+		t.Errorf("expected namespace field 'shop', got %v", got)
+	}
+	if got := entry.Data["pod"]; got != "override" {
+		t.Errorf("expected entry's pod field to be kept, got %v", got)
+	}
+}
+
+func TestLoadMetadataFromEnvNotInPod(t *testing.T) {
+	os.Setenv("POD_INFO_PATH", "/does/not/exist")
+	defer os.Unsetenv("POD_INFO_PATH")
+	if md := LoadMetadataFromEnv(); md != nil {
+		t.Errorf("expected nil metadata, got %+v", md)
+	}
+}
@@ -0,0 +1,118 @@
+package kubernetes
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+
+	"github.com/NYTimes/gizmo/pubsub"
+	"github.com/NYTimes/gizmo/server"
+)
+
+// ErrConsumerNotRunning is returned by a consumer check if the
+// Consumer is not receiving messages.
+var ErrConsumerNotRunning = errors.New("consumer is not running")
+
+// ReadinessCheck is an http.Handler that will fail while any of its checks are
+// failing. Used as the server's 'custom' health check and a readiness probe,
+// it will keep traffic away from a pod until everything it depends on, like
+// its pubsub consumers, is healthy:
+//
+//	cfg.HealthCheckType = "custom"
+//	cfg.HealthCheckPath = "/ready"
+//	cfg.CustomHealthCheckHandler = ready
+type ReadinessCheck struct {
+	mu     sync.RWMutex
+	names  []string
+	checks map[string]func() error
+}
+
+// NewReadinessCheck will return a ReadinessCheck with no checks.
+func NewReadinessCheck() *ReadinessCheck {
+	return &ReadinessCheck{checks: map[string]func() error{}}
+}
+
+// AddCheck will add a check that must return nil for the pod to be ready.
+// Checks are run on every probe, so they should be quick.
+func (r *ReadinessCheck) AddCheck(name string, check func() error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.checks[name]; !ok {
+		r.names = append(r.names, name)
+	}
+	r.checks[name] = check
+}
+
+// AddConsumer will add a check that fails while the Consumer is not running
+// or, if maxFailureRatio is greater than 0, while more than that ratio of the
+// messages handled since the previous probe have failed.
+func (r *ReadinessCheck) AddConsumer(name string, c *pubsub.Consumer, maxFailureRatio float64) {
+	r.AddCheck(name, ConsumerCheck(c, maxFailureRatio))
+}
+
+// ConsumerCheck will return a check for the health of the Consumer. See
+// AddConsumer for details.
+func ConsumerCheck(c *pubsub.Consumer, maxFailureRatio float64) func() error {
+	var (
+		mu   sync.Mutex
+		last pubsub.ConsumerStats
+	)
+	return func() error {
+		if !c.Running() {
+			return ErrConsumerNotRunning
+		}
+		if maxFailureRatio <= 0 {
+			return nil
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		stats := c.Stats()
+		handled, failed := stats.Handled-last.Handled, stats.Failed-last.Failed
+		last = stats
+		if handled == 0 {
+			return nil
+		}
+		if ratio := float64(failed) / float64(handled); ratio > maxFailureRatio {
+			return fmt.Errorf("%d of %d messages failed", failed, handled)
+		}
+		return nil
+	}
+}
+
+// Check will run all checks and return the errors of any that failed.
+func (r *ReadinessCheck) Check() map[string]error {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	failed := map[string]error{}
+	for _, name := range r.names {
+		if err := r.checks[name](); err != nil {
+			failed[name] = err
+		}
+	}
+	return failed
+}
+
+// ServeHTTP will respond with a 503 and the errors of any failing checks.
+// Otherwise, it will respond with "ok-"+server.Name.
+func (r *ReadinessCheck) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	failed := r.Check()
+	if len(failed) == 0 {
+		if _, err := io.WriteString(w, "ok-"+server.Name); err != nil {
+			server.LogWithFields(req).Warn("unable to write readiness response: ", err)
+		}
+		return
+	}
+
+	res := map[string]string{}
+	for name, err := range failed {
+		res[name] = err.Error()
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(http.StatusServiceUnavailable)
+	if err := json.NewEncoder(w).Encode(map[string]interface{}{"failed": res}); err != nil {
+		server.LogWithFields(req).Warn("unable to write readiness response: ", err)
+	}
+}
@@ -0,0 +1,108 @@
+package kubernetes
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/NYTimes/gizmo/pubsub"
+)
+
+type testMessage []byte
+
+func (m testMessage) Message() []byte { return m }
+func (m testMessage) Done() error     { return nil }
+
+type testSubscriber struct {
+	msgs chan pubsub.SubscriberMessage
+}
+
+func (s *testSubscriber) Start() <-chan pubsub.SubscriberMessage { return s.msgs }
+func (s *testSubscriber) Err() error                             { return nil }
+func (s *testSubscriber) Stop() error {
+	close(s.msgs)
+	return nil
+}
+
+func TestReadinessCheck(t *testing.T) {
+	ready := NewReadinessCheck()
+	var depErr error
+	ready.AddCheck("dependency", func() error { return depErr })
+
+	tests := []struct {
+		given error
+
+		wantCode int
+		wantBody string
+	}{
+		{nil, http.StatusOK, "ok-"},
+		{errors.New("connection refused"), http.StatusServiceUnavailable, `{"failed":{"dependency":"connection refused"}}`},
+	}
+
+	for _, test := range tests {
+		depErr = test.given
+		r, _ := http.NewRequest("GET", "/ready", nil)
+		w := httptest.NewRecorder()
+		ready.ServeHTTP(w, r)
+
+		if w.Code != test.wantCode {
+			t.Errorf("expected code %d, got %d", test.wantCode, w.Code)
+		}
+		if got := w.Body.String(); !strings.HasPrefix(got, test.wantBody) {
+			t.Errorf("expected body to start with %q, got %q", test.wantBody, got)
+		}
+	}
+}
+
+func TestConsumerCheck(t *testing.T) {
+	sub := &testSubscriber{msgs: make(chan pubsub.SubscriberMessage)}
+	c := pubsub.NewConsumer(sub, func(ctx context.Context, msg pubsub.SubscriberMessage) error {
+		if string(msg.Message()) == "bad" {
+			return errors.New("bad message")
+		}
+		return nil
+	})
+	check := ConsumerCheck(c, 0.5)
+
+	if err := check(); err != ErrConsumerNotRunning {
+		t.Errorf("expected ErrConsumerNotRunning before Run, got %v", err)
+	}
+
+	go c.Run()
+	deadline := time.Now().Add(time.Second)
+	for !c.Running() && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+
+	handle := func(msgs ...string) {
+		want := c.Stats().Handled + int64(len(msgs))
+		for _, msg := range msgs {
+			sub.msgs <- testMessage(msg)
+		}
+		for c.Stats().Handled < want && time.Now().Before(deadline) {
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	handle("good", "bad")
+	if err := check(); err != nil {
+		t.Errorf("expected healthy consumer with 1 of 2 failed, got %s", err)
+	}
+	handle("bad", "bad", "good")
+	if err := check(); err == nil {
+		t.Error("expected unhealthy consumer with 2 of 3 failed")
+	}
+	if err := check(); err != nil {
+		t.Errorf("expected healthy consumer with no new messages, got %s", err)
+	}
+
+	c.Stop()
+	if err := check(); err != ErrConsumerNotRunning {
+		t.Errorf("expected ErrConsumerNotRunning after Stop, got %v", err)
+	}
+}
@@ -0,0 +1,26 @@
+/*
+Package lambda will run gizmo services and pubsub consumers as AWS Lambda
+functions. Start will poll the Lambda runtime API for invocations and pass each
+event to a Handler until the process is shut down.
+
+A server.Service can be run behind API Gateway or an ALB with NewServiceHandler.
+Each event is converted to an *http.Request and served by a SimpleServer with the
+Service registered, so the same endpoints and middleware are used in and out of
+Lambda:
+
+	handler, err := lambda.NewServiceHandler(cfg.Server, &MyService{})
+	if err != nil {
+		lambda.Log.Fatal(err)
+	}
+	lambda.Log.Fatal(lambda.Start(handler))
+
+A pubsub.MessageHandler can consume SQS or SNS events with NewSQSHandler and
+NewSNSHandler. The records of each event are run through a pubsub.Consumer and
+the invocation fails if any of them could not be handled, unless
+ReportBatchItemFailures is set so only the failed SQS messages are retried:
+
+	handler := lambda.NewSQSHandler(handleOrder)
+	handler.ReportBatchItemFailures = true
+	lambda.Log.Fatal(lambda.Start(handler))
+*/
+package lambda
@@ -0,0 +1,142 @@
+package lambda
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"unicode/utf8"
+
+	"golang.org/x/net/context"
+
+	"github.com/NYTimes/gizmo/config"
+	"github.com/NYTimes/gizmo/server"
+)
+
+// HTTPRequest is the event sent by API Gateway's Lambda proxy integration and
+// by an Application Load Balancer's Lambda target group.
+type HTTPRequest struct {
+	HTTPMethod                      string              `json:"httpMethod"`
+	Path                            string              `json:"path"`
+	Headers                         map[string]string   `json:"headers"`
+	MultiValueHeaders               map[string][]string `json:"multiValueHeaders"`
+	QueryStringParameters           map[string]string   `json:"queryStringParameters"`
+	MultiValueQueryStringParameters map[string][]string `json:"multiValueQueryStringParameters"`
+	Body                            string              `json:"body"`
+	IsBase64Encoded                 bool                `json:"isBase64Encoded"`
+	RequestContext                  HTTPRequestContext  `json:"requestContext"`
+}
+
+// HTTPRequestContext is the context of an HTTPRequest. Only one of
+// Identity, for API Gateway, or ELB, for load balancers, will be set.
+type HTTPRequestContext struct {
+	RequestID string `json:"requestId"`
+	Stage     string `json:"stage"`
+	Identity  struct {
+		SourceIP string `json:"sourceIp"`
+	} `json:"identity"`
+	ELB *struct {
+		TargetGroupArn string `json:"targetGroupArn"`
+	} `json:"elb,omitempty"`
+}
+
+// HTTPResponse is the response expected by API Gateway and load balancers.
+type HTTPResponse struct {
+	StatusCode        int                 `json:"statusCode"`
+	StatusDescription string              `json:"statusDescription,omitempty"`
+	Headers           map[string]string   `json:"headers,omitempty"`
+	MultiValueHeaders map[string][]string `json:"multiValueHeaders,omitempty"`
+	Body              string              `json:"body"`
+	IsBase64Encoded   bool                `json:"isBase64Encoded"`
+}
+
+// NewHTTPHandler will return a Handler that serves API Gateway and load
+// balancer events with the http.Handler.
+func NewHTTPHandler(h http.Handler) Handler {
+	return HandlerFunc(func(ctx context.Context, payload []byte) ([]byte, error) {
+		var event HTTPRequest
+		if err := json.Unmarshal(payload, &event); err != nil {
+			return nil, err
+		}
+		r, err := event.request()
+		if err != nil {
+			return nil, err
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return json.Marshal(newHTTPResponse(w, event.RequestContext.ELB != nil))
+	})
+}
+
+// NewServiceHandler will return a Handler that serves API Gateway and load
+// balancer events with a SimpleServer the Service has been registered on, so
+// a service can be deployed to Lambda without any changes.
+func NewServiceHandler(cfg *config.Server, svc server.Service) (Handler, error) {
+	s := server.NewSimpleServer(cfg)
+	if err := s.Register(svc); err != nil {
+		return nil, err
+	}
+	return NewHTTPHandler(s), nil
+}
+
+func (e *HTTPRequest) request() (*http.Request, error) {
+	body := []byte(e.Body)
+	if e.IsBase64Encoded {
+		var err error
+		if body, err = base64.StdEncoding.DecodeString(e.Body); err != nil {
+			return nil, err
+		}
+	}
+
+	query := url.Values{}
+	for k, v := range e.QueryStringParameters {
+		query.Set(k, v)
+	}
+	for k, vs := range e.MultiValueQueryStringParameters {
+		query[k] = vs
+	}
+	u := &url.URL{Path: e.Path, RawQuery: query.Encode()}
+	r, err := http.NewRequest(e.HTTPMethod, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+
+	for k, v := range e.Headers {
+		r.Header.Set(k, v)
+	}
+	for k, vs := range e.MultiValueHeaders {
+		r.Header.Del(k)
+		for _, v := range vs {
+			r.Header.Add(k, v)
+		}
+	}
+	r.Host = r.Header.Get("Host")
+	if e.RequestContext.Identity.SourceIP != "" {
+		r.RemoteAddr = e.RequestContext.Identity.SourceIP + ":0"
+	}
+	if e.RequestContext.RequestID != "" && r.Header.Get("X-Request-Id") == "" {
+		r.Header.Set("X-Request-Id", e.RequestContext.RequestID)
+	}
+	return r, nil
+}
+
+func newHTTPResponse(w *httptest.ResponseRecorder, elb bool) *HTTPResponse {
+	resp := &HTTPResponse{
+		StatusCode:        w.Code,
+		MultiValueHeaders: map[string][]string(w.HeaderMap),
+	}
+	if elb {
+		resp.StatusDescription = fmt.Sprintf("%d %s", w.Code, http.StatusText(w.Code))
+	}
+	body := w.Body.Bytes()
+	if utf8.Valid(body) {
+		resp.Body = string(body)
+	} else {
+		resp.Body = base64.StdEncoding.EncodeToString(body)
+		resp.IsBase64Encoded = true
+	}
+	return resp
+}
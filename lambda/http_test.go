@@ -0,0 +1,137 @@
+package lambda
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"golang.org/x/net/context"
+
+	"github.com/NYTimes/gizmo/config"
+	"github.com/NYTimes/gizmo/server"
+)
+
+type testJSONService struct{}
+
+func (s *testJSONService) Prefix() string { return "/svc" }
+
+func (s *testJSONService) Middleware(h http.Handler) http.Handler { return h }
+
+func (s *testJSONService) JSONMiddleware(e server.JSONEndpoint) server.JSONEndpoint { return e }
+
+func (s *testJSONService) JSONEndpoints() map[string]map[string]server.JSONEndpoint {
+	return map[string]map[string]server.JSONEndpoint{
+		"/echo": {
+			"POST": func(r *http.Request) (int, interface{}, error) {
+				var body map[string]string
+				if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+					return http.StatusBadRequest, nil, err
+				}
+				body["q"] = r.URL.Query().Get("q")
+				body["ip"] = server.GetForwardedIP(r)
+				body["header"] = r.Header.Get("X-Test")
+				return http.StatusOK, body, nil
+			},
+		},
+		"/missing": {
+			"GET": func(r *http.Request) (int, interface{}, error) {
+				return http.StatusNotFound, map[string]string{"error": "not found"}, nil
+			},
+		},
+	}
+}
+
+func TestServiceHandler(t *testing.T) {
+	h, err := NewServiceHandler(&config.Server{}, &testJSONService{})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	tests := []struct {
+		name  string
+		given HTTPRequest
+
+		wantCode        int
+		wantDescription string
+		wantBody        string
+	}{
+		{
+			name: "api gateway",
+			given: HTTPRequest{
+				HTTPMethod:            "POST",
+				Path:                  "/svc/echo",
+				Headers:               map[string]string{"X-Test": "yes", "X-Forwarded-For": "1.2.3.4"},
+				QueryStringParameters: map[string]string{"q": "search"},
+				Body:                  `{"hello":"world"}`,
+			},
+			wantCode: http.StatusOK,
+			wantBody: `{"header":"yes","hello":"world","ip":"1.2.3.4","q":"search"}`,
+		},
+		{
+			name: "load balancer with base64 body",
+			given: func() HTTPRequest {
+				r := HTTPRequest{
+					HTTPMethod:                      "POST",
+					Path:                            "/svc/echo",
+					MultiValueHeaders:               map[string][]string{"X-Test": {"a", "b"}},
+					MultiValueQueryStringParameters: map[string][]string{"q": {"one", "two"}},
+					Body:                            base64.StdEncoding.EncodeToString([]byte(`{"hello":"world"}`)),
+					IsBase64Encoded:                 true,
+				}
+				r.RequestContext.ELB = &struct {
+					TargetGroupArn string `json:"targetGroupArn"`
+				}{"arn"}
+				return r
+			}(),
+			wantCode:        http.StatusOK,
+			wantDescription: "200 OK",
+			wantBody:        `{"header":"a","hello":"world","ip":"","q":"one"}`,
+		},
+		{
+			name:     "error",
+			given:    HTTPRequest{HTTPMethod: "GET", Path: "/svc/missing"},
+			wantCode: http.StatusNotFound,
+			wantBody: `{"error":"not found"}`,
+		},
+	}
+
+	for _, test := range tests {
+		payload, _ := json.Marshal(test.given)
+		out, err := h.Invoke(context.Background(), payload)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %s", test.name, err)
+		}
+		var got HTTPResponse
+		if err := json.Unmarshal(out, &got); err != nil {
+			t.Fatalf("%s: unable to decode response: %s", test.name, err)
+		}
+		if got.StatusCode != test.wantCode {
+			t.Errorf("%s: expected status %d, got %d", test.name, test.wantCode, got.StatusCode)
+		}
+		if got.StatusDescription != test.wantDescription {
+			t.Errorf("%s: expected status description %q, got %q", test.name, test.wantDescription, got.StatusDescription)
+		}
+		if got.Body != test.wantBody+"\n" {
+			t.Errorf("%s: expected body %q, got %q", test.name, test.wantBody, got.Body)
+		}
+		if ct := got.MultiValueHeaders["Content-Type"]; len(ct) != 1 || ct[0] != "application/json; charset=UTF-8" {
+			t.Errorf("%s: expected JSON content type, got %v", test.name, ct)
+		}
+	}
+}
+
+func TestHTTPHandlerBinaryResponse(t *testing.T) {
+	h := NewHTTPHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte{0xff, 0xfe})
+	}))
+	out, err := h.Invoke(context.Background(), []byte(`{"httpMethod":"GET","path":"/"}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	var got HTTPResponse
+	json.Unmarshal(out, &got)
+	if !got.IsBase64Encoded || got.Body != "//4=" {
+		t.Errorf("expected base64 encoded body, got %#v", got)
+	}
+}
@@ -0,0 +1,142 @@
+package lambda
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"reflect"
+	"runtime/debug"
+	"strconv"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"golang.org/x/net/context"
+)
+
+// Log is the structured logger used throughout the package.
+var Log = logrus.New()
+
+// runtimeAPIVersion is the version of the Lambda Runtime API paths.
+const runtimeAPIVersion = "2018-06-01"
+
+// Handler will handle the JSON payload of a single Lambda invocation.
+type Handler interface {
+	Invoke(ctx context.Context, payload []byte) ([]byte, error)
+}
+
+// HandlerFunc is a func that implements Handler.
+type HandlerFunc func(ctx context.Context, payload []byte) ([]byte, error)
+
+// Invoke will call the func.
+func (f HandlerFunc) Invoke(ctx context.Context, payload []byte) ([]byte, error) {
+	return f(ctx, payload)
+}
+
+type requestIDKey int
+
+// RequestID will return the ID of the invocation being handled, if any.
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey(0)).(string)
+	return id
+}
+
+// Start will receive invocations from the Lambda Runtime API and pass them to
+// the Handler until the runtime fails. It should be called from main in a
+// function deployed with a custom runtime.
+func Start(h Handler) error {
+	api := os.Getenv("AWS_LAMBDA_RUNTIME_API")
+	if api == "" {
+		return errors.New("AWS_LAMBDA_RUNTIME_API is not set, is this running in Lambda?")
+	}
+	return (&runtimeClient{
+		base:   "http://" + api + "/" + runtimeAPIVersion + "/runtime/invocation/",
+		client: &http.Client{},
+	}).run(h)
+}
+
+type runtimeClient struct {
+	base   string
+	client *http.Client
+}
+
+// invocationError is the body posted to the Runtime API when an
+// invocation fails.
+type invocationError struct {
+	Message    string   `json:"errorMessage"`
+	Type       string   `json:"errorType"`
+	StackTrace []string `json:"stackTrace,omitempty"`
+}
+
+func (rc *runtimeClient) run(h Handler) error {
+	for {
+		resp, err := rc.client.Get(rc.base + "next")
+		if err != nil {
+			return err
+		}
+		payload, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return err
+		}
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("unexpected status from lambda runtime: %d: %s", resp.StatusCode, payload)
+		}
+
+		id := resp.Header.Get("Lambda-Runtime-Aws-Request-Id")
+		ctx := context.WithValue(context.Background(), requestIDKey(0), id)
+		var cancel context.CancelFunc
+		if ms, err := strconv.ParseInt(resp.Header.Get("Lambda-Runtime-Deadline-Ms"), 10, 64); err == nil {
+			ctx, cancel = context.WithDeadline(ctx, time.Unix(0, ms*int64(time.Millisecond)))
+		} else {
+			ctx, cancel = context.WithCancel(ctx)
+		}
+
+		out, ierr := invoke(ctx, h, payload)
+		cancel()
+		if ierr != nil {
+			Log.WithField("request_id", id).Error("lambda invocation failed: ", ierr.Message)
+			b, _ := json.Marshal(ierr)
+			err = rc.post(id+"/error", b)
+		} else {
+			err = rc.post(id+"/response", out)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// invoke will run the handler, turning any panic into an error.
+func invoke(ctx context.Context, h Handler, payload []byte) (out []byte, ierr *invocationError) {
+	defer func() {
+		if x := recover(); x != nil {
+			ierr = &invocationError{
+				Message:    fmt.Sprint(x),
+				Type:       "panic",
+				StackTrace: []string{string(debug.Stack())},
+			}
+		}
+	}()
+	out, err := h.Invoke(ctx, payload)
+	if err != nil {
+		return nil, &invocationError{Message: err.Error(), Type: reflect.TypeOf(err).String()}
+	}
+	return out, nil
+}
+
+func (rc *runtimeClient) post(path string, body []byte) error {
+	resp, err := rc.client.Post(rc.base+path, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted {
+		b, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("unexpected status from lambda runtime: %d: %s", resp.StatusCode, b)
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+package lambda
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// testRuntimeAPI serves the given payloads as invocations and records
+// the responses, failing once it runs out of payloads.
+type testRuntimeAPI struct {
+	mu        sync.Mutex
+	payloads  []string
+	responses map[string]string
+	errors    map[string]string
+}
+
+func (rt *testRuntimeAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	path := strings.TrimPrefix(r.URL.Path, "/"+runtimeAPIVersion+"/runtime/invocation/")
+	if path == "next" {
+		if len(rt.payloads) == 0 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		id := strconv.Itoa(len(rt.payloads))
+		w.Header().Set("Lambda-Runtime-Aws-Request-Id", id)
+		w.Header().Set("Lambda-Runtime-Deadline-Ms", fmt.Sprint(time.Now().Add(time.Minute).UnixNano()/int64(time.Millisecond)))
+		w.Write([]byte(rt.payloads[0]))
+		rt.payloads = rt.payloads[1:]
+		return
+	}
+
+	b, _ := ioutil.ReadAll(r.Body)
+	parts := strings.Split(path, "/")
+	switch parts[1] {
+	case "response":
+		rt.responses[parts[0]] = string(b)
+	case "error":
+		rt.errors[parts[0]] = string(b)
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func TestRuntime(t *testing.T) {
+	rt := &testRuntimeAPI{
+		payloads:  []string{"hello", "fail", "panic"},
+		responses: map[string]string{},
+		errors:    map[string]string{},
+	}
+	srv := httptest.NewServer(rt)
+	defer srv.Close()
+
+	var ids []string
+	h := HandlerFunc(func(ctx context.Context, payload []byte) ([]byte, error) {
+		if _, ok := ctx.Deadline(); !ok {
+			t.Error("expected invocation context to have a deadline")
+		}
+		ids = append(ids, RequestID(ctx))
+		switch string(payload) {
+		case "fail":
+			return nil, errors.New("nope")
+		case "panic":
+			panic("boom")
+		}
+		return []byte(`"` + string(payload) + ` world"`), nil
+	})
+
+	rc := &runtimeClient{base: srv.URL + "/" + runtimeAPIVersion + "/runtime/invocation/", client: &http.Client{}}
+	err := rc.run(h)
+	if err == nil || !strings.Contains(err.Error(), "500") {
+		t.Errorf("expected runtime to stop on an unexpected status, got %v", err)
+	}
+
+	if got := strings.Join(ids, ","); got != "3,2,1" {
+		t.Errorf("expected request IDs 3,2,1, got %s", got)
+	}
+	if got := rt.responses["3"]; got != `"hello world"` {
+		t.Errorf("expected response %q, got %q", `"hello world"`, got)
+	}
+	if got := rt.errors["2"]; got != `{"errorMessage":"nope","errorType":"*errors.errorString"}` {
+		t.Errorf("expected error response, got %q", got)
+	}
+	if got := rt.errors["1"]; !strings.Contains(got, `"errorMessage":"boom","errorType":"panic"`) {
+		t.Errorf("expected panic error response, got %q", got)
+	}
+}
+
+func TestStartWithoutRuntime(t *testing.T) {
+	if err := Start(HandlerFunc(nil)); err == nil {
+		t.Error("expected error without AWS_LAMBDA_RUNTIME_API, got nil")
+	}
+}
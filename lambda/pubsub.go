@@ -0,0 +1,195 @@
+package lambda
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"golang.org/x/net/context"
+
+	"github.com/NYTimes/gizmo/pubsub"
+	"github.com/NYTimes/gizmo/reporting"
+)
+
+// SQSEvent is the event sent by an SQS event source mapping.
+type SQSEvent struct {
+	Records []SQSRecord `json:"Records"`
+}
+
+// SQSRecord is a single message of an SQSEvent.
+type SQSRecord struct {
+	MessageID      string            `json:"messageId"`
+	ReceiptHandle  string            `json:"receiptHandle"`
+	Body           string            `json:"body"`
+	Attributes     map[string]string `json:"attributes"`
+	EventSource    string            `json:"eventSource"`
+	EventSourceARN string            `json:"eventSourceARN"`
+}
+
+// SQSBatchResponse is the response that reports which messages of an
+// SQSEvent failed, so only those are retried.
+type SQSBatchResponse struct {
+	BatchItemFailures []SQSBatchItemFailure `json:"batchItemFailures"`
+}
+
+// SQSBatchItemFailure is a failed message of an SQSEvent.
+type SQSBatchItemFailure struct {
+	ItemIdentifier string `json:"itemIdentifier"`
+}
+
+// SNSEvent is the event sent by an SNS subscription.
+type SNSEvent struct {
+	Records []SNSRecord `json:"Records"`
+}
+
+// SNSRecord is a single notification of an SNSEvent.
+type SNSRecord struct {
+	EventSource          string    `json:"EventSource"`
+	EventSubscriptionArn string    `json:"EventSubscriptionArn"`
+	SNS                  SNSEntity `json:"Sns"`
+}
+
+// SNSEntity is the notification of an SNSRecord.
+type SNSEntity struct {
+	MessageID string `json:"MessageId"`
+	TopicArn  string `json:"TopicArn"`
+	Subject   string `json:"Subject"`
+	Message   string `json:"Message"`
+	Timestamp string `json:"Timestamp"`
+}
+
+// ConsumerHandler is a Handler that runs a pubsub.MessageHandler over
+// the messages of each SQS or SNS event with a pubsub.Consumer, so the
+// same handler can be run in Lambda or a long-running consumer.
+type ConsumerHandler struct {
+	// Concurrency is the max number of messages of an event that
+	// will be handled at once. Defaults to 1.
+	Concurrency int
+	// Base64 will decode message bodies, like the ConsumeBase64
+	// option of the SQSSubscriber.
+	Base64 bool
+	// ReportBatchItemFailures will respond to SQS events with the messages
+	// that failed so only they are retried. It must match the setting of
+	// the event source mapping. Otherwise, the invocation will fail if
+	// any message fails and the whole batch will be retried.
+	ReportBatchItemFailures bool
+	// Reporter is an optional hook that will be sent a report of any
+	// handler panic along with the message being handled.
+	Reporter reporting.Reporter
+
+	handler pubsub.MessageHandler
+	sns     bool
+}
+
+// NewSQSHandler will return a Handler for SQS events.
+func NewSQSHandler(handler pubsub.MessageHandler) *ConsumerHandler {
+	return &ConsumerHandler{handler: handler}
+}
+
+// NewSNSHandler will return a Handler for SNS events. The invocation will
+// fail if any message fails so SNS will retry the event.
+func NewSNSHandler(handler pubsub.MessageHandler) *ConsumerHandler {
+	return &ConsumerHandler{handler: handler, sns: true}
+}
+
+// Invoke will handle every message of the event.
+func (c *ConsumerHandler) Invoke(ctx context.Context, payload []byte) ([]byte, error) {
+	var msgs []*eventMessage
+	if c.sns {
+		var event SNSEvent
+		if err := json.Unmarshal(payload, &event); err != nil {
+			return nil, err
+		}
+		for _, r := range event.Records {
+			msgs = append(msgs, &eventMessage{id: r.SNS.MessageID, body: r.SNS.Message})
+		}
+	} else {
+		var event SQSEvent
+		if err := json.Unmarshal(payload, &event); err != nil {
+			return nil, err
+		}
+		for _, r := range event.Records {
+			msgs = append(msgs, &eventMessage{id: r.MessageID, body: r.Body})
+		}
+	}
+	for _, m := range msgs {
+		m.base64 = c.Base64
+	}
+
+	consumer := pubsub.NewConsumer(&eventSubscriber{msgs: msgs}, c.handler)
+	consumer.Concurrency = c.Concurrency
+	consumer.Reporter = c.Reporter
+	if err := consumer.Run(); err != nil {
+		return nil, err
+	}
+
+	var resp SQSBatchResponse
+	for _, m := range msgs {
+		if !m.isDone() {
+			resp.BatchItemFailures = append(resp.BatchItemFailures, SQSBatchItemFailure{ItemIdentifier: m.id})
+		}
+	}
+	if c.sns || !c.ReportBatchItemFailures {
+		if n := len(resp.BatchItemFailures); n > 0 {
+			return nil, fmt.Errorf("%d of %d messages failed", n, len(msgs))
+		}
+		return nil, nil
+	}
+	if resp.BatchItemFailures == nil {
+		resp.BatchItemFailures = []SQSBatchItemFailure{}
+	}
+	return json.Marshal(resp)
+}
+
+// eventSubscriber is a Subscriber that emits the messages of a single event.
+type eventSubscriber struct {
+	msgs []*eventMessage
+}
+
+func (s *eventSubscriber) Start() <-chan pubsub.SubscriberMessage {
+	out := make(chan pubsub.SubscriberMessage, len(s.msgs))
+	for _, m := range s.msgs {
+		out <- m
+	}
+	close(out)
+	return out
+}
+
+func (s *eventSubscriber) Err() error  { return nil }
+func (s *eventSubscriber) Stop() error { return nil }
+
+// eventMessage is a message of an event. Lambda will delete every message of
+// a successful invocation, so Done only records that it was handled.
+type eventMessage struct {
+	id     string
+	body   string
+	base64 bool
+
+	mu   sync.Mutex
+	done bool
+}
+
+func (m *eventMessage) Message() []byte {
+	if !m.base64 {
+		return []byte(m.body)
+	}
+	b, err := base64.StdEncoding.DecodeString(m.body)
+	if err != nil {
+		Log.Warnf("unable to parse message body: %s", err)
+	}
+	return b
+}
+
+func (m *eventMessage) Done() error {
+	m.mu.Lock()
+	m.done = true
+	m.mu.Unlock()
+	return nil
+}
+
+func (m *eventMessage) isDone() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.done
+}
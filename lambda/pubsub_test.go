@@ -0,0 +1,116 @@
+package lambda
+
+import (
+	"encoding/json"
+	"errors"
+	"sort"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/context"
+
+	"github.com/NYTimes/gizmo/pubsub"
+)
+
+func testMessageHandler(handled *[]string) pubsub.MessageHandler {
+	return func(ctx context.Context, msg pubsub.SubscriberMessage) error {
+		body := string(msg.Message())
+		switch body {
+		case "fail":
+			return errors.New("nope")
+		case "panic":
+			panic("boom")
+		}
+		*handled = append(*handled, body)
+		return nil
+	}
+}
+
+func TestSQSHandler(t *testing.T) {
+	tests := []struct {
+		name         string
+		bodies       []string
+		base64       bool
+		reportBatch  bool
+		wantHandled  []string
+		wantResponse string
+		wantErr      bool
+	}{
+		{
+			name:         "success",
+			bodies:       []string{"a", "b"},
+			wantHandled:  []string{"a", "b"},
+			wantResponse: "",
+		},
+		{
+			name:        "failure fails the batch",
+			bodies:      []string{"a", "fail"},
+			wantHandled: []string{"a"},
+			wantErr:     true,
+		},
+		{
+			name:         "failures reported",
+			bodies:       []string{"a", "fail", "panic"},
+			reportBatch:  true,
+			wantHandled:  []string{"a"},
+			wantResponse: `{"batchItemFailures":[{"itemIdentifier":"1"},{"itemIdentifier":"2"}]}`,
+		},
+		{
+			name:         "no failures reported",
+			bodies:       []string{"a"},
+			reportBatch:  true,
+			wantHandled:  []string{"a"},
+			wantResponse: `{"batchItemFailures":[]}`,
+		},
+		{
+			name:         "base64",
+			bodies:       []string{"aGk="},
+			base64:       true,
+			wantHandled:  []string{"hi"},
+			wantResponse: "",
+		},
+	}
+
+	for _, test := range tests {
+		var event SQSEvent
+		for i, body := range test.bodies {
+			event.Records = append(event.Records, SQSRecord{MessageID: string('0' + rune(i)), Body: body})
+		}
+		payload, _ := json.Marshal(event)
+
+		var handled []string
+		h := NewSQSHandler(testMessageHandler(&handled))
+		h.Base64 = test.base64
+		h.ReportBatchItemFailures = test.reportBatch
+		out, err := h.Invoke(context.Background(), payload)
+		if (err != nil) != test.wantErr {
+			t.Errorf("%s: expected error %t, got %v", test.name, test.wantErr, err)
+		}
+		if string(out) != test.wantResponse {
+			t.Errorf("%s: expected response %q, got %q", test.name, test.wantResponse, out)
+		}
+		sort.Strings(handled)
+		if strings.Join(handled, ",") != strings.Join(test.wantHandled, ",") {
+			t.Errorf("%s: expected handled %v, got %v", test.name, test.wantHandled, handled)
+		}
+	}
+}
+
+func TestSNSHandler(t *testing.T) {
+	var handled []string
+	h := NewSNSHandler(testMessageHandler(&handled))
+	h.ReportBatchItemFailures = true
+
+	payload := []byte(`{"Records":[{"EventSource":"aws:sns","Sns":{"MessageId":"1","Message":"hello"}}]}`)
+	if _, err := h.Invoke(context.Background(), payload); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+	if len(handled) != 1 || handled[0] != "hello" {
+		t.Errorf("expected 'hello' to be handled, got %v", handled)
+	}
+
+	payload = []byte(`{"Records":[{"EventSource":"aws:sns","Sns":{"MessageId":"2","Message":"fail"}}]}`)
+	if _, err := h.Invoke(context.Background(), payload); err == nil {
+		t.Error("expected SNS failures to fail the invocation, got nil")
+	}
+}
@@ -0,0 +1,25 @@
+/*
+Package leader elects a single leader among the instances of a service, so
+exactly one of N replicas runs work like a replay, compaction or scheduled
+jobs.
+
+An Elector campaigns for leadership with a Campaigner. A LockCampaigner holds
+a lease in any lock.Locker, like a DynamoDB table, and a KubernetesCampaigner
+holds a coordination.k8s.io Lease:
+
+	campaigner, err := leader.NewDynamoDBCampaigner(cfg.DynamoDB, "compaction", "")
+	elector := leader.NewElector(campaigner)
+	elector.OnElected = func(ctx context.Context) {
+		compact(ctx)
+	}
+	elector.OnResigned = func() {
+		server.Log.Info("no longer compacting")
+	}
+	go elector.Run()
+	defer elector.Stop()
+
+OnElected is run in its own goroutine and its context is cancelled once
+leadership is lost, so the work it starts should stop before the lease can be
+taken over by another instance.
+*/
+package leader
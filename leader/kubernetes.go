@@ -0,0 +1,58 @@
+package leader
+
+import "github.com/NYTimes/gizmo/kubernetes"
+
+// KubernetesCampaigner is a Campaigner that holds leadership with a
+// coordination.k8s.io Lease via a kubernetes.LeaderElector.
+type KubernetesCampaigner struct {
+	elector *kubernetes.LeaderElector
+}
+
+// NewKubernetesCampaigner will return a KubernetesCampaigner for the Lease
+// with the given name in the Client's namespace. The identity will default
+// to the pod name if empty.
+func NewKubernetesCampaigner(c *kubernetes.Client, name, identity string) (*KubernetesCampaigner, error) {
+	le, err := kubernetes.NewLeaderElector(c, name, identity)
+	if err != nil {
+		return nil, err
+	}
+	return &KubernetesCampaigner{elector: le}, nil
+}
+
+// LeaderElector will return the underlying kubernetes.LeaderElector so its
+// timings can be adjusted before campaigning.
+func (k *KubernetesCampaigner) LeaderElector() *kubernetes.LeaderElector {
+	return k.elector
+}
+
+// Campaign will run the LeaderElector until stop is closed. It may only
+// be called once.
+func (k *KubernetesCampaigner) Campaign(elected, resigned func(), stop <-chan struct{}) {
+	terms := make(chan (<-chan struct{}))
+	done := make(chan struct{})
+	k.elector.OnStartedLeading = func(leading <-chan struct{}) {
+		select {
+		case terms <- leading:
+		case <-done:
+		}
+	}
+	go func() {
+		defer close(done)
+		k.elector.Run()
+	}()
+	go func() {
+		<-stop
+		k.elector.Stop()
+	}()
+
+	for {
+		select {
+		case leading := <-terms:
+			elected()
+			<-leading
+			resigned()
+		case <-done:
+			return
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package leader
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/Sirupsen/logrus"
+	"golang.org/x/net/context"
+)
+
+// Log is the structured logger used by the leader package.
+var Log = logrus.New()
+
+// Campaigner competes for leadership on behalf of an Elector.
+type Campaigner interface {
+	// Campaign will call elected once leadership is acquired and resigned
+	// once it is lost, as many times as it is won and lost, until stop is
+	// closed. If leading when stop is closed, leadership should be given up
+	// and resigned called before returning.
+	Campaign(elected, resigned func(), stop <-chan struct{})
+}
+
+// Elector elects a single leader among the instances of a service, so
+// exactly one of them runs work like a replay, compaction or scheduled jobs.
+type Elector struct {
+	// OnElected is called in its own goroutine each time leadership is
+	// acquired. The context will be cancelled once leadership is lost or
+	// the Elector is stopped and OnElected should return promptly after.
+	OnElected func(ctx context.Context)
+	// OnResigned is called each time leadership is lost or given up on Stop,
+	// once OnElected has returned.
+	OnResigned func()
+
+	campaigner Campaigner
+	leader     int32
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	stop     chan struct{}
+	stopOnce sync.Once
+	done     chan struct{}
+}
+
+// NewElector will return an Elector that campaigns with the given Campaigner.
+func NewElector(c Campaigner) *Elector {
+	return &Elector{
+		campaigner: c,
+		stop:       make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+}
+
+// IsLeader will return true while this instance is the leader.
+func (e *Elector) IsLeader() bool {
+	return atomic.LoadInt32(&e.leader) == 1
+}
+
+// Run will campaign for leadership until Stop is called.
+func (e *Elector) Run() {
+	defer close(e.done)
+	e.campaigner.Campaign(e.elected, e.resigned, e.stop)
+}
+
+func (e *Elector) elected() {
+	atomic.StoreInt32(&e.leader, 1)
+	var ctx context.Context
+	ctx, e.cancel = context.WithCancel(context.Background())
+	if e.OnElected == nil {
+		return
+	}
+	e.wg.Add(1)
+	go func() {
+		defer e.wg.Done()
+		e.OnElected(ctx)
+	}()
+}
+
+func (e *Elector) resigned() {
+	atomic.StoreInt32(&e.leader, 0)
+	e.cancel()
+	e.wg.Wait()
+	if e.OnResigned != nil {
+		e.OnResigned()
+	}
+}
+
+// Stop will give up leadership, if held, and block until Run has returned.
+// Run must have been called before calling Stop.
+func (e *Elector) Stop() {
+	e.stopOnce.Do(func() {
+		close(e.stop)
+	})
+	<-e.done
+}
@@ -0,0 +1,129 @@
+package leader
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/NYTimes/gizmo/lock"
+)
+
+// testElector will record the order of elections and resignations.
+type testElector struct {
+	*Elector
+
+	mu     sync.Mutex
+	events []string
+}
+
+func newTestElector(t *testing.T, l lock.Locker, identity string) *testElector {
+	c, err := NewLockCampaigner(l, "compaction", identity)
+	if err != nil {
+		t.Fatal("unexpected error creating campaigner: ", err)
+	}
+	c.TTL = 30 * time.Millisecond
+	c.RetryPeriod = 5 * time.Millisecond
+	e := &testElector{Elector: NewElector(c)}
+	e.OnElected = func(ctx context.Context) {
+		e.record("elected")
+		<-ctx.Done()
+		e.record("cancelled")
+	}
+	e.OnResigned = func() {
+		e.record("resigned")
+	}
+	return e
+}
+
+func (e *testElector) record(event string) {
+	e.mu.Lock()
+	e.events = append(e.events, event)
+	e.mu.Unlock()
+}
+
+func (e *testElector) recorded() []string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]string(nil), e.events...)
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestElector(t *testing.T) {
+	l := lock.NewLocalLocker()
+	a, b := newTestElector(t, l, "a"), newTestElector(t, l, "b")
+	go a.Run()
+	waitFor(t, "a to be elected", a.IsLeader)
+	go b.Run()
+	defer b.Stop()
+
+	// b should not take over while a renews its lease
+	time.Sleep(60 * time.Millisecond)
+	if b.IsLeader() {
+		t.Fatal("expected only one leader")
+	}
+
+	a.Stop()
+	if a.IsLeader() {
+		t.Error("expected a to resign on Stop")
+	}
+	want := []string{"elected", "cancelled", "resigned"}
+	if got := a.recorded(); len(got) != len(want) || got[0] != want[0] || got[1] != want[1] || got[2] != want[2] {
+		t.Errorf("expected events %v, got %v", want, got)
+	}
+	waitFor(t, "b to be elected", b.IsLeader)
+}
+
+// deniedLocker will refuse any lock while deny is set, as if
+// it were held by another owner.
+type deniedLocker struct {
+	*lock.LocalLocker
+
+	mu   sync.Mutex
+	deny bool
+}
+
+func (d *deniedLocker) Acquire(name, owner string, ttl time.Duration) (bool, error) {
+	d.mu.Lock()
+	deny := d.deny
+	d.mu.Unlock()
+	if deny {
+		return false, nil
+	}
+	return d.LocalLocker.Acquire(name, owner, ttl)
+}
+
+func (d *deniedLocker) setDeny(deny bool) {
+	d.mu.Lock()
+	d.deny = deny
+	d.mu.Unlock()
+}
+
+func TestElectorLostLease(t *testing.T) {
+	l := &deniedLocker{LocalLocker: lock.NewLocalLocker()}
+	a := newTestElector(t, l, "a")
+	go a.Run()
+	defer a.Stop()
+	waitFor(t, "a to be elected", a.IsLeader)
+
+	// losing the lease to another owner should make a resign
+	l.setDeny(true)
+	waitFor(t, "a to resign", func() bool { return len(a.recorded()) == 3 })
+	if a.IsLeader() {
+		t.Error("expected a to not be leader after resigning")
+	}
+
+	// and run for leadership again once it is free
+	l.setDeny(false)
+	waitFor(t, "a to be re-elected", a.IsLeader)
+}
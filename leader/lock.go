@@ -0,0 +1,133 @@
+package leader
+
+import (
+	"os"
+	"time"
+
+	"github.com/NYTimes/gizmo/config"
+	"github.com/NYTimes/gizmo/lock"
+)
+
+var (
+	// DefaultLeaseTTL is how long a LockCampaigner's lease will be valid
+	// for after each renewal if no TTL is given.
+	DefaultLeaseTTL = 15 * time.Second
+	// DefaultRetryPeriod is how often a LockCampaigner will try to acquire
+	// the lease while not leading if no RetryPeriod is given.
+	DefaultRetryPeriod = 2 * time.Second
+)
+
+// LockCampaigner is a Campaigner that holds leadership with a lease in any
+// lock.Locker, such as a lock.DynamoDBLocker. The leader renews its lease
+// every third of the TTL and resigns if it cannot be renewed before the
+// lease expires.
+type LockCampaigner struct {
+	// TTL is how long the lease is valid for after each renewal.
+	// Defaults to DefaultLeaseTTL.
+	TTL time.Duration
+	// RetryPeriod is how often the lease will be tried while not leading.
+	// Defaults to DefaultRetryPeriod.
+	RetryPeriod time.Duration
+
+	locker   lock.Locker
+	name     string
+	identity string
+}
+
+// NewLockCampaigner will return a LockCampaigner for the lease with the given
+// name. The identity must be unique to each instance and will default to the
+// hostname if empty.
+func NewLockCampaigner(l lock.Locker, name, identity string) (*LockCampaigner, error) {
+	if identity == "" {
+		var err error
+		if identity, err = os.Hostname(); err != nil {
+			return nil, err
+		}
+	}
+	return &LockCampaigner{
+		TTL:         DefaultLeaseTTL,
+		RetryPeriod: DefaultRetryPeriod,
+		locker:      l,
+		name:        name,
+		identity:    identity,
+	}, nil
+}
+
+// NewDynamoDBCampaigner will return a LockCampaigner with its lease in the
+// DynamoDB table in the config. See lock.NewDynamoDBLocker for the table's
+// schema.
+func NewDynamoDBCampaigner(cfg *config.DynamoDB, name, identity string) (*LockCampaigner, error) {
+	l, err := lock.NewDynamoDBLocker(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return NewLockCampaigner(l, name, identity)
+}
+
+// Campaign will try to acquire the lease every RetryPeriod and, once
+// elected, keep renewing it until it is lost or stop is closed.
+func (c *LockCampaigner) Campaign(elected, resigned func(), stop <-chan struct{}) {
+	for {
+		if !c.acquire(stop) {
+			return
+		}
+		Log.Infof("elected leader of %s as %s", c.name, c.identity)
+		elected()
+		stopped := c.lead(stop)
+		resigned()
+		if stopped {
+			return
+		}
+	}
+}
+
+// acquire will block until the lease has been acquired. If stop is
+// closed first, false will be returned.
+func (c *LockCampaigner) acquire(stop <-chan struct{}) bool {
+	for {
+		ok, err := c.locker.Acquire(c.name, c.identity, c.TTL)
+		if err != nil {
+			Log.Warnf("unable to acquire lease %s: %s", c.name, err)
+		}
+		if ok {
+			return true
+		}
+		select {
+		case <-stop:
+			return false
+		case <-time.After(c.RetryPeriod):
+		}
+	}
+}
+
+// lead will keep renewing the lease until it is lost or stop is closed,
+// in which case the lease will be released and true returned.
+func (c *LockCampaigner) lead(stop <-chan struct{}) (stopped bool) {
+	ticker := time.NewTicker(c.TTL / 3)
+	defer ticker.Stop()
+	renewed := time.Now()
+	for {
+		select {
+		case <-stop:
+			if err := c.locker.Release(c.name, c.identity); err != nil {
+				Log.Warnf("unable to release lease %s: %s", c.name, err)
+			}
+			return true
+		case <-ticker.C:
+		}
+		ok, err := c.locker.Acquire(c.name, c.identity, c.TTL)
+		switch {
+		case ok:
+			renewed = time.Now()
+		case err == nil:
+			Log.Warnf("lease %s was taken by another instance, resigning", c.name)
+			return false
+		case time.Since(renewed) > c.TTL*2/3:
+			// another renewal attempt may not land before the lease expires
+			Log.Warnf("unable to renew lease %s before it expires, resigning: %s", c.name, err)
+			return false
+		default:
+			Log.Warnf("unable to renew lease %s: %s", c.name, err)
+		}
+	}
+}
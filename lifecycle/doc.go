@@ -0,0 +1,22 @@
+/*
+Package lifecycle composes an application's long-running components, like the
+server, pubsub consumers and background loops, so they share one shutdown
+story: when any of them stops or the process is signalled, all of them stop.
+
+Each component is a Unit, a func that runs until its context is done. The
+server's RunContext, a pubsub.Consumer's RunContext and pubsub.Consume all
+fit:
+
+	err := lifecycle.Run(context.Background(),
+	    server.RunContext,
+	    consumer.RunContext,
+	    pubsub.Consume(sub, handleCat),
+	)
+
+Units are also compatible with golang.org/x/sync/errgroup, and Actor adapts
+them to an oklog/run Group:
+
+	var g run.Group
+	g.Add(lifecycle.Actor(consumer.RunContext))
+*/
+package lifecycle
@@ -0,0 +1,73 @@
+package lifecycle
+
+import (
+	"os"
+	"os/signal"
+	"sync"
+
+	"github.com/Sirupsen/logrus"
+	"golang.org/x/net/context"
+)
+
+// Log is the structured logger used by the lifecycle package.
+var Log = logrus.New()
+
+// Unit is a long-running component of an application. It should run until
+// ctx is done, then clean up and return.
+type Unit func(ctx context.Context) error
+
+// Run will run all the units at once until ctx is done or any of them
+// returns, at which point the context given to the others is canceled. It
+// will block until every unit has returned and return the first non-nil
+// error.
+func Run(ctx context.Context, units ...Unit) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg       sync.WaitGroup
+		errOnce  sync.Once
+		firstErr error
+	)
+	for _, u := range units {
+		wg.Add(1)
+		go func(u Unit) {
+			defer wg.Done()
+			err := u(ctx)
+			if err != nil {
+				errOnce.Do(func() { firstErr = err })
+			}
+			// one stopping stops them all
+			cancel()
+		}(u)
+	}
+	wg.Wait()
+	return firstErr
+}
+
+// Actor will return the execute and interrupt funcs of the unit for an
+// oklog/run Group. Interrupting cancels the unit's context.
+func Actor(u Unit) (execute func() error, interrupt func(error)) {
+	ctx, cancel := context.WithCancel(context.Background())
+	return func() error {
+			return u(ctx)
+		}, func(error) {
+			cancel()
+		}
+}
+
+// Signal will return a Unit that returns once the process receives any of
+// the given signals, stopping the other units given to Run with it.
+func Signal(sigs ...os.Signal) Unit {
+	return func(ctx context.Context) error {
+		ch := make(chan os.Signal, 1)
+		signal.Notify(ch, sigs...)
+		defer signal.Stop(ch)
+		select {
+		case sig := <-ch:
+			Log.Infof("Received signal %s", sig)
+		case <-ctx.Done():
+		}
+		return nil
+	}
+}
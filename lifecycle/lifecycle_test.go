@@ -0,0 +1,85 @@
+package lifecycle
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+func TestRun(t *testing.T) {
+	fail := errors.New("nope")
+	stopped := make(chan struct{})
+	err := Run(context.Background(),
+		func(ctx context.Context) error {
+			<-ctx.Done()
+			close(stopped)
+			return nil
+		},
+		func(ctx context.Context) error {
+			return fail
+		},
+	)
+	if err != fail {
+		t.Errorf("expected the failing unit's error, got %v", err)
+	}
+	select {
+	case <-stopped:
+	default:
+		t.Error("expected the other unit to be stopped before Run returned")
+	}
+}
+
+func TestRunContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- Run(ctx, func(ctx context.Context) error {
+			<-ctx.Done()
+			return nil
+		})
+	}()
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("expected no error, got %s", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected Run to return once its context was done")
+	}
+}
+
+func TestActor(t *testing.T) {
+	execute, interrupt := Actor(func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	done := make(chan error, 1)
+	go func() {
+		done <- execute()
+	}()
+	interrupt(nil)
+	if err := <-done; err != context.Canceled {
+		t.Errorf("expected the unit's context to be canceled, got %v", err)
+	}
+}
+
+func TestSignal(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- Signal(os.Interrupt)(ctx)
+	}()
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("expected no error, got %s", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected Signal to return once its context was done")
+	}
+}
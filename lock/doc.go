@@ -0,0 +1,31 @@
+/*
+Package lock offers distributed locks for coordinating work across the
+instances of a service, such as the jobs run by the cron package.
+
+A Locker can be backed by conditional writes to a DynamoDB table, keys in a
+single Redis instance, the Redlock algorithm over several independent Redis
+instances or Postgres advisory locks. There is also an in-memory LocalLocker
+for services with a single instance and for tests.
+
+Locks are acquired for an owner with a ttl, after which they expire if not
+released. Obtain will keep a lock renewed until it is released:
+
+	locker := lock.NewRedlock(
+		func() lock.RedisConn { return pool1.Get() },
+		func() lock.RedisConn { return pool2.Get() },
+		func() lock.RedisConn { return pool3.Get() },
+	)
+	lk, err := lock.Obtain(locker, "compaction", owner, 30*time.Second)
+	if err == lock.ErrNotAcquired {
+		// another instance is compacting
+		return nil
+	}
+	defer lk.Release()
+
+	select {
+	case <-lk.Lost():
+		// stop working, another instance took over
+	case <-done:
+	}
+*/
+package lock
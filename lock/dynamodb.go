@@ -0,0 +1,107 @@
+package lock
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+
+	"github.com/NYTimes/gizmo/config"
+)
+
+// DynamoDBLocker is a Locker backed by conditional writes to a DynamoDB
+// table. The table must have a string hash key named 'name'.
+type DynamoDBLocker struct {
+	db    dynamodbiface.DynamoDBAPI
+	table string
+}
+
+// NewDynamoDBLocker will initiate the DynamoDB client for the table in the
+// config. If no credentials are passed in with the config, the locker is
+// instantiated with the AWS_ACCESS_KEY and the AWS_SECRET_KEY environment
+// variables.
+func NewDynamoDBLocker(cfg *config.DynamoDB) (*DynamoDBLocker, error) {
+	if cfg.TableName == "" {
+		return nil, errors.New("dynamodb table name is required")
+	}
+	if cfg.Region == "" {
+		return nil, errors.New("dynamodb region is required")
+	}
+
+	var creds *credentials.Credentials
+	if cfg.AccessKey != "" {
+		creds = credentials.NewStaticCredentials(cfg.AccessKey, cfg.SecretKey, "")
+	} else {
+		creds = credentials.NewEnvCredentials()
+	}
+	return &DynamoDBLocker{
+		db: dynamodb.New(session.New(&aws.Config{
+			Credentials: creds,
+			Region:      &cfg.Region,
+		})),
+		table: cfg.TableName,
+	}, nil
+}
+
+// Acquire will write the lock unless an unexpired lock
+// for another owner already exists.
+func (d *DynamoDBLocker) Acquire(name, owner string, ttl time.Duration) (bool, error) {
+	now := time.Now()
+	_, err := d.db.PutItem(&dynamodb.PutItemInput{
+		TableName: &d.table,
+		Item: map[string]*dynamodb.AttributeValue{
+			"name":    {S: &name},
+			"owner":   {S: &owner},
+			"expires": {N: aws.String(unixMillis(now.Add(ttl)))},
+		},
+		ConditionExpression: aws.String("attribute_not_exists(#name) OR #expires < :now OR #owner = :owner"),
+		ExpressionAttributeNames: map[string]*string{
+			"#name":    aws.String("name"),
+			"#owner":   aws.String("owner"),
+			"#expires": aws.String("expires"),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":now":   {N: aws.String(unixMillis(now))},
+			":owner": {S: &owner},
+		},
+	})
+	if isConditionFailed(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// Release will delete the lock if it is held by the owner.
+func (d *DynamoDBLocker) Release(name, owner string) error {
+	_, err := d.db.DeleteItem(&dynamodb.DeleteItemInput{
+		TableName: &d.table,
+		Key: map[string]*dynamodb.AttributeValue{
+			"name": {S: &name},
+		},
+		ConditionExpression:      aws.String("#owner = :owner"),
+		ExpressionAttributeNames: map[string]*string{"#owner": aws.String("owner")},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":owner": {S: &owner},
+		},
+	})
+	if isConditionFailed(err) {
+		// someone else holds it now
+		return nil
+	}
+	return err
+}
+
+func isConditionFailed(err error) bool {
+	aerr, ok := err.(awserr.Error)
+	return ok && aerr.Code() == "ConditionalCheckFailedException"
+}
+
+func unixMillis(t time.Time) string {
+	return strconv.FormatInt(t.UnixNano()/int64(time.Millisecond), 10)
+}
@@ -0,0 +1,137 @@
+package lock
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrNotAcquired is returned by Obtain if the lock is held by another owner.
+var ErrNotAcquired = errors.New("lock is held by another owner")
+
+// Locker is a distributed lock for coordinating work across the instances
+// of a service. It is used by the cron package so only one instance runs
+// each scheduled job.
+type Locker interface {
+	// Acquire will attempt to take the named lock for the owner. It will
+	// return false if the lock is held by another owner. If it is not
+	// released, the lock will expire after the ttl.
+	Acquire(name, owner string, ttl time.Duration) (bool, error)
+	// Release will give up the named lock if it is held by the owner.
+	Release(name, owner string) error
+}
+
+// Lock is a held lock that is kept from expiring until it is released.
+type Lock struct {
+	locker Locker
+	name   string
+	owner  string
+
+	lost chan struct{}
+	stop chan struct{}
+	done chan struct{}
+}
+
+// Obtain will acquire the named lock for the owner and keep renewing it every
+// third of the ttl until Release is called. If the lock is held by another
+// owner, ErrNotAcquired will be returned.
+//
+//	lk, err := lock.Obtain(locker, "compaction", owner, 30*time.Second)
+//	if err == lock.ErrNotAcquired {
+//		return nil
+//	}
+//	defer lk.Release()
+func Obtain(l Locker, name, owner string, ttl time.Duration) (*Lock, error) {
+	ok, err := l.Acquire(name, owner, ttl)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrNotAcquired
+	}
+	lk := &Lock{
+		locker: l,
+		name:   name,
+		owner:  owner,
+		lost:   make(chan struct{}),
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	go lk.renew(ttl)
+	return lk, nil
+}
+
+func (lk *Lock) renew(ttl time.Duration) {
+	defer close(lk.done)
+	ticker := time.NewTicker(ttl / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-lk.stop:
+			return
+		case <-ticker.C:
+		}
+		ok, err := lk.locker.Acquire(lk.name, lk.owner, ttl)
+		if err != nil {
+			// try again before the lock expires
+			continue
+		}
+		if !ok {
+			close(lk.lost)
+			return
+		}
+	}
+}
+
+// Lost will return a channel that is closed if the lock is taken by
+// another owner, such as after it could not be renewed before expiring.
+func (lk *Lock) Lost() <-chan struct{} {
+	return lk.lost
+}
+
+// Release will stop renewing the lock and give it up.
+func (lk *Lock) Release() error {
+	close(lk.stop)
+	<-lk.done
+	return lk.locker.Release(lk.name, lk.owner)
+}
+
+// LocalLocker is an in-memory Locker for services with a single
+// instance and for tests.
+type LocalLocker struct {
+	mu    sync.Mutex
+	locks map[string]localLock
+}
+
+type localLock struct {
+	owner   string
+	expires time.Time
+}
+
+// NewLocalLocker will return an empty LocalLocker.
+func NewLocalLocker() *LocalLocker {
+	return &LocalLocker{locks: map[string]localLock{}}
+}
+
+// Acquire will take the lock if it is free, expired or already
+// held by the owner.
+func (l *LocalLocker) Acquire(name, owner string, ttl time.Duration) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := time.Now()
+	if lock, ok := l.locks[name]; ok && lock.owner != owner && now.Before(lock.expires) {
+		return false, nil
+	}
+	l.locks[name] = localLock{owner: owner, expires: now.Add(ttl)}
+	return true, nil
+}
+
+// Release will free the lock if it is held by the owner.
+func (l *LocalLocker) Release(name, owner string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if lock, ok := l.locks[name]; ok && lock.owner == owner {
+		delete(l.locks, name)
+	}
+	return nil
+}
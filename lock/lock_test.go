@@ -0,0 +1,345 @@
+package lock
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+// testLocker will run the behavior every Locker should share.
+func testLocker(t *testing.T, l Locker) {
+	ok, err := l.Acquire("job", "a", time.Minute)
+	if err != nil || !ok {
+		t.Fatalf("expected a to acquire a free lock, got %v, %v", ok, err)
+	}
+	if ok, err = l.Acquire("job", "b", time.Minute); err != nil || ok {
+		t.Errorf("expected b to not acquire a held lock, got %v, %v", ok, err)
+	}
+	if ok, err = l.Acquire("other-job", "b", time.Minute); err != nil || !ok {
+		t.Errorf("expected b to acquire a different lock, got %v, %v", ok, err)
+	}
+	if err = l.Release("job", "b"); err != nil {
+		t.Errorf("unexpected error releasing another owner's lock: %s", err)
+	}
+	if ok, _ = l.Acquire("job", "b", time.Minute); ok {
+		t.Error("expected a release by another owner to not free the lock")
+	}
+	if err = l.Release("job", "a"); err != nil {
+		t.Errorf("unexpected error releasing lock: %s", err)
+	}
+	if ok, err = l.Acquire("job", "b", time.Minute); err != nil || !ok {
+		t.Errorf("expected b to acquire a released lock, got %v, %v", ok, err)
+	}
+}
+
+func TestLocalLocker(t *testing.T) {
+	testLocker(t, NewLocalLocker())
+
+	l := NewLocalLocker()
+	l.Acquire("job", "a", time.Millisecond)
+	time.Sleep(2 * time.Millisecond)
+	if ok, _ := l.Acquire("job", "b", time.Minute); !ok {
+		t.Error("expected an expired lock to be acquired")
+	}
+}
+
+// testDynamoDBAPI will evaluate lock conditions against an in-memory
+// table. All other DynamoDBAPI methods will panic.
+type testDynamoDBAPI struct {
+	dynamodbiface.DynamoDBAPI
+
+	mu    sync.Mutex
+	items map[string]map[string]*dynamodb.AttributeValue
+}
+
+var errConditionFailed = awserr.New("ConditionalCheckFailedException", "the conditional request failed", nil)
+
+func (d *testDynamoDBAPI) PutItem(i *dynamodb.PutItemInput) (*dynamodb.PutItemOutput, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	name := *i.Item["name"].S
+	if cur, ok := d.items[name]; ok {
+		expires, _ := strconv.ParseInt(*cur["expires"].N, 10, 64)
+		now, _ := strconv.ParseInt(*i.ExpressionAttributeValues[":now"].N, 10, 64)
+		if expires >= now && *cur["owner"].S != *i.ExpressionAttributeValues[":owner"].S {
+			return nil, errConditionFailed
+		}
+	}
+	d.items[name] = i.Item
+	return &dynamodb.PutItemOutput{}, nil
+}
+
+func (d *testDynamoDBAPI) DeleteItem(i *dynamodb.DeleteItemInput) (*dynamodb.DeleteItemOutput, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	name := *i.Key["name"].S
+	cur, ok := d.items[name]
+	if !ok || *cur["owner"].S != *i.ExpressionAttributeValues[":owner"].S {
+		return nil, errConditionFailed
+	}
+	delete(d.items, name)
+	return &dynamodb.DeleteItemOutput{}, nil
+}
+
+func TestDynamoDBLocker(t *testing.T) {
+	testLocker(t, &DynamoDBLocker{
+		db:    &testDynamoDBAPI{items: map[string]map[string]*dynamodb.AttributeValue{}},
+		table: "locks",
+	})
+}
+
+// testRedisConn implements the commands used by the RedisLocker,
+// without expiry, against a shared map.
+type testRedisConn struct {
+	mu   *sync.Mutex
+	keys map[string]string
+}
+
+func (c testRedisConn) Do(cmd string, args ...interface{}) (interface{}, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	key, _ := args[0].(string)
+	switch cmd {
+	case "SET":
+		if _, ok := c.keys[key]; ok {
+			return nil, nil
+		}
+		c.keys[key] = args[1].(string)
+		return "OK", nil
+	case "GET":
+		if v, ok := c.keys[key]; ok {
+			return []byte(v), nil
+		}
+		return nil, nil
+	case "PEXPIRE":
+		return int64(1), nil
+	case "EVAL":
+		key = args[2].(string)
+		if c.keys[key] == args[3].(string) {
+			delete(c.keys, key)
+			return int64(1), nil
+		}
+		return int64(0), nil
+	}
+	return nil, errors.New("unexpected command " + cmd)
+}
+
+func (c testRedisConn) Close() error {
+	return nil
+}
+
+func TestRedisLocker(t *testing.T) {
+	conn := testRedisConn{mu: &sync.Mutex{}, keys: map[string]string{}}
+	l := NewRedisLocker(func() RedisConn { return conn })
+	testLocker(t, l)
+
+	if _, ok := conn.keys[DefaultRedisKeyPrefix+"job"]; !ok {
+		t.Errorf("expected lock to be stored under the key prefix, got %v", conn.keys)
+	}
+	if ok, _ := l.Acquire("job", "b", time.Minute); !ok {
+		t.Error("expected the owner to be able to re-acquire its lock")
+	}
+}
+
+// testPGDriver is a database/sql driver that answers advisory lock queries
+// from an in-memory set of held keys, released when a transaction ends.
+type testPGDriver struct {
+	mu   sync.Mutex
+	held map[int64]bool
+}
+
+func (d *testPGDriver) Open(string) (driver.Conn, error) {
+	return &testPGConn{d: d}, nil
+}
+
+type testPGConn struct {
+	d    *testPGDriver
+	keys []int64
+}
+
+func (c *testPGConn) Prepare(query string) (driver.Stmt, error) { return &testPGStmt{c}, nil }
+func (c *testPGConn) Close() error                              { return nil }
+func (c *testPGConn) Begin() (driver.Tx, error)                 { return c, nil }
+func (c *testPGConn) Commit() error                             { return c.end() }
+func (c *testPGConn) Rollback() error                           { return c.end() }
+
+func (c *testPGConn) end() error {
+	c.d.mu.Lock()
+	defer c.d.mu.Unlock()
+	for _, k := range c.keys {
+		delete(c.d.held, k)
+	}
+	c.keys = nil
+	return nil
+}
+
+type testPGStmt struct{ c *testPGConn }
+
+func (s *testPGStmt) Close() error  { return nil }
+func (s *testPGStmt) NumInput() int { return 1 }
+func (s *testPGStmt) Exec([]driver.Value) (driver.Result, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (s *testPGStmt) Query(args []driver.Value) (driver.Rows, error) {
+	key := args[0].(int64)
+	d := s.c.d
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	locked := !d.held[key]
+	if locked {
+		d.held[key] = true
+		s.c.keys = append(s.c.keys, key)
+	}
+	return &testPGRows{value: locked}, nil
+}
+
+type testPGRows struct {
+	value bool
+	done  bool
+}
+
+func (r *testPGRows) Columns() []string { return []string{"pg_try_advisory_xact_lock"} }
+func (r *testPGRows) Close() error      { return nil }
+func (r *testPGRows) Next(dest []driver.Value) error {
+	if r.done {
+		return io.EOF
+	}
+	r.done = true
+	dest[0] = r.value
+	return nil
+}
+
+func init() {
+	sql.Register("lock-test-pg", &testPGDriver{held: map[int64]bool{}})
+}
+
+func TestPostgresLocker(t *testing.T) {
+	db, err := sql.Open("lock-test-pg", "")
+	if err != nil {
+		t.Fatal("unable to open test db: ", err)
+	}
+	defer db.Close()
+
+	// advisory locks are per session, so each owner needs its own locker
+	a, b := NewPostgresLocker(db), NewPostgresLocker(db)
+	if ok, err := a.Acquire("job", "a", time.Minute); err != nil || !ok {
+		t.Fatalf("expected a to acquire a free lock, got %v, %v", ok, err)
+	}
+	if ok, err := b.Acquire("job", "b", time.Minute); err != nil || ok {
+		t.Errorf("expected b to not acquire a held lock, got %v, %v", ok, err)
+	}
+	if ok, _ := a.Acquire("job", "a", time.Minute); !ok {
+		t.Error("expected a to re-acquire its own lock")
+	}
+	if err := a.Release("job", "a"); err != nil {
+		t.Errorf("unexpected error releasing lock: %s", err)
+	}
+	if ok, err := b.Acquire("job", "b", time.Minute); err != nil || !ok {
+		t.Errorf("expected b to acquire a released lock, got %v, %v", ok, err)
+	}
+	b.Release("job", "b")
+}
+
+// downRedisConn fails every command like an unreachable instance.
+type downRedisConn struct{}
+
+func (downRedisConn) Do(string, ...interface{}) (interface{}, error) {
+	return nil, errors.New("connection refused")
+}
+
+func (downRedisConn) Close() error {
+	return nil
+}
+
+func TestRedlock(t *testing.T) {
+	var conns []testRedisConn
+	var funcs []func() RedisConn
+	for i := 0; i < 3; i++ {
+		conn := testRedisConn{mu: &sync.Mutex{}, keys: map[string]string{}}
+		conns = append(conns, conn)
+		funcs = append(funcs, func() RedisConn { return conn })
+	}
+	testLocker(t, NewRedlock(funcs...))
+
+	// a minority of instances being down should not matter
+	l := NewRedlock(funcs[0], funcs[1], func() RedisConn { return downRedisConn{} })
+	testLocker(t, l)
+
+	// nor should a minority of instances held by another owner
+	conns[0].keys[DefaultRedisKeyPrefix+"held"] = "b"
+	if ok, err := NewRedlock(funcs...).Acquire("held", "a", time.Minute); err != nil || !ok {
+		t.Errorf("expected a to acquire a majority, got %v, %v", ok, err)
+	}
+
+	// but a majority should, and any partial locks should be released
+	conns[1].keys[DefaultRedisKeyPrefix+"contended"] = "b"
+	conns[2].keys[DefaultRedisKeyPrefix+"contended"] = "b"
+	if ok, err := NewRedlock(funcs...).Acquire("contended", "a", time.Minute); err != nil || ok {
+		t.Errorf("expected a to not acquire a minority, got %v, %v", ok, err)
+	}
+	if _, ok := conns[0].keys[DefaultRedisKeyPrefix+"contended"]; ok {
+		t.Error("expected the partially acquired lock to be released")
+	}
+
+	down := func() RedisConn { return downRedisConn{} }
+	if ok, err := NewRedlock(down, down, funcs[0]).Acquire("job", "c", time.Minute); err == nil || ok {
+		t.Errorf("expected an error with a majority of instances down, got %v, %v", ok, err)
+	}
+}
+
+// stealableLocker is a LocalLocker whose locks can be handed to
+// another owner.
+type stealableLocker struct {
+	*LocalLocker
+}
+
+func (s stealableLocker) steal(name, owner string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.locks[name] = localLock{owner: owner, expires: time.Now().Add(time.Hour)}
+}
+
+func TestObtain(t *testing.T) {
+	l := stealableLocker{NewLocalLocker()}
+	lk, err := Obtain(l, "job", "a", 30*time.Millisecond)
+	if err != nil {
+		t.Fatal("unexpected error obtaining lock: ", err)
+	}
+	if _, err = Obtain(l, "job", "b", time.Minute); err != ErrNotAcquired {
+		t.Errorf("expected ErrNotAcquired for a held lock, got %v", err)
+	}
+
+	// it should be renewed past its ttl
+	time.Sleep(60 * time.Millisecond)
+	if ok, _ := l.Acquire("job", "b", time.Minute); ok {
+		t.Error("expected the lock to be renewed")
+	}
+	if err = lk.Release(); err != nil {
+		t.Error("unexpected error releasing lock: ", err)
+	}
+	if ok, _ := l.Acquire("job", "b", time.Minute); !ok {
+		t.Error("expected the lock to be released")
+	}
+
+	lk, err = Obtain(l, "other-job", "a", 30*time.Millisecond)
+	if err != nil {
+		t.Fatal("unexpected error obtaining lock: ", err)
+	}
+	l.steal("other-job", "b")
+	select {
+	case <-lk.Lost():
+	case <-time.After(time.Second):
+		t.Error("expected the lock to be lost")
+	}
+	lk.Release()
+}
@@ -0,0 +1,67 @@
+package lock
+
+import (
+	"database/sql"
+	"hash/fnv"
+	"sync"
+	"time"
+)
+
+// PostgresLocker is a Locker backed by Postgres transaction level advisory
+// locks. Each held lock keeps a transaction, and so a connection, open until
+// it is released. Locks are not given a ttl as Postgres will release them if
+// the holder's connection is lost.
+type PostgresLocker struct {
+	db *sql.DB
+
+	mu  sync.Mutex
+	txs map[string]*sql.Tx
+}
+
+// NewPostgresLocker will return a Locker using the given database.
+func NewPostgresLocker(db *sql.DB) *PostgresLocker {
+	return &PostgresLocker{db: db, txs: map[string]*sql.Tx{}}
+}
+
+// Acquire will attempt to take the advisory lock keyed by a hash of the name.
+func (p *PostgresLocker) Acquire(name, owner string, ttl time.Duration) (bool, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, ok := p.txs[name]; ok {
+		// advisory locks are per session and we already hold it
+		return true, nil
+	}
+
+	tx, err := p.db.Begin()
+	if err != nil {
+		return false, err
+	}
+	var locked bool
+	if err = tx.QueryRow("SELECT pg_try_advisory_xact_lock($1)", advisoryKey(name)).Scan(&locked); err != nil || !locked {
+		if rerr := tx.Rollback(); err == nil {
+			err = rerr
+		}
+		return false, err
+	}
+	p.txs[name] = tx
+	return true, nil
+}
+
+// Release will end the transaction holding the lock, if any.
+func (p *PostgresLocker) Release(name, owner string) error {
+	p.mu.Lock()
+	tx, ok := p.txs[name]
+	delete(p.txs, name)
+	p.mu.Unlock()
+	if !ok {
+		return nil
+	}
+	return tx.Commit()
+}
+
+// advisoryKey will hash the lock name to a Postgres advisory lock key.
+func advisoryKey(name string) int64 {
+	h := fnv.New64a()
+	h.Write([]byte(name))
+	return int64(h.Sum64())
+}
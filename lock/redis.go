@@ -0,0 +1,153 @@
+package lock
+
+import "time"
+
+// RedisConn is the subset of a Redis connection used by the
+// RedisLocker and Redlock. It is satisfied by redigo's redis.Conn.
+type RedisConn interface {
+	Do(cmd string, args ...interface{}) (interface{}, error)
+	Close() error
+}
+
+// DefaultRedisKeyPrefix is prepended to the name of each lock
+// stored by a RedisLocker or Redlock.
+const DefaultRedisKeyPrefix = "gizmo:lock:"
+
+// releaseScript will delete a lock only if it is held by the owner.
+const releaseScript = `if redis.call("get", KEYS[1]) == ARGV[1] then return redis.call("del", KEYS[1]) else return 0 end`
+
+// RedisLocker is a Locker backed by Redis keys with an expiry.
+type RedisLocker struct {
+	// KeyPrefix will override the DefaultRedisKeyPrefix.
+	KeyPrefix string
+
+	conn func() RedisConn
+}
+
+// NewRedisLocker will return a Locker that uses the given func to get a
+// connection for each operation, such as a redigo Pool's Get method:
+//
+//	lock.NewRedisLocker(func() lock.RedisConn { return pool.Get() })
+func NewRedisLocker(conn func() RedisConn) *RedisLocker {
+	return &RedisLocker{KeyPrefix: DefaultRedisKeyPrefix, conn: conn}
+}
+
+// Acquire will set the lock's key if it does not exist or
+// is already held by the owner.
+func (r *RedisLocker) Acquire(name, owner string, ttl time.Duration) (bool, error) {
+	return redisAcquire(r.conn, r.KeyPrefix+name, owner, ttl)
+}
+
+// Release will delete the lock's key if it is held by the owner.
+func (r *RedisLocker) Release(name, owner string) error {
+	return redisRelease(r.conn, r.KeyPrefix+name, owner)
+}
+
+// Redlock is a Locker that holds a lock on a majority of independent Redis
+// instances, using the Redlock algorithm, so it stays available and safe
+// while a minority of the instances are down.
+type Redlock struct {
+	// KeyPrefix will override the DefaultRedisKeyPrefix.
+	KeyPrefix string
+	// ClockDrift is the fraction of the ttl allowed for clock drift between
+	// instances when deciding if an acquired lock is still valid. Defaults
+	// to 0.01.
+	ClockDrift float64
+
+	conns []func() RedisConn
+}
+
+// NewRedlock will return a Locker for the Redis instances behind each
+// of the given connection funcs. An odd number, usually 3 or 5, of
+// instances is recommended.
+func NewRedlock(conns ...func() RedisConn) *Redlock {
+	return &Redlock{KeyPrefix: DefaultRedisKeyPrefix, ClockDrift: 0.01, conns: conns}
+}
+
+// Acquire will attempt to take the lock on every instance. It will only
+// return true if a majority of the instances were locked with time left
+// before the ttl expires. Otherwise, any instances that were locked will
+// be released.
+func (r *Redlock) Acquire(name, owner string, ttl time.Duration) (bool, error) {
+	key := r.KeyPrefix + name
+	start := time.Now()
+	var (
+		locked int
+		lerr   error
+	)
+	for _, conn := range r.conns {
+		ok, err := redisAcquire(conn, key, owner, ttl)
+		if err != nil {
+			lerr = err
+			continue
+		}
+		if ok {
+			locked++
+		}
+	}
+	drift := time.Duration(float64(ttl)*r.ClockDrift) + 2*time.Millisecond
+	if locked > len(r.conns)/2 && time.Since(start)+drift < ttl {
+		return true, nil
+	}
+	if err := r.Release(name, owner); err != nil && lerr == nil {
+		lerr = err
+	}
+	if locked > 0 {
+		// the instances we reached were fine, we just lost the race
+		lerr = nil
+	}
+	return false, lerr
+}
+
+// Release will delete the lock's key on every instance it is held by
+// the owner. The first error encountered will be returned.
+func (r *Redlock) Release(name, owner string) error {
+	var rerr error
+	for _, conn := range r.conns {
+		if err := redisRelease(conn, r.KeyPrefix+name, owner); err != nil && rerr == nil {
+			rerr = err
+		}
+	}
+	return rerr
+}
+
+// redisAcquire will set the key if it does not exist or
+// extend it if it is already held by the owner.
+func redisAcquire(conn func() RedisConn, key, owner string, ttl time.Duration) (bool, error) {
+	c := conn()
+	defer c.Close()
+	ms := int64(ttl / time.Millisecond)
+	reply, err := c.Do("SET", key, owner, "NX", "PX", ms)
+	if err != nil {
+		return false, err
+	}
+	if reply != nil {
+		return true, nil
+	}
+	// extend it if we already hold it
+	current, err := c.Do("GET", key)
+	if err != nil || !replyEquals(current, owner) {
+		return false, err
+	}
+	_, err = c.Do("PEXPIRE", key, ms)
+	return err == nil, err
+}
+
+// redisRelease will delete the key if it is held by the owner.
+func redisRelease(conn func() RedisConn, key, owner string) error {
+	c := conn()
+	defer c.Close()
+	_, err := c.Do("EVAL", releaseScript, 1, key, owner)
+	return err
+}
+
+// replyEquals will compare a bulk string reply to s.
+func replyEquals(reply interface{}, s string) bool {
+	switch v := reply.(type) {
+	case []byte:
+		return string(v) == s
+	case string:
+		return v == s
+	}
+	return false
+}
@@ -0,0 +1,210 @@
+package metrics
+
+import (
+	"encoding/json"
+	"io"
+	"sort"
+	"sync"
+	"time"
+)
+
+// maxEMFMetrics is the max number of metrics CloudWatch will
+// accept in a single embedded metric format document.
+const maxEMFMetrics = 100
+
+// CloudWatchEMF is a Provider that buffers metrics and writes them in the
+// CloudWatch embedded metric format, which the CloudWatch agent and Lambda
+// will turn into custom metrics. Counters and histograms are reset after
+// each Flush while gauges keep their last value.
+type CloudWatchEMF struct {
+	w          io.Writer
+	namespace  string
+	dimensions map[string]string
+
+	mu         sync.Mutex
+	counters   map[string]float64
+	gauges     map[string]float64
+	histograms map[string][]float64
+
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+// NewCloudWatchEMF will return a Provider that writes metrics under the given
+// namespace and dimensions to w. If interval is greater than 0, metrics will be
+// flushed on the interval until Stop is called. Otherwise, Flush must be called.
+func NewCloudWatchEMF(w io.Writer, namespace string, dimensions map[string]string, interval time.Duration) *CloudWatchEMF {
+	c := &CloudWatchEMF{
+		w:          w,
+		namespace:  namespace,
+		dimensions: dimensions,
+		counters:   map[string]float64{},
+		gauges:     map[string]float64{},
+		histograms: map[string][]float64{},
+		stop:       make(chan struct{}),
+	}
+	if interval > 0 {
+		go func() {
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-c.stop:
+					return
+				case <-ticker.C:
+					if err := c.Flush(); err != nil {
+						Log.Warn("unable to flush CloudWatch metrics: ", err)
+					}
+				}
+			}
+		}()
+	}
+	return c
+}
+
+// Counter will return the counter with the given name.
+func (c *CloudWatchEMF) Counter(name string) Counter {
+	return emfCounter{c: c, name: name}
+}
+
+// Gauge will return the gauge with the given name.
+func (c *CloudWatchEMF) Gauge(name string) Gauge {
+	return emfGauge{c: c, name: name}
+}
+
+// Histogram will return the histogram with the given name.
+func (c *CloudWatchEMF) Histogram(name string) Histogram {
+	return emfHistogram{c: c, name: name}
+}
+
+// Flush will write all buffered metrics, with up to 100 metrics per line.
+func (c *CloudWatchEMF) Flush() error {
+	c.mu.Lock()
+	values := make(map[string]interface{}, len(c.counters)+len(c.gauges)+len(c.histograms))
+	for name, v := range c.counters {
+		values[name] = v
+	}
+	for name, v := range c.gauges {
+		values[name] = v
+	}
+	for name, vs := range c.histograms {
+		values[name] = vs
+	}
+	c.counters = map[string]float64{}
+	c.histograms = map[string][]float64{}
+	c.mu.Unlock()
+
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for len(names) > 0 {
+		n := len(names)
+		if n > maxEMFMetrics {
+			n = maxEMFMetrics
+		}
+		if err := c.write(names[:n], values); err != nil {
+			return err
+		}
+		names = names[n:]
+	}
+	return nil
+}
+
+type emfMetric struct {
+	Name string `json:"Name"`
+}
+
+type emfDirective struct {
+	Namespace  string      `json:"Namespace"`
+	Dimensions [][]string  `json:"Dimensions"`
+	Metrics    []emfMetric `json:"Metrics"`
+}
+
+type emfMetadata struct {
+	Timestamp         int64          `json:"Timestamp"`
+	CloudWatchMetrics []emfDirective `json:"CloudWatchMetrics"`
+}
+
+func (c *CloudWatchEMF) write(names []string, values map[string]interface{}) error {
+	doc := make(map[string]interface{}, len(names)+len(c.dimensions)+1)
+	dims := make([]string, 0, len(c.dimensions))
+	for k, v := range c.dimensions {
+		dims = append(dims, k)
+		doc[k] = v
+	}
+	sort.Strings(dims)
+
+	directive := emfDirective{
+		Namespace:  c.namespace,
+		Dimensions: [][]string{dims},
+	}
+	for _, name := range names {
+		directive.Metrics = append(directive.Metrics, emfMetric{Name: name})
+		doc[name] = values[name]
+	}
+	doc["_aws"] = emfMetadata{
+		Timestamp:         time.Now().UnixNano() / int64(time.Millisecond),
+		CloudWatchMetrics: []emfDirective{directive},
+	}
+
+	line, err := json.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	_, err = c.w.Write(append(line, '\n'))
+	return err
+}
+
+// Stop will stop flushing on the interval and flush any buffered metrics.
+func (c *CloudWatchEMF) Stop() error {
+	c.stopOnce.Do(func() {
+		close(c.stop)
+	})
+	return c.Flush()
+}
+
+type emfCounter struct {
+	c    *CloudWatchEMF
+	name string
+}
+
+func (e emfCounter) Add(delta float64) {
+	e.c.mu.Lock()
+	e.c.counters[e.name] += delta
+	e.c.mu.Unlock()
+}
+
+type emfGauge struct {
+	c    *CloudWatchEMF
+	name string
+}
+
+func (e emfGauge) Set(value float64) {
+	e.c.mu.Lock()
+	e.c.gauges[e.name] = value
+	e.c.mu.Unlock()
+}
+
+func (e emfGauge) Add(delta float64) {
+	e.c.mu.Lock()
+	e.c.gauges[e.name] += delta
+	e.c.mu.Unlock()
+}
+
+type emfHistogram struct {
+	c    *CloudWatchEMF
+	name string
+}
+
+func (e emfHistogram) Observe(value float64) {
+	e.c.mu.Lock()
+	defer e.c.mu.Unlock()
+	// CloudWatch only accepts 100 values per metric in each document, so
+	// further observations are dropped until the next flush
+	if vs := e.c.histograms[e.name]; len(vs) < maxEMFMetrics {
+		e.c.histograms[e.name] = append(vs, value)
+	}
+}
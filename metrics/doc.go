@@ -0,0 +1,26 @@
+/*
+Package metrics offers a small counter, gauge and histogram API with providers for
+Prometheus, StatsD, DogStatsD and the CloudWatch embedded metric format, so services
+can choose their metrics backend via config.
+
+	provider, err := metrics.NewProviderFromConfig(cfg.Metrics)
+
+	requests := provider.Counter("cats.requests")
+	requests.Add(1)
+
+	latency := provider.Histogram("cats.latency")
+	latency.Observe(time.Since(start).Seconds())
+
+The Prometheus provider is also an http.Handler for serving its metrics:
+
+	if p, ok := provider.(http.Handler); ok {
+		mux.Handle("GET", "/metrics", p)
+	}
+
+Gizmo's server and httpclient packages instrument themselves with a go-metrics
+Registry, which can be reported to any Provider:
+
+	cfg.Server.MetricsRegistry = gometrics.NewRegistry()
+	stop := metrics.ReportRegistry(cfg.Server.MetricsRegistry, provider, cfg.Metrics.Interval)
+*/
+package metrics
@@ -0,0 +1,95 @@
+package metrics
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+
+	"github.com/NYTimes/gizmo/config"
+)
+
+// Log is the structured logger used throughout the package.
+var Log = logrus.New()
+
+// DefaultInterval is how often buffered metrics will be flushed if
+// no Interval is configured.
+var DefaultInterval = time.Minute
+
+// Provider is the interface for creating metrics for a particular backend.
+// Metrics with the same name will share the same value.
+type Provider interface {
+	Counter(name string) Counter
+	Gauge(name string) Gauge
+	Histogram(name string) Histogram
+}
+
+// Counter is a metric that only goes up.
+type Counter interface {
+	Add(delta float64)
+}
+
+// Gauge is a metric that can be set to any value.
+type Gauge interface {
+	Set(value float64)
+	Add(delta float64)
+}
+
+// Histogram is a metric that tracks the distribution of observed values.
+type Histogram interface {
+	Observe(value float64)
+}
+
+// NewProviderFromConfig will return the Provider described by the config. A
+// Prometheus provider will also be an http.Handler for serving its metrics
+// and a CloudWatch provider will flush to stdout on the config's Interval.
+func NewProviderFromConfig(cfg *config.Metrics) (Provider, error) {
+	if cfg == nil {
+		return Discard, nil
+	}
+	interval := cfg.Interval
+	if interval == 0 {
+		interval = DefaultInterval
+	}
+	switch strings.ToLower(cfg.Provider) {
+	case "", "discard":
+		return Discard, nil
+	case "prometheus":
+		return NewPrometheus(cfg.Namespace), nil
+	case "statsd":
+		return NewStatsD(cfg.StatsDAddr, cfg.Namespace)
+	case "dogstatsd":
+		return NewDogStatsD(cfg.StatsDAddr, cfg.Namespace, cfg.Tags)
+	case "cloudwatch":
+		return NewCloudWatchEMF(os.Stdout, cfg.Namespace, parseTags(cfg.Tags), interval), nil
+	}
+	return nil, fmt.Errorf("unknown metrics provider: %s", cfg.Provider)
+}
+
+// Discard is a Provider that drops all metrics.
+var Discard Provider = discard{}
+
+type discard struct{}
+
+func (discard) Counter(string) Counter     { return discard{} }
+func (discard) Gauge(string) Gauge         { return discard{} }
+func (discard) Histogram(string) Histogram { return discard{} }
+func (discard) Add(float64)                {}
+func (discard) Set(float64)                {}
+func (discard) Observe(float64)            {}
+
+// parseTags will split 'key:value' tags into a map.
+func parseTags(tags []string) map[string]string {
+	m := make(map[string]string, len(tags))
+	for _, tag := range tags {
+		kv := strings.SplitN(tag, ":", 2)
+		if len(kv) == 2 {
+			m[kv[0]] = kv[1]
+		} else {
+			m[kv[0]] = ""
+		}
+	}
+	return m
+}
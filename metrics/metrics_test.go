@@ -0,0 +1,239 @@
+package metrics
+
+import (
+	"bytes"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	gometrics "github.com/rcrowley/go-metrics"
+
+	"github.com/NYTimes/gizmo/config"
+)
+
+func TestPrometheus(t *testing.T) {
+	p := NewPrometheus("app")
+	p.Buckets = []float64{0.1, 1}
+
+	p.Counter("cats.requests").Add(2)
+	p.Counter("cats.requests").Add(1)
+	p.Gauge("cats-in-flight").Set(5)
+	p.Gauge("cats-in-flight").Add(-2)
+	h := p.Histogram("cats.latency")
+	h.Observe(0.05)
+	h.Observe(0.5)
+	h.Observe(5)
+
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("GET", "/metrics", nil)
+	p.ServeHTTP(w, r)
+
+	want := `# TYPE app_cats_requests counter
+app_cats_requests 3
+# TYPE app_cats_in_flight gauge
+app_cats_in_flight 3
+# TYPE app_cats_latency histogram
+app_cats_latency_bucket{le="0.1"} 1
+app_cats_latency_bucket{le="1"} 2
+app_cats_latency_bucket{le="+Inf"} 3
+app_cats_latency_sum 5.55
+app_cats_latency_count 3
+`
+	if got := w.Body.String(); got != want {
+		t.Errorf("expected metrics:\n%s\ngot:\n%s", want, got)
+	}
+}
+
+func TestStatsD(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal("unable to listen: ", err)
+	}
+	defer conn.Close()
+
+	tests := []struct {
+		dog bool
+
+		want []string
+	}{
+		{false, []string{"app.hits:1|c", "app.temp:-2|g", "app.temp:+1.5|g", "app.latency:12|ms"}},
+		{true, []string{"app.hits:1|c|#env:test", "app.temp:-2|g|#env:test", "app.temp:+1.5|g|#env:test", "app.latency:12|h|#env:test"}},
+	}
+
+	for _, test := range tests {
+		var s *StatsD
+		if test.dog {
+			s, err = NewDogStatsD(conn.LocalAddr().String(), "app", []string{"env:test"})
+		} else {
+			s, err = NewStatsD(conn.LocalAddr().String(), "app")
+		}
+		if err != nil {
+			t.Fatal("unable to create provider: ", err)
+		}
+		s.Counter("hits").Add(1)
+		s.Gauge("temp").Add(-2)
+		s.Gauge("temp").Add(1.5)
+		s.Histogram("latency").Observe(12)
+		s.Close()
+
+		var got []string
+		buf := make([]byte, 512)
+		for range test.want {
+			conn.SetReadDeadline(time.Now().Add(time.Second))
+			n, _, err := conn.ReadFrom(buf)
+			if err != nil {
+				t.Fatal("unable to read metric: ", err)
+			}
+			got = append(got, string(buf[:n]))
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("expected %#v, got %#v", test.want, got)
+		}
+	}
+}
+
+func TestCloudWatchEMF(t *testing.T) {
+	var buf bytes.Buffer
+	c := NewCloudWatchEMF(&buf, "Cats", map[string]string{"Service": "cats"}, 0)
+	c.Counter("requests").Add(2)
+	c.Counter("requests").Add(1)
+	c.Gauge("lag").Set(7)
+	c.Histogram("latency").Observe(10)
+	c.Histogram("latency").Observe(20)
+	if err := c.Flush(); err != nil {
+		t.Fatal("unable to flush: ", err)
+	}
+
+	var doc struct {
+		AWS struct {
+			Timestamp         int64
+			CloudWatchMetrics []struct {
+				Namespace  string
+				Dimensions [][]string
+				Metrics    []struct{ Name string }
+			}
+		} `json:"_aws"`
+		Service  string
+		Requests float64   `json:"requests"`
+		Lag      float64   `json:"lag"`
+		Latency  []float64 `json:"latency"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatalf("unable to decode EMF document %q: %s", buf.String(), err)
+	}
+	if len(doc.AWS.CloudWatchMetrics) != 1 {
+		t.Fatalf("expected 1 metric directive, got %d", len(doc.AWS.CloudWatchMetrics))
+	}
+	directive := doc.AWS.CloudWatchMetrics[0]
+	if directive.Namespace != "Cats" {
+		t.Errorf("expected namespace 'Cats', got '%s'", directive.Namespace)
+	}
+	if !reflect.DeepEqual(directive.Dimensions, [][]string{{"Service"}}) {
+		t.Errorf("expected Service dimension, got %#v", directive.Dimensions)
+	}
+	if len(directive.Metrics) != 3 {
+		t.Errorf("expected 3 metrics, got %d", len(directive.Metrics))
+	}
+	if doc.Service != "cats" || doc.Requests != 3 || doc.Lag != 7 ||
+		!reflect.DeepEqual(doc.Latency, []float64{10, 20}) {
+		t.Errorf("unexpected metric values: %s", buf.String())
+	}
+
+	// counters and histograms reset, gauges do not
+	buf.Reset()
+	if err := c.Stop(); err != nil {
+		t.Fatal("unable to flush: ", err)
+	}
+	if got := buf.String(); !strings.Contains(got, `"lag":7`) || strings.Contains(got, "requests") {
+		t.Errorf("expected only the gauge to be flushed again, got %s", got)
+	}
+}
+
+type recordingProvider struct {
+	values map[string]float64
+}
+
+func (p *recordingProvider) Counter(name string) Counter     { return recorder{p, name, true} }
+func (p *recordingProvider) Gauge(name string) Gauge         { return recorder{p, name, false} }
+func (p *recordingProvider) Histogram(name string) Histogram { return recorder{p, name, false} }
+
+type recorder struct {
+	p     *recordingProvider
+	name  string
+	count bool
+}
+
+func (r recorder) Add(delta float64)     { r.p.values[r.name] += delta }
+func (r recorder) Set(value float64)     { r.p.values[r.name] = value }
+func (r recorder) Observe(value float64) { r.p.values[r.name] = value }
+
+func TestReportRegistry(t *testing.T) {
+	registry := gometrics.NewRegistry()
+	counter := gometrics.GetOrRegisterCounter("PANIC", registry)
+	gometrics.GetOrRegisterGauge("requests.INFLIGHT", registry).Update(4)
+	timer := gometrics.GetOrRegisterTimer("routes.cats-GET.DURATION", registry)
+
+	p := &recordingProvider{values: map[string]float64{}}
+	rep := &registryReporter{registry: registry, provider: p, counts: map[string]int64{}}
+
+	counter.Inc(2)
+	timer.Update(10 * time.Millisecond)
+	rep.report()
+	counter.Inc(3)
+	timer.Update(30 * time.Millisecond)
+	rep.report()
+
+	want := map[string]float64{
+		"PANIC":                          5,
+		"requests.INFLIGHT":              4,
+		"routes.cats-GET.DURATION.count": 2,
+		"routes.cats-GET.DURATION.mean":  20,
+		"routes.cats-GET.DURATION.p50":   20,
+		"routes.cats-GET.DURATION.p95":   30,
+		"routes.cats-GET.DURATION.p99":   30,
+	}
+	if !reflect.DeepEqual(p.values, want) {
+		t.Errorf("expected %v, got %v", want, p.values)
+	}
+}
+
+func TestNewProviderFromConfig(t *testing.T) {
+	tests := []struct {
+		cfg *config.Metrics
+
+		want    interface{}
+		wantErr bool
+	}{
+		{nil, Discard, false},
+		{&config.Metrics{Provider: "prometheus"}, &Prometheus{}, false},
+		{&config.Metrics{Provider: "statsd", StatsDAddr: "127.0.0.1:8125"}, &StatsD{}, false},
+		{&config.Metrics{Provider: "dogstatsd", StatsDAddr: "127.0.0.1:8125"}, &StatsD{}, false},
+		{&config.Metrics{Provider: "cloudwatch"}, &CloudWatchEMF{}, false},
+		{&config.Metrics{Provider: "graphite"}, nil, true},
+	}
+
+	for _, test := range tests {
+		got, err := NewProviderFromConfig(test.cfg)
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("expected an error for %#v, got none", test.cfg)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("unexpected error for %#v: %s", test.cfg, err)
+			continue
+		}
+		if reflect.TypeOf(got) != reflect.TypeOf(test.want) {
+			t.Errorf("expected a %T, got %T", test.want, got)
+		}
+		if c, ok := got.(*CloudWatchEMF); ok {
+			c.Stop()
+		}
+	}
+}
@@ -0,0 +1,200 @@
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// DefaultBuckets are the histogram buckets used by a Prometheus
+// provider if none are given.
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Prometheus is a Provider that keeps metrics in memory and serves them
+// in the Prometheus text exposition format.
+type Prometheus struct {
+	// Buckets are the upper bounds of all histogram buckets.
+	Buckets []float64
+
+	namespace string
+
+	mu         sync.Mutex
+	counters   map[string]*promValue
+	gauges     map[string]*promValue
+	histograms map[string]*promHistogram
+}
+
+// NewPrometheus will return a Prometheus provider that prefixes all
+// metric names with the given namespace.
+func NewPrometheus(namespace string) *Prometheus {
+	return &Prometheus{
+		Buckets:    DefaultBuckets,
+		namespace:  namespace,
+		counters:   map[string]*promValue{},
+		gauges:     map[string]*promValue{},
+		histograms: map[string]*promHistogram{},
+	}
+}
+
+// Counter will return the counter with the given name.
+func (p *Prometheus) Counter(name string) Counter {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	name = p.name(name)
+	c, ok := p.counters[name]
+	if !ok {
+		c = &promValue{}
+		p.counters[name] = c
+	}
+	return c
+}
+
+// Gauge will return the gauge with the given name.
+func (p *Prometheus) Gauge(name string) Gauge {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	name = p.name(name)
+	g, ok := p.gauges[name]
+	if !ok {
+		g = &promValue{}
+		p.gauges[name] = g
+	}
+	return g
+}
+
+// Histogram will return the histogram with the given name.
+func (p *Prometheus) Histogram(name string) Histogram {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	name = p.name(name)
+	h, ok := p.histograms[name]
+	if !ok {
+		h = &promHistogram{
+			buckets: p.Buckets,
+			counts:  make([]uint64, len(p.Buckets)),
+		}
+		p.histograms[name] = h
+	}
+	return h
+}
+
+// ServeHTTP will respond with all metrics in the Prometheus text format.
+func (p *Prometheus) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var buf bytes.Buffer
+	p.mu.Lock()
+	for _, name := range sortedKeys(p.counters) {
+		fmt.Fprintf(&buf, "# TYPE %s counter\n%s %s\n", name, name, formatFloat(p.counters[name].get()))
+	}
+	for _, name := range sortedKeys(p.gauges) {
+		fmt.Fprintf(&buf, "# TYPE %s gauge\n%s %s\n", name, name, formatFloat(p.gauges[name].get()))
+	}
+	hnames := make([]string, 0, len(p.histograms))
+	for name := range p.histograms {
+		hnames = append(hnames, name)
+	}
+	sort.Strings(hnames)
+	for _, name := range hnames {
+		p.histograms[name].write(&buf, name)
+	}
+	p.mu.Unlock()
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	if _, err := w.Write(buf.Bytes()); err != nil {
+		Log.Warn("unable to write metrics: ", err)
+	}
+}
+
+func (p *Prometheus) name(name string) string {
+	if p.namespace != "" {
+		name = p.namespace + "_" + name
+	}
+	return promName(name)
+}
+
+// promName will replace any characters not allowed in Prometheus metric names.
+func promName(name string) string {
+	b := []byte(name)
+	for i, c := range b {
+		if !(c == '_' || c == ':' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || i > 0 && '0' <= c && c <= '9') {
+			b[i] = '_'
+		}
+	}
+	return string(b)
+}
+
+func sortedKeys(m map[string]*promValue) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func formatFloat(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "+Inf"
+	case math.IsInf(f, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+type promValue struct {
+	mu    sync.Mutex
+	value float64
+}
+
+func (v *promValue) Add(delta float64) {
+	v.mu.Lock()
+	v.value += delta
+	v.mu.Unlock()
+}
+
+func (v *promValue) Set(value float64) {
+	v.mu.Lock()
+	v.value = value
+	v.mu.Unlock()
+}
+
+func (v *promValue) get() float64 {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.value
+}
+
+type promHistogram struct {
+	mu      sync.Mutex
+	buckets []float64
+	counts  []uint64
+	count   uint64
+	sum     float64
+}
+
+func (h *promHistogram) Observe(value float64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for i, upper := range h.buckets {
+		if value <= upper {
+			h.counts[i]++
+		}
+	}
+	h.count++
+	h.sum += value
+}
+
+func (h *promHistogram) write(buf *bytes.Buffer, name string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	fmt.Fprintf(buf, "# TYPE %s histogram\n", name)
+	for i, upper := range h.buckets {
+		fmt.Fprintf(buf, "%s_bucket{le=\"%s\"} %d\n", name, formatFloat(upper), h.counts[i])
+	}
+	fmt.Fprintf(buf, "%s_bucket{le=\"+Inf\"} %d\n", name, h.count)
+	fmt.Fprintf(buf, "%s_sum %s\n%s_count %d\n", name, formatFloat(h.sum), name, h.count)
+}
@@ -0,0 +1,94 @@
+package metrics
+
+import (
+	"sync"
+	"time"
+
+	gometrics "github.com/rcrowley/go-metrics"
+)
+
+// ReportRegistry will report all metrics in the go-metrics Registry used by
+// gizmo's server and httpclient packages to the Provider on the given interval,
+// until the returned func is called. Counts are reported as counter deltas,
+// gauges as gauges and timers and histograms as gauges of their count, mean
+// and 50th, 95th and 99th percentiles. Timer values are in milliseconds.
+func ReportRegistry(r gometrics.Registry, p Provider, interval time.Duration) func() {
+	if r == nil {
+		r = gometrics.DefaultRegistry
+	}
+	if interval == 0 {
+		interval = DefaultInterval
+	}
+	rep := &registryReporter{registry: r, provider: p, counts: map[string]int64{}}
+	stop := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				rep.report()
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			close(stop)
+			rep.report()
+		})
+	}
+}
+
+type registryReporter struct {
+	registry gometrics.Registry
+	provider Provider
+
+	mu sync.Mutex
+	// last reported count of each counter
+	counts map[string]int64
+}
+
+func (rep *registryReporter) report() {
+	rep.mu.Lock()
+	defer rep.mu.Unlock()
+	rep.registry.Each(func(name string, i interface{}) {
+		switch m := i.(type) {
+		case gometrics.Counter:
+			rep.count(name, m.Count())
+		case gometrics.Meter:
+			rep.count(name, m.Count())
+		case gometrics.Gauge:
+			rep.provider.Gauge(name).Set(float64(m.Value()))
+		case gometrics.GaugeFloat64:
+			rep.provider.Gauge(name).Set(m.Value())
+		case gometrics.Timer:
+			s := m.Snapshot()
+			rep.count(name+".count", s.Count())
+			ms := float64(time.Millisecond)
+			rep.distribution(name, s.Mean()/ms, s.Percentiles([]float64{0.5, 0.95, 0.99}), ms)
+		case gometrics.Histogram:
+			s := m.Snapshot()
+			rep.count(name+".count", s.Count())
+			rep.distribution(name, s.Mean(), s.Percentiles([]float64{0.5, 0.95, 0.99}), 1)
+		}
+	})
+}
+
+// count will report the change in a count since the last report.
+func (rep *registryReporter) count(name string, count int64) {
+	if delta := count - rep.counts[name]; delta != 0 {
+		rep.provider.Counter(name).Add(float64(delta))
+	}
+	rep.counts[name] = count
+}
+
+func (rep *registryReporter) distribution(name string, mean float64, ps []float64, unit float64) {
+	rep.provider.Gauge(name + ".mean").Set(mean)
+	rep.provider.Gauge(name + ".p50").Set(ps[0] / unit)
+	rep.provider.Gauge(name + ".p95").Set(ps[1] / unit)
+	rep.provider.Gauge(name + ".p99").Set(ps[2] / unit)
+}
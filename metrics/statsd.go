@@ -0,0 +1,115 @@
+package metrics
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// StatsD is a Provider that sends every metric update to a StatsD or
+// DogStatsD agent over UDP.
+type StatsD struct {
+	conn   net.Conn
+	prefix string
+	// tag suffix for DogStatsD
+	tags string
+	// histograms are sent as timers to StatsD
+	histogramType string
+}
+
+// NewStatsD will return a Provider for the StatsD agent at the given address
+// that prefixes all metric names with the given prefix.
+func NewStatsD(addr, prefix string) (*StatsD, error) {
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	if prefix != "" {
+		prefix += "."
+	}
+	return &StatsD{conn: conn, prefix: prefix, histogramType: "ms"}, nil
+}
+
+// NewDogStatsD will return a Provider for the DogStatsD agent at the given
+// address that will add the 'key:value' tags to every metric.
+func NewDogStatsD(addr, prefix string, tags []string) (*StatsD, error) {
+	s, err := NewStatsD(addr, prefix)
+	if err != nil {
+		return nil, err
+	}
+	s.histogramType = "h"
+	if len(tags) > 0 {
+		s.tags = "|#" + strings.Join(tags, ",")
+	}
+	return s, nil
+}
+
+// Counter will return a counter that sends each Add to the agent.
+func (s *StatsD) Counter(name string) Counter {
+	return statsdCounter{s: s, name: s.prefix + name}
+}
+
+// Gauge will return a gauge that sends each Set and Add to the agent.
+func (s *StatsD) Gauge(name string) Gauge {
+	return statsdGauge{s: s, name: s.prefix + name}
+}
+
+// Histogram will return a histogram that sends each observation to the agent.
+func (s *StatsD) Histogram(name string) Histogram {
+	return statsdHistogram{s: s, name: s.prefix + name}
+}
+
+// Close will close the connection to the agent.
+func (s *StatsD) Close() error {
+	return s.conn.Close()
+}
+
+func (s *StatsD) send(name, value, typ string) {
+	if _, err := fmt.Fprintf(s.conn, "%s:%s|%s%s", name, value, typ, s.tags); err != nil {
+		Log.Debugf("unable to send metric %s: %s", name, err)
+	}
+}
+
+func formatValue(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+type statsdCounter struct {
+	s    *StatsD
+	name string
+}
+
+func (c statsdCounter) Add(delta float64) {
+	c.s.send(c.name, formatValue(delta), "c")
+}
+
+type statsdGauge struct {
+	s    *StatsD
+	name string
+}
+
+func (g statsdGauge) Set(value float64) {
+	// a leading sign would be taken as a relative change
+	if value < 0 {
+		g.s.send(g.name, "0", "g")
+	}
+	g.s.send(g.name, formatValue(value), "g")
+}
+
+func (g statsdGauge) Add(delta float64) {
+	value := formatValue(delta)
+	if delta >= 0 {
+		value = "+" + value
+	}
+	g.s.send(g.name, value, "g")
+}
+
+type statsdHistogram struct {
+	s    *StatsD
+	name string
+}
+
+func (h statsdHistogram) Observe(value float64) {
+	h.s.send(h.name, formatValue(value), h.s.histogramType)
+}
@@ -0,0 +1,19 @@
+/*
+Package migrate applies versioned SQL migrations to Postgres and MySQL
+databases when a service starts, before its servers or consumers begin.
+
+Migrations are loaded from any http.FileSystem, such as an http.Dir or the
+FileSystem of an asset embedding tool, and are applied in order of the
+version number their file names start with. An advisory lock makes sure only
+one instance of a service migrates at once while the others wait for it.
+
+With MigrateOnStart set in the config.SQL, Startup will apply any pending
+migrations:
+
+	db, err := sqldb.Open(cfg.SQL)
+	if err = migrate.Startup(db, cfg.SQL, assets); err != nil {
+	    server.Log.Fatal("unable to migrate database: ", err)
+	}
+	server.Init("saved-items", cfg.Server)
+*/
+package migrate
@@ -0,0 +1,306 @@
+package migrate
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io/ioutil"
+	"net/http"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+
+	"github.com/NYTimes/gizmo/config"
+)
+
+// Log is the structured logger used by the migrate package.
+var Log = logrus.New()
+
+// DefaultTable is the table applied migrations are recorded in
+// if no Table is given.
+const DefaultTable = "schema_migrations"
+
+// DefaultLockTimeout is how long a Runner will wait for another
+// instance to finish migrating if no LockTimeout is given.
+var DefaultLockTimeout = 5 * time.Minute
+
+// Migration is a versioned SQL script.
+type Migration struct {
+	Version int64
+	Name    string
+	SQL     string
+}
+
+// Load will read the migrations in the directory of the http.FileSystem.
+// This can be an http.Dir, the FileSystem of an asset embedding tool or,
+// with Go 1.16 or later, http.FS of an embed.FS. Migration file names must
+// start with their version number, like '0001_create_cats.sql'. Files ending
+// in '.down.sql' and files without a '.sql' extension are ignored.
+func Load(fs http.FileSystem, dir string) ([]Migration, error) {
+	d, err := fs.Open(dir)
+	if err != nil {
+		return nil, err
+	}
+	defer d.Close()
+	infos, err := d.Readdir(-1)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		migrations []Migration
+		versions   = map[int64]string{}
+	)
+	for _, info := range infos {
+		name := info.Name()
+		if info.IsDir() || !strings.HasSuffix(name, ".sql") || strings.HasSuffix(name, ".down.sql") {
+			continue
+		}
+		i := strings.IndexFunc(name, func(r rune) bool { return r < '0' || r > '9' })
+		if i <= 0 {
+			return nil, fmt.Errorf("migration %s does not start with a version number", name)
+		}
+		version, err := strconv.ParseInt(name[:i], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("migration %s has an invalid version: %s", name, err)
+		}
+		if other, ok := versions[version]; ok {
+			return nil, fmt.Errorf("migrations %s and %s have the same version", other, name)
+		}
+		versions[version] = name
+
+		f, err := fs.Open(path.Join(dir, name))
+		if err != nil {
+			return nil, err
+		}
+		b, err := ioutil.ReadAll(f)
+		f.Close()
+		if err != nil {
+			return nil, err
+		}
+		migrations = append(migrations, Migration{Version: version, Name: name, SQL: string(b)})
+	}
+	sort.Sort(byVersion(migrations))
+	return migrations, nil
+}
+
+type byVersion []Migration
+
+func (m byVersion) Len() int           { return len(m) }
+func (m byVersion) Swap(i, j int)      { m[i], m[j] = m[j], m[i] }
+func (m byVersion) Less(i, j int) bool { return m[i].Version < m[j].Version }
+
+// Runner applies migrations to a database. Only one Runner across all
+// instances of a service will migrate at once, guarded by an advisory lock,
+// and each migration is applied in its own transaction.
+//
+// The lock is held on its own connection, so the DB must allow at least
+// two open connections.
+type Runner struct {
+	// Table will override the DefaultTable.
+	Table string
+	// LockTimeout will override the DefaultLockTimeout.
+	LockTimeout time.Duration
+
+	db      *sql.DB
+	dialect string
+}
+
+// NewRunner will return a Runner for the given dialect: 'postgres' or 'mysql'.
+func NewRunner(db *sql.DB, dialect string) (*Runner, error) {
+	switch dialect {
+	case "postgres", "mysql":
+	default:
+		return nil, fmt.Errorf("unsupported migration dialect: %s", dialect)
+	}
+	return &Runner{
+		Table:       DefaultTable,
+		LockTimeout: DefaultLockTimeout,
+		db:          db,
+		dialect:     dialect,
+	}, nil
+}
+
+// Startup will apply any pending migrations in the config's MigrationsDir of
+// the given http.FileSystem if MigrateOnStart is set. It is meant to be called
+// before any servers or consumers are started. If fs is nil, the MigrationsDir
+// will be read from disk.
+func Startup(db *sql.DB, cfg *config.SQL, fs http.FileSystem) error {
+	if cfg == nil || !cfg.MigrateOnStart {
+		return nil
+	}
+	if fs == nil {
+		fs = http.Dir("")
+	}
+	dir := cfg.MigrationsDir
+	if dir == "" {
+		dir = "."
+	}
+	migrations, err := Load(fs, dir)
+	if err != nil {
+		return err
+	}
+	dialect := cfg.Dialect
+	if dialect == "" {
+		dialect = cfg.Driver
+	}
+	r, err := NewRunner(db, dialect)
+	if err != nil {
+		return err
+	}
+	if cfg.MigrationsTable != "" {
+		r.Table = cfg.MigrationsTable
+	}
+	_, err = r.Up(migrations)
+	return err
+}
+
+// Up will apply the migrations that have not been applied yet, in order of
+// version, and return the number applied. It will stop at the first
+// migration that fails.
+func (r *Runner) Up(migrations []Migration) (int, error) {
+	unlock, err := r.lock()
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+
+	if _, err = r.db.Exec(r.createTable()); err != nil {
+		return 0, err
+	}
+	applied, err := r.Applied()
+	if err != nil {
+		return 0, err
+	}
+	done := map[int64]bool{}
+	for _, v := range applied {
+		done[v] = true
+	}
+
+	var n int
+	for _, m := range migrations {
+		if done[m.Version] {
+			continue
+		}
+		start := time.Now()
+		if err = r.apply(m); err != nil {
+			return n, fmt.Errorf("migration %s failed: %s", m.Name, err)
+		}
+		Log.Infof("applied migration %s in %s", m.Name, time.Since(start))
+		n++
+	}
+	return n, nil
+}
+
+// Applied will return the versions of the migrations that have been applied.
+func (r *Runner) Applied() ([]int64, error) {
+	rows, err := r.db.Query("SELECT version FROM " + r.Table + " ORDER BY version")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var versions []int64
+	for rows.Next() {
+		var v int64
+		if err = rows.Scan(&v); err != nil {
+			return nil, err
+		}
+		versions = append(versions, v)
+	}
+	return versions, rows.Err()
+}
+
+func (r *Runner) apply(m Migration) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	if _, err = tx.Exec(m.SQL); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if _, err = tx.Exec(r.insertVersion(), m.Version, m.Name); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// lock will block until the migration lock is acquired or the LockTimeout
+// passes. The lock is held by a transaction so it stays on one connection.
+func (r *Runner) lock() (func(), error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	var unlock func()
+	switch r.dialect {
+	case "postgres":
+		// wait in small steps so the timeout can be honored
+		deadline := time.Now().Add(r.LockTimeout)
+		for {
+			var locked bool
+			if err = tx.QueryRow("SELECT pg_try_advisory_xact_lock($1)", r.lockKey()).Scan(&locked); err != nil || locked {
+				break
+			}
+			if time.Now().After(deadline) {
+				err = errors.New("timed out waiting for migration lock")
+				break
+			}
+			time.Sleep(100 * time.Millisecond)
+		}
+		unlock = func() {
+			// ending the transaction releases the lock
+			if err := tx.Commit(); err != nil {
+				Log.Warn("unable to release migration lock: ", err)
+			}
+		}
+	case "mysql":
+		var locked sql.NullInt64
+		secs := int(r.LockTimeout / time.Second)
+		if err = tx.QueryRow("SELECT GET_LOCK(?, ?)", r.lockName(), secs).Scan(&locked); err == nil && locked.Int64 != 1 {
+			err = errors.New("timed out waiting for migration lock")
+		}
+		unlock = func() {
+			if _, err := tx.Exec("SELECT RELEASE_LOCK(?)", r.lockName()); err != nil {
+				Log.Warn("unable to release migration lock: ", err)
+			}
+			tx.Commit()
+		}
+	}
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	return unlock, nil
+}
+
+func (r *Runner) lockName() string {
+	return "gizmo-migrate-" + r.Table
+}
+
+// lockKey will hash the lock name to a Postgres advisory lock key.
+func (r *Runner) lockKey() int64 {
+	h := fnv.New64a()
+	h.Write([]byte(r.lockName()))
+	return int64(h.Sum64())
+}
+
+func (r *Runner) createTable() string {
+	return "CREATE TABLE IF NOT EXISTS " + r.Table + " (" +
+		"version BIGINT PRIMARY KEY, " +
+		"name VARCHAR(255) NOT NULL, " +
+		"applied_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP)"
+}
+
+func (r *Runner) insertVersion() string {
+	if r.dialect == "postgres" {
+		return "INSERT INTO " + r.Table + " (version, name) VALUES ($1, $2)"
+	}
+	return "INSERT INTO " + r.Table + " (version, name) VALUES (?, ?)"
+}
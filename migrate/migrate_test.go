@@ -0,0 +1,191 @@
+package migrate
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/NYTimes/gizmo/config"
+)
+
+func writeMigrations(t *testing.T, files map[string]string) string {
+	dir, err := ioutil.TempDir("", "migrate")
+	if err != nil {
+		t.Fatal("unable to create temp dir: ", err)
+	}
+	for name, body := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(body), 0644); err != nil {
+			t.Fatal("unable to write migration: ", err)
+		}
+	}
+	return dir
+}
+
+func TestLoad(t *testing.T) {
+	dir := writeMigrations(t, map[string]string{
+		"0002_add_owner.sql":        "ALTER TABLE cats ADD owner TEXT",
+		"0001_create_cats.sql":      "CREATE TABLE cats (name TEXT)",
+		"0001_create_cats.down.sql": "DROP TABLE cats",
+		"README.md":                 "not a migration",
+	})
+	defer os.RemoveAll(dir)
+
+	migrations, err := Load(http.Dir(dir), "/")
+	if err != nil {
+		t.Fatal("unexpected error loading migrations: ", err)
+	}
+	if len(migrations) != 2 {
+		t.Fatalf("expected 2 migrations, got %d", len(migrations))
+	}
+	if migrations[0].Version != 1 || migrations[0].SQL != "CREATE TABLE cats (name TEXT)" {
+		t.Errorf("unexpected first migration: %+v", migrations[0])
+	}
+	if migrations[1].Version != 2 || migrations[1].Name != "0002_add_owner.sql" {
+		t.Errorf("unexpected second migration: %+v", migrations[1])
+	}
+
+	bad := writeMigrations(t, map[string]string{"create_cats.sql": ""})
+	defer os.RemoveAll(bad)
+	if _, err = Load(http.Dir(bad), "/"); err == nil {
+		t.Error("expected an error for a migration without a version")
+	}
+	dup := writeMigrations(t, map[string]string{"1_a.sql": "", "01_b.sql": ""})
+	defer os.RemoveAll(dup)
+	if _, err = Load(http.Dir(dup), "/"); err == nil {
+		t.Error("expected an error for migrations with the same version")
+	}
+}
+
+// testDB is a database/sql driver that records executed statements and
+// keeps applied versions in memory. Statements containing FAIL will fail.
+type testDB struct {
+	mu       sync.Mutex
+	execs    []string
+	versions []int64
+	locks    int
+}
+
+func (d *testDB) Open(string) (driver.Conn, error) { return &testConn{d}, nil }
+
+type testConn struct{ d *testDB }
+
+func (c *testConn) Prepare(query string) (driver.Stmt, error) { return &testStmt{c.d, query}, nil }
+func (c *testConn) Close() error                              { return nil }
+func (c *testConn) Begin() (driver.Tx, error)                 { return c, nil }
+func (c *testConn) Commit() error                             { return nil }
+func (c *testConn) Rollback() error                           { return nil }
+
+type testStmt struct {
+	d     *testDB
+	query string
+}
+
+func (s *testStmt) Close() error  { return nil }
+func (s *testStmt) NumInput() int { return -1 }
+
+func (s *testStmt) Exec(args []driver.Value) (driver.Result, error) {
+	s.d.mu.Lock()
+	defer s.d.mu.Unlock()
+	if strings.Contains(s.query, "FAIL") {
+		return nil, errors.New("syntax error")
+	}
+	if strings.HasPrefix(s.query, "INSERT INTO schema_migrations") {
+		s.d.versions = append(s.d.versions, args[0].(int64))
+	} else if !strings.HasPrefix(s.query, "CREATE TABLE IF NOT EXISTS") {
+		s.d.execs = append(s.d.execs, s.query)
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func (s *testStmt) Query(args []driver.Value) (driver.Rows, error) {
+	s.d.mu.Lock()
+	defer s.d.mu.Unlock()
+	switch {
+	case strings.Contains(s.query, "advisory"):
+		s.d.locks++
+		return &testRows{values: []driver.Value{true}}, nil
+	case strings.HasPrefix(s.query, "SELECT version"):
+		rows := &testRows{}
+		for _, v := range s.d.versions {
+			rows.values = append(rows.values, v)
+		}
+		return rows, nil
+	}
+	return nil, errors.New("unexpected query: " + s.query)
+}
+
+type testRows struct{ values []driver.Value }
+
+func (r *testRows) Columns() []string { return []string{"v"} }
+func (r *testRows) Close() error      { return nil }
+func (r *testRows) Next(dest []driver.Value) error {
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+	dest[0], r.values = r.values[0], r.values[1:]
+	return nil
+}
+
+func TestRunnerUp(t *testing.T) {
+	tdb := &testDB{}
+	sql.Register("migrate-test", tdb)
+	db, err := sql.Open("migrate-test", "")
+	if err != nil {
+		t.Fatal("unable to open test db: ", err)
+	}
+	defer db.Close()
+
+	r, err := NewRunner(db, "postgres")
+	if err != nil {
+		t.Fatal("unexpected error creating runner: ", err)
+	}
+	migrations := []Migration{
+		{Version: 1, Name: "0001_create_cats.sql", SQL: "CREATE TABLE cats"},
+		{Version: 2, Name: "0002_add_owner.sql", SQL: "ALTER TABLE cats"},
+	}
+	if n, err := r.Up(migrations[:1]); err != nil || n != 1 {
+		t.Fatalf("expected 1 migration applied, got %d, %v", n, err)
+	}
+	if n, err := r.Up(migrations); err != nil || n != 1 {
+		t.Fatalf("expected only the new migration applied, got %d, %v", n, err)
+	}
+	if len(tdb.execs) != 2 || tdb.execs[1] != "ALTER TABLE cats" {
+		t.Errorf("unexpected statements: %v", tdb.execs)
+	}
+	if tdb.locks != 2 {
+		t.Errorf("expected the lock to be taken for each run, got %d", tdb.locks)
+	}
+
+	migrations = append(migrations,
+		Migration{Version: 3, Name: "0003_broken.sql", SQL: "FAIL"},
+		Migration{Version: 4, Name: "0004_after.sql", SQL: "ALTER TABLE dogs"},
+	)
+	if n, err := r.Up(migrations); err == nil || n != 0 {
+		t.Errorf("expected the broken migration to fail, got %d, %v", n, err)
+	}
+	if applied, _ := r.Applied(); len(applied) != 2 {
+		t.Errorf("expected migrations to stop at the failure, got %v", applied)
+	}
+
+	if _, err = NewRunner(db, "oracle"); err == nil {
+		t.Error("expected an error for an unsupported dialect")
+	}
+}
+
+func TestStartup(t *testing.T) {
+	if err := Startup(nil, &config.SQL{}, nil); err != nil {
+		t.Error("expected nothing to happen without MigrateOnStart, got ", err)
+	}
+	err := Startup(nil, &config.SQL{MigrateOnStart: true, MigrationsDir: "/does/not/exist"}, nil)
+	if err == nil {
+		t.Error("expected an error for a missing migrations dir")
+	}
+}
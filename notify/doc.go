@@ -0,0 +1,22 @@
+/*
+Package notify offers a hook for telling operators about events like a dead letter
+queue growing, a consumer restarting or a health check flapping, with adapters
+for Slack incoming webhooks and generic JSON webhooks.
+
+Gizmo's pubsub consumers and servers will send events to a configured Notifier:
+
+	notifier, err := notify.New(cfg.Notify)
+
+	consumer := pubsub.NewConsumer(sub, handle)
+	consumer.Notifier = notifier
+
+	server.Notifier = notifier
+
+To be told when a queue grows, WatchGrowth will poll its depth:
+
+	go notify.WatchGrowth(notifier, "cats DLQ", 100, time.Minute, dlqDepth, stop)
+
+Notifications are sent in the background. Before exiting, call Flush on a Slack or
+Webhook notifier to wait for any pending notifications.
+*/
+package notify
@@ -0,0 +1,221 @@
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+
+	"github.com/NYTimes/gizmo/config"
+)
+
+// Log is the structured logger used throughout the package.
+var Log = logrus.New()
+
+// DefaultTimeout is how long a Notifier will wait on a destination if none
+// is configured.
+var DefaultTimeout = 5 * time.Second
+
+// Level is the severity of an Event.
+type Level string
+
+// The levels of an Event.
+const (
+	Info     Level = "info"
+	Warning  Level = "warning"
+	Critical Level = "critical"
+)
+
+// Event is something operators should know about, like a dead letter queue
+// growing, a consumer restarting or a health check flapping.
+type Event struct {
+	// Title is a short summary of the event. Events with the same title
+	// are considered repeats by Throttle.
+	Title string `json:"title"`
+	// Text is an optional longer description of the event.
+	Text string `json:"text,omitempty"`
+	// Level is the severity of the event. Defaults to Info.
+	Level Level `json:"level"`
+	// Fields are any extra details about the event.
+	Fields map[string]string `json:"fields,omitempty"`
+	// Source is the host the event happened on. Defaults to the hostname.
+	Source string `json:"source"`
+	// Time is when the event happened.
+	Time time.Time `json:"time"`
+}
+
+// Notifier is a hook for sending operational events to a chat room or
+// paging service. Implementations should not block on the destination.
+type Notifier interface {
+	Notify(*Event)
+}
+
+// NotifierFunc is a func that implements the Notifier interface.
+type NotifierFunc func(*Event)
+
+// Notify will call the func.
+func (f NotifierFunc) Notify(e *Event) {
+	f(e)
+}
+
+// New will return a Notifier that sends events to every destination in the
+// given config, throttled by its MinInterval. A nil config will return a
+// nil Notifier.
+func New(cfg *config.Notify) (Notifier, error) {
+	if cfg == nil {
+		return nil, nil
+	}
+	timeout := cfg.Timeout
+	if timeout == 0 {
+		timeout = DefaultTimeout
+	}
+	client := &http.Client{Timeout: timeout}
+
+	var ns Multi
+	if cfg.SlackWebhookURL != "" {
+		ns = append(ns, NewSlack(cfg, client))
+	}
+	if cfg.WebhookURL != "" {
+		ns = append(ns, NewWebhook(cfg.WebhookURL, client))
+	}
+	if len(ns) == 0 {
+		return nil, errors.New("notify config has no destinations")
+	}
+
+	var n Notifier = ns
+	if len(ns) == 1 {
+		n = ns[0]
+	}
+	if cfg.MinInterval > 0 {
+		n = Throttle(n, cfg.MinInterval)
+	}
+	return n, nil
+}
+
+// Multi is a Notifier that sends events to all of its Notifiers.
+type Multi []Notifier
+
+// Notify will send the event to each Notifier.
+func (m Multi) Notify(e *Event) {
+	for _, n := range m {
+		n.Notify(e)
+	}
+}
+
+// Throttle will return a Notifier that drops events with the same Title as
+// one sent less than the interval ago, so a flapping check does not flood
+// the destination.
+func Throttle(n Notifier, interval time.Duration) Notifier {
+	var (
+		mu   sync.Mutex
+		sent = map[string]time.Time{}
+	)
+	return NotifierFunc(func(e *Event) {
+		now := time.Now()
+		mu.Lock()
+		last, ok := sent[e.Title]
+		if ok && now.Sub(last) < interval {
+			mu.Unlock()
+			return
+		}
+		sent[e.Title] = now
+		// forget titles that can no longer be throttled
+		for title, at := range sent {
+			if now.Sub(at) >= interval {
+				delete(sent, title)
+			}
+		}
+		mu.Unlock()
+		n.Notify(e)
+	})
+}
+
+// fill will set the defaults of any empty fields of the event.
+func (e *Event) fill() {
+	if e.Level == "" {
+		e.Level = Info
+	}
+	if e.Source == "" {
+		e.Source, _ = os.Hostname()
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+}
+
+// sender will POST events to a destination in the background.
+type sender struct {
+	client *http.Client
+	wg     sync.WaitGroup
+}
+
+func newSender(client *http.Client) *sender {
+	if client == nil {
+		client = &http.Client{Timeout: DefaultTimeout}
+	}
+	return &sender{client: client}
+}
+
+// send will encode the payload and POST it in the background.
+func (s *sender) send(url string, payload interface{}) {
+	b, err := json.Marshal(payload)
+	if err != nil {
+		Log.Error("unable to encode notification: ", err)
+		return
+	}
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		if err := s.post(url, b); err != nil {
+			Log.Warn("unable to send notification: ", err)
+		}
+	}()
+}
+
+func (s *sender) post(url string, b []byte) error {
+	req, err := http.NewRequest("POST", url, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+		return errors.New("notification destination responded with " + resp.Status + ": " + string(msg))
+	}
+	return nil
+}
+
+// Flush will block until all pending notifications have been sent.
+func (s *sender) Flush() {
+	s.wg.Wait()
+}
+
+// Webhook is a Notifier that POSTs each Event as JSON to a URL.
+type Webhook struct {
+	*sender
+	url string
+}
+
+// NewWebhook will return a Webhook for the URL. If no client is given, one
+// with the DefaultTimeout will be used.
+func NewWebhook(url string, client *http.Client) *Webhook {
+	return &Webhook{sender: newSender(client), url: url}
+}
+
+// Notify will send the event in the background.
+func (w *Webhook) Notify(e *Event) {
+	e.fill()
+	w.send(w.url, e)
+}
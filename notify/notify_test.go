@@ -0,0 +1,138 @@
+package notify
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/NYTimes/gizmo/config"
+)
+
+func TestNew(t *testing.T) {
+	var (
+		mu    sync.Mutex
+		slack []slackMessage
+		hooks []Event
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch r.URL.Path {
+		case "/slack":
+			var m slackMessage
+			json.NewDecoder(r.Body).Decode(&m)
+			slack = append(slack, m)
+		case "/hook":
+			var e Event
+			json.NewDecoder(r.Body).Decode(&e)
+			hooks = append(hooks, e)
+		}
+	}))
+	defer srv.Close()
+
+	n, err := New(&config.Notify{
+		SlackWebhookURL: srv.URL + "/slack",
+		SlackChannel:    "#ops",
+		WebhookURL:      srv.URL + "/hook",
+		MinInterval:     time.Minute,
+	})
+	if err != nil {
+		t.Fatal("unexpected error: ", err)
+	}
+
+	e := &Event{Title: "cats DLQ is growing", Level: Warning, Fields: map[string]string{"depth": "12"}}
+	n.Notify(e)
+	// repeats within the MinInterval are dropped
+	n.Notify(&Event{Title: "cats DLQ is growing"})
+	n.Notify(&Event{Title: "consumer restarted", Level: Critical})
+
+	// wait for the background sends
+	deadline := time.Now().Add(time.Second)
+	for {
+		mu.Lock()
+		done := len(slack) == 2 && len(hooks) == 2
+		mu.Unlock()
+		if done || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(slack) != 2 || len(hooks) != 2 {
+		t.Fatalf("expected 2 notifications to each destination, got %d and %d", len(slack), len(hooks))
+	}
+	titles := map[string]slackAttachment{}
+	for _, m := range slack {
+		if m.Channel != "#ops" || len(m.Attachments) != 1 {
+			t.Errorf("expected a single attachment to #ops, got %+v", m)
+			continue
+		}
+		titles[m.Attachments[0].Title] = m.Attachments[0]
+	}
+	att := titles["cats DLQ is growing"]
+	if att.Color != "warning" || len(att.Fields) != 1 || att.Fields[0].Value != "12" {
+		t.Errorf("unexpected attachment for the DLQ event: %+v", att)
+	}
+	if titles["consumer restarted"].Color != "danger" {
+		t.Errorf("expected a danger attachment for the critical event")
+	}
+	for _, h := range hooks {
+		if h.Source == "" || h.Time.IsZero() {
+			t.Errorf("expected the event source and time to be filled, got %+v", h)
+		}
+	}
+}
+
+func TestNewWithoutDestinations(t *testing.T) {
+	if n, err := New(nil); n != nil || err != nil {
+		t.Errorf("expected a nil notifier for a nil config, got %v, %v", n, err)
+	}
+	if _, err := New(&config.Notify{}); err == nil {
+		t.Error("expected an error for a config without destinations")
+	}
+}
+
+func TestWatchGrowth(t *testing.T) {
+	var (
+		mu     sync.Mutex
+		events []*Event
+		depth  = []int64{5, 20, 20, 30, 10}
+		checks int
+	)
+	n := NotifierFunc(func(e *Event) {
+		mu.Lock()
+		events = append(events, e)
+		mu.Unlock()
+	})
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		WatchGrowth(n, "cats DLQ", 10, time.Millisecond, func() (int64, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			if checks == len(depth) {
+				return depth[len(depth)-1], nil
+			}
+			checks++
+			return depth[checks-1], nil
+		}, stop)
+		close(done)
+	}()
+	time.Sleep(50 * time.Millisecond)
+	close(stop)
+	<-done
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(events) != 2 {
+		t.Fatalf("expected 2 growth events, got %d", len(events))
+	}
+	if events[0].Text != "cats DLQ has grown to 20" || events[1].Text != "cats DLQ has grown to 30" {
+		t.Errorf("unexpected growth events: %q, %q", events[0].Text, events[1].Text)
+	}
+}
@@ -0,0 +1,82 @@
+package notify
+
+import (
+	"net/http"
+	"sort"
+
+	"github.com/NYTimes/gizmo/config"
+)
+
+// slackColors are the attachment colors of each Level.
+var slackColors = map[Level]string{
+	Info:     "good",
+	Warning:  "warning",
+	Critical: "danger",
+}
+
+// Slack is a Notifier that posts each Event to a Slack incoming webhook.
+type Slack struct {
+	*sender
+	url      string
+	channel  string
+	username string
+}
+
+// NewSlack will return a Notifier for the config's SlackWebhookURL. If no
+// client is given, one with the DefaultTimeout will be used.
+func NewSlack(cfg *config.Notify, client *http.Client) *Slack {
+	return &Slack{
+		sender:   newSender(client),
+		url:      cfg.SlackWebhookURL,
+		channel:  cfg.SlackChannel,
+		username: cfg.SlackUsername,
+	}
+}
+
+type slackMessage struct {
+	Channel     string            `json:"channel,omitempty"`
+	Username    string            `json:"username,omitempty"`
+	Attachments []slackAttachment `json:"attachments"`
+}
+
+type slackAttachment struct {
+	Fallback string       `json:"fallback"`
+	Color    string       `json:"color"`
+	Title    string       `json:"title"`
+	Text     string       `json:"text,omitempty"`
+	Fields   []slackField `json:"fields,omitempty"`
+	Footer   string       `json:"footer,omitempty"`
+	TS       int64        `json:"ts"`
+}
+
+type slackField struct {
+	Title string `json:"title"`
+	Value string `json:"value"`
+	Short bool   `json:"short"`
+}
+
+// Notify will post the event in the background.
+func (s *Slack) Notify(e *Event) {
+	e.fill()
+	att := slackAttachment{
+		Fallback: "[" + string(e.Level) + "] " + e.Title,
+		Color:    slackColors[e.Level],
+		Title:    e.Title,
+		Text:     e.Text,
+		Footer:   e.Source,
+		TS:       e.Time.Unix(),
+	}
+	keys := make([]string, 0, len(e.Fields))
+	for k := range e.Fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		att.Fields = append(att.Fields, slackField{Title: k, Value: e.Fields[k], Short: true})
+	}
+	s.send(s.url, &slackMessage{
+		Channel:     s.channel,
+		Username:    s.username,
+		Attachments: []slackAttachment{att},
+	})
+}
@@ -0,0 +1,41 @@
+package notify
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// WatchGrowth will check the value returned by gauge, like the depth of a dead
+// letter queue, every interval and send a Warning event whenever it has grown
+// past the threshold and above the last value checked. It will block until
+// stop is closed.
+func WatchGrowth(n Notifier, name string, threshold int64, interval time.Duration, gauge func() (int64, error), stop <-chan struct{}) {
+	var last int64
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+		v, err := gauge()
+		if err != nil {
+			Log.Warn("unable to check "+name+": ", err)
+			continue
+		}
+		if v > threshold && v > last {
+			n.Notify(&Event{
+				Title: name + " is growing",
+				Text:  fmt.Sprintf("%s has grown to %d", name, v),
+				Level: Warning,
+				Fields: map[string]string{
+					"previous":  strconv.FormatInt(last, 10),
+					"threshold": strconv.FormatInt(threshold, 10),
+				},
+			})
+		}
+		last = v
+	}
+}
@@ -0,0 +1,21 @@
+/*
+Package objectstore provides a Store interface for working with blob stores along with implementations for Amazon S3 and Google Cloud Storage.
+
+Uploads are streamed to the store in parts, so large objects, like oversized pubsub messages or user uploads, never need to be held in memory in full. Both stores can also create presigned GET and PUT URLs to let clients download or upload objects directly.
+
+Stores are created from the config package's structs:
+
+	store, err := objectstore.NewS3(cfg.S3)
+	if err != nil {
+		server.Log.Fatal("unable to init S3: ", err)
+	}
+
+	err = store.Put(ctx, "exports/cats.csv", file, "text/csv")
+
+	url, err := store.PresignGet("exports/cats.csv", 15*time.Minute)
+
+Get will return ErrNotFound if the requested object does not exist.
+
+The S3 store is left out of builds with the 'noaws' tag and the Google Cloud Storage store out of builds with the 'nogcp' tag, so code that only needs the Store interface does not link either SDK.
+*/
+package objectstore
@@ -0,0 +1,112 @@
+// +build !nogcp
+
+package objectstore
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"golang.org/x/net/context"
+	"google.golang.org/api/option"
+
+	"github.com/NYTimes/gizmo/config"
+)
+
+// GCS is a Store backed by a Google Cloud Storage bucket.
+type GCS struct {
+	bucket     *storage.BucketHandle
+	name       string
+	chunkSize  int
+	accessID   string
+	privateKey []byte
+}
+
+// NewGCS will initiate a Google Cloud Storage client for the bucket in the
+// given config. If no CredentialsFile is configured, the application default
+// credentials will be used.
+func NewGCS(ctx context.Context, cfg *config.GCS) (*GCS, error) {
+	if cfg.Bucket == "" {
+		return nil, errors.New("GCS bucket is required")
+	}
+	var opts []option.ClientOption
+	if cfg.CredentialsFile != "" {
+		opts = append(opts, option.WithCredentialsFile(cfg.CredentialsFile))
+	}
+	client, err := storage.NewClient(ctx, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return NewGCSWithClient(client, cfg), nil
+}
+
+// NewGCSWithClient will return a GCS Store that uses the given client for
+// the bucket in the given config.
+func NewGCSWithClient(client *storage.Client, cfg *config.GCS) *GCS {
+	chunkSize := cfg.UploadChunkSize
+	if chunkSize == 0 {
+		chunkSize = DefaultPartSize
+	}
+	return &GCS{
+		bucket:     client.Bucket(cfg.Bucket),
+		name:       cfg.Bucket,
+		chunkSize:  chunkSize,
+		accessID:   cfg.AccessID,
+		privateKey: []byte(cfg.PrivateKey),
+	}
+}
+
+// Put will stream r to GCS with a resumable upload, sending it in chunks of
+// the configured size.
+func (g *GCS) Put(ctx context.Context, key string, r io.Reader, contentType string) error {
+	w := g.bucket.Object(key).NewWriter(ctx)
+	w.ChunkSize = g.chunkSize
+	w.ContentType = contentType
+	if _, err := io.Copy(w, r); err != nil {
+		w.CloseWithError(err)
+		return err
+	}
+	return w.Close()
+}
+
+// Get will return a reader over the object at key.
+func (g *GCS) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	rc, err := g.bucket.Object(key).NewReader(ctx)
+	if err == storage.ErrObjectNotExist {
+		return nil, ErrNotFound
+	}
+	return rc, err
+}
+
+// Delete will remove the object at key.
+func (g *GCS) Delete(ctx context.Context, key string) error {
+	return g.bucket.Object(key).Delete(ctx)
+}
+
+// PresignGet will return a signed URL for downloading the object at key. The
+// config's AccessID and PrivateKey are required.
+func (g *GCS) PresignGet(key string, expires time.Duration) (string, error) {
+	return g.sign(key, http.MethodGet, "", expires)
+}
+
+// PresignPut will return a signed URL for uploading the object at key. The
+// upload must be sent with the given content type. The config's AccessID and
+// PrivateKey are required.
+func (g *GCS) PresignPut(key, contentType string, expires time.Duration) (string, error) {
+	return g.sign(key, http.MethodPut, contentType, expires)
+}
+
+func (g *GCS) sign(key, method, contentType string, expires time.Duration) (string, error) {
+	if g.accessID == "" || len(g.privateKey) == 0 {
+		return "", errors.New("GCS access ID and private key are required to presign URLs")
+	}
+	return storage.SignedURL(g.name, key, &storage.SignedURLOptions{
+		GoogleAccessID: g.accessID,
+		PrivateKey:     g.privateKey,
+		Method:         method,
+		ContentType:    contentType,
+		Expires:        time.Now().Add(expires),
+	})
+}
@@ -0,0 +1,40 @@
+package objectstore
+
+import (
+	"errors"
+	"io"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// DefaultPartSize is the size in bytes of each part of a multipart or
+// resumable upload if none is configured.
+var DefaultPartSize = 8 * 1024 * 1024
+
+// DefaultUploadConcurrency is how many parts of a multipart upload will be
+// sent at once if none is configured.
+var DefaultUploadConcurrency = 4
+
+// ErrNotFound is returned by Get when the requested object does not exist.
+var ErrNotFound = errors.New("object not found")
+
+// Store is a bucket of objects in a blob store like S3 or Google Cloud
+// Storage.
+type Store interface {
+	// Put will stream the contents of r to the object at key. Large objects
+	// are sent in parts so the full body never needs to be held in memory.
+	Put(ctx context.Context, key string, r io.Reader, contentType string) error
+	// Get will return a reader over the contents of the object at key. It is
+	// the caller's responsibility to close it. If the object does not exist,
+	// ErrNotFound is returned.
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// Delete will remove the object at key.
+	Delete(ctx context.Context, key string) error
+	// PresignGet will return a URL that allows anyone holding it to download
+	// the object at key until it expires.
+	PresignGet(key string, expires time.Duration) (string, error)
+	// PresignPut will return a URL that allows anyone holding it to upload
+	// the object at key with the given content type until it expires.
+	PresignPut(key, contentType string, expires time.Duration) (string, error)
+}
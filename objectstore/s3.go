@@ -0,0 +1,147 @@
+// +build !noaws
+
+package objectstore
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+	"golang.org/x/net/context"
+
+	"github.com/NYTimes/gizmo/config"
+)
+
+// S3 is a Store backed by an Amazon S3 bucket.
+type S3 struct {
+	s3       s3iface.S3API
+	uploader *s3manager.Uploader
+	bucket   string
+}
+
+// NewS3 will initiate an S3 client for the bucket in the given config. If
+// no credentials are passed in with the config, the client will use the
+// AWS_ACCESS_KEY and the AWS_SECRET_KEY environment variables.
+func NewS3(cfg *config.S3) (*S3, error) {
+	if cfg.Bucket == "" {
+		return nil, errors.New("S3 bucket is required")
+	}
+	if cfg.Region == "" {
+		return nil, errors.New("S3 region is required")
+	}
+
+	var creds *credentials.Credentials
+	if cfg.AccessKey != "" {
+		creds = credentials.NewStaticCredentials(cfg.AccessKey, cfg.SecretKey, "")
+	} else {
+		creds = credentials.NewEnvCredentials()
+	}
+	awsCfg := &aws.Config{
+		Credentials: creds,
+		Region:      &cfg.Region,
+	}
+	if cfg.Endpoint != "" {
+		awsCfg.Endpoint = &cfg.Endpoint
+		awsCfg.S3ForcePathStyle = aws.Bool(true)
+	}
+	return NewS3WithClient(s3.New(session.New(awsCfg)), cfg), nil
+}
+
+// NewS3WithClient will return an S3 Store that uses the given client for
+// the bucket in the given config.
+func NewS3WithClient(client s3iface.S3API, cfg *config.S3) *S3 {
+	partSize := cfg.UploadPartSize
+	if partSize == 0 {
+		partSize = int64(DefaultPartSize)
+	}
+	concurrency := cfg.UploadConcurrency
+	if concurrency == 0 {
+		concurrency = DefaultUploadConcurrency
+	}
+	return &S3{
+		s3: client,
+		uploader: s3manager.NewUploaderWithClient(client, func(u *s3manager.Uploader) {
+			u.PartSize = partSize
+			u.Concurrency = concurrency
+		}),
+		bucket: cfg.Bucket,
+	}
+}
+
+// Put will stream r to S3, using a multipart upload if it is larger than
+// a single part. Failed multipart uploads are aborted.
+func (s *S3) Put(ctx context.Context, key string, r io.Reader, contentType string) error {
+	in := &s3manager.UploadInput{
+		Bucket: &s.bucket,
+		Key:    &key,
+		Body:   r,
+	}
+	if contentType != "" {
+		in.ContentType = &contentType
+	}
+	_, err := s.uploader.UploadWithContext(ctx, in)
+	return err
+}
+
+// Get will return the body of the object at key.
+func (s *S3) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	out, err := s.s3.GetObjectWithContext(ctx, &s3.GetObjectInput{
+		Bucket: &s.bucket,
+		Key:    &key,
+	})
+	if err != nil {
+		if isS3NotFound(err) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return out.Body, nil
+}
+
+// Delete will remove the object at key.
+func (s *S3) Delete(ctx context.Context, key string) error {
+	_, err := s.s3.DeleteObjectWithContext(ctx, &s3.DeleteObjectInput{
+		Bucket: &s.bucket,
+		Key:    &key,
+	})
+	return err
+}
+
+// PresignGet will return a signed URL for downloading the object at key.
+func (s *S3) PresignGet(key string, expires time.Duration) (string, error) {
+	req, _ := s.s3.GetObjectRequest(&s3.GetObjectInput{
+		Bucket: &s.bucket,
+		Key:    &key,
+	})
+	return req.Presign(expires)
+}
+
+// PresignPut will return a signed URL for uploading the object at key. The
+// upload must be sent with the given content type.
+func (s *S3) PresignPut(key, contentType string, expires time.Duration) (string, error) {
+	in := &s3.PutObjectInput{
+		Bucket: &s.bucket,
+		Key:    &key,
+	}
+	if contentType != "" {
+		in.ContentType = &contentType
+	}
+	req, _ := s.s3.PutObjectRequest(in)
+	return req.Presign(expires)
+}
+
+func isS3NotFound(err error) bool {
+	if rerr, ok := err.(awserr.RequestFailure); ok && rerr.StatusCode() == http.StatusNotFound {
+		return true
+	}
+	aerr, ok := err.(awserr.Error)
+	return ok && aerr.Code() == s3.ErrCodeNoSuchKey
+}
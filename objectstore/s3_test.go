@@ -0,0 +1,111 @@
+// +build !noaws
+
+package objectstore
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/NYTimes/gizmo/config"
+)
+
+func TestS3(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		objects = map[string][]byte{}
+		types   = map[string]string{}
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch r.Method {
+		case "PUT":
+			body, _ := ioutil.ReadAll(r.Body)
+			objects[r.URL.Path] = body
+			types[r.URL.Path] = r.Header.Get("Content-Type")
+		case "GET":
+			body, ok := objects[r.URL.Path]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				w.Write([]byte(`<Error><Code>NoSuchKey</Code><Message>nope</Message></Error>`))
+				return
+			}
+			w.Write(body)
+		case "DELETE":
+			delete(objects, r.URL.Path)
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer srv.Close()
+
+	store, err := NewS3(&config.S3{
+		AWS:      config.AWS{AccessKey: "key", SecretKey: "secret", Region: "us-east-1"},
+		Bucket:   "cats",
+		Endpoint: srv.URL,
+	})
+	if err != nil {
+		t.Fatal("unable to init store: ", err)
+	}
+	ctx := context.Background()
+
+	if err = store.Put(ctx, "tabby.json", strings.NewReader(`{"name":"tabby"}`), "application/json"); err != nil {
+		t.Fatal("unexpected error putting object: ", err)
+	}
+	if got := types["/cats/tabby.json"]; got != "application/json" {
+		t.Errorf("expected content type 'application/json', got %q", got)
+	}
+
+	rc, err := store.Get(ctx, "tabby.json")
+	if err != nil {
+		t.Fatal("unexpected error getting object: ", err)
+	}
+	body, _ := ioutil.ReadAll(rc)
+	rc.Close()
+	if string(body) != `{"name":"tabby"}` {
+		t.Errorf("expected the uploaded body, got %q", body)
+	}
+
+	if err = store.Delete(ctx, "tabby.json"); err != nil {
+		t.Fatal("unexpected error deleting object: ", err)
+	}
+	if _, err = store.Get(ctx, "tabby.json"); err != ErrNotFound {
+		t.Errorf("expected ErrNotFound after delete, got %v", err)
+	}
+}
+
+func TestS3Presign(t *testing.T) {
+	store, err := NewS3(&config.S3{
+		AWS:    config.AWS{AccessKey: "key", SecretKey: "secret", Region: "us-east-1"},
+		Bucket: "cats",
+	})
+	if err != nil {
+		t.Fatal("unable to init store: ", err)
+	}
+
+	get, err := store.PresignGet("tabby.json", time.Hour)
+	if err != nil {
+		t.Fatal("unexpected error presigning GET: ", err)
+	}
+	put, err := store.PresignPut("tabby.json", "application/json", time.Hour)
+	if err != nil {
+		t.Fatal("unexpected error presigning PUT: ", err)
+	}
+	for _, u := range []string{get, put} {
+		if !strings.Contains(u, "tabby.json") || !strings.Contains(u, "X-Amz-Signature=") {
+			t.Errorf("expected a signed URL for tabby.json, got %s", u)
+		}
+		if !strings.Contains(u, "X-Amz-Expires=3600") {
+			t.Errorf("expected the URL to expire in an hour, got %s", u)
+		}
+	}
+	if get == put {
+		t.Error("expected GET and PUT URLs to differ")
+	}
+}
@@ -0,0 +1,234 @@
+package pubsub
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+)
+
+var (
+	// DefaultAsyncWorkers is how many messages an AsyncPublisher will
+	// publish at once if no Workers is given.
+	DefaultAsyncWorkers = 4
+	// DefaultAsyncQueueSize is how many messages can be waiting to be
+	// published by an AsyncPublisher if no QueueSize is given.
+	DefaultAsyncQueueSize = 1000
+	// DefaultAsyncBatchSize is the max number of messages an AsyncPublisher
+	// will send in one request to a BatchPublisher if no BatchSize is given.
+	DefaultAsyncBatchSize = 10
+	// DefaultAsyncBatchWait is how long an AsyncPublisher will wait for a
+	// batch to fill if no BatchWait is given.
+	DefaultAsyncBatchWait = 10 * time.Millisecond
+)
+
+// ErrPublisherClosed is passed to the callbacks of messages published
+// after an AsyncPublisher has been closed.
+var ErrPublisherClosed = errors.New("publisher is closed")
+
+type (
+	// BatchPublisher is a Publisher that can send several messages in a
+	// single request.
+	BatchPublisher interface {
+		Publisher
+		// PublishRawBatch will publish the messages, each with the key of
+		// the same index, and return the result or error of each.
+		PublishRawBatch(keys []string, msgs [][]byte) ([]*PublishResult, []error)
+	}
+
+	// PublishCallback is called with the outcome of an asynchronous publish.
+	PublishCallback func(*PublishResult, error)
+
+	// AsyncOptions configure an AsyncPublisher.
+	AsyncOptions struct {
+		// Workers is the max number of publish requests that will be made
+		// at once. Defaults to DefaultAsyncWorkers.
+		Workers int
+		// QueueSize is the max number of messages that can be waiting to be
+		// published before PublishAsync blocks. Defaults to
+		// DefaultAsyncQueueSize.
+		QueueSize int
+		// BatchSize is the max number of messages that will be sent in one
+		// request if the underlying Publisher is a BatchPublisher. Defaults
+		// to DefaultAsyncBatchSize.
+		BatchSize int
+		// BatchWait is how long a worker will wait for more messages to fill
+		// a batch. Defaults to DefaultAsyncBatchWait.
+		BatchWait time.Duration
+	}
+
+	// AsyncPublisher is a Publisher that queues messages and publishes them
+	// in the background with a bounded pool of workers, for hot paths that
+	// cannot wait on a round trip per message. If the underlying Publisher
+	// is a BatchPublisher, queued messages are sent in batches.
+	AsyncPublisher struct {
+		pub       Publisher
+		batchSize int
+		batchWait time.Duration
+
+		// mu guards sends to the queue against it being closed
+		mu     sync.RWMutex
+		closed bool
+		queue  chan *asyncMessage
+		wg     sync.WaitGroup
+	}
+
+	asyncMessage struct {
+		key  string
+		body []byte
+		cb   PublishCallback
+	}
+)
+
+// NewAsyncPublisher will return an AsyncPublisher that publishes to pub and
+// start its workers. Close must be called to flush any queued messages.
+func NewAsyncPublisher(pub Publisher, opts AsyncOptions) *AsyncPublisher {
+	if opts.Workers < 1 {
+		opts.Workers = DefaultAsyncWorkers
+	}
+	if opts.QueueSize < 1 {
+		opts.QueueSize = DefaultAsyncQueueSize
+	}
+	if opts.BatchSize < 1 {
+		opts.BatchSize = DefaultAsyncBatchSize
+	}
+	if opts.BatchWait == 0 {
+		opts.BatchWait = DefaultAsyncBatchWait
+	}
+	p := &AsyncPublisher{
+		pub:       pub,
+		batchSize: opts.BatchSize,
+		batchWait: opts.BatchWait,
+		queue:     make(chan *asyncMessage, opts.QueueSize),
+	}
+	p.wg.Add(opts.Workers)
+	for i := 0; i < opts.Workers; i++ {
+		go p.work()
+	}
+	return p
+}
+
+// Publish will marshal the proto message and queue it to be published.
+// Any failure to publish it will be logged.
+func (p *AsyncPublisher) Publish(key string, m proto.Message) error {
+	mb, err := proto.Marshal(m)
+	if err != nil {
+		return err
+	}
+	return p.PublishRaw(key, mb)
+}
+
+// PublishRaw will queue the byte array to be published. Any failure to
+// publish it will be logged.
+func (p *AsyncPublisher) PublishRaw(key string, m []byte) error {
+	p.PublishRawAsync(key, m, nil)
+	return nil
+}
+
+// PublishAsync will marshal the proto message and queue it to be published,
+// passing the outcome to the optional callback.
+func (p *AsyncPublisher) PublishAsync(key string, m proto.Message, cb PublishCallback) {
+	mb, err := proto.Marshal(m)
+	if err != nil {
+		if cb != nil {
+			cb(nil, err)
+		}
+		return
+	}
+	p.PublishRawAsync(key, mb, cb)
+}
+
+// PublishRawAsync will queue the byte array to be published, passing the
+// outcome to the optional callback. Callbacks are called from the worker
+// goroutines, so they should be quick. If the queue is full, it will block
+// until there is room.
+func (p *AsyncPublisher) PublishRawAsync(key string, m []byte, cb PublishCallback) {
+	p.mu.RLock()
+	if p.closed {
+		p.mu.RUnlock()
+		p.complete(&asyncMessage{key: key, cb: cb}, nil, ErrPublisherClosed)
+		return
+	}
+	p.queue <- &asyncMessage{key: key, body: m, cb: cb}
+	p.mu.RUnlock()
+}
+
+// Close will stop accepting messages and block until all queued messages
+// have been published.
+func (p *AsyncPublisher) Close() error {
+	p.mu.Lock()
+	if !p.closed {
+		p.closed = true
+		close(p.queue)
+	}
+	p.mu.Unlock()
+	p.wg.Wait()
+	return nil
+}
+
+func (p *AsyncPublisher) work() {
+	defer p.wg.Done()
+	bp, batching := p.pub.(BatchPublisher)
+	batching = batching && p.batchSize > 1
+	for msg := range p.queue {
+		if !batching {
+			res, err := PublishWithResult(p.pub, msg.key, msg.body)
+			p.complete(msg, res, err)
+			continue
+		}
+		p.publishBatch(bp, p.fill(msg))
+	}
+}
+
+// fill will collect queued messages into a batch until it is full, the
+// BatchWait passes or the queue is closed.
+func (p *AsyncPublisher) fill(first *asyncMessage) []*asyncMessage {
+	batch := []*asyncMessage{first}
+	timer := time.NewTimer(p.batchWait)
+	defer timer.Stop()
+	for len(batch) < p.batchSize {
+		select {
+		case msg, ok := <-p.queue:
+			if !ok {
+				return batch
+			}
+			batch = append(batch, msg)
+		case <-timer.C:
+			return batch
+		}
+	}
+	return batch
+}
+
+func (p *AsyncPublisher) publishBatch(bp BatchPublisher, batch []*asyncMessage) {
+	keys := make([]string, len(batch))
+	bodies := make([][]byte, len(batch))
+	for i, msg := range batch {
+		keys[i], bodies[i] = msg.key, msg.body
+	}
+	results, errs := bp.PublishRawBatch(keys, bodies)
+	for i, msg := range batch {
+		var (
+			res *PublishResult
+			err error
+		)
+		if i < len(results) {
+			res = results[i]
+		}
+		if i < len(errs) {
+			err = errs[i]
+		}
+		p.complete(msg, res, err)
+	}
+}
+
+func (p *AsyncPublisher) complete(msg *asyncMessage, res *PublishResult, err error) {
+	if msg.cb != nil {
+		msg.cb(res, err)
+		return
+	}
+	if err != nil {
+		Log.WithField("key", msg.key).Error("unable to publish message: ", err)
+	}
+}
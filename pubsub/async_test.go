@@ -0,0 +1,102 @@
+package pubsub
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+type testBatchPublisher struct {
+	testMirrorPublisher
+	batches []int
+}
+
+func (p *testBatchPublisher) PublishRawBatch(keys []string, msgs [][]byte) ([]*PublishResult, []error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.batches = append(p.batches, len(msgs))
+	results := make([]*PublishResult, len(msgs))
+	errs := make([]error, len(msgs))
+	for i, m := range msgs {
+		if string(m) == "bad" {
+			errs[i] = errors.New("bad message")
+			continue
+		}
+		p.published = append(p.published, string(m))
+		results[i] = &PublishResult{MessageID: keys[i]}
+	}
+	return results, errs
+}
+
+func TestAsyncPublisher(t *testing.T) {
+	pub := &testMirrorPublisher{}
+	ap := NewAsyncPublisher(pub, AsyncOptions{Workers: 2, QueueSize: 2})
+
+	var wg sync.WaitGroup
+	wg.Add(5)
+	for i := 0; i < 5; i++ {
+		ap.PublishRawAsync("key", []byte(strconv.Itoa(i)), func(res *PublishResult, err error) {
+			defer wg.Done()
+			if err != nil || res == nil || res.Timestamp.IsZero() {
+				t.Errorf("expected a result, got %+v and %v", res, err)
+			}
+		})
+	}
+	ap.PublishRaw("key", []byte("5"))
+	wg.Wait()
+	ap.Close()
+
+	if got := len(pub.published); got != 6 {
+		t.Errorf("expected 6 messages published, got %d", got)
+	}
+
+	var closedErr error
+	ap.PublishRawAsync("key", []byte("late"), func(res *PublishResult, err error) {
+		closedErr = err
+	})
+	if closedErr != ErrPublisherClosed {
+		t.Errorf("expected %v after closing, got %v", ErrPublisherClosed, closedErr)
+	}
+}
+
+func TestAsyncPublisherBatches(t *testing.T) {
+	pub := &testBatchPublisher{}
+	ap := NewAsyncPublisher(pub, AsyncOptions{Workers: 1, BatchSize: 3, BatchWait: time.Second})
+
+	var (
+		mu     sync.Mutex
+		failed []string
+		ids    []string
+	)
+	for _, body := range []string{"a", "bad", "c", "d"} {
+		ap.PublishRawAsync("key-"+body, []byte(body), func(res *PublishResult, err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				failed = append(failed, err.Error())
+				return
+			}
+			ids = append(ids, res.MessageID)
+		})
+	}
+	// closing should flush the partial batch without waiting for it to fill
+	start := time.Now()
+	ap.Close()
+	if took := time.Since(start); took > 500*time.Millisecond {
+		t.Errorf("expected close to flush right away, took %s", took)
+	}
+
+	if len(pub.batches) != 2 || pub.batches[0] != 3 || pub.batches[1] != 1 {
+		t.Errorf("expected batches of 3 and 1, got %v", pub.batches)
+	}
+	if len(failed) != 1 || failed[0] != "bad message" {
+		t.Errorf("expected 1 failed message, got %v", failed)
+	}
+	sort.Strings(ids)
+	if len(ids) != 3 || ids[0] != "key-a" || ids[2] != "key-d" {
+		t.Errorf("expected results for a, c and d, got %v", ids)
+	}
+}
@@ -1,30 +1,87 @@
+// +build !noaws
+
 package pubsub
 
 import (
+	"crypto/sha256"
 	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
 	"sync/atomic"
 	"time"
+	"unicode/utf8"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/endpoints"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/sns"
 	"github.com/aws/aws-sdk-go/service/sns/snsiface"
 	"github.com/aws/aws-sdk-go/service/sqs"
 	"github.com/aws/aws-sdk-go/service/sqs/sqsiface"
 	"github.com/golang/protobuf/proto"
+	"golang.org/x/net/context"
 
 	"github.com/NYTimes/gizmo/config"
+	"github.com/NYTimes/gizmo/objectstore"
 )
 
 // SNSPublisher will accept AWS credentials and an SNS topic name
 // and it will emit any publish events to it.
 type SNSPublisher struct {
-	sns   snsiface.SNSAPI
-	topic string
+	sns         snsiface.SNSAPI
+	topic       string
+	encoding    string
+	subjectMode string
 }
 
+// The ways an SNSPublisher can send the keys of its messages, which are
+// sent as the SNS Subject by default. SNS rejects subjects over
+// MaxSNSSubjectLength characters or with non-ASCII or control characters.
+const (
+	// SNSSubjectKey sends every key as the subject, as it is.
+	SNSSubjectKey = "subject"
+	// SNSSubjectOmit sends no subject.
+	SNSSubjectOmit = "omit"
+	// SNSSubjectTruncate replaces the characters SNS rejects in a key and
+	// truncates it with a hash suffix, so long keys remain distinct.
+	SNSSubjectTruncate = "truncate"
+	// SNSSubjectAttribute sends keys SNS would reject in the SNSKeyAttribute
+	// message attribute instead of the subject.
+	SNSSubjectAttribute = "attribute"
+
+	// SNSKeyAttribute is the message attribute keys are sent in by the
+	// SNSSubjectAttribute mode.
+	SNSKeyAttribute = "key"
+	// MaxSNSSubjectLength is the longest subject SNS accepts.
+	MaxSNSSubjectLength = 100
+)
+
+// The transport encodings of an SNSPublisher's messages.
+const (
+	// TransportEncodingAttribute is the message attribute that describes
+	// how the body of a message is encoded, set by SNSPublishers in the
+	// TransportEncodingAuto mode. SQSSubscribers decode messages with the
+	// attribute by it, whatever their ConsumeBase64.
+	TransportEncodingAttribute = "transport_encoding"
+	// TransportEncodingBase64 marks bodies that are base64 encoded. It is
+	// also the default mode of an SNSPublisher, which encodes every body
+	// without setting the attribute.
+	TransportEncodingBase64 = "base64"
+	// TransportEncodingIdentity marks bodies that are sent as they are.
+	TransportEncodingIdentity = "identity"
+	// TransportEncodingAuto is the mode of an SNSPublisher that sends JSON
+	// bodies as they are and base64 encodes only binary ones, which SNS
+	// cannot carry, avoiding a third of the size of each JSON message.
+	TransportEncodingAuto = "auto"
+)
+
 // NewSNSPublisher will initiate the SNS client.
 // If no credentials are passed in with the config,
 // the publisher is instantiated with the AWS_ACCESS_KEY
@@ -41,6 +98,20 @@ func NewSNSPublisher(cfg *config.SNS) (*SNSPublisher, error) {
 		return p, errors.New("SNS region is required")
 	}
 
+	switch cfg.SubjectMode {
+	case "", SNSSubjectKey, SNSSubjectOmit, SNSSubjectTruncate, SNSSubjectAttribute:
+		p.subjectMode = cfg.SubjectMode
+	default:
+		return p, errors.New("unknown SNS subject mode: " + cfg.SubjectMode)
+	}
+
+	switch cfg.TransportEncoding {
+	case "", TransportEncodingBase64, TransportEncodingAuto:
+		p.encoding = cfg.TransportEncoding
+	default:
+		return p, errors.New("unknown SNS transport encoding: " + cfg.TransportEncoding)
+	}
+
 	var creds *credentials.Credentials
 	if cfg.AccessKey != "" {
 		creds = credentials.NewStaticCredentials(cfg.AccessKey, cfg.SecretKey, "")
@@ -48,13 +119,130 @@ func NewSNSPublisher(cfg *config.SNS) (*SNSPublisher, error) {
 		creds = credentials.NewEnvCredentials()
 	}
 
-	p.sns = sns.New(session.New(&aws.Config{
+	awsCfg := awsEndpoints(cfg.AWS, &aws.Config{
 		Credentials: creds,
 		Region:      &cfg.Region,
-	}))
+		HTTPClient:  awsHTTPClient(cfg.AWS),
+	})
+	if cfg.Endpoint != "" {
+		awsCfg.Endpoint = &cfg.Endpoint
+	}
+	p.sns = sns.New(session.New(awsCfg))
+	return p, nil
+}
+
+// NewSNSRegionalPublisher will return a RegionalPublisher over the config's
+// Topic in its Region and each of its FailoverTopics, in the region named by
+// its ARN, for active-active deployments.
+func NewSNSRegionalPublisher(cfg *config.SNS) (*RegionalPublisher, error) {
+	pub, err := NewSNSPublisher(cfg)
+	if err != nil {
+		return nil, err
+	}
+	regions := []Region{{Name: cfg.Region, Publisher: pub}}
+	for _, topic := range cfg.FailoverTopics {
+		// arn:aws:sns:<region>:<account>:<name>
+		parts := strings.Split(topic, ":")
+		if len(parts) < 6 || parts[3] == "" {
+			return nil, errors.New("invalid SNS failover topic ARN: " + topic)
+		}
+		rcfg := *cfg
+		rcfg.Topic, rcfg.Region, rcfg.FailoverTopics = topic, parts[3], nil
+		pub, err := NewSNSPublisher(&rcfg)
+		if err != nil {
+			return nil, err
+		}
+		regions = append(regions, Region{Name: rcfg.Region, Publisher: pub})
+	}
+	return NewRegionalPublisher(regions...)
+}
+
+// NewSQSMultiRegionSubscriber will return a MultiRegionSubscriber over the
+// config's QueueName in its Region and each of its FailoverRegions, that
+// keeps the keys of done messages in tokens.
+func NewSQSMultiRegionSubscriber(cfg *config.SQS, tokens PublishTokenStore) (*MultiRegionSubscriber, error) {
+	var regions []Region
+	for _, name := range append([]string{cfg.Region}, cfg.FailoverRegions...) {
+		rcfg := *cfg
+		rcfg.Region, rcfg.FailoverRegions = name, nil
+		sub, err := NewSQSSubscriber(&rcfg)
+		if err != nil {
+			return nil, err
+		}
+		regions = append(regions, Region{Name: name, Subscriber: sub})
+	}
+	return NewMultiRegionSubscriber(tokens, regions...)
+}
+
+// NewSNSSizeLimitPublisher will return a SizeLimitPublisher over an
+// SNSPublisher for the config that handles messages over its MaxMessageSize
+// with its SizeStrategy. With the offload strategy, messages are stored in
+// the bucket of the S3 config.
+func NewSNSSizeLimitPublisher(cfg *config.SNS, s3cfg *config.S3) (*SizeLimitPublisher, error) {
+	pub, err := NewSNSPublisher(cfg)
+	if err != nil {
+		return nil, err
+	}
+	var store objectstore.Store
+	if cfg.SizeStrategy == SizeStrategyOffload {
+		if s3cfg == nil {
+			return nil, errors.New("S3 config is required to offload messages")
+		}
+		if store, err = objectstore.NewS3(s3cfg); err != nil {
+			return nil, err
+		}
+	}
+	p, err := NewSizeLimitPublisher(pub, cfg.MaxMessageSize, cfg.SizeStrategy, store)
+	if err != nil {
+		return nil, err
+	}
+	p.OffloadPrefix = cfg.OffloadPrefix
 	return p, nil
 }
 
+// awsHTTPClient will return an http.Client with a transport tuned by the
+// config's HTTP settings, or nil so the SDK's default client is used if
+// none are set.
+func awsHTTPClient(cfg config.AWS) *http.Client {
+	if cfg.MaxIdleConnsPerHost == 0 && cfg.TLSHandshakeTimeout == 0 && cfg.KeepAlive == 0 {
+		return nil
+	}
+	tlsTimeout := cfg.TLSHandshakeTimeout
+	if tlsTimeout == 0 {
+		tlsTimeout = 10 * time.Second
+	}
+	keepAlive := cfg.KeepAlive
+	if keepAlive == 0 {
+		keepAlive = 30 * time.Second
+	}
+	return &http.Client{
+		Transport: &http.Transport{
+			Proxy: http.ProxyFromEnvironment,
+			Dial: (&net.Dialer{
+				Timeout:   30 * time.Second,
+				KeepAlive: keepAlive,
+			}).Dial,
+			TLSHandshakeTimeout: tlsTimeout,
+			MaxIdleConnsPerHost: cfg.MaxIdleConnsPerHost,
+		},
+	}
+}
+
+// awsEndpoints will set the endpoint options of the config on the client
+// config and return it.
+func awsEndpoints(cfg config.AWS, c *aws.Config) *aws.Config {
+	if cfg.UseFIPSEndpoint {
+		c.UseFIPSEndpoint = endpoints.FIPSEndpointStateEnabled
+	}
+	if cfg.UseDualStackEndpoint {
+		c.UseDualStackEndpoint = endpoints.DualStackEndpointStateEnabled
+	}
+	if cfg.STSRegionalEndpoint {
+		c.STSRegionalEndpoint = endpoints.RegionalSTSEndpoint
+	}
+	return c
+}
+
 // Publish will marshal the proto message and emit it to the SNS topic.
 // The key will be used as the SNS message subject.
 func (p *SNSPublisher) Publish(key string, m proto.Message) error {
@@ -69,14 +257,196 @@ func (p *SNSPublisher) Publish(key string, m proto.Message) error {
 // PublishRaw will emit the byte array to the SNS topic.
 // The key will be used as the SNS message subject.
 func (p *SNSPublisher) PublishRaw(key string, m []byte) error {
+	_, err := p.PublishRawResult(key, m)
+	return err
+}
+
+// PublishRawResult will emit the byte array to the SNS topic like
+// PublishRaw and return the SNS message ID and, for FIFO topics,
+// sequence number.
+func (p *SNSPublisher) PublishRawResult(key string, m []byte) (*PublishResult, error) {
 	msg := &sns.PublishInput{
 		TopicArn: &p.topic,
-		Subject:  &key,
-		Message:  aws.String(base64.StdEncoding.EncodeToString(m)),
 	}
+	p.setSubject(msg, key)
+	p.encode(msg, m)
+
+	out, err := p.sns.Publish(msg)
+	if err != nil {
+		return nil, classify(err)
+	}
+	return &PublishResult{
+		MessageID:      aws.StringValue(out.MessageId),
+		SequenceNumber: aws.StringValue(out.SequenceNumber),
+		Timestamp:      time.Now(),
+	}, nil
+}
+
+// Verify will check that the SNS topic exists and that the credentials may
+// use it by fetching its attributes.
+func (p *SNSPublisher) Verify() error {
+	_, err := p.sns.GetTopicAttributes(&sns.GetTopicAttributesInput{
+		TopicArn: &p.topic,
+	})
+	if err != nil {
+		return &VerifyError{Resource: "sns topic " + p.topic, Err: classify(err)}
+	}
+	return nil
+}
+
+// PublishDeduplicated will emit the byte array to the SNS topic so it is
+// only delivered once for the given token. On FIFO topics, the token is sent
+// as the MessageDeduplicationId and the key as the MessageGroupId. On
+// standard topics, which cannot deduplicate, the token is sent as the
+// 'idempotency_token' message attribute for subscribers to check.
+func (p *SNSPublisher) PublishDeduplicated(key string, m []byte, token string) error {
+	if !strings.HasSuffix(p.topic, ".fifo") {
+		return p.PublishAttributes(key, m, map[string]string{IdempotencyTokenAttribute: token})
+	}
+	msg := &sns.PublishInput{
+		TopicArn:               &p.topic,
+		MessageDeduplicationId: &token,
+		MessageGroupId:         &key,
+	}
+	p.setSubject(msg, key)
+	p.encode(msg, m)
 
 	_, err := p.sns.Publish(msg)
-	return err
+	return classify(err)
+}
+
+// PublishAttributes will emit the byte array to the SNS topic with the
+// attributes as string message attributes. The key will be used as the SNS
+// message subject.
+func (p *SNSPublisher) PublishAttributes(key string, m []byte, attrs map[string]string) error {
+	msg := &sns.PublishInput{
+		TopicArn:          &p.topic,
+		MessageAttributes: map[string]*sns.MessageAttributeValue{},
+	}
+	for k, v := range attrs {
+		msg.MessageAttributes[k] = &sns.MessageAttributeValue{
+			DataType:    aws.String("String"),
+			StringValue: aws.String(v),
+		}
+	}
+	p.setSubject(msg, key)
+	p.encode(msg, m)
+
+	_, err := p.sns.Publish(msg)
+	return classify(err)
+}
+
+// setSubject will send the key as the subject of the message according to
+// the publisher's subject mode.
+func (p *SNSPublisher) setSubject(msg *sns.PublishInput, key string) {
+	switch {
+	case p.subjectMode == SNSSubjectOmit:
+	case p.subjectMode == SNSSubjectTruncate && !validSNSSubject(key):
+		msg.Subject = aws.String(truncateSNSSubject(key))
+	case p.subjectMode == SNSSubjectAttribute && !validSNSSubject(key):
+		if msg.MessageAttributes == nil {
+			msg.MessageAttributes = map[string]*sns.MessageAttributeValue{}
+		}
+		msg.MessageAttributes[SNSKeyAttribute] = &sns.MessageAttributeValue{
+			DataType:    aws.String("String"),
+			StringValue: aws.String(key),
+		}
+	default:
+		msg.Subject = &key
+	}
+}
+
+// validSNSSubject will return true if SNS accepts the subject: up to
+// MaxSNSSubjectLength printable ASCII characters, not starting with a space.
+func validSNSSubject(s string) bool {
+	if s == "" || len(s) > MaxSNSSubjectLength || s[0] == ' ' {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] < ' ' || s[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// truncateSNSSubject will replace the characters SNS rejects in the key and,
+// if it is too long, truncate it with a hash of the whole key.
+func truncateSNSSubject(key string) string {
+	b := []byte(strings.TrimLeft(key, " "))
+	for i, c := range b {
+		if c < ' ' || c > '~' {
+			b[i] = '_'
+		}
+	}
+	if len(b) == 0 {
+		b = []byte("_")
+	}
+	if len(b) <= MaxSNSSubjectLength && len(b) == len(key) {
+		return string(b)
+	}
+	// keep keys that differ past the cut distinct
+	sum := sha256.Sum256([]byte(key))
+	suffix := "-" + hex.EncodeToString(sum[:4])
+	if len(b) > MaxSNSSubjectLength-len(suffix) {
+		b = b[:MaxSNSSubjectLength-len(suffix)]
+	}
+	return string(b) + suffix
+}
+
+// MaxMessageSize will return the most bytes SNS accepts in a message.
+func (p *SNSPublisher) MaxMessageSize() int {
+	return MaxSNSMessageSize
+}
+
+// MessageSize will return how many bytes the message will count for against
+// the SNS limit once encoded, along with its attributes.
+func (p *SNSPublisher) MessageSize(key string, m []byte, attrs map[string]string) int {
+	msg := &sns.PublishInput{MessageAttributes: map[string]*sns.MessageAttributeValue{}}
+	for k, v := range attrs {
+		msg.MessageAttributes[k] = &sns.MessageAttributeValue{
+			DataType:    aws.String("String"),
+			StringValue: aws.String(v),
+		}
+	}
+	p.setSubject(msg, key)
+	p.encode(msg, m)
+
+	n := len(aws.StringValue(msg.Message))
+	for k, v := range msg.MessageAttributes {
+		n += len(k) + len(aws.StringValue(v.DataType)) + len(aws.StringValue(v.StringValue))
+	}
+	return n
+}
+
+// encode will set the body of the message in the publisher's transport
+// encoding, along with its TransportEncodingAttribute in the auto mode.
+func (p *SNSPublisher) encode(msg *sns.PublishInput, m []byte) {
+	if p.encoding != TransportEncodingAuto {
+		msg.Message = aws.String(base64.StdEncoding.EncodeToString(m))
+		return
+	}
+	encoding := TransportEncodingBase64
+	if utf8.Valid(m) && isJSON(m) {
+		encoding = TransportEncodingIdentity
+		msg.Message = aws.String(string(m))
+	} else {
+		msg.Message = aws.String(base64.StdEncoding.EncodeToString(m))
+	}
+	if msg.MessageAttributes == nil {
+		msg.MessageAttributes = map[string]*sns.MessageAttributeValue{}
+	}
+	msg.MessageAttributes[TransportEncodingAttribute] = &sns.MessageAttributeValue{
+		DataType:    aws.String("String"),
+		StringValue: aws.String(encoding),
+	}
+}
+
+// isJSON will return true if m is a single valid JSON value. It stands in
+// for json.Valid, which needs Go 1.9.
+func isJSON(m []byte) bool {
+	var v json.RawMessage
+	return json.Unmarshal(m, &v) == nil
 }
 
 var (
@@ -98,6 +468,26 @@ var (
 	defaultSQSDeleteBufferSize = 0
 
 	defaultSQSConsumeBase64 = true
+
+	// defaultSQSAttributeNames are the system attributes the
+	// SQSSubscriber will request with each message.
+	defaultSQSAttributeNames = []string{
+		SentTimestampAttribute,
+		ApproximateReceiveCountAttribute,
+		AWSTraceHeaderAttribute,
+	}
+	// defaultSQSMessageAttributeNames are the message attributes
+	// the SQSSubscriber will request with each message.
+	defaultSQSMessageAttributeNames = []string{"All"}
+)
+
+const (
+	// ApproximateReceiveCountAttribute is the SQS system attribute holding
+	// the number of times a message has been received.
+	ApproximateReceiveCountAttribute = "ApproximateReceiveCount"
+	// AWSTraceHeaderAttribute is the SQS system attribute holding the
+	// message's X-Ray trace header.
+	AWSTraceHeaderAttribute = "AWSTraceHeader"
 )
 
 func defaultSQSConfig(cfg *config.SQS) {
@@ -120,37 +510,70 @@ func defaultSQSConfig(cfg *config.SQS) {
 	if cfg.ConsumeBase64 == nil {
 		cfg.ConsumeBase64 = &defaultSQSConsumeBase64
 	}
+
+	if cfg.AttributeNames == nil {
+		cfg.AttributeNames = defaultSQSAttributeNames
+	}
+
+	if cfg.MessageAttributeNames == nil {
+		cfg.MessageAttributeNames = defaultSQSMessageAttributeNames
+	}
 }
 
 type (
 	// SQSSubscriber is an SQS client that allows a user to
 	// consume messages via the pubsub.Subscriber interface.
+	// A SQSSubscriber may be started again once it has been stopped.
 	SQSSubscriber struct {
 		sqs sqsiface.SQSAPI
 
 		cfg      *config.SQS
 		queueURL *string
 
-		toDelete chan *deleteRequest
-		// inFlight and stopped are signals to manage delete requests
-		// at shutdown.
+		// inFlight counts the messages that have been emitted
+		// but not yet done, nacked or released.
 		inFlight uint64
-		stopped  uint32
 
-		stop   chan chan error
-		sqsErr error
+		// mu guards the state of the current Start/Stop cycle
+		mu       sync.Mutex
+		stop     chan struct{}
+		stopped  chan struct{}
+		sqsErr   error
+		listener SubscriberListener
 	}
 
 	// SQSMessage is the SQS implementation of `SubscriberMessage`.
 	SQSMessage struct {
 		sub     *SQSSubscriber
+		deletes *sqsDeleter
 		message *sqs.Message
+
+		attrsOnce sync.Once
+		attrs     map[string]string
+		// released is set once the message has given up its in-flight
+		// slot, accessed atomically.
+		released int32
 	}
 
 	deleteRequest struct {
 		entry   *sqs.DeleteMessageBatchRequestEntry
 		receipt chan error
 	}
+
+	// sqsDeleter batches the delete requests of the messages received in a
+	// single Start/Stop cycle of a SQSSubscriber. It is closed once the
+	// cycle's receive loop exits, after which any messages still being
+	// handled are deleted directly.
+	sqsDeleter struct {
+		sqs      sqsiface.SQSAPI
+		queueURL *string
+		bufSize  int
+
+		mu       sync.RWMutex
+		closed   bool
+		requests chan *deleteRequest
+		done     chan struct{}
+	}
 )
 
 // incrementInflight will increment the add in flight count.
@@ -163,23 +586,44 @@ func (s *SQSSubscriber) decrementInFlight() {
 	atomic.AddUint64(&s.inFlight, ^uint64(0))
 }
 
+// InFlight will return the number of received messages that have not yet
+// been marked as done, nacked or released, for use with a Watchdog.
+func (s *SQSSubscriber) InFlight() int64 {
+	return int64(s.inFlightCount())
+}
+
 // inFlightCount returns the number of in-flight requests currently
 // running on this server.
 func (s *SQSSubscriber) inFlightCount() uint64 {
 	return atomic.LoadUint64(&s.inFlight)
 }
 
+// SetListener will set the listener notified when the subscriber receives,
+// acks or nacks a message or backs off an empty queue.
+func (s *SQSSubscriber) SetListener(l SubscriberListener) {
+	s.mu.Lock()
+	s.listener = l
+	s.mu.Unlock()
+}
+
+// emit will notify the listener, if any, of the event.
+func (s *SQSSubscriber) emit(e SubscriberEvent) {
+	s.mu.Lock()
+	l := s.listener
+	s.mu.Unlock()
+	if l != nil {
+		e.Time = time.Now()
+		l.OnSubscriberEvent(e)
+	}
+}
+
 // NewSQSSubscriber will initiate a new Decrypter for the subscriber
 // if a key file is provided. It will also fetch the SQS Queue Url
 // and set up the SQS client.
 func NewSQSSubscriber(cfg *config.SQS) (*SQSSubscriber, error) {
 	var err error
 	defaultSQSConfig(cfg)
-	s := &SQSSubscriber{
-		cfg:      cfg,
-		toDelete: make(chan *deleteRequest),
-		stop:     make(chan chan error, 1),
-	}
+	s := &SQSSubscriber{cfg: cfg}
 
 	if len(cfg.QueueName) == 0 {
 		return s, errors.New("sqs queue name is required")
@@ -191,10 +635,15 @@ func NewSQSSubscriber(cfg *config.SQS) (*SQSSubscriber, error) {
 	} else {
 		creds = credentials.NewEnvCredentials()
 	}
-	s.sqs = sqs.New(session.New(&aws.Config{
+	awsCfg := awsEndpoints(cfg.AWS, &aws.Config{
 		Credentials: creds,
 		Region:      &cfg.Region,
-	}))
+		HTTPClient:  awsHTTPClient(cfg.AWS),
+	})
+	if cfg.Endpoint != "" {
+		awsCfg.Endpoint = &cfg.Endpoint
+	}
+	s.sqs = sqs.New(session.New(awsCfg))
 
 	var urlResp *sqs.GetQueueUrlOutput
 	urlResp, err = s.sqs.GetQueueUrl(&sqs.GetQueueUrlInput{
@@ -202,7 +651,7 @@ func NewSQSSubscriber(cfg *config.SQS) (*SQSSubscriber, error) {
 	})
 
 	if err != nil {
-		return s, err
+		return s, classify(err)
 	}
 
 	s.queueURL = urlResp.QueueUrl
@@ -212,144 +661,382 @@ func NewSQSSubscriber(cfg *config.SQS) (*SQSSubscriber, error) {
 // Message will decode protobufed message bodies and simply return
 // a byte slice containing the message body for all others types.
 func (m *SQSMessage) Message() []byte {
-	if !*m.sub.cfg.ConsumeBase64 {
-		return []byte(*m.message.Body)
+	return m.MessageInto(nil)
+}
+
+// MessageInto will write the message body into buf like Message, reusing
+// its capacity.
+func (m *SQSMessage) MessageInto(buf []byte) []byte {
+	decode := *m.sub.cfg.ConsumeBase64
+	if attr, ok := m.message.MessageAttributes[TransportEncodingAttribute]; ok {
+		switch aws.StringValue(attr.StringValue) {
+		case TransportEncodingIdentity:
+			decode = false
+		case TransportEncodingBase64:
+			decode = true
+		}
+	}
+	if !decode {
+		return append(buf[:0], *m.message.Body...)
 	}
 
-	msgBody, err := base64.StdEncoding.DecodeString(*m.message.Body)
+	msgBody, err := DecodeBase64Into(buf, *m.message.Body, m.sub.cfg.MaxDecodedSize)
 	if err != nil {
 		Log.Warnf("unable to parse message body: %s", err)
 	}
 	return msgBody
 }
 
+// ID will return the ID SQS assigned to the message.
+func (m *SQSMessage) ID() string {
+	return aws.StringValue(m.message.MessageId)
+}
+
+// SentAt will return when the message was sent to the queue, from its
+// SentTimestamp attribute.
+func (m *SQSMessage) SentAt() time.Time {
+	return parseSentTimestamp(aws.StringValue(m.message.Attributes[SentTimestampAttribute]))
+}
+
+// SystemAttributes will return the system attributes requested with the
+// message, like its SentTimestampAttribute.
+func (m *SQSMessage) SystemAttributes() map[string]string {
+	attrs := make(map[string]string, len(m.message.Attributes))
+	for k, v := range m.message.Attributes {
+		attrs[k] = aws.StringValue(v)
+	}
+	return attrs
+}
+
+// ReceiveCount will return the approximate number of times the message has
+// been received, or 0 if its ApproximateReceiveCountAttribute was not
+// requested.
+func (m *SQSMessage) ReceiveCount() int {
+	n, _ := strconv.Atoi(aws.StringValue(m.message.Attributes[ApproximateReceiveCountAttribute]))
+	return n
+}
+
+// TraceHeader will return the message's X-Ray trace header, if it has one
+// and its AWSTraceHeaderAttribute was requested.
+func (m *SQSMessage) TraceHeader() string {
+	return aws.StringValue(m.message.Attributes[AWSTraceHeaderAttribute])
+}
+
+// Attributes will return the message's string message attributes. Only
+// the config's MessageAttributeNames are requested, and they are decoded
+// once, on the first call.
+func (m *SQSMessage) Attributes() map[string]string {
+	m.attrsOnce.Do(func() {
+		m.attrs = make(map[string]string, len(m.message.MessageAttributes))
+		for k, v := range m.message.MessageAttributes {
+			if v.StringValue != nil {
+				m.attrs[k] = *v.StringValue
+			}
+		}
+	})
+	return m.attrs
+}
+
 // Done will queue up a message to be deleted. By default,
 // the `SQSDeleteBufferSize` will be 0, so this will block until the
 // message has been deleted.
 func (m *SQSMessage) Done() error {
-	defer m.sub.decrementInFlight()
-	receipt := make(chan error)
-	m.sub.toDelete <- &deleteRequest{
-		entry: &sqs.DeleteMessageBatchRequestEntry{
-			Id:            m.message.MessageId,
-			ReceiptHandle: m.message.ReceiptHandle,
-		},
-		receipt: receipt,
+	defer m.Release()
+	err := m.deletes.delete(&sqs.DeleteMessageBatchRequestEntry{
+		Id:            m.message.MessageId,
+		ReceiptHandle: m.message.ReceiptHandle,
+	})
+	m.sub.emit(SubscriberEvent{Type: EventAcked, Message: m, Err: err})
+	return err
+}
+
+// Nack will make the message visible on the queue again right away so it
+// can be redelivered without waiting for its visibility timeout.
+func (m *SQSMessage) Nack() error {
+	defer m.Release()
+	_, err := m.sub.sqs.ChangeMessageVisibility(&sqs.ChangeMessageVisibilityInput{
+		QueueUrl:          m.sub.queueURL,
+		ReceiptHandle:     m.message.ReceiptHandle,
+		VisibilityTimeout: aws.Int64(0),
+	})
+	m.sub.emit(SubscriberEvent{Type: EventNacked, Message: m, Err: err})
+	return err
+}
+
+// Release will stop counting the message as in flight without deleting it,
+// leaving it to be redelivered once its visibility timeout passes. It is
+// called by Done and Nack and is safe to call more than once.
+func (m *SQSMessage) Release() {
+	if atomic.CompareAndSwapInt32(&m.released, 0, 1) {
+		m.sub.decrementInFlight()
 	}
-	return <-receipt
 }
 
 // Start will start consuming messages on the SQS queue
 // and emit any messages to the returned channel.
 // If it encounters any issues, it will populate the Err() error
-// and close the returned channel.
+// and close the returned channel. Calling Start while the subscriber is
+// already running will stop it and close its previous channel first.
 func (s *SQSSubscriber) Start() <-chan SubscriberMessage {
+	output, _ := s.start()
+	return output
+}
+
+// StartWithContext will start consuming like Start and stop the subscriber,
+// as Stop would, once ctx is done.
+func (s *SQSSubscriber) StartWithContext(ctx context.Context) <-chan SubscriberMessage {
+	output, stopped := s.start()
+	go func() {
+		select {
+		case <-ctx.Done():
+			s.Stop()
+		case <-stopped:
+		}
+	}()
+	return output
+}
+
+// Restart will start consuming again after Stop, like Start, so a paused
+// Consumer does not hold messages from the queue.
+func (s *SQSSubscriber) Restart() <-chan SubscriberMessage {
+	return s.Start()
+}
+
+// start will begin a new receive cycle and return its output along with
+// a channel that is closed once the cycle has ended. Any cycle that is
+// still running is stopped first so its goroutines are not leaked.
+func (s *SQSSubscriber) start() (<-chan SubscriberMessage, <-chan struct{}) {
 	output := make(chan SubscriberMessage)
-	go s.handleDeletes()
-	go func(s *SQSSubscriber, output chan SubscriberMessage) {
+	stop, stopped := make(chan struct{}), make(chan struct{})
+
+	s.mu.Lock()
+	prevStop, prevStopped := s.stop, s.stopped
+	if prevStop != nil {
+		select {
+		case <-prevStop:
+		default:
+			close(prevStop)
+		}
+	}
+	s.stop, s.stopped = stop, stopped
+	s.mu.Unlock()
+	if prevStopped != nil {
+		// let the previous cycle record any error before clearing it
+		<-prevStopped
+	}
+	s.mu.Lock()
+	s.sqsErr = nil
+	s.mu.Unlock()
+
+	deletes := newSQSDeleter(s)
+
+	go func() {
+		defer close(stopped)
+		// flush any buffered deletes once nothing more will be received
+		defer deletes.close()
 		defer close(output)
-		var (
-			resp *sqs.ReceiveMessageOutput
-			err  error
-		)
-		for {
+		if err := s.receive(output, stop, deletes); err != nil {
+			s.mu.Lock()
+			s.sqsErr = err
+			s.mu.Unlock()
+		}
+	}()
+	return output, stopped
+}
+
+// receive will emit messages from the queue until stop is closed or
+// receiving fails.
+func (s *SQSSubscriber) receive(output chan<- SubscriberMessage, stop <-chan struct{}, deletes *sqsDeleter) error {
+	attrNames := aws.StringSlice(sqsMessageAttributeNames(s.cfg.MessageAttributeNames))
+	for {
+		select {
+		case <-stop:
+			return nil
+		default:
+		}
+
+		// get messages
+		Log.Infof("receiving messages")
+		resp, err := s.sqs.ReceiveMessage(&sqs.ReceiveMessageInput{
+			MaxNumberOfMessages:   s.cfg.MaxMessages,
+			QueueUrl:              s.queueURL,
+			WaitTimeSeconds:       s.cfg.TimeoutSeconds,
+			MessageAttributeNames: attrNames,
+			AttributeNames:        aws.StringSlice(s.cfg.AttributeNames),
+		})
+		if err != nil {
+			// we've encountered a major error
+			// this will set the error value and close the channel
+			// so the user will stop iterating and check the err
+			return classify(err)
+		}
+
+		// if we didn't get any messages, lets chill out for a sec
+		if len(resp.Messages) == 0 {
+			Log.Infof("no messages found. sleeping for %s", s.cfg.SleepInterval)
+			s.emit(SubscriberEvent{Type: EventBackoff, Delay: *s.cfg.SleepInterval})
 			select {
-			case exit := <-s.stop:
-				exit <- nil
-				return
-			default:
-				// get messages
-				Log.Infof("receiving messages")
-				resp, err = s.sqs.ReceiveMessage(&sqs.ReceiveMessageInput{
-					MaxNumberOfMessages: s.cfg.MaxMessages,
-					QueueUrl:            s.queueURL,
-					WaitTimeSeconds:     s.cfg.TimeoutSeconds,
-				})
-				if err != nil {
-					// we've encountered a major error
-					// this will set the error value and close the channel
-					// so the user will stop iterating and check the err
-					s.sqsErr = err
-					go s.Stop()
-					continue
-				}
-
-				// if we didn't get any messages, lets chill out for a sec
-				if len(resp.Messages) == 0 {
-					Log.Infof("no messages found. sleeping for %s", s.cfg.SleepInterval)
-					time.Sleep(*s.cfg.SleepInterval)
-					continue
-				}
-
-				Log.Infof("found %d messages", len(resp.Messages))
-
-				// for each message, pass to output
-				for _, msg := range resp.Messages {
-					output <- &SQSMessage{
-						sub:     s,
-						message: msg,
-					}
-					s.incrementInFlight()
-				}
+			case <-stop:
+				return nil
+			case <-time.After(*s.cfg.SleepInterval):
 			}
+			continue
 		}
-	}(s, output)
-	return output
-}
 
-func (s *SQSSubscriber) handleDeletes() {
-	batchInput := &sqs.DeleteMessageBatchInput{
-		QueueUrl: s.queueURL,
-	}
-	var (
-		err           error
-		entriesBuffer []*sqs.DeleteMessageBatchRequestEntry
-		delRequest    *deleteRequest
-	)
-	for delRequest = range s.toDelete {
-		entriesBuffer = append(entriesBuffer, delRequest.entry)
-		// if the subber is stopped and this is the last request,
-		// flush quit!
-		if s.isStopped() && s.inFlightCount() == 1 {
-			break
+		Log.Infof("found %d messages", len(resp.Messages))
+
+		// for each message, pass to output. any left over when
+		// stopped will be redelivered once their visibility times out
+		for _, msg := range resp.Messages {
+			s.incrementInFlight()
+			smsg := &SQSMessage{sub: s, deletes: deletes, message: msg}
+			s.emit(SubscriberEvent{Type: EventReceived, Message: smsg})
+			select {
+			case output <- smsg:
+			case <-stop:
+				s.decrementInFlight()
+				return nil
+			}
 		}
-		// if buffer is full, send the request
-		if len(entriesBuffer) > *s.cfg.DeleteBufferSize {
-			batchInput.Entries = entriesBuffer
-			_, err = s.sqs.DeleteMessageBatch(batchInput)
-			// cleaer buffer
-			entriesBuffer = []*sqs.DeleteMessageBatchRequestEntry{}
+	}
+}
+
+// sqsMessageAttributeNames will return the message attributes to request,
+// adding the TransportEncodingAttribute if it is not already requested so
+// auto encoded messages can always be decoded.
+func sqsMessageAttributeNames(names []string) []string {
+	for _, name := range names {
+		if name == "All" || name == ".*" || name == TransportEncodingAttribute {
+			return names
 		}
+	}
+	return append(append([]string(nil), names...), TransportEncodingAttribute)
+}
+
+func newSQSDeleter(s *SQSSubscriber) *sqsDeleter {
+	d := &sqsDeleter{
+		sqs:      s.sqs,
+		queueURL: s.queueURL,
+		bufSize:  *s.cfg.DeleteBufferSize,
+		requests: make(chan *deleteRequest),
+		done:     make(chan struct{}),
+	}
+	go d.run()
+	return d
+}
 
-		delRequest.receipt <- err
+// delete will queue up the entry to be deleted in a batch or, if the
+// deleter has been closed, delete it right away.
+func (d *sqsDeleter) delete(entry *sqs.DeleteMessageBatchRequestEntry) error {
+	d.mu.RLock()
+	if d.closed {
+		d.mu.RUnlock()
+		return d.flush([]*sqs.DeleteMessageBatchRequestEntry{entry})
+	}
+	receipt := make(chan error, 1)
+	d.requests <- &deleteRequest{entry: entry, receipt: receipt}
+	d.mu.RUnlock()
+	return <-receipt
+}
+
+func (d *sqsDeleter) run() {
+	defer close(d.done)
+	var entriesBuffer []*sqs.DeleteMessageBatchRequestEntry
+	for req := range d.requests {
+		entriesBuffer = append(entriesBuffer, req.entry)
+		// send the request once the buffer is full
+		if len(entriesBuffer) <= d.bufSize {
+			req.receipt <- nil
+			continue
+		}
+		req.receipt <- d.flush(entriesBuffer)
+		entriesBuffer = nil
 	}
 	// clear any remainders before shutdown
 	if len(entriesBuffer) > 0 {
-		batchInput.Entries = entriesBuffer
-		_, err = s.sqs.DeleteMessageBatch(batchInput)
-		delRequest.receipt <- err
+		if err := d.flush(entriesBuffer); err != nil {
+			Log.Error("unable to delete buffered messages: ", err)
+		}
 	}
 }
 
-func (s *SQSSubscriber) isStopped() bool {
-	return atomic.LoadUint32(&s.stopped) == 1
+func (d *sqsDeleter) flush(entries []*sqs.DeleteMessageBatchRequestEntry) error {
+	_, err := d.sqs.DeleteMessageBatch(&sqs.DeleteMessageBatchInput{
+		QueueUrl: d.queueURL,
+		Entries:  entries,
+	})
+	return classify(err)
+}
+
+// close will stop accepting delete requests and wait for any
+// buffered ones to be sent.
+func (d *sqsDeleter) close() {
+	d.mu.Lock()
+	if !d.closed {
+		d.closed = true
+		close(d.requests)
+	}
+	d.mu.Unlock()
+	<-d.done
 }
 
 // Stop will block until the consumer has stopped consuming
-// messages.
+// messages and any buffered deletes have been sent.
 func (s *SQSSubscriber) Stop() error {
-	if s.isStopped() {
+	s.mu.Lock()
+	stop, stopped := s.stop, s.stopped
+	if stop == nil {
+		s.mu.Unlock()
+		return errors.New("sqs subscriber is not started")
+	}
+	select {
+	case <-stop:
+		s.mu.Unlock()
 		return errors.New("sqs subscriber is already stopped")
+	default:
+		close(stop)
 	}
-	exit := make(chan error)
-	s.stop <- exit
-	atomic.SwapUint32(&s.stopped, uint32(1))
-	return <-exit
+	s.mu.Unlock()
+	<-stopped
+	return nil
 }
 
 // Err will contain any errors that occurred during
 // consumption. This method should be checked after
 // a user encounters a closed channel.
 func (s *SQSSubscriber) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	return s.sqsErr
 }
+
+// Verify will check that the SQS queue is reachable and that the
+// credentials may use it by fetching its attributes.
+func (s *SQSSubscriber) Verify() error {
+	_, err := s.sqs.GetQueueAttributes(&sqs.GetQueueAttributesInput{
+		QueueUrl:       s.queueURL,
+		AttributeNames: []*string{aws.String(sqs.QueueAttributeNameQueueArn)},
+	})
+	if err != nil {
+		return &VerifyError{Resource: "sqs queue " + s.cfg.QueueName, Err: classify(err)}
+	}
+	return nil
+}
+
+// Lag will return the approximate number of messages waiting on the
+// SQS queue. It can be used as the Lag func of a CloudWatchReporter.
+func (s *SQSSubscriber) Lag() (int64, error) {
+	attr := sqs.QueueAttributeNameApproximateNumberOfMessages
+	resp, err := s.sqs.GetQueueAttributes(&sqs.GetQueueAttributesInput{
+		QueueUrl:       s.queueURL,
+		AttributeNames: []*string{&attr},
+	})
+	if err != nil {
+		return 0, err
+	}
+	count, ok := resp.Attributes[attr]
+	if !ok || count == nil {
+		return 0, errors.New("sqs queue attributes are missing " + attr)
+	}
+	return strconv.ParseInt(*count, 10, 64)
+}
@@ -1,13 +1,24 @@
+// +build !noaws
+
 package pubsub
 
 import (
 	"encoding/base64"
 	"errors"
+	"net/http"
 	"reflect"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/NYTimes/gizmo/config"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/endpoints"
 	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/service/sns"
+	"github.com/aws/aws-sdk-go/service/sqs"
 	"github.com/golang/protobuf/proto"
 )
 
@@ -52,6 +63,174 @@ func TestSNSPublisher(t *testing.T) {
 	}
 }
 
+func TestAWSHTTPClient(t *testing.T) {
+	if c := awsHTTPClient(config.AWS{}); c != nil {
+		t.Errorf("expected the SDK default client without any settings, got %v", c)
+	}
+
+	c := awsHTTPClient(config.AWS{MaxIdleConnsPerHost: 100, KeepAlive: time.Minute})
+	if c == nil {
+		t.Fatal("expected a tuned client")
+	}
+	tr := c.Transport.(*http.Transport)
+	if tr.MaxIdleConnsPerHost != 100 {
+		t.Errorf("expected 100 idle conns per host, got %d", tr.MaxIdleConnsPerHost)
+	}
+	if tr.TLSHandshakeTimeout != 10*time.Second {
+		t.Errorf("expected the default TLS handshake timeout of 10s, got %s", tr.TLSHandshakeTimeout)
+	}
+}
+
+func TestAWSEndpoints(t *testing.T) {
+	c := awsEndpoints(config.AWS{UseFIPSEndpoint: true, STSRegionalEndpoint: true}, &aws.Config{})
+	if c.UseFIPSEndpoint != endpoints.FIPSEndpointStateEnabled {
+		t.Errorf("expected FIPS endpoints to be enabled, got %v", c.UseFIPSEndpoint)
+	}
+	if c.UseDualStackEndpoint != endpoints.DualStackEndpointStateUnset {
+		t.Errorf("expected dual-stack endpoints to be unset, got %v", c.UseDualStackEndpoint)
+	}
+	if c.STSRegionalEndpoint != endpoints.RegionalSTSEndpoint {
+		t.Errorf("expected the regional STS endpoint, got %v", c.STSRegionalEndpoint)
+	}
+}
+
+func TestSNSPublisherVerify(t *testing.T) {
+	snstest := &TestSNSAPI{}
+	pub := &SNSPublisher{sns: snstest, topic: "arn:aws:sns:us-east-1:123:cats"}
+	if err := pub.Verify(); err != nil {
+		t.Errorf("expected no error, got %s", err)
+	}
+
+	snstest.Error = awserr.New("AuthorizationError", "not authorized", nil)
+	err := pub.Verify()
+	if ErrorClass(err) != ErrAccessDenied {
+		t.Errorf("expected ErrAccessDenied, got %v", err)
+	}
+	if want := "sns topic arn:aws:sns:us-east-1:123:cats is not accessible"; err == nil || !strings.HasPrefix(err.Error(), want) {
+		t.Errorf("expected %q, got %v", want, err)
+	}
+}
+
+func TestSNSPublisherTransportEncoding(t *testing.T) {
+	snstest := &TestSNSAPI{}
+	pub := &SNSPublisher{sns: snstest, encoding: TransportEncodingAuto}
+	cfg := &config.SQS{}
+	defaultSQSConfig(cfg)
+	sub := &SQSSubscriber{cfg: cfg}
+
+	tests := []struct {
+		body     []byte
+		encoding string
+	}{
+		{[]byte(`{"cat":"meow"}`), TransportEncodingIdentity},
+		{[]byte{0x0a, 0x03, 0xff, 0x00}, TransportEncodingBase64},
+	}
+	for i, test := range tests {
+		if err := pub.PublishRaw("key", test.body); err != nil {
+			t.Fatalf("expected no error, got %s", err)
+		}
+		in := snstest.Published[i]
+		if got := aws.StringValue(in.MessageAttributes[TransportEncodingAttribute].StringValue); got != test.encoding {
+			t.Errorf("expected encoding %s, got %s", test.encoding, got)
+		}
+		if test.encoding == TransportEncodingIdentity && aws.StringValue(in.Message) != string(test.body) {
+			t.Errorf("expected the JSON body as it is, got %s", aws.StringValue(in.Message))
+		}
+
+		// raw message delivery passes the attributes through to SQS
+		msg := &SQSMessage{sub: sub, message: &sqs.Message{
+			Body: in.Message,
+			MessageAttributes: map[string]*sqs.MessageAttributeValue{
+				TransportEncodingAttribute: {
+					DataType:    aws.String("String"),
+					StringValue: aws.String(test.encoding),
+				},
+			},
+		}}
+		if got := msg.Message(); !reflect.DeepEqual(got, test.body) {
+			t.Errorf("expected body %q, got %q", test.body, got)
+		}
+	}
+}
+
+func TestSNSPublisherSubjectMode(t *testing.T) {
+	long := strings.Repeat("k", MaxSNSSubjectLength+20)
+
+	tests := []struct {
+		mode    string
+		key     string
+		subject string
+		attr    string
+	}{
+		{SNSSubjectKey, "key", "key", ""},
+		{SNSSubjectOmit, "key", "", ""},
+		{SNSSubjectTruncate, "key", "key", ""},
+		{SNSSubjectTruncate, "caf\u00e9\n", "caf___", ""},
+		{SNSSubjectAttribute, "key", "key", ""},
+		{SNSSubjectAttribute, long, "", long},
+	}
+	for _, test := range tests {
+		snstest := &TestSNSAPI{}
+		pub := &SNSPublisher{sns: snstest, subjectMode: test.mode}
+		if err := pub.PublishRaw(test.key, []byte("hi there!")); err != nil {
+			t.Fatalf("expected no error, got %s", err)
+		}
+		in := snstest.Published[0]
+		if got := aws.StringValue(in.Subject); got != test.subject {
+			t.Errorf("%s: expected subject %q, got %q", test.mode, test.subject, got)
+		}
+		var attr string
+		if v, ok := in.MessageAttributes[SNSKeyAttribute]; ok {
+			attr = aws.StringValue(v.StringValue)
+		}
+		if attr != test.attr {
+			t.Errorf("%s: expected key attribute %q, got %q", test.mode, test.attr, attr)
+		}
+	}
+
+	// long keys stay distinct once truncated
+	a, b := truncateSNSSubject(long+"a"), truncateSNSSubject(long+"b")
+	if len(a) != MaxSNSSubjectLength || !validSNSSubject(a) {
+		t.Errorf("expected a valid subject of %d characters, got %q", MaxSNSSubjectLength, a)
+	}
+	if a == b {
+		t.Errorf("expected distinct subjects for distinct keys, got %q twice", a)
+	}
+
+	if _, err := NewSNSPublisher(&config.SNS{Topic: "topic", AWS: config.AWS{Region: "us-east-1"}, SubjectMode: "nope"}); err == nil {
+		t.Error("expected an error for an unknown subject mode, got none")
+	}
+}
+
+func TestSNSPublisherMessageSize(t *testing.T) {
+	pub := &SNSPublisher{}
+	// base64 encoding makes the 3 bytes 4, and the attribute counts its type
+	if got := MessageSize(pub, "key", []byte("hi!"), map[string]string{"a": "b"}); got != 12 {
+		t.Errorf("expected a size of 12, got %d", got)
+	}
+	if got := pub.MaxMessageSize(); got != MaxSNSMessageSize {
+		t.Errorf("expected a max of %d, got %d", MaxSNSMessageSize, got)
+	}
+}
+
+func TestSNSPublisherResult(t *testing.T) {
+	snstest := &TestSNSAPI{}
+	pub := &SNSPublisher{sns: snstest}
+
+	res, err := PublishWithResult(pub, "key", []byte("hi there!"))
+	if err != nil {
+		t.Fatal("PublishWithResult returned an unexpected error: ", err)
+	}
+	if res.MessageID != "msg-1" || res.Timestamp.IsZero() {
+		t.Errorf("expected a result for msg-1, got %+v", res)
+	}
+
+	snstest.Error = errors.New("nope")
+	if _, err = pub.PublishRawResult("key", []byte("hi there!")); err == nil {
+		t.Error("expected an error, got none")
+	}
+}
+
 type TestSNSAPI struct {
 	// Error will be returned by the API when Publish() is called.
 	Error error
@@ -61,7 +240,7 @@ type TestSNSAPI struct {
 
 func (t *TestSNSAPI) Publish(i *sns.PublishInput) (*sns.PublishOutput, error) {
 	t.Published = append(t.Published, i)
-	return &sns.PublishOutput{}, t.Error
+	return &sns.PublishOutput{MessageId: aws.String("msg-" + strconv.Itoa(len(t.Published)))}, t.Error
 }
 
 ///////////
@@ -140,7 +319,7 @@ func (t *TestSNSAPI) GetTopicAttributesRequest(*sns.GetTopicAttributesInput) (*r
 	return nil, nil
 }
 func (t *TestSNSAPI) GetTopicAttributes(*sns.GetTopicAttributesInput) (*sns.GetTopicAttributesOutput, error) {
-	return nil, errNotImpl
+	return &sns.GetTopicAttributesOutput{}, t.Error
 }
 func (t *TestSNSAPI) ListEndpointsByPlatformApplicationRequest(*sns.ListEndpointsByPlatformApplicationInput) (*request.Request, *sns.ListEndpointsByPlatformApplicationOutput) {
 	return nil, nil
@@ -1,3 +1,5 @@
+// +build !noaws
+
 package pubsub
 
 import (
@@ -5,12 +7,16 @@ import (
 	"errors"
 	"log"
 	"reflect"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/NYTimes/gizmo/config"
+	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/service/sqs"
 	"github.com/golang/protobuf/proto"
+	"golang.org/x/net/context"
 )
 
 func TestSQSSubscriberNoBase64(t *testing.T) {
@@ -47,10 +53,8 @@ func TestSQSSubscriberNoBase64(t *testing.T) {
 	cfg := &config.SQS{ConsumeBase64: &fals}
 	defaultSQSConfig(cfg)
 	sub := &SQSSubscriber{
-		sqs:      sqstest,
-		cfg:      cfg,
-		toDelete: make(chan *deleteRequest),
-		stop:     make(chan chan error, 1),
+		sqs: sqstest,
+		cfg: cfg,
 	}
 
 	queue := sub.Start()
@@ -71,10 +75,8 @@ func TestSQSReceiveError(t *testing.T) {
 	cfg := &config.SQS{ConsumeBase64: &fals}
 	defaultSQSConfig(cfg)
 	sub := &SQSSubscriber{
-		sqs:      sqstest,
-		cfg:      cfg,
-		toDelete: make(chan *deleteRequest),
-		stop:     make(chan chan error, 1),
+		sqs: sqstest,
+		cfg: cfg,
 	}
 
 	queue := sub.Start()
@@ -108,10 +110,8 @@ func TestSQSDoneAfterStop(t *testing.T) {
 	cfg := &config.SQS{ConsumeBase64: &fals}
 	defaultSQSConfig(cfg)
 	sub := &SQSSubscriber{
-		sqs:      sqstest,
-		cfg:      cfg,
-		toDelete: make(chan *deleteRequest),
-		stop:     make(chan chan error, 1),
+		sqs: sqstest,
+		cfg: cfg,
 	}
 
 	queue := sub.Start()
@@ -131,6 +131,366 @@ func TestSQSDoneAfterStop(t *testing.T) {
 	}
 }
 
+func TestSQSSubscriberBackoffEvent(t *testing.T) {
+	sleep := time.Millisecond
+	cfg := &config.SQS{SleepInterval: &sleep}
+	defaultSQSConfig(cfg)
+	sub := &SQSSubscriber{
+		sqs: &TestSQSAPI{},
+		cfg: cfg,
+	}
+	backoffs := make(chan time.Duration, 1)
+	sub.SetListener(SubscriberListenerFunc(func(e SubscriberEvent) {
+		if e.Type != EventBackoff {
+			return
+		}
+		select {
+		case backoffs <- e.Delay:
+		default:
+		}
+	}))
+
+	sub.Start()
+	select {
+	case delay := <-backoffs:
+		if delay != sleep {
+			t.Errorf("expected a backoff of %s, got %s", sleep, delay)
+		}
+	case <-time.After(time.Second):
+		t.Error("expected a backoff event for the empty queue")
+	}
+	sub.Stop()
+}
+
+func TestObservedSQSSubscriber(t *testing.T) {
+	body := "observed"
+	sleep := time.Millisecond
+	fals := false
+	// buffer the delete so it is not sent to the fake until stopping
+	buffer := 5
+	cfg := &config.SQS{SleepInterval: &sleep, ConsumeBase64: &fals, DeleteBufferSize: &buffer}
+	defaultSQSConfig(cfg)
+	sqstest := &TestSQSAPI{Messages: [][]*sqs.Message{{
+		{Body: &body, ReceiptHandle: &body},
+	}}}
+	l := &testListener{}
+	sub := NewObservedSubscriber(&SQSSubscriber{sqs: sqstest, cfg: cfg}, l)
+
+	msg := <-sub.Start()
+	if _, ok := msg.(*SQSMessage); !ok {
+		t.Errorf("expected the SQS message as it is, got %T", msg)
+	}
+	if err := msg.Done(); err != nil {
+		t.Errorf("expected no error, got %s", err)
+	}
+	if err := sub.Stop(); err != nil {
+		t.Fatal("unexpected error stopping subscriber: ", err)
+	}
+
+	var got []SubscriberEventType
+	for _, e := range l.types() {
+		if e != EventBackoff {
+			got = append(got, e)
+		}
+	}
+	want := []SubscriberEventType{EventStarted, EventReceived, EventAcked, EventStopping, EventStopped}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected events %v, got %v", want, got)
+	}
+}
+
+func TestSQSSubscriberAttributes(t *testing.T) {
+	body := "with attributes"
+	sqstest := &TestSQSAPI{
+		Messages: [][]*sqs.Message{
+			[]*sqs.Message{
+				&sqs.Message{
+					Body:          &body,
+					ReceiptHandle: &body,
+					Attributes: map[string]*string{
+						SentTimestampAttribute:           aws.String("1500000000000"),
+						ApproximateReceiveCountAttribute: aws.String("3"),
+						AWSTraceHeaderAttribute:          aws.String("Root=1-5759e988-bd862e3fe1be46a994272793"),
+					},
+				},
+			},
+		},
+	}
+
+	fals := false
+	cfg := &config.SQS{ConsumeBase64: &fals, MessageAttributeNames: []string{"trace-id"}}
+	defaultSQSConfig(cfg)
+	sub := &SQSSubscriber{
+		sqs: sqstest,
+		cfg: cfg,
+	}
+
+	msg := (<-sub.Start()).(*SQSMessage)
+	sub.Stop()
+
+	if got := aws.StringValueSlice(sqstest.Received.AttributeNames); !reflect.DeepEqual(got, defaultSQSAttributeNames) {
+		t.Errorf("expected attribute names %v, got %v", defaultSQSAttributeNames, got)
+	}
+	// the transport encoding is always requested so messages can be decoded
+	want := []string{"trace-id", TransportEncodingAttribute}
+	if got := aws.StringValueSlice(sqstest.Received.MessageAttributeNames); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected message attribute names %v, got %v", want, got)
+	}
+	if got := msg.ReceiveCount(); got != 3 {
+		t.Errorf("expected a receive count of 3, got %d", got)
+	}
+	if got := msg.SentAt(); !got.Equal(time.Unix(1500000000, 0)) {
+		t.Errorf("expected to be sent at %s, got %s", time.Unix(1500000000, 0), got)
+	}
+	if got := msg.TraceHeader(); got != "Root=1-5759e988-bd862e3fe1be46a994272793" {
+		t.Errorf("expected trace header, got %q", got)
+	}
+	if got := msg.SystemAttributes()[ApproximateReceiveCountAttribute]; got != "3" {
+		t.Errorf("expected system attribute '3', got %q", got)
+	}
+}
+
+func TestSQSSubscriberTransportEncoding(t *testing.T) {
+	body := `{"cat":"meow"}`
+	sqstest := &TestSQSAPI{
+		Messages: [][]*sqs.Message{
+			[]*sqs.Message{
+				&sqs.Message{
+					Body:          &body,
+					ReceiptHandle: &body,
+					MessageAttributes: map[string]*sqs.MessageAttributeValue{
+						TransportEncodingAttribute: {
+							DataType:    aws.String("String"),
+							StringValue: aws.String(TransportEncodingIdentity),
+						},
+					},
+				},
+			},
+		},
+	}
+
+	// the attribute should be requested even when it is not configured
+	tru := true
+	cfg := &config.SQS{ConsumeBase64: &tru, MessageAttributeNames: []string{"trace-id"}}
+	defaultSQSConfig(cfg)
+	sub := &SQSSubscriber{
+		sqs: sqstest,
+		cfg: cfg,
+	}
+
+	msg := <-sub.Start()
+	sub.Stop()
+
+	names := aws.StringValueSlice(sqstest.Received.MessageAttributeNames)
+	var requested bool
+	for _, name := range names {
+		requested = requested || name == TransportEncodingAttribute
+	}
+	if !requested {
+		t.Errorf("expected %q to be requested, got %v", TransportEncodingAttribute, names)
+	}
+	if got := string(msg.Message()); got != body {
+		t.Errorf("expected the identity encoded body %q, got %q", body, got)
+	}
+	if len(cfg.MessageAttributeNames) != 1 {
+		t.Errorf("expected the configured attribute names to be left as is, got %v", cfg.MessageAttributeNames)
+	}
+
+	if got := sqsMessageAttributeNames([]string{"All"}); !reflect.DeepEqual(got, []string{"All"}) {
+		t.Errorf("expected all attributes to be requested as is, got %v", got)
+	}
+}
+
+func TestSQSSubscriberRestart(t *testing.T) {
+	test1 := "first"
+	test2 := "second"
+	sqstest := &TestSQSAPI{
+		Messages: [][]*sqs.Message{
+			[]*sqs.Message{
+				&sqs.Message{
+					Body:          &test1,
+					ReceiptHandle: &test1,
+				},
+			},
+			[]*sqs.Message{
+				&sqs.Message{
+					Body:          &test2,
+					ReceiptHandle: &test2,
+				},
+			},
+		},
+	}
+
+	fals := false
+	buffer := 5
+	cfg := &config.SQS{ConsumeBase64: &fals, DeleteBufferSize: &buffer}
+	defaultSQSConfig(cfg)
+	sub := &SQSSubscriber{
+		sqs: sqstest,
+		cfg: cfg,
+	}
+
+	queue := sub.Start()
+	first := <-queue
+	first.Done()
+	if err := sub.Stop(); err != nil {
+		t.Fatal("unexpected error stopping subscriber: ", err)
+	}
+	if err := sub.Stop(); err == nil {
+		t.Error("expected an error stopping a stopped subscriber")
+	}
+	// the buffered delete should be flushed on stop
+	if len(sqstest.Deleted) != 1 {
+		t.Fatalf("expected 1 deleted message after stopping, got %d", len(sqstest.Deleted))
+	}
+	if _, ok := <-queue; ok {
+		t.Error("expected the first channel to be closed")
+	}
+
+	// the second message may have been received but not emitted before
+	// stopping, so redeliver it as SQS would once its visibility times out
+	sqstest.Offset = 1
+	queue = sub.Start()
+	second := <-queue
+	if got := string(second.Message()); got != test2 {
+		t.Errorf("expected %q after restarting, got %q", test2, got)
+	}
+	if err := sub.Stop(); err != nil {
+		t.Fatal("unexpected error stopping subscriber: ", err)
+	}
+	// messages done after stopping are deleted right away
+	second.Done()
+	if len(sqstest.Deleted) != 2 || *sqstest.Deleted[1].ReceiptHandle != test2 {
+		t.Errorf("expected %q to be deleted, got %d deleted", test2, len(sqstest.Deleted))
+	}
+	if n := sub.InFlight(); n != 0 {
+		t.Errorf("expected no messages in flight, got %d", n)
+	}
+}
+
+func TestSQSSubscriberStartTwice(t *testing.T) {
+	fals := false
+	cfg := &config.SQS{ConsumeBase64: &fals}
+	defaultSQSConfig(cfg)
+	sub := &SQSSubscriber{
+		sqs: &TestSQSAPI{},
+		cfg: cfg,
+	}
+
+	first := sub.Start()
+	second := sub.Start()
+	select {
+	case _, ok := <-first:
+		if ok {
+			t.Error("expected no messages from the first channel")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected the first channel to close once started again")
+	}
+
+	if err := sub.Stop(); err != nil {
+		t.Fatal("unexpected error stopping subscriber: ", err)
+	}
+	if _, ok := <-second; ok {
+		t.Error("expected the second channel to be closed")
+	}
+	if err := sub.Err(); err != nil {
+		t.Error("unexpected subscriber error: ", err)
+	}
+}
+
+func TestConsumerPausesSQSSubscriber(t *testing.T) {
+	test1 := "first"
+	test2 := "second"
+	sqstest := &TestSQSAPI{
+		Messages: [][]*sqs.Message{
+			[]*sqs.Message{
+				&sqs.Message{
+					Body:          &test1,
+					ReceiptHandle: &test1,
+				},
+			},
+			[]*sqs.Message{
+				&sqs.Message{
+					Body:          &test2,
+					ReceiptHandle: &test2,
+				},
+			},
+		},
+	}
+
+	fals := false
+	cfg := &config.SQS{ConsumeBase64: &fals}
+	defaultSQSConfig(cfg)
+	sub := &SQSSubscriber{
+		sqs: sqstest,
+		cfg: cfg,
+	}
+
+	var enabled, handled int32
+	c := NewConsumer(sub, func(ctx context.Context, msg SubscriberMessage) error {
+		atomic.AddInt32(&handled, 1)
+		return nil
+	})
+	c.PauseInterval = time.Millisecond
+	c.Enabled = func() bool {
+		return atomic.LoadInt32(&enabled) == 1
+	}
+	go c.Run()
+
+	// the subscriber should be stopped rather than left holding a
+	// message whose visibility would expire while paused
+	time.Sleep(20 * time.Millisecond)
+	sub.mu.Lock()
+	stopped := sub.stopped
+	sub.mu.Unlock()
+	select {
+	case <-stopped:
+	default:
+		t.Error("expected the subscriber to be stopped while paused")
+	}
+	if n := sub.InFlight(); n != 0 {
+		t.Errorf("expected no messages in flight while paused, got %d", n)
+	}
+	if got := atomic.LoadInt32(&handled); got != 0 {
+		t.Errorf("expected no messages handled while paused, got %d", got)
+	}
+
+	// any messages drained while stopping were nacked, so redeliver
+	// them as SQS would
+	sqstest.Offset = 0
+	atomic.StoreInt32(&enabled, 1)
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) && atomic.LoadInt32(&handled) < 2 {
+		time.Sleep(time.Millisecond)
+	}
+	if err := c.Stop(); err != nil {
+		t.Error("unexpected error stopping consumer: ", err)
+	}
+	if got := atomic.LoadInt32(&handled); got != 2 {
+		t.Errorf("expected 2 messages handled once the consumer was enabled, got %d", got)
+	}
+}
+
+func TestSQSMessageRelease(t *testing.T) {
+	sub := &SQSSubscriber{sqs: &TestSQSAPI{}}
+	sub.incrementInFlight()
+	sub.incrementInFlight()
+	released := &SQSMessage{sub: sub, message: &sqs.Message{}}
+	nacked := &SQSMessage{sub: sub, message: &sqs.Message{}}
+
+	released.Release()
+	released.Release()
+	if n := sub.InFlight(); n != 1 {
+		t.Errorf("expected 1 message in flight after releasing twice, got %d", n)
+	}
+	nacked.Nack()
+	nacked.Release()
+	if n := sub.InFlight(); n != 0 {
+		t.Errorf("expected no messages in flight after nacking, got %d", n)
+	}
+}
+
 func verifySQSSub(t *testing.T, queue <-chan SubscriberMessage, testsqs *TestSQSAPI, want string, index int) {
 	gotRaw := <-queue
 	got := string(gotRaw.Message())
@@ -183,10 +543,8 @@ func TestSQSSubscriber(t *testing.T) {
 	cfg := &config.SQS{}
 	defaultSQSConfig(cfg)
 	sub := &SQSSubscriber{
-		sqs:      sqstest,
-		cfg:      cfg,
-		toDelete: make(chan *deleteRequest),
-		stop:     make(chan chan error, 1),
+		sqs: sqstest,
+		cfg: cfg,
 	}
 
 	queue := sub.Start()
@@ -268,10 +626,8 @@ func BenchmarkSQSSubscriber_Proto(b *testing.B) {
 	cfg := &config.SQS{}
 	defaultSQSConfig(cfg)
 	sub := &SQSSubscriber{
-		sqs:      sqstest,
-		cfg:      cfg,
-		toDelete: make(chan *deleteRequest),
-		stop:     make(chan chan error, 1),
+		sqs: sqstest,
+		cfg: cfg,
 	}
 	queue := sub.Start()
 	for i := 0; i < b.N; i++ {
@@ -289,9 +645,14 @@ type TestSQSAPI struct {
 	Messages [][]*sqs.Message
 	Deleted  []*sqs.DeleteMessageBatchRequestEntry
 	Err      error
+	// Attributes will be returned by GetQueueAttributes
+	Attributes map[string]*string
+	// Received is the input of the last ReceiveMessage call
+	Received *sqs.ReceiveMessageInput
 }
 
-func (s *TestSQSAPI) ReceiveMessage(*sqs.ReceiveMessageInput) (*sqs.ReceiveMessageOutput, error) {
+func (s *TestSQSAPI) ReceiveMessage(in *sqs.ReceiveMessageInput) (*sqs.ReceiveMessageOutput, error) {
+	s.Received = in
 	if s.Offset >= len(s.Messages) {
 		return &sqs.ReceiveMessageOutput{}, s.Err
 	}
@@ -355,7 +716,10 @@ func (s *TestSQSAPI) GetQueueAttributesRequest(*sqs.GetQueueAttributesInput) (*r
 	return nil, nil
 }
 func (s *TestSQSAPI) GetQueueAttributes(*sqs.GetQueueAttributesInput) (*sqs.GetQueueAttributesOutput, error) {
-	return nil, errNotImpl
+	if s.Attributes == nil {
+		return nil, errNotImpl
+	}
+	return &sqs.GetQueueAttributesOutput{Attributes: s.Attributes}, nil
 }
 func (s *TestSQSAPI) GetQueueUrlRequest(*sqs.GetQueueUrlInput) (*request.Request, *sqs.GetQueueUrlOutput) {
 	return nil, nil
@@ -0,0 +1,105 @@
+package pubsub
+
+import (
+	"fmt"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// DefaultBatchWait is how long a batch consumer will wait to fill a batch
+// if no wait is given.
+var DefaultBatchWait = time.Second
+
+// BatchHandler is a func for processing a batch of messages from a Subscriber.
+// If the handler returns nil, every message in the batch will be marked as
+// Done. Otherwise, they will all be left to be redelivered by the Subscriber.
+type BatchHandler func(context.Context, []SubscriberMessage) error
+
+// NewBatchConsumer will return a Consumer that passes messages from the
+// Subscriber to the given handler in batches, for handlers doing bulk work
+// like multi-row inserts. A batch is handled once it has size messages or
+// wait has passed since its first message arrived.
+//
+// The Consumer's Concurrency is the max number of messages being batched or
+// handled at once and defaults to size, so one batch is handled at a time.
+// Set it to a multiple of size to handle several batches at once.
+func NewBatchConsumer(sub Subscriber, handler BatchHandler, size int, wait time.Duration) *Consumer {
+	if size < 1 {
+		size = 1
+	}
+	if wait == 0 {
+		wait = DefaultBatchWait
+	}
+	b := &batcher{handler: handler, size: size, wait: wait}
+	c := NewConsumer(sub, b.add)
+	c.Concurrency = size
+	return c
+}
+
+// batcher assembles the messages given to its add method into batches. Each
+// call to add will block until the batch its message was added to has been
+// handled and return the batch's result.
+type batcher struct {
+	handler BatchHandler
+	size    int
+	wait    time.Duration
+
+	mu  sync.Mutex
+	cur *batch
+}
+
+type batch struct {
+	msgs  []SubscriberMessage
+	timer *time.Timer
+	err   error
+	done  chan struct{}
+}
+
+func (b *batcher) add(ctx context.Context, msg SubscriberMessage) error {
+	b.mu.Lock()
+	bt := b.cur
+	if bt == nil {
+		bt = &batch{done: make(chan struct{})}
+		bt.timer = time.AfterFunc(b.wait, func() { b.flush(bt) })
+		b.cur = bt
+	}
+	bt.msgs = append(bt.msgs, msg)
+	full := len(bt.msgs) >= b.size
+	if full {
+		b.cur = nil
+	}
+	b.mu.Unlock()
+
+	if full {
+		bt.timer.Stop()
+		b.run(ctx, bt)
+	}
+	<-bt.done
+	return bt.err
+}
+
+// flush will handle the batch if it has not filled up already.
+func (b *batcher) flush(bt *batch) {
+	b.mu.Lock()
+	if b.cur != bt {
+		b.mu.Unlock()
+		return
+	}
+	b.cur = nil
+	b.mu.Unlock()
+	b.run(context.Background(), bt)
+}
+
+func (b *batcher) run(ctx context.Context, bt *batch) {
+	defer close(bt.done)
+	defer func() {
+		if x := recover(); x != nil {
+			Log.Errorf("consumer batch handler panic: %v\n%s", x, debug.Stack())
+			bt.err = fmt.Errorf("batch handler panic: %v", x)
+		}
+	}()
+	bt.err = b.handler(ctx, bt.msgs)
+}
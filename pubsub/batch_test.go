@@ -0,0 +1,74 @@
+package pubsub
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+func TestBatchConsumer(t *testing.T) {
+	sub := newTestChanSubscriber()
+	var (
+		mu      sync.Mutex
+		batches [][]string
+	)
+	c := NewBatchConsumer(sub, func(ctx context.Context, msgs []SubscriberMessage) error {
+		var (
+			batch []string
+			err   error
+		)
+		for _, msg := range msgs {
+			batch = append(batch, string(msg.Message()))
+			if string(msg.Message()) == "bad" {
+				err = errors.New("bad batch")
+			}
+		}
+		mu.Lock()
+		batches = append(batches, batch)
+		mu.Unlock()
+		return err
+	}, 3, 20*time.Millisecond)
+
+	go c.Run()
+	var msgs []*testConsumerMessage
+	send := func(bodies ...string) {
+		for _, body := range bodies {
+			msg := &testConsumerMessage{msg: []byte(body)}
+			msgs = append(msgs, msg)
+			sub.msgs <- msg
+		}
+	}
+	// a full batch, a failed batch and a partial batch flushed by time
+	send("a", "b", "c")
+	send("bad", "d", "e")
+	send("f")
+	time.Sleep(50 * time.Millisecond)
+	if err := c.Stop(); err != nil {
+		t.Error("unexpected error stopping consumer: ", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(batches) != 3 {
+		t.Fatalf("expected 3 batches, got %v", batches)
+	}
+	if len(batches[0]) != 3 || len(batches[1]) != 3 || len(batches[2]) != 1 {
+		t.Errorf("expected batches of 3, 3 and 1, got %v", batches)
+	}
+	for _, msg := range msgs {
+		want := int32(1)
+		if s := string(msg.msg); s == "bad" || s == "d" || s == "e" {
+			want = 0
+		}
+		if got := atomic.LoadInt32(&msg.doned); got != want {
+			t.Errorf("expected %s done to be %d, got %d", msg.msg, want, got)
+		}
+	}
+	if stats := c.Stats(); stats.Handled != 7 || stats.Failed != 3 {
+		t.Errorf("expected 7 handled and 3 failed, got %+v", stats)
+	}
+}
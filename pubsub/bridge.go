@@ -0,0 +1,109 @@
+package pubsub
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// ErrSkipMessage can be returned by a BridgeOptions.Transform to mark a
+// message as done without publishing it.
+var ErrSkipMessage = errors.New("skip message")
+
+// BridgeOptions configure how a Bridge relays messages.
+type BridgeOptions struct {
+	// Transform is an optional hook to filter or rewrite each message before
+	// it is published. It returns the key and body to publish. If it returns
+	// ErrSkipMessage, the message is done without being published. By default,
+	// messages are published with their original body and, if the message
+	// has a Key() method, their original key.
+	Transform func(SubscriberMessage) (key string, body []byte, err error)
+	// Concurrency is the max number of messages that will be relayed at
+	// once. Defaults to 1.
+	Concurrency int
+	// RateLimit is the max number of messages that will be published per
+	// second. Unlimited if 0.
+	RateLimit float64
+	// Failures is an optional Publisher that messages will be sent to, with
+	// their original key and body, if they fail to be transformed or
+	// published. If the message reaches Failures, it is marked as done.
+	// Otherwise failed messages are left to be redelivered by the Subscriber.
+	Failures Publisher
+}
+
+// Bridge will return a Consumer that relays every message from src to dst,
+// for jobs like mirroring a queue to a topic. Messages are only marked as done
+// once they have been published, and since a slow Publisher will hold up
+// the handler, src will not be read faster than dst can accept messages.
+//
+//	bridge := pubsub.Bridge(sqsSub, kafkaPub, pubsub.BridgeOptions{
+//		Concurrency: 10,
+//		RateLimit:   500,
+//	})
+//	go bridge.Run()
+//	defer bridge.Stop()
+func Bridge(src Subscriber, dst Publisher, opts BridgeOptions) *Consumer {
+	transform := opts.Transform
+	if transform == nil {
+		transform = passthrough
+	}
+	var limit *rateLimiter
+	if opts.RateLimit > 0 {
+		limit = &rateLimiter{interval: time.Duration(float64(time.Second) / opts.RateLimit)}
+	}
+
+	c := NewConsumer(src, func(ctx context.Context, msg SubscriberMessage) error {
+		key, body, err := transform(msg)
+		if err == ErrSkipMessage {
+			return nil
+		}
+		if err == nil {
+			if limit != nil {
+				limit.wait()
+			}
+			err = dst.PublishRaw(key, body)
+		}
+		if err == nil || opts.Failures == nil {
+			return err
+		}
+		Log.Warn("unable to relay message, sending to failures: ", err)
+		key, _, _ = passthrough(msg)
+		return opts.Failures.PublishRaw(key, msg.Message())
+	})
+	c.Concurrency = opts.Concurrency
+	return c
+}
+
+// passthrough is the default Transform, which keeps the message's original
+// key and body.
+func passthrough(msg SubscriberMessage) (string, []byte, error) {
+	var key string
+	if k, ok := msg.(interface {
+		Key() string
+	}); ok {
+		key = k.Key()
+	}
+	return key, msg.Message(), nil
+}
+
+// rateLimiter will space out callers of wait by at least the interval.
+type rateLimiter struct {
+	interval time.Duration
+
+	mu   sync.Mutex
+	next time.Time
+}
+
+func (l *rateLimiter) wait() {
+	l.mu.Lock()
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	delay := l.next.Sub(now)
+	l.next = l.next.Add(l.interval)
+	l.mu.Unlock()
+	time.Sleep(delay)
+}
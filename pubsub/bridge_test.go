@@ -0,0 +1,55 @@
+package pubsub_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/NYTimes/gizmo/pubsub"
+	"github.com/NYTimes/gizmo/pubsub/pubsubtest"
+)
+
+func TestBridge(t *testing.T) {
+	sub := &pubsubtest.TestSubscriber{JSONMessages: []interface{}{"a", "skip", "bad", "c"}}
+	dst := &pubsubtest.TestPublisher{}
+	failures := &pubsubtest.TestPublisher{}
+
+	bridge := pubsub.Bridge(sub, dst, pubsub.BridgeOptions{
+		Transform: func(msg pubsub.SubscriberMessage) (string, []byte, error) {
+			switch string(msg.Message()) {
+			case `"skip"`:
+				return "", nil, pubsub.ErrSkipMessage
+			case `"bad"`:
+				return "", nil, errors.New("bad message")
+			}
+			return "cats", bytes.ToUpper(msg.Message()), nil
+		},
+		RateLimit: 100,
+		Failures:  failures,
+	})
+
+	start := time.Now()
+	if err := bridge.Run(); err != nil {
+		t.Fatal("unexpected error: ", err)
+	}
+	// the 2 publishes are spaced out by 10ms
+	if took := time.Since(start); took < 10*time.Millisecond {
+		t.Errorf("expected publishes to be rate limited, took %s", took)
+	}
+
+	if len(dst.Published) != 2 {
+		t.Fatalf("expected 2 messages relayed, got %d", len(dst.Published))
+	}
+	for i, want := range []string{`"A"`, `"C"`} {
+		if got := dst.Published[i]; got.Key != "cats" || string(got.Body) != want {
+			t.Errorf("expected %s published with key 'cats', got %s with %q", want, got.Body, got.Key)
+		}
+	}
+	if len(failures.Published) != 1 || string(failures.Published[0].Body) != `"bad"` {
+		t.Errorf("expected the bad message to be sent to failures, got %+v", failures.Published)
+	}
+	if stats := bridge.Stats(); stats.Handled != 4 || stats.Failed != 0 {
+		t.Errorf("expected 4 messages handled without failures, got %+v", stats)
+	}
+}
@@ -0,0 +1,196 @@
+package pubsub
+
+import (
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+)
+
+var (
+	// DefaultBufferMaxMessages is how many messages a BufferedPublisher
+	// will hold before flushing if no MaxMessages is given.
+	DefaultBufferMaxMessages = 10
+	// DefaultBufferMaxBytes is how many bytes of messages a
+	// BufferedPublisher will hold before flushing if no MaxBytes is given.
+	DefaultBufferMaxBytes = 1 << 20
+	// DefaultBufferFlushInterval is how often a BufferedPublisher will
+	// flush if no FlushInterval is given.
+	DefaultBufferFlushInterval = time.Second
+)
+
+// BufferOptions configure a BufferedPublisher.
+type BufferOptions struct {
+	// MaxMessages is the number of messages that will trigger a flush and
+	// the max that will be sent in one batch. Defaults to
+	// DefaultBufferMaxMessages.
+	MaxMessages int
+	// MaxBytes is the max total size of the buffered messages. A publish
+	// that would exceed it will flush first. Defaults to
+	// DefaultBufferMaxBytes.
+	MaxBytes int
+	// FlushInterval is how often the buffer will be flushed in the
+	// background. Defaults to DefaultBufferFlushInterval.
+	FlushInterval time.Duration
+}
+
+// BufferedPublisher is a Publisher that buffers messages and publishes them
+// together once the buffer is full or the FlushInterval passes, so bursts of
+// small publishes become fewer, batched calls if the underlying Publisher is
+// a BatchPublisher. Memory is bounded by MaxBytes: a publish that would
+// exceed it flushes first and fails if the flush does. Messages that fail to
+// flush stay buffered and are retried on the next flush, and Close will
+// flush anything left, so it should be called on shutdown, like with
+// server.OnStop.
+type BufferedPublisher struct {
+	pub         Publisher
+	maxMessages int
+	maxBytes    int
+
+	mu     sync.Mutex
+	buffer []stagedMessage
+	size   int
+
+	stop     chan struct{}
+	stopOnce sync.Once
+	done     chan struct{}
+}
+
+// NewBufferedPublisher will return a BufferedPublisher that flushes to pub
+// and start flushing it in the background.
+func NewBufferedPublisher(pub Publisher, opts BufferOptions) *BufferedPublisher {
+	if opts.MaxMessages < 1 {
+		opts.MaxMessages = DefaultBufferMaxMessages
+	}
+	if opts.MaxBytes < 1 {
+		opts.MaxBytes = DefaultBufferMaxBytes
+	}
+	if opts.FlushInterval == 0 {
+		opts.FlushInterval = DefaultBufferFlushInterval
+	}
+	p := &BufferedPublisher{
+		pub:         pub,
+		maxMessages: opts.MaxMessages,
+		maxBytes:    opts.MaxBytes,
+		stop:        make(chan struct{}),
+		done:        make(chan struct{}),
+	}
+	go p.run(opts.FlushInterval)
+	return p
+}
+
+// Publish will marshal the proto message and buffer it.
+func (p *BufferedPublisher) Publish(key string, m proto.Message) error {
+	mb, err := proto.Marshal(m)
+	if err != nil {
+		return err
+	}
+	return p.PublishRaw(key, mb)
+}
+
+// PublishRaw will buffer the byte array, flushing first if it would not fit
+// and after if the buffer is then full.
+func (p *BufferedPublisher) PublishRaw(key string, m []byte) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.buffer) > 0 && p.size+len(m) > p.maxBytes {
+		if err := p.flush(); err != nil {
+			return err
+		}
+	}
+	p.buffer = append(p.buffer, stagedMessage{key, m})
+	p.size += len(m)
+	if len(p.buffer) >= p.maxMessages || p.size >= p.maxBytes {
+		return p.flush()
+	}
+	return nil
+}
+
+// Len will return the number of messages currently buffered.
+func (p *BufferedPublisher) Len() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.buffer)
+}
+
+// Flush will publish all buffered messages. Any that fail to publish are
+// kept to be retried and the first error is returned.
+func (p *BufferedPublisher) Flush() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.flush()
+}
+
+// Close will stop flushing in the background and flush any buffered
+// messages.
+func (p *BufferedPublisher) Close() error {
+	p.stopOnce.Do(func() {
+		close(p.stop)
+	})
+	<-p.done
+	return p.Flush()
+}
+
+func (p *BufferedPublisher) run(interval time.Duration) {
+	defer close(p.done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-p.stop:
+			return
+		case <-ticker.C:
+			if err := p.Flush(); err != nil {
+				Log.Error("unable to flush buffered messages: ", err)
+			}
+		}
+	}
+}
+
+// flush will publish the buffer in batches of up to maxMessages. It must be
+// called with mu held.
+func (p *BufferedPublisher) flush() error {
+	var (
+		failed   []stagedMessage
+		firstErr error
+	)
+	bp, batching := p.pub.(BatchPublisher)
+	for start := 0; start < len(p.buffer); start += p.maxMessages {
+		end := start + p.maxMessages
+		if end > len(p.buffer) {
+			end = len(p.buffer)
+		}
+		batch := p.buffer[start:end]
+		if !batching {
+			for _, msg := range batch {
+				if err := p.pub.PublishRaw(msg.key, msg.body); err != nil {
+					failed = append(failed, msg)
+					if firstErr == nil {
+						firstErr = err
+					}
+				}
+			}
+			continue
+		}
+		keys := make([]string, len(batch))
+		bodies := make([][]byte, len(batch))
+		for i, msg := range batch {
+			keys[i], bodies[i] = msg.key, msg.body
+		}
+		_, errs := bp.PublishRawBatch(keys, bodies)
+		for i, msg := range batch {
+			if i < len(errs) && errs[i] != nil {
+				failed = append(failed, msg)
+				if firstErr == nil {
+					firstErr = errs[i]
+				}
+			}
+		}
+	}
+
+	p.buffer, p.size = failed, 0
+	for _, msg := range failed {
+		p.size += len(msg.body)
+	}
+	return firstErr
+}
@@ -0,0 +1,93 @@
+package pubsub
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+)
+
+type testFailingPublisher struct {
+	fail      bool
+	published []string
+}
+
+func (p *testFailingPublisher) Publish(key string, m proto.Message) error {
+	return errors.New("not implemented")
+}
+
+func (p *testFailingPublisher) PublishRaw(key string, m []byte) error {
+	if p.fail {
+		return errors.New("unavailable")
+	}
+	p.published = append(p.published, string(m))
+	return nil
+}
+
+func TestBufferedPublisher(t *testing.T) {
+	pub := &testFailingPublisher{}
+	bp := NewBufferedPublisher(pub, BufferOptions{MaxMessages: 3, MaxBytes: 10, FlushInterval: time.Hour})
+
+	bp.PublishRaw("key", []byte("a"))
+	bp.PublishRaw("key", []byte("b"))
+	if len(pub.published) != 0 || bp.Len() != 2 {
+		t.Fatalf("expected 2 buffered messages, got %d published and %d buffered", len(pub.published), bp.Len())
+	}
+	// a full buffer should flush
+	bp.PublishRaw("key", []byte("c"))
+	if len(pub.published) != 3 || bp.Len() != 0 {
+		t.Fatalf("expected 3 published messages, got %d published and %d buffered", len(pub.published), bp.Len())
+	}
+
+	// a message that would not fit should flush what's buffered first
+	bp.PublishRaw("key", []byte("12345"))
+	bp.PublishRaw("key", []byte("678901"))
+	if len(pub.published) != 4 || bp.Len() != 1 {
+		t.Fatalf("expected 4 published and 1 buffered, got %d published and %d buffered", len(pub.published), bp.Len())
+	}
+
+	// failed messages stay buffered and memory stays bounded
+	pub.fail = true
+	if err := bp.Flush(); err == nil {
+		t.Error("expected an error flushing to a failing publisher")
+	}
+	if err := bp.PublishRaw("key", []byte("abcde")); err == nil {
+		t.Error("expected an error publishing past the max bytes")
+	}
+	if bp.Len() != 1 {
+		t.Errorf("expected 1 buffered message, got %d", bp.Len())
+	}
+
+	pub.fail = false
+	if err := bp.Close(); err != nil {
+		t.Fatal("unexpected error closing: ", err)
+	}
+	if want := []string{"a", "b", "c", "12345", "678901"}; len(pub.published) != len(want) || pub.published[4] != want[4] {
+		t.Errorf("expected %v published, got %v", want, pub.published)
+	}
+}
+
+func TestBufferedPublisherBatches(t *testing.T) {
+	pub := &testBatchPublisher{}
+	bp := NewBufferedPublisher(pub, BufferOptions{MaxMessages: 2, FlushInterval: 10 * time.Millisecond})
+
+	bp.PublishRaw("key", []byte("a"))
+	bp.PublishRaw("key", []byte("bad"))
+	bp.PublishRaw("key", []byte("c"))
+	// the interval should flush the last message
+	time.Sleep(50 * time.Millisecond)
+	if n := bp.Len(); n != 1 {
+		t.Errorf("expected the bad message to stay buffered, got %d buffered", n)
+	}
+	bp.Close()
+
+	pub.mu.Lock()
+	defer pub.mu.Unlock()
+	if len(pub.batches) < 2 || pub.batches[0] != 2 || pub.batches[1] != 2 {
+		t.Errorf("expected batches of 2, got %v", pub.batches)
+	}
+	if len(pub.published) != 2 {
+		t.Errorf("expected 2 messages published, got %v", pub.published)
+	}
+}
@@ -0,0 +1,247 @@
+package pubsub
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// DefaultCheckpointInterval is how often a checkpointed subscriber will save
+// its progress if no interval is given.
+var DefaultCheckpointInterval = 5 * time.Second
+
+// CheckpointStore persists how far each stream partition has been consumed
+// so subscribers can resume where they left off after a restart. Streams are
+// identified by a key, like '<topic>/<partition>' for Kafka.
+type CheckpointStore interface {
+	// Load will return the last saved checkpoint for the stream, and false
+	// if none has been saved.
+	Load(stream string) (int64, bool, error)
+	// Save will persist the checkpoint for the stream.
+	Save(stream string, checkpoint int64) error
+}
+
+// MemoryCheckpointStore is a CheckpointStore that keeps checkpoints in
+// memory, for tests and for subscribers that only need to resume within
+// a single process.
+type MemoryCheckpointStore struct {
+	mu          sync.Mutex
+	checkpoints map[string]int64
+}
+
+// Load will return the checkpoint for the stream.
+func (m *MemoryCheckpointStore) Load(stream string) (int64, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	cp, ok := m.checkpoints[stream]
+	return cp, ok, nil
+}
+
+// Save will set the checkpoint for the stream.
+func (m *MemoryCheckpointStore) Save(stream string, checkpoint int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.checkpoints == nil {
+		m.checkpoints = map[string]int64{}
+	}
+	m.checkpoints[stream] = checkpoint
+	return nil
+}
+
+// FileCheckpointStore is a CheckpointStore that keeps checkpoints in a JSON
+// file on local disk. Saves are written to a temporary file and renamed over
+// Path so a crash never leaves a partial file behind.
+type FileCheckpointStore struct {
+	Path string
+
+	mu sync.Mutex
+}
+
+func (f *FileCheckpointStore) read() (map[string]int64, error) {
+	checkpoints := map[string]int64{}
+	b, err := ioutil.ReadFile(f.Path)
+	if os.IsNotExist(err) {
+		return checkpoints, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return checkpoints, json.Unmarshal(b, &checkpoints)
+}
+
+// Load will read the checkpoint for the stream from the file.
+func (f *FileCheckpointStore) Load(stream string) (int64, bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	checkpoints, err := f.read()
+	if err != nil {
+		return 0, false, err
+	}
+	cp, ok := checkpoints[stream]
+	return cp, ok, nil
+}
+
+// Save will write the checkpoint for the stream to the file.
+func (f *FileCheckpointStore) Save(stream string, checkpoint int64) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	checkpoints, err := f.read()
+	if err != nil {
+		return err
+	}
+	checkpoints[stream] = checkpoint
+	b, err := json.Marshal(checkpoints)
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(f.Path), ".checkpoints")
+	if err != nil {
+		return err
+	}
+	if _, err = tmp.Write(b); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), f.Path)
+}
+
+// PostgresCheckpointStore is a CheckpointStore that keeps checkpoints in a
+// Postgres table with a text 'stream' primary key and a bigint 'checkpoint'
+// column.
+type PostgresCheckpointStore struct {
+	db    *sql.DB
+	table string
+}
+
+// NewPostgresCheckpointStore will return a PostgresCheckpointStore that uses
+// the given table.
+func NewPostgresCheckpointStore(db *sql.DB, table string) *PostgresCheckpointStore {
+	return &PostgresCheckpointStore{db: db, table: table}
+}
+
+// Load will select the checkpoint for the stream.
+func (p *PostgresCheckpointStore) Load(stream string) (int64, bool, error) {
+	var cp int64
+	err := p.db.QueryRow(fmt.Sprintf("SELECT checkpoint FROM %s WHERE stream = $1", p.table), stream).Scan(&cp)
+	if err == sql.ErrNoRows {
+		return 0, false, nil
+	}
+	return cp, err == nil, err
+}
+
+// Save will upsert the checkpoint for the stream.
+func (p *PostgresCheckpointStore) Save(stream string, checkpoint int64) error {
+	_, err := p.db.Exec(fmt.Sprintf("INSERT INTO %s (stream, checkpoint) VALUES ($1, $2) ON CONFLICT (stream) DO UPDATE SET checkpoint = EXCLUDED.checkpoint", p.table),
+		stream, checkpoint)
+	return err
+}
+
+// checkpointer tracks which offsets of a stream have been emitted and done
+// and periodically saves the highest offset below which every message is
+// done, so messages handled out of order are never skipped on a restart.
+type checkpointer struct {
+	store  CheckpointStore
+	stream string
+
+	mu      sync.Mutex
+	pending map[int64]bool
+	last    int64
+	emitted bool
+	saved   int64
+	hasSave bool
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+func newCheckpointer(store CheckpointStore, stream string, interval time.Duration) *checkpointer {
+	if interval == 0 {
+		interval = DefaultCheckpointInterval
+	}
+	c := &checkpointer{
+		store:   store,
+		stream:  stream,
+		pending: map[int64]bool{},
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	go func() {
+		defer close(c.done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-c.stop:
+				return
+			case <-ticker.C:
+				if err := c.save(); err != nil {
+					Log.Warn("unable to save checkpoint: ", err)
+				}
+			}
+		}
+	}()
+	return c
+}
+
+// track will record that the offset has been emitted.
+func (c *checkpointer) track(offset int64) {
+	c.mu.Lock()
+	c.pending[offset] = true
+	c.last = offset
+	c.emitted = true
+	c.mu.Unlock()
+}
+
+// finish will record that the offset is done.
+func (c *checkpointer) finish(offset int64) {
+	c.mu.Lock()
+	delete(c.pending, offset)
+	c.mu.Unlock()
+}
+
+// position will return the highest offset below which everything
+// emitted is done.
+func (c *checkpointer) position() (int64, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.emitted {
+		return 0, false
+	}
+	pos := c.last
+	for off := range c.pending {
+		if off-1 < pos {
+			pos = off - 1
+		}
+	}
+	return pos, true
+}
+
+// save will persist the position if it has moved since the last save.
+func (c *checkpointer) save() error {
+	pos, ok := c.position()
+	if !ok || (c.hasSave && pos <= c.saved) {
+		return nil
+	}
+	if err := c.store.Save(c.stream, pos); err != nil {
+		return err
+	}
+	c.saved, c.hasSave = pos, true
+	return nil
+}
+
+// close will stop saving periodically and save the final position.
+func (c *checkpointer) close() error {
+	close(c.stop)
+	<-c.done
+	return c.save()
+}
@@ -0,0 +1,61 @@
+package pubsub
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCheckpointer(t *testing.T) {
+	store := &MemoryCheckpointStore{}
+	cp := newCheckpointer(store, "cats/0", time.Hour)
+
+	for off := int64(10); off < 15; off++ {
+		cp.track(off)
+	}
+	// finishing out of order should only advance past contiguous offsets
+	cp.finish(10)
+	cp.finish(11)
+	cp.finish(13)
+	if err := cp.save(); err != nil {
+		t.Fatal("unexpected error: ", err)
+	}
+	if got, ok, _ := store.Load("cats/0"); !ok || got != 11 {
+		t.Errorf("expected checkpoint 11, got %d (%t)", got, ok)
+	}
+
+	cp.finish(12)
+	cp.finish(14)
+	if err := cp.close(); err != nil {
+		t.Fatal("unexpected error: ", err)
+	}
+	if got, _, _ := store.Load("cats/0"); got != 14 {
+		t.Errorf("expected checkpoint 14 after close, got %d", got)
+	}
+}
+
+func TestFileCheckpointStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "checkpoints")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	store := &FileCheckpointStore{Path: filepath.Join(dir, "checkpoints.json")}
+	if _, ok, err := store.Load("cats/0"); ok || err != nil {
+		t.Fatalf("expected no checkpoint, got %t, %v", ok, err)
+	}
+	store.Save("cats/0", 42)
+	store.Save("cats/1", 7)
+
+	// a new store for the same file should see the checkpoints
+	store = &FileCheckpointStore{Path: store.Path}
+	if got, ok, err := store.Load("cats/0"); !ok || err != nil || got != 42 {
+		t.Errorf("expected checkpoint 42, got %d, %t, %v", got, ok, err)
+	}
+	if got, _, _ := store.Load("cats/1"); got != 7 {
+		t.Errorf("expected checkpoint 7, got %d", got)
+	}
+}
@@ -0,0 +1,314 @@
+package pubsub
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	// CloudEventsSpecVersion is the version of the CloudEvents spec
+	// implemented by CloudEvent.
+	CloudEventsSpecVersion = "1.0"
+	// CloudEventsContentType is the content type of CloudEvents in
+	// the structured JSON mode.
+	CloudEventsContentType = "application/cloudevents+json"
+)
+
+// ErrAttributesUnsupported is returned when publishing a binary mode
+// CloudEvent to a Publisher that is not an AttributePublisher.
+var ErrAttributesUnsupported = errors.New("publisher does not support message attributes")
+
+type (
+	// AttributePublisher is a Publisher that can send string attributes,
+	// like Kafka headers or SNS message attributes, along with a message.
+	AttributePublisher interface {
+		Publisher
+		PublishAttributes(key string, m []byte, attrs map[string]string) error
+	}
+
+	// AttributeMessage is a SubscriberMessage that carries string attributes
+	// along with its body.
+	AttributeMessage interface {
+		SubscriberMessage
+		Attributes() map[string]string
+	}
+)
+
+// CloudEvent is an event in the CloudEvents 1.0 format, for interoperating
+// with other event producers and consumers like Knative and EventBridge.
+type CloudEvent struct {
+	// ID, Source and Type are required.
+	ID              string
+	Source          string
+	Type            string
+	SpecVersion     string
+	DataContentType string
+	DataSchema      string
+	Subject         string
+	Time            time.Time
+	// Extensions hold any extension attributes of the event.
+	Extensions map[string]string
+	// Data is the event payload, encoded as the DataContentType.
+	Data []byte
+}
+
+// Validate will return an error if any required attributes are missing.
+func (e *CloudEvent) Validate() error {
+	switch {
+	case e.ID == "":
+		return errors.New("cloudevent id is required")
+	case e.Source == "":
+		return errors.New("cloudevent source is required")
+	case e.Type == "":
+		return errors.New("cloudevent type is required")
+	case e.SpecVersion != "" && e.SpecVersion != CloudEventsSpecVersion:
+		return errors.New("unsupported cloudevent specversion: " + e.SpecVersion)
+	}
+	return nil
+}
+
+// attributes will return the context attributes of the event by their names
+// in the spec, skipping any that are empty.
+func (e *CloudEvent) attributes() map[string]string {
+	attrs := map[string]string{
+		"specversion": CloudEventsSpecVersion,
+		"id":          e.ID,
+		"source":      e.Source,
+		"type":        e.Type,
+	}
+	if e.DataContentType != "" {
+		attrs["datacontenttype"] = e.DataContentType
+	}
+	if e.DataSchema != "" {
+		attrs["dataschema"] = e.DataSchema
+	}
+	if e.Subject != "" {
+		attrs["subject"] = e.Subject
+	}
+	if !e.Time.IsZero() {
+		attrs["time"] = e.Time.UTC().Format(time.RFC3339Nano)
+	}
+	for k, v := range e.Extensions {
+		attrs[k] = v
+	}
+	return attrs
+}
+
+// setAttribute will set the named context attribute on the event.
+func (e *CloudEvent) setAttribute(name, value string) error {
+	switch name {
+	case "specversion":
+		e.SpecVersion = value
+	case "id":
+		e.ID = value
+	case "source":
+		e.Source = value
+	case "type":
+		e.Type = value
+	case "datacontenttype":
+		e.DataContentType = value
+	case "dataschema":
+		e.DataSchema = value
+	case "subject":
+		e.Subject = value
+	case "time":
+		t, err := time.Parse(time.RFC3339Nano, value)
+		if err != nil {
+			return err
+		}
+		e.Time = t
+	default:
+		if e.Extensions == nil {
+			e.Extensions = map[string]string{}
+		}
+		e.Extensions[name] = value
+	}
+	return nil
+}
+
+// MarshalJSON will encode the event in the structured JSON mode. Data with a
+// JSON content type is embedded as is and any other data is base64 encoded.
+func (e *CloudEvent) MarshalJSON() ([]byte, error) {
+	out := map[string]interface{}{}
+	for k, v := range e.attributes() {
+		out[k] = v
+	}
+	if len(e.Data) > 0 {
+		if isJSONContentType(e.DataContentType) {
+			out["data"] = json.RawMessage(e.Data)
+		} else {
+			out["data_base64"] = base64.StdEncoding.EncodeToString(e.Data)
+		}
+	}
+	return json.Marshal(out)
+}
+
+// UnmarshalJSON will decode an event in the structured JSON mode.
+func (e *CloudEvent) UnmarshalJSON(b []byte) error {
+	var in map[string]json.RawMessage
+	if err := json.Unmarshal(b, &in); err != nil {
+		return err
+	}
+	*e = CloudEvent{}
+	for k, raw := range in {
+		switch k {
+		case "data":
+			e.Data = []byte(raw)
+		case "data_base64":
+			var s string
+			if err := json.Unmarshal(raw, &s); err != nil {
+				return err
+			}
+			data, err := DecodeBase64(s, 0)
+			if err != nil {
+				return err
+			}
+			e.Data = data
+		default:
+			var s string
+			if err := json.Unmarshal(raw, &s); err != nil {
+				// keep non-string extensions as their JSON
+				s = string(raw)
+			}
+			if err := e.setAttribute(k, s); err != nil {
+				return err
+			}
+		}
+	}
+	return e.Validate()
+}
+
+func isJSONContentType(ct string) bool {
+	if ct == "" {
+		return true
+	}
+	mt, _, err := mime.ParseMediaType(ct)
+	if err != nil {
+		return false
+	}
+	return mt == "application/json" || strings.HasSuffix(mt, "+json")
+}
+
+// PublishCloudEvent will publish the event to pub. In the structured mode,
+// the event is published as JSON. In the binary mode, the event's data is
+// the message body and its attributes are sent as 'ce_' prefixed message
+// attributes, with the content type as 'content-type', which requires pub to
+// be an AttributePublisher like the KafkaPublisher or the SNSPublisher.
+func PublishCloudEvent(pub Publisher, key string, e *CloudEvent, binary bool) error {
+	if err := e.Validate(); err != nil {
+		return err
+	}
+	if !binary {
+		body, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		return pub.PublishRaw(key, body)
+	}
+	apub, ok := pub.(AttributePublisher)
+	if !ok {
+		return ErrAttributesUnsupported
+	}
+	attrs := map[string]string{}
+	for k, v := range e.attributes() {
+		if k == "datacontenttype" {
+			attrs["content-type"] = v
+			continue
+		}
+		attrs["ce_"+k] = v
+	}
+	return apub.PublishAttributes(key, e.Data, attrs)
+}
+
+// DecodeCloudEvent will read a CloudEvent from the message. Messages with a
+// 'ce_specversion' attribute are read in the binary mode and all others are
+// read in the structured mode.
+func DecodeCloudEvent(msg SubscriberMessage) (*CloudEvent, error) {
+	if amsg, ok := msg.(AttributeMessage); ok {
+		attrs := amsg.Attributes()
+		if _, binary := attrs["ce_specversion"]; binary {
+			e := &CloudEvent{Data: msg.Message()}
+			for k, v := range attrs {
+				var err error
+				switch {
+				case k == "content-type":
+					e.DataContentType = v
+				case strings.HasPrefix(k, "ce_"):
+					err = e.setAttribute(strings.TrimPrefix(k, "ce_"), v)
+				}
+				if err != nil {
+					return nil, err
+				}
+			}
+			return e, e.Validate()
+		}
+	}
+	e := &CloudEvent{}
+	return e, json.Unmarshal(msg.Message(), e)
+}
+
+// NewCloudEventRequest will return a POST request carrying the event to url.
+// In the structured mode, the event is sent as JSON. In the binary mode, the
+// event's data is the request body and its attributes are sent as 'ce-'
+// prefixed headers.
+func NewCloudEventRequest(url string, e *CloudEvent, binary bool) (*http.Request, error) {
+	if err := e.Validate(); err != nil {
+		return nil, err
+	}
+	if !binary {
+		body, err := json.Marshal(e)
+		if err != nil {
+			return nil, err
+		}
+		r, err := http.NewRequest("POST", url, bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		r.Header.Set("Content-Type", CloudEventsContentType)
+		return r, nil
+	}
+	r, err := http.NewRequest("POST", url, bytes.NewReader(e.Data))
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range e.attributes() {
+		if k == "datacontenttype" {
+			r.Header.Set("Content-Type", v)
+			continue
+		}
+		r.Header.Set("ce-"+k, v)
+	}
+	return r, nil
+}
+
+// ReadCloudEvent will read a CloudEvent from an HTTP request in either the
+// structured or binary mode. Bodies over the DefaultMaxDecodedSize are
+// rejected.
+func ReadCloudEvent(r *http.Request) (*CloudEvent, error) {
+	body, err := readLimited(nil, r.Body, DefaultMaxDecodedSize)
+	if err != nil {
+		return nil, err
+	}
+	ct := r.Header.Get("Content-Type")
+	if mt, _, _ := mime.ParseMediaType(ct); mt == CloudEventsContentType {
+		e := &CloudEvent{}
+		return e, json.Unmarshal(body, e)
+	}
+	e := &CloudEvent{Data: body, DataContentType: ct}
+	for k, vs := range r.Header {
+		k = strings.ToLower(k)
+		if !strings.HasPrefix(k, "ce-") || len(vs) == 0 {
+			continue
+		}
+		if err := e.setAttribute(strings.TrimPrefix(k, "ce-"), vs[0]); err != nil {
+			return nil, err
+		}
+	}
+	return e, e.Validate()
+}
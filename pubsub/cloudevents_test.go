@@ -0,0 +1,119 @@
+package pubsub
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+)
+
+// testAttributePublisher will record the last message published
+// along with its attributes.
+type testAttributePublisher struct {
+	body  []byte
+	attrs map[string]string
+}
+
+func (p *testAttributePublisher) Publish(key string, m proto.Message) error {
+	return nil
+}
+
+func (p *testAttributePublisher) PublishRaw(key string, m []byte) error {
+	p.body, p.attrs = m, nil
+	return nil
+}
+
+func (p *testAttributePublisher) PublishAttributes(key string, m []byte, attrs map[string]string) error {
+	p.body, p.attrs = m, attrs
+	return nil
+}
+
+type testAttributeMessage struct {
+	testConsumerMessage
+	attrs map[string]string
+}
+
+func (m *testAttributeMessage) Attributes() map[string]string {
+	return m.attrs
+}
+
+func testCloudEvent() *CloudEvent {
+	return &CloudEvent{
+		ID:              "123",
+		Source:          "/cats",
+		Type:            "com.example.cat.adopted",
+		SpecVersion:     CloudEventsSpecVersion,
+		DataContentType: "application/json",
+		Subject:         "tabby",
+		Time:            time.Date(2016, 5, 4, 3, 2, 1, 0, time.UTC),
+		Extensions:      map[string]string{"traceparent": "00-abc-def-01"},
+		Data:            []byte(`{"name":"tabby"}`),
+	}
+}
+
+func TestCloudEventsPubsub(t *testing.T) {
+	for _, binary := range []bool{false, true} {
+		pub := &testAttributePublisher{}
+		want := testCloudEvent()
+		if err := PublishCloudEvent(pub, "tabby", want, binary); err != nil {
+			t.Fatalf("unexpected error publishing with binary=%v: %s", binary, err)
+		}
+		if binary && (pub.attrs["ce_id"] != "123" || string(pub.body) != `{"name":"tabby"}`) {
+			t.Errorf("expected the data as the body and attributes as headers, got %q with %v", pub.body, pub.attrs)
+		}
+
+		msg := &testAttributeMessage{testConsumerMessage{msg: pub.body}, pub.attrs}
+		got, err := DecodeCloudEvent(msg)
+		if err != nil {
+			t.Fatalf("unexpected error decoding with binary=%v: %s", binary, err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("expected %+v, got %+v with binary=%v", want, got, binary)
+		}
+	}
+
+	if err := PublishCloudEvent(&testEnvelopePublisher{}, "tabby", testCloudEvent(), true); err != ErrAttributesUnsupported {
+		t.Errorf("expected ErrAttributesUnsupported, got %v", err)
+	}
+	if err := PublishCloudEvent(&testAttributePublisher{}, "tabby", &CloudEvent{ID: "123"}, false); err == nil {
+		t.Error("expected an invalid event to be rejected")
+	}
+}
+
+func TestCloudEventsBase64Data(t *testing.T) {
+	want := testCloudEvent()
+	want.DataContentType = "application/protobuf"
+	want.Data = []byte{0, 1, 2}
+	b, err := want.MarshalJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got CloudEvent
+	if err := got.UnmarshalJSON(b); err != nil {
+		t.Fatal("unexpected error: ", err)
+	}
+	if !reflect.DeepEqual(&got, want) {
+		t.Errorf("expected %+v, got %+v", want, got)
+	}
+}
+
+func TestCloudEventsHTTP(t *testing.T) {
+	for _, binary := range []bool{false, true} {
+		want := testCloudEvent()
+		r, err := NewCloudEventRequest("http://example.com/events", want, binary)
+		if err != nil {
+			t.Fatal("unexpected error: ", err)
+		}
+		if binary && r.Header.Get("ce-type") != want.Type {
+			t.Errorf("expected the type header, got %v", r.Header)
+		}
+		got, err := ReadCloudEvent(r)
+		if err != nil {
+			t.Fatalf("unexpected error reading with binary=%v: %s", binary, err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("expected %+v, got %+v with binary=%v", want, got, binary)
+		}
+	}
+}
@@ -0,0 +1,181 @@
+// +build !noaws
+
+package pubsub
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/cloudwatch"
+	"github.com/aws/aws-sdk-go/service/cloudwatch/cloudwatchiface"
+
+	"github.com/NYTimes/gizmo/config"
+)
+
+// DefaultCloudWatchInterval is how often a CloudWatchReporter will publish
+// metrics if no interval is configured.
+var DefaultCloudWatchInterval = time.Minute
+
+// CloudWatchReporter will publish the throughput, error rate, processing
+// latency and, optionally, lag of a Consumer as CloudWatch custom metrics
+// on an interval. Each datapoint covers the messages handled since the
+// previous one.
+type CloudWatchReporter struct {
+	// Lag is an optional func for looking up how many messages are waiting
+	// to be consumed, such as SQSSubscriber.Lag. If set, it will be
+	// published as the 'Lag' metric.
+	Lag func() (int64, error)
+
+	cw         cloudwatchiface.CloudWatchAPI
+	namespace  string
+	dimensions []*cloudwatch.Dimension
+	interval   time.Duration
+	consumer   *Consumer
+
+	mu   sync.Mutex
+	last ConsumerStats
+
+	stop     chan struct{}
+	stopOnce sync.Once
+	done     chan struct{}
+}
+
+// NewCloudWatchReporter will initiate the CloudWatch client and return a
+// reporter for the Consumer. If no credentials are passed in with the config,
+// the reporter is instantiated with the AWS_ACCESS_KEY and the AWS_SECRET_KEY
+// environment variables. Call Start to begin publishing metrics.
+func NewCloudWatchReporter(cfg *config.CloudWatch, c *Consumer) (*CloudWatchReporter, error) {
+	if cfg.Namespace == "" {
+		return nil, errors.New("cloudwatch namespace is required")
+	}
+	if cfg.Region == "" {
+		return nil, errors.New("cloudwatch region is required")
+	}
+
+	var dims []*cloudwatch.Dimension
+	for _, d := range cfg.Dimensions {
+		kv := strings.SplitN(d, ":", 2)
+		if len(kv) != 2 {
+			return nil, errors.New("invalid cloudwatch dimension, expected 'name:value': " + d)
+		}
+		dims = append(dims, &cloudwatch.Dimension{
+			Name:  aws.String(kv[0]),
+			Value: aws.String(kv[1]),
+		})
+	}
+
+	var creds *credentials.Credentials
+	if cfg.AccessKey != "" {
+		creds = credentials.NewStaticCredentials(cfg.AccessKey, cfg.SecretKey, "")
+	} else {
+		creds = credentials.NewEnvCredentials()
+	}
+
+	interval := cfg.Interval
+	if interval == 0 {
+		interval = DefaultCloudWatchInterval
+	}
+
+	return &CloudWatchReporter{
+		cw: cloudwatch.New(session.New(awsEndpoints(cfg.AWS, &aws.Config{
+			Credentials: creds,
+			Region:      &cfg.Region,
+		}))),
+		namespace:  cfg.Namespace,
+		dimensions: dims,
+		interval:   interval,
+		consumer:   c,
+		stop:       make(chan struct{}),
+		done:       make(chan struct{}),
+	}, nil
+}
+
+// Start will begin publishing metrics on the interval
+// until Stop is called.
+func (r *CloudWatchReporter) Start() {
+	go func() {
+		defer close(r.done)
+		ticker := time.NewTicker(r.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-r.stop:
+				return
+			case <-ticker.C:
+				if err := r.Report(); err != nil {
+					Log.Warn("unable to publish consumer metrics: ", err)
+				}
+			}
+		}
+	}()
+}
+
+// Stop will stop publishing on the interval and publish one final set
+// of metrics for any messages handled since the last report. Start must
+// have been called before calling Stop.
+func (r *CloudWatchReporter) Stop() error {
+	r.stopOnce.Do(func() {
+		close(r.stop)
+	})
+	<-r.done
+	return r.Report()
+}
+
+// Report will publish metrics for the messages
+// handled since the last report.
+func (r *CloudWatchReporter) Report() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	stats := r.consumer.Stats()
+	handled := stats.Handled - r.last.Handled
+	failed := stats.Failed - r.last.Failed
+	latency := stats.Latency - r.last.Latency
+
+	data := []*cloudwatch.MetricDatum{
+		r.datum("MessagesHandled", float64(handled), cloudwatch.StandardUnitCount, now),
+		r.datum("MessagesFailed", float64(failed), cloudwatch.StandardUnitCount, now),
+	}
+	// rates and averages are meaningless without any messages
+	if handled > 0 {
+		data = append(data,
+			r.datum("ErrorRate", 100*float64(failed)/float64(handled), cloudwatch.StandardUnitPercent, now),
+			r.datum("ProcessingLatency", float64(latency/time.Duration(handled))/float64(time.Millisecond),
+				cloudwatch.StandardUnitMilliseconds, now),
+		)
+	}
+	if r.Lag != nil {
+		lag, err := r.Lag()
+		if err != nil {
+			Log.Warn("unable to look up consumer lag: ", err)
+		} else {
+			data = append(data, r.datum("Lag", float64(lag), cloudwatch.StandardUnitCount, now))
+		}
+	}
+
+	_, err := r.cw.PutMetricData(&cloudwatch.PutMetricDataInput{
+		Namespace:  &r.namespace,
+		MetricData: data,
+	})
+	if err != nil {
+		return err
+	}
+	r.last = stats
+	return nil
+}
+
+func (r *CloudWatchReporter) datum(name string, value float64, unit string, ts time.Time) *cloudwatch.MetricDatum {
+	return &cloudwatch.MetricDatum{
+		MetricName: aws.String(name),
+		Dimensions: r.dimensions,
+		Value:      aws.Float64(value),
+		Unit:       aws.String(unit),
+		Timestamp:  aws.Time(ts),
+	}
+}
@@ -0,0 +1,195 @@
+// +build !noaws
+
+package pubsub
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudwatch"
+	"github.com/aws/aws-sdk-go/service/cloudwatch/cloudwatchiface"
+	"github.com/aws/aws-sdk-go/service/sqs"
+
+	"github.com/NYTimes/gizmo/config"
+)
+
+// testCloudWatchAPI will record any published metrics. All other
+// CloudWatchAPI methods will panic.
+type testCloudWatchAPI struct {
+	cloudwatchiface.CloudWatchAPI
+
+	inputs []*cloudwatch.PutMetricDataInput
+	err    error
+}
+
+func (c *testCloudWatchAPI) PutMetricData(i *cloudwatch.PutMetricDataInput) (*cloudwatch.PutMetricDataOutput, error) {
+	c.inputs = append(c.inputs, i)
+	return &cloudwatch.PutMetricDataOutput{}, c.err
+}
+
+func metricValues(i *cloudwatch.PutMetricDataInput) map[string]float64 {
+	values := map[string]float64{}
+	for _, d := range i.MetricData {
+		values[*d.MetricName] = *d.Value
+	}
+	return values
+}
+
+func TestCloudWatchReporter(t *testing.T) {
+	cw := &testCloudWatchAPI{}
+	c := NewConsumer(newTestChanSubscriber(), nil)
+	r := &CloudWatchReporter{
+		cw:         cw,
+		namespace:  "Cats",
+		dimensions: []*cloudwatch.Dimension{{Name: aws.String("Service"), Value: aws.String("cats")}},
+		consumer:   c,
+	}
+	lag := int64(12)
+	r.Lag = func() (int64, error) { return lag, nil }
+
+	tests := []struct {
+		handled, failed int64
+		latency         time.Duration
+		err             error
+
+		want map[string]float64
+	}{
+		{
+			4, 1, 40 * time.Millisecond, nil,
+			map[string]float64{
+				"MessagesHandled":   4,
+				"MessagesFailed":    1,
+				"ErrorRate":         25,
+				"ProcessingLatency": 10,
+				"Lag":               12,
+			},
+		},
+		// a failed publish should be retried with the next report
+		{
+			5, 1, 50 * time.Millisecond, errors.New("throttled"),
+			map[string]float64{
+				"MessagesHandled":   1,
+				"MessagesFailed":    0,
+				"ErrorRate":         0,
+				"ProcessingLatency": 10,
+				"Lag":               12,
+			},
+		},
+		{
+			6, 2, 70 * time.Millisecond, nil,
+			map[string]float64{
+				"MessagesHandled":   2,
+				"MessagesFailed":    1,
+				"ErrorRate":         50,
+				"ProcessingLatency": 15,
+				"Lag":               12,
+			},
+		},
+		// no messages means no rate or latency
+		{
+			6, 2, 70 * time.Millisecond, nil,
+			map[string]float64{
+				"MessagesHandled": 0,
+				"MessagesFailed":  0,
+				"Lag":             12,
+			},
+		},
+	}
+
+	for i, test := range tests {
+		c.handled, c.failed, c.latency = test.handled, test.failed, int64(test.latency)
+		cw.err = test.err
+		err := r.Report()
+		if err != test.err {
+			t.Errorf("test %d: expected error %v, got %v", i, test.err, err)
+		}
+		input := cw.inputs[len(cw.inputs)-1]
+		if *input.Namespace != "Cats" {
+			t.Errorf("test %d: expected namespace 'Cats', got '%s'", i, *input.Namespace)
+		}
+		if !reflect.DeepEqual(input.MetricData[0].Dimensions, r.dimensions) {
+			t.Errorf("test %d: expected dimensions %v, got %v", i, r.dimensions, input.MetricData[0].Dimensions)
+		}
+		if got := metricValues(input); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("test %d: expected metrics %v, got %v", i, test.want, got)
+		}
+	}
+}
+
+func TestCloudWatchReporterStop(t *testing.T) {
+	cw := &testCloudWatchAPI{}
+	c := NewConsumer(newTestChanSubscriber(), nil)
+	r := &CloudWatchReporter{
+		cw:        cw,
+		namespace: "Cats",
+		interval:  time.Hour,
+		consumer:  c,
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+	r.Start()
+	c.handled = 3
+	if err := r.Stop(); err != nil {
+		t.Fatal("unexpected error stopping reporter: ", err)
+	}
+	if len(cw.inputs) != 1 {
+		t.Fatalf("expected 1 final report, got %d", len(cw.inputs))
+	}
+	if got := metricValues(cw.inputs[0])["MessagesHandled"]; got != 3 {
+		t.Errorf("expected 3 messages handled, got %v", got)
+	}
+}
+
+func TestNewCloudWatchReporter(t *testing.T) {
+	tests := []struct {
+		cfg *config.CloudWatch
+
+		wantErr bool
+	}{
+		{&config.CloudWatch{AWS: config.AWS{Region: "us-east-1"}, Namespace: "Cats", Dimensions: []string{"Service:cats"}}, false},
+		{&config.CloudWatch{AWS: config.AWS{Region: "us-east-1"}}, true},
+		{&config.CloudWatch{Namespace: "Cats"}, true},
+		{&config.CloudWatch{AWS: config.AWS{Region: "us-east-1"}, Namespace: "Cats", Dimensions: []string{"cats"}}, true},
+	}
+
+	for i, test := range tests {
+		r, err := NewCloudWatchReporter(test.cfg, NewConsumer(newTestChanSubscriber(), nil))
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("test %d: expected an error, got none", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("test %d: unexpected error: %s", i, err)
+			continue
+		}
+		if r.interval != DefaultCloudWatchInterval {
+			t.Errorf("test %d: expected default interval, got %s", i, r.interval)
+		}
+	}
+}
+
+func TestSQSSubscriberLag(t *testing.T) {
+	count := "42"
+	sub := &SQSSubscriber{
+		sqs: &TestSQSAPI{Attributes: map[string]*string{
+			sqs.QueueAttributeNameApproximateNumberOfMessages: &count,
+		}},
+	}
+	lag, err := sub.Lag()
+	if err != nil {
+		t.Fatal("unexpected error: ", err)
+	}
+	if lag != 42 {
+		t.Errorf("expected lag of 42, got %d", lag)
+	}
+
+	sub.sqs = &TestSQSAPI{}
+	if _, err := sub.Lag(); err == nil {
+		t.Error("expected an error when the attributes can't be fetched")
+	}
+}
@@ -0,0 +1,19 @@
+package pubsub
+
+import "github.com/NYTimes/gizmo/codec"
+
+// PublishEncoded will encode the value with the given codec and publish it
+// as a raw message. Since messages carry no content type, subscribers must
+// decode them with the same codec, like with DecodeMessage.
+func PublishEncoded(p Publisher, c codec.Codec, key string, v interface{}) error {
+	b, err := c.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return p.PublishRaw(key, b)
+}
+
+// DecodeMessage will decode the message's body into v with the given codec.
+func DecodeMessage(c codec.Codec, msg SubscriberMessage, v interface{}) error {
+	return c.Unmarshal(msg.Message(), v)
+}
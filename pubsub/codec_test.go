@@ -0,0 +1,36 @@
+package pubsub_test
+
+import (
+	"testing"
+
+	"github.com/NYTimes/gizmo/codec"
+	"github.com/NYTimes/gizmo/pubsub"
+	"github.com/NYTimes/gizmo/pubsub/pubsubtest"
+)
+
+type testReading struct {
+	Sensor string
+	Temp   float64
+}
+
+func TestPublishEncoded(t *testing.T) {
+	for _, c := range []codec.Codec{codec.JSON, codec.MsgPack, codec.CBOR} {
+		pub := &pubsubtest.TestPublisher{}
+		want := testReading{"porch", 21.5}
+		if err := pubsub.PublishEncoded(pub, c, "reading", want); err != nil {
+			t.Fatalf("%s: unexpected error publishing: %s", c.ContentType(), err)
+		}
+		if len(pub.Published) != 1 || pub.Published[0].Key != "reading" {
+			t.Fatalf("%s: expected a single published reading, got %+v", c.ContentType(), pub.Published)
+		}
+
+		var got testReading
+		msg := &pubsubtest.TestSubsMessage{Msg: pub.Published[0].Body}
+		if err := pubsub.DecodeMessage(c, msg, &got); err != nil {
+			t.Fatalf("%s: unexpected error decoding: %s", c.ContentType(), err)
+		}
+		if got != want {
+			t.Errorf("%s: expected %+v, got %+v", c.ContentType(), want, got)
+		}
+	}
+}
@@ -0,0 +1,588 @@
+package pubsub
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+	"golang.org/x/net/context"
+
+	"github.com/NYTimes/gizmo/notify"
+	"github.com/NYTimes/gizmo/reporting"
+)
+
+// DefaultConsumerPauseInterval is how often a paused Consumer will check
+// if it has been enabled again if no PauseInterval is given.
+var DefaultConsumerPauseInterval = time.Second
+
+// DefaultConsumerMirrorBuffer is how many sampled messages can be waiting to
+// be published to a Consumer's Mirror before more are dropped.
+var DefaultConsumerMirrorBuffer = 100
+
+// ErrHandlerTimeout is logged when a MessageHandler does not return within
+// its Consumer's Timeout.
+var ErrHandlerTimeout = errors.New("message handler timed out")
+
+// MessageHandler is a func for processing a single message from a Subscriber.
+// If the handler returns nil, the message will be marked as Done. Otherwise,
+// the message will be left to be redelivered by the Subscriber.
+type MessageHandler func(context.Context, SubscriberMessage) error
+
+// NackMessage is a SubscriberMessage that can be handed back to its
+// Subscriber for redelivery right away, rather than once it times out.
+type NackMessage interface {
+	SubscriberMessage
+	Nack() error
+}
+
+// ReleasableMessage is a SubscriberMessage that counts against its
+// Subscriber's in-flight messages until it is released. Release gives up
+// that slot without acknowledging the message and must be safe to call after
+// Done, Nack or another Release.
+type ReleasableMessage interface {
+	SubscriberMessage
+	Release()
+}
+
+// release will give up the message's in-flight slot, if it holds one.
+func release(msg SubscriberMessage) {
+	if rmsg, ok := msg.(ReleasableMessage); ok {
+		rmsg.Release()
+	}
+}
+
+// handBack will return a message that will not be handled to its Subscriber,
+// nacking it for redelivery right away if it is a NackMessage and otherwise
+// giving up its in-flight slot.
+func handBack(msg SubscriberMessage) {
+	if nmsg, ok := msg.(NackMessage); ok {
+		if err := nmsg.Nack(); err != nil {
+			Log.Warn("unable to nack unhandled message: ", err)
+		}
+	}
+	release(msg)
+}
+
+// RestartableSubscriber is a Subscriber that can be started again once it
+// has been stopped. A paused Consumer will stop a RestartableSubscriber, so
+// it does not hold any messages back from the queue, and restart it once the
+// Consumer is enabled again.
+type RestartableSubscriber interface {
+	Subscriber
+	// Restart will begin receiving again after Stop and return a new
+	// channel of raw messages.
+	Restart() <-chan SubscriberMessage
+}
+
+// Consumer will run a MessageHandler over every message from a Subscriber
+// until it is stopped or the Subscriber closes its channel.
+type Consumer struct {
+	// totals for Stats, accessed atomically and kept
+	// first for 64-bit alignment
+	handled  int64
+	failed   int64
+	latency  int64
+	filtered int64
+	inFlight int64
+	// set while Run is handling messages, accessed atomically
+	running int32
+
+	// Concurrency is the max number of messages that will be
+	// handled at once. Defaults to 1.
+	Concurrency int
+	// Timeout is an optional limit on how long the handler may take with
+	// each message. Once it passes, the handler's context is canceled, the
+	// 'pubsub.consumer.TIMEOUT' metric is incremented and the message is
+	// failed, and nacked if it is a NackMessage. The Consumer moves on to
+	// other messages without waiting for the handler to return, so handlers
+	// should watch their context to avoid leaking goroutines.
+	Timeout time.Duration
+	// Filter is an optional MessageFilter for consumers that only care
+	// about a subset of a shared queue. Messages it does not match are
+	// marked as done without reaching the handler.
+	Filter MessageFilter
+	// Enabled is an optional hook that will be checked before receiving each
+	// message. While it returns false, the Consumer will stop receiving
+	// messages until it is enabled again. A RestartableSubscriber is stopped
+	// while paused, so its messages stay on the queue, while any other
+	// Subscriber is left running and may hold a message until the Consumer
+	// resumes.
+	Enabled func() bool
+	// PauseInterval is how often Enabled will be checked while the Consumer
+	// is paused. Defaults to DefaultConsumerPauseInterval.
+	PauseInterval time.Duration
+	// DrainIdle will run the Consumer in drain mode, where it stops and
+	// returns cleanly once no message has been received for this long, for
+	// batch jobs and for emptying queues during migrations. Time spent
+	// paused does not count. Subscribers that long poll, like SQS, should
+	// have a DrainIdle longer than their wait time.
+	DrainIdle time.Duration
+	// Reporter is an optional hook that will be sent a report of any
+	// handler panic along with the message being handled.
+	Reporter reporting.Reporter
+	// Notifier is an optional hook that will be sent an event when the
+	// Consumer is paused or resumed and when its Subscriber fails.
+	Notifier notify.Notifier
+	// Mirror is an optional Publisher that a sample of messages will be
+	// copied to, such as a queue read by a new version of a consumer for
+	// shadow-testing against live traffic. Messages are mirrored in the
+	// background and dropped if Mirror falls behind, so mirroring never
+	// affects how messages are handled.
+	Mirror Publisher
+	// MirrorRate is the fraction of messages, like 0.05 for 5%, that will be
+	// copied to the Mirror. If 0, every message is mirrored.
+	MirrorRate float64
+	// Canary will run the Consumer in canary mode, where messages are handled
+	// fully but never marked as done, leaving them to be redelivered to and
+	// acknowledged by the stable fleet. The outcome of each message is
+	// recorded in the 'pubsub.consumer.canary' metrics, while a stable
+	// Consumer records the same metrics under 'pubsub.consumer.stable', so
+	// the two can be compared before new consumer code is rolled out. As the
+	// fleets differ in size, compare their ERROR_RATE and DURATION rather
+	// than their SUCCESS and ERROR counts.
+	Canary bool
+	// MetricsRegistry will override the default metrics registry for the
+	// outcome and timeout metrics if set.
+	MetricsRegistry metrics.Registry
+	// Tracer is an optional MessageTracer that will keep the ID, subject,
+	// size, latency and outcome of the last few messages received, such as
+	// the DefaultMessageTracer served by the server/pubsubhttp package.
+	Tracer *MessageTracer
+	// Name identifies the Consumer's messages in the Tracer and the
+	// handler's MessageMetadata. Defaults to 'default'.
+	Name string
+	// Service is an optional description of the service running the
+	// Consumer. It is added to each handler's context, along with the
+	// MessageMetadata of the message being handled, so handlers can log
+	// and record metrics with the same dimensions with MessageFields,
+	// ServiceFromContext and MessageMetadataFromContext.
+	Service StandardAttributes
+
+	sub     Subscriber
+	handler MessageHandler
+
+	mirrored chan SubscriberMessage
+
+	stop     chan struct{}
+	stopOnce sync.Once
+	done     chan error
+}
+
+// ConsumerStats holds the running totals of a Consumer.
+type ConsumerStats struct {
+	// Handled is the number of messages the handler has finished with.
+	Handled int64
+	// Failed is the number of handled messages that returned an error,
+	// panicked or could not be marked as done.
+	Failed int64
+	// Latency is the total time spent handling messages.
+	Latency time.Duration
+	// Filtered is the number of messages discarded by the Filter.
+	Filtered int64
+	// InFlight is the number of handler calls currently running, including
+	// any that were abandoned after the Timeout but have not returned.
+	InFlight int64
+}
+
+// NewConsumer will return a Consumer that passes all messages from
+// the Subscriber to the given handler.
+func NewConsumer(sub Subscriber, handler MessageHandler) *Consumer {
+	return &Consumer{
+		sub:     sub,
+		handler: handler,
+		stop:    make(chan struct{}),
+		done:    make(chan error, 1),
+	}
+}
+
+// Consume will return a function that runs a new Consumer of sub with
+// handler until its context is done. Its signature matches what errgroup
+// and the lifecycle package expect of a long-running component.
+func Consume(sub Subscriber, handler MessageHandler) func(context.Context) error {
+	return NewConsumer(sub, handler).RunContext
+}
+
+// Run will start the Subscriber and handle its messages until Stop is
+// called or the Subscriber's channel is closed. It will block until all
+// in-flight messages have been handled and return the Subscriber's error,
+// if any.
+func (c *Consumer) Run() error {
+	return c.RunContext(context.Background())
+}
+
+// RunContext will run the Consumer like Run until Stop is called, the
+// Subscriber's channel is closed or ctx is done, so it may be run in an
+// errgroup alongside a service's other goroutines. Once ctx is done, the
+// Subscriber is stopped as with Stop.
+func (c *Consumer) RunContext(ctx context.Context) error {
+	atomic.StoreInt32(&c.running, 1)
+	err := c.run(ctx)
+	atomic.StoreInt32(&c.running, 0)
+	if err != nil {
+		c.notify(&notify.Event{
+			Title: "consumer stopped with an error",
+			Text:  err.Error(),
+			Level: notify.Critical,
+		})
+	}
+	c.done <- err
+	return err
+}
+
+func (c *Consumer) run(ctx context.Context) error {
+	concurrency := c.Concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	interval := c.PauseInterval
+	if interval == 0 {
+		interval = DefaultConsumerPauseInterval
+	}
+
+	if c.Mirror != nil {
+		c.mirrored = make(chan SubscriberMessage, DefaultConsumerMirrorBuffer)
+		mirrored := make(chan struct{})
+		go func() {
+			c.mirror(c.mirrored)
+			close(mirrored)
+		}()
+		// let any sampled messages be published before returning
+		defer func() {
+			close(c.mirrored)
+			<-mirrored
+		}()
+	}
+
+	rsub, restartable := c.sub.(RestartableSubscriber)
+	var (
+		wg     sync.WaitGroup
+		sem    = make(chan struct{}, concurrency)
+		msgs   = c.sub.Start()
+		paused bool
+		// set while a RestartableSubscriber is stopped for a pause
+		stopped bool
+		// idle fires once no message has arrived for DrainIdle
+		idle  *time.Timer
+		idleC <-chan time.Time
+	)
+	if c.DrainIdle > 0 {
+		idle = time.NewTimer(c.DrainIdle)
+		defer idle.Stop()
+		idleC = idle.C
+	}
+	for {
+		if c.Enabled != nil && !c.Enabled() {
+			if !paused {
+				Log.Info("consumer has been disabled, pausing")
+				c.notify(&notify.Event{Title: "consumer paused", Level: notify.Warning})
+				paused = true
+				if restartable {
+					if err := c.stopSubscriber(msgs); err != nil {
+						wg.Wait()
+						return err
+					}
+					stopped = true
+				}
+			}
+			select {
+			case <-c.stop:
+			case <-ctx.Done():
+			case <-time.After(interval):
+				continue
+			}
+			if stopped {
+				wg.Wait()
+				return nil
+			}
+			return c.shutdown(msgs, &wg)
+		}
+		if paused {
+			Log.Info("consumer has been enabled, resuming")
+			c.notify(&notify.Event{Title: "consumer resumed", Level: notify.Info})
+			paused = false
+			if stopped {
+				msgs = rsub.Restart()
+				stopped = false
+			}
+			// time spent paused does not count towards draining
+			resetTimer(idle, c.DrainIdle)
+		}
+
+		select {
+		case <-c.stop:
+			return c.shutdown(msgs, &wg)
+		case <-ctx.Done():
+			return c.shutdown(msgs, &wg)
+		case <-idleC:
+			Log.Infof("no messages received for %s, consumer has drained", c.DrainIdle)
+			return c.shutdown(msgs, &wg)
+		case msg, ok := <-msgs:
+			if !ok {
+				wg.Wait()
+				return c.sub.Err()
+			}
+			sem <- struct{}{}
+			wg.Add(1)
+			go func(msg SubscriberMessage) {
+				defer func() {
+					<-sem
+					wg.Done()
+				}()
+				c.handle(msg)
+			}(msg)
+			// waiting on a free handler does not count towards draining
+			resetTimer(idle, c.DrainIdle)
+		}
+	}
+}
+
+// resetTimer will restart t, if any, to fire after d, dropping any
+// pending fire that was not received.
+func resetTimer(t *time.Timer, d time.Duration) {
+	if t == nil {
+		return
+	}
+	if !t.Stop() {
+		select {
+		case <-t.C:
+		default:
+		}
+	}
+	t.Reset(d)
+}
+
+// shutdown will stop the Subscriber and wait for any in-flight messages
+// to complete.
+func (c *Consumer) shutdown(msgs <-chan SubscriberMessage, wg *sync.WaitGroup) error {
+	err := c.stopSubscriber(msgs)
+	wg.Wait()
+	return err
+}
+
+// stopSubscriber will stop the Subscriber, handing back any messages
+// received while stopping to be redelivered.
+func (c *Consumer) stopSubscriber(msgs <-chan SubscriberMessage) error {
+	stopped := make(chan error, 1)
+	go func() {
+		stopped <- c.sub.Stop()
+	}()
+	for {
+		select {
+		case msg, ok := <-msgs:
+			if !ok {
+				// block on the stop result from here on
+				msgs = nil
+				continue
+			}
+			handBack(msg)
+		case err := <-stopped:
+			return err
+		}
+	}
+}
+
+func (c *Consumer) notify(e *notify.Event) {
+	if c.Notifier != nil {
+		c.Notifier.Notify(e)
+	}
+}
+
+// mirror will publish the sampled messages to the Mirror until
+// the channel is closed.
+func (c *Consumer) mirror(msgs <-chan SubscriberMessage) {
+	for msg := range msgs {
+		key, body, _ := passthrough(msg)
+		if err := c.Mirror.PublishRaw(key, body); err != nil {
+			Log.Warn("unable to mirror message: ", err)
+		}
+	}
+}
+
+// sample will queue the message to be mirrored if it is picked by
+// the MirrorRate.
+func (c *Consumer) sample(msg SubscriberMessage) {
+	if c.mirrored == nil || (c.MirrorRate > 0 && rand.Float64() >= c.MirrorRate) {
+		return
+	}
+	select {
+	case c.mirrored <- msg:
+	default:
+		Log.Debug("mirror is behind, dropping sampled message")
+	}
+}
+
+func (c *Consumer) handle(msg SubscriberMessage) {
+	if c.Filter != nil && !c.Filter(msg) {
+		atomic.AddInt64(&c.filtered, 1)
+		c.trace(msg, time.Now(), TraceFiltered, nil)
+		if c.Canary {
+			release(msg)
+			return
+		}
+		if err := msg.Done(); err != nil {
+			Log.Error("unable to mark filtered message as done: ", err)
+		}
+		return
+	}
+	c.sample(msg)
+	start := time.Now()
+	var err error
+	defer func() {
+		atomic.AddInt64(&c.handled, 1)
+		atomic.AddInt64(&c.latency, int64(time.Since(start)))
+		if err != nil {
+			atomic.AddInt64(&c.failed, 1)
+		}
+		c.recordOutcome(start, err != nil)
+		// a message that was not done must still give up its
+		// in-flight slot while it waits to be redelivered
+		release(msg)
+		outcome := TraceHandled
+		switch {
+		case err == ErrHandlerTimeout:
+			outcome = TraceTimeout
+		case err != nil:
+			outcome = TraceFailed
+		}
+		c.trace(msg, start, outcome, err)
+	}()
+	if err = c.call(msg); err != nil {
+		Log.Warn("unable to handle message: ", err)
+		return
+	}
+	if c.Canary {
+		return
+	}
+	if err = msg.Done(); err != nil {
+		Log.Error("unable to mark message as done: ", err)
+	}
+}
+
+// trace will record the message and its outcome in the Tracer, if any.
+func (c *Consumer) trace(msg SubscriberMessage, start time.Time, outcome string, err error) {
+	if c.Tracer == nil {
+		return
+	}
+	t := newMessageTrace(msg, start)
+	t.Latency = time.Since(start)
+	t.Outcome = outcome
+	if err != nil {
+		t.Error = err.Error()
+	}
+	c.Tracer.Record(c.name(), t)
+}
+
+func (c *Consumer) name() string {
+	if c.Name == "" {
+		return "default"
+	}
+	return c.Name
+}
+
+// handlerContext will return the handler's base context, carrying the
+// Consumer's Service, if set, and the message's metadata.
+func (c *Consumer) handlerContext(msg SubscriberMessage) context.Context {
+	ctx := context.Background()
+	if c.Service != (StandardAttributes{}) {
+		ctx = WithService(ctx, c.Service)
+	}
+	return WithMessageMetadata(ctx, newMessageMetadata(c.name(), msg))
+}
+
+// call will run the handler over the message, giving up on it if the
+// Consumer's Timeout passes first.
+func (c *Consumer) call(msg SubscriberMessage) error {
+	if c.Timeout <= 0 {
+		return c.safeHandle(c.handlerContext(msg), msg)
+	}
+	ctx, cancel := context.WithTimeout(c.handlerContext(msg), c.Timeout)
+	defer cancel()
+	result := make(chan error, 1)
+	go func() {
+		result <- c.safeHandle(ctx, msg)
+	}()
+	select {
+	case err := <-result:
+		return err
+	case <-ctx.Done():
+		metrics.GetOrRegisterCounter("pubsub.consumer.TIMEOUT", c.MetricsRegistry).Inc(1)
+		if nmsg, ok := msg.(NackMessage); ok && !c.Canary {
+			if err := nmsg.Nack(); err != nil {
+				Log.Error("unable to nack timed out message: ", err)
+			}
+		}
+		return ErrHandlerTimeout
+	}
+}
+
+// safeHandle will run the handler over the message, reporting and returning
+// any panic as an error.
+func (c *Consumer) safeHandle(ctx context.Context, msg SubscriberMessage) (err error) {
+	atomic.AddInt64(&c.inFlight, 1)
+	defer func() {
+		atomic.AddInt64(&c.inFlight, -1)
+		if x := recover(); x != nil {
+			stack := debug.Stack()
+			Log.Errorf("consumer handler panic: %v\n%s", x, stack)
+			if c.Reporter != nil {
+				report := reporting.PanicReport(x, stack)
+				report.Message = msg.Message()
+				c.Reporter.Report(report)
+			}
+			err = fmt.Errorf("handler panic: %v", x)
+		}
+	}()
+	return c.handler(ctx, msg)
+}
+
+// recordOutcome will update the canary or stable metrics with the outcome of
+// a message. ERROR_RATE is the fraction of all messages handled so far that
+// failed, so canaries can be compared with a larger stable fleet.
+func (c *Consumer) recordOutcome(start time.Time, failed bool) {
+	prefix := "pubsub.consumer.stable."
+	if c.Canary {
+		prefix = "pubsub.consumer.canary."
+	}
+	metrics.GetOrRegisterTimer(prefix+"DURATION", c.MetricsRegistry).UpdateSince(start)
+	metrics.GetOrRegisterGaugeFloat64(prefix+"ERROR_RATE", c.MetricsRegistry).Update(
+		float64(atomic.LoadInt64(&c.failed)) / float64(atomic.LoadInt64(&c.handled)))
+	if failed {
+		metrics.GetOrRegisterCounter(prefix+"ERROR", c.MetricsRegistry).Inc(1)
+		return
+	}
+	metrics.GetOrRegisterCounter(prefix+"SUCCESS", c.MetricsRegistry).Inc(1)
+}
+
+// Stats will return the totals for all messages handled so far.
+func (c *Consumer) Stats() ConsumerStats {
+	return ConsumerStats{
+		Handled:  atomic.LoadInt64(&c.handled),
+		Failed:   atomic.LoadInt64(&c.failed),
+		Latency:  time.Duration(atomic.LoadInt64(&c.latency)),
+		Filtered: atomic.LoadInt64(&c.filtered),
+		InFlight: atomic.LoadInt64(&c.inFlight),
+	}
+}
+
+// Running will return true while Run is receiving and handling messages.
+func (c *Consumer) Running() bool {
+	return atomic.LoadInt32(&c.running) == 1
+}
+
+// Stop will stop the Subscriber and block until the Consumer has
+// finished handling any in-flight messages. Run must have been called
+// before calling Stop.
+func (c *Consumer) Stop() error {
+	c.stopOnce.Do(func() {
+		close(c.stop)
+	})
+	err := <-c.done
+	// let any other callers see the result
+	c.done <- err
+	return err
+}
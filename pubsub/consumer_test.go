@@ -0,0 +1,528 @@
+package pubsub
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/rcrowley/go-metrics"
+	"golang.org/x/net/context"
+
+	"github.com/NYTimes/gizmo/reporting"
+)
+
+type testConsumerMessage struct {
+	msg   []byte
+	doned int32
+}
+
+func (m *testConsumerMessage) Message() []byte {
+	return m.msg
+}
+
+func (m *testConsumerMessage) Done() error {
+	atomic.StoreInt32(&m.doned, 1)
+	return nil
+}
+
+// testChanSubscriber will emit any messages sent on msgs
+// until it is stopped.
+type testChanSubscriber struct {
+	msgs chan SubscriberMessage
+	err  error
+}
+
+func newTestChanSubscriber() *testChanSubscriber {
+	return &testChanSubscriber{msgs: make(chan SubscriberMessage)}
+}
+
+func (s *testChanSubscriber) Start() <-chan SubscriberMessage {
+	return s.msgs
+}
+
+func (s *testChanSubscriber) Err() error {
+	return s.err
+}
+
+func (s *testChanSubscriber) Stop() error {
+	close(s.msgs)
+	return nil
+}
+
+func TestConsumer(t *testing.T) {
+	sub := newTestChanSubscriber()
+	var handled int32
+	c := NewConsumer(sub, func(ctx context.Context, msg SubscriberMessage) error {
+		atomic.AddInt32(&handled, 1)
+		if string(msg.Message()) == "bad" {
+			return errors.New("bad message")
+		}
+		return nil
+	})
+	c.Concurrency = 2
+
+	go c.Run()
+
+	good := &testConsumerMessage{msg: []byte("good")}
+	bad := &testConsumerMessage{msg: []byte("bad")}
+	sub.msgs <- good
+	sub.msgs <- bad
+
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) && atomic.LoadInt32(&handled) < 2 {
+		time.Sleep(time.Millisecond)
+	}
+	if !c.Running() {
+		t.Error("expected consumer to be running")
+	}
+	if err := c.Stop(); err != nil {
+		t.Error("unexpected error stopping consumer: ", err)
+	}
+	if c.Running() {
+		t.Error("expected consumer to not be running after Stop")
+	}
+
+	if got := atomic.LoadInt32(&handled); got != 2 {
+		t.Errorf("expected 2 messages handled, got %d", got)
+	}
+	if atomic.LoadInt32(&good.doned) != 1 {
+		t.Error("expected successful message to be marked done")
+	}
+	if atomic.LoadInt32(&bad.doned) != 0 {
+		t.Error("expected failed message to not be marked done")
+	}
+	if stats := c.Stats(); stats.Handled != 2 || stats.Failed != 1 {
+		t.Errorf("expected stats with 2 handled and 1 failed, got %+v", stats)
+	}
+}
+
+func TestConsumerEnabled(t *testing.T) {
+	sub := newTestChanSubscriber()
+	var (
+		mu      sync.Mutex
+		enabled bool
+		handled int32
+	)
+	c := NewConsumer(sub, func(ctx context.Context, msg SubscriberMessage) error {
+		atomic.AddInt32(&handled, 1)
+		return nil
+	})
+	c.PauseInterval = time.Millisecond
+	c.Enabled = func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return enabled
+	}
+
+	go c.Run()
+
+	// the consumer should not receive while disabled
+	select {
+	case sub.msgs <- &testConsumerMessage{}:
+		t.Error("expected a disabled consumer to not receive messages")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	mu.Lock()
+	enabled = true
+	mu.Unlock()
+
+	select {
+	case sub.msgs <- &testConsumerMessage{}:
+	case <-time.After(time.Second):
+		t.Error("expected an enabled consumer to receive messages")
+	}
+
+	if err := c.Stop(); err != nil {
+		t.Error("unexpected error stopping consumer: ", err)
+	}
+	if got := atomic.LoadInt32(&handled); got != 1 {
+		t.Errorf("expected 1 message handled, got %d", got)
+	}
+}
+
+func TestConsumerReportsPanics(t *testing.T) {
+	sub := newTestChanSubscriber()
+	c := NewConsumer(sub, func(ctx context.Context, msg SubscriberMessage) error {
+		panic("oh no")
+	})
+	reports := make(chan *reporting.Report, 1)
+	c.Reporter = reporting.ReporterFunc(func(r *reporting.Report) {
+		reports <- r
+	})
+
+	go c.Run()
+	sub.msgs <- &testConsumerMessage{msg: []byte("boom")}
+
+	select {
+	case r := <-reports:
+		if !r.Panic || r.Err.Error() != "oh no" {
+			t.Errorf("expected a report of the panic, got %#v", r)
+		}
+		if string(r.Message) != "boom" {
+			t.Errorf("expected the report to include message 'boom', got '%s'", r.Message)
+		}
+	case <-time.After(time.Second):
+		t.Error("expected the panic to be reported")
+	}
+	if err := c.Stop(); err != nil {
+		t.Error("unexpected error stopping consumer: ", err)
+	}
+	if stats := c.Stats(); stats.Failed != 1 {
+		t.Errorf("expected the panic to count as a failure, got %+v", stats)
+	}
+}
+
+// testMirrorPublisher will record the bodies of any messages published.
+type testMirrorPublisher struct {
+	mu        sync.Mutex
+	published []string
+}
+
+func (p *testMirrorPublisher) Publish(key string, m proto.Message) error {
+	return errors.New("not implemented")
+}
+
+func (p *testMirrorPublisher) PublishRaw(key string, m []byte) error {
+	p.mu.Lock()
+	p.published = append(p.published, string(m))
+	p.mu.Unlock()
+	return nil
+}
+
+func TestConsumerMirror(t *testing.T) {
+	tests := []struct {
+		rate     float64
+		min, max int
+	}{
+		{0, 100, 100},
+		{0.5, 20, 80},
+	}
+	for _, test := range tests {
+		sub := newTestChanSubscriber()
+		mirror := &testMirrorPublisher{}
+		var handled int32
+		c := NewConsumer(sub, func(ctx context.Context, msg SubscriberMessage) error {
+			atomic.AddInt32(&handled, 1)
+			return nil
+		})
+		c.Mirror = mirror
+		c.MirrorRate = test.rate
+
+		go c.Run()
+		for i := 0; i < 100; i++ {
+			sub.msgs <- &testConsumerMessage{msg: []byte("cat")}
+		}
+		if err := c.Stop(); err != nil {
+			t.Error("unexpected error stopping consumer: ", err)
+		}
+
+		if got := atomic.LoadInt32(&handled); got != 100 {
+			t.Errorf("expected all 100 messages handled, got %d", got)
+		}
+		if got := len(mirror.published); got < test.min || got > test.max {
+			t.Errorf("expected %d to %d messages mirrored at rate %v, got %d", test.min, test.max, test.rate, got)
+		}
+	}
+}
+
+func TestConsumerCanary(t *testing.T) {
+	sub := newTestChanSubscriber()
+	c := NewConsumer(sub, func(ctx context.Context, msg SubscriberMessage) error {
+		if string(msg.Message()) == "bad" {
+			return errors.New("bad message")
+		}
+		return nil
+	})
+	c.Canary = true
+	c.MetricsRegistry = metrics.NewRegistry()
+
+	go c.Run()
+	msgs := []*testReleaseMessage{
+		{testConsumerMessage: testConsumerMessage{msg: []byte("good")}},
+		{testConsumerMessage: testConsumerMessage{msg: []byte("bad")}},
+		{testConsumerMessage: testConsumerMessage{msg: []byte("good")}},
+	}
+	for _, msg := range msgs {
+		sub.msgs <- msg
+	}
+	if err := c.Stop(); err != nil {
+		t.Error("unexpected error stopping consumer: ", err)
+	}
+
+	for _, msg := range msgs {
+		if atomic.LoadInt32(&msg.doned) == 1 {
+			t.Errorf("expected a canary to leave %s message for the stable fleet", msg.msg)
+		}
+		if atomic.LoadInt32(&msg.released) != 1 {
+			t.Errorf("expected a canary to release its %s message", msg.msg)
+		}
+	}
+	if got := metrics.GetOrRegisterCounter("pubsub.consumer.canary.SUCCESS", c.MetricsRegistry).Count(); got != 2 {
+		t.Errorf("expected 2 canary successes, got %d", got)
+	}
+	if got := metrics.GetOrRegisterCounter("pubsub.consumer.canary.ERROR", c.MetricsRegistry).Count(); got != 1 {
+		t.Errorf("expected 1 canary error, got %d", got)
+	}
+	if got := metrics.GetOrRegisterGaugeFloat64("pubsub.consumer.canary.ERROR_RATE", c.MetricsRegistry).Value(); got != 1.0/3 {
+		t.Errorf("expected a canary error rate of 1/3, got %f", got)
+	}
+	if stats := c.Stats(); stats.Handled != 3 || stats.Failed != 1 {
+		t.Errorf("expected 3 handled and 1 failed, got %+v", stats)
+	}
+}
+
+type testReleaseMessage struct {
+	testConsumerMessage
+	released int32
+}
+
+func (m *testReleaseMessage) Release() {
+	atomic.StoreInt32(&m.released, 1)
+}
+
+type testNackMessage struct {
+	testConsumerMessage
+	nacked int32
+}
+
+func (m *testNackMessage) Nack() error {
+	atomic.StoreInt32(&m.nacked, 1)
+	return nil
+}
+
+func TestConsumerTimeout(t *testing.T) {
+	sub := newTestChanSubscriber()
+	canceled := make(chan struct{})
+	c := NewConsumer(sub, func(ctx context.Context, msg SubscriberMessage) error {
+		if string(msg.Message()) == "stuck" {
+			<-ctx.Done()
+			close(canceled)
+		}
+		return nil
+	})
+	c.Timeout = 10 * time.Millisecond
+	c.MetricsRegistry = metrics.NewRegistry()
+
+	go c.Run()
+	stuck := &testNackMessage{testConsumerMessage: testConsumerMessage{msg: []byte("stuck")}}
+	fine := &testNackMessage{testConsumerMessage: testConsumerMessage{msg: []byte("fine")}}
+	sub.msgs <- stuck
+	sub.msgs <- fine
+	if err := c.Stop(); err != nil {
+		t.Error("unexpected error stopping consumer: ", err)
+	}
+
+	select {
+	case <-canceled:
+	case <-time.After(time.Second):
+		t.Fatal("expected the stuck handler's context to be canceled")
+	}
+	if atomic.LoadInt32(&stuck.doned) == 1 || atomic.LoadInt32(&stuck.nacked) != 1 {
+		t.Error("expected the timed out message to be nacked and not done")
+	}
+	if atomic.LoadInt32(&fine.doned) != 1 || atomic.LoadInt32(&fine.nacked) == 1 {
+		t.Error("expected the other message to be done")
+	}
+	if got := metrics.GetOrRegisterCounter("pubsub.consumer.TIMEOUT", c.MetricsRegistry).Count(); got != 1 {
+		t.Errorf("expected 1 timeout, got %d", got)
+	}
+	if stats := c.Stats(); stats.Handled != 2 || stats.Failed != 1 {
+		t.Errorf("expected 2 handled and 1 failed, got %+v", stats)
+	}
+}
+
+func TestConsumerRunContext(t *testing.T) {
+	sub := newTestChanSubscriber()
+	handled := make(chan struct{}, 1)
+	c := NewConsumer(sub, func(ctx context.Context, msg SubscriberMessage) error {
+		handled <- struct{}{}
+		return nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- c.RunContext(ctx)
+	}()
+
+	sub.msgs <- &testConsumerMessage{msg: []byte("good")}
+	<-handled
+	cancel()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("expected no error, got %s", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected the consumer to stop once its context was done")
+	}
+	if c.Running() {
+		t.Error("expected consumer to have stopped running")
+	}
+}
+
+func TestConsumerDrain(t *testing.T) {
+	sub := newTestChanSubscriber()
+	var handled int32
+	c := NewConsumer(sub, func(ctx context.Context, msg SubscriberMessage) error {
+		atomic.AddInt32(&handled, 1)
+		return nil
+	})
+	c.DrainIdle = 20 * time.Millisecond
+
+	done := make(chan error, 1)
+	go func() {
+		done <- c.Run()
+	}()
+	for i := 0; i < 3; i++ {
+		sub.msgs <- &testConsumerMessage{msg: []byte("good")}
+	}
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("expected no error, got %s", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected the consumer to stop once its queue was drained")
+	}
+	if got := atomic.LoadInt32(&handled); got != 3 {
+		t.Errorf("expected 3 messages handled, got %d", got)
+	}
+}
+
+func TestConsume(t *testing.T) {
+	sub := newTestChanSubscriber()
+	handled := make(chan string, 1)
+	run := Consume(sub, func(ctx context.Context, msg SubscriberMessage) error {
+		handled <- string(msg.Message())
+		return nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- run(ctx)
+	}()
+
+	sub.msgs <- &testConsumerMessage{msg: []byte("good")}
+	if got := <-handled; got != "good" {
+		t.Errorf("expected message 'good', got %q", got)
+	}
+	cancel()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("expected no error, got %s", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected Consume to return once its context was done")
+	}
+}
+
+// testHoldingSubscriber will emit its held messages while stopping.
+type testHoldingSubscriber struct {
+	testChanSubscriber
+	held []SubscriberMessage
+}
+
+func (s *testHoldingSubscriber) Stop() error {
+	for _, msg := range s.held {
+		s.msgs <- msg
+	}
+	return s.testChanSubscriber.Stop()
+}
+
+func TestStartWithContextHandsBackDrained(t *testing.T) {
+	nacked := &testNackMessage{}
+	released := &testReleaseMessage{}
+	sub := &testHoldingSubscriber{
+		testChanSubscriber: *newTestChanSubscriber(),
+		held:               []SubscriberMessage{nacked, released},
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	msgs := StartWithContext(ctx, sub)
+
+	cancel()
+	for range msgs {
+		t.Error("expected no messages once the context was done")
+	}
+	if atomic.LoadInt32(&nacked.nacked) != 1 {
+		t.Error("expected the drained message to be nacked")
+	}
+	if atomic.LoadInt32(&released.released) != 1 {
+		t.Error("expected the drained message to be released")
+	}
+}
+
+func TestEmitBatch(t *testing.T) {
+	emit := func(stop chan struct{}) (chan bool, []func()) {
+		output := make(chan SubscriberMessage, 2)
+		made := make(chan func(), 2)
+		result := make(chan bool, 1)
+		go func() {
+			result <- emitBatch(stop, output, 2, func(i int, done func()) SubscriberMessage {
+				made <- done
+				return &testConsumerMessage{}
+			})
+		}()
+		var dones []func()
+		for i := 0; i < 2; i++ {
+			<-output
+			dones = append(dones, <-made)
+		}
+		return result, dones
+	}
+
+	result, dones := emit(make(chan struct{}))
+	dones[0]()
+	dones[0]()
+	select {
+	case <-result:
+		t.Fatal("expected the batch to wait for every message to be done")
+	case <-time.After(10 * time.Millisecond):
+	}
+	dones[1]()
+	if ok := <-result; !ok {
+		t.Error("expected the batch to finish once every message was done")
+	}
+
+	stop := make(chan struct{})
+	result, dones = emit(stop)
+	dones[0]()
+	close(stop)
+	if ok := <-result; ok {
+		t.Error("expected the batch to be abandoned once stopped")
+	}
+	// messages may still be done after the batch was abandoned
+	dones[1]()
+}
+
+func TestStartWithContext(t *testing.T) {
+	sub := newTestChanSubscriber()
+	ctx, cancel := context.WithCancel(context.Background())
+	msgs := StartWithContext(ctx, sub)
+
+	go func() {
+		sub.msgs <- &testConsumerMessage{msg: []byte("good")}
+	}()
+	if got := string((<-msgs).Message()); got != "good" {
+		t.Errorf("expected message 'good', got %q", got)
+	}
+
+	cancel()
+	select {
+	case _, ok := <-msgs:
+		if ok {
+			t.Error("expected no messages once the context was done")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected the channel to close once the context was done")
+	}
+}
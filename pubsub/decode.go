@@ -0,0 +1,147 @@
+package pubsub
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// DefaultMaxDecodedSize is the most bytes a message body may be decoded
+// into, by base64 decoding or decompression, if no other limit is
+// configured. It keeps a corrupt or malicious message from ballooning a
+// consumer's memory.
+var DefaultMaxDecodedSize int64 = 16 << 20
+
+// maxPooledBuffer is the largest buffer that will be kept for reuse, so a
+// few large messages do not pin their memory.
+const maxPooledBuffer = 1 << 20
+
+var (
+	bufPool  = sync.Pool{New: func() interface{} { return new([]byte) }}
+	gzipPool sync.Pool
+)
+
+// GetBuffer will return an empty buffer from a pool shared with the
+// package's decoders, for use with MessageInto. Return it with PutBuffer
+// once its contents are no longer used.
+func GetBuffer() []byte {
+	return (*bufPool.Get().(*[]byte))[:0]
+}
+
+// PutBuffer will return a buffer to the pool for reuse.
+func PutBuffer(buf []byte) {
+	if cap(buf) > maxPooledBuffer {
+		return
+	}
+	buf = buf[:0]
+	bufPool.Put(&buf)
+}
+
+// BufferedMessage is a SubscriberMessage that can write its payload into a
+// buffer supplied by the caller, so consumers of busy queues can reuse
+// buffers instead of allocating for every message.
+type BufferedMessage interface {
+	SubscriberMessage
+	// MessageInto will write the payload into buf, reusing its capacity,
+	// and return the resulting slice.
+	MessageInto(buf []byte) []byte
+}
+
+// MessageInto will write the message's payload into buf, reusing its
+// capacity, and return the resulting slice. If msg is not a
+// BufferedMessage, its Message is copied into buf:
+//
+//	buf := pubsub.MessageInto(msg, pubsub.GetBuffer())
+//	defer pubsub.PutBuffer(buf)
+func MessageInto(msg SubscriberMessage, buf []byte) []byte {
+	if bmsg, ok := msg.(BufferedMessage); ok {
+		return bmsg.MessageInto(buf)
+	}
+	return append(buf[:0], msg.Message()...)
+}
+
+// maxDecodedSize will return the limit to decode with, where 0 means the
+// DefaultMaxDecodedSize and a negative limit means there is none.
+func maxDecodedSize(max int64) int64 {
+	if max == 0 {
+		return DefaultMaxDecodedSize
+	}
+	return max
+}
+
+// tooLarge will return an ErrMessageTooLarge Error for a message that
+// would decode into more than max bytes.
+func tooLarge(max int64) error {
+	return &Error{
+		Class: ErrMessageTooLarge,
+		Err:   fmt.Errorf("decoded message exceeds the limit of %d bytes", max),
+	}
+}
+
+// DecodeBase64 will decode the standard base64 string, failing with an
+// ErrMessageTooLarge Error before decoding if it would be over max bytes.
+// A max of 0 uses the DefaultMaxDecodedSize and a negative max has no
+// limit.
+func DecodeBase64(s string, max int64) ([]byte, error) {
+	return DecodeBase64Into(nil, s, max)
+}
+
+// DecodeBase64Into will decode the string like DecodeBase64, but into buf,
+// reusing its capacity.
+func DecodeBase64Into(buf []byte, s string, max int64) ([]byte, error) {
+	n := base64.StdEncoding.DecodedLen(len(s))
+	if max = maxDecodedSize(max); max > 0 && int64(n) > max {
+		return nil, tooLarge(max)
+	}
+	if cap(buf) < n {
+		buf = make([]byte, n)
+	}
+	// decode from a pooled copy rather than allocating one for the string
+	src := append(GetBuffer(), s...)
+	n, err := base64.StdEncoding.Decode(buf[:n], src)
+	PutBuffer(src)
+	return buf[:n], err
+}
+
+// Gunzip will decompress the gzipped message, failing with an
+// ErrMessageTooLarge Error as soon as it decompresses to over max bytes,
+// so compression bombs are caught without being fully decompressed. A max
+// of 0 uses the DefaultMaxDecodedSize and a negative max has no limit.
+func Gunzip(m []byte, max int64) ([]byte, error) {
+	return GunzipInto(nil, m, max)
+}
+
+// GunzipInto will decompress the message like Gunzip, but into buf,
+// reusing its capacity.
+func GunzipInto(buf, m []byte, max int64) ([]byte, error) {
+	var err error
+	gr, ok := gzipPool.Get().(*gzip.Reader)
+	if ok {
+		err = gr.Reset(bytes.NewReader(m))
+	} else {
+		gr, err = gzip.NewReader(bytes.NewReader(m))
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer gzipPool.Put(gr)
+	return readLimited(buf, gr, maxDecodedSize(max))
+}
+
+// readLimited will read all of r into buf, failing if it is over max bytes.
+func readLimited(buf []byte, r io.Reader, max int64) ([]byte, error) {
+	if max >= 0 {
+		r = io.LimitReader(r, max+1)
+	}
+	b := bytes.NewBuffer(buf[:0])
+	if _, err := b.ReadFrom(r); err != nil {
+		return nil, err
+	}
+	if max >= 0 && int64(b.Len()) > max {
+		return nil, tooLarge(max)
+	}
+	return b.Bytes(), nil
+}
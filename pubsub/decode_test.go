@@ -0,0 +1,76 @@
+package pubsub
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"testing"
+)
+
+func TestDecodeBase64(t *testing.T) {
+	s := base64.StdEncoding.EncodeToString([]byte("hello cats"))
+	if got, err := DecodeBase64(s, 0); err != nil || string(got) != "hello cats" {
+		t.Errorf("expected 'hello cats', got %q, %v", got, err)
+	}
+	if _, err := DecodeBase64(s, 4); ErrorClass(err) != ErrMessageTooLarge {
+		t.Errorf("expected ErrMessageTooLarge, got %v", err)
+	}
+	if _, err := DecodeBase64(s, -1); err != nil {
+		t.Errorf("expected no limit, got %s", err)
+	}
+	if _, err := DecodeBase64("not base64!", 0); err == nil {
+		t.Error("expected an error for a corrupt body")
+	}
+}
+
+func TestGunzip(t *testing.T) {
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	// a small message that decompresses to 1MB
+	gw.Write(make([]byte, 1<<20))
+	gw.Close()
+
+	if _, err := Gunzip(buf.Bytes(), 1024); ErrorClass(err) != ErrMessageTooLarge {
+		t.Errorf("expected ErrMessageTooLarge, got %v", err)
+	}
+	got, err := Gunzip(buf.Bytes(), 0)
+	if err != nil {
+		t.Fatalf("expected no error, got %s", err)
+	}
+	if len(got) != 1<<20 {
+		t.Errorf("expected 1MB, got %d bytes", len(got))
+	}
+	if _, err := Gunzip([]byte("not gzip"), 0); err == nil {
+		t.Error("expected an error for a corrupt body")
+	}
+}
+
+func TestDecodeBase64Into(t *testing.T) {
+	s := base64.StdEncoding.EncodeToString([]byte("hello cats"))
+	buf := make([]byte, 0, 64)
+	got, err := DecodeBase64Into(buf, s, 0)
+	if err != nil || string(got) != "hello cats" {
+		t.Fatalf("expected 'hello cats', got %q, %v", got, err)
+	}
+	if &got[:1][0] != &buf[:1][0] {
+		t.Error("expected the buffer to be reused")
+	}
+}
+
+func TestMessageInto(t *testing.T) {
+	buf := GetBuffer()
+	buf = MessageInto(&testConsumerMessage{msg: []byte("meow")}, buf)
+	if string(buf) != "meow" {
+		t.Errorf("expected 'meow', got %q", buf)
+	}
+	PutBuffer(buf)
+}
+
+func BenchmarkDecodeBase64Into(b *testing.B) {
+	s := base64.StdEncoding.EncodeToString(make([]byte, 4096))
+	buf := GetBuffer()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		buf, _ = DecodeBase64Into(buf, s, 0)
+	}
+}
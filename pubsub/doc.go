@@ -26,10 +26,42 @@ Package pubsub contains two generic interfaces for publishing data to queues and
 
 Where a `SubscriberMessage` is an interface that gives implementations a hook for acknowledging/delete messages. Take a look at the docs for each implementation in `pubsub` to see how they behave.
 
-There are currently 2 implementations of each type of `pubsub` interfaces:
+There are implementations of the `pubsub` interfaces for several backends:
 
 For pubsub via Amazon's SNS/SQS, you can use the `SNSPublisher` and the `SQSSubscriber`.
 
 For pubsub via Kafka topics, you can use the `KakfaPublisher` and the `KafkaSubscriber`.
+
+To consume the change records of a DynamoDB table with the same consumer code as a queue, you can use the `DynamoDBStreamSubscriber`. It coordinates which instance reads each shard of the stream and checkpoints its progress in a DynamoDB lease table.
+
+For low-volume eventing within an app that already uses Postgres, you can use the `PostgresPublisher` and the `PostgresSubscriber`. Messages are written to a journal table and subscribers are woken with LISTEN/NOTIFY, so any notifications missed while disconnected are caught up on from the journal.
+
+For batch file ingestion, the `FileSubscriber` polls a `FileSource`, like a local directory with a `DirSource` or an S3 prefix with an `S3Source`, and emits each new file as a message. Files are moved or removed once their messages are done.
+
+To process messages from any `Subscriber`, the `Consumer` will run a `MessageHandler` over each message with a configurable concurrency and mark messages as done when the handler succeeds:
+
+    consumer := pubsub.NewConsumer(sub, func(ctx context.Context, msg pubsub.SubscriberMessage) error {
+        return process(msg.Message())
+    })
+    err := consumer.Run()
+
+To relay messages from a `Subscriber` to a `Publisher`, like mirroring a queue to a topic, `Bridge` will return a `Consumer` that publishes each message with optional transformation, concurrency, rate limits and failure routing:
+
+    bridge := pubsub.Bridge(sub, pub, pubsub.BridgeOptions{Concurrency: 10, Failures: dlq})
+    err := bridge.Run()
+
+To publish a Consumer's throughput, error rate and processing latency as CloudWatch custom metrics, start a `CloudWatchReporter`. Its optional `Lag` func, such as `SQSSubscriber.Lag`, will be published as well:
+
+    reporter, err := pubsub.NewCloudWatchReporter(cfg.CloudWatch, consumer)
+    reporter.Lag = sub.Lag
+    reporter.Start()
+    defer reporter.Stop()
+
+Each backend is built by default. To keep the AWS SDK, sarama or the Google
+Cloud client out of a binary that does not need them, build with the
+'noaws', 'nokafka' or 'nogcp' tags. The core interfaces, the Consumer, the
+in-memory and Postgres backends and the publisher wrappers are always built:
+
+    go build -tags 'nokafka nogcp' ./cmd/cats
 */
 package pubsub
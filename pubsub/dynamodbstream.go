@@ -0,0 +1,570 @@
+// +build !noaws
+
+package pubsub
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/aws/aws-sdk-go/service/dynamodbstreams"
+	"github.com/aws/aws-sdk-go/service/dynamodbstreams/dynamodbstreamsiface"
+
+	"github.com/NYTimes/gizmo/config"
+)
+
+var (
+	// DefaultDynamoDBStreamLeaseDuration is how long a shard lease is held
+	// without being renewed if no LeaseDuration is given.
+	DefaultDynamoDBStreamLeaseDuration = 30 * time.Second
+	// DefaultDynamoDBStreamPollInterval is how long to wait before reading
+	// a shard again if no PollInterval is given.
+	DefaultDynamoDBStreamPollInterval = time.Second
+	// DefaultDynamoDBStreamMaxRecords is the max number of records read
+	// from a shard at once if no MaxRecords is given.
+	DefaultDynamoDBStreamMaxRecords int64 = 100
+)
+
+// shardEnd is the checkpoint of a shard that has been read to its end.
+const shardEnd = "SHARD_END"
+
+// errLeaseLost is returned when another worker has taken a shard's lease.
+var errLeaseLost = errors.New("dynamodb stream shard lease was taken by another worker")
+
+type (
+	// DynamoDBStreamSubscriber is a Subscriber that emits the change records
+	// of a DynamoDB table. Each shard of the stream is read by a single
+	// instance at a time, coordinated by leases in a DynamoDB table that also
+	// checkpoints how far each shard has been read. The lease table must have
+	// a string hash key named 'lease'.
+	//
+	// Records are emitted in order for each shard, a batch at a time. A shard's
+	// checkpoint will only advance once every message in the batch is Done, so
+	// records may be emitted again if an instance stops before then. Child
+	// shards are not read until their parent has been read to its end.
+	DynamoDBStreamSubscriber struct {
+		streams dynamodbstreamsiface.DynamoDBStreamsAPI
+		leases  dynamodbiface.DynamoDBAPI
+		cfg     *config.DynamoDBStream
+
+		mu sync.Mutex
+		// shards being read by this worker
+		reading map[string]bool
+		// shards read to their end
+		finished map[string]bool
+		err      error
+
+		wg       sync.WaitGroup
+		stop     chan struct{}
+		stopOnce sync.Once
+		done     chan struct{}
+	}
+
+	// DynamoDBStreamMessage is the DynamoDB Streams implementation of
+	// `SubscriberMessage`.
+	DynamoDBStreamMessage struct {
+		record *dynamodbstreams.Record
+		done   func()
+	}
+
+	// DynamoDBStreamRecord is the JSON body of a DynamoDBStreamMessage with
+	// the record's keys and images as plain values.
+	DynamoDBStreamRecord struct {
+		EventID        string                 `json:"event_id"`
+		EventName      string                 `json:"event_name"`
+		SequenceNumber string                 `json:"sequence_number"`
+		Keys           map[string]interface{} `json:"keys,omitempty"`
+		NewImage       map[string]interface{} `json:"new_image,omitempty"`
+		OldImage       map[string]interface{} `json:"old_image,omitempty"`
+	}
+
+	// streamLease is this worker's hold on a shard.
+	streamLease struct {
+		key        string
+		checkpoint string
+	}
+)
+
+// NewDynamoDBStreamSubscriber will set up the DynamoDB Streams and DynamoDB
+// clients. If no credentials are passed in with the config, the subscriber
+// is instantiated with the AWS_ACCESS_KEY and the AWS_SECRET_KEY environment
+// variables.
+func NewDynamoDBStreamSubscriber(cfg *config.DynamoDBStream) (*DynamoDBStreamSubscriber, error) {
+	if cfg.StreamARN == "" {
+		return nil, errors.New("dynamodb stream arn is required")
+	}
+	if cfg.LeaseTable == "" {
+		return nil, errors.New("dynamodb stream lease table is required")
+	}
+	if cfg.Region == "" {
+		return nil, errors.New("dynamodb stream region is required")
+	}
+
+	var creds *credentials.Credentials
+	if cfg.AccessKey != "" {
+		creds = credentials.NewStaticCredentials(cfg.AccessKey, cfg.SecretKey, "")
+	} else {
+		creds = credentials.NewEnvCredentials()
+	}
+	sess := session.New(awsEndpoints(cfg.AWS, &aws.Config{
+		Credentials: creds,
+		Region:      &cfg.Region,
+	}))
+	return newDynamoDBStreamSubscriber(cfg, dynamodbstreams.New(sess), dynamodb.New(sess)), nil
+}
+
+func newDynamoDBStreamSubscriber(cfg *config.DynamoDBStream, streams dynamodbstreamsiface.DynamoDBStreamsAPI, leases dynamodbiface.DynamoDBAPI) *DynamoDBStreamSubscriber {
+	defaultDynamoDBStreamConfig(cfg)
+	return &DynamoDBStreamSubscriber{
+		streams:  streams,
+		leases:   leases,
+		cfg:      cfg,
+		reading:  map[string]bool{},
+		finished: map[string]bool{},
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+func defaultDynamoDBStreamConfig(cfg *config.DynamoDBStream) {
+	if cfg.WorkerID == "" {
+		host, _ := os.Hostname()
+		cfg.WorkerID = fmt.Sprintf("%s-%d", host, os.Getpid())
+	}
+	if cfg.LeaseDuration == 0 {
+		cfg.LeaseDuration = DefaultDynamoDBStreamLeaseDuration
+	}
+	if cfg.PollInterval == 0 {
+		cfg.PollInterval = DefaultDynamoDBStreamPollInterval
+	}
+	if cfg.MaxRecords == 0 {
+		cfg.MaxRecords = DefaultDynamoDBStreamMaxRecords
+	}
+}
+
+// Message will return the record encoded as a DynamoDBStreamRecord.
+func (m *DynamoDBStreamMessage) Message() []byte {
+	rec := DynamoDBStreamRecord{
+		EventID:   aws.StringValue(m.record.EventID),
+		EventName: aws.StringValue(m.record.EventName),
+	}
+	if d := m.record.Dynamodb; d != nil {
+		rec.SequenceNumber = aws.StringValue(d.SequenceNumber)
+		for _, img := range []struct {
+			from map[string]*dynamodb.AttributeValue
+			to   *map[string]interface{}
+		}{{d.Keys, &rec.Keys}, {d.NewImage, &rec.NewImage}, {d.OldImage, &rec.OldImage}} {
+			if len(img.from) == 0 {
+				continue
+			}
+			if err := dynamodbattribute.UnmarshalMap(img.from, img.to); err != nil {
+				Log.Warnf("unable to decode dynamodb stream record: %s", err)
+			}
+		}
+	}
+	b, err := json.Marshal(rec)
+	if err != nil {
+		Log.Warnf("unable to encode dynamodb stream record: %s", err)
+	}
+	return b
+}
+
+// Record will return the raw DynamoDB Streams record.
+func (m *DynamoDBStreamMessage) Record() *dynamodbstreams.Record {
+	return m.record
+}
+
+// Done will mark the record as handled so its shard can be
+// checkpointed once the rest of its batch is done.
+func (m *DynamoDBStreamMessage) Done() error {
+	m.done()
+	return nil
+}
+
+// Start will begin discovering the stream's shards, taking the lease of any
+// that are free and emitting their records to the returned channel. If it
+// encounters any issues, it will populate the Err() error and close the
+// returned channel.
+func (s *DynamoDBStreamSubscriber) Start() <-chan SubscriberMessage {
+	output := make(chan SubscriberMessage)
+	go func() {
+		defer close(s.done)
+		defer close(output)
+		// expired leases are checked for at least twice per lease
+		ticker := time.NewTicker(s.cfg.LeaseDuration / 2)
+		defer ticker.Stop()
+		for {
+			if err := s.syncShards(output); err != nil {
+				s.fail(err)
+			}
+			select {
+			case <-s.stop:
+				s.wg.Wait()
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	return output
+}
+
+// Stop will stop reading the stream, wait for any shard readers to
+// exit and release their leases. Records that have been emitted but
+// not checkpointed will be emitted again by the next lease holder.
+func (s *DynamoDBStreamSubscriber) Stop() error {
+	s.stopOnce.Do(func() {
+		close(s.stop)
+	})
+	<-s.done
+	return nil
+}
+
+// Err will contain any errors that occurred during consumption. This
+// method should be checked after a user encounters a closed channel.
+func (s *DynamoDBStreamSubscriber) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+// fail will record the error and stop the subscriber.
+func (s *DynamoDBStreamSubscriber) fail(err error) {
+	s.mu.Lock()
+	if s.err == nil {
+		s.err = err
+	}
+	s.mu.Unlock()
+	s.stopOnce.Do(func() {
+		close(s.stop)
+	})
+}
+
+func (s *DynamoDBStreamSubscriber) stopped() bool {
+	select {
+	case <-s.stop:
+		return true
+	default:
+		return false
+	}
+}
+
+// syncShards will describe the stream and start reading any shards that are
+// ready to be read and are not leased by another worker.
+func (s *DynamoDBStreamSubscriber) syncShards(output chan<- SubscriberMessage) error {
+	var (
+		shards []*dynamodbstreams.Shard
+		start  *string
+	)
+	for {
+		resp, err := s.streams.DescribeStream(&dynamodbstreams.DescribeStreamInput{
+			StreamArn:             &s.cfg.StreamARN,
+			ExclusiveStartShardId: start,
+		})
+		if err != nil {
+			return err
+		}
+		if resp.StreamDescription == nil {
+			break
+		}
+		shards = append(shards, resp.StreamDescription.Shards...)
+		start = resp.StreamDescription.LastEvaluatedShardId
+		if start == nil {
+			break
+		}
+	}
+
+	known := make(map[string]bool, len(shards))
+	for _, shard := range shards {
+		known[aws.StringValue(shard.ShardId)] = true
+	}
+	for _, shard := range shards {
+		id := aws.StringValue(shard.ShardId)
+		s.mu.Lock()
+		skip := s.reading[id] || s.finished[id]
+		s.mu.Unlock()
+		if skip || s.stopped() {
+			continue
+		}
+
+		parent := aws.StringValue(shard.ParentShardId)
+		if parent != "" && known[parent] {
+			done, err := s.isFinished(parent)
+			if err != nil {
+				return err
+			}
+			if !done {
+				// the parent's records need to be read first
+				continue
+			}
+		}
+
+		lease, ok, err := s.acquire(id)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
+		if lease.checkpoint == shardEnd {
+			s.markFinished(id)
+			s.release(lease)
+			continue
+		}
+
+		s.mu.Lock()
+		s.reading[id] = true
+		s.mu.Unlock()
+		s.wg.Add(1)
+		go s.readShard(id, parent != "", lease, output)
+	}
+	return nil
+}
+
+func (s *DynamoDBStreamSubscriber) markFinished(shard string) {
+	s.mu.Lock()
+	s.finished[shard] = true
+	s.mu.Unlock()
+}
+
+// readShard will emit the shard's records a batch at a time, checkpointing
+// after each batch, until the shard ends, its lease is lost or the
+// subscriber is stopped.
+func (s *DynamoDBStreamSubscriber) readShard(shard string, hasParent bool, lease *streamLease, output chan<- SubscriberMessage) {
+	defer func() {
+		s.mu.Lock()
+		delete(s.reading, shard)
+		s.mu.Unlock()
+		s.wg.Done()
+	}()
+	log := Log.WithField("shard", shard)
+
+	iter, err := s.shardIterator(shard, hasParent, lease.checkpoint)
+	if err != nil {
+		s.fail(err)
+		return
+	}
+	renewed := time.Now()
+	for !s.stopped() {
+		resp, err := s.streams.GetRecords(&dynamodbstreams.GetRecordsInput{
+			ShardIterator: iter,
+			Limit:         &s.cfg.MaxRecords,
+		})
+		if aerr, ok := err.(awserr.Error); ok {
+			switch aerr.Code() {
+			case "ExpiredIteratorException":
+				iter, err = s.shardIterator(shard, hasParent, lease.checkpoint)
+			case "ProvisionedThroughputExceededException", "LimitExceededException":
+				s.sleep(s.cfg.PollInterval)
+				continue
+			}
+			if err == nil {
+				continue
+			}
+		}
+		if err != nil {
+			s.fail(err)
+			return
+		}
+
+		if len(resp.Records) > 0 {
+			records := resp.Records
+			ok := emitBatch(s.stop, output, len(records), func(i int, done func()) SubscriberMessage {
+				return &DynamoDBStreamMessage{record: records[i], done: done}
+			})
+			if !ok {
+				// stopped before the batch was done
+				s.release(lease)
+				return
+			}
+			last := resp.Records[len(resp.Records)-1]
+			lease.checkpoint = aws.StringValue(last.Dynamodb.SequenceNumber)
+			err = s.checkpoint(lease)
+			renewed = time.Now()
+		} else if time.Since(renewed) > s.cfg.LeaseDuration/3 {
+			err = s.renew(lease)
+			renewed = time.Now()
+		}
+		if err == errLeaseLost {
+			log.Warn(err)
+			return
+		}
+		if err != nil {
+			s.fail(err)
+			return
+		}
+
+		if resp.NextShardIterator == nil {
+			lease.checkpoint = shardEnd
+			if err := s.checkpoint(lease); err != nil && err != errLeaseLost {
+				s.fail(err)
+				return
+			}
+			s.markFinished(shard)
+			s.release(lease)
+			log.Info("finished reading dynamodb stream shard")
+			return
+		}
+		iter = resp.NextShardIterator
+		if len(resp.Records) == 0 {
+			s.sleep(s.cfg.PollInterval)
+		}
+	}
+	s.release(lease)
+}
+
+func (s *DynamoDBStreamSubscriber) sleep(d time.Duration) {
+	select {
+	case <-s.stop:
+	case <-time.After(d):
+	}
+}
+
+func (s *DynamoDBStreamSubscriber) shardIterator(shard string, hasParent bool, checkpoint string) (*string, error) {
+	in := &dynamodbstreams.GetShardIteratorInput{
+		StreamArn: &s.cfg.StreamARN,
+		ShardId:   &shard,
+	}
+	switch {
+	case checkpoint != "":
+		in.ShardIteratorType = aws.String(dynamodbstreams.ShardIteratorTypeAfterSequenceNumber)
+		in.SequenceNumber = &checkpoint
+	case hasParent || s.cfg.StartFromOldest:
+		// a child of a shard we have read needs all of its records
+		in.ShardIteratorType = aws.String(dynamodbstreams.ShardIteratorTypeTrimHorizon)
+	default:
+		in.ShardIteratorType = aws.String(dynamodbstreams.ShardIteratorTypeLatest)
+	}
+	resp, err := s.streams.GetShardIterator(in)
+	if err != nil {
+		return nil, err
+	}
+	return resp.ShardIterator, nil
+}
+
+func (s *DynamoDBStreamSubscriber) leaseKey(shard string) string {
+	return s.cfg.StreamARN + "/" + shard
+}
+
+// isFinished will check the lease table for whether the
+// shard has been read to its end.
+func (s *DynamoDBStreamSubscriber) isFinished(shard string) (bool, error) {
+	s.mu.Lock()
+	done := s.finished[shard]
+	s.mu.Unlock()
+	if done {
+		return true, nil
+	}
+	resp, err := s.leases.GetItem(&dynamodb.GetItemInput{
+		TableName:      &s.cfg.LeaseTable,
+		Key:            map[string]*dynamodb.AttributeValue{"lease": {S: aws.String(s.leaseKey(shard))}},
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return false, err
+	}
+	if cp := resp.Item["checkpoint"]; cp != nil && aws.StringValue(cp.S) == shardEnd {
+		s.markFinished(shard)
+		return true, nil
+	}
+	return false, nil
+}
+
+// acquire will take the shard's lease if it is free, expired or already
+// held by this worker and return its checkpoint.
+func (s *DynamoDBStreamSubscriber) acquire(shard string) (*streamLease, bool, error) {
+	key := s.leaseKey(shard)
+	now := time.Now()
+	resp, err := s.leases.UpdateItem(&dynamodb.UpdateItemInput{
+		TableName:           &s.cfg.LeaseTable,
+		Key:                 map[string]*dynamodb.AttributeValue{"lease": {S: &key}},
+		UpdateExpression:    aws.String("SET #owner = :owner, #expires = :expires"),
+		ConditionExpression: aws.String("attribute_not_exists(#lease) OR #owner = :owner OR #expires < :now"),
+		ExpressionAttributeNames: map[string]*string{
+			"#lease":   aws.String("lease"),
+			"#owner":   aws.String("owner"),
+			"#expires": aws.String("expires"),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":owner":   {S: &s.cfg.WorkerID},
+			":expires": {N: aws.String(streamMillis(now.Add(s.cfg.LeaseDuration)))},
+			":now":     {N: aws.String(streamMillis(now))},
+		},
+		ReturnValues: aws.String(dynamodb.ReturnValueAllNew),
+	})
+	if isConditionFailed(err) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	lease := &streamLease{key: key}
+	if cp := resp.Attributes["checkpoint"]; cp != nil {
+		lease.checkpoint = aws.StringValue(cp.S)
+	}
+	return lease, true, nil
+}
+
+// checkpoint will save the lease's checkpoint and renew it.
+func (s *DynamoDBStreamSubscriber) checkpoint(lease *streamLease) error {
+	return s.updateLease(lease, "SET #checkpoint = :checkpoint, #expires = :expires", map[string]*dynamodb.AttributeValue{
+		":checkpoint": {S: aws.String(lease.checkpoint)},
+		":expires":    {N: aws.String(streamMillis(time.Now().Add(s.cfg.LeaseDuration)))},
+	})
+}
+
+// renew will extend the lease.
+func (s *DynamoDBStreamSubscriber) renew(lease *streamLease) error {
+	return s.updateLease(lease, "SET #expires = :expires", map[string]*dynamodb.AttributeValue{
+		":expires": {N: aws.String(streamMillis(time.Now().Add(s.cfg.LeaseDuration)))},
+	})
+}
+
+// release will expire the lease so another worker can take it right away.
+func (s *DynamoDBStreamSubscriber) release(lease *streamLease) {
+	err := s.updateLease(lease, "SET #expires = :expires", map[string]*dynamodb.AttributeValue{
+		":expires": {N: aws.String("0")},
+	})
+	if err != nil && err != errLeaseLost {
+		Log.Warnf("unable to release dynamodb stream lease %s: %s", lease.key, err)
+	}
+}
+
+func (s *DynamoDBStreamSubscriber) updateLease(lease *streamLease, update string, values map[string]*dynamodb.AttributeValue) error {
+	names := map[string]*string{"#owner": aws.String("owner"), "#expires": aws.String("expires")}
+	if _, ok := values[":checkpoint"]; ok {
+		names["#checkpoint"] = aws.String("checkpoint")
+	}
+	values[":owner"] = &dynamodb.AttributeValue{S: &s.cfg.WorkerID}
+	_, err := s.leases.UpdateItem(&dynamodb.UpdateItemInput{
+		TableName:                 &s.cfg.LeaseTable,
+		Key:                       map[string]*dynamodb.AttributeValue{"lease": {S: &lease.key}},
+		UpdateExpression:          &update,
+		ConditionExpression:       aws.String("#owner = :owner"),
+		ExpressionAttributeNames:  names,
+		ExpressionAttributeValues: values,
+	})
+	if isConditionFailed(err) {
+		return errLeaseLost
+	}
+	return err
+}
+
+func isConditionFailed(err error) bool {
+	aerr, ok := err.(awserr.Error)
+	return ok && aerr.Code() == "ConditionalCheckFailedException"
+}
+
+func streamMillis(t time.Time) string {
+	return strconv.FormatInt(t.UnixNano()/int64(time.Millisecond), 10)
+}
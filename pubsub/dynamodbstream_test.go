@@ -0,0 +1,302 @@
+// +build !noaws
+
+package pubsub
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/aws/aws-sdk-go/service/dynamodbstreams"
+	"github.com/aws/aws-sdk-go/service/dynamodbstreams/dynamodbstreamsiface"
+
+	"github.com/NYTimes/gizmo/config"
+)
+
+type testStreamShard struct {
+	id, parent string
+	records    []*dynamodbstreams.Record
+	closed     bool
+}
+
+// testDynamoDBStreamsAPI serves records from in-memory shards. Iterators
+// are the shard ID and the index of the next record.
+type testDynamoDBStreamsAPI struct {
+	dynamodbstreamsiface.DynamoDBStreamsAPI
+
+	mu     sync.Mutex
+	shards []*testStreamShard
+}
+
+func (s *testDynamoDBStreamsAPI) shard(id string) *testStreamShard {
+	for _, shard := range s.shards {
+		if shard.id == id {
+			return shard
+		}
+	}
+	return nil
+}
+
+func (s *testDynamoDBStreamsAPI) DescribeStream(*dynamodbstreams.DescribeStreamInput) (*dynamodbstreams.DescribeStreamOutput, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	desc := &dynamodbstreams.StreamDescription{}
+	for _, shard := range s.shards {
+		sh := &dynamodbstreams.Shard{ShardId: aws.String(shard.id)}
+		if shard.parent != "" {
+			sh.ParentShardId = aws.String(shard.parent)
+		}
+		desc.Shards = append(desc.Shards, sh)
+	}
+	return &dynamodbstreams.DescribeStreamOutput{StreamDescription: desc}, nil
+}
+
+func (s *testDynamoDBStreamsAPI) GetShardIterator(i *dynamodbstreams.GetShardIteratorInput) (*dynamodbstreams.GetShardIteratorOutput, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	shard := s.shard(*i.ShardId)
+	var next int
+	switch *i.ShardIteratorType {
+	case dynamodbstreams.ShardIteratorTypeLatest:
+		next = len(shard.records)
+	case dynamodbstreams.ShardIteratorTypeAfterSequenceNumber:
+		for j, r := range shard.records {
+			if *r.Dynamodb.SequenceNumber == *i.SequenceNumber {
+				next = j + 1
+			}
+		}
+	}
+	return &dynamodbstreams.GetShardIteratorOutput{ShardIterator: aws.String(fmt.Sprintf("%s:%d", shard.id, next))}, nil
+}
+
+func (s *testDynamoDBStreamsAPI) GetRecords(i *dynamodbstreams.GetRecordsInput) (*dynamodbstreams.GetRecordsOutput, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	parts := strings.Split(*i.ShardIterator, ":")
+	shard := s.shard(parts[0])
+	next, _ := strconv.Atoi(parts[1])
+	end := next + int(*i.Limit)
+	if end > len(shard.records) {
+		end = len(shard.records)
+	}
+	out := &dynamodbstreams.GetRecordsOutput{Records: shard.records[next:end]}
+	if end < len(shard.records) || !shard.closed {
+		out.NextShardIterator = aws.String(fmt.Sprintf("%s:%d", shard.id, end))
+	}
+	return out, nil
+}
+
+// testLeaseAPI evaluates lease conditions against an in-memory table.
+type testLeaseAPI struct {
+	dynamodbiface.DynamoDBAPI
+
+	mu    sync.Mutex
+	items map[string]map[string]*dynamodb.AttributeValue
+}
+
+func (l *testLeaseAPI) GetItem(i *dynamodb.GetItemInput) (*dynamodb.GetItemOutput, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return &dynamodb.GetItemOutput{Item: copyItem(l.items[*i.Key["lease"].S])}, nil
+}
+
+func copyItem(item map[string]*dynamodb.AttributeValue) map[string]*dynamodb.AttributeValue {
+	if item == nil {
+		return nil
+	}
+	c := make(map[string]*dynamodb.AttributeValue, len(item))
+	for k, v := range item {
+		c[k] = v
+	}
+	return c
+}
+
+func (l *testLeaseAPI) UpdateItem(i *dynamodb.UpdateItemInput) (*dynamodb.UpdateItemOutput, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	key := *i.Key["lease"].S
+	item, exists := l.items[key]
+	vals := i.ExpressionAttributeValues
+	owner := *vals[":owner"].S
+
+	pass := exists && *item["owner"].S == owner
+	if strings.HasPrefix(*i.ConditionExpression, "attribute_not_exists") {
+		pass = !exists || pass
+		if exists && !pass {
+			expires, _ := strconv.ParseInt(*item["expires"].N, 10, 64)
+			now, _ := strconv.ParseInt(*vals[":now"].N, 10, 64)
+			pass = expires < now
+		}
+	}
+	if !pass {
+		return nil, awserr.New("ConditionalCheckFailedException", "condition failed", nil)
+	}
+
+	if !exists {
+		item = map[string]*dynamodb.AttributeValue{"lease": {S: &key}}
+		l.items[key] = item
+	}
+	item["owner"] = vals[":owner"]
+	item["expires"] = vals[":expires"]
+	if cp, ok := vals[":checkpoint"]; ok {
+		item["checkpoint"] = cp
+	}
+	return &dynamodb.UpdateItemOutput{Attributes: copyItem(item)}, nil
+}
+
+func (l *testLeaseAPI) checkpoint(shard string) string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if item, ok := l.items["arn/"+shard]; ok && item["checkpoint"] != nil {
+		return *item["checkpoint"].S
+	}
+	return ""
+}
+
+func testStreamRecords(shard string, n int) []*dynamodbstreams.Record {
+	var records []*dynamodbstreams.Record
+	for i := 1; i <= n; i++ {
+		id := fmt.Sprintf("%s-%d", shard, i)
+		records = append(records, &dynamodbstreams.Record{
+			EventID:   aws.String(id),
+			EventName: aws.String("INSERT"),
+			Dynamodb: &dynamodbstreams.StreamRecord{
+				SequenceNumber: aws.String(id),
+				Keys:           map[string]*dynamodb.AttributeValue{"id": {S: aws.String(id)}},
+				NewImage: map[string]*dynamodb.AttributeValue{
+					"id":    {S: aws.String(id)},
+					"count": {N: aws.String(strconv.Itoa(i))},
+				},
+			},
+		})
+	}
+	return records
+}
+
+func newTestStreamSubscriber(streams *testDynamoDBStreamsAPI, leases *testLeaseAPI, worker string) *DynamoDBStreamSubscriber {
+	return newDynamoDBStreamSubscriber(&config.DynamoDBStream{
+		StreamARN:       "arn",
+		LeaseTable:      "leases",
+		WorkerID:        worker,
+		LeaseDuration:   time.Second,
+		PollInterval:    time.Millisecond,
+		MaxRecords:      2,
+		StartFromOldest: true,
+	}, streams, leases)
+}
+
+// readStream will return the event IDs of the first n messages.
+func readStream(t *testing.T, msgs <-chan SubscriberMessage, n int) []string {
+	var ids []string
+	for len(ids) < n {
+		select {
+		case msg, ok := <-msgs:
+			if !ok {
+				t.Fatalf("expected %d messages, the channel closed after %v", n, ids)
+			}
+			var rec DynamoDBStreamRecord
+			if err := json.Unmarshal(msg.Message(), &rec); err != nil {
+				t.Fatalf("unable to decode message: %s", err)
+			}
+			ids = append(ids, rec.EventID)
+			msg.Done()
+		case <-time.After(2 * time.Second):
+			t.Fatalf("timed out waiting for messages, got %v", ids)
+		}
+	}
+	return ids
+}
+
+func TestDynamoDBStreamSubscriber(t *testing.T) {
+	streams := &testDynamoDBStreamsAPI{shards: []*testStreamShard{
+		{id: "parent", records: testStreamRecords("parent", 3), closed: true},
+		{id: "child", parent: "parent", records: testStreamRecords("child", 2)},
+	}}
+	leases := &testLeaseAPI{items: map[string]map[string]*dynamodb.AttributeValue{}}
+	sub := newTestStreamSubscriber(streams, leases, "a")
+
+	got := readStream(t, sub.Start(), 5)
+	// the child is not read until its parent has ended
+	want := []string{"parent-1", "parent-2", "parent-3", "child-1", "child-2"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("expected records %v, got %v", want, got)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for leases.checkpoint("child") != "child-2" && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if err := sub.Stop(); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+	if err := sub.Err(); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+	if cp := leases.checkpoint("parent"); cp != shardEnd {
+		t.Errorf("expected parent checkpoint %q, got %q", shardEnd, cp)
+	}
+	if cp := leases.checkpoint("child"); cp != "child-2" {
+		t.Errorf("expected child checkpoint 'child-2', got %q", cp)
+	}
+
+	// another worker should pick up from the checkpoints right away
+	streams.mu.Lock()
+	child := streams.shard("child")
+	child.records = append(child.records, testStreamRecords("child", 3)[2])
+	streams.mu.Unlock()
+
+	sub = newTestStreamSubscriber(streams, leases, "b")
+	got = readStream(t, sub.Start(), 1)
+	sub.Stop()
+	if got[0] != "child-3" {
+		t.Errorf("expected to resume at 'child-3', got %v", got)
+	}
+}
+
+func TestDynamoDBStreamSubscriberLeased(t *testing.T) {
+	streams := &testDynamoDBStreamsAPI{shards: []*testStreamShard{
+		{id: "one", records: testStreamRecords("one", 1)},
+		{id: "two", records: testStreamRecords("two", 1)},
+	}}
+	leases := &testLeaseAPI{items: map[string]map[string]*dynamodb.AttributeValue{
+		"arn/one": {
+			"lease":   {S: aws.String("arn/one")},
+			"owner":   {S: aws.String("other")},
+			"expires": {N: aws.String(streamMillis(time.Now().Add(time.Hour)))},
+		},
+	}}
+	sub := newTestStreamSubscriber(streams, leases, "a")
+	msgs := sub.Start()
+	got := readStream(t, msgs, 1)
+	if got[0] != "two-1" {
+		t.Errorf("expected only the unleased shard to be read, got %v", got)
+	}
+	select {
+	case msg := <-msgs:
+		t.Errorf("expected no more messages, got %s", msg.Message())
+	case <-time.After(50 * time.Millisecond):
+	}
+	sub.Stop()
+}
+
+func TestDynamoDBStreamMessage(t *testing.T) {
+	msg := &DynamoDBStreamMessage{record: testStreamRecords("shard", 1)[0], done: func() {}}
+	var got DynamoDBStreamRecord
+	if err := json.Unmarshal(msg.Message(), &got); err != nil {
+		t.Fatalf("unable to decode message: %s", err)
+	}
+	if got.EventID != "shard-1" || got.EventName != "INSERT" || got.SequenceNumber != "shard-1" {
+		t.Errorf("expected record fields to be set, got %#v", got)
+	}
+	if got.Keys["id"] != "shard-1" || got.NewImage["count"] != float64(1) || got.OldImage != nil {
+		t.Errorf("expected images as plain values, got %#v", got)
+	}
+}
@@ -0,0 +1,107 @@
+package pubsub
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+)
+
+// The message attributes stamped by StandardAttributes and the
+// EnrichingPublisher.
+const (
+	// ServiceAttribute carries the name of the service that published a
+	// message.
+	ServiceAttribute = "service"
+	// ServiceVersionAttribute carries the version of the service that
+	// published a message.
+	ServiceVersionAttribute = "service_version"
+	// EnvironmentAttribute carries the environment, like 'prd' or 'stg', a
+	// message was published in.
+	EnvironmentAttribute = "environment"
+	// PublishTimeAttribute carries the RFC 3339 time a message was
+	// published at.
+	PublishTimeAttribute = "publish_time"
+	// SchemaVersionAttribute carries the schema version of messages that
+	// implement SchemaVersioner.
+	SchemaVersionAttribute = "schema_version"
+)
+
+// PublishHook is a func for stamping attributes onto an outgoing message.
+// It is given the message's key and body and may add to its attributes.
+type PublishHook func(key string, m []byte, attrs map[string]string)
+
+// StandardAttributes are the attributes that identify where a message came
+// from.
+type StandardAttributes struct {
+	Service     string
+	Version     string
+	Environment string
+}
+
+// Hook will return a PublishHook that stamps the non-empty attributes onto
+// each message along with its PublishTimeAttribute.
+func (a StandardAttributes) Hook() PublishHook {
+	return func(key string, m []byte, attrs map[string]string) {
+		if a.Service != "" {
+			attrs[ServiceAttribute] = a.Service
+		}
+		if a.Version != "" {
+			attrs[ServiceVersionAttribute] = a.Version
+		}
+		if a.Environment != "" {
+			attrs[EnvironmentAttribute] = a.Environment
+		}
+		attrs[PublishTimeAttribute] = time.Now().UTC().Format(time.RFC3339Nano)
+	}
+}
+
+// EnrichingPublisher is a Publisher that runs its PublishHooks on every
+// message so standard attributes are set once, when the publisher is
+// created, rather than at every publish. Proto messages that implement
+// SchemaVersioner are also stamped with their SchemaVersionAttribute.
+// Attributes given to PublishAttributes take precedence over those from the
+// hooks.
+type EnrichingPublisher struct {
+	pub   AttributePublisher
+	hooks []PublishHook
+}
+
+// NewEnrichingPublisher will return an EnrichingPublisher that runs the hooks,
+// in order, on each message before publishing it to pub.
+func NewEnrichingPublisher(pub AttributePublisher, hooks ...PublishHook) *EnrichingPublisher {
+	return &EnrichingPublisher{pub: pub, hooks: hooks}
+}
+
+// Publish will marshal the proto message and publish it enriched.
+func (p *EnrichingPublisher) Publish(key string, m proto.Message) error {
+	mb, err := proto.Marshal(m)
+	if err != nil {
+		return err
+	}
+	var attrs map[string]string
+	if v, ok := m.(SchemaVersioner); ok {
+		attrs = map[string]string{
+			SchemaVersionAttribute: strconv.FormatUint(uint64(v.SchemaVersion()), 10),
+		}
+	}
+	return p.PublishAttributes(key, mb, attrs)
+}
+
+// PublishRaw will publish the byte array enriched.
+func (p *EnrichingPublisher) PublishRaw(key string, m []byte) error {
+	return p.PublishAttributes(key, m, nil)
+}
+
+// PublishAttributes will publish the byte array enriched along with the
+// given attributes.
+func (p *EnrichingPublisher) PublishAttributes(key string, m []byte, attrs map[string]string) error {
+	out := make(map[string]string, len(attrs)+5)
+	for _, hook := range p.hooks {
+		hook(key, m, out)
+	}
+	for k, v := range attrs {
+		out[k] = v
+	}
+	return p.pub.PublishAttributes(key, m, out)
+}
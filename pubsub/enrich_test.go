@@ -0,0 +1,51 @@
+package pubsub
+
+import (
+	"testing"
+	"time"
+)
+
+// versionedMessage is a proto.Message with a schema version.
+type versionedMessage struct{}
+
+func (m *versionedMessage) Reset()                   {}
+func (m *versionedMessage) String() string           { return "versioned" }
+func (m *versionedMessage) ProtoMessage()            {}
+func (m *versionedMessage) Marshal() ([]byte, error) { return []byte("versioned"), nil }
+func (m *versionedMessage) SchemaVersion() uint32    { return 3 }
+
+func TestEnrichingPublisher(t *testing.T) {
+	tpub := &testAttributePublisher{}
+	pub := NewEnrichingPublisher(tpub, StandardAttributes{
+		Service:     "cats",
+		Version:     "1.2.3",
+		Environment: "stg",
+	}.Hook())
+
+	if err := pub.PublishAttributes("key", []byte("meow"), map[string]string{EnvironmentAttribute: "dev"}); err != nil {
+		t.Fatalf("expected no error, got %s", err)
+	}
+	for k, want := range map[string]string{
+		ServiceAttribute:        "cats",
+		ServiceVersionAttribute: "1.2.3",
+		// attributes given to the publish take precedence
+		EnvironmentAttribute: "dev",
+	} {
+		if got := tpub.attrs[k]; got != want {
+			t.Errorf("expected %s attribute %q, got %q", k, want, got)
+		}
+	}
+	if _, err := time.Parse(time.RFC3339Nano, tpub.attrs[PublishTimeAttribute]); err != nil {
+		t.Errorf("expected an RFC 3339 publish time, got %q", tpub.attrs[PublishTimeAttribute])
+	}
+
+	if err := pub.Publish("key", &versionedMessage{}); err != nil {
+		t.Fatalf("expected no error, got %s", err)
+	}
+	if got := tpub.attrs[SchemaVersionAttribute]; got != "3" {
+		t.Errorf("expected schema version 3, got %q", got)
+	}
+	if got := tpub.attrs[ServiceAttribute]; got != "cats" {
+		t.Errorf("expected service cats, got %q", got)
+	}
+}
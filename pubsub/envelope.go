@@ -0,0 +1,170 @@
+package pubsub
+
+import (
+	"errors"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"golang.org/x/net/context"
+)
+
+// TypeURLPrefix is prepended to message names to make the type URLs of
+// enveloped messages, matching the convention of google.protobuf.Any.
+const TypeURLPrefix = "type.googleapis.com/"
+
+// ErrUnknownType is returned by an EnvelopeRouter for messages with a type
+// that has no registered handler.
+var ErrUnknownType = errors.New("no handler registered for message type")
+
+// Envelope wraps a published message with its type and some metadata so
+// topics can carry messages of several types. Its wire format is described by
+// envelope.proto for consumers in other languages.
+type Envelope struct {
+	// TypeURL identifies the type of the payload.
+	TypeURL string `protobuf:"bytes,1,opt,name=type_url,json=typeUrl" json:"type_url,omitempty"`
+	// SchemaVersion is the version of the payload's schema.
+	SchemaVersion uint32 `protobuf:"varint,2,opt,name=schema_version,json=schemaVersion" json:"schema_version,omitempty"`
+	// Timestamp is when the message was published, in Unix nanoseconds.
+	Timestamp int64 `protobuf:"varint,3,opt,name=timestamp" json:"timestamp,omitempty"`
+	// ProducerID identifies the service that published the message.
+	ProducerID string `protobuf:"bytes,4,opt,name=producer_id,json=producerId" json:"producer_id,omitempty"`
+	// Payload is the serialized message.
+	Payload []byte `protobuf:"bytes,5,opt,name=payload" json:"payload,omitempty"`
+}
+
+// Reset will clear the envelope.
+func (m *Envelope) Reset() { *m = Envelope{} }
+
+// String will return the envelope in the compact text format.
+func (m *Envelope) String() string { return proto.CompactTextString(m) }
+
+// ProtoMessage marks the Envelope as a protobuf message.
+func (*Envelope) ProtoMessage() {}
+
+// Time will return when the message was published.
+func (m *Envelope) Time() time.Time {
+	return time.Unix(0, m.Timestamp)
+}
+
+func init() {
+	proto.RegisterType((*Envelope)(nil), "gizmo.pubsub.Envelope")
+}
+
+// SchemaVersioner can be implemented by messages to set the SchemaVersion
+// of their envelopes.
+type SchemaVersioner interface {
+	SchemaVersion() uint32
+}
+
+// TypeURL will return the type URL of the given message, based on its
+// registered protobuf name or, if it is not registered, its Go type.
+func TypeURL(m proto.Message) string {
+	name := proto.MessageName(m)
+	if name == "" {
+		name = reflect.TypeOf(m).Elem().String()
+	}
+	return TypeURLPrefix + name
+}
+
+// EnvelopePublisher is a Publisher that wraps every message in an Envelope
+// before publishing it to the underlying Publisher.
+type EnvelopePublisher struct {
+	pub        Publisher
+	producerID string
+}
+
+// NewEnvelopePublisher will return an EnvelopePublisher that publishes to pub
+// with the given producer ID, like the service's name.
+func NewEnvelopePublisher(pub Publisher, producerID string) *EnvelopePublisher {
+	return &EnvelopePublisher{pub: pub, producerID: producerID}
+}
+
+// Publish will wrap the message in an Envelope with its type URL and, if it
+// implements SchemaVersioner, its schema version.
+func (p *EnvelopePublisher) Publish(key string, m proto.Message) error {
+	payload, err := proto.Marshal(m)
+	if err != nil {
+		return err
+	}
+	env := &Envelope{TypeURL: TypeURL(m), Payload: payload}
+	if v, ok := m.(SchemaVersioner); ok {
+		env.SchemaVersion = v.SchemaVersion()
+	}
+	return p.PublishEnvelope(key, env)
+}
+
+// PublishRaw will wrap the bytes in an Envelope without a type URL.
+func (p *EnvelopePublisher) PublishRaw(key string, m []byte) error {
+	return p.PublishEnvelope(key, &Envelope{Payload: m})
+}
+
+// PublishEnvelope will publish the given Envelope, filling in its timestamp
+// and producer ID if they are not set.
+func (p *EnvelopePublisher) PublishEnvelope(key string, env *Envelope) error {
+	if env.Timestamp == 0 {
+		env.Timestamp = time.Now().UnixNano()
+	}
+	if env.ProducerID == "" {
+		env.ProducerID = p.producerID
+	}
+	return p.pub.Publish(key, env)
+}
+
+// EnvelopeHandler is a func for processing an enveloped message that has
+// been unmarshaled into the type it was registered with.
+type EnvelopeHandler func(ctx context.Context, env *Envelope, m proto.Message) error
+
+// EnvelopeRouter is a MessageHandler registry that unwraps enveloped messages
+// and dispatches them to a handler by their type URL.
+type EnvelopeRouter struct {
+	// SkipUnknown will mark messages with an unregistered type as done
+	// rather than returning ErrUnknownType.
+	SkipUnknown bool
+
+	mu       sync.RWMutex
+	handlers map[string]envelopeRoute
+}
+
+type envelopeRoute struct {
+	typ     reflect.Type
+	handler EnvelopeHandler
+}
+
+// NewEnvelopeRouter will return an empty EnvelopeRouter.
+func NewEnvelopeRouter() *EnvelopeRouter {
+	return &EnvelopeRouter{handlers: map[string]envelopeRoute{}}
+}
+
+// Handle will register h to handle messages with the type of m, which is
+// only used to look up its type URL and Go type.
+func (r *EnvelopeRouter) Handle(m proto.Message, h EnvelopeHandler) {
+	r.mu.Lock()
+	r.handlers[TypeURL(m)] = envelopeRoute{typ: reflect.TypeOf(m).Elem(), handler: h}
+	r.mu.Unlock()
+}
+
+// HandleMessage is a MessageHandler that will unwrap the message's Envelope
+// and pass its payload to the handler registered for its type.
+func (r *EnvelopeRouter) HandleMessage(ctx context.Context, msg SubscriberMessage) error {
+	var env Envelope
+	if err := proto.Unmarshal(msg.Message(), &env); err != nil {
+		return err
+	}
+	r.mu.RLock()
+	route, ok := r.handlers[env.TypeURL]
+	r.mu.RUnlock()
+	if !ok {
+		if r.SkipUnknown {
+			return nil
+		}
+		Log.Warn("no handler registered for message type: ", env.TypeURL)
+		return ErrUnknownType
+	}
+	m := reflect.New(route.typ).Interface().(proto.Message)
+	if err := proto.Unmarshal(env.Payload, m); err != nil {
+		return err
+	}
+	return route.handler(ctx, &env, m)
+}
@@ -0,0 +1,74 @@
+package pubsub
+
+import (
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"golang.org/x/net/context"
+)
+
+// testEnvelopePublisher will record the last message published.
+type testEnvelopePublisher struct {
+	key  string
+	body []byte
+}
+
+func (p *testEnvelopePublisher) Publish(key string, m proto.Message) error {
+	body, err := proto.Marshal(m)
+	if err != nil {
+		return err
+	}
+	return p.PublishRaw(key, body)
+}
+
+func (p *testEnvelopePublisher) PublishRaw(key string, m []byte) error {
+	p.key, p.body = key, m
+	return nil
+}
+
+func TestEnvelope(t *testing.T) {
+	pub := &testEnvelopePublisher{}
+	ep := NewEnvelopePublisher(pub, "cats-api")
+	if err := ep.Publish("tabby", &TestProto{Value: "meow"}); err != nil {
+		t.Fatal("unexpected error publishing: ", err)
+	}
+
+	var env Envelope
+	if err := proto.Unmarshal(pub.body, &env); err != nil {
+		t.Fatal("unable to unmarshal envelope: ", err)
+	}
+	if env.TypeURL != TypeURLPrefix+"pubsub.TestProto" {
+		t.Errorf("expected the type URL of TestProto, got %q", env.TypeURL)
+	}
+	if env.ProducerID != "cats-api" {
+		t.Errorf("expected producer 'cats-api', got %q", env.ProducerID)
+	}
+	if time.Since(env.Time()) > time.Minute {
+		t.Errorf("expected a recent timestamp, got %s", env.Time())
+	}
+
+	var got *TestProto
+	router := NewEnvelopeRouter()
+	router.Handle(&TestProto{}, func(ctx context.Context, env *Envelope, m proto.Message) error {
+		got = m.(*TestProto)
+		return nil
+	})
+	msg := &testConsumerMessage{msg: pub.body}
+	if err := router.HandleMessage(context.Background(), msg); err != nil {
+		t.Fatal("unexpected error handling: ", err)
+	}
+	if got == nil || got.Value != "meow" {
+		t.Errorf("expected the TestProto to be dispatched, got %v", got)
+	}
+
+	ep.PublishRaw("tabby", []byte("untyped"))
+	msg = &testConsumerMessage{msg: pub.body}
+	if err := router.HandleMessage(context.Background(), msg); err != ErrUnknownType {
+		t.Errorf("expected ErrUnknownType, got %v", err)
+	}
+	router.SkipUnknown = true
+	if err := router.HandleMessage(context.Background(), msg); err != nil {
+		t.Errorf("expected unknown types to be skipped, got %v", err)
+	}
+}
@@ -0,0 +1,105 @@
+package pubsub
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+const (
+	// ControlAttribute is the message attribute that marks control messages
+	// so consumers can tell them apart from regular messages.
+	ControlAttribute = "control"
+	// EraseSubjectControl is the ControlAttribute value of erasure requests.
+	EraseSubjectControl = "erase_subject"
+)
+
+// ErasureRequest is a control message asking every consumer of a topic to
+// delete the data it holds about a data subject, such as for a GDPR right to
+// erasure request.
+type ErasureRequest struct {
+	// SubjectID identifies the data subject, like a user ID.
+	SubjectID string `json:"erase_subject"`
+	// Reason is an optional note on why the data is being erased.
+	Reason string `json:"reason,omitempty"`
+	// RequestedAt is when the erasure was requested.
+	RequestedAt time.Time `json:"requested_at"`
+}
+
+// ErasureFunc is an application callback that deletes the data held about
+// the subject of an ErasureRequest.
+type ErasureFunc func(context.Context, *ErasureRequest) error
+
+// PublishErasure will publish an ErasureRequest for the subject to the
+// topic, keyed by the subject ID. Since consumers may not see message
+// attributes, requests are recognized by their JSON body alone, but are also
+// marked with the ControlAttribute if pub is an AttributePublisher.
+func PublishErasure(pub Publisher, subjectID, reason string) error {
+	if subjectID == "" {
+		return errors.New("erasure subject ID is required")
+	}
+	b, err := json.Marshal(&ErasureRequest{
+		SubjectID:   subjectID,
+		Reason:      reason,
+		RequestedAt: time.Now().UTC(),
+	})
+	if err != nil {
+		return err
+	}
+	if apub, ok := pub.(AttributePublisher); ok {
+		return apub.PublishAttributes(subjectID, b, map[string]string{ControlAttribute: EraseSubjectControl})
+	}
+	return pub.PublishRaw(subjectID, b)
+}
+
+// DecodeErasure will return the ErasureRequest carried by msg, if it is one.
+func DecodeErasure(msg SubscriberMessage) (*ErasureRequest, bool) {
+	if c, ok := attribute(msg, ControlAttribute); ok && c != EraseSubjectControl {
+		return nil, false
+	}
+	body := msg.Message()
+	if !bytes.Contains(body, []byte(`"erase_subject"`)) {
+		return nil, false
+	}
+	var req ErasureRequest
+	if err := json.Unmarshal(body, &req); err != nil || req.SubjectID == "" {
+		return nil, false
+	}
+	return &req, true
+}
+
+// ErasureHandler will return a MessageHandler that intercepts erasure
+// requests and passes them to erase, handing every other message to h. If
+// erase fails, the request is left to be redelivered like any other failed
+// message.
+func ErasureHandler(erase ErasureFunc, h MessageHandler) MessageHandler {
+	return func(ctx context.Context, msg SubscriberMessage) error {
+		req, ok := DecodeErasure(msg)
+		if !ok {
+			return h(ctx, msg)
+		}
+		Log.WithField("subject", req.SubjectID).Info("erasing subject")
+		return erase(ctx, req)
+	}
+}
+
+// SubjectMatcher will return a func for Scrub that matches JSON records
+// with the subject ID at the given path, like '$.user.id'. Paths are handled
+// as in BodyPathEquals.
+func SubjectMatcher(path, subjectID string) func([]byte) bool {
+	filter := BodyPathEquals(path, subjectID)
+	return func(record []byte) bool {
+		return filter(&rawMessage{body: record})
+	}
+}
+
+// rawMessage is a SubscriberMessage for applying filters to raw bytes.
+type rawMessage struct {
+	body []byte
+}
+
+func (m *rawMessage) Message() []byte { return m.body }
+func (m *rawMessage) Done() error     { return nil }
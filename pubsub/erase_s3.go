@@ -0,0 +1,174 @@
+// +build !noaws
+
+package pubsub
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"io/ioutil"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+
+	"github.com/NYTimes/gizmo/config"
+)
+
+// ArchiveScrubber will remove the records of a data subject from message
+// archives kept in S3. Archives are expected to hold a record per line, and
+// objects with keys ending in '.gz' are read and rewritten with gzip.
+type ArchiveScrubber struct {
+	s3     s3iface.S3API
+	bucket string
+	// Prefix limits scrubbing to the objects with keys starting with it.
+	Prefix string
+}
+
+// ScrubStats are the results of a scrub.
+type ScrubStats struct {
+	// Objects is the number of objects read.
+	Objects int
+	// Rewritten is the number of objects that had records removed.
+	Rewritten int
+	// Records is the number of records removed.
+	Records int
+}
+
+// NewArchiveScrubber will initiate an S3 client for the bucket in the given
+// config. If no credentials are passed in with the config, the client will
+// use the AWS_ACCESS_KEY and the AWS_SECRET_KEY environment variables.
+func NewArchiveScrubber(cfg *config.S3, prefix string) (*ArchiveScrubber, error) {
+	if cfg.Bucket == "" {
+		return nil, errors.New("S3 bucket is required")
+	}
+	if cfg.Region == "" {
+		return nil, errors.New("S3 region is required")
+	}
+
+	var creds *credentials.Credentials
+	if cfg.AccessKey != "" {
+		creds = credentials.NewStaticCredentials(cfg.AccessKey, cfg.SecretKey, "")
+	} else {
+		creds = credentials.NewEnvCredentials()
+	}
+	awsCfg := &aws.Config{
+		Credentials: creds,
+		Region:      &cfg.Region,
+	}
+	if cfg.Endpoint != "" {
+		awsCfg.Endpoint = &cfg.Endpoint
+		awsCfg.S3ForcePathStyle = aws.Bool(true)
+	}
+	return &ArchiveScrubber{
+		s3:     s3.New(session.New(awsEndpoints(cfg.AWS, awsCfg))),
+		bucket: cfg.Bucket,
+		Prefix: prefix,
+	}, nil
+}
+
+// Scrub will rewrite every object under the Prefix without the records
+// that match. Objects without matching records are left untouched.
+func (s *ArchiveScrubber) Scrub(match func(record []byte) bool) (ScrubStats, error) {
+	var (
+		stats ScrubStats
+		keys  []string
+	)
+	err := s.s3.ListObjectsPages(&s3.ListObjectsInput{
+		Bucket: &s.bucket,
+		Prefix: &s.Prefix,
+	}, func(page *s3.ListObjectsOutput, last bool) bool {
+		for _, obj := range page.Contents {
+			if !strings.HasSuffix(*obj.Key, "/") {
+				keys = append(keys, *obj.Key)
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return stats, err
+	}
+	for _, key := range keys {
+		removed, err := s.scrub(key, match)
+		if err != nil {
+			return stats, err
+		}
+		stats.Objects++
+		if removed > 0 {
+			stats.Rewritten++
+			stats.Records += removed
+		}
+	}
+	return stats, nil
+}
+
+// scrub will rewrite the object without matching records and return the
+// number removed.
+func (s *ArchiveScrubber) scrub(key string, match func([]byte) bool) (int, error) {
+	out, err := s.s3.GetObject(&s3.GetObjectInput{
+		Bucket: &s.bucket,
+		Key:    &key,
+	})
+	if err != nil {
+		return 0, err
+	}
+	defer out.Body.Close()
+
+	gzipped := strings.HasSuffix(key, ".gz")
+	var r io.Reader = out.Body
+	if gzipped {
+		gr, err := gzip.NewReader(out.Body)
+		if err != nil {
+			return 0, err
+		}
+		defer gr.Close()
+		r = gr
+	}
+	body, err := ioutil.ReadAll(r)
+	if err != nil {
+		return 0, err
+	}
+
+	var (
+		kept    bytes.Buffer
+		removed int
+	)
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	scanner.Buffer(make([]byte, 64*1024), len(body)+1)
+	for scanner.Scan() {
+		if match(scanner.Bytes()) {
+			removed++
+			continue
+		}
+		kept.Write(scanner.Bytes())
+		kept.WriteByte('\n')
+	}
+	if err = scanner.Err(); err != nil || removed == 0 {
+		return 0, err
+	}
+
+	result := kept.Bytes()
+	if gzipped {
+		var buf bytes.Buffer
+		gw := gzip.NewWriter(&buf)
+		if _, err = gw.Write(result); err != nil {
+			return 0, err
+		}
+		if err = gw.Close(); err != nil {
+			return 0, err
+		}
+		result = buf.Bytes()
+	}
+	_, err = s.s3.PutObject(&s3.PutObjectInput{
+		Bucket:      &s.bucket,
+		Key:         &key,
+		Body:        bytes.NewReader(result),
+		ContentType: out.ContentType,
+	})
+	return removed, err
+}
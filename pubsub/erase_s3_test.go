@@ -0,0 +1,75 @@
+// +build !noaws
+
+package pubsub
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+)
+
+type testScrubS3 struct {
+	s3iface.S3API
+	objects map[string][]byte
+}
+
+func (s *testScrubS3) ListObjectsPages(in *s3.ListObjectsInput, fn func(*s3.ListObjectsOutput, bool) bool) error {
+	out := &s3.ListObjectsOutput{}
+	for key := range s.objects {
+		out.Contents = append(out.Contents, &s3.Object{Key: aws.String(key)})
+	}
+	fn(out, true)
+	return nil
+}
+
+func (s *testScrubS3) GetObject(in *s3.GetObjectInput) (*s3.GetObjectOutput, error) {
+	return &s3.GetObjectOutput{Body: ioutil.NopCloser(bytes.NewReader(s.objects[*in.Key]))}, nil
+}
+
+func (s *testScrubS3) PutObject(in *s3.PutObjectInput) (*s3.PutObjectOutput, error) {
+	b, err := ioutil.ReadAll(in.Body)
+	s.objects[*in.Key] = b
+	return &s3.PutObjectOutput{}, err
+}
+
+func TestArchiveScrubber(t *testing.T) {
+	var gz bytes.Buffer
+	gw := gzip.NewWriter(&gz)
+	gw.Write([]byte("{\"user\":{\"id\":\"user-1\"}}\n{\"user\":{\"id\":\"user-2\"}}\n"))
+	gw.Close()
+
+	api := &testScrubS3{objects: map[string][]byte{
+		"archive/1.json":    []byte("{\"user\":{\"id\":\"user-1\"},\"n\":1}\n{\"user\":{\"id\":\"user-2\"}}\n{\"user\":{\"id\":\"user-1\"},\"n\":2}\n"),
+		"archive/2.json":    []byte("{\"user\":{\"id\":\"user-3\"}}\n"),
+		"archive/3.json.gz": gz.Bytes(),
+	}}
+	scrubber := &ArchiveScrubber{s3: api, bucket: "archive", Prefix: "archive/"}
+
+	stats, err := scrubber.Scrub(SubjectMatcher("$.user.id", "user-1"))
+	if err != nil {
+		t.Fatalf("expected no error, got %s", err)
+	}
+	if want := (ScrubStats{Objects: 3, Rewritten: 2, Records: 3}); stats != want {
+		t.Errorf("expected stats %+v, got %+v", want, stats)
+	}
+
+	if got, want := string(api.objects["archive/1.json"]), "{\"user\":{\"id\":\"user-2\"}}\n"; got != want {
+		t.Errorf("expected scrubbed archive %q, got %q", want, got)
+	}
+	if got, want := string(api.objects["archive/2.json"]), "{\"user\":{\"id\":\"user-3\"}}\n"; got != want {
+		t.Errorf("expected untouched archive %q, got %q", want, got)
+	}
+	gr, err := gzip.NewReader(bytes.NewReader(api.objects["archive/3.json.gz"]))
+	if err != nil {
+		t.Fatalf("expected a gzipped archive, got %s", err)
+	}
+	b, _ := ioutil.ReadAll(gr)
+	if got, want := string(b), "{\"user\":{\"id\":\"user-2\"}}\n"; got != want {
+		t.Errorf("expected scrubbed gzipped archive %q, got %q", want, got)
+	}
+}
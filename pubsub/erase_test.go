@@ -0,0 +1,45 @@
+package pubsub
+
+import (
+	"testing"
+
+	"golang.org/x/net/context"
+)
+
+func TestErasureHandler(t *testing.T) {
+	pub := &testAttributePublisher{}
+	if err := PublishErasure(pub, "user-1", "account closed"); err != nil {
+		t.Fatalf("expected no error, got %s", err)
+	}
+	if got := pub.attrs[ControlAttribute]; got != EraseSubjectControl {
+		t.Errorf("expected control attribute %q, got %q", EraseSubjectControl, got)
+	}
+
+	var (
+		erased  []string
+		handled []string
+	)
+	h := ErasureHandler(func(ctx context.Context, req *ErasureRequest) error {
+		erased = append(erased, req.SubjectID)
+		return nil
+	}, func(ctx context.Context, msg SubscriberMessage) error {
+		handled = append(handled, string(msg.Message()))
+		return nil
+	})
+
+	msgs := []SubscriberMessage{
+		&testAttributeMessage{testConsumerMessage{msg: pub.body}, pub.attrs},
+		&testConsumerMessage{msg: pub.body},
+		&testConsumerMessage{msg: []byte(`{"name":"jp"}`)},
+		&testAttributeMessage{testConsumerMessage{msg: pub.body}, map[string]string{ControlAttribute: "other"}},
+	}
+	for _, msg := range msgs {
+		h(context.Background(), msg)
+	}
+	if len(erased) != 2 || erased[0] != "user-1" || erased[1] != "user-1" {
+		t.Errorf("expected 2 erasures of 'user-1', got %v", erased)
+	}
+	if len(handled) != 2 {
+		t.Errorf("expected 2 messages handled, got %v", handled)
+	}
+}
@@ -0,0 +1,87 @@
+package pubsub
+
+import (
+	"errors"
+)
+
+// The classes of failure that errors from the pubsub backends are sorted
+// into. Use ErrorClass to find the class of an error.
+var (
+	// ErrThrottled is the class of errors from requests that were rejected
+	// for exceeding a rate limit or provisioned throughput.
+	ErrThrottled = errors.New("pubsub: request throttled")
+	// ErrMessageTooLarge is the class of errors from messages that are over
+	// the size limit of the backend.
+	ErrMessageTooLarge = errors.New("pubsub: message too large")
+	// ErrQueueNotFound is the class of errors from queues, topics or
+	// partitions that do not exist.
+	ErrQueueNotFound = errors.New("pubsub: queue not found")
+	// ErrAccessDenied is the class of errors from requests the credentials
+	// are not allowed to make.
+	ErrAccessDenied = errors.New("pubsub: access denied")
+)
+
+// Error is a backend-specific error along with its class of failure.
+type Error struct {
+	// Class is one of ErrThrottled, ErrMessageTooLarge, ErrQueueNotFound
+	// or ErrAccessDenied.
+	Class error
+	// Err is the original error from the backend.
+	Err error
+}
+
+func (e *Error) Error() string {
+	return e.Class.Error() + ": " + e.Err.Error()
+}
+
+// ErrorClass will return the class of failure of an error returned by a
+// Publisher or Subscriber, like ErrThrottled, or nil if it has none. This
+// allows applications to branch on the kind of failure without matching on
+// backend error codes:
+//
+//	if pubsub.ErrorClass(err) == pubsub.ErrThrottled {
+//	    // back off and retry
+//	}
+func ErrorClass(err error) error {
+	switch e := err.(type) {
+	case *Error:
+		return e.Class
+	case *VerifyError:
+		return ErrorClass(e.Err)
+	case nil:
+		return nil
+	}
+	switch err {
+	case ErrThrottled, ErrMessageTooLarge, ErrQueueNotFound, ErrAccessDenied:
+		return err
+	}
+	return nil
+}
+
+// classify will wrap any backend error that has a known class of failure
+// in an Error. Others are returned as they are.
+func classify(err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := err.(*Error); ok {
+		return err
+	}
+	if class := classOf(err); class != nil {
+		return &Error{Class: class, Err: err}
+	}
+	return err
+}
+
+// errorClassifiers return the class of errors from each backend, or nil if
+// the error is not theirs or has no known class.
+var errorClassifiers []func(error) error
+
+func classOf(err error) error {
+	for _, class := range errorClassifiers {
+		if c := class(err); c != nil {
+			return c
+		}
+	}
+	return nil
+}
@@ -0,0 +1,42 @@
+// +build !noaws
+
+package pubsub
+
+import (
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+)
+
+func init() {
+	errorClassifiers = append(errorClassifiers, func(err error) error {
+		if e, ok := err.(awserr.Error); ok {
+			return awsErrorClass(e)
+		}
+		return nil
+	})
+}
+
+func awsErrorClass(err awserr.Error) error {
+	switch err.Code() {
+	case "Throttling", "ThrottlingException", "ThrottledException", "RequestThrottled",
+		"TooManyRequestsException", "RequestLimitExceeded", "OverLimit",
+		"ProvisionedThroughputExceededException", "KMSThrottlingException":
+		return ErrThrottled
+	case "RequestEntityTooLarge", "MessageTooLong":
+		return ErrMessageTooLarge
+	case "AWS.SimpleQueueService.NonExistentQueue", "QueueDoesNotExist",
+		"NotFound", "NotFoundException", "ResourceNotFoundException":
+		return ErrQueueNotFound
+	case "AccessDenied", "AccessDeniedException", "AuthorizationError",
+		"InvalidClientTokenId", "UnrecognizedClientException", "SignatureDoesNotMatch":
+		return ErrAccessDenied
+	case "InvalidParameterValue", "InvalidParameter":
+		// SNS and SQS report oversized messages as invalid parameters
+		msg := strings.ToLower(err.Message())
+		if strings.Contains(msg, "too long") || strings.Contains(msg, "must be shorter") {
+			return ErrMessageTooLarge
+		}
+	}
+	return nil
+}
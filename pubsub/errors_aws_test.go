@@ -0,0 +1,30 @@
+// +build !noaws
+
+package pubsub
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+)
+
+func TestAWSErrorClass(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+
+		want error
+	}{
+		{"sqs throttling", awserr.New("RequestThrottled", "slow down", nil), ErrThrottled},
+		{"sns throttling", awserr.New("Throttling", "rate exceeded", nil), ErrThrottled},
+		{"sqs missing queue", awserr.New("AWS.SimpleQueueService.NonExistentQueue", "no queue", nil), ErrQueueNotFound},
+		{"sns access", awserr.New("AuthorizationError", "not allowed", nil), ErrAccessDenied},
+		{"sqs too large", awserr.New("InvalidParameterValue", "One or more parameters are invalid. Reason: Message must be shorter than 262144 bytes.", nil), ErrMessageTooLarge},
+		{"sns too large", awserr.New("InvalidParameter", "Invalid parameter: Message too long", nil), ErrMessageTooLarge},
+		{"other invalid", awserr.New("InvalidParameter", "Invalid parameter: TopicArn", nil), nil},
+	}
+
+	for _, test := range tests {
+		checkErrorClass(t, test.name, test.err, test.want)
+	}
+}
@@ -0,0 +1,31 @@
+// +build !nokafka
+
+package pubsub
+
+import "github.com/Shopify/sarama"
+
+func init() {
+	errorClassifiers = append(errorClassifiers, kafkaClassOf)
+}
+
+func kafkaClassOf(err error) error {
+	switch e := err.(type) {
+	case sarama.KError:
+		return kafkaErrorClass(e)
+	case *sarama.ProducerError:
+		return kafkaClassOf(e.Err)
+	}
+	return nil
+}
+
+func kafkaErrorClass(err sarama.KError) error {
+	switch err {
+	case sarama.ErrMessageSizeTooLarge:
+		return ErrMessageTooLarge
+	case sarama.ErrUnknownTopicOrPartition:
+		return ErrQueueNotFound
+	case sarama.ErrTopicAuthorizationFailed, sarama.ErrClusterAuthorizationFailed:
+		return ErrAccessDenied
+	}
+	return nil
+}
@@ -0,0 +1,25 @@
+// +build !nokafka
+
+package pubsub
+
+import (
+	"testing"
+
+	"github.com/Shopify/sarama"
+)
+
+func TestKafkaErrorClass(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+
+		want error
+	}{
+		{"kafka too large", sarama.ErrMessageSizeTooLarge, ErrMessageTooLarge},
+		{"kafka missing topic", &sarama.ProducerError{Err: sarama.ErrUnknownTopicOrPartition}, ErrQueueNotFound},
+	}
+
+	for _, test := range tests {
+		checkErrorClass(t, test.name, test.err, test.want)
+	}
+}
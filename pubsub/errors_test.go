@@ -0,0 +1,37 @@
+package pubsub
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestErrorClass(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+
+		want error
+	}{
+		{"nil", nil, nil},
+		{"unknown", errors.New("oops"), nil},
+		{"sentinel", ErrThrottled, ErrThrottled},
+	}
+
+	for _, test := range tests {
+		checkErrorClass(t, test.name, test.err, test.want)
+	}
+}
+
+// checkErrorClass will verify that classify sorts err into the want class.
+func checkErrorClass(t *testing.T, name string, err, want error) {
+	cerr := classify(err)
+	if got := ErrorClass(cerr); got != want {
+		t.Errorf("%s: expected class %v, got %v", name, want, got)
+	}
+	if want == nil && cerr != err {
+		t.Errorf("%s: expected unclassified error to be returned as is, got %v", name, cerr)
+	}
+	if e, ok := cerr.(*Error); ok && e.Err != err {
+		t.Errorf("%s: expected the original error to be kept, got %v", name, e.Err)
+	}
+}
@@ -0,0 +1,413 @@
+// +build !noaws
+
+package pubsub
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/eventbridge"
+	"github.com/aws/aws-sdk-go/service/eventbridge/eventbridgeiface"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/proto"
+
+	"github.com/NYTimes/gizmo/config"
+)
+
+type (
+	// EventBridgePublisher will emit any publish events to an Amazon
+	// EventBridge event bus. Since EventBridge events carry JSON details,
+	// protobuf messages are published in their JSON form.
+	EventBridgePublisher struct {
+		eb  eventbridgeiface.EventBridgeAPI
+		cfg *config.EventBridge
+	}
+
+	// EventBridgeSubscriber is a Subscriber that consumes the events an
+	// EventBridge rule delivers to an SQS queue. The rule can be created or
+	// verified when the subscriber is set up.
+	EventBridgeSubscriber struct {
+		sqs *SQSSubscriber
+	}
+
+	// EventBridgeMessage is the EventBridge implementation of
+	// `SubscriberMessage`. Its body is the event's detail.
+	EventBridgeMessage struct {
+		*SQSMessage
+		event *EventBridgeEvent
+	}
+
+	// EventBridgeEvent is an event as delivered by EventBridge.
+	EventBridgeEvent struct {
+		ID         string          `json:"id"`
+		DetailType string          `json:"detail-type"`
+		Source     string          `json:"source"`
+		Account    string          `json:"account"`
+		Time       time.Time       `json:"time"`
+		Region     string          `json:"region"`
+		Resources  []string        `json:"resources"`
+		Detail     json.RawMessage `json:"detail"`
+	}
+)
+
+func eventBridgeSession(cfg *config.EventBridge) *session.Session {
+	var creds *credentials.Credentials
+	if cfg.AccessKey != "" {
+		creds = credentials.NewStaticCredentials(cfg.AccessKey, cfg.SecretKey, "")
+	} else {
+		creds = credentials.NewEnvCredentials()
+	}
+	return session.New(awsEndpoints(cfg.AWS, &aws.Config{
+		Credentials: creds,
+		Region:      &cfg.Region,
+	}))
+}
+
+// NewEventBridgePublisher will initiate the EventBridge client.
+// If no credentials are passed in with the config,
+// the publisher is instantiated with the AWS_ACCESS_KEY
+// and the AWS_SECRET_KEY environment variables.
+func NewEventBridgePublisher(cfg *config.EventBridge) (*EventBridgePublisher, error) {
+	if cfg.Source == "" {
+		return nil, errors.New("eventbridge source is required")
+	}
+	if cfg.Region == "" {
+		return nil, errors.New("eventbridge region is required")
+	}
+	return &EventBridgePublisher{
+		eb:  eventbridge.New(eventBridgeSession(cfg)),
+		cfg: cfg,
+	}, nil
+}
+
+// Publish will marshal the proto message to JSON and emit it as the detail
+// of an event.
+func (p *EventBridgePublisher) Publish(key string, m proto.Message) error {
+	var buf bytes.Buffer
+	if err := (&jsonpb.Marshaler{OrigName: true}).Marshal(&buf, m); err != nil {
+		return err
+	}
+	return p.PublishRaw(key, buf.Bytes())
+}
+
+// PublishRaw will emit the byte array, which must be a JSON object, as the
+// detail of an event. The key will be used as the detail-type unless the
+// config has a DetailType.
+func (p *EventBridgePublisher) PublishRaw(key string, m []byte) error {
+	_, err := p.PublishRawResult(key, m)
+	return err
+}
+
+// PublishRawResult will emit the byte array as the detail of an event like
+// PublishRaw and return the event's ID.
+func (p *EventBridgePublisher) PublishRawResult(key string, m []byte) (*PublishResult, error) {
+	detailType := p.cfg.DetailType
+	if detailType == "" {
+		detailType = key
+	}
+	entry := &eventbridge.PutEventsRequestEntry{
+		Source:     &p.cfg.Source,
+		DetailType: &detailType,
+		Detail:     aws.String(string(m)),
+	}
+	if p.cfg.EventBus != "" {
+		entry.EventBusName = &p.cfg.EventBus
+	}
+	out, err := p.eb.PutEvents(&eventbridge.PutEventsInput{
+		Entries: []*eventbridge.PutEventsRequestEntry{entry},
+	})
+	if err != nil {
+		return nil, classify(err)
+	}
+	if aws.Int64Value(out.FailedEntryCount) > 0 && len(out.Entries) > 0 {
+		return nil, fmt.Errorf("unable to put event: %s: %s",
+			aws.StringValue(out.Entries[0].ErrorCode), aws.StringValue(out.Entries[0].ErrorMessage))
+	}
+	res := &PublishResult{Timestamp: time.Now()}
+	if len(out.Entries) > 0 {
+		res.MessageID = aws.StringValue(out.Entries[0].EventId)
+	}
+	return res, nil
+}
+
+// PublishRawBatch will emit the byte arrays as the details of events in a
+// single request, which EventBridge limits to 10 events, and return the
+// result or error of each.
+func (p *EventBridgePublisher) PublishRawBatch(keys []string, msgs [][]byte) ([]*PublishResult, []error) {
+	entries := make([]*eventbridge.PutEventsRequestEntry, len(msgs))
+	for i, m := range msgs {
+		detailType := p.cfg.DetailType
+		if detailType == "" {
+			detailType = keys[i]
+		}
+		entries[i] = &eventbridge.PutEventsRequestEntry{
+			Source:     &p.cfg.Source,
+			DetailType: aws.String(detailType),
+			Detail:     aws.String(string(m)),
+		}
+		if p.cfg.EventBus != "" {
+			entries[i].EventBusName = &p.cfg.EventBus
+		}
+	}
+	results := make([]*PublishResult, len(msgs))
+	errs := make([]error, len(msgs))
+	out, err := p.eb.PutEvents(&eventbridge.PutEventsInput{Entries: entries})
+	if err != nil {
+		for i := range errs {
+			errs[i] = classify(err)
+		}
+		return results, errs
+	}
+	now := time.Now()
+	for i := range msgs {
+		if i >= len(out.Entries) {
+			results[i] = &PublishResult{Timestamp: now}
+			continue
+		}
+		e := out.Entries[i]
+		if e.ErrorCode != nil {
+			errs[i] = fmt.Errorf("unable to put event: %s: %s",
+				aws.StringValue(e.ErrorCode), aws.StringValue(e.ErrorMessage))
+			continue
+		}
+		results[i] = &PublishResult{MessageID: aws.StringValue(e.EventId), Timestamp: now}
+	}
+	return results, errs
+}
+
+// NewEventBridgeSubscriber will set up an SQSSubscriber for the config's
+// QueueName and make sure the RuleName rule delivers events to it. With
+// CreateRule set, the rule is created or updated with the EventPattern,
+// the queue is added as its target and the queue's policy is replaced with
+// one that allows the rule to send to it. Otherwise, an error is returned if
+// the rule does not already target the queue.
+func NewEventBridgeSubscriber(cfg *config.EventBridge) (*EventBridgeSubscriber, error) {
+	if cfg.RuleName == "" {
+		return nil, errors.New("eventbridge rule name is required")
+	}
+	if cfg.QueueName == "" {
+		return nil, errors.New("eventbridge queue name is required")
+	}
+	if cfg.CreateRule && cfg.EventPattern == "" {
+		return nil, errors.New("eventbridge event pattern is required to create a rule")
+	}
+
+	consumeBase64 := false
+	sub, err := NewSQSSubscriber(&config.SQS{
+		AWS:           cfg.AWS,
+		QueueName:     cfg.QueueName,
+		ConsumeBase64: &consumeBase64,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err = ensureEventBridgeRule(eventbridge.New(eventBridgeSession(cfg)), sub, cfg); err != nil {
+		return nil, err
+	}
+	return &EventBridgeSubscriber{sqs: sub}, nil
+}
+
+func ensureEventBridgeRule(eb eventbridgeiface.EventBridgeAPI, sub *SQSSubscriber, cfg *config.EventBridge) error {
+	attrs, err := sub.sqs.GetQueueAttributes(&sqs.GetQueueAttributesInput{
+		QueueUrl: sub.queueURL,
+		AttributeNames: []*string{
+			aws.String(sqs.QueueAttributeNameQueueArn),
+			aws.String(sqs.QueueAttributeNamePolicy),
+		},
+	})
+	if err != nil {
+		return err
+	}
+	queueARN := attrs.Attributes[sqs.QueueAttributeNameQueueArn]
+	if queueARN == nil {
+		return errors.New("unable to find the arn of sqs queue " + cfg.QueueName)
+	}
+
+	var bus *string
+	if cfg.EventBus != "" {
+		bus = &cfg.EventBus
+	}
+
+	if !cfg.CreateRule {
+		targets, err := eb.ListTargetsByRule(&eventbridge.ListTargetsByRuleInput{
+			Rule:         &cfg.RuleName,
+			EventBusName: bus,
+		})
+		if err != nil {
+			return err
+		}
+		for _, t := range targets.Targets {
+			if aws.StringValue(t.Arn) == *queueARN {
+				return nil
+			}
+		}
+		return fmt.Errorf("eventbridge rule %s does not target sqs queue %s", cfg.RuleName, cfg.QueueName)
+	}
+
+	rule, err := eb.PutRule(&eventbridge.PutRuleInput{
+		Name:         &cfg.RuleName,
+		EventBusName: bus,
+		EventPattern: &cfg.EventPattern,
+		State:        aws.String(eventbridge.RuleStateEnabled),
+	})
+	if err != nil {
+		return err
+	}
+
+	// keep any existing permissions, like those letting SNS topics send to
+	// the queue, and only add the rule's statement if it is missing
+	policy, changed, err := addEventBridgeStatement(
+		aws.StringValue(attrs.Attributes[sqs.QueueAttributeNamePolicy]),
+		eventBridgeSid(cfg.RuleName),
+		*queueARN,
+		aws.StringValue(rule.RuleArn),
+	)
+	if err != nil {
+		return err
+	}
+	if changed {
+		_, err = sub.sqs.SetQueueAttributes(&sqs.SetQueueAttributesInput{
+			QueueUrl:   sub.queueURL,
+			Attributes: map[string]*string{sqs.QueueAttributeNamePolicy: &policy},
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	out, err := eb.PutTargets(&eventbridge.PutTargetsInput{
+		Rule:         &cfg.RuleName,
+		EventBusName: bus,
+		Targets: []*eventbridge.Target{{
+			Id:  aws.String("gizmo-" + cfg.QueueName),
+			Arn: queueARN,
+		}},
+	})
+	if err != nil {
+		return err
+	}
+	if aws.Int64Value(out.FailedEntryCount) > 0 && len(out.FailedEntries) > 0 {
+		return fmt.Errorf("unable to target sqs queue %s: %s", cfg.QueueName, aws.StringValue(out.FailedEntries[0].ErrorMessage))
+	}
+	return nil
+}
+
+// eventBridgeSid will return the sid of the queue policy statement for the
+// named rule, keeping only the letters and digits policies allow in a sid.
+func eventBridgeSid(rule string) string {
+	return "GizmoEventBridge" + strings.Map(func(r rune) rune {
+		if r < utf8.RuneSelf && (unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			return r
+		}
+		return -1
+	}, rule)
+}
+
+// addEventBridgeStatement will add a statement allowing the EventBridge rule
+// to send to the queue to its existing policy, unless a statement with the
+// same sid is already there. It returns the policy and whether it changed.
+func addEventBridgeStatement(policy, sid, queueARN, ruleARN string) (string, bool, error) {
+	doc := map[string]interface{}{"Version": "2012-10-17"}
+	if policy != "" {
+		if err := json.Unmarshal([]byte(policy), &doc); err != nil {
+			return "", false, fmt.Errorf("unable to parse sqs queue policy: %s", err)
+		}
+	}
+
+	var statements []interface{}
+	switch st := doc["Statement"].(type) {
+	case []interface{}:
+		statements = st
+	case map[string]interface{}:
+		// a policy with a single statement may omit the list
+		statements = []interface{}{st}
+	}
+	for _, st := range statements {
+		if st, ok := st.(map[string]interface{}); ok && st["Sid"] == sid {
+			return policy, false, nil
+		}
+	}
+
+	doc["Statement"] = append(statements, map[string]interface{}{
+		"Sid":       sid,
+		"Effect":    "Allow",
+		"Principal": map[string]string{"Service": "events.amazonaws.com"},
+		"Action":    "sqs:SendMessage",
+		"Resource":  queueARN,
+		"Condition": map[string]interface{}{
+			"ArnEquals": map[string]string{"aws:SourceArn": ruleARN},
+		},
+	})
+	b, err := json.Marshal(doc)
+	if err != nil {
+		return "", false, err
+	}
+	return string(b), true, nil
+}
+
+// Message will return the event's detail. If the body could not be parsed as
+// an event, the full body is returned.
+func (m *EventBridgeMessage) Message() []byte {
+	if m.event == nil {
+		return m.SQSMessage.Message()
+	}
+	return m.event.Detail
+}
+
+// Event will return the full event, or nil if the body could not be parsed.
+func (m *EventBridgeMessage) Event() *EventBridgeEvent {
+	return m.event
+}
+
+func newEventBridgeMessage(msg *SQSMessage) *EventBridgeMessage {
+	m := &EventBridgeMessage{SQSMessage: msg}
+	var event EventBridgeEvent
+	if err := json.Unmarshal(msg.Message(), &event); err != nil {
+		Log.Warnf("unable to parse eventbridge event: %s", err)
+		return m
+	}
+	m.event = &event
+	return m
+}
+
+// Start will start consuming the queue and emit each event. If it encounters
+// any issues, it will populate the Err() error and close the returned channel.
+func (s *EventBridgeSubscriber) Start() <-chan SubscriberMessage {
+	msgs, stopped := s.sqs.start()
+	output := make(chan SubscriberMessage)
+	go func() {
+		defer close(output)
+		for msg := range msgs {
+			select {
+			case output <- newEventBridgeMessage(msg.(*SQSMessage)):
+			case <-stopped:
+				// as with SQS, a message left over when stopped will
+				// be redelivered once its visibility times out
+				msg.(*SQSMessage).Release()
+				return
+			}
+		}
+	}()
+	return output
+}
+
+// Stop will stop consuming the queue.
+func (s *EventBridgeSubscriber) Stop() error {
+	return s.sqs.Stop()
+}
+
+// Err will contain any errors that occurred during consumption. This
+// method should be checked after a user encounters a closed channel.
+func (s *EventBridgeSubscriber) Err() error {
+	return s.sqs.Err()
+}
@@ -0,0 +1,160 @@
+// +build !noaws
+
+package pubsub
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/eventbridge"
+	"github.com/aws/aws-sdk-go/service/eventbridge/eventbridgeiface"
+	"github.com/aws/aws-sdk-go/service/sqs"
+
+	"github.com/NYTimes/gizmo/config"
+)
+
+type testEventBridgeAPI struct {
+	eventbridgeiface.EventBridgeAPI
+	put    []*eventbridge.PutEventsRequestEntry
+	failed bool
+}
+
+func (t *testEventBridgeAPI) PutEvents(in *eventbridge.PutEventsInput) (*eventbridge.PutEventsOutput, error) {
+	t.put = append(t.put, in.Entries...)
+	if t.failed {
+		return &eventbridge.PutEventsOutput{
+			FailedEntryCount: aws.Int64(1),
+			Entries: []*eventbridge.PutEventsResultEntry{{
+				ErrorCode:    aws.String("InternalFailure"),
+				ErrorMessage: aws.String("nope"),
+			}},
+		}, nil
+	}
+	return &eventbridge.PutEventsOutput{
+		FailedEntryCount: aws.Int64(0),
+		Entries:          []*eventbridge.PutEventsResultEntry{{EventId: aws.String("event-1")}},
+	}, nil
+}
+
+func TestEventBridgePublisher(t *testing.T) {
+	eb := &testEventBridgeAPI{}
+	pub := &EventBridgePublisher{eb: eb, cfg: &config.EventBridge{EventBus: "cats", Source: "com.example.cats"}}
+
+	if err := pub.Publish("CatAdopted", &TestProto{Value: "tabby"}); err != nil {
+		t.Fatal("unexpected error: ", err)
+	}
+	if len(eb.put) != 1 {
+		t.Fatalf("expected 1 event put, got %d", len(eb.put))
+	}
+	e := eb.put[0]
+	if *e.DetailType != "CatAdopted" || *e.Source != "com.example.cats" || *e.EventBusName != "cats" {
+		t.Errorf("unexpected event: %s", e)
+	}
+	if *e.Detail != `{"value":"tabby"}` {
+		t.Errorf("expected the JSON form of the message as the detail, got %s", *e.Detail)
+	}
+
+	res, err := PublishWithResult(pub, "CatAdopted", []byte(`{}`))
+	if err != nil {
+		t.Fatal("unexpected error: ", err)
+	}
+	if res.MessageID != "event-1" || res.Timestamp.IsZero() {
+		t.Errorf("expected a result for event-1, got %+v", res)
+	}
+
+	eb.failed = true
+	if err := pub.PublishRaw("CatAdopted", []byte(`{}`)); err == nil {
+		t.Error("expected an error for a failed entry")
+	}
+}
+
+func TestEventBridgeMessage(t *testing.T) {
+	consumeBase64 := false
+	msg := newEventBridgeMessage(&SQSMessage{
+		sub: &SQSSubscriber{cfg: &config.SQS{ConsumeBase64: &consumeBase64}},
+		message: &sqs.Message{
+			Body: aws.String(`{"id":"1","detail-type":"CatAdopted","source":"com.example.cats","detail":{"value":"tabby"}}`),
+		},
+	})
+	if string(msg.Message()) != `{"value":"tabby"}` {
+		t.Errorf("expected the event detail, got %s", msg.Message())
+	}
+	if e := msg.Event(); e == nil || e.DetailType != "CatAdopted" || e.Source != "com.example.cats" {
+		t.Errorf("unexpected event: %+v", e)
+	}
+}
+
+func TestAddEventBridgeStatement(t *testing.T) {
+	sns := `{"Version":"2012-10-17","Id":"cats","Statement":{"Sid":"sns","Effect":"Allow","Principal":"*","Action":"sqs:SendMessage"}}`
+	sid := eventBridgeSid("cat-adopted.rule")
+	if sid != "GizmoEventBridgecatadoptedrule" {
+		t.Errorf("expected an alphanumeric sid, got %s", sid)
+	}
+
+	policy, changed, err := addEventBridgeStatement(sns, sid, "queue-arn", "rule-arn")
+	if err != nil {
+		t.Fatalf("expected no error, got %s", err)
+	}
+	if !changed {
+		t.Error("expected the policy to change")
+	}
+	var doc struct {
+		ID        string `json:"Id"`
+		Statement []struct {
+			Sid string
+		}
+	}
+	if err := json.Unmarshal([]byte(policy), &doc); err != nil {
+		t.Fatalf("expected a valid policy, got %s", err)
+	}
+	if doc.ID != "cats" || len(doc.Statement) != 2 || doc.Statement[0].Sid != "sns" || doc.Statement[1].Sid != sid {
+		t.Errorf("expected the sns and eventbridge statements, got %s", policy)
+	}
+
+	again, changed, err := addEventBridgeStatement(policy, sid, "queue-arn", "rule-arn")
+	if err != nil {
+		t.Fatalf("expected no error, got %s", err)
+	}
+	if changed || again != policy {
+		t.Errorf("expected the policy to be left alone, got %s", again)
+	}
+
+	if _, changed, _ = addEventBridgeStatement("", sid, "queue-arn", "rule-arn"); !changed {
+		t.Error("expected a new policy for a queue without one")
+	}
+	if _, _, err = addEventBridgeStatement("{", sid, "queue-arn", "rule-arn"); err == nil {
+		t.Error("expected an error for an invalid policy, got none")
+	}
+}
+
+func TestEventBridgeSubscriberStop(t *testing.T) {
+	body := `{"id":"1","detail":{"value":"tabby"}}`
+	fals := false
+	cfg := &config.SQS{ConsumeBase64: &fals}
+	defaultSQSConfig(cfg)
+	sqstest := &TestSQSAPI{Messages: [][]*sqs.Message{{
+		{Body: &body, ReceiptHandle: aws.String("1")},
+	}}}
+	sub := &EventBridgeSubscriber{sqs: &SQSSubscriber{sqs: sqstest, cfg: cfg}}
+
+	// nothing reads the events, so stopping must not leave the
+	// relay blocked holding a message
+	output := sub.Start()
+	for sub.sqs.InFlight() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	if err := sub.Stop(); err != nil {
+		t.Fatal("unexpected error stopping subscriber: ", err)
+	}
+	for i := 0; sub.sqs.InFlight() != 0 && i < 100; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := sub.sqs.InFlight(); n != 0 {
+		t.Errorf("expected no messages in flight, got %d", n)
+	}
+	if _, ok := <-output; ok {
+		t.Error("expected the channel to be closed")
+	}
+}
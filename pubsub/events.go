@@ -0,0 +1,259 @@
+package pubsub
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+// SubscriberEventType identifies a transition in a subscriber's lifecycle.
+type SubscriberEventType int
+
+const (
+	// EventStarted is emitted once a subscriber has been started.
+	EventStarted SubscriberEventType = iota
+	// EventReceived is emitted for each message a subscriber emits.
+	EventReceived
+	// EventAcked is emitted once a message has been marked as done.
+	EventAcked
+	// EventNacked is emitted once a message has been handed back for
+	// redelivery.
+	EventNacked
+	// EventBackoff is emitted when a subscriber waits before receiving
+	// again, like after finding its queue empty.
+	EventBackoff
+	// EventStopping is emitted when a subscriber has been asked to stop.
+	EventStopping
+	// EventStopped is emitted once a subscriber has stopped, either on
+	// request or because it encountered an error.
+	EventStopped
+)
+
+var subscriberEventNames = []string{
+	EventStarted:  "started",
+	EventReceived: "received",
+	EventAcked:    "acked",
+	EventNacked:   "nacked",
+	EventBackoff:  "backoff",
+	EventStopping: "stopping",
+	EventStopped:  "stopped",
+}
+
+func (t SubscriberEventType) String() string {
+	if t < 0 || int(t) >= len(subscriberEventNames) {
+		return "unknown"
+	}
+	return subscriberEventNames[t]
+}
+
+// SubscriberEvent describes a transition in a subscriber's lifecycle.
+type SubscriberEvent struct {
+	Type SubscriberEventType
+	Time time.Time
+	// Message is the message the event is about, for EventReceived,
+	// EventAcked and EventNacked.
+	Message SubscriberMessage
+	// Delay is how long the subscriber will wait, for EventBackoff.
+	Delay time.Duration
+	// Err is the error from acking or nacking the message, for EventAcked
+	// and EventNacked, or that stopped the subscriber, for EventStopped.
+	Err error
+}
+
+// SubscriberListener is notified of the transitions in a subscriber's
+// lifecycle. Events are delivered synchronously and may come from several
+// goroutines at once, such as a Consumer's handlers acking messages, so
+// implementations should be quick and safe for concurrent use.
+type SubscriberListener interface {
+	OnSubscriberEvent(SubscriberEvent)
+}
+
+// SubscriberListenerFunc is a func that implements SubscriberListener.
+type SubscriberListenerFunc func(SubscriberEvent)
+
+// OnSubscriberEvent will call f with the event.
+func (f SubscriberListenerFunc) OnSubscriberEvent(e SubscriberEvent) {
+	f(e)
+}
+
+// ListenableSubscriber is a Subscriber that can report its messages being
+// received, acked and nacked, along with transitions only it can see, like
+// backing off an empty queue, to a SubscriberListener.
+type ListenableSubscriber interface {
+	Subscriber
+	// SetListener will set the listener notified of the subscriber's
+	// messages and internal transitions.
+	SetListener(SubscriberListener)
+}
+
+// NewMetricsListener will return a SubscriberListener that counts each event
+// in a 'pubsub.subscriber.EVENT' metric, like 'pubsub.subscriber.ACKED', for
+// dashboards. Failed acks and nacks are also counted in
+// 'pubsub.subscriber.ACKED.ERROR' and 'pubsub.subscriber.NACKED.ERROR'. If
+// registry is nil, the default metrics registry is used.
+func NewMetricsListener(registry metrics.Registry) SubscriberListener {
+	return SubscriberListenerFunc(func(e SubscriberEvent) {
+		name := "pubsub.subscriber." + strings.ToUpper(e.Type.String())
+		metrics.GetOrRegisterCounter(name, registry).Inc(1)
+		if e.Err != nil && (e.Type == EventAcked || e.Type == EventNacked) {
+			metrics.GetOrRegisterCounter(name+".ERROR", registry).Inc(1)
+		}
+	})
+}
+
+// ObservedSubscriber is a Subscriber that reports the lifecycle of another
+// Subscriber and its messages to a SubscriberListener, so its state can be
+// watched without parsing logs. A ListenableSubscriber reports its own
+// messages, so they are emitted as they are. Messages of other Subscribers
+// are wrapped to report acking and nacking, keeping only the Nack and
+// Attributes methods of the messages they wrap.
+type ObservedSubscriber struct {
+	sub      Subscriber
+	listener SubscriberListener
+	// native is set if sub reports its own messages
+	native bool
+
+	stop        chan struct{}
+	stopOnce    sync.Once
+	stoppedOnce sync.Once
+
+	mu   sync.Mutex
+	done chan struct{}
+}
+
+// NewObservedSubscriber will return an ObservedSubscriber that reports the
+// lifecycle of sub to listener. If sub is a ListenableSubscriber, it will be
+// given the listener for its messages and its own transitions too.
+func NewObservedSubscriber(sub Subscriber, listener SubscriberListener) *ObservedSubscriber {
+	ls, native := sub.(ListenableSubscriber)
+	if native {
+		ls.SetListener(listener)
+	}
+	return &ObservedSubscriber{
+		sub:      sub,
+		listener: listener,
+		native:   native,
+		stop:     make(chan struct{}),
+	}
+}
+
+// Start will start the underlying Subscriber and emit its messages. The
+// returned channel is closed once the underlying Subscriber's is.
+func (s *ObservedSubscriber) Start() <-chan SubscriberMessage {
+	output := make(chan SubscriberMessage)
+	done := make(chan struct{})
+	s.mu.Lock()
+	s.done = done
+	s.mu.Unlock()
+	// a ListenableSubscriber may report its first messages right away
+	s.emit(SubscriberEvent{Type: EventStarted})
+	msgs := s.sub.Start()
+
+	go func() {
+		defer close(done)
+		defer close(output)
+		for msg := range msgs {
+			if !s.native {
+				s.emit(SubscriberEvent{Type: EventReceived, Message: msg})
+			}
+			select {
+			case output <- s.observe(msg):
+			case <-s.stop:
+				// drain so the Subscriber is not blocked from stopping
+				for range msgs {
+				}
+				return
+			}
+		}
+		select {
+		case <-s.stop:
+			// Stop will report the stop once it is complete
+		default:
+			s.stopped(s.sub.Err())
+		}
+	}()
+	return output
+}
+
+// Stop will stop the underlying Subscriber and wait for the last of its
+// messages to be relayed.
+func (s *ObservedSubscriber) Stop() error {
+	s.emit(SubscriberEvent{Type: EventStopping})
+	s.stopOnce.Do(func() {
+		close(s.stop)
+	})
+	err := s.sub.Stop()
+	s.mu.Lock()
+	done := s.done
+	s.mu.Unlock()
+	if done != nil {
+		<-done
+	}
+	if err == nil {
+		err = s.sub.Err()
+	}
+	s.stopped(err)
+	return err
+}
+
+// Err will return the underlying Subscriber's error.
+func (s *ObservedSubscriber) Err() error {
+	return s.sub.Err()
+}
+
+func (s *ObservedSubscriber) stopped(err error) {
+	s.stoppedOnce.Do(func() {
+		s.emit(SubscriberEvent{Type: EventStopped, Err: err})
+	})
+}
+
+func (s *ObservedSubscriber) emit(e SubscriberEvent) {
+	e.Time = time.Now()
+	s.listener.OnSubscriberEvent(e)
+}
+
+// observe will wrap the message so acking and nacking it are reported,
+// unless the Subscriber reports them itself.
+func (s *ObservedSubscriber) observe(msg SubscriberMessage) SubscriberMessage {
+	if s.native {
+		return msg
+	}
+	om := &observedMessage{SubscriberMessage: msg, sub: s}
+	if _, ok := msg.(NackMessage); ok {
+		return &observedNackMessage{om}
+	}
+	return om
+}
+
+type observedMessage struct {
+	SubscriberMessage
+	sub *ObservedSubscriber
+}
+
+// Done will mark the message as done and report it.
+func (m *observedMessage) Done() error {
+	err := m.SubscriberMessage.Done()
+	m.sub.emit(SubscriberEvent{Type: EventAcked, Message: m.SubscriberMessage, Err: err})
+	return err
+}
+
+// Attributes will return the wrapped message's attributes, if it has any.
+func (m *observedMessage) Attributes() map[string]string {
+	if amsg, ok := m.SubscriberMessage.(AttributeMessage); ok {
+		return amsg.Attributes()
+	}
+	return nil
+}
+
+type observedNackMessage struct {
+	*observedMessage
+}
+
+// Nack will hand the message back for redelivery and report it.
+func (m *observedNackMessage) Nack() error {
+	err := m.SubscriberMessage.(NackMessage).Nack()
+	m.sub.emit(SubscriberEvent{Type: EventNacked, Message: m.SubscriberMessage, Err: err})
+	return err
+}
@@ -0,0 +1,108 @@
+package pubsub
+
+import (
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+// testListener records the type of each event it is given.
+type testListener struct {
+	mu     sync.Mutex
+	events []SubscriberEventType
+}
+
+func (l *testListener) OnSubscriberEvent(e SubscriberEvent) {
+	l.mu.Lock()
+	l.events = append(l.events, e.Type)
+	l.mu.Unlock()
+}
+
+func (l *testListener) types() []SubscriberEventType {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]SubscriberEventType(nil), l.events...)
+}
+
+func TestObservedSubscriber(t *testing.T) {
+	sub := newTestChanSubscriber()
+	l := &testListener{}
+	osub := NewObservedSubscriber(sub, l)
+
+	msgs := osub.Start()
+	// send each message once the last is done to keep the events in order
+	go func() {
+		sub.msgs <- &testConsumerMessage{msg: []byte("ack")}
+	}()
+	msg := <-msgs
+	if err := msg.Done(); err != nil {
+		t.Errorf("expected no error, got %s", err)
+	}
+	go func() {
+		sub.msgs <- &testNackMessage{testConsumerMessage: testConsumerMessage{msg: []byte("nack")}}
+	}()
+	msg = <-msgs
+	nmsg, ok := msg.(NackMessage)
+	if !ok {
+		t.Fatalf("expected a NackMessage, got %T", msg)
+	}
+	if err := nmsg.Nack(); err != nil {
+		t.Errorf("expected no error, got %s", err)
+	}
+
+	if err := osub.Stop(); err != nil {
+		t.Errorf("expected no error, got %s", err)
+	}
+	if _, ok := <-msgs; ok {
+		t.Error("expected the channel to be closed")
+	}
+
+	want := []SubscriberEventType{
+		EventStarted, EventReceived, EventAcked, EventReceived, EventNacked,
+		EventStopping, EventStopped,
+	}
+	if got := l.types(); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected events %v, got %v", want, got)
+	}
+}
+
+func TestObservedSubscriberError(t *testing.T) {
+	sub := newTestChanSubscriber()
+	sub.err = errors.New("receive failed")
+	var stopErr error
+	osub := NewObservedSubscriber(sub, SubscriberListenerFunc(func(e SubscriberEvent) {
+		if e.Type == EventStopped {
+			stopErr = e.Err
+		}
+	}))
+
+	msgs := osub.Start()
+	close(sub.msgs)
+	if _, ok := <-msgs; ok {
+		t.Fatal("expected the channel to be closed")
+	}
+	if stopErr != sub.err {
+		t.Errorf("expected stopped event with error %q, got %v", sub.err, stopErr)
+	}
+}
+
+func TestMetricsListener(t *testing.T) {
+	registry := metrics.NewRegistry()
+	l := NewMetricsListener(registry)
+	l.OnSubscriberEvent(SubscriberEvent{Type: EventAcked})
+	l.OnSubscriberEvent(SubscriberEvent{Type: EventAcked, Err: errors.New("delete failed")})
+	l.OnSubscriberEvent(SubscriberEvent{Type: EventBackoff})
+
+	for name, want := range map[string]int64{
+		"pubsub.subscriber.ACKED":       2,
+		"pubsub.subscriber.ACKED.ERROR": 1,
+		"pubsub.subscriber.BACKOFF":     1,
+	} {
+		if got := metrics.GetOrRegisterCounter(name, registry).Count(); got != want {
+			t.Errorf("expected %s to be %d, got %d", name, want, got)
+		}
+	}
+}
@@ -0,0 +1,236 @@
+package pubsub
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultFilePollInterval is how long a FileSubscriber will wait between
+// listing its source if no interval is given.
+var DefaultFilePollInterval = 10 * time.Second
+
+type (
+	// FileSource is a place files arrive in, like a local directory or an
+	// S3 prefix, that can be consumed by a FileSubscriber.
+	FileSource interface {
+		// List will return the names of all files currently in the source.
+		List() ([]string, error)
+		// Open will return a reader over the named file.
+		Open(name string) (io.ReadCloser, error)
+		// Finish will be called once the message for the named file is
+		// done so the file will not be listed again.
+		Finish(name string) error
+	}
+
+	// FileSubscriber is a Subscriber that polls a FileSource and emits each
+	// new file as a message, so batch file ingestion can share consumer code
+	// with streaming pipelines. Files are emitted in name order with the
+	// file's contents as the message body and are finished, which usually
+	// means moved or removed, once the message is Done. Files that are
+	// emitted but never done will be emitted again when the subscriber
+	// restarts.
+	FileSubscriber struct {
+		src      FileSource
+		interval time.Duration
+
+		// files that have been emitted but not finished
+		mu       sync.Mutex
+		inflight map[string]bool
+		err      error
+
+		stop     chan struct{}
+		stopOnce sync.Once
+		done     chan struct{}
+	}
+
+	// FileMessage is the FileSubscriber implementation of `SubscriberMessage`.
+	FileMessage struct {
+		name string
+		body []byte
+		sub  *FileSubscriber
+	}
+)
+
+// NewFileSubscriber will return a FileSubscriber that lists src every
+// interval. If interval is 0, DefaultFilePollInterval is used.
+func NewFileSubscriber(src FileSource, interval time.Duration) *FileSubscriber {
+	if interval == 0 {
+		interval = DefaultFilePollInterval
+	}
+	return &FileSubscriber{
+		src:      src,
+		interval: interval,
+		inflight: map[string]bool{},
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+// Message will return the contents of the file.
+func (m *FileMessage) Message() []byte {
+	return m.body
+}
+
+// Name will return the name of the file in its source.
+func (m *FileMessage) Name() string {
+	return m.name
+}
+
+// Done will finish the file in its source so it is not emitted again.
+func (m *FileMessage) Done() error {
+	err := m.sub.src.Finish(m.name)
+	if err == nil {
+		m.sub.mu.Lock()
+		delete(m.sub.inflight, m.name)
+		m.sub.mu.Unlock()
+	}
+	return err
+}
+
+// Start will begin polling the source and emitting any new files. If it
+// encounters any issues, it will populate the Err() error and close the
+// returned channel.
+func (s *FileSubscriber) Start() <-chan SubscriberMessage {
+	output := make(chan SubscriberMessage)
+	go func() {
+		defer close(s.done)
+		defer close(output)
+		if err := s.run(output); err != nil {
+			s.mu.Lock()
+			s.err = err
+			s.mu.Unlock()
+		}
+	}()
+	return output
+}
+
+func (s *FileSubscriber) run(output chan<- SubscriberMessage) error {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		names, err := s.src.List()
+		if err != nil {
+			return err
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			s.mu.Lock()
+			emitted := s.inflight[name]
+			s.mu.Unlock()
+			if emitted {
+				continue
+			}
+
+			body, err := s.read(name)
+			if os.IsNotExist(err) {
+				// the file was moved since it was listed
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			s.mu.Lock()
+			s.inflight[name] = true
+			s.mu.Unlock()
+
+			select {
+			case <-s.stop:
+				return nil
+			case output <- &FileMessage{name: name, body: body, sub: s}:
+			}
+		}
+
+		select {
+		case <-s.stop:
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *FileSubscriber) read(name string) ([]byte, error) {
+	r, err := s.src.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return ioutil.ReadAll(r)
+}
+
+// Stop will stop polling the source.
+func (s *FileSubscriber) Stop() error {
+	s.stopOnce.Do(func() {
+		close(s.stop)
+	})
+	<-s.done
+	return nil
+}
+
+// Err will contain any errors that occurred during consumption. This
+// method should be checked after a user encounters a closed channel.
+func (s *FileSubscriber) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+// DirSource is a FileSource for the files in a local directory.
+type DirSource struct {
+	// Dir is the directory to watch.
+	Dir string
+	// Pattern will limit the files emitted to those with names matching
+	// the shell pattern, like '*.csv'. All files are emitted if empty.
+	Pattern string
+	// DoneDir is where finished files will be moved to. If empty,
+	// finished files are removed.
+	DoneDir string
+	// MinAge will skip files that have been modified more recently than
+	// this, to avoid emitting files that are still being written.
+	MinAge time.Duration
+}
+
+// List will return the names of the regular files in the directory.
+// Hidden files are skipped.
+func (d *DirSource) List() ([]string, error) {
+	infos, err := ioutil.ReadDir(d.Dir)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, info := range infos {
+		if !info.Mode().IsRegular() || strings.HasPrefix(info.Name(), ".") {
+			continue
+		}
+		if d.Pattern != "" {
+			if ok, err := filepath.Match(d.Pattern, info.Name()); err != nil {
+				return nil, err
+			} else if !ok {
+				continue
+			}
+		}
+		if d.MinAge > 0 && time.Since(info.ModTime()) < d.MinAge {
+			continue
+		}
+		names = append(names, info.Name())
+	}
+	return names, nil
+}
+
+// Open will open the named file in the directory.
+func (d *DirSource) Open(name string) (io.ReadCloser, error) {
+	return os.Open(filepath.Join(d.Dir, name))
+}
+
+// Finish will move the named file to the DoneDir or remove it.
+func (d *DirSource) Finish(name string) error {
+	if d.DoneDir != "" {
+		return os.Rename(filepath.Join(d.Dir, name), filepath.Join(d.DoneDir, name))
+	}
+	return os.Remove(filepath.Join(d.Dir, name))
+}
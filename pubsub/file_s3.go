@@ -0,0 +1,113 @@
+// +build !noaws
+
+package pubsub
+
+import (
+	"errors"
+	"io"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+
+	"github.com/NYTimes/gizmo/config"
+)
+
+// S3Source is a FileSource for the objects under a prefix of an S3 bucket.
+// File names are the objects' full keys. To react to new objects without
+// polling, S3 event notifications can instead be sent to a queue and
+// consumed with the SQSSubscriber.
+type S3Source struct {
+	s3     s3iface.S3API
+	bucket string
+	// Prefix limits the objects emitted to those with keys starting with it.
+	Prefix string
+	// DonePrefix is the prefix finished objects will be moved under, in
+	// place of the Prefix. If empty, finished objects are deleted.
+	DonePrefix string
+}
+
+// NewS3Source will initiate an S3 client for the bucket in the given config.
+// If no credentials are passed in with the config, the client will use the
+// AWS_ACCESS_KEY and the AWS_SECRET_KEY environment variables.
+func NewS3Source(cfg *config.S3, prefix string) (*S3Source, error) {
+	if cfg.Bucket == "" {
+		return nil, errors.New("S3 bucket is required")
+	}
+	if cfg.Region == "" {
+		return nil, errors.New("S3 region is required")
+	}
+
+	var creds *credentials.Credentials
+	if cfg.AccessKey != "" {
+		creds = credentials.NewStaticCredentials(cfg.AccessKey, cfg.SecretKey, "")
+	} else {
+		creds = credentials.NewEnvCredentials()
+	}
+	awsCfg := &aws.Config{
+		Credentials: creds,
+		Region:      &cfg.Region,
+	}
+	if cfg.Endpoint != "" {
+		awsCfg.Endpoint = &cfg.Endpoint
+		awsCfg.S3ForcePathStyle = aws.Bool(true)
+	}
+	return &S3Source{
+		s3:     s3.New(session.New(awsEndpoints(cfg.AWS, awsCfg))),
+		bucket: cfg.Bucket,
+		Prefix: prefix,
+	}, nil
+}
+
+// List will return the keys of all objects under the Prefix.
+func (s *S3Source) List() ([]string, error) {
+	var keys []string
+	err := s.s3.ListObjectsPages(&s3.ListObjectsInput{
+		Bucket: &s.bucket,
+		Prefix: &s.Prefix,
+	}, func(page *s3.ListObjectsOutput, last bool) bool {
+		for _, obj := range page.Contents {
+			// skip 'directory' placeholders
+			if strings.HasSuffix(*obj.Key, "/") {
+				continue
+			}
+			keys = append(keys, *obj.Key)
+		}
+		return true
+	})
+	return keys, err
+}
+
+// Open will return the body of the object with the given key.
+func (s *S3Source) Open(key string) (io.ReadCloser, error) {
+	out, err := s.s3.GetObject(&s3.GetObjectInput{
+		Bucket: &s.bucket,
+		Key:    &key,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out.Body, nil
+}
+
+// Finish will copy the object under the DonePrefix, if set, and delete it.
+func (s *S3Source) Finish(key string) error {
+	if s.DonePrefix != "" {
+		_, err := s.s3.CopyObject(&s3.CopyObjectInput{
+			Bucket:     &s.bucket,
+			CopySource: aws.String(s.bucket + "/" + key),
+			Key:        aws.String(s.DonePrefix + strings.TrimPrefix(key, s.Prefix)),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	_, err := s.s3.DeleteObject(&s3.DeleteObjectInput{
+		Bucket: &s.bucket,
+		Key:    &key,
+	})
+	return err
+}
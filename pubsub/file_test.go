@@ -0,0 +1,72 @@
+package pubsub
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFileSubscriber(t *testing.T) {
+	dir, err := ioutil.TempDir("", "filesub")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	in := filepath.Join(dir, "in")
+	done := filepath.Join(dir, "done")
+	os.Mkdir(in, 0755)
+	os.Mkdir(done, 0755)
+
+	write := func(name, body string) {
+		if err := ioutil.WriteFile(filepath.Join(in, name), []byte(body), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("b.csv", "b")
+	write("a.csv", "a")
+	write("c.txt", "c")
+	write(".d.csv", "d")
+
+	sub := NewFileSubscriber(&DirSource{Dir: in, Pattern: "*.csv", DoneDir: done}, 10*time.Millisecond)
+	msgs := sub.Start()
+
+	next := func() *FileMessage {
+		select {
+		case m := <-msgs:
+			return m.(*FileMessage)
+		case <-time.After(time.Second):
+			t.Fatal("timed out waiting for a file")
+		}
+		return nil
+	}
+
+	for _, want := range []string{"a.csv", "b.csv"} {
+		m := next()
+		if m.Name() != want || string(m.Message()) != want[:1] {
+			t.Errorf("expected %s, got %s with %q", want, m.Name(), m.Message())
+		}
+		if err := m.Done(); err != nil {
+			t.Fatal("unexpected error finishing file: ", err)
+		}
+		if _, err := os.Stat(filepath.Join(done, want)); err != nil {
+			t.Errorf("expected %s to be moved to the done dir: %s", want, err)
+		}
+	}
+
+	write("e.csv", "e")
+	if m := next(); m.Name() != "e.csv" {
+		t.Errorf("expected the new file to be emitted, got %s", m.Name())
+	}
+
+	if err := sub.Stop(); err != nil {
+		t.Error("unexpected error stopping: ", err)
+	}
+	if _, ok := <-msgs; ok {
+		t.Error("expected the channel to be closed after stop")
+	}
+	if err := sub.Err(); err != nil {
+		t.Error("unexpected subscriber error: ", err)
+	}
+}
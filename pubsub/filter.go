@@ -0,0 +1,167 @@
+package pubsub
+
+import (
+	"encoding/json"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// MessageFilter is a func that decides whether a Consumer should handle a
+// message. Messages it returns false for are marked as done without being
+// handled.
+type MessageFilter func(SubscriberMessage) bool
+
+// AllFilters will return a MessageFilter that matches messages matched
+// by every given filter.
+func AllFilters(filters ...MessageFilter) MessageFilter {
+	return func(msg SubscriberMessage) bool {
+		for _, f := range filters {
+			if !f(msg) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// AnyFilter will return a MessageFilter that matches messages matched
+// by at least one of the given filters.
+func AnyFilter(filters ...MessageFilter) MessageFilter {
+	return func(msg SubscriberMessage) bool {
+		for _, f := range filters {
+			if f(msg) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// attribute will return the named attribute of the message, if the
+// message is an AttributeMessage that has it.
+func attribute(msg SubscriberMessage, name string) (string, bool) {
+	amsg, ok := msg.(AttributeMessage)
+	if !ok {
+		return "", false
+	}
+	v, ok := amsg.Attributes()[name]
+	return v, ok
+}
+
+// AttributeEquals will return a MessageFilter that matches messages with
+// the named attribute set to value.
+func AttributeEquals(name, value string) MessageFilter {
+	return func(msg SubscriberMessage) bool {
+		v, ok := attribute(msg, name)
+		return ok && v == value
+	}
+}
+
+// AttributePrefix will return a MessageFilter that matches messages with
+// the named attribute starting with prefix.
+func AttributePrefix(name, prefix string) MessageFilter {
+	return func(msg SubscriberMessage) bool {
+		v, ok := attribute(msg, name)
+		return ok && strings.HasPrefix(v, prefix)
+	}
+}
+
+// AttributeMatches will return a MessageFilter that matches messages with
+// the named attribute matching re.
+func AttributeMatches(name string, re *regexp.Regexp) MessageFilter {
+	return func(msg SubscriberMessage) bool {
+		v, ok := attribute(msg, name)
+		return ok && re.MatchString(v)
+	}
+}
+
+// BodyPathEquals will return a MessageFilter that matches messages with JSON
+// bodies that have value at the given path. Paths are simple JSONPath
+// expressions of object keys and array indexes, like '$.cat.toys[0].name'.
+// Values that are not strings are compared by their JSON form, so a path to
+// the number 3 will equal "3" and one to true will equal "true".
+func BodyPathEquals(path, value string) MessageFilter {
+	return bodyPathFilter(path, func(v string) bool {
+		return v == value
+	})
+}
+
+// BodyPathMatches will return a MessageFilter that matches messages with
+// JSON bodies that have a value matching re at the given path. Paths and
+// values are handled as in BodyPathEquals.
+func BodyPathMatches(path string, re *regexp.Regexp) MessageFilter {
+	return bodyPathFilter(path, re.MatchString)
+}
+
+func bodyPathFilter(path string, match func(string) bool) MessageFilter {
+	steps := parseJSONPath(path)
+	return func(msg SubscriberMessage) bool {
+		var body interface{}
+		if err := json.Unmarshal(msg.Message(), &body); err != nil {
+			return false
+		}
+		v, ok := lookupJSONPath(body, steps)
+		if !ok {
+			return false
+		}
+		if s, isString := v.(string); isString {
+			return match(s)
+		}
+		b, err := json.Marshal(v)
+		return err == nil && match(string(b))
+	}
+}
+
+// parseJSONPath will split a path like '$.cat.toys[0]' into
+// the steps 'cat', 'toys' and '[0]'.
+func parseJSONPath(path string) []string {
+	path = strings.TrimPrefix(strings.TrimPrefix(path, "$"), ".")
+	var steps []string
+	for _, part := range strings.Split(path, ".") {
+		for {
+			i := strings.Index(part, "[")
+			if i < 0 {
+				break
+			}
+			if i > 0 {
+				steps = append(steps, part[:i])
+			}
+			j := strings.Index(part, "]")
+			if j < i {
+				break
+			}
+			steps = append(steps, part[i:j+1])
+			part = part[j+1:]
+		}
+		if part != "" {
+			steps = append(steps, part)
+		}
+	}
+	return steps
+}
+
+func lookupJSONPath(v interface{}, steps []string) (interface{}, bool) {
+	for _, step := range steps {
+		if strings.HasPrefix(step, "[") {
+			arr, ok := v.([]interface{})
+			if !ok {
+				return nil, false
+			}
+			i, err := strconv.Atoi(strings.Trim(step, "[]"))
+			if err != nil || i < 0 || i >= len(arr) {
+				return nil, false
+			}
+			v = arr[i]
+			continue
+		}
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if v, ok = obj[step]; !ok {
+			return nil, false
+		}
+	}
+	return v, true
+}
@@ -0,0 +1,69 @@
+package pubsub
+
+import (
+	"regexp"
+	"sync/atomic"
+	"testing"
+
+	"golang.org/x/net/context"
+)
+
+func TestMessageFilters(t *testing.T) {
+	msg := &testAttributeMessage{
+		testConsumerMessage{msg: []byte(`{"cat":{"name":"tabby","lives":9,"toys":[{"name":"mouse"}]}}`)},
+		map[string]string{"type": "cat.adopted"},
+	}
+	tests := []struct {
+		name   string
+		filter MessageFilter
+		want   bool
+	}{
+		{"attribute equals", AttributeEquals("type", "cat.adopted"), true},
+		{"attribute differs", AttributeEquals("type", "dog.adopted"), false},
+		{"missing attribute", AttributeEquals("color", "orange"), false},
+		{"attribute prefix", AttributePrefix("type", "cat."), true},
+		{"attribute regex", AttributeMatches("type", regexp.MustCompile(`^(cat|dog)\.`)), true},
+		{"body path", BodyPathEquals("$.cat.name", "tabby"), true},
+		{"body path number", BodyPathEquals("$.cat.lives", "9"), true},
+		{"body path index", BodyPathEquals("$.cat.toys[0].name", "mouse"), true},
+		{"body path out of range", BodyPathEquals("$.cat.toys[1].name", "mouse"), false},
+		{"body path missing", BodyPathEquals("$.dog.name", "tabby"), false},
+		{"body path regex", BodyPathMatches("cat.name", regexp.MustCompile(`^tab`)), true},
+		{"all", AllFilters(AttributePrefix("type", "cat."), BodyPathEquals("$.cat.name", "garfield")), false},
+		{"any", AnyFilter(AttributePrefix("type", "dog."), BodyPathEquals("$.cat.name", "tabby")), true},
+	}
+	for _, test := range tests {
+		if got := test.filter(msg); got != test.want {
+			t.Errorf("%s: expected %v, got %v", test.name, test.want, got)
+		}
+	}
+}
+
+func TestConsumerFilter(t *testing.T) {
+	sub := newTestChanSubscriber()
+	var handled int32
+	c := NewConsumer(sub, func(ctx context.Context, msg SubscriberMessage) error {
+		atomic.AddInt32(&handled, 1)
+		return nil
+	})
+	c.Filter = BodyPathEquals("$.type", "cat")
+
+	go c.Run()
+	cat := &testConsumerMessage{msg: []byte(`{"type":"cat"}`)}
+	dog := &testConsumerMessage{msg: []byte(`{"type":"dog"}`)}
+	sub.msgs <- cat
+	sub.msgs <- dog
+	if err := c.Stop(); err != nil {
+		t.Error("unexpected error stopping consumer: ", err)
+	}
+
+	if got := atomic.LoadInt32(&handled); got != 1 {
+		t.Errorf("expected 1 message handled, got %d", got)
+	}
+	if atomic.LoadInt32(&cat.doned) != 1 || atomic.LoadInt32(&dog.doned) != 1 {
+		t.Error("expected both messages to be done")
+	}
+	if stats := c.Stats(); stats.Filtered != 1 || stats.Handled != 1 {
+		t.Errorf("expected 1 handled and 1 filtered, got %+v", stats)
+	}
+}
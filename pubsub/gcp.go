@@ -0,0 +1,261 @@
+// +build !nogcp
+
+package pubsub
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	gpubsub "cloud.google.com/go/pubsub"
+	"github.com/golang/protobuf/proto"
+	"golang.org/x/net/context"
+	"google.golang.org/api/option"
+
+	"github.com/NYTimes/gizmo/config"
+)
+
+// GCPKeyAttribute is the message attribute that carries the key messages
+// are published with to Google Cloud Pub/Sub, which has no subject of its own.
+const GCPKeyAttribute = "key"
+
+// GCPPublisher is a publisher that provides an implementation for
+// Google Cloud Pub/Sub.
+type GCPPublisher struct {
+	topic *gpubsub.Topic
+}
+
+// NewGCPPublisher will initiate a Google Cloud Pub/Sub client for the
+// configured Topic. If no CredentialsFile is configured, the application
+// default credentials will be used.
+func NewGCPPublisher(ctx context.Context, cfg *config.GCP) (*GCPPublisher, error) {
+	if cfg.Topic == "" {
+		return nil, errors.New("GCP topic name is required")
+	}
+	client, err := newGCPClient(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &GCPPublisher{topic: client.Topic(cfg.Topic)}, nil
+}
+
+func newGCPClient(ctx context.Context, cfg *config.GCP) (*gpubsub.Client, error) {
+	if cfg.ProjectID == "" {
+		return nil, errors.New("GCP project ID is required")
+	}
+	var opts []option.ClientOption
+	if cfg.CredentialsFile != "" {
+		opts = append(opts, option.WithCredentialsFile(cfg.CredentialsFile))
+	}
+	return gpubsub.NewClient(ctx, cfg.ProjectID, opts...)
+}
+
+// Publish will marshal the proto message and emit it to the Pub/Sub topic.
+// The key will be sent in the GCPKeyAttribute.
+func (p *GCPPublisher) Publish(key string, m proto.Message) error {
+	mb, err := proto.Marshal(m)
+	if err != nil {
+		return err
+	}
+	return p.PublishRaw(key, mb)
+}
+
+// PublishRaw will emit the byte array to the Pub/Sub topic.
+// The key will be sent in the GCPKeyAttribute.
+func (p *GCPPublisher) PublishRaw(key string, m []byte) error {
+	_, err := p.PublishRawResult(key, m)
+	return err
+}
+
+// PublishRawResult will emit the byte array to the Pub/Sub topic like
+// PublishRaw and return the Pub/Sub message ID.
+func (p *GCPPublisher) PublishRawResult(key string, m []byte) (*PublishResult, error) {
+	return p.publish(key, m, nil)
+}
+
+// PublishAttributes will emit the byte array to the Pub/Sub topic with the
+// attributes, along with the key in the GCPKeyAttribute.
+func (p *GCPPublisher) PublishAttributes(key string, m []byte, attrs map[string]string) error {
+	_, err := p.publish(key, m, attrs)
+	return err
+}
+
+func (p *GCPPublisher) publish(key string, m []byte, attrs map[string]string) (*PublishResult, error) {
+	msg := &gpubsub.Message{
+		Data:       m,
+		Attributes: map[string]string{GCPKeyAttribute: key},
+	}
+	for k, v := range attrs {
+		msg.Attributes[k] = v
+	}
+	ctx := context.Background()
+	id, err := p.topic.Publish(ctx, msg).Get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &PublishResult{MessageID: id, Timestamp: time.Now()}, nil
+}
+
+// Stop will send any messages still batched by the client and stop
+// its publishing goroutines.
+func (p *GCPPublisher) Stop() {
+	p.topic.Stop()
+}
+
+// Verify will check that the Pub/Sub topic exists and that the credentials
+// may use it.
+func (p *GCPPublisher) Verify() error {
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultVerifyTimeout)
+	defer cancel()
+	ok, err := p.topic.Exists(ctx)
+	if err == nil && !ok {
+		err = &Error{Class: ErrQueueNotFound, Err: errors.New("topic not found")}
+	}
+	if err != nil {
+		return &VerifyError{Resource: "pubsub topic " + p.topic.ID(), Err: classify(err)}
+	}
+	return nil
+}
+
+// GCPSubscriber is a subscriber that provides an implementation for
+// Google Cloud Pub/Sub subscriptions.
+type GCPSubscriber struct {
+	sub *gpubsub.Subscription
+
+	mu     sync.Mutex
+	cancel context.CancelFunc
+	done   chan struct{}
+	err    error
+}
+
+// NewGCPSubscriber will initiate a Google Cloud Pub/Sub client for the
+// configured Subscription. If no CredentialsFile is configured, the
+// application default credentials will be used.
+func NewGCPSubscriber(ctx context.Context, cfg *config.GCP) (*GCPSubscriber, error) {
+	if cfg.Subscription == "" {
+		return nil, errors.New("GCP subscription name is required")
+	}
+	client, err := newGCPClient(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &GCPSubscriber{sub: client.Subscription(cfg.Subscription)}, nil
+}
+
+// GCPSubMessage is a SubscriberMessage received from a Pub/Sub subscription.
+type GCPSubMessage struct {
+	message *gpubsub.Message
+}
+
+// ID will return the ID Pub/Sub assigned to the message.
+func (m *GCPSubMessage) ID() string {
+	return m.message.ID
+}
+
+// SentAt will return when the message was published.
+func (m *GCPSubMessage) SentAt() time.Time {
+	return m.message.PublishTime
+}
+
+// Message will return the data of the Pub/Sub message.
+func (m *GCPSubMessage) Message() []byte {
+	return m.message.Data
+}
+
+// Attributes will return the attributes of the Pub/Sub message.
+func (m *GCPSubMessage) Attributes() map[string]string {
+	return m.message.Attributes
+}
+
+// Done will acknowledge the Pub/Sub message so it is not redelivered.
+func (m *GCPSubMessage) Done() error {
+	m.message.Ack()
+	return nil
+}
+
+// Nack will tell Pub/Sub to redeliver the message.
+func (m *GCPSubMessage) Nack() error {
+	m.message.Nack()
+	return nil
+}
+
+// Start will start receiving messages from the Pub/Sub subscription and
+// emit them to the returned channel. If it encounters any issues, it will
+// populate the Err() error and close the returned channel.
+func (s *GCPSubscriber) Start() <-chan SubscriberMessage {
+	return s.StartWithContext(context.Background())
+}
+
+// StartWithContext will start receiving like Start and stop the subscriber
+// once ctx is done.
+func (s *GCPSubscriber) StartWithContext(ctx context.Context) <-chan SubscriberMessage {
+	output := make(chan SubscriberMessage)
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+
+	s.mu.Lock()
+	s.cancel, s.done, s.err = cancel, done, nil
+	s.mu.Unlock()
+
+	go func() {
+		defer close(done)
+		defer close(output)
+		err := s.sub.Receive(ctx, func(ctx context.Context, msg *gpubsub.Message) {
+			select {
+			case output <- &GCPSubMessage{message: msg}:
+			case <-ctx.Done():
+				msg.Nack()
+			}
+		})
+		if err != nil && err != context.Canceled && err != context.DeadlineExceeded {
+			s.mu.Lock()
+			s.err = err
+			s.mu.Unlock()
+		}
+	}()
+	return output
+}
+
+// Restart will start receiving again after Stop, like Start, so a paused
+// Consumer does not hold messages from the subscription.
+func (s *GCPSubscriber) Restart() <-chan SubscriberMessage {
+	return s.Start()
+}
+
+// Err will contain any errors that occurred while receiving.
+func (s *GCPSubscriber) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+// Verify will check that the Pub/Sub subscription exists and that the
+// credentials may use it.
+func (s *GCPSubscriber) Verify() error {
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultVerifyTimeout)
+	defer cancel()
+	ok, err := s.sub.Exists(ctx)
+	if err == nil && !ok {
+		err = &Error{Class: ErrQueueNotFound, Err: errors.New("subscription not found")}
+	}
+	if err != nil {
+		return &VerifyError{Resource: "pubsub subscription " + s.sub.ID(), Err: classify(err)}
+	}
+	return nil
+}
+
+// Stop will stop receiving and wait for the returned channel to close.
+// Messages handed out but not yet done will be redelivered once their
+// acknowledgement deadline passes.
+func (s *GCPSubscriber) Stop() error {
+	s.mu.Lock()
+	cancel, done := s.cancel, s.done
+	s.cancel = nil
+	s.mu.Unlock()
+	if cancel == nil {
+		return errors.New("subscriber is not running")
+	}
+	cancel()
+	<-done
+	return nil
+}
@@ -0,0 +1,222 @@
+package pubsub
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+
+	"github.com/NYTimes/gizmo/backoff"
+)
+
+// IdempotencyTokenAttribute is the message attribute that carries the
+// idempotency token of messages published by an IdempotentPublisher to
+// backends that cannot deduplicate on their own.
+const IdempotencyTokenAttribute = "idempotency_token"
+
+var (
+	// DefaultPublishAttempts is how many times an IdempotentPublisher will
+	// attempt a publish if no MaxAttempts is given.
+	DefaultPublishAttempts = 3
+	// DefaultPublishRetryBaseDelay is the initial backoff between publish
+	// attempts if no RetryBaseDelay is given.
+	DefaultPublishRetryBaseDelay = 100 * time.Millisecond
+	// DefaultPublishRetryMaxDelay is the cap on the backoff between publish
+	// attempts if no RetryMaxDelay is given.
+	DefaultPublishRetryMaxDelay = 2 * time.Second
+)
+
+type (
+	// PublishTokenStore persists the idempotency tokens of completed
+	// publishes.
+	PublishTokenStore interface {
+		// Completed will return true if the token has been marked complete.
+		Completed(token string) (bool, error)
+		// Complete will mark the token as complete.
+		Complete(token string) error
+	}
+
+	// DeduplicatingPublisher is a Publisher that can have the backend
+	// drop duplicate messages sent with the same token, like the
+	// SNSPublisher with a FIFO topic.
+	DeduplicatingPublisher interface {
+		Publisher
+		PublishDeduplicated(key string, m []byte, token string) error
+	}
+
+	// IdempotentPublisher is a Publisher that retries failed publishes
+	// without sending duplicates. Each logical publish has a token that is
+	// marked complete in a PublishTokenStore once it has been sent, and
+	// publishes whose token is already complete are skipped. Since an attempt
+	// that times out may have been sent anyway, retries pass the token on to
+	// the underlying Publisher: a DeduplicatingPublisher will have the backend
+	// drop the duplicate, and an AttributePublisher will send the token in the
+	// IdempotencyTokenAttribute for subscribers to check.
+	IdempotentPublisher struct {
+		// MaxAttempts is how many times each publish will be attempted.
+		// Defaults to DefaultPublishAttempts.
+		MaxAttempts int
+		// RetryBaseDelay is the initial backoff between attempts. Defaults
+		// to DefaultPublishRetryBaseDelay.
+		RetryBaseDelay time.Duration
+		// RetryMaxDelay is the cap on the backoff between attempts. Defaults
+		// to DefaultPublishRetryMaxDelay.
+		RetryMaxDelay time.Duration
+		// Backoff is the strategy used to wait between attempts. If nil,
+		// exponential backoff between RetryBaseDelay and RetryMaxDelay is used.
+		Backoff backoff.Strategy
+
+		pub    Publisher
+		tokens PublishTokenStore
+	}
+)
+
+// NewIdempotentPublisher will return an IdempotentPublisher that publishes
+// to pub and keeps tokens in the given store.
+func NewIdempotentPublisher(pub Publisher, tokens PublishTokenStore) *IdempotentPublisher {
+	return &IdempotentPublisher{pub: pub, tokens: tokens}
+}
+
+// Publish will marshal the proto message and publish it with a token made
+// from the key and body, like PublishRaw.
+func (p *IdempotentPublisher) Publish(key string, m proto.Message) error {
+	mb, err := proto.Marshal(m)
+	if err != nil {
+		return err
+	}
+	return p.PublishRaw(key, mb)
+}
+
+// PublishRaw will publish the byte array with a token made from a hash of the
+// key and body, so identical messages will only be published once. Use
+// PublishToken to publish with a token that identifies the logical event.
+func (p *IdempotentPublisher) PublishRaw(key string, m []byte) error {
+	return p.PublishToken(contentToken(key, m), key, m)
+}
+
+// contentToken will return a token made from a hash of the key and body.
+func contentToken(key string, m []byte) string {
+	h := sha256.New()
+	h.Write([]byte(key))
+	h.Write([]byte{0})
+	h.Write(m)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// PublishToken will publish the byte array unless the token has already been
+// marked complete, retrying failed attempts with backoff.
+func (p *IdempotentPublisher) PublishToken(token, key string, m []byte) error {
+	done, err := p.tokens.Completed(token)
+	if err != nil {
+		return err
+	}
+	if done {
+		return nil
+	}
+
+	attempts := p.MaxAttempts
+	if attempts < 1 {
+		attempts = DefaultPublishAttempts
+	}
+	strategy := p.Backoff
+	if strategy == nil {
+		base := p.RetryBaseDelay
+		if base == 0 {
+			base = DefaultPublishRetryBaseDelay
+		}
+		max := p.RetryMaxDelay
+		if max == 0 {
+			max = DefaultPublishRetryMaxDelay
+		}
+		strategy = backoff.Exponential{Base: base, Max: max}
+	}
+	for attempt := 1; ; attempt++ {
+		if err = p.publish(token, key, m); err == nil {
+			return p.tokens.Complete(token)
+		}
+		if attempt >= attempts {
+			return err
+		}
+		Log.Warnf("unable to publish message with token %s, retrying: %s", token, err)
+		time.Sleep(strategy.Delay(attempt))
+	}
+}
+
+func (p *IdempotentPublisher) publish(token, key string, m []byte) error {
+	switch pub := p.pub.(type) {
+	case DeduplicatingPublisher:
+		return pub.PublishDeduplicated(key, m, token)
+	case AttributePublisher:
+		return pub.PublishAttributes(key, m, map[string]string{IdempotencyTokenAttribute: token})
+	}
+	return p.pub.PublishRaw(key, m)
+}
+
+// MemoryPublishTokenStore is a PublishTokenStore that keeps tokens in memory
+// for the given TTL, for tests and for retries within a single process.
+type MemoryPublishTokenStore struct {
+	TTL time.Duration
+
+	mu     sync.Mutex
+	tokens map[string]time.Time
+}
+
+// Completed will return true if the token was completed within the TTL.
+func (m *MemoryPublishTokenStore) Completed(token string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	at, ok := m.tokens[token]
+	return ok && (m.TTL == 0 || time.Since(at) < m.TTL), nil
+}
+
+// Complete will mark the token as complete and drop any expired tokens.
+func (m *MemoryPublishTokenStore) Complete(token string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.tokens == nil {
+		m.tokens = map[string]time.Time{}
+	}
+	if m.TTL > 0 {
+		for t, at := range m.tokens {
+			if time.Since(at) >= m.TTL {
+				delete(m.tokens, t)
+			}
+		}
+	}
+	m.tokens[token] = time.Now()
+	return nil
+}
+
+// PostgresPublishTokenStore is a PublishTokenStore that keeps tokens in a
+// Postgres table with a text 'token' primary key and a 'completed_at'
+// timestamp column, which can be used to clean up old tokens.
+type PostgresPublishTokenStore struct {
+	db    *sql.DB
+	table string
+}
+
+// NewPostgresPublishTokenStore will return a PostgresPublishTokenStore that
+// uses the given table.
+func NewPostgresPublishTokenStore(db *sql.DB, table string) *PostgresPublishTokenStore {
+	return &PostgresPublishTokenStore{db: db, table: table}
+}
+
+// Completed will return true if the token is in the table.
+func (p *PostgresPublishTokenStore) Completed(token string) (bool, error) {
+	var found int
+	err := p.db.QueryRow(fmt.Sprintf("SELECT 1 FROM %s WHERE token = $1", p.table), token).Scan(&found)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// Complete will insert the token into the table.
+func (p *PostgresPublishTokenStore) Complete(token string) error {
+	_, err := p.db.Exec(fmt.Sprintf("INSERT INTO %s (token, completed_at) VALUES ($1, now()) ON CONFLICT (token) DO NOTHING", p.table), token)
+	return err
+}
@@ -0,0 +1,102 @@
+package pubsub
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+)
+
+type testFlakyPublisher struct {
+	failures int
+	sent     []string
+	tokens   []string
+}
+
+func (p *testFlakyPublisher) Publish(key string, m proto.Message) error {
+	return nil
+}
+
+func (p *testFlakyPublisher) PublishRaw(key string, m []byte) error {
+	return p.PublishDeduplicated(key, m, "")
+}
+
+func (p *testFlakyPublisher) PublishDeduplicated(key string, m []byte, token string) error {
+	p.tokens = append(p.tokens, token)
+	if p.failures > 0 {
+		p.failures--
+		return errors.New("timeout")
+	}
+	p.sent = append(p.sent, string(m))
+	return nil
+}
+
+func TestIdempotentPublisher(t *testing.T) {
+	pub := &testFlakyPublisher{failures: 2}
+	store := &MemoryPublishTokenStore{}
+	ip := NewIdempotentPublisher(pub, store)
+	ip.RetryBaseDelay = time.Millisecond
+
+	if err := ip.PublishToken("abc", "key", []byte("hello")); err != nil {
+		t.Fatalf("expected no error, got %s", err)
+	}
+	if len(pub.tokens) != 3 {
+		t.Errorf("expected 3 attempts, got %d", len(pub.tokens))
+	}
+	for _, tok := range pub.tokens {
+		if tok != "abc" {
+			t.Errorf("expected token 'abc' on every attempt, got %q", tok)
+		}
+	}
+	if done, _ := store.Completed("abc"); !done {
+		t.Error("expected token to be marked complete")
+	}
+
+	// a retry of the same logical publish should be skipped
+	if err := ip.PublishToken("abc", "key", []byte("hello")); err != nil {
+		t.Fatalf("expected no error, got %s", err)
+	}
+	if len(pub.sent) != 1 {
+		t.Errorf("expected 1 message sent, got %d", len(pub.sent))
+	}
+}
+
+func TestIdempotentPublisherGiveUp(t *testing.T) {
+	pub := &testFlakyPublisher{failures: 5}
+	store := &MemoryPublishTokenStore{}
+	ip := NewIdempotentPublisher(pub, store)
+	ip.MaxAttempts = 2
+	ip.RetryBaseDelay = time.Millisecond
+
+	if err := ip.PublishRaw("key", []byte("hello")); err == nil {
+		t.Fatal("expected an error, got none")
+	}
+	if len(pub.tokens) != 2 {
+		t.Errorf("expected 2 attempts, got %d", len(pub.tokens))
+	}
+	if done, _ := store.Completed(pub.tokens[0]); done {
+		t.Error("expected token not to be marked complete")
+	}
+}
+
+func TestIdempotentPublisherAttributes(t *testing.T) {
+	pub := &testAttributePublisher{}
+	ip := NewIdempotentPublisher(pub, &MemoryPublishTokenStore{})
+
+	if err := ip.PublishToken("abc", "key", []byte("hello")); err != nil {
+		t.Fatalf("expected no error, got %s", err)
+	}
+	if got := pub.attrs[IdempotencyTokenAttribute]; got != "abc" {
+		t.Errorf("expected token attribute 'abc', got %q", got)
+	}
+}
+
+func TestMemoryPublishTokenStoreTTL(t *testing.T) {
+	store := &MemoryPublishTokenStore{TTL: time.Millisecond}
+	store.Complete("abc")
+	time.Sleep(5 * time.Millisecond)
+	if done, _ := store.Completed("abc"); done {
+		t.Error("expected token to expire")
+	}
+}
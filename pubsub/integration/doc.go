@@ -0,0 +1,31 @@
+/*
+Package integration runs real end-to-end pubsub tests against SNS and SQS
+compatible services in a container, so downstream repos can exercise their
+publishers and subscribers in CI without an AWS account.
+
+Start will run localstack, or another image like ElasticMQ or goaws, via
+testcontainers. Provision will then create the topics, queues, dead letter
+queues and subscriptions implied by a service's configs, as described by the
+infra package, and point the configs at the container:
+
+	func TestCats(t *testing.T) {
+	    ctx := context.Background()
+	    env, err := integration.Start(ctx, integration.Options{})
+	    if err != nil {
+	        t.Fatal(err)
+	    }
+	    defer env.Terminate(ctx)
+
+	    _, snsCfg, sqsCfg, _, _, _ := config.LoadAWSFromEnv()
+	    if err := env.Provision(snsCfg, sqsCfg); err != nil {
+	        t.Fatal(err)
+	    }
+	    pub, _ := pubsub.NewSNSPublisher(snsCfg)
+	    sub, _ := pubsub.NewSQSSubscriber(sqsCfg)
+	    ...
+	}
+
+Tests using this package need Docker, so they are usually kept behind a
+build tag or an environment variable.
+*/
+package integration
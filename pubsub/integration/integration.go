@@ -0,0 +1,271 @@
+package integration
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/sns"
+	"github.com/aws/aws-sdk-go/service/sns/snsiface"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/aws/aws-sdk-go/service/sqs/sqsiface"
+	"github.com/docker/go-connections/nat"
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/wait"
+	"golang.org/x/net/context"
+
+	"github.com/NYTimes/gizmo/config"
+	"github.com/NYTimes/gizmo/infra"
+)
+
+// The defaults used by Start for empty Options.
+const (
+	// DefaultImage is the localstack image, which serves both SNS and SQS.
+	DefaultImage = "localstack/localstack:3"
+	// DefaultPort is the port localstack serves every API on.
+	DefaultPort = "4566/tcp"
+	// DefaultRegion is the region resources are provisioned in.
+	DefaultRegion = "us-east-1"
+)
+
+// The credentials configs are given by Provision. Local services accept any.
+const (
+	AccessKey = "test"
+	SecretKey = "test"
+)
+
+// Options configures the container run by Start.
+type Options struct {
+	// Image is the container image. Defaults to DefaultImage. ElasticMQ
+	// ('softwaremill/elasticmq-native', port '9324/tcp') serves only SQS
+	// and goaws ('pafortin/goaws', port '4100/tcp') serves both.
+	Image string
+	// Port is the port the image serves SNS and SQS on. Defaults to
+	// DefaultPort.
+	Port string
+	// Region is the region resources are provisioned in. Defaults to
+	// DefaultRegion.
+	Region string
+	// Env is passed to the container, like {"SERVICES": "sns,sqs"} to
+	// limit the services localstack starts.
+	Env map[string]string
+	// StartupTimeout is how long to wait for the services to accept
+	// requests. Defaults to 2 minutes.
+	StartupTimeout time.Duration
+}
+
+// Environment is a running container of SNS and SQS compatible services.
+type Environment struct {
+	// Endpoint is the URL of the container's SNS and SQS APIs.
+	Endpoint string
+	// Region is the region resources are provisioned in.
+	Region string
+
+	container testcontainers.Container
+	sns       snsiface.SNSAPI
+	sqs       sqsiface.SQSAPI
+}
+
+// Start will run a container with the given options and wait for it to
+// accept requests.
+func Start(ctx context.Context, opts Options) (*Environment, error) {
+	if opts.Image == "" {
+		opts.Image = DefaultImage
+	}
+	if opts.Port == "" {
+		opts.Port = DefaultPort
+	}
+	if opts.Region == "" {
+		opts.Region = DefaultRegion
+	}
+	if opts.StartupTimeout == 0 {
+		opts.StartupTimeout = 2 * time.Minute
+	}
+
+	c, err := testcontainers.GenericContainer(ctx, testcontainers.GenericContainerRequest{
+		ContainerRequest: testcontainers.ContainerRequest{
+			Image:        opts.Image,
+			ExposedPorts: []string{opts.Port},
+			Env:          opts.Env,
+			WaitingFor:   wait.ForListeningPort(nat.Port(opts.Port)).WithStartupTimeout(opts.StartupTimeout),
+		},
+		Started: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+	e := &Environment{Region: opts.Region, container: c}
+	host, err := c.Host(ctx)
+	if err != nil {
+		c.Terminate(ctx)
+		return nil, err
+	}
+	port, err := c.MappedPort(ctx, nat.Port(opts.Port))
+	if err != nil {
+		c.Terminate(ctx)
+		return nil, err
+	}
+	e.Endpoint = "http://" + host + ":" + port.Port()
+
+	sess := session.New(&aws.Config{
+		Credentials: credentials.NewStaticCredentials(AccessKey, SecretKey, ""),
+		Region:      &e.Region,
+		Endpoint:    &e.Endpoint,
+	})
+	e.sns = sns.New(sess)
+	e.sqs = sqs.New(sess)
+
+	// the port may be open before the services are ready
+	ctx, cancel := context.WithTimeout(ctx, opts.StartupTimeout)
+	defer cancel()
+	for {
+		if _, err = e.sqs.ListQueues(&sqs.ListQueuesInput{}); err == nil {
+			return e, nil
+		}
+		select {
+		case <-ctx.Done():
+			c.Terminate(context.Background())
+			return nil, err
+		case <-time.After(250 * time.Millisecond):
+		}
+	}
+}
+
+// Provision will create the resources implied by the configs, either of
+// which may be nil, and point the configs at them.
+func (e *Environment) Provision(snsCfg *config.SNS, sqsCfg *config.SQS) error {
+	m := infra.NewManifest(snsCfg, sqsCfg)
+	if err := e.ProvisionManifest(m); err != nil {
+		return err
+	}
+	if snsCfg != nil {
+		// the topic's ARN is in the container's account
+		topic, err := e.createTopic(arnName(snsCfg.Topic))
+		if err != nil {
+			return err
+		}
+		e.wire(&snsCfg.AWS)
+		snsCfg.Endpoint = e.Endpoint
+		snsCfg.Topic = topic
+		snsCfg.FailoverTopics = nil
+	}
+	if sqsCfg != nil {
+		e.wire(&sqsCfg.AWS)
+		sqsCfg.Endpoint = e.Endpoint
+		sqsCfg.FailoverRegions = nil
+	}
+	return nil
+}
+
+func (e *Environment) wire(cfg *config.AWS) {
+	cfg.AccessKey = AccessKey
+	cfg.SecretKey = SecretKey
+	cfg.Region = e.Region
+	cfg.UseFIPSEndpoint = false
+	cfg.UseDualStackEndpoint = false
+}
+
+// ProvisionManifest will create the topics, queues and subscriptions of
+// the manifest. Subscriptions to topics of other services will create the
+// topic too, so messages can be published to it.
+func (e *Environment) ProvisionManifest(m *infra.Manifest) error {
+	for _, t := range m.Topics {
+		if _, err := e.createTopic(t.Name); err != nil {
+			return err
+		}
+	}
+	for _, q := range m.Queues {
+		attrs := map[string]*string{}
+		if q.DeadLetterQueue != "" {
+			dlq, err := e.createQueue(q.DeadLetterQueue, nil)
+			if err != nil {
+				return err
+			}
+			policy, err := json.Marshal(map[string]string{
+				"deadLetterTargetArn": dlq,
+				"maxReceiveCount":     strconv.Itoa(q.MaxReceiveCount),
+			})
+			if err != nil {
+				return err
+			}
+			attrs[sqs.QueueAttributeNameRedrivePolicy] = aws.String(string(policy))
+		}
+		if _, err := e.createQueue(q.Name, attrs); err != nil {
+			return err
+		}
+	}
+	for _, s := range m.Subscriptions {
+		topic, err := e.createTopic(arnName(s.TopicARN))
+		if err != nil {
+			return err
+		}
+		queue, err := e.queueARN(s.Queue)
+		if err != nil {
+			return err
+		}
+		_, err = e.sns.Subscribe(&sns.SubscribeInput{
+			TopicArn: &topic,
+			Protocol: aws.String("sqs"),
+			Endpoint: &queue,
+			Attributes: map[string]*string{
+				"RawMessageDelivery": aws.String(strconv.FormatBool(s.RawMessageDelivery)),
+			},
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Terminate will stop and remove the container.
+func (e *Environment) Terminate(ctx context.Context) error {
+	return e.container.Terminate(ctx)
+}
+
+// createTopic will create the topic, if it does not exist, and return its
+// ARN.
+func (e *Environment) createTopic(name string) (string, error) {
+	out, err := e.sns.CreateTopic(&sns.CreateTopicInput{Name: &name})
+	if err != nil {
+		return "", err
+	}
+	return aws.StringValue(out.TopicArn), nil
+}
+
+// createQueue will create the queue, if it does not exist, and return its
+// ARN.
+func (e *Environment) createQueue(name string, attrs map[string]*string) (string, error) {
+	_, err := e.sqs.CreateQueue(&sqs.CreateQueueInput{
+		QueueName:  &name,
+		Attributes: attrs,
+	})
+	if err != nil {
+		return "", err
+	}
+	return e.queueARN(name)
+}
+
+func (e *Environment) queueARN(name string) (string, error) {
+	url, err := e.sqs.GetQueueUrl(&sqs.GetQueueUrlInput{QueueName: &name})
+	if err != nil {
+		return "", err
+	}
+	out, err := e.sqs.GetQueueAttributes(&sqs.GetQueueAttributesInput{
+		QueueUrl:       url.QueueUrl,
+		AttributeNames: []*string{aws.String(sqs.QueueAttributeNameQueueArn)},
+	})
+	if err != nil {
+		return "", err
+	}
+	return aws.StringValue(out.Attributes[sqs.QueueAttributeNameQueueArn]), nil
+}
+
+// arnName will return the resource name at the end of an ARN.
+func arnName(arn string) string {
+	return arn[strings.LastIndex(arn, ":")+1:]
+}
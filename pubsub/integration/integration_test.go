@@ -0,0 +1,106 @@
+package integration
+
+import (
+	"os"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"golang.org/x/net/context"
+
+	"github.com/NYTimes/gizmo/config"
+	"github.com/NYTimes/gizmo/pubsub"
+	"github.com/NYTimes/gizmo/pubsub/pubsubtest"
+)
+
+func TestProvision(t *testing.T) {
+	if os.Getenv("PUBSUB_INTEGRATION") == "" {
+		t.Skip("set PUBSUB_INTEGRATION to run tests against a container")
+	}
+	ctx := context.Background()
+	env, err := Start(ctx, Options{})
+	if err != nil {
+		t.Fatalf("expected no error, got %s", err)
+	}
+	defer env.Terminate(ctx)
+
+	snsCfg := &config.SNS{Topic: "arn:aws:sns:us-east-1:123456789012:cats"}
+	sqsCfg := &config.SQS{
+		QueueName:           "cats",
+		DeadLetterQueueName: "cats-dlq",
+		SubscribedTopic:     snsCfg.Topic,
+	}
+	if err := env.Provision(snsCfg, sqsCfg); err != nil {
+		t.Fatalf("expected no error, got %s", err)
+	}
+	if sqsCfg.Endpoint != env.Endpoint {
+		t.Errorf("expected the SQS config to use %s, got %s", env.Endpoint, sqsCfg.Endpoint)
+	}
+
+	pub, err := pubsub.NewSNSPublisher(snsCfg)
+	if err != nil {
+		t.Fatalf("expected no error, got %s", err)
+	}
+	sub, err := pubsub.NewSQSSubscriber(sqsCfg)
+	if err != nil {
+		t.Fatalf("expected no error, got %s", err)
+	}
+	if err := pubsub.Verify(pub, sub); err != nil {
+		t.Fatalf("expected no error, got %s", err)
+	}
+
+	if err := pub.PublishRaw("key", []byte("meow")); err != nil {
+		t.Fatalf("expected no error, got %s", err)
+	}
+	msg := <-sub.Start()
+	if got := string(msg.Message()); got != "meow" {
+		t.Errorf("expected message 'meow', got %q", got)
+	}
+	msg.Done()
+	if err := sub.Stop(); err != nil {
+		t.Errorf("expected no error, got %s", err)
+	}
+}
+
+func TestConformance(t *testing.T) {
+	if os.Getenv("PUBSUB_INTEGRATION") == "" {
+		t.Skip("set PUBSUB_INTEGRATION to run tests against a container")
+	}
+	ctx := context.Background()
+	env, err := Start(ctx, Options{})
+	if err != nil {
+		t.Fatalf("expected no error, got %s", err)
+	}
+	defer env.Terminate(ctx)
+
+	pubsubtest.RunPublisherTests(t, func(t *testing.T, test string) *pubsubtest.Backend {
+		// a fresh topic and queue for each test
+		name := "conformance-" + test
+		snsCfg := &config.SNS{Topic: "arn:aws:sns:us-east-1:123456789012:" + name}
+		sqsCfg := &config.SQS{QueueName: name, SubscribedTopic: snsCfg.Topic}
+		if err := env.Provision(snsCfg, sqsCfg); err != nil {
+			t.Fatalf("expected no error, got %s", err)
+		}
+		pub, err := pubsub.NewSNSPublisher(snsCfg)
+		if err != nil {
+			t.Fatalf("expected no error, got %s", err)
+		}
+		sub, err := pubsub.NewSQSSubscriber(sqsCfg)
+		if err != nil {
+			t.Fatalf("expected no error, got %s", err)
+		}
+		return &pubsubtest.Backend{
+			Publisher:  pub,
+			Subscriber: sub,
+			// receiving from a deleted queue fails
+			Break: func() {
+				url, err := env.sqs.GetQueueUrl(&sqs.GetQueueUrlInput{QueueName: &name})
+				if err != nil {
+					t.Fatalf("expected no error, got %s", err)
+				}
+				if _, err = env.sqs.DeleteQueue(&sqs.DeleteQueueInput{QueueUrl: url.QueueUrl}); err != nil {
+					t.Fatalf("expected no error, got %s", err)
+				}
+			},
+		}
+	})
+}
@@ -1,8 +1,14 @@
+// +build !nokafka
+
 package pubsub
 
 import (
 	"errors"
 	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/NYTimes/gizmo/config"
 
@@ -51,14 +57,90 @@ func (p *KafkaPublisher) Publish(key string, m proto.Message) error {
 
 // PublishRaw will emit the byte array to the Kafka topic.
 func (p *KafkaPublisher) PublishRaw(key string, m []byte) error {
+	_, err := p.PublishRawResult(key, m)
+	return err
+}
+
+// PublishRawResult will emit the byte array to the Kafka topic like
+// PublishRaw and return its position as the message ID, in the form
+// 'topic/partition/offset', with the offset as the sequence number.
+func (p *KafkaPublisher) PublishRawResult(key string, m []byte) (*PublishResult, error) {
+	msg := &sarama.ProducerMessage{
+		Topic: p.topic,
+		Key:   sarama.StringEncoder(key),
+		Value: sarama.ByteEncoder(m),
+	}
+	partition, offset, err := p.producer.SendMessage(msg)
+	if err != nil {
+		return nil, classify(err)
+	}
+	seq := strconv.FormatInt(offset, 10)
+	return &PublishResult{
+		MessageID:      p.topic + "/" + strconv.Itoa(int(partition)) + "/" + seq,
+		SequenceNumber: seq,
+		Timestamp:      time.Now(),
+	}, nil
+}
+
+// PublishRawBatch will emit the byte arrays to the Kafka topic in a single
+// request and return the result or error of each.
+func (p *KafkaPublisher) PublishRawBatch(keys []string, msgs [][]byte) ([]*PublishResult, []error) {
+	pms := make([]*sarama.ProducerMessage, len(msgs))
+	for i, m := range msgs {
+		pms[i] = &sarama.ProducerMessage{
+			Topic: p.topic,
+			Key:   sarama.StringEncoder(keys[i]),
+			Value: sarama.ByteEncoder(m),
+		}
+	}
+	results := make([]*PublishResult, len(msgs))
+	errs := make([]error, len(msgs))
+	if err := p.producer.SendMessages(pms); err != nil {
+		perrs, ok := err.(sarama.ProducerErrors)
+		if !ok {
+			for i := range errs {
+				errs[i] = classify(err)
+			}
+			return results, errs
+		}
+		index := make(map[*sarama.ProducerMessage]int, len(pms))
+		for i, pm := range pms {
+			index[pm] = i
+		}
+		for _, perr := range perrs {
+			if i, ok := index[perr.Msg]; ok {
+				errs[i] = classify(perr.Err)
+			}
+		}
+	}
+	now := time.Now()
+	for i, pm := range pms {
+		if errs[i] != nil {
+			continue
+		}
+		seq := strconv.FormatInt(pm.Offset, 10)
+		results[i] = &PublishResult{
+			MessageID:      p.topic + "/" + strconv.Itoa(int(pm.Partition)) + "/" + seq,
+			SequenceNumber: seq,
+			Timestamp:      now,
+		}
+	}
+	return results, errs
+}
+
+// PublishAttributes will emit the byte array to the Kafka topic with the
+// attributes as record headers.
+func (p *KafkaPublisher) PublishAttributes(key string, m []byte, attrs map[string]string) error {
 	msg := &sarama.ProducerMessage{
 		Topic: p.topic,
 		Key:   sarama.StringEncoder(key),
 		Value: sarama.ByteEncoder(m),
 	}
-	// TODO: do something with this partition/offset values
+	for k, v := range attrs {
+		msg.Headers = append(msg.Headers, sarama.RecordHeader{Key: []byte(k), Value: []byte(v)})
+	}
 	_, _, err := p.producer.SendMessage(msg)
-	return err
+	return classify(err)
 }
 
 // Stop will close the pub connection.
@@ -76,6 +158,11 @@ type (
 
 		offset          func() int64
 		broadcastOffset func(int64)
+		checkpoints     *checkpointer
+		// headers are the names of the headers decoded into attributes
+		headers []string
+		// client is closed on Stop if the consumer was made from it
+		client sarama.Client
 
 		kerr error
 
@@ -87,6 +174,10 @@ type (
 	KafkaSubMessage struct {
 		message         *sarama.ConsumerMessage
 		broadcastOffset func(int64)
+		headers         []string
+
+		attrsOnce sync.Once
+		attrs     map[string]string
 	}
 )
 
@@ -95,6 +186,41 @@ func (m *KafkaSubMessage) Message() []byte {
 	return m.message.Value
 }
 
+// Attributes will return the message's record headers, limited to the
+// config's HeaderNames if any were given. They are decoded once, on the
+// first call.
+func (m *KafkaSubMessage) Attributes() map[string]string {
+	m.attrsOnce.Do(func() {
+		m.attrs = make(map[string]string, len(m.message.Headers))
+		for _, h := range m.message.Headers {
+			if key := string(h.Key); matchHeaderName(m.headers, key) {
+				m.attrs[key] = string(h.Value)
+			}
+		}
+	})
+	return m.attrs
+}
+
+// matchHeaderName will return true if the header should be decoded
+// according to the names, which may end in '.*' to match a prefix.
+func matchHeaderName(names []string, key string) bool {
+	if len(names) == 0 {
+		return true
+	}
+	for _, name := range names {
+		if name == key || (strings.HasSuffix(name, ".*") && strings.HasPrefix(key, name[:len(name)-1])) {
+			return true
+		}
+	}
+	return false
+}
+
+// SentAt will return the message's timestamp, which is zero for brokers
+// older than Kafka 0.10.
+func (m *KafkaSubMessage) SentAt() time.Time {
+	return m.message.Timestamp
+}
+
 // Done will emit the message's offset.
 func (m *KafkaSubMessage) Done() error {
 	m.broadcastOffset(m.message.Offset)
@@ -110,6 +236,7 @@ func NewKafkaSubscriber(cfg *config.Kafka, offsetProvider func() int64, offsetBr
 		offset:          offsetProvider,
 		broadcastOffset: offsetBroadcast,
 		partition:       cfg.Partition,
+		headers:         cfg.HeaderNames,
 		stop:            make(chan chan error, 1),
 	}
 
@@ -128,6 +255,76 @@ func NewKafkaSubscriber(cfg *config.Kafka, offsetProvider func() int64, offsetBr
 	return s, err
 }
 
+// NewKafkaCheckpointSubscriber will initiate an experimental Kafka consumer
+// that saves its progress to the given CheckpointStore every
+// CheckpointInterval and when it is stopped, under the key
+// '<topic>/<partition>'. Where it starts consuming is set by the config's
+// ResumeFrom. Messages may be handled out of order, but the saved checkpoint
+// will only advance past a message once it is done.
+func NewKafkaCheckpointSubscriber(cfg *config.Kafka, store CheckpointStore) (*KafkaSubscriber, error) {
+	if len(cfg.BrokerHosts) == 0 {
+		return nil, errors.New("at least 1 broker host is required")
+	}
+	if len(cfg.Topic) == 0 {
+		return nil, errors.New("topic name is required")
+	}
+	stream := cfg.Topic + "/" + strconv.Itoa(int(cfg.Partition))
+
+	sconfig := sarama.NewConfig()
+	sconfig.Consumer.Return.Errors = true
+	client, err := sarama.NewClient(cfg.BrokerHosts, sconfig)
+	if err != nil {
+		return nil, err
+	}
+	start, err := kafkaStartOffset(client, cfg, store, stream)
+	if err != nil {
+		client.Close()
+		return nil, err
+	}
+	cnsmr, err := sarama.NewConsumerFromClient(client)
+	if err != nil {
+		client.Close()
+		return nil, err
+	}
+
+	cp := newCheckpointer(store, stream, cfg.CheckpointInterval)
+	return &KafkaSubscriber{
+		cnsmr:           cnsmr,
+		topic:           cfg.Topic,
+		partition:       cfg.Partition,
+		offset:          func() int64 { return start },
+		broadcastOffset: cp.finish,
+		checkpoints:     cp,
+		headers:         cfg.HeaderNames,
+		client:          client,
+		stop:            make(chan chan error, 1),
+	}, nil
+}
+
+// kafkaStartOffset will resolve the config's ResumeFrom to an offset.
+func kafkaStartOffset(client sarama.Client, cfg *config.Kafka, store CheckpointStore, stream string) (int64, error) {
+	switch cfg.ResumeFrom {
+	case "", "checkpoint":
+		cp, ok, err := store.Load(stream)
+		if err != nil {
+			return 0, err
+		}
+		if !ok {
+			return sarama.OffsetNewest, nil
+		}
+		return cp + 1, nil
+	case "latest":
+		return sarama.OffsetNewest, nil
+	case "earliest":
+		return sarama.OffsetOldest, nil
+	}
+	t, err := time.Parse(time.RFC3339, cfg.ResumeFrom)
+	if err != nil {
+		return 0, errors.New("invalid kafka resume from: " + cfg.ResumeFrom)
+	}
+	return client.GetOffset(cfg.Topic, cfg.Partition, t.UnixNano()/int64(time.Millisecond))
+}
+
 // Start will start consuming message on the Kafka topic
 // partition and emit any messages to the returned channel.
 // On start up, it will call the offset func provider to the subscriber
@@ -159,9 +356,13 @@ func (s *KafkaSubscriber) Start() <-chan SubscriberMessage {
 				s.kerr = kerr
 				return
 			case msg = <-msgs:
+				if s.checkpoints != nil {
+					s.checkpoints.track(msg.Offset)
+				}
 				output <- &KafkaSubMessage{
 					message:         msg,
 					broadcastOffset: s.broadcastOffset,
+					headers:         s.headers,
 				}
 			}
 		}
@@ -180,7 +381,30 @@ func (s *KafkaSubscriber) Stop() error {
 	if err != nil {
 		return err
 	}
-	return s.cnsmr.Close()
+	if s.checkpoints != nil {
+		if err = s.checkpoints.close(); err != nil {
+			return err
+		}
+	}
+	if err = s.cnsmr.Close(); err != nil || s.client == nil {
+		return err
+	}
+	return s.client.Close()
+}
+
+// Verify will check that the subscriber's topic and partition exist.
+func (s *KafkaSubscriber) Verify() error {
+	resource := "kafka topic " + s.topic + " partition " + strconv.Itoa(int(s.partition))
+	partitions, err := s.cnsmr.Partitions(s.topic)
+	if err != nil {
+		return &VerifyError{Resource: resource, Err: classify(err)}
+	}
+	for _, p := range partitions {
+		if p == s.partition {
+			return nil
+		}
+	}
+	return &VerifyError{Resource: resource, Err: &Error{Class: ErrQueueNotFound, Err: errors.New("partition not found")}}
 }
 
 // Err will contain any  errors that occurred during
@@ -0,0 +1,45 @@
+// +build !nokafka
+
+package pubsub
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/Shopify/sarama"
+)
+
+func TestKafkaSubMessageAttributes(t *testing.T) {
+	msg := &sarama.ConsumerMessage{
+		Headers: []*sarama.RecordHeader{
+			{Key: []byte("tenant"), Value: []byte("cats")},
+			{Key: []byte("trace.id"), Value: []byte("abc")},
+			{Key: []byte("trace.span"), Value: []byte("def")},
+			{Key: []byte("payload-schema"), Value: []byte("v2")},
+		},
+	}
+
+	tests := []struct {
+		headers []string
+		want    map[string]string
+	}{
+		{
+			nil,
+			map[string]string{"tenant": "cats", "trace.id": "abc", "trace.span": "def", "payload-schema": "v2"},
+		},
+		{
+			[]string{"tenant", "trace.*"},
+			map[string]string{"tenant": "cats", "trace.id": "abc", "trace.span": "def"},
+		},
+		{
+			[]string{"missing"},
+			map[string]string{},
+		},
+	}
+	for _, test := range tests {
+		m := &KafkaSubMessage{message: msg, headers: test.headers}
+		if got := m.Attributes(); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("expected attributes %v with headers %v, got %v", test.want, test.headers, got)
+		}
+	}
+}
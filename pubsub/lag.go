@@ -0,0 +1,212 @@
+package pubsub
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+	"golang.org/x/net/context"
+)
+
+// SentTimestampAttribute is the attribute holding when a message was sent,
+// in milliseconds since the epoch, as set by SQS.
+const SentTimestampAttribute = "SentTimestamp"
+
+var (
+	// DefaultLagInterval is how often a LagMonitor will check the age of
+	// the messages it has seen if no Interval is given.
+	DefaultLagInterval = time.Minute
+	// DefaultLagPercentile is the percentile of message age a LagMonitor
+	// will hold to its SLA if no Percentile is given.
+	DefaultLagPercentile = 0.95
+)
+
+// SentMessage is a SubscriberMessage that knows when it was sent.
+type SentMessage interface {
+	SubscriberMessage
+	// SentAt will return when the message was sent, or the zero time if
+	// it is unknown.
+	SentAt() time.Time
+}
+
+// LagAlarm describes why a LagMonitor has raised an alarm.
+type LagAlarm struct {
+	// Age is the percentile age of the messages seen in the last interval.
+	Age time.Duration
+	// SLA is the age the messages were expected to stay under.
+	SLA time.Duration
+	// Samples is the number of messages seen in the last interval.
+	Samples int
+}
+
+// LagMonitor will track the age of the messages a consumer handles, from
+// when they were sent to when they are processed, and raise an alarm when
+// a percentile of that age exceeds an SLA. This catches slow consumers
+// before their queues back up badly. The age of a message is known if it
+// is a SentMessage or has a SentTimestampAttribute or CloudEvents 'time'
+// attribute.
+//
+// Each Interval, the percentile age of the messages seen since the last
+// check is recorded in the 'pubsub.lag.AGE' metric, in milliseconds. Alarms
+// are logged, counted in the 'pubsub.lag.ALARM' metric and passed to the
+// optional OnAlarm callback. Each alarm is raised once until the age comes
+// back under the SLA.
+type LagMonitor struct {
+	// SLA is the age the Percentile of messages should stay under.
+	SLA time.Duration
+	// Percentile is the fraction of messages, like 0.95, that should be
+	// under the SLA. Defaults to DefaultLagPercentile.
+	Percentile float64
+	// Interval is how often the age of messages is checked. Defaults to
+	// DefaultLagInterval.
+	Interval time.Duration
+	// OnAlarm is an optional callback for each alarm.
+	OnAlarm func(LagAlarm)
+	// MetricsRegistry will override the default metrics registry for the
+	// lag metrics if set.
+	MetricsRegistry metrics.Registry
+
+	mu      sync.Mutex
+	ages    []time.Duration
+	alarmed bool
+
+	stop     chan struct{}
+	stopOnce sync.Once
+	done     chan struct{}
+}
+
+// NewLagMonitor will return a LagMonitor that alarms once the age of
+// messages exceeds sla.
+func NewLagMonitor(sla time.Duration) *LagMonitor {
+	return &LagMonitor{
+		SLA:  sla,
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+}
+
+// Handler will return a MessageHandler that observes each message before
+// passing it to h.
+func (m *LagMonitor) Handler(h MessageHandler) MessageHandler {
+	return func(ctx context.Context, msg SubscriberMessage) error {
+		m.Observe(msg)
+		return h(ctx, msg)
+	}
+}
+
+// Observe will record the age of the message, if it is known.
+func (m *LagMonitor) Observe(msg SubscriberMessage) {
+	sent, ok := sentAt(msg)
+	if !ok {
+		return
+	}
+	age := time.Since(sent)
+	m.mu.Lock()
+	m.ages = append(m.ages, age)
+	m.mu.Unlock()
+}
+
+// Start will begin checking the age of messages in the background.
+func (m *LagMonitor) Start() {
+	interval := m.Interval
+	if interval == 0 {
+		interval = DefaultLagInterval
+	}
+	go func() {
+		defer close(m.done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-m.stop:
+				return
+			case <-ticker.C:
+				m.check()
+			}
+		}
+	}()
+}
+
+// Stop will stop the LagMonitor and wait for it to finish.
+func (m *LagMonitor) Stop() {
+	m.stopOnce.Do(func() {
+		close(m.stop)
+	})
+	<-m.done
+}
+
+// check will compare the percentile age of the messages seen since the
+// last check against the SLA.
+func (m *LagMonitor) check() {
+	m.mu.Lock()
+	ages := m.ages
+	m.ages = nil
+	m.mu.Unlock()
+	if len(ages) == 0 {
+		return
+	}
+
+	p := m.Percentile
+	if p <= 0 || p > 1 {
+		p = DefaultLagPercentile
+	}
+	sort.Sort(durations(ages))
+	age := ages[int(math.Ceil(p*float64(len(ages))))-1]
+	metrics.GetOrRegisterGauge("pubsub.lag.AGE", m.MetricsRegistry).Update(int64(age / time.Millisecond))
+
+	if age <= m.SLA {
+		if m.alarmed {
+			Log.Infof("lag: message age of %s is back under the SLA of %s", age, m.SLA)
+		}
+		m.alarmed = false
+		return
+	}
+	if m.alarmed {
+		return
+	}
+	m.alarmed = true
+	Log.Errorf("lag: message age of %s is over the SLA of %s", age, m.SLA)
+	metrics.GetOrRegisterCounter("pubsub.lag.ALARM", m.MetricsRegistry).Inc(1)
+	if m.OnAlarm != nil {
+		m.OnAlarm(LagAlarm{Age: age, SLA: m.SLA, Samples: len(ages)})
+	}
+}
+
+// sentAt will return when the message was sent, if it is known.
+func sentAt(msg SubscriberMessage) (time.Time, bool) {
+	if smsg, ok := msg.(SentMessage); ok {
+		if t := smsg.SentAt(); !t.IsZero() {
+			return t, true
+		}
+	}
+	if v, ok := attribute(msg, SentTimestampAttribute); ok {
+		if t := parseSentTimestamp(v); !t.IsZero() {
+			return t, true
+		}
+	}
+	if v, ok := attribute(msg, "time"); ok {
+		if t, err := time.Parse(time.RFC3339Nano, v); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// parseSentTimestamp will parse a SentTimestampAttribute value, returning
+// the zero time if it is invalid.
+func parseSentTimestamp(v string) time.Time {
+	ms, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		return time.Time{}
+	}
+	return time.Unix(0, ms*int64(time.Millisecond))
+}
+
+type durations []time.Duration
+
+func (d durations) Len() int           { return len(d) }
+func (d durations) Swap(i, j int)      { d[i], d[j] = d[j], d[i] }
+func (d durations) Less(i, j int) bool { return d[i] < d[j] }
@@ -0,0 +1,76 @@
+package pubsub
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+	"golang.org/x/net/context"
+)
+
+func sentMessage(age time.Duration) SubscriberMessage {
+	ms := time.Now().Add(-age).UnixNano() / int64(time.Millisecond)
+	return &testAttributeMessage{attrs: map[string]string{
+		SentTimestampAttribute: strconv.FormatInt(ms, 10),
+	}}
+}
+
+func TestLagMonitor(t *testing.T) {
+	var alarms []LagAlarm
+	m := NewLagMonitor(time.Minute)
+	m.MetricsRegistry = metrics.NewRegistry()
+	m.OnAlarm = func(a LagAlarm) { alarms = append(alarms, a) }
+
+	// 19 of 20 messages are fresh, so the p95 is under the SLA
+	for i := 0; i < 19; i++ {
+		m.Observe(sentMessage(time.Second))
+	}
+	m.Observe(sentMessage(time.Hour))
+	m.check()
+	if len(alarms) != 0 {
+		t.Fatalf("expected no alarms, got %d", len(alarms))
+	}
+
+	for i := 0; i < 10; i++ {
+		m.Observe(sentMessage(2 * time.Minute))
+	}
+	m.check()
+	if len(alarms) != 1 {
+		t.Fatalf("expected 1 alarm, got %d", len(alarms))
+	}
+	if alarms[0].Age < 2*time.Minute || alarms[0].Samples != 10 {
+		t.Errorf("expected an age of at least 2m over 10 samples, got %+v", alarms[0])
+	}
+
+	// the alarm is only raised once until it clears
+	m.Observe(sentMessage(2 * time.Minute))
+	m.check()
+	m.Observe(sentMessage(time.Second))
+	m.check()
+	m.Observe(sentMessage(2 * time.Minute))
+	m.check()
+	if len(alarms) != 2 {
+		t.Errorf("expected 2 alarms, got %d", len(alarms))
+	}
+	if got := metrics.GetOrRegisterCounter("pubsub.lag.ALARM", m.MetricsRegistry).Count(); got != 2 {
+		t.Errorf("expected 2 alarms counted, got %d", got)
+	}
+}
+
+func TestLagMonitorHandler(t *testing.T) {
+	m := NewLagMonitor(time.Minute)
+	var handled bool
+	h := m.Handler(func(ctx context.Context, msg SubscriberMessage) error {
+		handled = true
+		return nil
+	})
+	h(context.Background(), sentMessage(time.Second))
+	h(context.Background(), &testConsumerMessage{msg: []byte("no timestamp")})
+	if !handled {
+		t.Error("expected the message to be handled")
+	}
+	if len(m.ages) != 1 {
+		t.Errorf("expected 1 observed age, got %d", len(m.ages))
+	}
+}
@@ -0,0 +1,154 @@
+package pubsub
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/golang/protobuf/proto"
+	"golang.org/x/net/context"
+)
+
+// DefaultMemoryBufferSize is how many messages each MemorySubscriber will
+// hold before publishers to its topic block.
+var DefaultMemoryBufferSize = 100
+
+var (
+	memTopicsMu sync.Mutex
+	memTopics   = map[string]*memTopic{}
+)
+
+// memTopic is a named in-process topic that fans each message out
+// to every running subscriber.
+type memTopic struct {
+	mu   sync.RWMutex
+	subs map[*MemorySubscriber]struct{}
+}
+
+func getMemTopic(name string) *memTopic {
+	memTopicsMu.Lock()
+	defer memTopicsMu.Unlock()
+	t, ok := memTopics[name]
+	if !ok {
+		t = &memTopic{subs: map[*MemorySubscriber]struct{}{}}
+		memTopics[name] = t
+	}
+	return t
+}
+
+// MemoryPublisher is a publisher that sends messages to the
+// MemorySubscribers of a named topic within the same process. It is meant
+// for local development and tests, where a real backend is not available.
+type MemoryPublisher struct {
+	topic *memTopic
+}
+
+// NewMemoryPublisher will return a MemoryPublisher for the named topic.
+func NewMemoryPublisher(topic string) *MemoryPublisher {
+	return &MemoryPublisher{topic: getMemTopic(topic)}
+}
+
+// Publish will marshal the proto message and send it to the topic.
+func (p *MemoryPublisher) Publish(key string, m proto.Message) error {
+	mb, err := proto.Marshal(m)
+	if err != nil {
+		return err
+	}
+	return p.PublishRaw(key, mb)
+}
+
+// PublishRaw will send a copy of the byte array to every running subscriber
+// of the topic. Messages published while a topic has no running subscribers
+// are dropped.
+func (p *MemoryPublisher) PublishRaw(key string, m []byte) error {
+	p.topic.mu.RLock()
+	defer p.topic.mu.RUnlock()
+	for s := range p.topic.subs {
+		msg := &MemoryMessage{body: append([]byte(nil), m...)}
+		select {
+		case s.msgs <- msg:
+		case <-s.stop:
+		}
+	}
+	return nil
+}
+
+// MemorySubscriber is a subscriber that receives the messages published
+// to a named topic by MemoryPublishers in the same process.
+type MemorySubscriber struct {
+	topic *memTopic
+
+	msgs     chan SubscriberMessage
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+// NewMemorySubscriber will return a MemorySubscriber for the named topic.
+func NewMemorySubscriber(topic string) *MemorySubscriber {
+	return &MemorySubscriber{
+		topic: getMemTopic(topic),
+		msgs:  make(chan SubscriberMessage, DefaultMemoryBufferSize),
+		stop:  make(chan struct{}),
+	}
+}
+
+// MemoryMessage is a SubscriberMessage received by a MemorySubscriber.
+type MemoryMessage struct {
+	body []byte
+}
+
+// Message will return the body of the message.
+func (m *MemoryMessage) Message() []byte {
+	return m.body
+}
+
+// Done is a no-op, as in-process messages are never redelivered.
+func (m *MemoryMessage) Done() error {
+	return nil
+}
+
+// Start will register the subscriber with its topic and return the channel
+// its messages are sent to.
+func (s *MemorySubscriber) Start() <-chan SubscriberMessage {
+	s.topic.mu.Lock()
+	s.topic.subs[s] = struct{}{}
+	s.topic.mu.Unlock()
+	return s.msgs
+}
+
+// StartWithContext will register the subscriber like Start and stop it
+// once ctx is done.
+func (s *MemorySubscriber) StartWithContext(ctx context.Context) <-chan SubscriberMessage {
+	msgs := s.Start()
+	go func() {
+		select {
+		case <-ctx.Done():
+			s.Stop()
+		case <-s.stop:
+		}
+	}()
+	return msgs
+}
+
+// Err will always return nil.
+func (s *MemorySubscriber) Err() error {
+	return nil
+}
+
+// Stop will remove the subscriber from its topic and close its channel.
+// Messages already buffered may still be read from the channel.
+func (s *MemorySubscriber) Stop() error {
+	stopped := true
+	s.stopOnce.Do(func() {
+		stopped = false
+		// unblock any publishers before waiting on them for the lock
+		close(s.stop)
+		s.topic.mu.Lock()
+		delete(s.topic.subs, s)
+		s.topic.mu.Unlock()
+		close(s.msgs)
+	})
+	if stopped {
+		return errors.New("subscriber is already stopped")
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package pubsub
+
+import (
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+func TestMemoryFanOut(t *testing.T) {
+	pub := NewMemoryPublisher("memory-test")
+	// published before anyone is listening
+	pub.PublishRaw("cats", []byte("lost"))
+
+	a, b := NewMemorySubscriber("memory-test"), NewMemorySubscriber("memory-test")
+	as, bs := a.Start(), b.Start()
+	other := NewMemorySubscriber("memory-other")
+	others := other.Start()
+
+	body := []byte("meow")
+	pub.PublishRaw("cats", body)
+	body[0] = 'p'
+
+	for _, msgs := range []<-chan SubscriberMessage{as, bs} {
+		if got := string((<-msgs).Message()); got != "meow" {
+			t.Errorf("expected message 'meow', got %q", got)
+		}
+	}
+	if len(others) != 0 {
+		t.Errorf("expected no messages on another topic, got %d", len(others))
+	}
+
+	if err := a.Stop(); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+	if err := a.Stop(); err == nil {
+		t.Error("expected an error stopping twice, got none")
+	}
+	pub.PublishRaw("cats", body)
+	if got := string((<-bs).Message()); got != "peow" {
+		t.Errorf("expected message 'peow', got %q", got)
+	}
+	if _, ok := <-as; ok {
+		t.Error("expected a stopped subscriber's channel to be closed")
+	}
+	b.Stop()
+	other.Stop()
+}
+
+func TestMemoryStartWithContext(t *testing.T) {
+	sub := NewMemorySubscriber("memory-context-test")
+	ctx, cancel := context.WithCancel(context.Background())
+	msgs := StartWithContext(ctx, sub)
+
+	cancel()
+	select {
+	case _, ok := <-msgs:
+		if ok {
+			t.Error("expected no messages once the context was done")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected the channel to close once the context was done")
+	}
+	if err := sub.Stop(); err == nil {
+		t.Error("expected the subscriber to already be stopped")
+	}
+}
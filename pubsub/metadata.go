@@ -0,0 +1,117 @@
+package pubsub
+
+import (
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"golang.org/x/net/context"
+)
+
+// MessageMetadata describes the message a Consumer's handler is called with.
+type MessageMetadata struct {
+	// Consumer is the Name of the Consumer handling the message.
+	Consumer string
+	// ID is the message's ID, if its Subscriber provides one.
+	ID string
+	// Key is the message's key, if its Subscriber provides one.
+	Key string
+	// Producer holds the StandardAttributes the message was published
+	// with by an EnrichingPublisher, if any.
+	Producer StandardAttributes
+	// PublishTime is when the message was published, from its
+	// PublishTimeAttribute or its Subscriber, or the zero time if it is
+	// unknown.
+	PublishTime time.Time
+	// ReceiveCount is the number of times the message has been received,
+	// if its Subscriber provides it.
+	ReceiveCount int
+}
+
+type metadataKey int
+
+const (
+	serviceContextKey metadataKey = iota
+	messageContextKey
+)
+
+// WithService will return a copy of ctx carrying the attributes of the
+// service handling messages.
+func WithService(ctx context.Context, service StandardAttributes) context.Context {
+	return context.WithValue(ctx, serviceContextKey, service)
+}
+
+// ServiceFromContext will return the attributes of the service handling
+// messages carried by ctx, like those of a Consumer's Service.
+func ServiceFromContext(ctx context.Context) (StandardAttributes, bool) {
+	service, ok := ctx.Value(serviceContextKey).(StandardAttributes)
+	return service, ok
+}
+
+// WithMessageMetadata will return a copy of ctx carrying the metadata of the
+// message being handled.
+func WithMessageMetadata(ctx context.Context, md *MessageMetadata) context.Context {
+	return context.WithValue(ctx, messageContextKey, md)
+}
+
+// MessageMetadataFromContext will return the metadata of the message being
+// handled carried by ctx, as added by a Consumer.
+func MessageMetadataFromContext(ctx context.Context) (*MessageMetadata, bool) {
+	md, ok := ctx.Value(messageContextKey).(*MessageMetadata)
+	return md, ok
+}
+
+// MessageFields will return the service and message metadata carried by ctx
+// as logrus Fields, so every handler logs with the same dimensions:
+//
+//	pubsub.Log.WithFields(pubsub.MessageFields(ctx)).Info("adopted a cat")
+func MessageFields(ctx context.Context) logrus.Fields {
+	fields := logrus.Fields{}
+	if service, ok := ServiceFromContext(ctx); ok {
+		addField(fields, ServiceAttribute, service.Service)
+		addField(fields, ServiceVersionAttribute, service.Version)
+		addField(fields, EnvironmentAttribute, service.Environment)
+	}
+	if md, ok := MessageMetadataFromContext(ctx); ok {
+		addField(fields, "consumer", md.Consumer)
+		addField(fields, "message_id", md.ID)
+		addField(fields, "message_key", md.Key)
+		addField(fields, "producer", md.Producer.Service)
+		addField(fields, "producer_version", md.Producer.Version)
+		if md.ReceiveCount > 0 {
+			fields["receive_count"] = md.ReceiveCount
+		}
+	}
+	return fields
+}
+
+func addField(fields logrus.Fields, k, v string) {
+	if v != "" {
+		fields[k] = v
+	}
+}
+
+// newMessageMetadata will describe the message being handled by the named
+// Consumer.
+func newMessageMetadata(consumer string, msg SubscriberMessage) *MessageMetadata {
+	md := &MessageMetadata{Consumer: consumer}
+	md.ID = messageID(msg)
+	if k, ok := msg.(interface {
+		Key() string
+	}); ok {
+		md.Key = k.Key()
+	}
+	md.Producer.Service, _ = attribute(msg, ServiceAttribute)
+	md.Producer.Version, _ = attribute(msg, ServiceVersionAttribute)
+	md.Producer.Environment, _ = attribute(msg, EnvironmentAttribute)
+	if ts, ok := attribute(msg, PublishTimeAttribute); ok {
+		md.PublishTime, _ = time.Parse(time.RFC3339Nano, ts)
+	} else if smsg, ok := msg.(SentMessage); ok {
+		md.PublishTime = smsg.SentAt()
+	}
+	if rmsg, ok := msg.(interface {
+		ReceiveCount() int
+	}); ok {
+		md.ReceiveCount = rmsg.ReceiveCount()
+	}
+	return md
+}
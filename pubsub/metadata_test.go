@@ -0,0 +1,65 @@
+package pubsub
+
+import (
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+func TestConsumerMetadata(t *testing.T) {
+	sub := newTestChanSubscriber()
+	published := time.Date(2017, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	type result struct {
+		service StandardAttributes
+		md      *MessageMetadata
+		fields  map[string]interface{}
+	}
+	results := make(chan result, 1)
+	c := NewConsumer(sub, func(ctx context.Context, msg SubscriberMessage) error {
+		service, _ := ServiceFromContext(ctx)
+		md, _ := MessageMetadataFromContext(ctx)
+		results <- result{service, md, MessageFields(ctx)}
+		return nil
+	})
+	c.Name = "adoptions"
+	c.Service = StandardAttributes{Service: "cat-shelter", Version: "2.0.0", Environment: "prd"}
+
+	go c.Run()
+	msg := &testAttributeMessage{attrs: map[string]string{
+		"id":                 "123",
+		ServiceAttribute:     "cats",
+		PublishTimeAttribute: published.Format(time.RFC3339Nano),
+	}}
+	msg.msg = []byte("meow")
+	sub.msgs <- msg
+	got := <-results
+	if err := c.Stop(); err != nil {
+		t.Error("unexpected error stopping consumer: ", err)
+	}
+
+	if got.service != c.Service {
+		t.Errorf("expected service %+v, got %+v", c.Service, got.service)
+	}
+	if got.md == nil {
+		t.Fatal("expected message metadata, got none")
+	}
+	if got.md.Consumer != "adoptions" || got.md.ID != "123" || got.md.Producer.Service != "cats" {
+		t.Errorf("expected metadata of message 123 from cats for adoptions, got %+v", got.md)
+	}
+	if !got.md.PublishTime.Equal(published) {
+		t.Errorf("expected publish time %s, got %s", published, got.md.PublishTime)
+	}
+	for k, want := range map[string]string{
+		ServiceAttribute:     "cat-shelter",
+		EnvironmentAttribute: "prd",
+		"consumer":           "adoptions",
+		"message_id":         "123",
+		"producer":           "cats",
+	} {
+		if got.fields[k] != want {
+			t.Errorf("expected field %s to be %q, got %v", k, want, got.fields[k])
+		}
+	}
+}
@@ -0,0 +1,231 @@
+package pubsub
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+// MultiRegionSubscriber is a Subscriber that consumes replicated queues in
+// several regions at once and emits each message only once, so a regional
+// failover does not require switching consumers by hand. If the subscriber
+// of one region fails, the others keep being consumed.
+//
+// Messages are deduplicated by their IdempotencyTokenAttribute, if they
+// have one, or else by a hash of their key and body. A message's key is
+// marked complete in a PublishTokenStore once it is done, and copies of it
+// received from other regions after that are marked as done without being
+// emitted. Copies received while the first is still being handled are
+// dropped and left to be redelivered. Each message received from a region
+// is counted in the 'pubsub.multiregion.REGION.RECEIVED' metric, and each
+// duplicate in 'pubsub.multiregion.DUPLICATE'.
+type MultiRegionSubscriber struct {
+	// DedupeKey is an optional func for the key messages are deduplicated
+	// by, like an ID set by the publisher.
+	DedupeKey func(SubscriberMessage) string
+	// MetricsRegistry will override the default metrics registry if set.
+	MetricsRegistry metrics.Registry
+
+	regions []Region
+	tokens  PublishTokenStore
+
+	mu       sync.Mutex
+	inFlight map[string]bool
+	err      error
+
+	stop     chan struct{}
+	stopOnce sync.Once
+	done     chan struct{}
+}
+
+// NewMultiRegionSubscriber will return a MultiRegionSubscriber over the
+// Subscribers of the given regions that keeps the keys of done messages in
+// tokens. The tokens should be kept for longer than messages may take to be
+// replicated between regions.
+func NewMultiRegionSubscriber(tokens PublishTokenStore, regions ...Region) (*MultiRegionSubscriber, error) {
+	if tokens == nil {
+		return nil, errors.New("a token store is required to drop duplicate messages")
+	}
+	if len(regions) == 0 {
+		return nil, errors.New("at least 1 region is required")
+	}
+	for _, r := range regions {
+		if r.Subscriber == nil {
+			return nil, errors.New("region " + r.Name + " has no subscriber")
+		}
+	}
+	return &MultiRegionSubscriber{
+		regions:  regions,
+		tokens:   tokens,
+		inFlight: map[string]bool{},
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}, nil
+}
+
+// Start will start the Subscribers of every region and emit the first copy
+// of each of their messages. The returned channel is closed once every
+// region's Subscriber has stopped.
+func (s *MultiRegionSubscriber) Start() <-chan SubscriberMessage {
+	output := make(chan SubscriberMessage)
+	var wg sync.WaitGroup
+	for _, r := range s.regions {
+		wg.Add(1)
+		go func(r Region) {
+			defer wg.Done()
+			s.consume(r, output)
+		}(r)
+	}
+	go func() {
+		wg.Wait()
+		close(output)
+		close(s.done)
+	}()
+	return output
+}
+
+// consume will emit the messages of the region until it is stopped.
+func (s *MultiRegionSubscriber) consume(r Region, output chan<- SubscriberMessage) {
+	msgs := r.Subscriber.Start()
+	received := metrics.GetOrRegisterCounter("pubsub.multiregion."+r.Name+".RECEIVED", s.MetricsRegistry)
+	for {
+		select {
+		case <-s.stop:
+			return
+		case msg, ok := <-msgs:
+			if !ok {
+				if err := r.Subscriber.Err(); err != nil {
+					Log.Errorf("subscriber for region %s stopped: %s", r.Name, err)
+					s.mu.Lock()
+					if s.err == nil {
+						s.err = err
+					}
+					s.mu.Unlock()
+				}
+				return
+			}
+			received.Inc(1)
+			dmsg, ok := s.dedupe(msg)
+			if !ok {
+				continue
+			}
+			select {
+			case output <- dmsg:
+			case <-s.stop:
+				dmsg.release()
+				return
+			}
+		}
+	}
+}
+
+// dedupe will return the message wrapped to record its key once it is
+// done, or false if it is a duplicate.
+func (s *MultiRegionSubscriber) dedupe(msg SubscriberMessage) (*dedupedMessage, bool) {
+	key := s.key(msg)
+	duplicates := metrics.GetOrRegisterCounter("pubsub.multiregion.DUPLICATE", s.MetricsRegistry)
+
+	s.mu.Lock()
+	if s.inFlight[key] {
+		s.mu.Unlock()
+		duplicates.Inc(1)
+		return nil, false
+	}
+	s.inFlight[key] = true
+	s.mu.Unlock()
+
+	dmsg := &dedupedMessage{SubscriberMessage: msg, sub: s, key: key}
+	done, err := s.tokens.Completed(key)
+	if err != nil {
+		// better to handle a message twice than not at all
+		Log.Warn("unable to check for duplicate message: ", err)
+	}
+	if done {
+		dmsg.release()
+		duplicates.Inc(1)
+		if err := msg.Done(); err != nil {
+			Log.Warn("unable to mark duplicate message as done: ", err)
+		}
+		return nil, false
+	}
+	return dmsg, true
+}
+
+func (s *MultiRegionSubscriber) key(msg SubscriberMessage) string {
+	if s.DedupeKey != nil {
+		return s.DedupeKey(msg)
+	}
+	if token, ok := attribute(msg, IdempotencyTokenAttribute); ok {
+		return token
+	}
+	key, body, _ := passthrough(msg)
+	return contentToken(key, body)
+}
+
+// Stop will stop emitting messages and stop the Subscriber of every
+// region, returning the first error encountered.
+func (s *MultiRegionSubscriber) Stop() error {
+	s.stopOnce.Do(func() {
+		close(s.stop)
+	})
+	<-s.done
+	var err error
+	for _, r := range s.regions {
+		if serr := r.Subscriber.Stop(); serr != nil && err == nil {
+			err = serr
+		}
+	}
+	return err
+}
+
+// Err will return the first error from the Subscriber of any region.
+func (s *MultiRegionSubscriber) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+type dedupedMessage struct {
+	SubscriberMessage
+	sub *MultiRegionSubscriber
+	key string
+}
+
+// Done will mark the message as done and record its key so copies from
+// other regions are dropped.
+func (m *dedupedMessage) Done() error {
+	defer m.release()
+	if err := m.SubscriberMessage.Done(); err != nil {
+		return err
+	}
+	if err := m.sub.tokens.Complete(m.key); err != nil {
+		Log.Warn("unable to record message as done: ", err)
+	}
+	return nil
+}
+
+// Nack will hand the message back for redelivery, if it supports it, so a
+// copy from any region may be handled again.
+func (m *dedupedMessage) Nack() error {
+	defer m.release()
+	if nmsg, ok := m.SubscriberMessage.(NackMessage); ok {
+		return nmsg.Nack()
+	}
+	return nil
+}
+
+// Attributes will return the wrapped message's attributes, if it has any.
+func (m *dedupedMessage) Attributes() map[string]string {
+	if amsg, ok := m.SubscriberMessage.(AttributeMessage); ok {
+		return amsg.Attributes()
+	}
+	return nil
+}
+
+// release will allow copies of the message to be emitted again.
+func (m *dedupedMessage) release() {
+	m.sub.mu.Lock()
+	delete(m.sub.inFlight, m.key)
+	m.sub.mu.Unlock()
+}
@@ -0,0 +1,86 @@
+package pubsub
+
+import (
+	"sync/atomic"
+	"testing"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+func TestMultiRegionSubscriber(t *testing.T) {
+	east := newTestChanSubscriber()
+	west := newTestChanSubscriber()
+	sub, err := NewMultiRegionSubscriber(&MemoryPublishTokenStore{},
+		Region{Name: "us-east-1", Subscriber: east},
+		Region{Name: "us-west-2", Subscriber: west},
+	)
+	if err != nil {
+		t.Fatalf("expected no error, got %s", err)
+	}
+	sub.MetricsRegistry = metrics.NewRegistry()
+	msgs := sub.Start()
+
+	east.msgs <- &testConsumerMessage{msg: []byte("a")}
+	msg := <-msgs
+	if got := string(msg.Message()); got != "a" {
+		t.Fatalf("expected message 'a', got %q", got)
+	}
+	if err := msg.Done(); err != nil {
+		t.Fatalf("expected no error, got %s", err)
+	}
+
+	// the replicated copy is marked as done without being emitted
+	dup := &testConsumerMessage{msg: []byte("a")}
+	west.msgs <- dup
+	west.msgs <- &testConsumerMessage{msg: []byte("b")}
+	msg = <-msgs
+	if got := string(msg.Message()); got != "b" {
+		t.Errorf("expected message 'b', got %q", got)
+	}
+	if atomic.LoadInt32(&dup.doned) != 1 {
+		t.Error("expected the duplicate to be marked as done")
+	}
+	if got := metrics.GetOrRegisterCounter("pubsub.multiregion.DUPLICATE", sub.MetricsRegistry).Count(); got != 1 {
+		t.Errorf("expected 1 duplicate, got %d", got)
+	}
+	if got := metrics.GetOrRegisterCounter("pubsub.multiregion.us-west-2.RECEIVED", sub.MetricsRegistry).Count(); got != 2 {
+		t.Errorf("expected 2 messages received from us-west-2, got %d", got)
+	}
+
+	if err := sub.Stop(); err != nil {
+		t.Errorf("expected no error, got %s", err)
+	}
+	if _, ok := <-msgs; ok {
+		t.Error("expected the channel to be closed")
+	}
+}
+
+func TestNewMultiRegionSubscriberErrors(t *testing.T) {
+	region := Region{Name: "us-east-1", Subscriber: newTestChanSubscriber()}
+	if _, err := NewMultiRegionSubscriber(nil, region); err == nil {
+		t.Error("expected an error without a token store, got none")
+	}
+	if _, err := NewMultiRegionSubscriber(&MemoryPublishTokenStore{}); err == nil {
+		t.Error("expected an error without any regions, got none")
+	}
+	if _, err := NewMultiRegionSubscriber(&MemoryPublishTokenStore{}, Region{Name: "us-west-2"}); err == nil {
+		t.Error("expected an error for a region without a subscriber, got none")
+	}
+}
+
+func TestMultiRegionSubscriberInFlight(t *testing.T) {
+	sub, _ := NewMultiRegionSubscriber(&MemoryPublishTokenStore{},
+		Region{Name: "us-east-1", Subscriber: newTestChanSubscriber()},
+	)
+	first, ok := sub.dedupe(&testConsumerMessage{msg: []byte("a")})
+	if !ok {
+		t.Fatal("expected the first copy to be emitted")
+	}
+	if _, ok := sub.dedupe(&testConsumerMessage{msg: []byte("a")}); ok {
+		t.Error("expected a copy to be dropped while the first is in flight")
+	}
+	first.Nack()
+	if _, ok := sub.dedupe(&testConsumerMessage{msg: []byte("a")}); !ok {
+		t.Error("expected a copy to be emitted once the first is nacked")
+	}
+}
@@ -0,0 +1,96 @@
+package pubsub
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"strings"
+	"text/template"
+)
+
+// NotificationTemplate renders a notification from a published message. Each
+// template is executed with the proto.Message given to Publish or with the
+// JSON decoded bytes given to PublishRaw.
+type NotificationTemplate struct {
+	// To renders the recipient's email address or, for SMS, their phone
+	// number in E.164 format.
+	To *template.Template
+	// Subject renders the subject of an email. It is not used for SMS.
+	Subject *template.Template
+	// Body renders the text of the email or SMS.
+	Body *template.Template
+	// HTMLBody optionally renders an HTML part for an email. It is not
+	// used for SMS.
+	HTMLBody *htmltemplate.Template
+}
+
+// notification is a rendered NotificationTemplate.
+type notification struct {
+	to, subject, body, html string
+}
+
+func (t *NotificationTemplate) render(data interface{}) (*notification, error) {
+	var (
+		n   notification
+		err error
+	)
+	if t.To == nil || t.Body == nil {
+		return nil, errors.New("notification templates require a To and a Body")
+	}
+	if n.to, err = execText(t.To, data); err != nil {
+		return nil, err
+	}
+	if n.to = strings.TrimSpace(n.to); n.to == "" {
+		return nil, errors.New("notification has no recipient")
+	}
+	if t.Subject != nil {
+		if n.subject, err = execText(t.Subject, data); err != nil {
+			return nil, err
+		}
+	}
+	if n.body, err = execText(t.Body, data); err != nil {
+		return nil, err
+	}
+	if t.HTMLBody != nil {
+		var b bytes.Buffer
+		if err = t.HTMLBody.Execute(&b, data); err != nil {
+			return nil, err
+		}
+		n.html = b.String()
+	}
+	return &n, nil
+}
+
+func execText(t *template.Template, data interface{}) (string, error) {
+	var b bytes.Buffer
+	err := t.Execute(&b, data)
+	return b.String(), err
+}
+
+// notifier holds the templates shared by the notification publishers.
+type notifier struct {
+	templates map[string]*NotificationTemplate
+	send      func(*notification) error
+}
+
+func (n *notifier) publish(key string, data interface{}) error {
+	t, ok := n.templates[key]
+	if !ok {
+		return fmt.Errorf("no notification template for key %q", key)
+	}
+	msg, err := t.render(data)
+	if err != nil {
+		return err
+	}
+	return n.send(msg)
+}
+
+func (n *notifier) publishRaw(key string, m []byte) error {
+	var data interface{}
+	if err := json.Unmarshal(m, &data); err != nil {
+		return fmt.Errorf("notification payloads must be JSON: %s", err)
+	}
+	return n.publish(key, data)
+}
@@ -0,0 +1,163 @@
+// +build !noaws
+
+package pubsub
+
+import (
+	"errors"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/ses"
+	"github.com/aws/aws-sdk-go/service/sns"
+	"github.com/aws/aws-sdk-go/service/sns/snsiface"
+	"github.com/golang/protobuf/proto"
+
+	"github.com/NYTimes/gizmo/config"
+)
+
+// sesSender is the part of the SES API used by the SESPublisher.
+type sesSender interface {
+	SendEmail(*ses.SendEmailInput) (*ses.SendEmailOutput, error)
+}
+
+// SESPublisher is a Publisher that sends an email with Amazon SES for each
+// message, rendered by the NotificationTemplate registered for its key. This
+// lets notifications reuse the same publish call sites as queue events.
+type SESPublisher struct {
+	notifier
+	ses     sesSender
+	source  string
+	replyTo string
+}
+
+// NewSESPublisher will initiate the SES client with the templates for each
+// message key. If no credentials are passed in with the config, the
+// publisher is instantiated with the AWS_ACCESS_KEY and the AWS_SECRET_KEY
+// environment variables.
+func NewSESPublisher(cfg *config.SES, templates map[string]*NotificationTemplate) (*SESPublisher, error) {
+	p := &SESPublisher{}
+	if cfg.Source == "" {
+		return p, errors.New("SES source address is required")
+	}
+	if cfg.Region == "" {
+		return p, errors.New("SES region is required")
+	}
+
+	var creds *credentials.Credentials
+	if cfg.AccessKey != "" {
+		creds = credentials.NewStaticCredentials(cfg.AccessKey, cfg.SecretKey, "")
+	} else {
+		creds = credentials.NewEnvCredentials()
+	}
+
+	p.ses = ses.New(session.New(awsEndpoints(cfg.AWS, &aws.Config{
+		Credentials: creds,
+		Region:      &cfg.Region,
+	})))
+	p.source = cfg.Source
+	p.replyTo = cfg.ReplyTo
+	p.notifier = notifier{templates: templates, send: p.send}
+	return p, nil
+}
+
+// Publish will render and send an email for the proto message.
+func (p *SESPublisher) Publish(key string, m proto.Message) error {
+	return p.publish(key, m)
+}
+
+// PublishRaw will render and send an email for the JSON payload.
+func (p *SESPublisher) PublishRaw(key string, m []byte) error {
+	return p.publishRaw(key, m)
+}
+
+func (p *SESPublisher) send(n *notification) error {
+	body := &ses.Body{
+		Text: &ses.Content{Data: aws.String(n.body), Charset: aws.String("UTF-8")},
+	}
+	if n.html != "" {
+		body.Html = &ses.Content{Data: aws.String(n.html), Charset: aws.String("UTF-8")}
+	}
+	input := &ses.SendEmailInput{
+		Source:      &p.source,
+		Destination: &ses.Destination{ToAddresses: []*string{aws.String(n.to)}},
+		Message: &ses.Message{
+			Subject: &ses.Content{Data: aws.String(n.subject), Charset: aws.String("UTF-8")},
+			Body:    body,
+		},
+	}
+	if p.replyTo != "" {
+		input.ReplyToAddresses = []*string{&p.replyTo}
+	}
+	_, err := p.ses.SendEmail(input)
+	return err
+}
+
+// SMSPublisher is a Publisher that sends an SMS with Amazon SNS for each
+// message, rendered by the NotificationTemplate registered for its key. This
+// lets notifications reuse the same publish call sites as queue events.
+type SMSPublisher struct {
+	notifier
+	sns      snsiface.SNSAPI
+	smsType  string
+	senderID string
+}
+
+// NewSMSPublisher will initiate the SNS client with the templates for each
+// message key. If no credentials are passed in with the config, the
+// publisher is instantiated with the AWS_ACCESS_KEY and the AWS_SECRET_KEY
+// environment variables.
+func NewSMSPublisher(cfg *config.SMS, templates map[string]*NotificationTemplate) (*SMSPublisher, error) {
+	p := &SMSPublisher{}
+	if cfg.Region == "" {
+		return p, errors.New("SNS region is required")
+	}
+
+	var creds *credentials.Credentials
+	if cfg.AccessKey != "" {
+		creds = credentials.NewStaticCredentials(cfg.AccessKey, cfg.SecretKey, "")
+	} else {
+		creds = credentials.NewEnvCredentials()
+	}
+
+	p.sns = sns.New(session.New(awsEndpoints(cfg.AWS, &aws.Config{
+		Credentials: creds,
+		Region:      &cfg.Region,
+	})))
+	p.smsType = cfg.SMSType
+	p.senderID = cfg.SenderID
+	p.notifier = notifier{templates: templates, send: p.send}
+	return p, nil
+}
+
+// Publish will render and send an SMS for the proto message.
+func (p *SMSPublisher) Publish(key string, m proto.Message) error {
+	return p.publish(key, m)
+}
+
+// PublishRaw will render and send an SMS for the JSON payload.
+func (p *SMSPublisher) PublishRaw(key string, m []byte) error {
+	return p.publishRaw(key, m)
+}
+
+func (p *SMSPublisher) send(n *notification) error {
+	attrs := map[string]*sns.MessageAttributeValue{}
+	if p.smsType != "" {
+		attrs["AWS.SNS.SMS.SMSType"] = &sns.MessageAttributeValue{
+			DataType:    aws.String("String"),
+			StringValue: &p.smsType,
+		}
+	}
+	if p.senderID != "" {
+		attrs["AWS.SNS.SMS.SenderID"] = &sns.MessageAttributeValue{
+			DataType:    aws.String("String"),
+			StringValue: &p.senderID,
+		}
+	}
+	_, err := p.sns.Publish(&sns.PublishInput{
+		PhoneNumber:       aws.String(n.to),
+		Message:           aws.String(n.body),
+		MessageAttributes: attrs,
+	})
+	return err
+}
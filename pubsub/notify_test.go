@@ -0,0 +1,89 @@
+// +build !noaws
+
+package pubsub
+
+import (
+	htmltemplate "html/template"
+	"testing"
+	"text/template"
+
+	"github.com/aws/aws-sdk-go/service/ses"
+)
+
+type testSESSender struct {
+	sent []*ses.SendEmailInput
+}
+
+func (t *testSESSender) SendEmail(i *ses.SendEmailInput) (*ses.SendEmailOutput, error) {
+	t.sent = append(t.sent, i)
+	return &ses.SendEmailOutput{}, nil
+}
+
+func TestSESPublisher(t *testing.T) {
+	sender := &testSESSender{}
+	pub := &SESPublisher{ses: sender, source: "cats@example.com"}
+	pub.notifier = notifier{
+		templates: map[string]*NotificationTemplate{
+			"adopted": {
+				To:       template.Must(template.New("to").Parse(`{{.email}}`)),
+				Subject:  template.Must(template.New("subject").Parse(`{{.cat}} is yours!`)),
+				Body:     template.Must(template.New("body").Parse(`Take good care of {{.cat}}.`)),
+				HTMLBody: htmltemplate.Must(htmltemplate.New("html").Parse(`<p>Take good care of {{.cat}}.</p>`)),
+			},
+		},
+		send: pub.send,
+	}
+
+	err := pub.PublishRaw("adopted", []byte(`{"email":"jon@example.com","cat":"<Tom>"}`))
+	if err != nil {
+		t.Fatal("unexpected error publishing: ", err)
+	}
+	if len(sender.sent) != 1 {
+		t.Fatalf("expected 1 email sent, got %d", len(sender.sent))
+	}
+	got := sender.sent[0]
+	if *got.Source != "cats@example.com" || *got.Destination.ToAddresses[0] != "jon@example.com" {
+		t.Errorf("expected email from cats@ to jon@, got %s to %s", *got.Source, *got.Destination.ToAddresses[0])
+	}
+	if want := "<Tom> is yours!"; *got.Message.Subject.Data != want {
+		t.Errorf("expected subject %q, got %q", want, *got.Message.Subject.Data)
+	}
+	if want := "<p>Take good care of &lt;Tom&gt;.</p>"; *got.Message.Body.Html.Data != want {
+		t.Errorf("expected escaped HTML body %q, got %q", want, *got.Message.Body.Html.Data)
+	}
+
+	if err = pub.PublishRaw("unknown", []byte(`{}`)); err == nil {
+		t.Error("expected an error for a key without a template")
+	}
+	if err = pub.PublishRaw("adopted", []byte(`not json`)); err == nil {
+		t.Error("expected an error for a payload that is not JSON")
+	}
+}
+
+func TestSMSPublisher(t *testing.T) {
+	snstest := &TestSNSAPI{}
+	pub := &SMSPublisher{sns: snstest, smsType: "Transactional"}
+	pub.notifier = notifier{
+		templates: map[string]*NotificationTemplate{
+			"reminder": {
+				To:   template.Must(template.New("to").Parse(`+1{{.Value}}`)),
+				Body: template.Must(template.New("body").Parse(`Feed the cat!`)),
+			},
+		},
+		send: pub.send,
+	}
+
+	if err := pub.Publish("reminder", &TestProto{"5555550100"}); err != nil {
+		t.Fatal("unexpected error publishing: ", err)
+	}
+	if len(snstest.Published) != 1 {
+		t.Fatalf("expected 1 SMS sent, got %d", len(snstest.Published))
+	}
+	got := snstest.Published[0]
+	if *got.PhoneNumber != "+15555550100" || *got.Message != "Feed the cat!" {
+		t.Errorf("expected SMS to +15555550100, got %q to %q", *got.Message, *got.PhoneNumber)
+	}
+	if *got.MessageAttributes["AWS.SNS.SMS.SMSType"].StringValue != "Transactional" {
+		t.Error("expected the SMS type attribute to be set")
+	}
+}
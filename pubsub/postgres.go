@@ -0,0 +1,315 @@
+package pubsub
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+)
+
+var (
+	// DefaultPostgresJournalTable is the table messages are written to
+	// if no Table is given.
+	DefaultPostgresJournalTable = "pubsub_journal"
+	// DefaultPostgresCursorTable is the table subscribers keep their
+	// position in if no CursorTable is given.
+	DefaultPostgresCursorTable = "pubsub_cursors"
+	// DefaultPostgresPollInterval is how often a PostgresSubscriber will
+	// check the journal without being notified if no PollInterval is given.
+	DefaultPostgresPollInterval = 30 * time.Second
+	// DefaultPostgresBatchSize is the max number of messages a
+	// PostgresSubscriber will read at once if no BatchSize is given.
+	DefaultPostgresBatchSize = 100
+)
+
+// PostgresSchema is the schema of the default journal and cursor tables used
+// by the PostgresPublisher and PostgresSubscriber. Old messages are not removed
+// from the journal, so it should be pruned once all subscribers have read them.
+const PostgresSchema = `CREATE TABLE IF NOT EXISTS pubsub_journal (
+	id         BIGSERIAL PRIMARY KEY,
+	channel    TEXT NOT NULL,
+	key        TEXT NOT NULL,
+	body       BYTEA NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+CREATE INDEX IF NOT EXISTS pubsub_journal_channel_id ON pubsub_journal (channel, id);
+CREATE TABLE IF NOT EXISTS pubsub_cursors (
+	name    TEXT PRIMARY KEY,
+	last_id BIGINT NOT NULL
+)`
+
+// PostgresListener is a connection that has LISTENed to a Postgres channel,
+// such as a lib/pq Listener. It is expected to reconnect on its own if its
+// connection is lost.
+type PostgresListener interface {
+	// Listen will start listening for notifications on the channel.
+	Listen(channel string) error
+	// Notifications will return a channel emitting the payload of each
+	// notification. An empty payload should be sent after reconnecting, as
+	// notifications may have been missed.
+	Notifications() <-chan string
+	// Close will stop listening and close the connection.
+	Close() error
+}
+
+// PostgresPublisher will write messages to a journal table and NOTIFY the
+// channel's subscribers in the same transaction.
+type PostgresPublisher struct {
+	// Table is the name of the journal table. It defaults to
+	// DefaultPostgresJournalTable and must not come from user input.
+	Table string
+
+	db      *sql.DB
+	channel string
+}
+
+// NewPostgresPublisher will return a Publisher for the Postgres channel.
+func NewPostgresPublisher(db *sql.DB, channel string) (*PostgresPublisher, error) {
+	if channel == "" {
+		return nil, errors.New("postgres channel is required")
+	}
+	return &PostgresPublisher{Table: DefaultPostgresJournalTable, db: db, channel: channel}, nil
+}
+
+// Publish will marshal the proto message and publish it.
+func (p *PostgresPublisher) Publish(key string, m proto.Message) error {
+	mb, err := proto.Marshal(m)
+	if err != nil {
+		return err
+	}
+	return p.PublishRaw(key, mb)
+}
+
+// PublishRaw will write the message to the journal and notify the
+// channel with its ID.
+func (p *PostgresPublisher) PublishRaw(key string, m []byte) (err error) {
+	tx, err := p.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+			return
+		}
+		err = tx.Commit()
+	}()
+
+	var id int64
+	err = tx.QueryRow(fmt.Sprintf("INSERT INTO %s (channel, key, body) VALUES ($1, $2, $3) RETURNING id", p.Table),
+		p.channel, key, m).Scan(&id)
+	if err != nil {
+		return err
+	}
+	// notifications are only sent once the transaction commits
+	_, err = tx.Exec("SELECT pg_notify($1, $2)", p.channel, fmt.Sprint(id))
+	return err
+}
+
+type (
+	// PostgresSubscriber will emit the messages published to a Postgres
+	// channel. Notifications only wake the subscriber, which reads any new
+	// messages from the journal table, so messages published while it was
+	// disconnected or stopped are caught up on.
+	//
+	// Subscribers with the same name share a position in the cursor table
+	// and should not run at the same time. Messages are emitted a batch at a
+	// time and the position will only advance once every message in the
+	// batch is Done, so messages may be emitted again after a restart.
+	PostgresSubscriber struct {
+		// Table is the name of the journal table. It defaults to
+		// DefaultPostgresJournalTable and must not come from user input.
+		Table string
+		// CursorTable is the name of the table positions are kept in.
+		// It defaults to DefaultPostgresCursorTable and must not come
+		// from user input.
+		CursorTable string
+		// PollInterval is how often the journal will be checked without
+		// a notification. It defaults to DefaultPostgresPollInterval.
+		PollInterval time.Duration
+		// BatchSize is the max number of messages that will be read at
+		// once. It defaults to DefaultPostgresBatchSize.
+		BatchSize int
+
+		db       *sql.DB
+		listener PostgresListener
+		channel  string
+		name     string
+
+		mu  sync.Mutex
+		err error
+
+		stop     chan struct{}
+		stopOnce sync.Once
+		done     chan struct{}
+	}
+
+	// PostgresMessage is the Postgres implementation of `SubscriberMessage`.
+	PostgresMessage struct {
+		id   int64
+		key  string
+		body []byte
+		done func()
+	}
+)
+
+// NewPostgresSubscriber will return a Subscriber for the Postgres channel
+// that LISTENs with the given listener and keeps its position under the name.
+func NewPostgresSubscriber(db *sql.DB, listener PostgresListener, channel, name string) (*PostgresSubscriber, error) {
+	if channel == "" {
+		return nil, errors.New("postgres channel is required")
+	}
+	if name == "" {
+		return nil, errors.New("postgres subscriber name is required")
+	}
+	return &PostgresSubscriber{
+		Table:        DefaultPostgresJournalTable,
+		CursorTable:  DefaultPostgresCursorTable,
+		PollInterval: DefaultPostgresPollInterval,
+		BatchSize:    DefaultPostgresBatchSize,
+		db:           db,
+		listener:     listener,
+		channel:      channel,
+		name:         name,
+		stop:         make(chan struct{}),
+		done:         make(chan struct{}),
+	}, nil
+}
+
+// Message will return the message body.
+func (m *PostgresMessage) Message() []byte {
+	return m.body
+}
+
+// Key will return the key the message was published with.
+func (m *PostgresMessage) Key() string {
+	return m.key
+}
+
+// Done will mark the message as handled so the subscriber's position
+// can advance once the rest of its batch is done.
+func (m *PostgresMessage) Done() error {
+	m.done()
+	return nil
+}
+
+// Start will LISTEN to the channel and emit any messages in the journal
+// after the subscriber's position, and then any new messages as they are
+// notified. If it encounters any issues, it will populate the Err() error
+// and close the returned channel.
+func (s *PostgresSubscriber) Start() <-chan SubscriberMessage {
+	output := make(chan SubscriberMessage)
+	go func() {
+		defer close(s.done)
+		defer close(output)
+		if err := s.run(output); err != nil {
+			s.mu.Lock()
+			s.err = err
+			s.mu.Unlock()
+		}
+	}()
+	return output
+}
+
+func (s *PostgresSubscriber) run(output chan<- SubscriberMessage) error {
+	if err := s.listener.Listen(s.channel); err != nil {
+		return err
+	}
+	last, err := s.position()
+	if err != nil {
+		return err
+	}
+
+	ticker := time.NewTicker(s.PollInterval)
+	defer ticker.Stop()
+	for {
+		// read everything we have not seen before waiting to be notified
+		for {
+			msgs, err := s.read(last)
+			if err != nil {
+				return err
+			}
+			if len(msgs) == 0 {
+				break
+			}
+			ok := emitBatch(s.stop, output, len(msgs), func(i int, done func()) SubscriberMessage {
+				msgs[i].done = done
+				return msgs[i]
+			})
+			if !ok {
+				return nil
+			}
+			last = msgs[len(msgs)-1].id
+			if err := s.savePosition(last); err != nil {
+				return err
+			}
+			if len(msgs) < s.BatchSize {
+				break
+			}
+		}
+
+		select {
+		case <-s.stop:
+			return nil
+		case _, ok := <-s.listener.Notifications():
+			if !ok {
+				return errors.New("postgres listener was closed")
+			}
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *PostgresSubscriber) position() (int64, error) {
+	var last int64
+	err := s.db.QueryRow(fmt.Sprintf("SELECT last_id FROM %s WHERE name = $1", s.CursorTable), s.name).Scan(&last)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	return last, err
+}
+
+func (s *PostgresSubscriber) savePosition(last int64) error {
+	_, err := s.db.Exec(fmt.Sprintf("INSERT INTO %s (name, last_id) VALUES ($1, $2) ON CONFLICT (name) DO UPDATE SET last_id = EXCLUDED.last_id", s.CursorTable),
+		s.name, last)
+	return err
+}
+
+func (s *PostgresSubscriber) read(after int64) ([]*PostgresMessage, error) {
+	rows, err := s.db.Query(fmt.Sprintf("SELECT id, key, body FROM %s WHERE channel = $1 AND id > $2 ORDER BY id LIMIT $3", s.Table),
+		s.channel, after, s.BatchSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var msgs []*PostgresMessage
+	for rows.Next() {
+		m := &PostgresMessage{}
+		if err := rows.Scan(&m.id, &m.key, &m.body); err != nil {
+			return nil, err
+		}
+		msgs = append(msgs, m)
+	}
+	return msgs, rows.Err()
+}
+
+// Stop will stop emitting messages and close the listener. Messages that have
+// been emitted but not done will be emitted again when the subscriber restarts.
+func (s *PostgresSubscriber) Stop() error {
+	s.stopOnce.Do(func() {
+		close(s.stop)
+	})
+	<-s.done
+	return s.listener.Close()
+}
+
+// Err will contain any errors that occurred during consumption. This
+// method should be checked after a user encounters a closed channel.
+func (s *PostgresSubscriber) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
@@ -0,0 +1,256 @@
+package pubsub
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type testJournalRow struct {
+	id      int64
+	channel string
+	key     string
+	body    []byte
+}
+
+// testPGDriver is a database/sql driver that answers the Postgres publisher
+// and subscriber queries from an in-memory journal. Notifications are sent
+// to the listener as soon as they are made.
+type testPGDriver struct {
+	mu       sync.Mutex
+	journal  []testJournalRow
+	cursors  map[string]int64
+	listener *testPGListener
+}
+
+func (d *testPGDriver) Open(string) (driver.Conn, error) { return &testPGConn{d: d}, nil }
+
+type testPGConn struct{ d *testPGDriver }
+
+func (c *testPGConn) Prepare(query string) (driver.Stmt, error) {
+	return &testPGStmt{d: c.d, query: query}, nil
+}
+func (c *testPGConn) Close() error              { return nil }
+func (c *testPGConn) Begin() (driver.Tx, error) { return c, nil }
+func (c *testPGConn) Commit() error             { return nil }
+func (c *testPGConn) Rollback() error           { return nil }
+
+type testPGStmt struct {
+	d     *testPGDriver
+	query string
+}
+
+func (s *testPGStmt) Close() error  { return nil }
+func (s *testPGStmt) NumInput() int { return -1 }
+
+func (s *testPGStmt) Exec(args []driver.Value) (driver.Result, error) {
+	d := s.d
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	switch {
+	case strings.HasPrefix(s.query, "SELECT pg_notify("):
+		if d.listener != nil && d.listener.channel == args[0].(string) {
+			d.listener.notify(args[1].(string))
+		}
+	case strings.HasPrefix(s.query, "INSERT INTO pubsub_cursors "):
+		d.cursors[args[0].(string)] = args[1].(int64)
+	default:
+		return nil, errors.New("unexpected exec: " + s.query)
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func (s *testPGStmt) Query(args []driver.Value) (driver.Rows, error) {
+	d := s.d
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	switch {
+	case strings.HasPrefix(s.query, "INSERT INTO pubsub_journal "):
+		id := int64(len(d.journal) + 1)
+		d.journal = append(d.journal, testJournalRow{id, args[0].(string), args[1].(string), args[2].([]byte)})
+		return &testPGRows{columns: []string{"id"}, rows: [][]driver.Value{{id}}}, nil
+	case strings.HasPrefix(s.query, "SELECT last_id FROM pubsub_cursors "):
+		rows := &testPGRows{columns: []string{"last_id"}}
+		if last, ok := d.cursors[args[0].(string)]; ok {
+			rows.rows = [][]driver.Value{{last}}
+		}
+		return rows, nil
+	case strings.HasPrefix(s.query, "SELECT id, key, body FROM pubsub_journal "):
+		rows := &testPGRows{columns: []string{"id", "key", "body"}}
+		for _, r := range d.journal {
+			if r.channel == args[0].(string) && r.id > args[1].(int64) && len(rows.rows) < int(args[2].(int64)) {
+				rows.rows = append(rows.rows, []driver.Value{r.id, r.key, r.body})
+			}
+		}
+		return rows, nil
+	}
+	return nil, errors.New("unexpected query: " + s.query)
+}
+
+type testPGRows struct {
+	columns []string
+	rows    [][]driver.Value
+}
+
+func (r *testPGRows) Columns() []string { return r.columns }
+func (r *testPGRows) Close() error      { return nil }
+func (r *testPGRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
+
+type testPGListener struct {
+	channel  string
+	notified chan string
+	closed   bool
+}
+
+func (l *testPGListener) Listen(channel string) error {
+	l.channel = channel
+	return nil
+}
+
+func (l *testPGListener) Notifications() <-chan string { return l.notified }
+
+func (l *testPGListener) Close() error {
+	l.closed = true
+	return nil
+}
+
+func (l *testPGListener) notify(payload string) {
+	select {
+	case l.notified <- payload:
+	default:
+		// the subscriber will read everything on its next wake
+	}
+}
+
+var testPG = &testPGDriver{}
+
+func init() {
+	sql.Register("pubsub-test-pg", testPG)
+}
+
+func TestPostgresPubSub(t *testing.T) {
+	db, err := sql.Open("pubsub-test-pg", "")
+	if err != nil {
+		t.Fatal("unable to open test db: ", err)
+	}
+	defer db.Close()
+	listener := &testPGListener{notified: make(chan string, 1)}
+	testPG.mu.Lock()
+	testPG.journal = nil
+	testPG.cursors = map[string]int64{}
+	testPG.listener = listener
+	testPG.mu.Unlock()
+
+	pub, err := NewPostgresPublisher(db, "events")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	other, _ := NewPostgresPublisher(db, "other")
+	// published before the subscriber started and should be caught up on
+	if err := pub.PublishRaw("a", []byte("one")); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	other.PublishRaw("a", []byte("not for us"))
+
+	sub, err := NewPostgresSubscriber(db, listener, "events", "test")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	sub.BatchSize = 2
+	// only notifications should wake it during the test
+	sub.PollInterval = time.Hour
+	msgs := sub.Start()
+
+	expect := func(want string) {
+		select {
+		case msg, ok := <-msgs:
+			if !ok {
+				t.Fatalf("expected %q, channel was closed: %v", want, sub.Err())
+			}
+			if got := string(msg.Message()); got != want {
+				t.Errorf("expected message %q, got %q", want, got)
+			}
+			if err := msg.Done(); err != nil {
+				t.Errorf("unexpected error: %s", err)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("timed out waiting for %q", want)
+		}
+	}
+	expect("one")
+	for _, body := range []string{"two", "three", "four"} {
+		if err := pub.PublishRaw("b", []byte(body)); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+	expect("two")
+	expect("three")
+	expect("four")
+
+	// the position is saved once the batch is done
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		testPG.mu.Lock()
+		cursor := testPG.cursors["test"]
+		testPG.mu.Unlock()
+		if cursor == 5 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if err := sub.Stop(); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+	if !listener.closed {
+		t.Error("expected listener to be closed on stop")
+	}
+	testPG.mu.Lock()
+	cursor := testPG.cursors["test"]
+	testPG.mu.Unlock()
+	if cursor != 5 {
+		t.Errorf("expected cursor at 5, got %d", cursor)
+	}
+
+	// another subscriber with the same name should resume from the cursor
+	pub.PublishRaw("c", []byte("five"))
+	listener = &testPGListener{notified: make(chan string, 1)}
+	sub, _ = NewPostgresSubscriber(db, listener, "events", "test")
+	msgs = sub.Start()
+	expect("five")
+	sub.Stop()
+}
+
+func TestPostgresSubscriberListenerClosed(t *testing.T) {
+	db, err := sql.Open("pubsub-test-pg", "")
+	if err != nil {
+		t.Fatal("unable to open test db: ", err)
+	}
+	defer db.Close()
+	testPG.mu.Lock()
+	testPG.journal = nil
+	testPG.cursors = map[string]int64{}
+	testPG.listener = nil
+	testPG.mu.Unlock()
+
+	listener := &testPGListener{notified: make(chan string)}
+	close(listener.notified)
+	sub, _ := NewPostgresSubscriber(db, listener, "events", "test")
+	if _, ok := <-sub.Start(); ok {
+		t.Error("expected channel to be closed")
+	}
+	if sub.Err() == nil {
+		t.Error("expected an error once the listener was closed, got nil")
+	}
+}
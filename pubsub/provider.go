@@ -0,0 +1,140 @@
+package pubsub
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/NYTimes/gizmo/config"
+)
+
+// The names of the built in backends accepted in a config.PubSub's Provider.
+// Each is only registered if its backend is built; see the package docs.
+const (
+	ProviderSNS   = "sns"
+	ProviderSQS   = "sqs"
+	ProviderKafka = "kafka"
+	ProviderGCP   = "gcp"
+	ProviderMem   = "mem"
+)
+
+// ProviderFactory builds the publishers and subscribers of a backend from a
+// config.PubSub. Backends that only publish or only subscribe may leave the
+// other func nil.
+type ProviderFactory struct {
+	NewPublisher  func(cfg *config.PubSub) (Publisher, error)
+	NewSubscriber func(cfg *config.PubSub) (Subscriber, error)
+}
+
+var (
+	providersMu sync.RWMutex
+	providers   = map[string]ProviderFactory{}
+)
+
+func init() {
+	RegisterProvider(ProviderMem, ProviderFactory{
+		NewPublisher: func(cfg *config.PubSub) (Publisher, error) {
+			return NewMemoryPublisher(cfg.MemTopic), nil
+		},
+		NewSubscriber: func(cfg *config.PubSub) (Subscriber, error) {
+			return NewMemorySubscriber(cfg.MemTopic), nil
+		},
+	})
+}
+
+// RegisterProvider will make a backend available to NewPublisher and
+// NewSubscriber under the given name, so packages outside of gizmo can add
+// backends without their dependencies being pulled into this one. It is
+// meant to be called from the init func of the package providing the
+// backend, and will panic if the name is empty or already registered.
+func RegisterProvider(name string, factory ProviderFactory) {
+	providersMu.Lock()
+	defer providersMu.Unlock()
+	if name == "" {
+		panic("pubsub: RegisterProvider name is empty")
+	}
+	if _, dup := providers[name]; dup {
+		panic("pubsub: RegisterProvider called twice for provider " + name)
+	}
+	providers[name] = factory
+}
+
+// Providers will return the sorted names of the registered backends.
+func Providers() []string {
+	providersMu.RLock()
+	defer providersMu.RUnlock()
+	names := make([]string, 0, len(providers))
+	for name := range providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func provider(name string) (ProviderFactory, error) {
+	providersMu.RLock()
+	defer providersMu.RUnlock()
+	factory, ok := providers[name]
+	if !ok {
+		return factory, fmt.Errorf("unknown pubsub provider %q", name)
+	}
+	return factory, nil
+}
+
+// NewPublisher will return a Publisher for the backend registered under the
+// config's Provider, verified if the config's Verify is set. SQS has no
+// publisher, so services on AWS should publish with the 'sns' provider to a
+// topic their queues subscribe to. If the config has a Service,
+// ServiceVersion or Environment, the Publisher is an EnrichingPublisher
+// that stamps them on every message, which requires a backend that supports
+// attributes.
+func NewPublisher(cfg *config.PubSub) (Publisher, error) {
+	factory, err := provider(cfg.Provider)
+	if err != nil {
+		return nil, err
+	}
+	if factory.NewPublisher == nil {
+		return nil, fmt.Errorf("pubsub provider %q has no publisher", cfg.Provider)
+	}
+	pub, err := factory.NewPublisher(cfg)
+	if err == nil && cfg.Verify {
+		err = Verify(pub)
+	}
+	if err != nil || (cfg.Service == "" && cfg.ServiceVersion == "" && cfg.Environment == "") {
+		return pub, err
+	}
+	apub, ok := pub.(AttributePublisher)
+	if !ok {
+		return nil, ErrAttributesUnsupported
+	}
+	return NewEnrichingPublisher(apub, StandardAttributes{
+		Service:     cfg.Service,
+		Version:     cfg.ServiceVersion,
+		Environment: cfg.Environment,
+	}.Hook()), nil
+}
+
+// NewSubscriber will return a Subscriber for the backend registered under the
+// config's Provider, verified if the config's Verify is set. SNS has no
+// subscriber, so services on AWS should subscribe with the 'sqs' provider.
+// Kafka subscribers start from the oldest offset if the config's ResumeFrom
+// is 'earliest' and the newest otherwise, and do not track their progress;
+// use NewKafkaCheckpointSubscriber to resume where a subscriber left off.
+func NewSubscriber(cfg *config.PubSub) (Subscriber, error) {
+	factory, err := provider(cfg.Provider)
+	if err != nil {
+		return nil, err
+	}
+	if factory.NewSubscriber == nil {
+		return nil, fmt.Errorf("pubsub provider %q has no subscriber", cfg.Provider)
+	}
+	sub, err := factory.NewSubscriber(cfg)
+	if err == nil && cfg.Verify {
+		err = Verify(sub)
+	}
+	return sub, err
+}
+
+func missingProviderConfig(provider string) error {
+	return fmt.Errorf("pubsub provider %q requires its config to be set", provider)
+}
@@ -0,0 +1,32 @@
+// +build !noaws
+
+package pubsub
+
+import "github.com/NYTimes/gizmo/config"
+
+func init() {
+	RegisterProvider(ProviderSNS, ProviderFactory{NewPublisher: newSNSProvider})
+	RegisterProvider(ProviderSQS, ProviderFactory{NewSubscriber: newSQSProvider})
+}
+
+func newSNSProvider(cfg *config.PubSub) (Publisher, error) {
+	if cfg.SNS == nil {
+		return nil, missingProviderConfig(cfg.Provider)
+	}
+	p, err := NewSNSPublisher(cfg.SNS)
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func newSQSProvider(cfg *config.PubSub) (Subscriber, error) {
+	if cfg.SQS == nil {
+		return nil, missingProviderConfig(cfg.Provider)
+	}
+	s, err := NewSQSSubscriber(cfg.SQS)
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
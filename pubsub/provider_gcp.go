@@ -0,0 +1,38 @@
+// +build !nogcp
+
+package pubsub
+
+import (
+	"golang.org/x/net/context"
+
+	"github.com/NYTimes/gizmo/config"
+)
+
+func init() {
+	RegisterProvider(ProviderGCP, ProviderFactory{
+		NewPublisher:  newGCPPublisherProvider,
+		NewSubscriber: newGCPSubscriberProvider,
+	})
+}
+
+func newGCPPublisherProvider(cfg *config.PubSub) (Publisher, error) {
+	if cfg.GCP == nil {
+		return nil, missingProviderConfig(cfg.Provider)
+	}
+	p, err := NewGCPPublisher(context.Background(), cfg.GCP)
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func newGCPSubscriberProvider(cfg *config.PubSub) (Subscriber, error) {
+	if cfg.GCP == nil {
+		return nil, missingProviderConfig(cfg.Provider)
+	}
+	s, err := NewGCPSubscriber(context.Background(), cfg.GCP)
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
@@ -0,0 +1,42 @@
+// +build !nokafka
+
+package pubsub
+
+import (
+	"github.com/Shopify/sarama"
+
+	"github.com/NYTimes/gizmo/config"
+)
+
+func init() {
+	RegisterProvider(ProviderKafka, ProviderFactory{
+		NewPublisher:  newKafkaPublisherProvider,
+		NewSubscriber: newKafkaSubscriberProvider,
+	})
+}
+
+func newKafkaPublisherProvider(cfg *config.PubSub) (Publisher, error) {
+	if cfg.Kafka == nil {
+		return nil, missingProviderConfig(cfg.Provider)
+	}
+	p, err := NewKafkaPublisher(cfg.Kafka)
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func newKafkaSubscriberProvider(cfg *config.PubSub) (Subscriber, error) {
+	if cfg.Kafka == nil {
+		return nil, missingProviderConfig(cfg.Provider)
+	}
+	offset := sarama.OffsetNewest
+	if cfg.Kafka.ResumeFrom == "earliest" {
+		offset = sarama.OffsetOldest
+	}
+	s, err := NewKafkaSubscriber(cfg.Kafka, func() int64 { return offset }, func(int64) {})
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
@@ -0,0 +1,109 @@
+package pubsub
+
+import (
+	"testing"
+
+	"github.com/NYTimes/gizmo/config"
+)
+
+func TestNewPublisherAndSubscriber(t *testing.T) {
+	cfg := &config.PubSub{Provider: ProviderMem, MemTopic: "provider-test"}
+	sub, err := NewSubscriber(cfg)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	msgs := sub.Start()
+	pub, err := NewPublisher(cfg)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err = pub.PublishRaw("cats", []byte("meow")); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if got := string((<-msgs).Message()); got != "meow" {
+		t.Errorf("expected message 'meow', got %q", got)
+	}
+	if err = sub.Stop(); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+}
+
+func TestNewPublisherErrors(t *testing.T) {
+	tests := []*config.PubSub{
+		{Provider: ""},
+		{Provider: "kinesis"},
+		{Provider: ProviderSQS, SQS: &config.SQS{}},
+		{Provider: ProviderSNS},
+		{Provider: ProviderKafka},
+		{Provider: ProviderGCP},
+	}
+
+	for _, cfg := range tests {
+		pub, err := NewPublisher(cfg)
+		if err == nil {
+			t.Errorf("%q expected an error, got none", cfg.Provider)
+		}
+		if pub != nil {
+			t.Errorf("%q expected no publisher, got %#v", cfg.Provider, pub)
+		}
+	}
+}
+
+func TestNewSubscriberErrors(t *testing.T) {
+	tests := []*config.PubSub{
+		{Provider: ""},
+		{Provider: "kinesis"},
+		{Provider: ProviderSNS, SNS: &config.SNS{}},
+		{Provider: ProviderSQS},
+		{Provider: ProviderKafka},
+		{Provider: ProviderGCP, GCP: &config.GCP{}},
+	}
+
+	for _, cfg := range tests {
+		sub, err := NewSubscriber(cfg)
+		if err == nil {
+			t.Errorf("%q expected an error, got none", cfg.Provider)
+		}
+		if sub != nil {
+			t.Errorf("%q expected no subscriber, got %#v", cfg.Provider, sub)
+		}
+	}
+}
+
+func TestRegisterProvider(t *testing.T) {
+	var got map[string]string
+	RegisterProvider("test-custom", ProviderFactory{
+		NewPublisher: func(cfg *config.PubSub) (Publisher, error) {
+			got = cfg.Options
+			return NewMemoryPublisher(cfg.Options["topic"]), nil
+		},
+	})
+
+	found := false
+	for _, name := range Providers() {
+		if name == "test-custom" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("expected 'test-custom' in providers, got %v", Providers())
+	}
+
+	cfg := &config.PubSub{Provider: "test-custom", Options: map[string]string{"topic": "cats"}}
+	if _, err := NewPublisher(cfg); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+	if got["topic"] != "cats" {
+		t.Errorf("expected the factory to be given the options, got %v", got)
+	}
+	if _, err := NewSubscriber(cfg); err == nil {
+		t.Error("expected an error for a provider without a subscriber, got none")
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("expected registering a provider twice to panic")
+		}
+	}()
+	RegisterProvider(ProviderMem, ProviderFactory{})
+}
@@ -1,8 +1,13 @@
 package pubsub
 
 import (
+	"sync"
+	"sync/atomic"
+	"time"
+
 	"github.com/Sirupsen/logrus"
 	"github.com/golang/protobuf/proto"
+	"golang.org/x/net/context"
 )
 
 // Log is the structured logger used throughout the package.
@@ -18,6 +23,41 @@ type Publisher interface {
 	PublishRaw(string, []byte) error
 }
 
+// PublishResult describes a published message so it can be logged and
+// correlated with downstream processing.
+type PublishResult struct {
+	// MessageID is the ID the backend assigned to the message.
+	MessageID string
+	// SequenceNumber is the position of the message in its topic or
+	// partition, for backends that order messages like SNS FIFO topics
+	// and Kafka. Empty for others.
+	SequenceNumber string
+	// Timestamp is when the message was published.
+	Timestamp time.Time
+}
+
+// ResultPublisher is a Publisher that can describe the messages it
+// publishes.
+type ResultPublisher interface {
+	Publisher
+	// PublishRawResult will publish a raw byte array as a message and
+	// return its PublishResult.
+	PublishRawResult(string, []byte) (*PublishResult, error)
+}
+
+// PublishWithResult will publish the byte array and return its
+// PublishResult. If pub is not a ResultPublisher, the result will
+// only have a Timestamp.
+func PublishWithResult(pub Publisher, key string, m []byte) (*PublishResult, error) {
+	if rp, ok := pub.(ResultPublisher); ok {
+		return rp.PublishRawResult(key, m)
+	}
+	if err := pub.PublishRaw(key, m); err != nil {
+		return nil, err
+	}
+	return &PublishResult{Timestamp: time.Now()}, nil
+}
+
 // Subscriber is a generic interface to encapsulate how we want our subscribers
 // to behave. For now the system will auto stop if it encounters any errors. If
 // a user encounters a closed channel, they should check the Err() method to see
@@ -31,9 +71,111 @@ type Subscriber interface {
 	Stop() error
 }
 
+// ContextSubscriber is a Subscriber that can tie its consumption to a
+// context, so it composes with services that coordinate their goroutines
+// through contexts, like with errgroup.
+type ContextSubscriber interface {
+	Subscriber
+	// StartWithContext will return a channel of raw messages like Start and
+	// stop the subscriber once ctx is done. The channel is closed once the
+	// subscriber has stopped.
+	StartWithContext(ctx context.Context) <-chan SubscriberMessage
+}
+
+// StartWithContext will start the Subscriber and stop it once ctx is done,
+// using its own StartWithContext if it is a ContextSubscriber. For any other
+// Subscriber, messages are relayed until ctx is done, and any received after
+// that are not emitted and are nacked or released for redelivery. The
+// returned channel is closed once the Subscriber has stopped, after which
+// its Err should be checked as usual.
+func StartWithContext(ctx context.Context, sub Subscriber) <-chan SubscriberMessage {
+	if cs, ok := sub.(ContextSubscriber); ok {
+		return cs.StartWithContext(ctx)
+	}
+	msgs := sub.Start()
+	output := make(chan SubscriberMessage)
+	go func() {
+		defer close(output)
+		for {
+			select {
+			case <-ctx.Done():
+				stopWithContext(sub, msgs)
+				return
+			case msg, ok := <-msgs:
+				if !ok {
+					return
+				}
+				select {
+				case output <- msg:
+				case <-ctx.Done():
+					stopWithContext(sub, msgs)
+					return
+				}
+			}
+		}
+	}()
+	return output
+}
+
+// stopWithContext will stop the Subscriber and drain its channel so it
+// is not blocked from stopping, handing back any drained messages.
+func stopWithContext(sub Subscriber, msgs <-chan SubscriberMessage) {
+	stopped := make(chan error, 1)
+	go func() {
+		stopped <- sub.Stop()
+	}()
+	for {
+		select {
+		case msg, ok := <-msgs:
+			if !ok {
+				msgs = nil
+				continue
+			}
+			handBack(msg)
+		case err := <-stopped:
+			if err != nil {
+				Log.Warn("unable to stop subscriber: ", err)
+			}
+			return
+		}
+	}
+}
+
 // SubscriberMessage is a struct to encapsulate subscriber messages and provide
 // a mechanism for acknowledging messages _after_ they've been processed.
 type SubscriberMessage interface {
 	Message() []byte
 	Done() error
 }
+
+// emitBatch will send n messages made by msg to the output and wait for all of
+// them to be done. It will return false if stop is closed before then.
+func emitBatch(stop <-chan struct{}, output chan<- SubscriberMessage, n int, msg func(i int, done func()) SubscriberMessage) bool {
+	// done is closed by the last message to be done rather than a goroutine
+	// waiting on them, so nothing is left behind if stop closes first
+	remaining := int32(n)
+	done := make(chan struct{})
+	if n == 0 {
+		close(done)
+	}
+	finish := func() {
+		if atomic.AddInt32(&remaining, -1) == 0 {
+			close(done)
+		}
+	}
+	for i := 0; i < n; i++ {
+		var once sync.Once
+		select {
+		case <-stop:
+			return false
+		case output <- msg(i, func() { once.Do(finish) }):
+		}
+	}
+
+	select {
+	case <-stop:
+		return false
+	case <-done:
+		return true
+	}
+}
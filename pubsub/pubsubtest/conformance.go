@@ -0,0 +1,203 @@
+package pubsubtest
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/NYTimes/gizmo/pubsub"
+)
+
+// DefaultConformanceTimeout is how long the conformance tests will wait for
+// a message or for a stopped subscriber's channel to close.
+var DefaultConformanceTimeout = 10 * time.Second
+
+type (
+	// Backend is a Publisher and a Subscriber connected to the same fresh
+	// topic or queue, so every message published is received by the
+	// subscriber once it has been started.
+	Backend struct {
+		Publisher  pubsub.Publisher
+		Subscriber pubsub.Subscriber
+		// Ordered is true if the backend delivers messages in the order
+		// they were published, like a single Kafka partition.
+		Ordered bool
+		// Break, if set, will make the started Subscriber fail, like by
+		// deleting its queue, so the suite can check the failure closes
+		// its channel and is reported by Err. Without it, that test is
+		// skipped.
+		Break func()
+	}
+
+	// BackendFactory returns a new Backend for each conformance test, given
+	// the test's name, like "Delivery", so it can create a fresh topic or
+	// queue for it. It may use t to fail the test.
+	BackendFactory func(t *testing.T, name string) *Backend
+)
+
+// conformanceTests are the cases run by RunPublisherTests, by name. They
+// are run one after another rather than as subtests, so the suite works on
+// Go releases before 1.7, and prefix their failures with their name.
+var conformanceTests = []struct {
+	name string
+	run  func(t *testing.T, name string, b *Backend)
+}{
+	{"Delivery", testDelivery},
+	{"DoneIdempotent", testDoneIdempotent},
+	{"Stop", testStop},
+	{"Err", testErr},
+}
+
+// RunPublisherTests will run a conformance suite against the Publisher and
+// Subscriber of a backend, so new backends, internal or third-party, are
+// verified against the same semantics as the built in ones:
+//
+//   - every published message is received with the body it was published with
+//   - messages are received in order, if the backend is Ordered
+//   - calling Done more than once on a message does not fail
+//   - Stop closes the channel returned by Start, and Err is nil afterwards
+//   - a failing Subscriber closes its channel and reports the failure by Err
+//
+// Backends are usually tested from their own _test.go file:
+//
+//	func TestConformance(t *testing.T) {
+//	    pubsubtest.RunPublisherTests(t, func(t *testing.T, name string) *pubsubtest.Backend {
+//	        topic := "conformance-" + name
+//	        return &pubsubtest.Backend{
+//	            Publisher:  pubsub.NewMemoryPublisher(topic),
+//	            Subscriber: pubsub.NewMemorySubscriber(topic),
+//	            Ordered:    true,
+//	        }
+//	    })
+//	}
+func RunPublisherTests(t *testing.T, factory BackendFactory) {
+	for _, test := range conformanceTests {
+		test.run(t, test.name, factory(t, test.name))
+	}
+}
+
+func testDelivery(t *testing.T, name string, b *Backend) {
+	msgs := b.Subscriber.Start()
+	defer b.Subscriber.Stop()
+
+	want := publishN(t, name, b.Publisher, 10)
+	got := receiveN(t, name, msgs, len(want))
+	if b.Ordered {
+		for i := range want {
+			if got[i] != want[i] {
+				t.Errorf("%s: expected messages in order %v, got %v", name, want, got)
+				return
+			}
+		}
+		return
+	}
+	seen := map[string]int{}
+	for _, m := range got {
+		seen[m]++
+	}
+	for _, m := range want {
+		if seen[m] == 0 {
+			t.Errorf("%s: expected message %q to be received, got %v", name, m, got)
+		}
+	}
+}
+
+func testDoneIdempotent(t *testing.T, name string, b *Backend) {
+	msgs := b.Subscriber.Start()
+	defer b.Subscriber.Stop()
+
+	publishN(t, name, b.Publisher, 1)
+	msg := receive(t, name, msgs)
+	if err := msg.Done(); err != nil {
+		t.Fatalf("%s: expected no error on Done, got %s", name, err)
+	}
+	if err := msg.Done(); err != nil {
+		t.Errorf("%s: expected no error on a second Done, got %s", name, err)
+	}
+}
+
+func testStop(t *testing.T, name string, b *Backend) {
+	msgs := b.Subscriber.Start()
+	if err := b.Subscriber.Stop(); err != nil {
+		t.Fatalf("%s: expected no error on Stop, got %s", name, err)
+	}
+	timeout := time.After(DefaultConformanceTimeout)
+	for {
+		select {
+		case msg, ok := <-msgs:
+			if !ok {
+				if err := b.Subscriber.Err(); err != nil {
+					t.Errorf("%s: expected no error after a clean Stop, got %s", name, err)
+				}
+				return
+			}
+			msg.Done()
+		case <-timeout:
+			t.Fatalf("%s: expected the channel to close after Stop", name)
+		}
+	}
+}
+
+func testErr(t *testing.T, name string, b *Backend) {
+	if b.Break == nil {
+		t.Logf("%s: skipped, the backend has no Break hook", name)
+		return
+	}
+	msgs := b.Subscriber.Start()
+	defer b.Subscriber.Stop()
+
+	b.Break()
+	timeout := time.After(DefaultConformanceTimeout)
+	for {
+		select {
+		case _, ok := <-msgs:
+			if !ok {
+				if err := b.Subscriber.Err(); err == nil {
+					t.Errorf("%s: expected an error once the backend was broken, got nil", name)
+				}
+				return
+			}
+		case <-timeout:
+			t.Fatalf("%s: expected the channel to close once the backend was broken", name)
+		}
+	}
+}
+
+// publishN will publish n numbered messages and return their bodies.
+func publishN(t *testing.T, name string, pub pubsub.Publisher, n int) []string {
+	bodies := make([]string, n)
+	for i := range bodies {
+		bodies[i] = "message " + strconv.Itoa(i)
+		if err := pub.PublishRaw("conformance", []byte(bodies[i])); err != nil {
+			t.Fatalf("%s: expected no error on PublishRaw, got %s", name, err)
+		}
+	}
+	return bodies
+}
+
+// receiveN will receive n messages, mark them as done and return their
+// bodies.
+func receiveN(t *testing.T, name string, msgs <-chan pubsub.SubscriberMessage, n int) []string {
+	bodies := make([]string, n)
+	for i := range bodies {
+		msg := receive(t, name, msgs)
+		bodies[i] = string(msg.Message())
+		if err := msg.Done(); err != nil {
+			t.Errorf("%s: expected no error on Done, got %s", name, err)
+		}
+	}
+	return bodies
+}
+
+func receive(t *testing.T, name string, msgs <-chan pubsub.SubscriberMessage) pubsub.SubscriberMessage {
+	select {
+	case msg, ok := <-msgs:
+		if !ok {
+			t.Fatalf("%s: expected a message, the channel was closed", name)
+		}
+		return msg
+	case <-time.After(DefaultConformanceTimeout):
+		t.Fatalf("%s: expected a message, got none before the timeout", name)
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package pubsubtest
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/NYTimes/gizmo/pubsub"
+)
+
+func TestMemoryConformance(t *testing.T) {
+	RunPublisherTests(t, func(t *testing.T, name string) *Backend {
+		topic := "memory-conformance-" + name
+		return &Backend{
+			Publisher:  pubsub.NewMemoryPublisher(topic),
+			Subscriber: pubsub.NewMemorySubscriber(topic),
+			Ordered:    true,
+		}
+	})
+}
+
+// breakableSubscriber is a MemorySubscriber that can be made to fail.
+type breakableSubscriber struct {
+	*pubsub.MemorySubscriber
+
+	mu  sync.Mutex
+	err error
+}
+
+func (s *breakableSubscriber) fail(err error) {
+	s.mu.Lock()
+	s.err = err
+	s.mu.Unlock()
+	s.MemorySubscriber.Stop()
+}
+
+func (s *breakableSubscriber) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+func TestBreakableConformance(t *testing.T) {
+	RunPublisherTests(t, func(t *testing.T, name string) *Backend {
+		topic := "breakable-conformance-" + name
+		sub := &breakableSubscriber{MemorySubscriber: pubsub.NewMemorySubscriber(topic)}
+		return &Backend{
+			Publisher:  pubsub.NewMemoryPublisher(topic),
+			Subscriber: sub,
+			Ordered:    true,
+			Break: func() {
+				sub.fail(errors.New("topic deleted"))
+			},
+		}
+	})
+}
@@ -0,0 +1,288 @@
+package pubsub
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+)
+
+// Redacted is the value that will replace any redacted fields.
+var Redacted = "[REDACTED]"
+
+type (
+	// PayloadHook is a func that inspects, and possibly rewrites, a payload
+	// before it leaves the service, such as to redact personal data. If it
+	// returns an error the payload will not be sent.
+	PayloadHook func(key string, m []byte) ([]byte, error)
+
+	// Tokenizer will replace a sensitive value with a token, such as a
+	// keyed hash that still allows records for the same value to be joined.
+	Tokenizer func(value string) (string, error)
+)
+
+// HMACTokenizer will return a Tokenizer that replaces values with the hex
+// encoded HMAC-SHA256 of the value keyed with secret, so equal values have
+// equal tokens without the values being recoverable.
+func HMACTokenizer(secret []byte) Tokenizer {
+	return func(value string) (string, error) {
+		mac := hmac.New(sha256.New, secret)
+		mac.Write([]byte(value))
+		return hex.EncodeToString(mac.Sum(nil)), nil
+	}
+}
+
+// RedactJSONPaths will return a PayloadHook that replaces the values at the
+// given paths of a JSON payload with Redacted. Paths are like those of
+// BodyPathEquals with the addition of '[*]' to match every element of an
+// array, like '$.users[*].email'. Paths missing from the payload are ignored,
+// but payloads that are not JSON are rejected.
+func RedactJSONPaths(paths ...string) PayloadHook {
+	return jsonPathHook(paths, func(interface{}) (interface{}, error) {
+		return Redacted, nil
+	})
+}
+
+// TokenizeJSONPaths will return a PayloadHook that replaces the values at
+// the given paths of a JSON payload with tokens. Values that are not strings
+// are tokenized by their JSON form. Paths are handled as in RedactJSONPaths.
+func TokenizeJSONPaths(t Tokenizer, paths ...string) PayloadHook {
+	return jsonPathHook(paths, func(v interface{}) (interface{}, error) {
+		s, ok := v.(string)
+		if !ok {
+			b, err := json.Marshal(v)
+			if err != nil {
+				return nil, err
+			}
+			s = string(b)
+		}
+		return t(s)
+	})
+}
+
+func jsonPathHook(paths []string, replace func(interface{}) (interface{}, error)) PayloadHook {
+	steps := make([][]string, len(paths))
+	for i, path := range paths {
+		steps[i] = parseJSONPath(path)
+	}
+	return func(key string, m []byte) ([]byte, error) {
+		var body interface{}
+		if err := json.Unmarshal(m, &body); err != nil {
+			return nil, err
+		}
+		for _, s := range steps {
+			var err error
+			if body, err = replaceJSONPath(body, s, replace); err != nil {
+				return nil, err
+			}
+		}
+		return json.Marshal(body)
+	}
+}
+
+// replaceJSONPath will return v with the values at the path swapped for
+// the result of replace.
+func replaceJSONPath(v interface{}, steps []string, replace func(interface{}) (interface{}, error)) (interface{}, error) {
+	if len(steps) == 0 {
+		return replace(v)
+	}
+	step, rest := steps[0], steps[1:]
+	if strings.HasPrefix(step, "[") {
+		arr, ok := v.([]interface{})
+		if !ok {
+			return v, nil
+		}
+		if step == "[*]" {
+			for i := range arr {
+				var err error
+				if arr[i], err = replaceJSONPath(arr[i], rest, replace); err != nil {
+					return nil, err
+				}
+			}
+			return arr, nil
+		}
+		i, err := strconv.Atoi(strings.Trim(step, "[]"))
+		if err != nil || i < 0 || i >= len(arr) {
+			return v, nil
+		}
+		arr[i], err = replaceJSONPath(arr[i], rest, replace)
+		return arr, err
+	}
+	obj, ok := v.(map[string]interface{})
+	if !ok {
+		return v, nil
+	}
+	fv, ok := obj[step]
+	if !ok {
+		return v, nil
+	}
+	var err error
+	obj[step], err = replaceJSONPath(fv, rest, replace)
+	return obj, err
+}
+
+// RedactProtoFields will return a PayloadHook that clears the given fields
+// of a payload encoding the same type as prototype. String fields are set to
+// Redacted and all others are cleared. Fields are named as in the .proto
+// file, with nested fields separated by dots like 'author.email', and
+// repeated messages have the field cleared in every element.
+func RedactProtoFields(prototype proto.Message, fields ...string) PayloadHook {
+	return protoFieldHook(prototype, fields, func(v reflect.Value) error {
+		if v.Kind() == reflect.String {
+			v.SetString(Redacted)
+			return nil
+		}
+		v.Set(reflect.Zero(v.Type()))
+		return nil
+	})
+}
+
+// TokenizeProtoFields will return a PayloadHook that replaces the given
+// string fields of a payload encoding the same type as prototype with
+// tokens. Fields are named as in RedactProtoFields and any that are not
+// strings are left as they are.
+func TokenizeProtoFields(prototype proto.Message, t Tokenizer, fields ...string) PayloadHook {
+	return protoFieldHook(prototype, fields, func(v reflect.Value) error {
+		if v.Kind() != reflect.String {
+			return nil
+		}
+		tok, err := t(v.String())
+		if err != nil {
+			return err
+		}
+		v.SetString(tok)
+		return nil
+	})
+}
+
+func protoFieldHook(prototype proto.Message, fields []string, replace func(reflect.Value) error) PayloadHook {
+	paths := make([][]string, len(fields))
+	for i, f := range fields {
+		paths[i] = strings.Split(f, ".")
+	}
+	return func(key string, m []byte) ([]byte, error) {
+		msg := proto.Clone(prototype)
+		msg.Reset()
+		if err := proto.Unmarshal(m, msg); err != nil {
+			return nil, err
+		}
+		for _, path := range paths {
+			if err := replaceProtoField(reflect.ValueOf(msg), path, replace); err != nil {
+				return nil, err
+			}
+		}
+		return proto.Marshal(msg)
+	}
+}
+
+// replaceProtoField will walk the generated struct v down the path of
+// field names and replace the field at the end of it.
+func replaceProtoField(v reflect.Value, path []string, replace func(reflect.Value) error) error {
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	if v.Kind() == reflect.Slice {
+		for i := 0; i < v.Len(); i++ {
+			if err := replaceProtoField(v.Index(i), path, replace); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if v.Kind() != reflect.Struct {
+		return nil
+	}
+	f, ok := protoField(v, path[0])
+	if !ok {
+		return nil
+	}
+	if len(path) > 1 {
+		return replaceProtoField(f, path[1:], replace)
+	}
+	if f.Kind() == reflect.Slice && f.Type().Elem().Kind() == reflect.String {
+		for i := 0; i < f.Len(); i++ {
+			if err := replace(f.Index(i)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return replace(f)
+}
+
+// protoField will find the field of a generated struct by its .proto name.
+func protoField(v reflect.Value, name string) (reflect.Value, bool) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		for _, opt := range strings.Split(t.Field(i).Tag.Get("protobuf"), ",") {
+			if opt == "name="+name {
+				return v.Field(i), true
+			}
+		}
+	}
+	return reflect.Value{}, false
+}
+
+// InspectingPublisher is a Publisher that runs every payload through a
+// chain of PayloadHooks before publishing it.
+type InspectingPublisher struct {
+	pub   Publisher
+	hooks []PayloadHook
+}
+
+// NewInspectingPublisher will return an InspectingPublisher that publishes
+// to pub once the hooks have been run in order.
+func NewInspectingPublisher(pub Publisher, hooks ...PayloadHook) *InspectingPublisher {
+	return &InspectingPublisher{pub: pub, hooks: hooks}
+}
+
+// Publish will marshal the proto message and publish it once inspected.
+func (p *InspectingPublisher) Publish(key string, m proto.Message) error {
+	mb, err := proto.Marshal(m)
+	if err != nil {
+		return err
+	}
+	return p.PublishRaw(key, mb)
+}
+
+// PublishRaw will publish the byte array once inspected.
+func (p *InspectingPublisher) PublishRaw(key string, m []byte) error {
+	m, err := p.inspect(key, m)
+	if err != nil {
+		return err
+	}
+	return p.pub.PublishRaw(key, m)
+}
+
+// PublishAttributes will publish the byte array once inspected along with
+// the given attributes, if the underlying Publisher is an
+// AttributePublisher.
+func (p *InspectingPublisher) PublishAttributes(key string, m []byte, attrs map[string]string) error {
+	apub, ok := p.pub.(AttributePublisher)
+	if !ok {
+		return ErrAttributesUnsupported
+	}
+	m, err := p.inspect(key, m)
+	if err != nil {
+		return err
+	}
+	return apub.PublishAttributes(key, m, attrs)
+}
+
+func (p *InspectingPublisher) inspect(key string, m []byte) ([]byte, error) {
+	for _, hook := range p.hooks {
+		var err error
+		if m, err = hook(key, m); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
@@ -0,0 +1,89 @@
+package pubsub
+
+import (
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+)
+
+func TestJSONPathHooks(t *testing.T) {
+	body := []byte(`{"name":"jp","ssn":"123-45-6789","cards":[{"number":"4111","exp":"01/20"},{"number":"5500","exp":"02/21"}]}`)
+	tokenize := HMACTokenizer([]byte("secret"))
+	tok, _ := tokenize("123-45-6789")
+
+	tests := []struct {
+		name string
+		hook PayloadHook
+
+		want string
+	}{
+		{
+			"redact",
+			RedactJSONPaths("$.cards[*].number", "$.missing.field"),
+			`{"cards":[{"exp":"01/20","number":"[REDACTED]"},{"exp":"02/21","number":"[REDACTED]"}],"name":"jp","ssn":"123-45-6789"}`,
+		},
+		{
+			"redact index",
+			RedactJSONPaths("$.cards[1].exp"),
+			`{"cards":[{"exp":"01/20","number":"4111"},{"exp":"[REDACTED]","number":"5500"}],"name":"jp","ssn":"123-45-6789"}`,
+		},
+		{
+			"tokenize",
+			TokenizeJSONPaths(tokenize, "$.ssn"),
+			`{"cards":[{"exp":"01/20","number":"4111"},{"exp":"02/21","number":"5500"}],"name":"jp","ssn":"` + tok + `"}`,
+		},
+	}
+
+	for _, test := range tests {
+		got, err := test.hook("key", body)
+		if err != nil {
+			t.Errorf("%s: expected no error, got %s", test.name, err)
+			continue
+		}
+		if string(got) != test.want {
+			t.Errorf("%s: expected %s, got %s", test.name, test.want, got)
+		}
+	}
+
+	if _, err := RedactJSONPaths("$.ssn")("key", []byte("not json")); err == nil {
+		t.Error("expected an error for a payload that is not JSON")
+	}
+}
+
+func TestInspectingPublisher(t *testing.T) {
+	pub := &testAttributePublisher{}
+	ip := NewInspectingPublisher(pub,
+		RedactProtoFields(&TestProto{}, "value"),
+	)
+	if err := ip.Publish("key", &TestProto{Value: "jp@example.com"}); err != nil {
+		t.Fatalf("expected no error, got %s", err)
+	}
+	var got TestProto
+	if err := proto.Unmarshal(pub.body, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Value != Redacted {
+		t.Errorf("expected value %q, got %q", Redacted, got.Value)
+	}
+
+	tokenize := HMACTokenizer([]byte("secret"))
+	want, _ := tokenize("jp@example.com")
+	ip = NewInspectingPublisher(pub, TokenizeProtoFields(&TestProto{}, tokenize, "value"))
+	if err := ip.PublishAttributes("key", mustMarshal(&TestProto{Value: "jp@example.com"}), map[string]string{"type": "user"}); err != nil {
+		t.Fatalf("expected no error, got %s", err)
+	}
+	if err := proto.Unmarshal(pub.body, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Value != want {
+		t.Errorf("expected value %q, got %q", want, got.Value)
+	}
+	if pub.attrs["type"] != "user" {
+		t.Errorf("expected attributes to be kept, got %v", pub.attrs)
+	}
+}
+
+func mustMarshal(m proto.Message) []byte {
+	b, _ := proto.Marshal(m)
+	return b
+}
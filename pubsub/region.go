@@ -0,0 +1,200 @@
+package pubsub
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/rcrowley/go-metrics"
+)
+
+var (
+	// DefaultRegionFailureThreshold is the number of consecutive failures
+	// after which a RegionalPublisher will stop preferring a region if no
+	// FailureThreshold is given.
+	DefaultRegionFailureThreshold = 3
+	// DefaultRegionCooldown is how long a RegionalPublisher will avoid a
+	// failing region if no Cooldown is given.
+	DefaultRegionCooldown = 30 * time.Second
+)
+
+// Region is a Publisher or Subscriber for a topic or queue in a single
+// region.
+type Region struct {
+	// Name identifies the region, like 'us-east-1'.
+	Name string
+	// Publisher publishes to the region's topic, for a RegionalPublisher.
+	Publisher Publisher
+	// Subscriber consumes the region's queue, for a
+	// MultiRegionSubscriber.
+	Subscriber Subscriber
+}
+
+// RegionStatus describes the health of a region of a RegionalPublisher.
+type RegionStatus struct {
+	Name string
+	// Healthy is false while the region is being avoided after failing.
+	Healthy bool
+	// Latency is the moving average latency of publishes to the region.
+	Latency time.Duration
+}
+
+// RegionalPublisher is a Publisher for active-active deployments that
+// publishes each message to one of several regions. Healthy regions are
+// tried in order of their average publish latency, followed by any that
+// have not been published to yet in the order they were given. Once a region fails FailureThreshold times in a
+// row, it is avoided for the Cooldown and only tried if every healthy region
+// fails too. Each publish that fails over to another region is counted in
+// the 'pubsub.regional.FAILOVER' metric.
+//
+// Errors that another region would fail with too, like ErrMessageTooLarge,
+// are returned without failing over.
+type RegionalPublisher struct {
+	// FailureThreshold is the number of consecutive failures after which a
+	// region is avoided. Defaults to DefaultRegionFailureThreshold.
+	FailureThreshold int
+	// Cooldown is how long a failing region is avoided. Defaults to
+	// DefaultRegionCooldown.
+	Cooldown time.Duration
+	// MetricsRegistry will override the default metrics registry if set.
+	MetricsRegistry metrics.Registry
+
+	mu      sync.Mutex
+	regions []*publishRegion
+}
+
+type publishRegion struct {
+	Region
+	// the moving average latency, 0 until the first success
+	latency   time.Duration
+	failures  int
+	downUntil time.Time
+}
+
+// NewRegionalPublisher will return a RegionalPublisher over the given
+// regions, which should list the local region first.
+func NewRegionalPublisher(regions ...Region) (*RegionalPublisher, error) {
+	if len(regions) == 0 {
+		return nil, errors.New("at least 1 region is required")
+	}
+	p := &RegionalPublisher{}
+	for _, r := range regions {
+		if r.Publisher == nil {
+			return nil, errors.New("region " + r.Name + " has no publisher")
+		}
+		p.regions = append(p.regions, &publishRegion{Region: r})
+	}
+	return p, nil
+}
+
+// Publish will marshal the proto message and publish it to a region.
+func (p *RegionalPublisher) Publish(key string, m proto.Message) error {
+	mb, err := proto.Marshal(m)
+	if err != nil {
+		return err
+	}
+	return p.PublishRaw(key, mb)
+}
+
+// PublishRaw will publish the byte array to the first region that accepts
+// it, returning the last error if none do.
+func (p *RegionalPublisher) PublishRaw(key string, m []byte) error {
+	var (
+		err  error
+		prev *publishRegion
+	)
+	for _, r := range p.route(time.Now()) {
+		if prev != nil {
+			Log.Warnf("unable to publish to region %s, failing over to %s: %s", prev.Name, r.Name, err)
+			metrics.GetOrRegisterCounter("pubsub.regional.FAILOVER", p.MetricsRegistry).Inc(1)
+		}
+		prev = r
+		start := time.Now()
+		err = r.Publisher.PublishRaw(key, m)
+		p.record(r, time.Since(start), err)
+		if err == nil || ErrorClass(err) == ErrMessageTooLarge {
+			return err
+		}
+	}
+	return err
+}
+
+// Status will return the health of each region, in the order they were
+// given.
+func (p *RegionalPublisher) Status() []RegionStatus {
+	now := time.Now()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	status := make([]RegionStatus, len(p.regions))
+	for i, r := range p.regions {
+		status[i] = RegionStatus{
+			Name:    r.Name,
+			Healthy: !now.Before(r.downUntil),
+			Latency: r.latency,
+		}
+	}
+	return status
+}
+
+// route will return the regions in the order they should be tried.
+func (p *RegionalPublisher) route(now time.Time) []*publishRegion {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var healthy, down []*publishRegion
+	for _, r := range p.regions {
+		if now.Before(r.downUntil) {
+			down = append(down, r)
+			continue
+		}
+		healthy = append(healthy, r)
+	}
+	sort.Stable(byLatency(healthy))
+	return append(healthy, down...)
+}
+
+// record will update the region's health with the result of a publish.
+func (p *RegionalPublisher) record(r *publishRegion, latency time.Duration, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err != nil {
+		if ErrorClass(err) == ErrMessageTooLarge {
+			return
+		}
+		threshold := p.FailureThreshold
+		if threshold < 1 {
+			threshold = DefaultRegionFailureThreshold
+		}
+		cooldown := p.Cooldown
+		if cooldown == 0 {
+			cooldown = DefaultRegionCooldown
+		}
+		r.failures++
+		if r.failures >= threshold {
+			if !time.Now().Before(r.downUntil) {
+				Log.Errorf("region %s failed %d publishes in a row, avoiding it for %s", r.Name, r.failures, cooldown)
+			}
+			r.downUntil = time.Now().Add(cooldown)
+		}
+		return
+	}
+	r.failures = 0
+	r.downUntil = time.Time{}
+	if r.latency == 0 {
+		r.latency = latency
+		return
+	}
+	// weigh the latest publish at 1/8th of the average
+	r.latency += (latency - r.latency) / 8
+}
+
+type byLatency []*publishRegion
+
+func (s byLatency) Len() int      { return len(s) }
+func (s byLatency) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+
+// Less will put regions with known latencies first, fastest first.
+func (s byLatency) Less(i, j int) bool {
+	return s[i].latency != 0 && (s[j].latency == 0 || s[i].latency < s[j].latency)
+}
@@ -0,0 +1,112 @@
+package pubsub
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/rcrowley/go-metrics"
+)
+
+// testRegionPublisher counts its publishes and fails while err is set.
+type testRegionPublisher struct {
+	err       error
+	published int
+}
+
+func (p *testRegionPublisher) Publish(key string, m proto.Message) error {
+	return p.PublishRaw(key, nil)
+}
+
+func (p *testRegionPublisher) PublishRaw(key string, m []byte) error {
+	if p.err != nil {
+		return p.err
+	}
+	p.published++
+	return nil
+}
+
+func TestRegionalPublisherFailover(t *testing.T) {
+	east := &testRegionPublisher{err: errors.New("region down")}
+	west := &testRegionPublisher{}
+	pub, err := NewRegionalPublisher(
+		Region{Name: "us-east-1", Publisher: east},
+		Region{Name: "us-west-2", Publisher: west},
+	)
+	if err != nil {
+		t.Fatalf("expected no error, got %s", err)
+	}
+	pub.FailureThreshold = 1
+	pub.Cooldown = time.Minute
+	pub.MetricsRegistry = metrics.NewRegistry()
+
+	for i := 0; i < 3; i++ {
+		if err := pub.PublishRaw("key", []byte("msg")); err != nil {
+			t.Fatalf("expected no error, got %s", err)
+		}
+	}
+	if west.published != 3 {
+		t.Errorf("expected 3 publishes to us-west-2, got %d", west.published)
+	}
+	// us-east-1 is avoided once it has failed
+	if got := metrics.GetOrRegisterCounter("pubsub.regional.FAILOVER", pub.MetricsRegistry).Count(); got != 1 {
+		t.Errorf("expected 1 failover, got %d", got)
+	}
+	status := pub.Status()
+	if status[0].Healthy || !status[1].Healthy {
+		t.Errorf("expected only us-west-2 to be healthy, got %+v", status)
+	}
+
+	west.err = errors.New("also down")
+	if err := pub.PublishRaw("key", []byte("msg")); err == nil {
+		t.Error("expected an error once every region is down")
+	}
+}
+
+func TestNewRegionalPublisherErrors(t *testing.T) {
+	if _, err := NewRegionalPublisher(); err == nil {
+		t.Error("expected an error without any regions, got none")
+	}
+	if _, err := NewRegionalPublisher(Region{Name: "us-east-1"}); err == nil {
+		t.Error("expected an error for a region without a publisher, got none")
+	}
+}
+
+func TestRegionalPublisherTooLarge(t *testing.T) {
+	east := &testRegionPublisher{err: &Error{Class: ErrMessageTooLarge, Err: errors.New("too big")}}
+	west := &testRegionPublisher{}
+	pub, _ := NewRegionalPublisher(
+		Region{Name: "us-east-1", Publisher: east},
+		Region{Name: "us-west-2", Publisher: west},
+	)
+
+	err := pub.PublishRaw("key", []byte("msg"))
+	if ErrorClass(err) != ErrMessageTooLarge {
+		t.Errorf("expected ErrMessageTooLarge, got %v", err)
+	}
+	if west.published != 0 {
+		t.Errorf("expected no failover, got %d publishes to us-west-2", west.published)
+	}
+}
+
+func TestRegionalPublisherLatency(t *testing.T) {
+	pub, _ := NewRegionalPublisher(
+		Region{Name: "us-east-1", Publisher: &testRegionPublisher{}},
+		Region{Name: "us-west-2", Publisher: &testRegionPublisher{}},
+		Region{Name: "eu-west-1", Publisher: &testRegionPublisher{}},
+	)
+	pub.regions[0].latency = 50 * time.Millisecond
+	pub.regions[1].latency = 10 * time.Millisecond
+
+	var got []string
+	for _, r := range pub.route(time.Now()) {
+		got = append(got, r.Name)
+	}
+	want := []string{"us-west-2", "us-east-1", "eu-west-1"}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("expected route %v, got %v", want, got)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package pubsub
+
+import (
+	"errors"
+	"time"
+
+	"github.com/NYTimes/gizmo/cron/cronexpr"
+)
+
+// ConsumptionSchedule describes the windows of time a Consumer may receive
+// messages in, for workloads that must only run off-peak against a fragile
+// downstream. Set a Consumer's Enabled to its Enabled method and the
+// Consumer will pause outside of the windows, staying healthy until the next
+// window opens. Only a RestartableSubscriber, like SQS or GCP, is stopped
+// while paused and leaves all of its messages in the queue. Any other
+// Subscriber may hold a message it has already received until the Consumer
+// resumes.
+type ConsumptionSchedule struct {
+	windows []cronexpr.Schedule
+	loc     *time.Location
+	now     func() time.Time
+}
+
+// NewConsumptionSchedule will return a ConsumptionSchedule that is active
+// during every minute matched by any of the cron specs, evaluated in loc,
+// or UTC if loc is nil. Specs are standard 5 field cron specs, so
+// '* 1-5 * * *' is active from 1:00 to 5:59 every day and
+// '* 22-23,0-5 * * sat,sun' overnight on weekends.
+func NewConsumptionSchedule(loc *time.Location, specs ...string) (*ConsumptionSchedule, error) {
+	if len(specs) == 0 {
+		return nil, errors.New("at least 1 consumption window is required")
+	}
+	if loc == nil {
+		loc = time.UTC
+	}
+	s := &ConsumptionSchedule{loc: loc, now: time.Now}
+	for _, spec := range specs {
+		w, err := cronexpr.Parse(spec)
+		if err != nil {
+			return nil, err
+		}
+		s.windows = append(s.windows, w)
+	}
+	return s, nil
+}
+
+// Active will return true if t falls within any of the windows.
+func (s *ConsumptionSchedule) Active(t time.Time) bool {
+	minute := t.In(s.loc).Truncate(time.Minute)
+	for _, w := range s.windows {
+		// Next looks from the following minute, so start just before this one
+		if w.Next(minute.Add(-time.Nanosecond)).Equal(minute) {
+			return true
+		}
+	}
+	return false
+}
+
+// Enabled will return true if a window is currently open. It is meant for
+// a Consumer's Enabled hook.
+func (s *ConsumptionSchedule) Enabled() bool {
+	return s.Active(s.now())
+}
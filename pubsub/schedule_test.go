@@ -0,0 +1,50 @@
+package pubsub
+
+import (
+	"testing"
+	"time"
+)
+
+func TestConsumptionSchedule(t *testing.T) {
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip("time zone data unavailable: ", err)
+	}
+	s, err := NewConsumptionSchedule(ny, "* 1-5 * * *", "* 22-23 * * sat,sun")
+	if err != nil {
+		t.Fatalf("expected no error, got %s", err)
+	}
+
+	tests := []struct {
+		given time.Time
+		want  bool
+	}{
+		// a weekday
+		{time.Date(2017, 3, 1, 0, 59, 0, 0, ny), false},
+		{time.Date(2017, 3, 1, 1, 0, 0, 0, ny), true},
+		{time.Date(2017, 3, 1, 5, 59, 59, 0, ny), true},
+		{time.Date(2017, 3, 1, 6, 0, 0, 0, ny), false},
+		{time.Date(2017, 3, 1, 22, 30, 0, 0, ny), false},
+		// a Saturday
+		{time.Date(2017, 3, 4, 22, 30, 0, 0, ny), true},
+		// windows are evaluated in the schedule's location
+		{time.Date(2017, 3, 1, 7, 0, 0, 0, time.UTC), true},
+	}
+	for _, test := range tests {
+		if got := s.Active(test.given); got != test.want {
+			t.Errorf("expected %s to be active %t, got %t", test.given, test.want, got)
+		}
+	}
+
+	s.now = func() time.Time { return time.Date(2017, 3, 1, 2, 0, 0, 0, ny) }
+	if !s.Enabled() {
+		t.Error("expected the schedule to be enabled within a window")
+	}
+
+	if _, err = NewConsumptionSchedule(nil); err == nil {
+		t.Error("expected an error without any windows, got none")
+	}
+	if _, err = NewConsumptionSchedule(nil, "nope"); err == nil {
+		t.Error("expected an error for an invalid window, got none")
+	}
+}
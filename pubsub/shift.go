@@ -0,0 +1,182 @@
+package pubsub
+
+import (
+	"math"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+// ShiftingSubscriber is a Subscriber that consumes from two Subscribers at
+// once and shifts a configurable share of consumption from one to the other,
+// such as while migrating a service from SQS to Kafka. The share can be
+// changed at runtime with SetWeight to gradually move traffic over, and a
+// weight of 1 will stop reading the old Subscriber altogether so it can be
+// drained by other consumers.
+//
+// The weight is the fraction of messages that will be read from the new
+// Subscriber while both have messages waiting. If the preferred side is idle,
+// messages are read from the other, as long as its weight is above 0. Each
+// message read is counted in the 'pubsub.shifting.from.RECEIVED' and
+// 'pubsub.shifting.to.RECEIVED' metrics.
+type ShiftingSubscriber struct {
+	// MetricsRegistry will override the default metrics registry if set.
+	MetricsRegistry metrics.Registry
+
+	from, to Subscriber
+	// the weight's float64 bits, accessed atomically
+	weight  uint64
+	changed chan struct{}
+
+	mu  sync.Mutex
+	err error
+
+	stop     chan struct{}
+	stopOnce sync.Once
+	done     chan struct{}
+}
+
+// NewShiftingSubscriber will return a ShiftingSubscriber that reads the given
+// fraction of messages, between 0 and 1, from the to Subscriber and the rest
+// from the from Subscriber.
+func NewShiftingSubscriber(from, to Subscriber, weight float64) *ShiftingSubscriber {
+	s := &ShiftingSubscriber{
+		from:    from,
+		to:      to,
+		changed: make(chan struct{}, 1),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	s.SetWeight(weight)
+	return s
+}
+
+// SetWeight will change the fraction of messages, between 0 and 1, that are
+// read from the to Subscriber. It is safe to call while the subscriber is
+// running.
+func (s *ShiftingSubscriber) SetWeight(weight float64) {
+	weight = math.Max(0, math.Min(1, weight))
+	atomic.StoreUint64(&s.weight, math.Float64bits(weight))
+	select {
+	case s.changed <- struct{}{}:
+	default:
+	}
+}
+
+// Weight will return the fraction of messages being read from the to
+// Subscriber.
+func (s *ShiftingSubscriber) Weight() float64 {
+	return math.Float64frombits(atomic.LoadUint64(&s.weight))
+}
+
+// Start will start both Subscribers and emit their messages. If either
+// Subscriber's channel is closed, it will populate the Err() error and close
+// the returned channel.
+func (s *ShiftingSubscriber) Start() <-chan SubscriberMessage {
+	output := make(chan SubscriberMessage)
+	go func() {
+		defer close(s.done)
+		defer close(output)
+		if err := s.run(output); err != nil {
+			s.mu.Lock()
+			s.err = err
+			s.mu.Unlock()
+		}
+	}()
+	return output
+}
+
+func (s *ShiftingSubscriber) run(output chan<- SubscriberMessage) error {
+	var (
+		fromMsgs = s.from.Start()
+		toMsgs   = s.to.Start()
+		fromRecv = metrics.GetOrRegisterCounter("pubsub.shifting.from.RECEIVED", s.MetricsRegistry)
+		toRecv   = metrics.GetOrRegisterCounter("pubsub.shifting.to.RECEIVED", s.MetricsRegistry)
+	)
+	for {
+		from, to := fromMsgs, toMsgs
+		weight := s.Weight()
+		if weight <= 0 {
+			to = nil
+		}
+		if weight >= 1 {
+			from = nil
+		}
+
+		// try the preferred side first so the weight holds while both are busy
+		preferred := from
+		if rand.Float64() < weight {
+			preferred = to
+		}
+		var (
+			msg SubscriberMessage
+			ok  bool
+			got bool
+		)
+		select {
+		case msg, ok = <-preferred:
+			got = true
+			if preferred == from {
+				to = nil
+			} else {
+				from = nil
+			}
+		default:
+		}
+		if !got {
+			select {
+			case <-s.stop:
+				return nil
+			case <-s.changed:
+				continue
+			case msg, ok = <-from:
+				to = nil
+			case msg, ok = <-to:
+				from = nil
+			}
+		}
+
+		if from != nil {
+			if !ok {
+				return s.from.Err()
+			}
+			fromRecv.Inc(1)
+		} else {
+			if !ok {
+				return s.to.Err()
+			}
+			toRecv.Inc(1)
+		}
+
+		select {
+		case <-s.stop:
+			return nil
+		case output <- msg:
+		}
+	}
+}
+
+// Stop will stop emitting messages and stop both Subscribers, returning the
+// first error encountered.
+func (s *ShiftingSubscriber) Stop() error {
+	s.stopOnce.Do(func() {
+		close(s.stop)
+	})
+	<-s.done
+	ferr := s.from.Stop()
+	terr := s.to.Stop()
+	if ferr != nil {
+		return ferr
+	}
+	return terr
+}
+
+// Err will contain any errors that occurred during consumption. This
+// method should be checked after a user encounters a closed channel.
+func (s *ShiftingSubscriber) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
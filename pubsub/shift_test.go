@@ -0,0 +1,81 @@
+package pubsub
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+func TestShiftingSubscriber(t *testing.T) {
+	from, to := newTestChanSubscriber(), newTestChanSubscriber()
+	sub := NewShiftingSubscriber(from, to, 0)
+	sub.MetricsRegistry = metrics.NewRegistry()
+	msgs := sub.Start()
+
+	send := func(side *testChanSubscriber, body string) bool {
+		select {
+		case side.msgs <- &testConsumerMessage{msg: []byte(body)}:
+			return true
+		case <-time.After(20 * time.Millisecond):
+			return false
+		}
+	}
+	recv := func(want string) {
+		select {
+		case msg, ok := <-msgs:
+			if !ok {
+				t.Errorf("expected %q, got a closed channel", want)
+				return
+			}
+			if string(msg.Message()) != want {
+				t.Errorf("expected %q, got %q", want, msg.Message())
+			}
+		case <-time.After(time.Second):
+			t.Errorf("timed out waiting for %q", want)
+		}
+	}
+
+	// with a weight of 0 only the old subscriber is read
+	go recv("old")
+	if !send(from, "old") {
+		t.Fatal("expected the old subscriber to be read")
+	}
+	if send(to, "new") {
+		t.Fatal("expected the new subscriber to not be read with a weight of 0")
+	}
+
+	// shifting everything over drains the old subscriber
+	sub.SetWeight(1)
+	go recv("new")
+	if !send(to, "new") {
+		t.Fatal("expected the new subscriber to be read")
+	}
+	if send(from, "old") {
+		t.Fatal("expected the old subscriber to not be read with a weight of 1")
+	}
+
+	// at half, both are read
+	sub.SetWeight(0.5)
+	received := make(chan struct{})
+	go func() {
+		recv("old")
+		recv("new")
+		close(received)
+	}()
+	if !send(from, "old") || !send(to, "new") {
+		t.Fatal("expected both subscribers to be read")
+	}
+	// let the last message be emitted before stopping
+	<-received
+
+	if err := sub.Stop(); err != nil {
+		t.Error("unexpected error stopping: ", err)
+	}
+	if got := metrics.GetOrRegisterCounter("pubsub.shifting.from.RECEIVED", sub.MetricsRegistry).Count(); got != 2 {
+		t.Errorf("expected 2 messages from the old subscriber, got %d", got)
+	}
+	if got := metrics.GetOrRegisterCounter("pubsub.shifting.to.RECEIVED", sub.MetricsRegistry).Count(); got != 2 {
+		t.Errorf("expected 2 messages from the new subscriber, got %d", got)
+	}
+}
@@ -0,0 +1,216 @@
+package pubsub
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"sync"
+
+	"github.com/golang/protobuf/proto"
+	"golang.org/x/crypto/ed25519"
+)
+
+const (
+	// SignatureAttribute is the message attribute that carries the base64
+	// encoded signature of messages published by a SigningPublisher.
+	SignatureAttribute = "signature"
+	// SignatureKeyIDAttribute is the message attribute that carries the ID of
+	// the key used to sign messages published by a SigningPublisher.
+	SignatureKeyIDAttribute = "signature_key_id"
+)
+
+var (
+	// ErrInvalidSignature is returned when a message's signature does not
+	// match its payload.
+	ErrInvalidSignature = errors.New("invalid message signature")
+	// ErrUnknownKey is returned when a message is signed with a key ID that
+	// a KeyRing does not have.
+	ErrUnknownKey = errors.New("unknown signing key")
+	// ErrUnsigned is returned when a message has no signature attributes.
+	ErrUnsigned = errors.New("message is not signed")
+)
+
+type (
+	// Signer signs message payloads with an identified key.
+	Signer interface {
+		// KeyID will return the ID of the key used to sign, which is sent
+		// along with the signature so verifiers can pick the right key.
+		KeyID() string
+		Sign(m []byte) ([]byte, error)
+	}
+
+	// Verifier checks the signature of a message payload made with the
+	// identified key.
+	Verifier interface {
+		Verify(keyID string, m, sig []byte) error
+	}
+
+	// HMACSigner is a Signer using HMAC-SHA256 with a shared secret.
+	HMACSigner struct {
+		ID     string
+		Secret []byte
+	}
+
+	// Ed25519Signer is a Signer using an Ed25519 private key, so consumers
+	// only need the public key to verify messages.
+	Ed25519Signer struct {
+		ID         string
+		PrivateKey ed25519.PrivateKey
+	}
+)
+
+// KeyID will return the ID of the secret.
+func (s *HMACSigner) KeyID() string {
+	return s.ID
+}
+
+// Sign will return the HMAC-SHA256 of the payload.
+func (s *HMACSigner) Sign(m []byte) ([]byte, error) {
+	mac := hmac.New(sha256.New, s.Secret)
+	mac.Write(m)
+	return mac.Sum(nil), nil
+}
+
+// KeyID will return the ID of the private key.
+func (s *Ed25519Signer) KeyID() string {
+	return s.ID
+}
+
+// Sign will return the Ed25519 signature of the payload.
+func (s *Ed25519Signer) Sign(m []byte) ([]byte, error) {
+	return ed25519.Sign(s.PrivateKey, m), nil
+}
+
+// KeyRing is a Verifier holding the keys of every trusted producer by ID.
+// Keys can be rotated by adding a new key, switching producers over to it
+// and then removing the old one.
+type KeyRing struct {
+	mu   sync.RWMutex
+	keys map[string]func(m, sig []byte) bool
+}
+
+// NewKeyRing will return an empty KeyRing.
+func NewKeyRing() *KeyRing {
+	return &KeyRing{keys: map[string]func(m, sig []byte) bool{}}
+}
+
+// AddHMAC will trust messages signed by an HMACSigner with the given ID
+// and secret.
+func (k *KeyRing) AddHMAC(id string, secret []byte) {
+	k.add(id, func(m, sig []byte) bool {
+		mac := hmac.New(sha256.New, secret)
+		mac.Write(m)
+		return hmac.Equal(sig, mac.Sum(nil))
+	})
+}
+
+// AddEd25519 will trust messages signed by an Ed25519Signer with the given ID
+// and the private key matching pub.
+func (k *KeyRing) AddEd25519(id string, pub ed25519.PublicKey) {
+	k.add(id, func(m, sig []byte) bool {
+		return ed25519.Verify(pub, m, sig)
+	})
+}
+
+func (k *KeyRing) add(id string, verify func(m, sig []byte) bool) {
+	k.mu.Lock()
+	k.keys[id] = verify
+	k.mu.Unlock()
+}
+
+// Remove will stop trusting the key with the given ID.
+func (k *KeyRing) Remove(id string) {
+	k.mu.Lock()
+	delete(k.keys, id)
+	k.mu.Unlock()
+}
+
+// Verify will check the signature with the key of the given ID.
+func (k *KeyRing) Verify(keyID string, m, sig []byte) error {
+	k.mu.RLock()
+	verify, ok := k.keys[keyID]
+	k.mu.RUnlock()
+	if !ok {
+		return ErrUnknownKey
+	}
+	if !verify(m, sig) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// SigningPublisher is a Publisher that signs the payload of every message
+// and sends the signature and key ID in the SignatureAttribute and
+// SignatureKeyIDAttribute message attributes.
+type SigningPublisher struct {
+	pub    AttributePublisher
+	signer Signer
+}
+
+// NewSigningPublisher will return a SigningPublisher that signs messages
+// with the given Signer before publishing them to pub.
+func NewSigningPublisher(pub AttributePublisher, signer Signer) *SigningPublisher {
+	return &SigningPublisher{pub: pub, signer: signer}
+}
+
+// Publish will marshal the proto message and publish it signed.
+func (p *SigningPublisher) Publish(key string, m proto.Message) error {
+	mb, err := proto.Marshal(m)
+	if err != nil {
+		return err
+	}
+	return p.PublishRaw(key, mb)
+}
+
+// PublishRaw will publish the byte array signed.
+func (p *SigningPublisher) PublishRaw(key string, m []byte) error {
+	return p.PublishAttributes(key, m, nil)
+}
+
+// PublishAttributes will publish the byte array signed along with the given
+// attributes. The attributes themselves are not signed.
+func (p *SigningPublisher) PublishAttributes(key string, m []byte, attrs map[string]string) error {
+	sig, err := p.signer.Sign(m)
+	if err != nil {
+		return err
+	}
+	out := make(map[string]string, len(attrs)+2)
+	for k, v := range attrs {
+		out[k] = v
+	}
+	out[SignatureAttribute] = base64.StdEncoding.EncodeToString(sig)
+	out[SignatureKeyIDAttribute] = p.signer.KeyID()
+	return p.pub.PublishAttributes(key, m, out)
+}
+
+// VerifySignature will check the signature attributes of the message
+// against its payload.
+func VerifySignature(v Verifier, msg SubscriberMessage) error {
+	sig, ok := attribute(msg, SignatureAttribute)
+	if !ok {
+		return ErrUnsigned
+	}
+	keyID, ok := attribute(msg, SignatureKeyIDAttribute)
+	if !ok {
+		return ErrUnsigned
+	}
+	sb, err := base64.StdEncoding.DecodeString(sig)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	return v.Verify(keyID, msg.Message(), sb)
+}
+
+// Verified will return a MessageFilter that only matches messages with a
+// valid signature, so a Consumer will discard any that are unsigned or
+// forged.
+func Verified(v Verifier) MessageFilter {
+	return func(msg SubscriberMessage) bool {
+		if err := VerifySignature(v, msg); err != nil {
+			Log.Warn("discarding message that failed verification: ", err)
+			return false
+		}
+		return true
+	}
+}
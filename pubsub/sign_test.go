@@ -0,0 +1,75 @@
+package pubsub
+
+import (
+	"crypto/rand"
+	"testing"
+
+	"golang.org/x/crypto/ed25519"
+)
+
+func TestSigningPublisher(t *testing.T) {
+	pubKey, privKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys := NewKeyRing()
+	keys.AddHMAC("hmac-1", []byte("secret"))
+	keys.AddEd25519("ed-1", pubKey)
+
+	tests := []struct {
+		name   string
+		signer Signer
+		body   []byte
+		tamper bool
+		want   error
+	}{
+		{"hmac", &HMACSigner{ID: "hmac-1", Secret: []byte("secret")}, []byte("hello"), false, nil},
+		{"ed25519", &Ed25519Signer{ID: "ed-1", PrivateKey: privKey}, []byte("hello"), false, nil},
+		{"wrong secret", &HMACSigner{ID: "hmac-1", Secret: []byte("guess")}, []byte("hello"), false, ErrInvalidSignature},
+		{"unknown key", &HMACSigner{ID: "hmac-2", Secret: []byte("secret")}, []byte("hello"), false, ErrUnknownKey},
+		{"tampered", &Ed25519Signer{ID: "ed-1", PrivateKey: privKey}, []byte("hello"), true, ErrInvalidSignature},
+	}
+
+	for _, test := range tests {
+		pub := &testAttributePublisher{}
+		sp := NewSigningPublisher(pub, test.signer)
+		if err := sp.PublishAttributes("key", test.body, map[string]string{"type": "cat"}); err != nil {
+			t.Fatalf("%s: expected no error, got %s", test.name, err)
+		}
+		if pub.attrs["type"] != "cat" {
+			t.Errorf("%s: expected attributes to be kept, got %v", test.name, pub.attrs)
+		}
+		body := pub.body
+		if test.tamper {
+			body = []byte("goodbye")
+		}
+		msg := &testAttributeMessage{testConsumerMessage{msg: body}, pub.attrs}
+		if got := VerifySignature(keys, msg); got != test.want {
+			t.Errorf("%s: expected %v, got %v", test.name, test.want, got)
+		}
+	}
+}
+
+func TestVerifiedFilter(t *testing.T) {
+	keys := NewKeyRing()
+	keys.AddHMAC("hmac-1", []byte("secret"))
+	filter := Verified(keys)
+
+	unsigned := &testAttributeMessage{testConsumerMessage{msg: []byte("hello")}, nil}
+	if filter(unsigned) {
+		t.Error("expected unsigned message to be discarded")
+	}
+
+	pub := &testAttributePublisher{}
+	NewSigningPublisher(pub, &HMACSigner{ID: "hmac-1", Secret: []byte("secret")}).PublishRaw("key", []byte("hello"))
+	signed := &testAttributeMessage{testConsumerMessage{msg: pub.body}, pub.attrs}
+	if !filter(signed) {
+		t.Error("expected signed message to be handled")
+	}
+
+	// rotating the key out should reject messages signed with it
+	keys.Remove("hmac-1")
+	if filter(signed) {
+		t.Error("expected message signed with a removed key to be discarded")
+	}
+}
@@ -0,0 +1,203 @@
+package pubsub
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+
+	"github.com/golang/protobuf/proto"
+	"golang.org/x/net/context"
+
+	"github.com/NYTimes/gizmo/objectstore"
+)
+
+// The strategies a SizeLimitPublisher can handle messages over its limit
+// with.
+const (
+	// SizeStrategyError fails the publish with an ErrMessageTooLarge Error.
+	SizeStrategyError = "error"
+	// SizeStrategyCompress gzips the message, failing the publish if it is
+	// still over the limit.
+	SizeStrategyCompress = "compress"
+	// SizeStrategyOffload stores the message in an objectstore.Store and
+	// publishes its key in its place.
+	SizeStrategyOffload = "offload"
+)
+
+const (
+	// ContentEncodingAttribute is the message attribute that marks messages
+	// compressed by a SizeLimitPublisher.
+	ContentEncodingAttribute = "content_encoding"
+	// ContentEncodingGzip is the ContentEncodingAttribute of gzipped
+	// messages.
+	ContentEncodingGzip = "gzip"
+	// OffloadedAttribute is the message attribute that carries the
+	// objectstore key of messages offloaded by a SizeLimitPublisher.
+	OffloadedAttribute = "offloaded_key"
+)
+
+// MaxSNSMessageSize is the most bytes SNS accepts in a message, counting
+// its attributes.
+const MaxSNSMessageSize = 256 * 1024
+
+// SizedPublisher is a Publisher that knows how large a message will be once
+// it is sent and how large its backend allows messages to be.
+type SizedPublisher interface {
+	Publisher
+	// MaxMessageSize will return the most bytes the backend accepts in a
+	// message.
+	MaxMessageSize() int
+	// MessageSize will return how many bytes the message will count for
+	// against the limit once encoded along with its attributes.
+	MessageSize(key string, m []byte, attrs map[string]string) int
+}
+
+// MessageSize will return how many bytes the message will count for against
+// the limit of pub. If pub is not a SizedPublisher, the size of the body and
+// attributes as they are is returned.
+func MessageSize(pub Publisher, key string, m []byte, attrs map[string]string) int {
+	if spub, ok := pub.(SizedPublisher); ok {
+		return spub.MessageSize(key, m, attrs)
+	}
+	n := len(m)
+	for k, v := range attrs {
+		n += len(k) + len(v)
+	}
+	return n
+}
+
+// SizeLimitPublisher is a Publisher that checks the size of every message
+// before publishing it and handles messages over the limit with a
+// configurable strategy, rather than leaving the backend to reject them
+// with an opaque error. Compressed and offloaded messages are marked with
+// the ContentEncodingAttribute and OffloadedAttribute message attributes,
+// and can be read back with ReadMessage.
+type SizeLimitPublisher struct {
+	// OffloadPrefix is prepended to the keys of offloaded messages.
+	OffloadPrefix string
+
+	pub      AttributePublisher
+	max      int
+	strategy string
+	store    objectstore.Store
+}
+
+// NewSizeLimitPublisher will return a SizeLimitPublisher that publishes
+// messages of up to max bytes to pub and handles larger messages with the
+// strategy, which defaults to SizeStrategyError. If max is 0, the
+// MaxMessageSize of pub is used. The store is only required for the
+// SizeStrategyOffload strategy. The lifetime of offloaded messages should be
+// managed with the store's own expiration rules.
+func NewSizeLimitPublisher(pub AttributePublisher, max int, strategy string, store objectstore.Store) (*SizeLimitPublisher, error) {
+	if max == 0 {
+		if spub, ok := pub.(SizedPublisher); ok {
+			max = spub.MaxMessageSize()
+		}
+	}
+	if max <= 0 {
+		return nil, errors.New("max message size is required")
+	}
+	switch strategy {
+	case "", SizeStrategyError, SizeStrategyCompress:
+	case SizeStrategyOffload:
+		if store == nil {
+			return nil, errors.New("an object store is required to offload messages")
+		}
+	default:
+		return nil, errors.New("unknown size strategy: " + strategy)
+	}
+	return &SizeLimitPublisher{
+		pub:      pub,
+		max:      max,
+		strategy: strategy,
+		store:    store,
+	}, nil
+}
+
+// Publish will marshal the proto message and publish it within the limit.
+func (p *SizeLimitPublisher) Publish(key string, m proto.Message) error {
+	mb, err := proto.Marshal(m)
+	if err != nil {
+		return err
+	}
+	return p.PublishRaw(key, mb)
+}
+
+// PublishRaw will publish the byte array within the limit.
+func (p *SizeLimitPublisher) PublishRaw(key string, m []byte) error {
+	return p.PublishAttributes(key, m, nil)
+}
+
+// PublishAttributes will publish the byte array along with the given
+// attributes, handling it with the publisher's strategy if it is over the
+// limit.
+func (p *SizeLimitPublisher) PublishAttributes(key string, m []byte, attrs map[string]string) error {
+	size := MessageSize(p.pub, key, m, attrs)
+	if size <= p.max {
+		return p.pub.PublishAttributes(key, m, attrs)
+	}
+
+	out := make(map[string]string, len(attrs)+1)
+	for k, v := range attrs {
+		out[k] = v
+	}
+	switch p.strategy {
+	case SizeStrategyCompress:
+		var buf bytes.Buffer
+		gw := gzip.NewWriter(&buf)
+		if _, err := gw.Write(m); err != nil {
+			return err
+		}
+		if err := gw.Close(); err != nil {
+			return err
+		}
+		out[ContentEncodingAttribute] = ContentEncodingGzip
+		m = buf.Bytes()
+	case SizeStrategyOffload:
+		okey := p.OffloadPrefix + contentToken(key, m)
+		if err := p.store.Put(context.Background(), okey, bytes.NewReader(m), "application/octet-stream"); err != nil {
+			return fmt.Errorf("unable to offload message: %s", err)
+		}
+		out[OffloadedAttribute] = okey
+		m = []byte(okey)
+	}
+
+	if size = MessageSize(p.pub, key, m, out); size > p.max {
+		return &Error{
+			Class: ErrMessageTooLarge,
+			Err:   fmt.Errorf("message of %d bytes exceeds the limit of %d bytes", size, p.max),
+		}
+	}
+	return p.pub.PublishAttributes(key, m, out)
+}
+
+// ReadMessage will return the payload of a message published by a
+// SizeLimitPublisher, fetching it from the store if it was offloaded and
+// decompressing it if it was compressed. Payloads of over max bytes fail
+// with an ErrMessageTooLarge Error, where a max of 0 uses the
+// DefaultMaxDecodedSize and a negative max has no limit. The store may be
+// nil if messages are never offloaded.
+func ReadMessage(ctx context.Context, store objectstore.Store, msg SubscriberMessage, max int64) ([]byte, error) {
+	m := msg.Message()
+	if okey, ok := attribute(msg, OffloadedAttribute); ok {
+		if store == nil {
+			return nil, errors.New("message was offloaded but no object store was given")
+		}
+		r, err := store.Get(ctx, okey)
+		if err != nil {
+			return nil, err
+		}
+		defer r.Close()
+		if m, err = readLimited(nil, r, maxDecodedSize(max)); err != nil {
+			return nil, err
+		}
+	}
+	if enc, ok := attribute(msg, ContentEncodingAttribute); ok {
+		if enc != ContentEncodingGzip {
+			return nil, errors.New("unknown content encoding: " + enc)
+		}
+		return Gunzip(m, max)
+	}
+	return m, nil
+}
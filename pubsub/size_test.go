@@ -0,0 +1,108 @@
+package pubsub
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/NYTimes/gizmo/objectstore"
+)
+
+// testStore is an in-memory objectstore.Store.
+type testStore struct {
+	objects map[string][]byte
+}
+
+func (s *testStore) Put(ctx context.Context, key string, r io.Reader, contentType string) error {
+	b, err := ioutil.ReadAll(r)
+	s.objects[key] = b
+	return err
+}
+
+func (s *testStore) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	b, ok := s.objects[key]
+	if !ok {
+		return nil, objectstore.ErrNotFound
+	}
+	return ioutil.NopCloser(bytes.NewReader(b)), nil
+}
+
+func (s *testStore) Delete(ctx context.Context, key string) error {
+	delete(s.objects, key)
+	return nil
+}
+
+func (s *testStore) PresignGet(key string, expires time.Duration) (string, error) {
+	return "", nil
+}
+
+func (s *testStore) PresignPut(key, contentType string, expires time.Duration) (string, error) {
+	return "", nil
+}
+
+func TestSizeLimitPublisher(t *testing.T) {
+	small := []byte("hi there!")
+	large := []byte(strings.Repeat("meow ", 200))
+
+	tests := []struct {
+		strategy string
+		body     []byte
+		wantErr  bool
+		attr     string
+	}{
+		{SizeStrategyError, small, false, ""},
+		{SizeStrategyError, large, true, ""},
+		{SizeStrategyCompress, large, false, ContentEncodingAttribute},
+		{SizeStrategyOffload, large, false, OffloadedAttribute},
+	}
+	for _, test := range tests {
+		store := &testStore{objects: map[string][]byte{}}
+		tpub := &testAttributePublisher{}
+		pub, err := NewSizeLimitPublisher(tpub, 200, test.strategy, store)
+		if err != nil {
+			t.Fatalf("expected no error, got %s", err)
+		}
+		pub.OffloadPrefix = "big/"
+
+		err = pub.PublishRaw("key", test.body)
+		if test.wantErr {
+			if ErrorClass(err) != ErrMessageTooLarge {
+				t.Errorf("%s: expected ErrMessageTooLarge, got %v", test.strategy, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: expected no error, got %s", test.strategy, err)
+		}
+		if test.attr != "" {
+			if _, ok := tpub.attrs[test.attr]; !ok {
+				t.Errorf("%s: expected the %s attribute, got %v", test.strategy, test.attr, tpub.attrs)
+			}
+		}
+		if len(tpub.body) > 200 {
+			t.Errorf("%s: expected a body of at most 200 bytes, got %d", test.strategy, len(tpub.body))
+		}
+
+		msg := &testAttributeMessage{attrs: tpub.attrs}
+		msg.msg = tpub.body
+		got, err := ReadMessage(context.Background(), store, msg, 0)
+		if err != nil {
+			t.Fatalf("%s: expected no error reading the message, got %s", test.strategy, err)
+		}
+		if !bytes.Equal(got, test.body) {
+			t.Errorf("%s: expected body %q, got %q", test.strategy, test.body, got)
+		}
+	}
+
+	if _, err := NewSizeLimitPublisher(&testAttributePublisher{}, 100, SizeStrategyOffload, nil); err == nil {
+		t.Error("expected an error offloading without a store, got none")
+	}
+	if _, err := NewSizeLimitPublisher(&testAttributePublisher{}, 0, "", nil); err == nil {
+		t.Error("expected an error without a max size, got none")
+	}
+}
@@ -0,0 +1,96 @@
+package pubsub
+
+import (
+	"sync"
+
+	"github.com/golang/protobuf/proto"
+	"golang.org/x/net/context"
+)
+
+// StagedPublisher is a Publisher that holds on to every message it is given
+// until Flush is called, at which point they are sent to the underlying
+// Publisher in the order they were staged. This allows a unit of work, like
+// an HTTP request writing to a database, to only publish once its writes
+// have been committed.
+type StagedPublisher struct {
+	pub Publisher
+
+	mu     sync.Mutex
+	staged []stagedMessage
+}
+
+type stagedMessage struct {
+	key  string
+	body []byte
+}
+
+// NewStagedPublisher will return a StagedPublisher that flushes to pub.
+func NewStagedPublisher(pub Publisher) *StagedPublisher {
+	return &StagedPublisher{pub: pub}
+}
+
+// Publish will marshal the message and stage it for publishing.
+func (p *StagedPublisher) Publish(key string, m proto.Message) error {
+	mb, err := proto.Marshal(m)
+	if err != nil {
+		return err
+	}
+	return p.PublishRaw(key, mb)
+}
+
+// PublishRaw will stage the byte array for publishing.
+func (p *StagedPublisher) PublishRaw(key string, m []byte) error {
+	p.mu.Lock()
+	p.staged = append(p.staged, stagedMessage{key, m})
+	p.mu.Unlock()
+	return nil
+}
+
+// Len will return the number of messages currently staged.
+func (p *StagedPublisher) Len() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.staged)
+}
+
+// Flush will publish all staged messages to the underlying Publisher. If
+// a publish fails, Flush will stop and return the error, leaving that message
+// and any after it staged so the flush may be retried.
+func (p *StagedPublisher) Flush() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for len(p.staged) > 0 {
+		msg := p.staged[0]
+		if err := p.pub.PublishRaw(msg.key, msg.body); err != nil {
+			return err
+		}
+		p.staged = p.staged[1:]
+	}
+	p.staged = nil
+	return nil
+}
+
+// Discard will drop all staged messages without publishing them.
+func (p *StagedPublisher) Discard() {
+	p.mu.Lock()
+	p.staged = nil
+	p.mu.Unlock()
+}
+
+type stagedKey int
+
+const stagedPublisherKey stagedKey = 0
+
+// NewStagedContext will return a copy of ctx carrying a new StagedPublisher
+// that flushes to pub, along with the StagedPublisher itself.
+func NewStagedContext(ctx context.Context, pub Publisher) (context.Context, *StagedPublisher) {
+	sp := NewStagedPublisher(pub)
+	return context.WithValue(ctx, stagedPublisherKey, sp), sp
+}
+
+// StagedPublisherFromContext will return the StagedPublisher carried by ctx,
+// if any.
+func StagedPublisherFromContext(ctx context.Context) (*StagedPublisher, bool) {
+	sp, ok := ctx.Value(stagedPublisherKey).(*StagedPublisher)
+	return sp, ok
+}
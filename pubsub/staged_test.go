@@ -0,0 +1,51 @@
+package pubsub_test
+
+import (
+	"errors"
+	"testing"
+
+	"golang.org/x/net/context"
+
+	"github.com/NYTimes/gizmo/pubsub"
+	"github.com/NYTimes/gizmo/pubsub/pubsubtest"
+)
+
+func TestStagedPublisher(t *testing.T) {
+	pub := &pubsubtest.TestPublisher{}
+	ctx, sp := pubsub.NewStagedContext(context.Background(), pub)
+	if got, ok := pubsub.StagedPublisherFromContext(ctx); !ok || got != sp {
+		t.Fatal("expected staged publisher in context")
+	}
+
+	sp.PublishRaw("a", []byte("1"))
+	sp.PublishRaw("b", []byte("2"))
+	if len(pub.Published) != 0 {
+		t.Fatalf("expected nothing published before flush, got %d", len(pub.Published))
+	}
+
+	pub.GivenError = errors.New("nope")
+	if err := sp.Flush(); err == nil {
+		t.Fatal("expected flush error")
+	}
+	if sp.Len() != 2 {
+		t.Errorf("expected 2 messages still staged, got %d", sp.Len())
+	}
+
+	pub.GivenError = nil
+	pub.Published = nil
+	if err := sp.Flush(); err != nil {
+		t.Fatal("unexpected flush error: ", err)
+	}
+	if len(pub.Published) != 2 || pub.Published[0].Key != "a" || pub.Published[1].Key != "b" {
+		t.Errorf("expected a and b published in order, got %+v", pub.Published)
+	}
+	if sp.Len() != 0 {
+		t.Errorf("expected nothing staged after flush, got %d", sp.Len())
+	}
+
+	sp.PublishRaw("c", []byte("3"))
+	sp.Discard()
+	if err := sp.Flush(); err != nil || len(pub.Published) != 2 {
+		t.Errorf("expected discarded message to never publish, got %+v", pub.Published)
+	}
+}
@@ -0,0 +1,145 @@
+package pubsub
+
+import (
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/rcrowley/go-metrics"
+	"golang.org/x/net/context"
+)
+
+// TenantAttribute is the message attribute that carries the ID of the
+// tenant a message belongs to.
+const TenantAttribute = "tenant_id"
+
+// noTenant is the name used in metrics for messages without a tenant.
+const noTenant = "none"
+
+type tenantKey int
+
+const tenantContextKey tenantKey = 0
+
+// WithTenant will return a copy of ctx carrying the tenant ID.
+func WithTenant(ctx context.Context, tenant string) context.Context {
+	return context.WithValue(ctx, tenantContextKey, tenant)
+}
+
+// TenantFromContext will return the tenant ID carried by ctx, if any.
+func TenantFromContext(ctx context.Context) (string, bool) {
+	tenant, ok := ctx.Value(tenantContextKey).(string)
+	return tenant, ok
+}
+
+// Tenant will return the tenant ID of the message, or an empty string if it
+// does not have one.
+func Tenant(msg SubscriberMessage) string {
+	tenant, _ := attribute(msg, TenantAttribute)
+	return tenant
+}
+
+// TenantFilter will return a MessageFilter that matches messages belonging
+// to any of the given tenants.
+func TenantFilter(tenants ...string) MessageFilter {
+	set := make(map[string]bool, len(tenants))
+	for _, t := range tenants {
+		set[t] = true
+	}
+	return func(msg SubscriberMessage) bool {
+		tenant, ok := attribute(msg, TenantAttribute)
+		return ok && set[tenant]
+	}
+}
+
+// TenantPublisher is a Publisher that stamps every message with a tenant ID
+// in the TenantAttribute message attribute.
+type TenantPublisher struct {
+	pub    AttributePublisher
+	tenant string
+}
+
+// NewTenantPublisher will return a TenantPublisher that publishes messages
+// for the given tenant to pub.
+func NewTenantPublisher(pub AttributePublisher, tenant string) *TenantPublisher {
+	return &TenantPublisher{pub: pub, tenant: tenant}
+}
+
+// Publish will marshal the proto message and publish it for the tenant.
+func (p *TenantPublisher) Publish(key string, m proto.Message) error {
+	mb, err := proto.Marshal(m)
+	if err != nil {
+		return err
+	}
+	return p.PublishRaw(key, mb)
+}
+
+// PublishRaw will publish the byte array for the tenant.
+func (p *TenantPublisher) PublishRaw(key string, m []byte) error {
+	return p.PublishAttributes(key, m, nil)
+}
+
+// PublishAttributes will publish the byte array for the tenant along with
+// the given attributes.
+func (p *TenantPublisher) PublishAttributes(key string, m []byte, attrs map[string]string) error {
+	out := make(map[string]string, len(attrs)+1)
+	for k, v := range attrs {
+		out[k] = v
+	}
+	out[TenantAttribute] = p.tenant
+	return p.pub.PublishAttributes(key, m, out)
+}
+
+// TenantOptions configure a TenantHandler.
+type TenantOptions struct {
+	// RateLimit is the max number of messages per second that will be
+	// handled for each tenant. Unlimited if 0.
+	RateLimit float64
+	// MetricsRegistry will override the default metrics registry for the
+	// per-tenant metrics if set.
+	MetricsRegistry metrics.Registry
+}
+
+// TenantHandler will return a MessageHandler that passes each message to h
+// with its tenant ID added to the context, for use with TenantFromContext.
+// The outcome of each message is recorded in the
+// 'pubsub.tenant.<tenant>.HANDLED', 'ERROR' and 'DURATION' metrics, and
+// messages can be rate limited per tenant so a single busy tenant cannot
+// starve the rest of a shared queue. Rate limits hold up the handler, so the
+// Consumer should have enough Concurrency for the other tenants to proceed.
+func TenantHandler(h MessageHandler, opts TenantOptions) MessageHandler {
+	var (
+		mu     sync.Mutex
+		limits = map[string]*rateLimiter{}
+	)
+	limit := func(tenant string) {
+		if opts.RateLimit <= 0 {
+			return
+		}
+		mu.Lock()
+		l, ok := limits[tenant]
+		if !ok {
+			l = &rateLimiter{interval: time.Duration(float64(time.Second) / opts.RateLimit)}
+			limits[tenant] = l
+		}
+		mu.Unlock()
+		l.wait()
+	}
+
+	return func(ctx context.Context, msg SubscriberMessage) error {
+		tenant := Tenant(msg)
+		name := tenant
+		if name == "" {
+			name = noTenant
+		}
+		limit(name)
+
+		start := time.Now()
+		err := h(WithTenant(ctx, tenant), msg)
+		metrics.GetOrRegisterTimer("pubsub.tenant."+name+".DURATION", opts.MetricsRegistry).UpdateSince(start)
+		metrics.GetOrRegisterCounter("pubsub.tenant."+name+".HANDLED", opts.MetricsRegistry).Inc(1)
+		if err != nil {
+			metrics.GetOrRegisterCounter("pubsub.tenant."+name+".ERROR", opts.MetricsRegistry).Inc(1)
+		}
+		return err
+	}
+}
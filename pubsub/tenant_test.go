@@ -0,0 +1,76 @@
+package pubsub
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+	"golang.org/x/net/context"
+)
+
+func TestTenantPublisher(t *testing.T) {
+	pub := &testAttributePublisher{}
+	tp := NewTenantPublisher(pub, "acme")
+	if err := tp.PublishAttributes("key", []byte("hello"), map[string]string{"type": "cat"}); err != nil {
+		t.Fatalf("expected no error, got %s", err)
+	}
+	if got := pub.attrs[TenantAttribute]; got != "acme" {
+		t.Errorf("expected tenant 'acme', got %q", got)
+	}
+	if got := pub.attrs["type"]; got != "cat" {
+		t.Errorf("expected attributes to be kept, got %v", pub.attrs)
+	}
+
+	filter := TenantFilter("acme", "globex")
+	msg := &testAttributeMessage{testConsumerMessage{msg: pub.body}, pub.attrs}
+	if !filter(msg) {
+		t.Error("expected message for 'acme' to match")
+	}
+	other := &testAttributeMessage{testConsumerMessage{msg: pub.body}, map[string]string{TenantAttribute: "initech"}}
+	if filter(other) {
+		t.Error("expected message for 'initech' not to match")
+	}
+}
+
+func TestTenantHandler(t *testing.T) {
+	registry := metrics.NewRegistry()
+	var got []string
+	h := TenantHandler(func(ctx context.Context, msg SubscriberMessage) error {
+		tenant, _ := TenantFromContext(ctx)
+		got = append(got, tenant)
+		if string(msg.Message()) == "bad" {
+			return errors.New("bad message")
+		}
+		return nil
+	}, TenantOptions{RateLimit: 20, MetricsRegistry: registry})
+
+	msgs := []SubscriberMessage{
+		&testAttributeMessage{testConsumerMessage{msg: []byte("good")}, map[string]string{TenantAttribute: "acme"}},
+		&testAttributeMessage{testConsumerMessage{msg: []byte("bad")}, map[string]string{TenantAttribute: "acme"}},
+		&testAttributeMessage{testConsumerMessage{msg: []byte("good")}, map[string]string{TenantAttribute: "globex"}},
+		&testConsumerMessage{msg: []byte("good")},
+	}
+	start := time.Now()
+	for _, msg := range msgs {
+		h(context.Background(), msg)
+	}
+	// only the second 'acme' message should have been held up
+	if took := time.Since(start); took < 40*time.Millisecond || took > time.Second {
+		t.Errorf("expected a single rate limit delay, took %s", took)
+	}
+
+	if want := []string{"acme", "acme", "globex", ""}; !reflect.DeepEqual(got, want) {
+		t.Errorf("expected tenants %v, got %v", want, got)
+	}
+	if c := metrics.GetOrRegisterCounter("pubsub.tenant.acme.HANDLED", registry).Count(); c != 2 {
+		t.Errorf("expected 2 handled for 'acme', got %d", c)
+	}
+	if c := metrics.GetOrRegisterCounter("pubsub.tenant.acme.ERROR", registry).Count(); c != 1 {
+		t.Errorf("expected 1 error for 'acme', got %d", c)
+	}
+	if c := metrics.GetOrRegisterCounter("pubsub.tenant.none.HANDLED", registry).Count(); c != 1 {
+		t.Errorf("expected 1 handled without a tenant, got %d", c)
+	}
+}
@@ -0,0 +1,170 @@
+package pubsub
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultTraceSize is the number of messages a MessageTracer will keep for
+// each consumer if no size is given.
+var DefaultTraceSize = 50
+
+// DefaultMessageTracer is the MessageTracer the server package serves at the
+// MessageTracePath admin endpoint. Consumers record to it once their Tracer
+// is set to it.
+var DefaultMessageTracer = NewMessageTracer(DefaultTraceSize)
+
+// The outcomes of a traced message.
+const (
+	// TraceHandled is the outcome of a message the handler succeeded with.
+	TraceHandled = "handled"
+	// TraceFailed is the outcome of a message that returned an error,
+	// panicked or could not be marked as done.
+	TraceFailed = "failed"
+	// TraceTimeout is the outcome of a message whose handler passed the
+	// Consumer's Timeout.
+	TraceTimeout = "timeout"
+	// TraceFiltered is the outcome of a message discarded by the Filter.
+	TraceFiltered = "filtered"
+)
+
+// MessageTrace describes a message a Consumer has received.
+type MessageTrace struct {
+	// ID is the message's ID, if its Subscriber provides one.
+	ID string `json:"id,omitempty"`
+	// Subject is the message's 'subject' attribute or key, if it has one.
+	Subject string `json:"subject,omitempty"`
+	// Size is the length of the message body in bytes.
+	Size int `json:"size"`
+	// Received is when the Consumer started with the message.
+	Received time.Time `json:"received"`
+	// Latency is how long the message took to handle.
+	Latency time.Duration `json:"-"`
+	// Outcome is one of TraceHandled, TraceFailed, TraceTimeout or
+	// TraceFiltered.
+	Outcome string `json:"outcome"`
+	// Error is the error the message failed with, if any.
+	Error string `json:"error,omitempty"`
+}
+
+// MarshalJSON will encode the trace with a readable latency, like '12ms'.
+func (t MessageTrace) MarshalJSON() ([]byte, error) {
+	type trace MessageTrace
+	return json.Marshal(struct {
+		trace
+		Latency string `json:"latency"`
+	}{trace(t), t.Latency.String()})
+}
+
+// MessageTracer keeps the last few messages received by each named Consumer
+// in memory, for quick production debugging of whether a consumer is seeing
+// anything. It is an http.Handler that serves them as JSON, newest first,
+// for all consumers or for the one given in the 'name' query parameter.
+type MessageTracer struct {
+	size int
+
+	mu    sync.Mutex
+	rings map[string]*traceRing
+}
+
+type traceRing struct {
+	traces []MessageTrace
+	next   int
+}
+
+// NewMessageTracer will return a MessageTracer that keeps the last size
+// messages of each consumer. If size is less than 1, DefaultTraceSize is
+// used.
+func NewMessageTracer(size int) *MessageTracer {
+	if size < 1 {
+		size = DefaultTraceSize
+	}
+	return &MessageTracer{size: size, rings: map[string]*traceRing{}}
+}
+
+// Record will add the trace to the named consumer's history, replacing its
+// oldest trace once it is full.
+func (t *MessageTracer) Record(name string, trace MessageTrace) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	r, ok := t.rings[name]
+	if !ok {
+		r = &traceRing{traces: make([]MessageTrace, 0, t.size)}
+		t.rings[name] = r
+	}
+	if len(r.traces) < t.size {
+		r.traces = append(r.traces, trace)
+		return
+	}
+	r.traces[r.next] = trace
+	r.next = (r.next + 1) % t.size
+}
+
+// Recent will return the named consumer's traces, newest first.
+func (t *MessageTracer) Recent(name string) []MessageTrace {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	r, ok := t.rings[name]
+	if !ok {
+		return nil
+	}
+	out := make([]MessageTrace, 0, len(r.traces))
+	for i := len(r.traces) - 1; i >= 0; i-- {
+		out = append(out, r.traces[(r.next+i)%len(r.traces)])
+	}
+	return out
+}
+
+// Names will return the names of all consumers with traces, sorted.
+func (t *MessageTracer) Names() []string {
+	t.mu.Lock()
+	names := make([]string, 0, len(t.rings))
+	for name := range t.rings {
+		names = append(names, name)
+	}
+	t.mu.Unlock()
+	sort.Strings(names)
+	return names
+}
+
+// ServeHTTP will respond with a JSON object of each consumer's traces.
+func (t *MessageTracer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	names := t.Names()
+	if name := r.URL.Query().Get("name"); name != "" {
+		names = []string{name}
+	}
+	out := make(map[string][]MessageTrace, len(names))
+	for _, name := range names {
+		out[name] = t.Recent(name)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(out); err != nil {
+		Log.Warn("unable to encode message traces: ", err)
+	}
+}
+
+// newMessageTrace will describe the message as received at start.
+func newMessageTrace(msg SubscriberMessage, start time.Time) MessageTrace {
+	trace := MessageTrace{ID: messageID(msg), Size: len(msg.Message()), Received: start}
+	if subject, ok := attribute(msg, "subject"); ok {
+		trace.Subject = subject
+	} else {
+		trace.Subject, _, _ = passthrough(msg)
+	}
+	return trace
+}
+
+// messageID will return the message's ID from its Subscriber or its 'id'
+// attribute, if it has one.
+func messageID(msg SubscriberMessage) string {
+	if m, ok := msg.(interface {
+		ID() string
+	}); ok {
+		return m.ID()
+	}
+	id, _ := attribute(msg, "id")
+	return id
+}
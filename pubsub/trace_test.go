@@ -0,0 +1,89 @@
+package pubsub
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+func TestMessageTracer(t *testing.T) {
+	tracer := NewMessageTracer(3)
+	for i := 0; i < 5; i++ {
+		tracer.Record("cats", MessageTrace{ID: strconv.Itoa(i)})
+	}
+	tracer.Record("dogs", MessageTrace{ID: "woof"})
+
+	got := tracer.Recent("cats")
+	want := []string{"4", "3", "2"}
+	if len(got) != len(want) {
+		t.Fatalf("expected %d traces, got %d", len(want), len(got))
+	}
+	for i, id := range want {
+		if got[i].ID != id {
+			t.Errorf("expected trace %d to be %q, got %q", i, id, got[i].ID)
+		}
+	}
+	if got := tracer.Names(); len(got) != 2 || got[0] != "cats" || got[1] != "dogs" {
+		t.Errorf("expected names [cats dogs], got %v", got)
+	}
+}
+
+func TestMessageTracerServeHTTP(t *testing.T) {
+	tracer := NewMessageTracer(0)
+	tracer.Record("cats", MessageTrace{ID: "meow", Latency: 12 * time.Millisecond, Outcome: TraceHandled})
+	tracer.Record("dogs", MessageTrace{ID: "woof", Outcome: TraceFailed})
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/debug/messages?name=cats", nil)
+	tracer.ServeHTTP(w, r)
+
+	var got map[string][]map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+		t.Fatalf("expected no error, got %s", err)
+	}
+	if len(got) != 1 || len(got["cats"]) != 1 {
+		t.Fatalf("expected a single trace for cats, got %v", got)
+	}
+	trace := got["cats"][0]
+	if trace["id"] != "meow" {
+		t.Errorf("expected id 'meow', got %v", trace["id"])
+	}
+	if trace["latency"] != "12ms" {
+		t.Errorf("expected latency '12ms', got %v", trace["latency"])
+	}
+}
+
+func TestConsumerTracer(t *testing.T) {
+	sub := newTestChanSubscriber()
+	c := NewConsumer(sub, func(ctx context.Context, msg SubscriberMessage) error {
+		if string(msg.Message()) == "bad" {
+			return errors.New("bad message")
+		}
+		return nil
+	})
+	c.Tracer = NewMessageTracer(10)
+	c.Name = "cats"
+
+	go c.Run()
+	sub.msgs <- &testConsumerMessage{msg: []byte("good")}
+	sub.msgs <- &testConsumerMessage{msg: []byte("bad")}
+	if err := c.Stop(); err != nil {
+		t.Errorf("expected no error, got %s", err)
+	}
+
+	got := c.Tracer.Recent("cats")
+	if len(got) != 2 {
+		t.Fatalf("expected 2 traces, got %d", len(got))
+	}
+	if got[0].Outcome != TraceFailed || got[0].Error != "bad message" || got[0].Size != 3 {
+		t.Errorf("expected a failed trace of 3 bytes, got %+v", got[0])
+	}
+	if got[1].Outcome != TraceHandled || got[1].Size != 4 {
+		t.Errorf("expected a handled trace of 4 bytes, got %+v", got[1])
+	}
+}
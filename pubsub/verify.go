@@ -0,0 +1,51 @@
+package pubsub
+
+import "time"
+
+// DefaultVerifyTimeout is how long a DependencyVerifier will wait on
+// backends whose clients take a context.
+var DefaultVerifyTimeout = 10 * time.Second
+
+// DependencyVerifier is a Publisher or Subscriber that can check its
+// dependencies, like that its topic or queue exists and its credentials may
+// use it, without publishing or consuming any messages.
+type DependencyVerifier interface {
+	Verify() error
+}
+
+// VerifyError describes the dependency a DependencyVerifier failed to
+// verify.
+type VerifyError struct {
+	// Resource names the dependency, like 'sqs queue cats'.
+	Resource string
+	// Err is the error from the backend.
+	Err error
+}
+
+func (e *VerifyError) Error() string {
+	switch ErrorClass(e.Err) {
+	case ErrQueueNotFound:
+		return e.Resource + " does not exist: " + e.Err.Error()
+	case ErrAccessDenied:
+		return e.Resource + " is not accessible with the configured credentials: " + e.Err.Error()
+	}
+	return "unable to verify " + e.Resource + ": " + e.Err.Error()
+}
+
+// Verify will verify each of the given publishers and subscribers that is a
+// DependencyVerifier, returning the first failure. It is meant to be called
+// at boot so misconfigurations fail fast, rather than at the first publish:
+//
+//	if err := pubsub.Verify(pub, sub); err != nil {
+//	    pubsub.Log.Fatal(err)
+//	}
+func Verify(clients ...interface{}) error {
+	for _, c := range clients {
+		if v, ok := c.(DependencyVerifier); ok {
+			if err := v.Verify(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
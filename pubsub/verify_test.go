@@ -0,0 +1,55 @@
+package pubsub
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/NYTimes/gizmo/config"
+)
+
+// testVerifyPublisher is a Publisher that fails verification with err.
+type testVerifyPublisher struct {
+	testRegionPublisher
+	err error
+}
+
+func (p *testVerifyPublisher) Verify() error {
+	return p.err
+}
+
+func TestVerify(t *testing.T) {
+	missing := &VerifyError{
+		Resource: "sqs queue cats",
+		Err:      &Error{Class: ErrQueueNotFound, Err: errors.New("no such queue")},
+	}
+	if err := Verify(&testVerifyPublisher{}, newTestChanSubscriber()); err != nil {
+		t.Errorf("expected no error, got %s", err)
+	}
+	err := Verify(&testVerifyPublisher{}, &testVerifyPublisher{err: missing})
+	if err != missing {
+		t.Fatalf("expected the verify error, got %v", err)
+	}
+	if ErrorClass(err) != ErrQueueNotFound {
+		t.Errorf("expected ErrQueueNotFound, got %v", ErrorClass(err))
+	}
+	if want := "sqs queue cats does not exist"; !strings.HasPrefix(err.Error(), want) {
+		t.Errorf("expected %q, got %q", want, err.Error())
+	}
+}
+
+func TestNewPublisherVerify(t *testing.T) {
+	RegisterProvider("test-verify", ProviderFactory{
+		NewPublisher: func(cfg *config.PubSub) (Publisher, error) {
+			return &testVerifyPublisher{err: errors.New("unreachable")}, nil
+		},
+	})
+	cfg := &config.PubSub{Provider: "test-verify"}
+	if _, err := NewPublisher(cfg); err != nil {
+		t.Errorf("expected no error without Verify, got %s", err)
+	}
+	cfg.Verify = true
+	if _, err := NewPublisher(cfg); err == nil {
+		t.Error("expected an error with Verify")
+	}
+}
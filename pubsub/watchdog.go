@@ -0,0 +1,168 @@
+package pubsub
+
+import (
+	"sync"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+var (
+	// DefaultWatchdogInterval is how often a Watchdog will check the
+	// in-flight count if no Interval is given.
+	DefaultWatchdogInterval = 10 * time.Second
+	// DefaultWatchdogStallAfter is how long the in-flight count may go
+	// without changing before a Watchdog alarms if no StallAfter is given.
+	DefaultWatchdogStallAfter = 5 * time.Minute
+)
+
+// WatchdogAlarm describes why a Watchdog has raised an alarm.
+type WatchdogAlarm struct {
+	// Stalled is true if the alarm is for no progress being made, and
+	// false if it is for the in-flight count being over the Threshold.
+	Stalled bool
+	// InFlight is the in-flight count when the alarm was raised.
+	InFlight int64
+	// Since is when the condition that raised the alarm began.
+	Since time.Time
+}
+
+// Watchdog will keep an eye on the number of messages in flight in a
+// Consumer or Subscriber and raise an alarm when it stays above a threshold
+// or stops changing while messages are in flight, which are the signs of
+// stuck handlers or a wedged delete loop. Alarms are logged, counted in the
+// 'pubsub.watchdog.ALARM' metric and passed to the optional OnAlarm callback.
+// Each alarm is raised once until the condition clears.
+type Watchdog struct {
+	// Threshold is the in-flight count that, once exceeded for
+	// ThresholdFor, will raise an alarm. If 0, the count is not checked.
+	Threshold int64
+	// ThresholdFor is how long the in-flight count must stay above the
+	// Threshold before an alarm is raised. If 0, an alarm is raised on the
+	// first check above it.
+	ThresholdFor time.Duration
+	// StallAfter is how long messages may be in flight without any
+	// progress before an alarm is raised. Defaults to
+	// DefaultWatchdogStallAfter.
+	StallAfter time.Duration
+	// Interval is how often the in-flight count is checked. Defaults to
+	// DefaultWatchdogInterval.
+	Interval time.Duration
+	// OnAlarm is an optional callback for each alarm.
+	OnAlarm func(WatchdogAlarm)
+	// MetricsRegistry will override the default metrics registry for the
+	// watchdog metrics if set.
+	MetricsRegistry metrics.Registry
+
+	inFlight func() int64
+	progress func() int64
+
+	overSince    time.Time
+	overAlarmed  bool
+	last         int64
+	lastProgress int64
+	changed      time.Time
+	stallAlarmed bool
+
+	stop     chan struct{}
+	stopOnce sync.Once
+	done     chan struct{}
+}
+
+// NewWatchdog will return a Watchdog over the given in-flight count, like
+// the InFlight method of an SQSSubscriber. Progress is only seen through
+// changes to the count.
+func NewWatchdog(inFlight func() int64) *Watchdog {
+	return &Watchdog{
+		inFlight: inFlight,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+// NewConsumerWatchdog will return a Watchdog over the handler calls in flight
+// in the Consumer. Since a busy Consumer may hold a steady in-flight count,
+// progress is also seen through messages being handled.
+func NewConsumerWatchdog(c *Consumer) *Watchdog {
+	w := NewWatchdog(func() int64 { return c.Stats().InFlight })
+	w.progress = func() int64 { return c.Stats().Handled }
+	return w
+}
+
+// Start will begin checking the in-flight count in the background.
+func (w *Watchdog) Start() {
+	interval := w.Interval
+	if interval == 0 {
+		interval = DefaultWatchdogInterval
+	}
+	w.changed = time.Now()
+	go func() {
+		defer close(w.done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-w.stop:
+				return
+			case now := <-ticker.C:
+				w.check(now)
+			}
+		}
+	}()
+}
+
+// Stop will stop the Watchdog and wait for it to finish.
+func (w *Watchdog) Stop() {
+	w.stopOnce.Do(func() {
+		close(w.stop)
+	})
+	<-w.done
+}
+
+// check will compare the in-flight count against the alarm conditions.
+func (w *Watchdog) check(now time.Time) {
+	n := w.inFlight()
+	metrics.GetOrRegisterGauge("pubsub.watchdog.INFLIGHT", w.MetricsRegistry).Update(n)
+
+	if w.Threshold > 0 && n > w.Threshold {
+		if w.overSince.IsZero() {
+			w.overSince = now
+		}
+		if !w.overAlarmed && now.Sub(w.overSince) >= w.ThresholdFor {
+			w.overAlarmed = true
+			w.alarm(WatchdogAlarm{InFlight: n, Since: w.overSince})
+		}
+	} else {
+		w.overSince, w.overAlarmed = time.Time{}, false
+	}
+
+	var progress int64
+	if w.progress != nil {
+		progress = w.progress()
+	}
+	if n == 0 || n != w.last || progress != w.lastProgress {
+		w.last, w.lastProgress, w.changed = n, progress, now
+		w.stallAlarmed = false
+		return
+	}
+	stallAfter := w.StallAfter
+	if stallAfter == 0 {
+		stallAfter = DefaultWatchdogStallAfter
+	}
+	if !w.stallAlarmed && now.Sub(w.changed) >= stallAfter {
+		w.stallAlarmed = true
+		w.alarm(WatchdogAlarm{Stalled: true, InFlight: n, Since: w.changed})
+	}
+}
+
+func (w *Watchdog) alarm(a WatchdogAlarm) {
+	if a.Stalled {
+		Log.Errorf("watchdog: %d messages in flight without progress since %s", a.InFlight, a.Since)
+	} else {
+		Log.Errorf("watchdog: %d messages in flight, over the threshold of %d since %s", a.InFlight, w.Threshold, a.Since)
+	}
+	metrics.GetOrRegisterCounter("pubsub.watchdog.ALARM", w.MetricsRegistry).Inc(1)
+	if w.OnAlarm != nil {
+		w.OnAlarm(a)
+	}
+}
@@ -0,0 +1,89 @@
+package pubsub
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+func TestWatchdog(t *testing.T) {
+	var (
+		inFlight int64
+		alarms   []WatchdogAlarm
+	)
+	w := NewWatchdog(func() int64 { return inFlight })
+	w.Threshold = 10
+	w.ThresholdFor = time.Minute
+	w.StallAfter = 5 * time.Minute
+	w.MetricsRegistry = metrics.NewRegistry()
+	w.OnAlarm = func(a WatchdogAlarm) { alarms = append(alarms, a) }
+
+	start := time.Now()
+	w.changed = start
+	at := func(d time.Duration) time.Time { return start.Add(d) }
+
+	// over the threshold, but not for long enough
+	inFlight = 20
+	w.check(at(0))
+	w.check(at(30 * time.Second))
+	if len(alarms) != 0 {
+		t.Fatalf("expected no alarms, got %+v", alarms)
+	}
+	w.check(at(time.Minute))
+	if len(alarms) != 1 || alarms[0].Stalled || alarms[0].InFlight != 20 {
+		t.Fatalf("expected a threshold alarm, got %+v", alarms)
+	}
+	// alarms are only raised once per episode
+	w.check(at(2 * time.Minute))
+	if len(alarms) != 1 {
+		t.Fatalf("expected 1 alarm, got %+v", alarms)
+	}
+
+	// under the threshold but with no progress
+	inFlight = 5
+	w.check(at(3 * time.Minute))
+	w.check(at(7 * time.Minute))
+	if len(alarms) != 1 {
+		t.Fatalf("expected 1 alarm, got %+v", alarms)
+	}
+	w.check(at(8 * time.Minute))
+	if len(alarms) != 2 || !alarms[1].Stalled || !alarms[1].Since.Equal(at(3*time.Minute)) {
+		t.Fatalf("expected a stall alarm, got %+v", alarms)
+	}
+
+	// an idle watchdog should never stall
+	inFlight = 0
+	w.check(at(9 * time.Minute))
+	w.check(at(20 * time.Minute))
+	if len(alarms) != 2 {
+		t.Fatalf("expected 2 alarms, got %+v", alarms)
+	}
+	if got := metrics.GetOrRegisterCounter("pubsub.watchdog.ALARM", w.MetricsRegistry).Count(); got != 2 {
+		t.Errorf("expected 2 alarms counted, got %d", got)
+	}
+}
+
+func TestConsumerWatchdog(t *testing.T) {
+	c := NewConsumer(newTestChanSubscriber(), nil)
+	c.inFlight = 3
+	var alarms []WatchdogAlarm
+	w := NewConsumerWatchdog(c)
+	w.StallAfter = time.Minute
+	w.MetricsRegistry = metrics.NewRegistry()
+	w.OnAlarm = func(a WatchdogAlarm) { alarms = append(alarms, a) }
+
+	start := time.Now()
+	w.changed = start
+	w.check(start)
+	// a steady in-flight count is fine while messages are being handled
+	c.handled = 100
+	w.check(start.Add(time.Minute))
+	if len(alarms) != 0 {
+		t.Fatalf("expected no alarms, got %+v", alarms)
+	}
+	w.check(start.Add(2 * time.Minute))
+	if len(alarms) != 1 || !alarms[0].Stalled {
+		t.Fatalf("expected a stall alarm, got %+v", alarms)
+	}
+}
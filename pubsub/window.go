@@ -0,0 +1,186 @@
+package pubsub
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+type (
+	// KeyFunc will return the key a message should be grouped by.
+	KeyFunc func(SubscriberMessage) (string, error)
+
+	// ReduceFunc will add a message to a key's aggregate, which is nil for
+	// the first message of a window, and return the new aggregate.
+	ReduceFunc func(agg interface{}, msg SubscriberMessage) (interface{}, error)
+
+	// FlushFunc will be called with each key's aggregate when a window closes.
+	FlushFunc func(WindowResult) error
+
+	// WindowResult is the aggregate of a key's messages within a window.
+	WindowResult struct {
+		Key   string
+		Start time.Time
+		End   time.Time
+		// Count is the number of messages aggregated.
+		Count int
+		// Value is the aggregate returned by the ReduceFunc.
+		Value interface{}
+	}
+
+	// Window aggregates messages by key over tumbling or sliding windows of
+	// time, for lightweight stream aggregation like counting events per
+	// user per minute. Its Handle method is a MessageHandler for a Consumer.
+	//
+	// Messages are placed in windows by when they are handled and marked as
+	// done once they have been aggregated, so the aggregates of open windows
+	// are lost if the process crashes. Close will flush any open windows and
+	// should be called after the Consumer is stopped.
+	Window struct {
+		size, slide time.Duration
+		key         KeyFunc
+		reduce      ReduceFunc
+		flush       FlushFunc
+
+		mu sync.Mutex
+		// aggregates by window start and key
+		windows map[int64]map[string]*WindowResult
+		closed  bool
+
+		stop chan struct{}
+		done chan struct{}
+	}
+)
+
+// errWindowClosed is returned by Handle after the Window is closed.
+var errWindowClosed = errors.New("window is closed")
+
+// NewTumblingWindow will return a Window that aggregates messages in
+// consecutive, non-overlapping windows of the given size.
+func NewTumblingWindow(size time.Duration, key KeyFunc, reduce ReduceFunc, flush FlushFunc) *Window {
+	return NewSlidingWindow(size, size, key, reduce, flush)
+}
+
+// NewSlidingWindow will return a Window that aggregates messages in windows of
+// the given size that start every slide, so each message is aggregated in
+// size/slide overlapping windows.
+func NewSlidingWindow(size, slide time.Duration, key KeyFunc, reduce ReduceFunc, flush FlushFunc) *Window {
+	if slide <= 0 || slide > size {
+		slide = size
+	}
+	w := &Window{
+		size:    size,
+		slide:   slide,
+		key:     key,
+		reduce:  reduce,
+		flush:   flush,
+		windows: map[int64]map[string]*WindowResult{},
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	go w.run()
+	return w
+}
+
+// Handle will aggregate the message into every window it falls into.
+func (w *Window) Handle(ctx context.Context, msg SubscriberMessage) error {
+	key, err := w.key(msg)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return errWindowClosed
+	}
+	for start := now.Truncate(w.slide); now.Sub(start) < w.size; start = start.Add(-w.slide) {
+		keys, ok := w.windows[start.UnixNano()]
+		if !ok {
+			keys = map[string]*WindowResult{}
+			w.windows[start.UnixNano()] = keys
+		}
+		res, ok := keys[key]
+		if !ok {
+			res = &WindowResult{Key: key, Start: start, End: start.Add(w.size)}
+		}
+		val, err := w.reduce(res.Value, msg)
+		if err != nil {
+			return err
+		}
+		res.Value = val
+		res.Count++
+		keys[key] = res
+	}
+	return nil
+}
+
+func (w *Window) run() {
+	defer close(w.done)
+	ticker := time.NewTicker(w.slide)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-w.stop:
+			return
+		case now := <-ticker.C:
+			w.flushBefore(now)
+		}
+	}
+}
+
+// flushBefore will flush every window that ended by the given time.
+func (w *Window) flushBefore(end time.Time) {
+	w.mu.Lock()
+	var starts []int64
+	for start := range w.windows {
+		if !time.Unix(0, start).Add(w.size).After(end) {
+			starts = append(starts, start)
+		}
+	}
+	sort.Sort(int64s(starts))
+	ready := make([]map[string]*WindowResult, len(starts))
+	for i, start := range starts {
+		ready[i] = w.windows[start]
+		delete(w.windows, start)
+	}
+	w.mu.Unlock()
+
+	for _, keys := range ready {
+		names := make([]string, 0, len(keys))
+		for k := range keys {
+			names = append(names, k)
+		}
+		sort.Strings(names)
+		for _, k := range names {
+			if err := w.flush(*keys[k]); err != nil {
+				Log.Warnf("unable to flush window for %s: %s", k, err)
+			}
+		}
+	}
+}
+
+// Close will stop the Window and flush every open window, even if it has
+// not ended yet.
+func (w *Window) Close() error {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return nil
+	}
+	w.closed = true
+	w.mu.Unlock()
+	close(w.stop)
+	<-w.done
+	w.flushBefore(time.Now().Add(w.size))
+	return nil
+}
+
+type int64s []int64
+
+func (s int64s) Len() int           { return len(s) }
+func (s int64s) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s int64s) Less(i, j int) bool { return s[i] < s[j] }
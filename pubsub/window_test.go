@@ -0,0 +1,90 @@
+package pubsub
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+func testWindowFuncs() (KeyFunc, ReduceFunc) {
+	key := func(msg SubscriberMessage) (string, error) {
+		return string(msg.Message()), nil
+	}
+	count := func(agg interface{}, msg SubscriberMessage) (interface{}, error) {
+		n, _ := agg.(int)
+		return n + 1, nil
+	}
+	return key, count
+}
+
+func TestTumblingWindow(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		results []WindowResult
+	)
+	key, count := testWindowFuncs()
+	w := NewTumblingWindow(20*time.Millisecond, key, count, func(res WindowResult) error {
+		mu.Lock()
+		results = append(results, res)
+		mu.Unlock()
+		return nil
+	})
+
+	for _, body := range []string{"cat", "dog", "cat"} {
+		w.Handle(context.Background(), &testConsumerMessage{msg: []byte(body)})
+	}
+	// let the window close on its own
+	time.Sleep(50 * time.Millisecond)
+	w.Handle(context.Background(), &testConsumerMessage{msg: []byte("cat")})
+	w.Close()
+
+	if err := w.Handle(context.Background(), &testConsumerMessage{msg: []byte("cat")}); err == nil {
+		t.Error("expected an error handling after close")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	totals := map[string]int{}
+	for _, res := range results {
+		if res.Count != res.Value.(int) {
+			t.Errorf("expected the count and aggregate to match, got %+v", res)
+		}
+		if res.End.Sub(res.Start) != 20*time.Millisecond {
+			t.Errorf("expected a 20ms window, got %+v", res)
+		}
+		totals[res.Key] += res.Count
+	}
+	if totals["cat"] != 3 || totals["dog"] != 1 {
+		t.Errorf("expected 3 cats and 1 dog, got %v", totals)
+	}
+	if len(results) < 3 {
+		t.Errorf("expected at least 3 results across 2 windows, got %d", len(results))
+	}
+}
+
+func TestSlidingWindow(t *testing.T) {
+	var (
+		mu    sync.Mutex
+		total int
+	)
+	key, count := testWindowFuncs()
+	w := NewSlidingWindow(time.Minute, 30*time.Second, key, count, func(res WindowResult) error {
+		mu.Lock()
+		total += res.Count
+		mu.Unlock()
+		return nil
+	})
+	for i := 0; i < 5; i++ {
+		w.Handle(context.Background(), &testConsumerMessage{msg: []byte("cat")})
+	}
+	w.Close()
+
+	mu.Lock()
+	defer mu.Unlock()
+	// every message falls into 2 overlapping windows
+	if total != 10 {
+		t.Errorf("expected 10 aggregated messages, got %d", total)
+	}
+}
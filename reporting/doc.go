@@ -0,0 +1,18 @@
+/*
+Package reporting offers a hook for sending errors and recovered panics to an error
+reporting service, with adapters for Sentry and Rollbar.
+
+Gizmo's servers and pubsub consumers will send a Report of any panic they recover
+from to a configured Reporter, along with the request or message being handled:
+
+	reporter, err := reporting.NewFromConfig(cfg.ErrorReporting)
+
+	server.ErrorReporter = reporter
+
+	consumer := pubsub.NewConsumer(sub, handle)
+	consumer.Reporter = reporter
+
+Reports are sent in the background. Before exiting, call Flush on a Sentry or Rollbar
+reporter to wait for any pending reports.
+*/
+package reporting
@@ -0,0 +1,209 @@
+package reporting
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+
+	"github.com/NYTimes/gizmo/config"
+)
+
+// Log is the structured logger used throughout the package.
+var Log = logrus.New()
+
+// DefaultTimeout is how long a Reporter will wait on the error
+// reporting service if no http.Client is given.
+var DefaultTimeout = 5 * time.Second
+
+// maxMessageBytes is the most of a message payload that will be
+// included in a report.
+const maxMessageBytes = 4096
+
+// scrubbedHeaders will not be included in reports.
+var scrubbedHeaders = map[string]bool{
+	"Authorization": true,
+	"Cookie":        true,
+}
+
+// Report holds an error along with any context about
+// where it happened.
+type Report struct {
+	// Err is the error being reported.
+	Err error
+	// Panic is whether the error was recovered from a panic.
+	Panic bool
+	// Stack is an optional stack trace of where the error occurred.
+	Stack []byte
+	// Request is the HTTP request being served, if any.
+	Request *http.Request
+	// Message is the pubsub message being consumed, if any.
+	Message []byte
+	// Tags are any extra details to index the report by.
+	Tags map[string]string
+	// Time is when the error occurred.
+	Time time.Time
+}
+
+// Reporter is a hook for sending errors to an error reporting service.
+// Implementations should not block on the service.
+type Reporter interface {
+	Report(*Report)
+}
+
+// ReporterFunc is a func that implements the Reporter interface.
+type ReporterFunc func(*Report)
+
+// Report will call the func.
+func (f ReporterFunc) Report(r *Report) {
+	f(r)
+}
+
+// NewFromConfig will return the Reporter for the provider in the given
+// config. A nil config will return a nil Reporter.
+func NewFromConfig(cfg *config.ErrorReporting) (Reporter, error) {
+	if cfg == nil {
+		return nil, nil
+	}
+	switch cfg.Provider {
+	case "sentry":
+		return NewSentry(cfg, nil)
+	case "rollbar":
+		return NewRollbar(cfg, nil)
+	default:
+		return nil, fmt.Errorf("unknown error reporting provider: %q", cfg.Provider)
+	}
+}
+
+// PanicReport will create a Report for a value recovered from a panic.
+func PanicReport(x interface{}, stack []byte) *Report {
+	err, ok := x.(error)
+	if !ok {
+		err = fmt.Errorf("%v", x)
+	}
+	return &Report{Err: err, Panic: true, Stack: stack, Time: time.Now()}
+}
+
+// requestContext holds the details of a Report's request that
+// will be sent with it.
+type requestContext struct {
+	URL         string            `json:"url"`
+	Method      string            `json:"method"`
+	Headers     map[string]string `json:"headers,omitempty"`
+	QueryString string            `json:"query_string,omitempty"`
+	RemoteAddr  string            `json:"user_ip,omitempty"`
+}
+
+func newRequestContext(r *http.Request) *requestContext {
+	if r == nil {
+		return nil
+	}
+	u := *r.URL
+	if u.Host == "" {
+		u.Host = r.Host
+	}
+	if u.Scheme == "" {
+		u.Scheme = "http"
+		if r.TLS != nil {
+			u.Scheme = "https"
+		}
+	}
+	u.RawQuery = ""
+	rc := &requestContext{
+		URL:         u.String(),
+		Method:      r.Method,
+		Headers:     map[string]string{},
+		QueryString: r.URL.RawQuery,
+		RemoteAddr:  r.RemoteAddr,
+	}
+	for k, v := range r.Header {
+		if !scrubbedHeaders[k] && len(v) > 0 {
+			rc.Headers[k] = v[0]
+		}
+	}
+	return rc
+}
+
+// message will return the Report's message payload, truncated
+// if it is too large.
+func (r *Report) message() string {
+	if len(r.Message) > maxMessageBytes {
+		return string(r.Message[:maxMessageBytes]) + "..."
+	}
+	return string(r.Message)
+}
+
+func (r *Report) time() time.Time {
+	if r.Time.IsZero() {
+		return time.Now()
+	}
+	return r.Time
+}
+
+func hostname() string {
+	host, _ := os.Hostname()
+	return host
+}
+
+// sender will POST reports to an error reporting service in the background.
+type sender struct {
+	client *http.Client
+	wg     sync.WaitGroup
+}
+
+func newSender(client *http.Client) *sender {
+	if client == nil {
+		client = &http.Client{Timeout: DefaultTimeout}
+	}
+	return &sender{client: client}
+}
+
+// send will encode the payload and POST it in the background.
+func (s *sender) send(url string, header http.Header, payload interface{}) {
+	b, err := json.Marshal(payload)
+	if err != nil {
+		Log.Error("unable to encode error report: ", err)
+		return
+	}
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		if err := s.post(url, header, b); err != nil {
+			Log.Warn("unable to send error report: ", err)
+		}
+	}()
+}
+
+func (s *sender) post(url string, header http.Header, b []byte) error {
+	req, err := http.NewRequest("POST", url, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+		return errors.New("error reporting service responded with " + resp.Status + ": " + string(msg))
+	}
+	return nil
+}
+
+// Flush will block until all pending reports have been sent.
+func (s *sender) Flush() {
+	s.wg.Wait()
+}
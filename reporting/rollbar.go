@@ -0,0 +1,109 @@
+package reporting
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/NYTimes/gizmo/config"
+)
+
+// DefaultRollbarEndpoint is the Rollbar API endpoint items are sent to.
+var DefaultRollbarEndpoint = "https://api.rollbar.com/api/1/item/"
+
+// Rollbar is a Reporter that sends items to a Rollbar project.
+type Rollbar struct {
+	*sender
+
+	// Endpoint will override the DefaultRollbarEndpoint.
+	Endpoint string
+
+	token       string
+	environment string
+	release     string
+	host        string
+}
+
+// NewRollbar will return a Reporter for the project of the config's
+// RollbarToken. If no client is given, one with the DefaultTimeout
+// will be used.
+func NewRollbar(cfg *config.ErrorReporting, client *http.Client) (*Rollbar, error) {
+	if cfg.RollbarToken == "" {
+		return nil, errors.New("rollbar access token is required")
+	}
+	env := cfg.Environment
+	if env == "" {
+		// Rollbar requires an environment
+		env = "production"
+	}
+	return &Rollbar{
+		sender:      newSender(client),
+		Endpoint:    DefaultRollbarEndpoint,
+		token:       cfg.RollbarToken,
+		environment: env,
+		release:     cfg.Release,
+		host:        hostname(),
+	}, nil
+}
+
+type rollbarItem struct {
+	AccessToken string      `json:"access_token"`
+	Data        rollbarData `json:"data"`
+}
+
+type rollbarData struct {
+	Environment string            `json:"environment"`
+	Level       string            `json:"level"`
+	Timestamp   int64             `json:"timestamp"`
+	Platform    string            `json:"platform"`
+	Language    string            `json:"language"`
+	CodeVersion string            `json:"code_version,omitempty"`
+	Server      map[string]string `json:"server"`
+	Body        rollbarBody       `json:"body"`
+	Request     *requestContext   `json:"request,omitempty"`
+	Custom      map[string]string `json:"custom,omitempty"`
+}
+
+type rollbarBody struct {
+	Message rollbarMessage `json:"message"`
+}
+
+type rollbarMessage struct {
+	Body  string `json:"body"`
+	Class string `json:"class"`
+	Stack string `json:"stack,omitempty"`
+}
+
+// Report will send the report to Rollbar in the background.
+func (rb *Rollbar) Report(r *Report) {
+	level := "error"
+	if r.Panic {
+		level = "critical"
+	}
+	custom := map[string]string{}
+	for k, v := range r.Tags {
+		custom[k] = v
+	}
+	if len(r.Message) > 0 {
+		custom["message"] = r.message()
+	}
+	item := rollbarItem{
+		AccessToken: rb.token,
+		Data: rollbarData{
+			Environment: rb.environment,
+			Level:       level,
+			Timestamp:   r.time().Unix(),
+			Platform:    "go",
+			Language:    "go",
+			CodeVersion: rb.release,
+			Server:      map[string]string{"host": rb.host},
+			Body: rollbarBody{Message: rollbarMessage{
+				Body:  r.Err.Error(),
+				Class: errorType(r.Err),
+				Stack: string(r.Stack),
+			}},
+			Request: newRequestContext(r.Request),
+			Custom:  custom,
+		},
+	}
+	rb.send(rb.Endpoint, nil, item)
+}
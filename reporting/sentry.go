@@ -0,0 +1,124 @@
+package reporting
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/nu7hatch/gouuid"
+
+	"github.com/NYTimes/gizmo/config"
+)
+
+// Sentry is a Reporter that sends events to the store
+// API of a Sentry project.
+type Sentry struct {
+	*sender
+
+	endpoint    string
+	auth        string
+	environment string
+	release     string
+	host        string
+}
+
+// NewSentry will return a Reporter for the project in the config's SentryDSN.
+// If no client is given, one with the DefaultTimeout will be used.
+func NewSentry(cfg *config.ErrorReporting, client *http.Client) (*Sentry, error) {
+	if cfg.SentryDSN == "" {
+		return nil, errors.New("sentry DSN is required")
+	}
+	dsn, err := url.Parse(cfg.SentryDSN)
+	if err != nil {
+		return nil, err
+	}
+	if dsn.User == nil || dsn.User.Username() == "" {
+		return nil, errors.New("sentry DSN is missing a public key")
+	}
+	i := strings.LastIndex(dsn.Path, "/")
+	if i < 0 || i == len(dsn.Path)-1 {
+		return nil, errors.New("sentry DSN is missing a project ID")
+	}
+	project := dsn.Path[i+1:]
+
+	auth := "Sentry sentry_version=7, sentry_client=gizmo/1.0, sentry_key=" + dsn.User.Username()
+	if secret, ok := dsn.User.Password(); ok {
+		auth += ", sentry_secret=" + secret
+	}
+	return &Sentry{
+		sender:      newSender(client),
+		endpoint:    dsn.Scheme + "://" + dsn.Host + dsn.Path[:i] + "/api/" + project + "/store/",
+		auth:        auth,
+		environment: cfg.Environment,
+		release:     cfg.Release,
+		host:        hostname(),
+	}, nil
+}
+
+type sentryException struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+type sentryEvent struct {
+	EventID     string            `json:"event_id"`
+	Timestamp   string            `json:"timestamp"`
+	Level       string            `json:"level"`
+	Platform    string            `json:"platform"`
+	Logger      string            `json:"logger"`
+	ServerName  string            `json:"server_name,omitempty"`
+	Environment string            `json:"environment,omitempty"`
+	Release     string            `json:"release,omitempty"`
+	Message     string            `json:"message"`
+	Exception   []sentryException `json:"exception"`
+	Request     *requestContext   `json:"request,omitempty"`
+	Tags        map[string]string `json:"tags,omitempty"`
+	Extra       map[string]string `json:"extra,omitempty"`
+}
+
+// Report will send the report to Sentry in the background.
+func (s *Sentry) Report(r *Report) {
+	id, err := uuid.NewV4()
+	if err != nil {
+		Log.Error("unable to generate sentry event ID: ", err)
+		return
+	}
+	level := "error"
+	if r.Panic {
+		level = "fatal"
+	}
+	event := sentryEvent{
+		EventID:     strings.Replace(id.String(), "-", "", -1),
+		Timestamp:   r.time().UTC().Format("2006-01-02T15:04:05"),
+		Level:       level,
+		Platform:    "go",
+		Logger:      "gizmo",
+		ServerName:  s.host,
+		Environment: s.environment,
+		Release:     s.release,
+		Message:     r.Err.Error(),
+		Exception:   []sentryException{{Type: errorType(r.Err), Value: r.Err.Error()}},
+		Request:     newRequestContext(r.Request),
+		Tags:        r.Tags,
+		Extra:       map[string]string{},
+	}
+	if len(r.Stack) > 0 {
+		event.Extra["stack"] = string(r.Stack)
+	}
+	if len(r.Message) > 0 {
+		event.Extra["message"] = r.message()
+	}
+
+	header := http.Header{}
+	header.Set("X-Sentry-Auth", fmt.Sprintf("%s, sentry_timestamp=%d", s.auth, time.Now().Unix()))
+	s.send(s.endpoint, header, event)
+}
+
+// errorType will return the name of the error's type, such as '*errors.errorString'.
+func errorType(err error) string {
+	return reflect.TypeOf(err).String()
+}
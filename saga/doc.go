@@ -0,0 +1,31 @@
+/*
+Package saga coordinates multi-step business processes where each step is run
+as a job over pubsub and completed steps are compensated in reverse order if a
+later step fails.
+
+A Saga is a named sequence of steps with an Action and an optional Compensate
+func. It is registered on a Coordinator along with the jobs.Worker that will
+run its steps:
+
+	store, err := saga.NewDynamoDBStore(cfg.DynamoDB)
+	coordinator := saga.NewCoordinator(store, pub)
+	worker := jobs.NewWorker(sub, pub)
+	err = coordinator.Register(worker, &saga.Saga{
+		Name: "fulfill-order",
+		Steps: []saga.Step{
+			{Name: "reserve", Action: reserve, Compensate: release},
+			{Name: "charge", Action: charge, Compensate: refund},
+			{Name: "ship", Action: ship},
+		},
+	}, jobs.HandlerOptions{Concurrency: 10})
+	go worker.Run()
+
+	id, err := coordinator.Start("fulfill-order", order)
+
+The state of each execution, including the data shared by its steps, is kept
+in a Store between steps so a process can be picked up by any instance. A step
+is compensated only after its Action has failed for its last attempt. If a
+compensation fails for its last attempt, the execution is marked as failed to be
+resolved by hand.
+*/
+package saga
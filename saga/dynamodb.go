@@ -0,0 +1,105 @@
+package saga
+
+import (
+	"encoding/json"
+	"errors"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+
+	"github.com/NYTimes/gizmo/config"
+)
+
+// DynamoDBStore is a Store that keeps executions as JSON in a DynamoDB
+// table. The table must have a string hash key named 'id'.
+type DynamoDBStore struct {
+	db    dynamodbiface.DynamoDBAPI
+	table string
+}
+
+// NewDynamoDBStore will initiate the DynamoDB client for the table in the
+// config. If no credentials are passed in with the config, the store is
+// instantiated with the AWS_ACCESS_KEY and the AWS_SECRET_KEY environment
+// variables.
+func NewDynamoDBStore(cfg *config.DynamoDB) (*DynamoDBStore, error) {
+	if cfg.TableName == "" {
+		return nil, errors.New("dynamodb table name is required")
+	}
+	if cfg.Region == "" {
+		return nil, errors.New("dynamodb region is required")
+	}
+
+	var creds *credentials.Credentials
+	if cfg.AccessKey != "" {
+		creds = credentials.NewStaticCredentials(cfg.AccessKey, cfg.SecretKey, "")
+	} else {
+		creds = credentials.NewEnvCredentials()
+	}
+	return &DynamoDBStore{
+		db: dynamodb.New(session.New(&aws.Config{
+			Credentials: creds,
+			Region:      &cfg.Region,
+		})),
+		table: cfg.TableName,
+	}, nil
+}
+
+// Get will do a consistent read of the execution.
+func (d *DynamoDBStore) Get(id string) (*Execution, error) {
+	out, err := d.db.GetItem(&dynamodb.GetItemInput{
+		TableName:      &d.table,
+		Key:            map[string]*dynamodb.AttributeValue{"id": {S: &id}},
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return nil, err
+	}
+	state, ok := out.Item["state"]
+	if !ok || state.S == nil {
+		return nil, ErrNotFound
+	}
+	var e Execution
+	return &e, json.Unmarshal([]byte(*state.S), &e)
+}
+
+// Put will write the execution with a condition on its version.
+func (d *DynamoDBStore) Put(e *Execution) error {
+	e.Version++
+	b, err := json.Marshal(e)
+	if err != nil {
+		e.Version--
+		return err
+	}
+	in := &dynamodb.PutItemInput{
+		TableName: &d.table,
+		Item: map[string]*dynamodb.AttributeValue{
+			"id":      {S: &e.ID},
+			"version": {N: aws.String(strconv.FormatInt(e.Version, 10))},
+			"state":   {S: aws.String(string(b))},
+		},
+		ExpressionAttributeNames: map[string]*string{"#id": aws.String("id")},
+	}
+	if e.Version == 1 {
+		in.ConditionExpression = aws.String("attribute_not_exists(#id)")
+	} else {
+		in.ConditionExpression = aws.String("#version = :version")
+		in.ExpressionAttributeNames = map[string]*string{"#version": aws.String("version")}
+		in.ExpressionAttributeValues = map[string]*dynamodb.AttributeValue{
+			":version": {N: aws.String(strconv.FormatInt(e.Version-1, 10))},
+		}
+	}
+
+	_, err = d.db.PutItem(in)
+	if err != nil {
+		e.Version--
+		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == "ConditionalCheckFailedException" {
+			return ErrConflict
+		}
+	}
+	return err
+}
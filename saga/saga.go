@@ -0,0 +1,309 @@
+package saga
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/nu7hatch/gouuid"
+	"golang.org/x/net/context"
+
+	"github.com/NYTimes/gizmo/jobs"
+	"github.com/NYTimes/gizmo/pubsub"
+)
+
+// Log is the structured logger used throughout the package.
+var Log = logrus.New()
+
+// Status is the state of a saga execution.
+type Status string
+
+const (
+	// StatusRunning is an execution that is running its steps.
+	StatusRunning Status = "running"
+	// StatusCompensating is an execution that had a step fail and is
+	// running the compensation of its completed steps.
+	StatusCompensating Status = "compensating"
+	// StatusCompleted is an execution that has run all of its steps.
+	StatusCompleted Status = "completed"
+	// StatusCompensated is an execution that had a step fail and has
+	// compensated all of its completed steps.
+	StatusCompensated Status = "compensated"
+	// StatusFailed is an execution that had a compensation fail and
+	// will need to be resolved by hand.
+	StatusFailed Status = "failed"
+)
+
+// StepFunc is a func for running or compensating a step of an execution.
+// It may change the execution's Data with Encode for use in later steps.
+type StepFunc func(context.Context, *Execution) error
+
+// Step is a single step of a Saga.
+type Step struct {
+	// Name is used in logs and errors.
+	Name string
+	// Action will run the step. If it returns an error once it has run
+	// out of attempts, all completed steps will be compensated.
+	Action StepFunc
+	// Compensate is an optional func to undo the step's Action.
+	Compensate StepFunc
+}
+
+// Saga is a named sequence of steps. Steps are run at least once, so
+// their Action and Compensate funcs should be idempotent.
+type Saga struct {
+	Name  string
+	Steps []Step
+}
+
+// Execution is the persisted state of a single run of a Saga.
+type Execution struct {
+	ID   string `json:"id"`
+	Saga string `json:"saga"`
+	// Status is the state of the execution.
+	Status Status `json:"status"`
+	// Step is the index of the step being run or compensated.
+	Step int `json:"step"`
+	// Data is the JSON encoded data shared by all of its steps.
+	Data json.RawMessage `json:"data,omitempty"`
+	// Error is the error of the step that caused the compensation.
+	Error string `json:"error,omitempty"`
+	// Version is incremented by the Store each time it is saved.
+	Version int64 `json:"version"`
+	// LastJobID is the ID of the job that last advanced the execution.
+	LastJobID string    `json:"last_job_id,omitempty"`
+	StartedAt time.Time `json:"started_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Decode will unmarshal the execution's Data into v.
+func (e *Execution) Decode(v interface{}) error {
+	return json.Unmarshal(e.Data, v)
+}
+
+// Encode will replace the execution's Data with v as JSON.
+func (e *Execution) Encode(v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	e.Data = b
+	return nil
+}
+
+// Done will return true if the execution will not run any more steps.
+func (e *Execution) Done() bool {
+	return e.Status == StatusCompleted || e.Status == StatusCompensated || e.Status == StatusFailed
+}
+
+// stepCommand is the payload of the job that runs a step.
+type stepCommand struct {
+	ID         string `json:"id"`
+	Step       int    `json:"step"`
+	Compensate bool   `json:"compensate,omitempty"`
+}
+
+type registeredSaga struct {
+	saga        *Saga
+	maxAttempts int
+}
+
+// Coordinator runs each step of a saga as a job and persists the state of
+// each execution between steps.
+type Coordinator struct {
+	store  Store
+	client *jobs.Client
+
+	mu    sync.RWMutex
+	sagas map[string]*registeredSaga
+}
+
+// NewCoordinator will return a Coordinator that keeps executions in the
+// Store and publishes the jobs for each step with the Publisher.
+func NewCoordinator(store Store, pub pubsub.Publisher) *Coordinator {
+	return &Coordinator{
+		store:  store,
+		client: jobs.NewClient(pub),
+		sagas:  map[string]*registeredSaga{},
+	}
+}
+
+// JobType is the type of the jobs used to run the steps of a saga.
+func JobType(saga string) string {
+	return "saga." + saga
+}
+
+// Register will add the saga and register the handler for its steps on the
+// Worker, which should receive the jobs published by the Coordinator. The
+// options will be used for every step and its compensation.
+func (c *Coordinator) Register(w *jobs.Worker, s *Saga, opts jobs.HandlerOptions) error {
+	if s.Name == "" {
+		return errors.New("saga name is required")
+	}
+	if len(s.Steps) == 0 {
+		return fmt.Errorf("saga %q has no steps", s.Name)
+	}
+	for i, step := range s.Steps {
+		if step.Action == nil {
+			return fmt.Errorf("saga %q step %d has no action", s.Name, i)
+		}
+	}
+
+	rs := &registeredSaga{saga: s, maxAttempts: opts.Retry.MaxAttempts}
+	if rs.maxAttempts < 1 {
+		rs.maxAttempts = jobs.DefaultMaxAttempts
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.sagas[s.Name]; ok {
+		return fmt.Errorf("saga %q is already registered", s.Name)
+	}
+	if err := w.Register(JobType(s.Name), c.handler(rs), opts); err != nil {
+		return err
+	}
+	c.sagas[s.Name] = rs
+	return nil
+}
+
+// Start will save a new execution of the saga with the given data and
+// enqueue its first step. It will return the ID of the execution.
+func (c *Coordinator) Start(saga string, data interface{}) (string, error) {
+	c.mu.RLock()
+	_, ok := c.sagas[saga]
+	c.mu.RUnlock()
+	if !ok {
+		return "", fmt.Errorf("saga %q is not registered", saga)
+	}
+	id, err := uuid.NewV4()
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	e := &Execution{
+		ID:        id.String(),
+		Saga:      saga,
+		Status:    StatusRunning,
+		StartedAt: now,
+		UpdatedAt: now,
+	}
+	if err := e.Encode(data); err != nil {
+		return "", err
+	}
+	if err := c.store.Put(e); err != nil {
+		return "", err
+	}
+	return e.ID, c.enqueue(e)
+}
+
+// Get will return the current state of the execution with the given ID.
+func (c *Coordinator) Get(id string) (*Execution, error) {
+	return c.store.Get(id)
+}
+
+func (c *Coordinator) enqueue(e *Execution) error {
+	_, err := c.client.Enqueue(JobType(e.Saga), stepCommand{
+		ID:         e.ID,
+		Step:       e.Step,
+		Compensate: e.Status == StatusCompensating,
+	}, jobs.EnqueueOptions{})
+	return err
+}
+
+func (c *Coordinator) handler(rs *registeredSaga) jobs.Handler {
+	return func(ctx context.Context, j *jobs.Job) error {
+		var cmd stepCommand
+		if err := j.Decode(&cmd); err != nil {
+			Log.Errorf("unable to decode saga step, dropping it: %s", err)
+			return nil
+		}
+		log := Log.WithFields(logrus.Fields{"saga": rs.saga.Name, "saga_id": cmd.ID, "step": cmd.Step})
+
+		e, err := c.store.Get(cmd.ID)
+		if err == ErrNotFound {
+			log.Error("saga execution not found, dropping step")
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case e.LastJobID == j.ID && !e.Done():
+			// this job already advanced the execution but was unable to
+			// enqueue the next step
+			return c.enqueue(e)
+		case e.Done() || e.Step != cmd.Step || (e.Status == StatusCompensating) != cmd.Compensate ||
+			cmd.Step < 0 || cmd.Step >= len(rs.saga.Steps):
+			log.Debug("dropping stale saga step")
+			return nil
+		}
+
+		maxAttempts := j.MaxAttempts
+		if maxAttempts < 1 {
+			maxAttempts = rs.maxAttempts
+		}
+		lastAttempt := j.Attempt+1 >= maxAttempts
+
+		step := rs.saga.Steps[cmd.Step]
+		if !cmd.Compensate {
+			if err := step.Action(ctx, e); err != nil {
+				if !lastAttempt {
+					return err
+				}
+				log.Errorf("saga step %q failed, compensating: %s", step.Name, err)
+				e.Status = StatusCompensating
+				e.Error = fmt.Sprintf("%s: %s", step.Name, err)
+				return c.advance(rs, e, j.ID, cmd.Step-1)
+			}
+			return c.advance(rs, e, j.ID, cmd.Step+1)
+		}
+
+		if err := step.Compensate(ctx, e); err != nil {
+			if !lastAttempt {
+				return err
+			}
+			log.Errorf("saga compensation of step %q failed: %s", step.Name, err)
+			e.Status = StatusFailed
+			e.Error = fmt.Sprintf("%s; compensating %s: %s", e.Error, step.Name, err)
+			return c.save(e, j.ID)
+		}
+		return c.advance(rs, e, j.ID, cmd.Step-1)
+	}
+}
+
+// advance will move the execution to the next step to run or compensate,
+// save it and enqueue the next step.
+func (c *Coordinator) advance(rs *registeredSaga, e *Execution, jobID string, next int) error {
+	if e.Status == StatusCompensating {
+		// skip any steps with nothing to compensate
+		for next >= 0 && rs.saga.Steps[next].Compensate == nil {
+			next--
+		}
+		if next < 0 {
+			e.Status = StatusCompensated
+		}
+	} else if next >= len(rs.saga.Steps) {
+		e.Status = StatusCompleted
+	}
+	e.Step = next
+	if err := c.save(e, jobID); err != nil || e.Done() {
+		return err
+	}
+	return c.enqueue(e)
+}
+
+func (c *Coordinator) save(e *Execution, jobID string) error {
+	e.LastJobID = jobID
+	e.UpdatedAt = time.Now()
+	err := c.store.Put(e)
+	if err == ErrConflict {
+		// a duplicate of this step has already advanced the execution
+		Log.WithField("saga_id", e.ID).Warn("saga execution was advanced concurrently, dropping step")
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,252 @@
+package saga
+
+import (
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"golang.org/x/net/context"
+
+	"github.com/NYTimes/gizmo/jobs"
+	"github.com/NYTimes/gizmo/pubsub"
+)
+
+// testQueue is an in-memory queue that delivers everything published to it.
+type testQueue struct {
+	mu      sync.Mutex
+	msgs    chan pubsub.SubscriberMessage
+	stopped bool
+}
+
+func (q *testQueue) Publish(key string, msg proto.Message) error {
+	b, err := proto.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	return q.PublishRaw(key, b)
+}
+
+func (q *testQueue) PublishRaw(key string, msg []byte) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if !q.stopped {
+		q.msgs <- testQueueMsg(msg)
+	}
+	return nil
+}
+
+func (q *testQueue) Start() <-chan pubsub.SubscriberMessage { return q.msgs }
+
+func (q *testQueue) Stop() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if !q.stopped {
+		q.stopped = true
+		close(q.msgs)
+	}
+	return nil
+}
+
+func (q *testQueue) Err() error { return nil }
+
+type testQueueMsg []byte
+
+func (m testQueueMsg) Message() []byte                        { return m }
+func (m testQueueMsg) ExtendDoneDeadline(time.Duration) error { return nil }
+func (m testQueueMsg) Done() error                            { return nil }
+
+type order struct {
+	Items   int
+	Charged bool
+}
+
+func TestCoordinator(t *testing.T) {
+	tests := []struct {
+		name        string
+		failStep    string
+		failCompens string
+
+		wantStatus Status
+		wantCalls  []string
+		wantError  string
+	}{
+		{
+			name:       "completed",
+			wantStatus: StatusCompleted,
+			wantCalls:  []string{"reserve", "charge", "ship"},
+		},
+		{
+			name:       "compensated",
+			failStep:   "ship",
+			wantStatus: StatusCompensated,
+			wantCalls:  []string{"reserve", "charge", "ship", "ship", "refund", "release"},
+			wantError:  "ship: failed",
+		},
+		{
+			name:       "first step",
+			failStep:   "reserve",
+			wantStatus: StatusCompensated,
+			wantCalls:  []string{"reserve", "reserve"},
+			wantError:  "reserve: failed",
+		},
+		{
+			name:        "failed",
+			failStep:    "ship",
+			failCompens: "refund",
+			wantStatus:  StatusFailed,
+			wantCalls:   []string{"reserve", "charge", "ship", "ship", "refund", "refund"},
+			wantError:   "ship: failed; compensating charge: failed",
+		},
+	}
+
+	for _, test := range tests {
+		var (
+			mu    sync.Mutex
+			calls []string
+		)
+		step := func(name, fail string) StepFunc {
+			return func(ctx context.Context, e *Execution) error {
+				mu.Lock()
+				calls = append(calls, name)
+				mu.Unlock()
+				if name == fail {
+					return errors.New("failed")
+				}
+				if name == "charge" {
+					var o order
+					if err := e.Decode(&o); err != nil {
+						return err
+					}
+					o.Charged = true
+					return e.Encode(o)
+				}
+				return nil
+			}
+		}
+		s := &Saga{
+			Name: "order",
+			Steps: []Step{
+				{Name: "reserve", Action: step("reserve", test.failStep), Compensate: step("release", test.failCompens)},
+				{Name: "charge", Action: step("charge", test.failStep), Compensate: step("refund", test.failCompens)},
+				{Name: "ship", Action: step("ship", test.failStep)},
+			},
+		}
+
+		q := &testQueue{msgs: make(chan pubsub.SubscriberMessage, 10)}
+		w := jobs.NewWorker(q, q)
+		c := NewCoordinator(NewMemoryStore(), q)
+		err := c.Register(w, s, jobs.HandlerOptions{
+			Retry: jobs.RetryPolicy{MaxAttempts: 2, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond},
+		})
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %s", test.name, err)
+		}
+		go w.Run()
+
+		id, err := c.Start("order", order{Items: 2})
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %s", test.name, err)
+		}
+
+		var e *Execution
+		deadline := time.Now().Add(2 * time.Second)
+		for time.Now().Before(deadline) {
+			if e, err = c.Get(id); err != nil {
+				t.Fatalf("%s: unexpected error: %s", test.name, err)
+			}
+			if e.Done() {
+				break
+			}
+			time.Sleep(time.Millisecond)
+		}
+		w.Stop()
+
+		if e.Status != test.wantStatus {
+			t.Errorf("%s: expected status %q, got %q", test.name, test.wantStatus, e.Status)
+		}
+		if e.Error != test.wantError {
+			t.Errorf("%s: expected error %q, got %q", test.name, test.wantError, e.Error)
+		}
+		mu.Lock()
+		if !reflect.DeepEqual(calls, test.wantCalls) {
+			t.Errorf("%s: expected calls %v, got %v", test.name, test.wantCalls, calls)
+		}
+		mu.Unlock()
+
+		if test.wantStatus == StatusCompleted {
+			var o order
+			if err := e.Decode(&o); err != nil {
+				t.Fatalf("%s: unexpected error: %s", test.name, err)
+			}
+			if !o.Charged || o.Items != 2 {
+				t.Errorf("%s: expected data changed by step to be kept, got %#v", test.name, o)
+			}
+		}
+	}
+}
+
+func TestCoordinatorRegister(t *testing.T) {
+	noop := func(context.Context, *Execution) error { return nil }
+	tests := []struct {
+		given *Saga
+		want  string
+	}{
+		{&Saga{}, "saga name is required"},
+		{&Saga{Name: "test"}, `saga "test" has no steps`},
+		{&Saga{Name: "test", Steps: []Step{{Name: "a"}}}, `saga "test" step 0 has no action`},
+		{&Saga{Name: "test", Steps: []Step{{Name: "a", Action: noop}}}, ""},
+	}
+
+	for _, test := range tests {
+		q := &testQueue{}
+		c := NewCoordinator(NewMemoryStore(), q)
+		err := c.Register(jobs.NewWorker(q, q), test.given, jobs.HandlerOptions{})
+		var got string
+		if err != nil {
+			got = err.Error()
+		}
+		if got != test.want {
+			t.Errorf("expected error %q, got %q", test.want, got)
+		}
+	}
+
+	c := NewCoordinator(NewMemoryStore(), &testQueue{})
+	if _, err := c.Start("unknown", nil); err == nil {
+		t.Error("expected error starting an unregistered saga, got nil")
+	}
+}
+
+func TestCoordinatorStaleStep(t *testing.T) {
+	var runs int
+	s := &Saga{Name: "test", Steps: []Step{{Name: "a", Action: func(context.Context, *Execution) error {
+		runs++
+		return nil
+	}}}}
+	store := NewMemoryStore()
+	c := NewCoordinator(store, &testQueue{msgs: make(chan pubsub.SubscriberMessage, 10)})
+	rs := &registeredSaga{saga: s, maxAttempts: 1}
+	h := c.handler(rs)
+
+	e := &Execution{ID: "abc", Saga: "test", Status: StatusRunning}
+	if err := store.Put(e); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	j := &jobs.Job{ID: "job1", Type: JobType("test"), Payload: []byte(`{"id":"abc","step":0}`)}
+	if err := h(context.Background(), j); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	// a redelivered duplicate should not run the step again
+	j2 := &jobs.Job{ID: "job2", Type: JobType("test"), Payload: []byte(`{"id":"abc","step":0}`)}
+	if err := h(context.Background(), j2); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if runs != 1 {
+		t.Errorf("expected 1 run, got %d", runs)
+	}
+	if got, _ := store.Get("abc"); got.Status != StatusCompleted || got.LastJobID != "job1" {
+		t.Errorf("expected execution completed by job1, got %q by %q", got.Status, got.LastJobID)
+	}
+}
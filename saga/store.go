@@ -0,0 +1,61 @@
+package saga
+
+import (
+	"errors"
+	"sync"
+)
+
+var (
+	// ErrNotFound is returned by a Store when an execution does not exist.
+	ErrNotFound = errors.New("saga execution not found")
+	// ErrConflict is returned by a Store when an execution has been
+	// saved by someone else since it was loaded.
+	ErrConflict = errors.New("saga execution was modified concurrently")
+)
+
+// Store persists the state of saga executions.
+type Store interface {
+	// Get will return the execution with the given ID or ErrNotFound.
+	Get(id string) (*Execution, error)
+	// Put will save the execution if its Version matches the stored
+	// version, or it does not exist yet and its Version is 0, and then
+	// increment its Version. Otherwise it will return ErrConflict.
+	Put(e *Execution) error
+}
+
+// MemoryStore is an in-memory Store for services with a single
+// instance and for tests.
+type MemoryStore struct {
+	mu         sync.Mutex
+	executions map[string]Execution
+}
+
+// NewMemoryStore will return an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{executions: map[string]Execution{}}
+}
+
+// Get will return a copy of the stored execution.
+func (m *MemoryStore) Get(id string) (*Execution, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e, ok := m.executions[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &e, nil
+}
+
+// Put will store a copy of the execution.
+func (m *MemoryStore) Put(e *Execution) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if stored, ok := m.executions[e.ID]; (ok && stored.Version != e.Version) || (!ok && e.Version != 0) {
+		return ErrConflict
+	}
+	e.Version++
+	stored := *e
+	stored.Data = append([]byte(nil), e.Data...)
+	m.executions[e.ID] = stored
+	return nil
+}
@@ -0,0 +1,100 @@
+package saga
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+func testStore(t *testing.T, s Store) {
+	if _, err := s.Get("abc"); err != ErrNotFound {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+
+	e := &Execution{ID: "abc", Saga: "test", Status: StatusRunning, Data: []byte(`{"a":1}`)}
+	if err := s.Put(e); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if e.Version != 1 {
+		t.Errorf("expected version 1, got %d", e.Version)
+	}
+
+	got, err := s.Get("abc")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if got.Version != 1 || got.Status != StatusRunning || string(got.Data) != `{"a":1}` {
+		t.Errorf("expected stored execution, got %#v", got)
+	}
+
+	// a stale copy should not overwrite a newer one
+	stale := *got
+	got.Step = 1
+	if err := s.Put(got); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := s.Put(&stale); err != ErrConflict {
+		t.Errorf("expected ErrConflict, got %v", err)
+	}
+	if stale.Version != 1 {
+		t.Errorf("expected version to be unchanged on conflict, got %d", stale.Version)
+	}
+	// nor should a new one
+	if err := s.Put(&Execution{ID: "abc"}); err != ErrConflict {
+		t.Errorf("expected ErrConflict, got %v", err)
+	}
+
+	got, err = s.Get("abc")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if got.Version != 2 || got.Step != 1 {
+		t.Errorf("expected version 2 at step 1, got version %d at step %d", got.Version, got.Step)
+	}
+}
+
+func TestMemoryStore(t *testing.T) {
+	testStore(t, NewMemoryStore())
+}
+
+// testDynamoDBAPI will evaluate version conditions against an
+// in-memory table. All other DynamoDBAPI methods will panic.
+type testDynamoDBAPI struct {
+	dynamodbiface.DynamoDBAPI
+
+	items map[string]map[string]*dynamodb.AttributeValue
+}
+
+func (d *testDynamoDBAPI) GetItem(i *dynamodb.GetItemInput) (*dynamodb.GetItemOutput, error) {
+	return &dynamodb.GetItemOutput{Item: d.items[*i.Key["id"].S]}, nil
+}
+
+func (d *testDynamoDBAPI) PutItem(i *dynamodb.PutItemInput) (*dynamodb.PutItemOutput, error) {
+	id := *i.Item["id"].S
+	existing, ok := d.items[id]
+	var pass bool
+	switch *i.ConditionExpression {
+	case "attribute_not_exists(#id)":
+		pass = !ok
+	case "#version = :version":
+		pass = ok && *existing["version"].N == *i.ExpressionAttributeValues[":version"].N
+	}
+	if !pass {
+		return nil, awserr.New("ConditionalCheckFailedException", "condition failed", nil)
+	}
+	if _, err := strconv.ParseInt(*i.Item["version"].N, 10, 64); err != nil {
+		return nil, err
+	}
+	d.items[id] = i.Item
+	return &dynamodb.PutItemOutput{}, nil
+}
+
+func TestDynamoDBStore(t *testing.T) {
+	testStore(t, &DynamoDBStore{
+		db:    &testDynamoDBAPI{items: map[string]map[string]*dynamodb.AttributeValue{}},
+		table: "sagas",
+	})
+}
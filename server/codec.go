@@ -0,0 +1,75 @@
+package server
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+
+	"github.com/NYTimes/gizmo/codec"
+)
+
+// ProtobufContentType is the content type that JSONEndpoints will accept and
+// respond with for callers preferring protobufs over JSON.
+const ProtobufContentType = codec.ProtobufContentType
+
+// DecodeRequest will decode the request body into v with the codec matching
+// the request's Content-Type, such as protobuf, MessagePack or CBOR. A
+// protobuf body is only decoded as such if v is a proto.Message. Otherwise,
+// the body is decoded as JSON.
+func DecodeRequest(r *http.Request, v interface{}) error {
+	c, ok := codec.ForContentType(r.Header.Get("Content-Type"))
+	if _, isPB := v.(proto.Message); !ok || (c == codec.Protobuf && !isPB) {
+		c = codec.JSON
+	}
+	if c == codec.JSON {
+		return json.NewDecoder(r.Body).Decode(v)
+	}
+	b, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return err
+	}
+	return c.Unmarshal(b, v)
+}
+
+// AcceptsProtobuf will return true if the request's Accept header lists
+// ProtobufContentType.
+func AcceptsProtobuf(r *http.Request) bool {
+	for _, accept := range strings.Split(r.Header.Get("Accept"), ",") {
+		if c, ok := codec.ForContentType(accept); ok && c == codec.Protobuf {
+			return true
+		}
+	}
+	return false
+}
+
+// responseCodec will return the codec negotiated by the request's Accept
+// header for res, if it is not JSON. Protobuf is only used if res is a
+// proto.Message.
+func responseCodec(r *http.Request, res interface{}) (codec.Codec, bool) {
+	c, ok := codec.Negotiate(r.Header.Get("Accept"))
+	if !ok || c == codec.JSON {
+		return nil, false
+	}
+	if _, isPB := res.(proto.Message); c == codec.Protobuf && !isPB {
+		return nil, false
+	}
+	return c, true
+}
+
+// writeCodec will write the status code and encoded response to w.
+func writeCodec(w http.ResponseWriter, r *http.Request, c codec.Codec, code int, res interface{}) {
+	b, err := c.Marshal(res)
+	if err != nil {
+		LogWithFields(r).Error("unable to encode response: ", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", c.ContentType())
+	w.WriteHeader(code)
+	if _, err := w.Write(b); err != nil {
+		LogWithFields(r).Warn("unable to write response: ", err)
+	}
+}
@@ -0,0 +1,116 @@
+package server
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+
+	"github.com/NYTimes/gizmo/codec"
+)
+
+type testProto struct {
+	Value string `protobuf:"bytes,1,opt,name=value" json:"value,omitempty"`
+}
+
+func (m *testProto) Reset()         { *m = testProto{} }
+func (m *testProto) String() string { return proto.CompactTextString(m) }
+func (*testProto) ProtoMessage()    {}
+
+func TestJSONToHTTPCodecs(t *testing.T) {
+	ep := JSONToHTTP(func(r *http.Request) (int, interface{}, error) {
+		var req testProto
+		if err := DecodeRequest(r, &req); err != nil {
+			t.Error("unable to decode request: ", err)
+		}
+		return http.StatusCreated, &testProto{Value: "hi " + req.Value}, nil
+	})
+
+	tests := []struct {
+		name        string
+		contentType string
+		accept      string
+		body        []byte
+
+		wantContentType string
+		wantValue       string
+	}{
+		{
+			"json",
+			"application/json",
+			"",
+			[]byte(`{"value":"tom"}`),
+			jsonContentType,
+			"hi tom",
+		},
+		{
+			"protobuf",
+			ProtobufContentType,
+			"application/json;q=0.9, application/x-protobuf",
+			mustEncode(codec.Protobuf, &testProto{Value: "tom"}),
+			ProtobufContentType,
+			"hi tom",
+		},
+		{
+			"json in, protobuf out",
+			"application/json",
+			ProtobufContentType,
+			[]byte(`{"value":"tom"}`),
+			ProtobufContentType,
+			"hi tom",
+		},
+		{
+			"msgpack",
+			codec.MsgPackContentType,
+			"application/cbor;q=0.5, application/x-msgpack",
+			mustEncode(codec.MsgPack, &testProto{Value: "tom"}),
+			codec.MsgPackContentType,
+			"hi tom",
+		},
+		{
+			"cbor",
+			codec.CBORContentType,
+			codec.CBORContentType,
+			mustEncode(codec.CBOR, &testProto{Value: "tom"}),
+			codec.CBORContentType,
+			"hi tom",
+		},
+	}
+
+	for _, test := range tests {
+		r, _ := http.NewRequest("POST", "/", bytes.NewReader(test.body))
+		r.Header.Set("Content-Type", test.contentType)
+		r.Header.Set("Accept", test.accept)
+		w := httptest.NewRecorder()
+		ep.ServeHTTP(w, r)
+
+		if w.Code != http.StatusCreated {
+			t.Errorf("%s: expected status code %d, got %d", test.name, http.StatusCreated, w.Code)
+		}
+		if got := w.Header().Get("Content-Type"); got != test.wantContentType {
+			t.Errorf("%s: expected Content-Type %q, got %q", test.name, test.wantContentType, got)
+		}
+		c, ok := codec.ForContentType(w.Header().Get("Content-Type"))
+		if !ok {
+			t.Errorf("%s: expected a known response Content-Type", test.name)
+			continue
+		}
+		var got testProto
+		if err := c.Unmarshal(w.Body.Bytes(), &got); err != nil {
+			t.Errorf("%s: unable to decode response: %s", test.name, err)
+		}
+		if got.Value != test.wantValue {
+			t.Errorf("%s: expected value %q, got %q", test.name, test.wantValue, got.Value)
+		}
+	}
+}
+
+func mustEncode(c codec.Codec, v interface{}) []byte {
+	b, err := c.Marshal(v)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
@@ -0,0 +1,40 @@
+// +build !windows
+
+package server
+
+import (
+	"runtime"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// NewCPUSampler will return a func that reports the CPU usage of the current
+// process as a fraction of all cores since the last time it was called.
+func NewCPUSampler() func() float64 {
+	var (
+		mu       sync.Mutex
+		lastCPU  = processCPUTime()
+		lastTime = time.Now()
+	)
+	return func() float64 {
+		mu.Lock()
+		defer mu.Unlock()
+		cpu, now := processCPUTime(), time.Now()
+		wall := now.Sub(lastTime)
+		used := cpu - lastCPU
+		lastCPU, lastTime = cpu, now
+		if wall <= 0 {
+			return 0
+		}
+		return float64(used) / float64(wall) / float64(runtime.NumCPU())
+	}
+}
+
+func processCPUTime() time.Duration {
+	var ru syscall.Rusage
+	if err := syscall.Getrusage(syscall.RUSAGE_SELF, &ru); err != nil {
+		return 0
+	}
+	return time.Duration(ru.Utime.Nano() + ru.Stime.Nano())
+}
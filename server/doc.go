@@ -53,10 +53,49 @@ The 3 service types that are accepted and hostable on the `SimpleServer`:
         JSONMiddleware(JSONEndpoint) JSONEndpoint
     }
 
+The `SimpleServer` can also act as a lightweight API gateway by hosting a `ProxyService`, which forwards
+its routes to upstream servers with header rewriting, retries, timeouts and circuit breaking:
+
+    type ProxyService interface {
+        Service
+
+        // route - method - upstream
+        ProxyEndpoints() map[string]map[string]*ProxyUpstream
+    }
+
 Where a `JSONEndpoint` is defined as:
 
     type JSONEndpoint func(*http.Request) (int, interface{}, error)
 
+Any service may also implement the optional `DocumentedService` interface to describe the request and response types of its endpoints for client and documentation generation:
+
+    type DocumentedService interface {
+        // route - method - doc
+        EndpointDocs() map[string]map[string]*EndpointDoc
+    }
+
+Routes can declare latency objectives by implementing the optional `SLOService` interface. Each route with an `SLO` will emit SLO total, breach and target metrics and log a warning for every slow request:
+
+    type SLOService interface {
+        // route - method - SLO
+        SLOs() map[string]map[string]*SLO
+    }
+
+Middleware can be scoped to a subset of services with route groups. Each group adds its prefix and middleware to the services registered with it:
+
+    admin := server.Group("/admin", authMW, auditMW)
+    err := admin.Register(&AdminService{})
+
+Services can be registered under multiple API versions with shared handlers by implementing the optional `VersionedService` interface. Each `APIVersion` is appended to the service prefix and deprecated versions will automatically emit `Deprecation`, `Sunset` and `Link` headers:
+
+    type VersionedService interface {
+        Service
+
+        Versions() []*APIVersion
+    }
+
+If `EnableOpenAPI` is set in the server config, an OpenAPI 3 document of all registered routes will be served at `/openapi.json` along with a Swagger UI at `/openapi/`.
+
 Also, the one service type that works with an `RPCServer`:
 
     type RPCService interface {
@@ -70,6 +109,27 @@ Also, the one service type that works with an `RPCServer`:
         JSONMiddlware(JSONEndpoint) JSONEndpoint
     }
 
+Setting `HealthCheckWarmup` will make the health check fail for a while after start up so load balancers don't send traffic to a cold instance. With `LameDuckDuration`, the server will fail its health check but keep serving for the given duration once it begins shutting down. Lame duck mode can also be toggled by a PUT or DELETE to the admin endpoint at `LameDuckPath`.
+
+A `SimpleServer` can serve over several listeners at once: the `HTTPPort`, an optional `HTTPSPort` for TLS alongside plain HTTP and an optional `HTTPSocket` unix domain socket for sidecar proxies.
+
+To degrade gracefully under a traffic spike, a `SimpleServer` can cap the number of requests it handles at once with `MaxConcurrentRequests`. Requests over the cap wait in a queue bounded by `MaxQueuedRequests` and `MaxQueueWait` and any overflow gets a 503. `MaxConnsPerClient` will cap the number of open connections from each client IP.
+
+If `LoadShedLatency` or `LoadShedCPU` are set, a `SimpleServer` will start rejecting a growing percentage of low priority requests while its p99 latency or CPU usage is over the threshold. Services can hint which of their routes are low priority or critical by implementing the optional `PrioritizedService` interface:
+
+    type PrioritizedService interface {
+        // route - method - priority
+        Priorities() map[string]map[string]Priority
+    }
+
+Servers bind their ports via `Listen`, which will reuse any listeners inherited through systemd socket activation or a previous process. If `GracefulRestart` is set in the server config, sending the process a SIGUSR2 will start a new copy of the binary with the current listeners and stop the old process once its in-flight requests complete, so binaries can be upgraded without dropping connections.
+
+For debugging production incidents where pprof can't be reached directly, setting a `ProfileBucket` will make `Run` capture heap, goroutine and CPU profiles on a SIGUSR1 and upload them to S3 under timestamped keys. A POST to the optional `ProfilePath` admin endpoint will do the same and respond with the uploaded keys.
+
+To check whether a consumer running alongside the server is seeing anything, set a `MessageTracePath`, import the `server/pubsubhttp` package and give the `pubsub.Consumer` a `Tracer` of `pubsub.DefaultMessageTracer`. A GET to the admin endpoint will show the ID, subject, size, handler latency and outcome of the last messages each consumer received.
+
+Set `ErrorReporter` to a `reporting.Reporter` to send any panic recovered while serving a request to Sentry, Rollbar or another error reporting service along with the request.
+
 The `Middleware(..)` functions offer each service a 'hook' to wrap each of its endpoints. This may be handy for adding additional headers or context to the request. This is also the point where other, third-party middleware could be easily be plugged in (ie. oauth, tracing, metrics, logging, etc.)
 
 Examples
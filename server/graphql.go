@@ -0,0 +1,306 @@
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/language/parser"
+	"golang.org/x/net/context"
+
+	"github.com/NYTimes/gizmo/web"
+)
+
+var (
+	// DefaultGraphQLPath is the path under a GraphQLService's prefix its
+	// schema will be served at if the GraphQLEndpoint has no Path.
+	DefaultGraphQLPath = "/graphql"
+	// GraphQLPlaygroundPath is the path under a GraphQLService's endpoint the
+	// playground will be served at if EnableGraphQLPlayground is set.
+	GraphQLPlaygroundPath = "/playground"
+	// GraphQLPlaygroundAssets is the base URL used to load the GraphiQL
+	// playground scripts and styles.
+	GraphQLPlaygroundAssets = "https://unpkg.com/graphiql@0.11"
+
+	// ErrPersistedQueryNotFound is returned to clients that send the ID or
+	// hash of a query that is not in the PersistedQueries.
+	ErrPersistedQueryNotFound = errors.New("PersistedQueryNotFound")
+	// ErrPersistedQueryOnly is returned to clients that send a query
+	// that is not persisted to a GraphQLEndpoint with PersistedOnly set.
+	ErrPersistedQueryOnly = errors.New("only persisted queries are allowed")
+)
+
+// GraphQLEndpoint describes the GraphQL schema served by a GraphQLService.
+type GraphQLEndpoint struct {
+	// Path is where the schema will be served under the service's prefix.
+	// Defaults to DefaultGraphQLPath.
+	Path string
+	// Schema is the GraphQL schema, including its resolvers.
+	Schema graphql.Schema
+
+	// PersistedQueries maps query IDs, or the hex SHA-256 hashes sent by
+	// Apollo's automatic persisted queries, to their queries.
+	PersistedQueries map[string]string
+	// PersistedOnly will reject any query that is not in PersistedQueries.
+	PersistedOnly bool
+
+	// MaxComplexity is the max number of fields a query may select,
+	// including those in fragments. If 0, complexity is not limited.
+	MaxComplexity int
+	// MaxDepth is the max depth of fields a query may select. If 0, depth
+	// is not limited.
+	MaxDepth int
+
+	// Context is an optional hook for adding values from the request to the
+	// context passed to resolvers.
+	Context func(context.Context, *http.Request) context.Context
+}
+
+func (e *GraphQLEndpoint) path() string {
+	if e.Path == "" {
+		return DefaultGraphQLPath
+	}
+	return e.Path
+}
+
+type graphQLRequest struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+	ID            string                 `json:"id"`
+	Extensions    struct {
+		PersistedQuery *struct {
+			SHA256Hash string `json:"sha256Hash"`
+		} `json:"persistedQuery"`
+	} `json:"extensions"`
+}
+
+// NewGraphQLHandler will return an http.Handler that executes queries against
+// the endpoint's schema. Queries may be sent as a JSON body in a POST or as
+// 'query', 'operationName', 'variables', 'id' and 'extensions' URL
+// parameters in a GET.
+func NewGraphQLHandler(ep *GraphQLEndpoint) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", jsonContentType)
+		req, err := parseGraphQLRequest(r)
+		if err != nil {
+			writeGraphQLError(w, r, http.StatusBadRequest, err)
+			return
+		}
+		query, err := ep.query(req)
+		if err != nil {
+			writeGraphQLError(w, r, http.StatusBadRequest, err)
+			return
+		}
+		if err = ep.checkLimits(query); err != nil {
+			writeGraphQLError(w, r, http.StatusBadRequest, err)
+			return
+		}
+
+		ctx := context.Background()
+		if ep.Context != nil {
+			ctx = ep.Context(ctx, r)
+		}
+		res := graphql.Do(graphql.Params{
+			Schema:         ep.Schema,
+			RequestString:  query,
+			VariableValues: req.Variables,
+			OperationName:  req.OperationName,
+			Context:        ctx,
+		})
+		if err = json.NewEncoder(w).Encode(res); err != nil {
+			LogWithFields(r).Error("unable to JSON encode GraphQL result: ", err)
+		}
+	})
+}
+
+func parseGraphQLRequest(r *http.Request) (*graphQLRequest, error) {
+	var req graphQLRequest
+	if r.Method == "POST" {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			return nil, fmt.Errorf("invalid request body: %s", err)
+		}
+		return &req, nil
+	}
+
+	q := r.URL.Query()
+	req.Query = q.Get("query")
+	req.OperationName = q.Get("operationName")
+	req.ID = q.Get("id")
+	if vars := q.Get("variables"); vars != "" {
+		if err := json.Unmarshal([]byte(vars), &req.Variables); err != nil {
+			return nil, fmt.Errorf("invalid variables: %s", err)
+		}
+	}
+	if ext := q.Get("extensions"); ext != "" {
+		if err := json.Unmarshal([]byte(ext), &req.Extensions); err != nil {
+			return nil, fmt.Errorf("invalid extensions: %s", err)
+		}
+	}
+	return &req, nil
+}
+
+// query will return the query to execute for the request, looking up any
+// persisted query it refers to.
+func (e *GraphQLEndpoint) query(req *graphQLRequest) (string, error) {
+	id := req.ID
+	if pq := req.Extensions.PersistedQuery; pq != nil && id == "" {
+		id = pq.SHA256Hash
+	}
+	if id != "" {
+		if q, ok := e.PersistedQueries[id]; ok {
+			return q, nil
+		}
+		if req.Query == "" || e.PersistedOnly {
+			return "", ErrPersistedQueryNotFound
+		}
+		if pq := req.Extensions.PersistedQuery; pq != nil && id == pq.SHA256Hash {
+			sum := sha256.Sum256([]byte(req.Query))
+			if hex.EncodeToString(sum[:]) != id {
+				return "", errors.New("provided sha256Hash does not match query")
+			}
+		}
+		return req.Query, nil
+	}
+	if e.PersistedOnly {
+		return "", ErrPersistedQueryOnly
+	}
+	if req.Query == "" {
+		return "", errors.New("a query is required")
+	}
+	return req.Query, nil
+}
+
+// checkLimits will parse the query and return an error if it exceeds the
+// MaxComplexity or MaxDepth.
+func (e *GraphQLEndpoint) checkLimits(query string) error {
+	if e.MaxComplexity == 0 && e.MaxDepth == 0 {
+		return nil
+	}
+	doc, err := parser.Parse(parser.ParseParams{Source: query})
+	if err != nil {
+		// let the executor report syntax errors
+		return nil
+	}
+	complexity, depth := QueryComplexity(doc)
+	if e.MaxComplexity > 0 && complexity > e.MaxComplexity {
+		return fmt.Errorf("query complexity of %d exceeds the limit of %d", complexity, e.MaxComplexity)
+	}
+	if e.MaxDepth > 0 && depth > e.MaxDepth {
+		return fmt.Errorf("query depth of %d exceeds the limit of %d", depth, e.MaxDepth)
+	}
+	return nil
+}
+
+// QueryComplexity will return the number of fields selected by the most
+// complex operation in the document and the max depth of any selection.
+// Fields selected through fragments are counted each time the fragment
+// is spread.
+func QueryComplexity(doc *ast.Document) (complexity, depth int) {
+	fragments := map[string]*ast.SelectionSet{}
+	for _, def := range doc.Definitions {
+		if frag, ok := def.(*ast.FragmentDefinition); ok && frag.Name != nil {
+			fragments[frag.Name.Value] = frag.SelectionSet
+		}
+	}
+	for _, def := range doc.Definitions {
+		if op, ok := def.(*ast.OperationDefinition); ok {
+			c, d := selectionComplexity(op.SelectionSet, fragments, map[string]bool{})
+			if c > complexity {
+				complexity = c
+			}
+			if d > depth {
+				depth = d
+			}
+		}
+	}
+	return complexity, depth
+}
+
+func selectionComplexity(set *ast.SelectionSet, fragments map[string]*ast.SelectionSet, spreading map[string]bool) (complexity, depth int) {
+	if set == nil {
+		return 0, 0
+	}
+	for _, sel := range set.Selections {
+		var c, d int
+		switch s := sel.(type) {
+		case *ast.Field:
+			c, d = selectionComplexity(s.SelectionSet, fragments, spreading)
+			c, d = c+1, d+1
+		case *ast.InlineFragment:
+			c, d = selectionComplexity(s.SelectionSet, fragments, spreading)
+		case *ast.FragmentSpread:
+			if s.Name == nil || spreading[s.Name.Value] {
+				// cycles are invalid and will be reported by the executor
+				continue
+			}
+			spreading[s.Name.Value] = true
+			c, d = selectionComplexity(fragments[s.Name.Value], fragments, spreading)
+			delete(spreading, s.Name.Value)
+		}
+		complexity += c
+		if d > depth {
+			depth = d
+		}
+	}
+	return complexity, depth
+}
+
+func writeGraphQLError(w http.ResponseWriter, r *http.Request, code int, err error) {
+	w.WriteHeader(code)
+	res := map[string][]map[string]string{
+		"errors": {{"message": err.Error()}},
+	}
+	if err := json.NewEncoder(w).Encode(res); err != nil {
+		LogWithFields(r).Error("unable to JSON encode GraphQL error: ", err)
+	}
+}
+
+// GraphQLPlaygroundHandler will serve a GraphiQL page for exploring the
+// GraphQL schema at the given URL.
+func GraphQLPlaygroundHandler(endpointURL string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", web.HTMLContentType)
+		err := graphQLPlaygroundTmpl.Execute(w, struct {
+			Assets      string
+			EndpointURL string
+		}{GraphQLPlaygroundAssets, endpointURL})
+		if err != nil {
+			LogWithFields(r).Error("unable to render GraphQL playground: ", err)
+		}
+	})
+}
+
+var graphQLPlaygroundTmpl = template.Must(template.New("graphiql").Parse(`<!DOCTYPE html>
+<html>
+<head>
+  <title>GraphQL Playground</title>
+  <link rel="stylesheet" href="{{.Assets}}/graphiql.css">
+  <style>body { height: 100vh; margin: 0; } #graphiql { height: 100vh; }</style>
+</head>
+<body>
+  <div id="graphiql"></div>
+  <script src="https://unpkg.com/react@15/dist/react.min.js"></script>
+  <script src="https://unpkg.com/react-dom@15/dist/react-dom.min.js"></script>
+  <script src="https://unpkg.com/whatwg-fetch@2/fetch.js"></script>
+  <script src="{{.Assets}}/graphiql.min.js"></script>
+  <script>
+    function fetcher(params) {
+      return fetch({{.EndpointURL}}, {
+        method: "POST",
+        headers: {"Content-Type": "application/json"},
+        body: JSON.stringify(params),
+        credentials: "same-origin"
+      }).then(function(resp) { return resp.json(); });
+    }
+    ReactDOM.render(React.createElement(GraphiQL, {fetcher: fetcher}), document.getElementById("graphiql"));
+  </script>
+</body>
+</html>
+`))
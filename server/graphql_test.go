@@ -0,0 +1,173 @@
+package server
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/language/parser"
+
+	"github.com/NYTimes/gizmo/config"
+)
+
+type testGraphQLService struct {
+	ep *GraphQLEndpoint
+}
+
+func (s *testGraphQLService) Prefix() string {
+	return "/svc/v1"
+}
+
+func (s *testGraphQLService) GraphQL() *GraphQLEndpoint {
+	return s.ep
+}
+
+func (s *testGraphQLService) Middleware(h http.Handler) http.Handler {
+	return h
+}
+
+func testGraphQLSchema(t *testing.T) graphql.Schema {
+	catType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Cat",
+		Fields: graphql.Fields{
+			"name":  &graphql.Field{Type: graphql.String},
+			"lives": &graphql.Field{Type: graphql.Int},
+		},
+	})
+	schema, err := graphql.NewSchema(graphql.SchemaConfig{
+		Query: graphql.NewObject(graphql.ObjectConfig{
+			Name: "Query",
+			Fields: graphql.Fields{
+				"cat": &graphql.Field{
+					Type: catType,
+					Args: graphql.FieldConfigArgument{
+						"name": &graphql.ArgumentConfig{Type: graphql.String},
+					},
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						return map[string]interface{}{"name": p.Args["name"], "lives": 9}, nil
+					},
+				},
+			},
+		}),
+	})
+	if err != nil {
+		t.Fatal("unable to create schema: ", err)
+	}
+	return schema
+}
+
+func TestGraphQLService(t *testing.T) {
+	srvr := NewSimpleServer(&config.Server{EnableGraphQLPlayground: true})
+	err := srvr.Register(&testGraphQLService{&GraphQLEndpoint{
+		Schema:           testGraphQLSchema(t),
+		PersistedQueries: map[string]string{"tom": `{ cat(name: "tom") { name } }`},
+		MaxComplexity:    3,
+	}})
+	if err != nil {
+		t.Fatal("unable to register GraphQL service: ", err)
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		params url.Values
+		body   string
+
+		wantCode int
+		wantBody string
+	}{
+		{
+			"post",
+			"POST",
+			nil,
+			`{"query":"query Cat($name: String) { cat(name: $name) { name lives } }","variables":{"name":"jerry"}}`,
+			http.StatusOK,
+			`{"data":{"cat":{"lives":9,"name":"jerry"}}}`,
+		},
+		{
+			"get",
+			"GET",
+			url.Values{"query": {`{ cat(name: "jerry") { lives } }`}},
+			"",
+			http.StatusOK,
+			`{"data":{"cat":{"lives":9}}}`,
+		},
+		{
+			"persisted",
+			"GET",
+			url.Values{"id": {"tom"}},
+			"",
+			http.StatusOK,
+			`{"data":{"cat":{"name":"tom"}}}`,
+		},
+		{
+			"persisted not found",
+			"POST",
+			nil,
+			`{"extensions":{"persistedQuery":{"sha256Hash":"abc"}}}`,
+			http.StatusBadRequest,
+			`{"errors":[{"message":"PersistedQueryNotFound"}]}`,
+		},
+		{
+			"too complex",
+			"POST",
+			nil,
+			`{"query":"{ cat { name ...more } dog: cat { name } } fragment more on Cat { lives }"}`,
+			http.StatusBadRequest,
+			`{"errors":[{"message":"query complexity of 5 exceeds the limit of 3"}]}`,
+		},
+	}
+
+	for _, test := range tests {
+		r, _ := http.NewRequest(test.method, "/svc/v1/graphql?"+test.params.Encode(), bytes.NewBufferString(test.body))
+		r.RemoteAddr = "0.0.0.0:8080"
+		w := httptest.NewRecorder()
+		srvr.ServeHTTP(w, r)
+
+		if w.Code != test.wantCode {
+			t.Errorf("%s: expected status code %d, got %d", test.name, test.wantCode, w.Code)
+		}
+		if got := strings.TrimSpace(w.Body.String()); got != test.wantBody {
+			t.Errorf("%s: expected body %s, got %s", test.name, test.wantBody, got)
+		}
+	}
+
+	r, _ := http.NewRequest("GET", "/svc/v1/graphql/playground", nil)
+	r.RemoteAddr = "0.0.0.0:8080"
+	w := httptest.NewRecorder()
+	srvr.ServeHTTP(w, r)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "/svc/v1/graphql") {
+		t.Errorf("expected the playground to be served, got %d", w.Code)
+	}
+}
+
+func TestQueryComplexity(t *testing.T) {
+	tests := []struct {
+		query string
+
+		wantComplexity int
+		wantDepth      int
+	}{
+		{`{ cat { name } }`, 2, 2},
+		{`{ cat { name lives } dog: cat { name } }`, 5, 2},
+		{`{ cat { ...parts } } fragment parts on Cat { name ... on Cat { lives } }`, 3, 2},
+		{`query A { cat { name } } query B { a: cat { name } b: cat { name } }`, 4, 2},
+		{`{ cat { ...loop } } fragment loop on Cat { name ...loop }`, 2, 2},
+	}
+
+	for _, test := range tests {
+		doc, err := parser.Parse(parser.ParseParams{Source: test.query})
+		if err != nil {
+			t.Fatalf("unable to parse %q: %s", test.query, err)
+		}
+		complexity, depth := QueryComplexity(doc)
+		if complexity != test.wantComplexity || depth != test.wantDepth {
+			t.Errorf("%q: expected complexity %d and depth %d, got %d and %d",
+				test.query, test.wantComplexity, test.wantDepth, complexity, depth)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package server
+
+import (
+	"net/http"
+	"strings"
+)
+
+// Group is a set of services that share a path prefix and middleware.
+// Groups are created with SimpleServer.Group.
+type Group struct {
+	server *SimpleServer
+	prefix string
+	mws    []func(http.Handler) http.Handler
+}
+
+// Group will return a new Group that will register services under the given
+// prefix with the given middleware wrapped around each of their endpoints.
+// The middleware will be called in the order given, before any
+// middleware provided by the services themselves.
+//
+//	admin := svr.Group("/admin", authMW, auditMW)
+//	err := admin.Register(&AdminService{})
+func (s *SimpleServer) Group(prefix string, mws ...func(http.Handler) http.Handler) *Group {
+	return &Group{
+		server: s,
+		prefix: strings.TrimRight(prefix, "/"),
+		mws:    mws,
+	}
+}
+
+// Group will return a new Group nested within the current one. The
+// prefix will be appended to the current group's prefix and the middleware
+// will be called after the current group's middleware.
+func (g *Group) Group(prefix string, mws ...func(http.Handler) http.Handler) *Group {
+	all := make([]func(http.Handler) http.Handler, 0, len(g.mws)+len(mws))
+	all = append(all, g.mws...)
+	return &Group{
+		server: g.server,
+		prefix: g.prefix + strings.TrimRight(prefix, "/"),
+		mws:    append(all, mws...),
+	}
+}
+
+// Register will register the service with the group's server under the
+// group's prefix and middleware.
+func (g *Group) Register(svc Service) error {
+	return g.server.registerGroup(svc, g.prefix, g.mws)
+}
+
+// chain will combine the given middleware and optional final middleware
+// into a single func. The first middleware given will be the outermost.
+func chain(mws []func(http.Handler) http.Handler, last func(http.Handler) http.Handler) func(http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		if last != nil {
+			h = last(h)
+		}
+		for i := len(mws) - 1; i >= 0; i-- {
+			h = mws[i](h)
+		}
+		return h
+	}
+}
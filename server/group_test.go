@@ -0,0 +1,71 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func testHeaderMiddleware(val string) func(http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add("X-Group", val)
+			h.ServeHTTP(w, r)
+		})
+	}
+}
+
+func TestGroup(t *testing.T) {
+	srvr := NewSimpleServer(nil)
+	admin := srvr.Group("/admin/", testHeaderMiddleware("admin"))
+	if err := admin.Register(&testMixedService{}); err != nil {
+		t.Fatal("unable to register group service: ", err)
+	}
+	nested := admin.Group("/nested", testHeaderMiddleware("nested"))
+	if err := nested.Register(&testMixedService{}); err != nil {
+		t.Fatal("unable to register nested group service: ", err)
+	}
+	if err := srvr.Register(&benchmarkSimpleService{}); err != nil {
+		t.Fatal("unable to register service: ", err)
+	}
+
+	tests := []struct {
+		path string
+
+		wantCode   int
+		wantGroups []string
+	}{
+		{"/admin/svc/v1/json", http.StatusOK, []string{"admin"}},
+		{"/admin/nested/svc/v1/json", http.StatusOK, []string{"admin", "nested"}},
+		{"/svc/v1/json", http.StatusNotFound, nil},
+		{"/svc/v1/2", http.StatusOK, nil},
+	}
+
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest("GET", test.path, nil)
+		srvr.ServeHTTP(w, r)
+
+		if w.Code != test.wantCode {
+			t.Errorf("%s expected status code of %d, got %d", test.path, test.wantCode, w.Code)
+		}
+		got := w.Header()["X-Group"]
+		if len(got) != len(test.wantGroups) {
+			t.Errorf("%s expected group headers of %v, got %v", test.path, test.wantGroups, got)
+			continue
+		}
+		for i := range got {
+			if got[i] != test.wantGroups[i] {
+				t.Errorf("%s expected group headers of %v, got %v", test.path, test.wantGroups, got)
+			}
+		}
+	}
+
+	var paths []string
+	for _, route := range srvr.Routes() {
+		paths = append(paths, route.Path)
+	}
+	if len(paths) < 3 || paths[0] != "/admin/svc/v1/json" || paths[2] != "/admin/nested/svc/v1/json" {
+		t.Errorf("expected group prefixes in routes, got %v", paths)
+	}
+}
@@ -49,3 +49,36 @@ func (s *SimpleHealthCheck) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		LogWithFields(r).Warn("unable to write healthcheck response: ", err)
 	}
 }
+
+// CustomHealthCheck is a HealthCheckHandler that uses
+// a custom http.Handler to serve health checks.
+type CustomHealthCheck struct {
+	path    string
+	handler http.Handler
+}
+
+// NewCustomHealthCheck will return a new CustomHealthCheck that serves
+// the given handler at the given path.
+func NewCustomHealthCheck(path string, handler http.Handler) *CustomHealthCheck {
+	return &CustomHealthCheck{path: path, handler: handler}
+}
+
+// Path will return the configured status path to server on.
+func (c *CustomHealthCheck) Path() string {
+	return c.path
+}
+
+// Start will do nothing.
+func (c *CustomHealthCheck) Start(monitor *ActivityMonitor) error {
+	return nil
+}
+
+// Stop will do nothing and return nil.
+func (c *CustomHealthCheck) Stop() error {
+	return nil
+}
+
+// ServeHTTP will serve the health check with the custom handler.
+func (c *CustomHealthCheck) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	c.handler.ServeHTTP(w, r)
+}
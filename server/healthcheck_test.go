@@ -5,6 +5,8 @@ import (
 	"net/http/httptest"
 	"strings"
 	"testing"
+
+	"github.com/NYTimes/gizmo/config"
 )
 
 func TestSimpleHealthCheck(t *testing.T) {
@@ -41,3 +43,25 @@ func TestSimpleHealthCheck(t *testing.T) {
 	}
 
 }
+
+func TestCustomHealthCheck(t *testing.T) {
+	cfg := &config.Server{
+		HealthCheckType: "custom",
+		HealthCheckPath: "/ready",
+		CustomHealthCheckHandler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}),
+	}
+	hch := NewHealthCheckHandler(cfg)
+	if hch.Path() != "/ready" {
+		t.Errorf("CustomHealthCheck expected path '/ready', got %s", hch.Path())
+	}
+
+	req, _ := http.NewRequest("GET", "/ready", nil)
+	wr := httptest.NewRecorder()
+	hch.ServeHTTP(wr, req)
+
+	if wr.Code != http.StatusServiceUnavailable {
+		t.Errorf("CustomHealthCheck expected 503 response code, got %d", wr.Code)
+	}
+}
@@ -0,0 +1,135 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// LameDuckHealthCheck wraps a HealthCheckHandler so it will fail health checks
+// while the server warms up and while it is in lame duck mode. In lame duck
+// mode the server will keep serving requests, giving load balancers time to
+// stop sending it traffic before it shuts down.
+type LameDuckHealthCheck struct {
+	HealthCheckHandler
+
+	warmup   time.Duration
+	duration time.Duration
+
+	mu       sync.RWMutex
+	started  time.Time
+	lameDuck bool
+	since    time.Time
+}
+
+// NewLameDuckHealthCheck will wrap the given HealthCheckHandler so it fails
+// until the warmup has passed after Start. On Stop, it will enter lame duck
+// mode and wait for the given duration before stopping the wrapped handler.
+func NewLameDuckHealthCheck(hch HealthCheckHandler, warmup, duration time.Duration) *LameDuckHealthCheck {
+	return &LameDuckHealthCheck{
+		HealthCheckHandler: hch,
+		warmup:             warmup,
+		duration:           duration,
+	}
+}
+
+// Start will begin the warmup and start the wrapped handler.
+func (l *LameDuckHealthCheck) Start(monitor *ActivityMonitor) error {
+	l.mu.Lock()
+	l.started = time.Now()
+	l.mu.Unlock()
+	if l.warmup > 0 {
+		Log.Infof("health check will report ready after a %s warmup", l.warmup)
+	}
+	return l.HealthCheckHandler.Start(monitor)
+}
+
+// Stop will enter lame duck mode and block until the lame duck duration has
+// passed before stopping the wrapped handler. If lame duck mode was entered
+// earlier, Stop will only wait for whatever is left of the duration.
+func (l *LameDuckHealthCheck) Stop() error {
+	l.SetLameDuck(true)
+	l.mu.RLock()
+	left := l.duration - time.Since(l.since)
+	l.mu.RUnlock()
+	if left > 0 {
+		Log.Infof("lame duck: serving for another %s before shutting down", left)
+		time.Sleep(left)
+	}
+	return l.HealthCheckHandler.Stop()
+}
+
+// SetLameDuck will enter or leave lame duck mode.
+func (l *LameDuckHealthCheck) SetLameDuck(on bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if on == l.lameDuck {
+		return
+	}
+	l.lameDuck = on
+	if on {
+		l.since = time.Now()
+		Log.Warn("entering lame duck mode, health checks will fail")
+	} else {
+		Log.Info("leaving lame duck mode")
+	}
+}
+
+// Ready will return true if the server has warmed up and is not in lame duck
+// mode. If false, the reason will be returned.
+func (l *LameDuckHealthCheck) Ready() (bool, string) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	if l.lameDuck {
+		return false, "lame duck"
+	}
+	if l.started.IsZero() || time.Since(l.started) < l.warmup {
+		return false, "warming up"
+	}
+	return true, ""
+}
+
+// ServeHTTP will respond with a 503 while the server is not ready and pass
+// the request to the wrapped handler otherwise.
+func (l *LameDuckHealthCheck) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if ready, reason := l.Ready(); !ready {
+		http.Error(w, "service unavailable: "+reason, http.StatusServiceUnavailable)
+		return
+	}
+	l.HealthCheckHandler.ServeHTTP(w, r)
+}
+
+// LameDuckStatus is the response of the LameDuckHealthCheck's admin handler.
+type LameDuckStatus struct {
+	Ready    bool   `json:"ready"`
+	LameDuck bool   `json:"lameDuck"`
+	Reason   string `json:"reason,omitempty"`
+}
+
+// AdminHandler will return an http.Handler for manually controlling lame
+// duck mode. A PUT or POST will enter lame duck mode, a DELETE will leave it
+// and all methods will respond with the current LameDuckStatus.
+func (l *LameDuckHealthCheck) AdminHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "GET":
+		case "PUT", "POST":
+			l.SetLameDuck(true)
+		case "DELETE":
+			l.SetLameDuck(false)
+		default:
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		ready, reason := l.Ready()
+		l.mu.RLock()
+		status := LameDuckStatus{Ready: ready, LameDuck: l.lameDuck, Reason: reason}
+		l.mu.RUnlock()
+		w.Header().Set("Content-Type", jsonContentType)
+		if err := json.NewEncoder(w).Encode(status); err != nil {
+			LogWithFields(r).Warn("unable to write lame duck status: ", err)
+		}
+	})
+}
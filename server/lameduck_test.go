@@ -0,0 +1,93 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/NYTimes/gizmo/config"
+)
+
+func TestLameDuckHealthCheck(t *testing.T) {
+	l := NewLameDuckHealthCheck(NewSimpleHealthCheck("/status.txt"), 50*time.Millisecond, 50*time.Millisecond)
+	check := func(want int) {
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest("GET", "/status.txt", nil)
+		l.ServeHTTP(w, r)
+		if w.Code != want {
+			t.Errorf("expected status code %d, got %d: %s", want, w.Code, w.Body.String())
+		}
+	}
+
+	// not ready before start or during warmup
+	check(http.StatusServiceUnavailable)
+	l.Start(NewActivityMonitor())
+	check(http.StatusServiceUnavailable)
+
+	time.Sleep(60 * time.Millisecond)
+	check(http.StatusOK)
+
+	l.SetLameDuck(true)
+	check(http.StatusServiceUnavailable)
+	l.SetLameDuck(false)
+	check(http.StatusOK)
+
+	start := time.Now()
+	if err := l.Stop(); err != nil {
+		t.Errorf("expected no error from Stop, got %s", err)
+	}
+	if took := time.Since(start); took < 50*time.Millisecond {
+		t.Errorf("expected Stop to wait out the lame duck duration, took %s", took)
+	}
+	check(http.StatusServiceUnavailable)
+}
+
+func TestLameDuckAdminHandler(t *testing.T) {
+	cfg := &config.Server{LameDuckPath: "/admin/lameduck"}
+	mx := NewRouter(cfg)
+	hch := RegisterHealthHandler(cfg, NewActivityMonitor(), mx)
+	if _, ok := hch.(*LameDuckHealthCheck); !ok {
+		t.Fatalf("expected a LameDuckHealthCheck, got %T", hch)
+	}
+
+	tests := []struct {
+		method string
+
+		wantCode     int
+		wantLameDuck bool
+		wantHealth   int
+	}{
+		{"GET", http.StatusOK, false, http.StatusOK},
+		{"PUT", http.StatusOK, true, http.StatusServiceUnavailable},
+		{"GET", http.StatusOK, true, http.StatusServiceUnavailable},
+		{"DELETE", http.StatusOK, false, http.StatusOK},
+		{"POST", http.StatusOK, true, http.StatusServiceUnavailable},
+	}
+
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest(test.method, "/admin/lameduck", nil)
+		mx.ServeHTTP(w, r)
+		if w.Code != test.wantCode {
+			t.Errorf("expected %s to respond with %d, got %d", test.method, test.wantCode, w.Code)
+			continue
+		}
+		var status LameDuckStatus
+		if err := json.NewDecoder(w.Body).Decode(&status); err != nil {
+			t.Errorf("unable to decode status: %s", err)
+			continue
+		}
+		if status.LameDuck != test.wantLameDuck {
+			t.Errorf("expected lame duck to be %t after %s, got %t", test.wantLameDuck, test.method, status.LameDuck)
+		}
+
+		w = httptest.NewRecorder()
+		r, _ = http.NewRequest("GET", hch.Path(), nil)
+		mx.ServeHTTP(w, r)
+		if w.Code != test.wantHealth {
+			t.Errorf("expected health check code %d after %s, got %d", test.wantHealth, test.method, w.Code)
+		}
+	}
+}
@@ -0,0 +1,194 @@
+package server
+
+import (
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+// OverloadedError is returned with a 503 status code when a
+// ConcurrencyLimiter is unable to admit a request.
+var OverloadedError = []byte("server overloaded, please retry")
+
+// ConcurrencyLimiter is an http.Handler that limits the number of requests
+// being handled at once. Requests over the limit will wait in a bounded
+// queue and any requests that overflow the queue or wait too long will be
+// rejected with a 503.
+type ConcurrencyLimiter struct {
+	// tokens for requests either being handled or waiting
+	admitted chan struct{}
+	// tokens for requests being handled
+	running chan struct{}
+	wait    time.Duration
+
+	queued   metrics.Gauge
+	rejected metrics.Counter
+	handler  http.Handler
+}
+
+// ConcurrencyLimited returns an http.Handler that will pass at most max concurrent
+// requests to the underlying http.Handler. Up to queue requests will wait for
+// a turn for as long as the given wait duration, or indefinitely if wait is 0.
+// The number of queued and rejected requests will be tracked via go-metrics.
+func ConcurrencyLimited(handler http.Handler, max, queue int, wait time.Duration, name string, registry metrics.Registry) *ConcurrencyLimiter {
+	if nil == registry {
+		registry = metrics.DefaultRegistry
+	}
+	if max < 1 {
+		max = 1
+	}
+	if queue < 0 {
+		queue = 0
+	}
+	c := &ConcurrencyLimiter{
+		admitted: make(chan struct{}, max+queue),
+		running:  make(chan struct{}, max),
+		wait:     wait,
+		queued:   metrics.NewGauge(),
+		rejected: metrics.NewCounter(),
+		handler:  handler,
+	}
+	if err := registry.Register(name+".QUEUED", c.queued); nil != err {
+		panic(err)
+	}
+	if err := registry.Register(name+".REJECTED", c.rejected); nil != err {
+		panic(err)
+	}
+	return c
+}
+
+// ServeHTTP will pass the request to the underlying http.Handler once there
+// is room or respond with a 503 if the request cannot be admitted.
+func (c *ConcurrencyLimiter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	select {
+	case c.admitted <- struct{}{}:
+	default:
+		c.reject(w, r, "queue is full")
+		return
+	}
+	defer func() { <-c.admitted }()
+
+	select {
+	case c.running <- struct{}{}:
+	default:
+		if !c.enqueue(w, r) {
+			return
+		}
+	}
+	defer func() { <-c.running }()
+
+	c.handler.ServeHTTP(w, r)
+}
+
+// enqueue will wait for a turn to run the request and
+// return false if it was rejected instead.
+func (c *ConcurrencyLimiter) enqueue(w http.ResponseWriter, r *http.Request) bool {
+	c.queued.Update(int64(len(c.admitted) - len(c.running)))
+	defer func() {
+		c.queued.Update(int64(len(c.admitted) - len(c.running)))
+	}()
+
+	var timeout <-chan time.Time
+	if c.wait > 0 {
+		timer := time.NewTimer(c.wait)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	select {
+	case c.running <- struct{}{}:
+		return true
+	case <-timeout:
+		c.reject(w, r, "timed out waiting in queue")
+		return false
+	}
+}
+
+func (c *ConcurrencyLimiter) reject(w http.ResponseWriter, r *http.Request, reason string) {
+	c.rejected.Inc(1)
+	LogWithFields(r).Warn("rejecting request: ", reason)
+	w.Header().Set("Retry-After", "1")
+	w.WriteHeader(http.StatusServiceUnavailable)
+	if _, err := w.Write(OverloadedError); err != nil {
+		LogWithFields(r).Warn("unable to write response: ", err)
+	}
+}
+
+// ClientConnLimitListener is a net.Listener that caps the number of open
+// connections from each client IP. Connections over the cap are closed as
+// soon as they are accepted.
+type ClientConnLimitListener struct {
+	net.Listener
+	max int
+
+	mu    sync.Mutex
+	conns map[string]int
+
+	rejected metrics.Counter
+}
+
+// ClientConnLimited returns a net.Listener that will allow at most max open
+// connections from any single client IP. The number of rejected connections
+// will be tracked via go-metrics.
+func ClientConnLimited(l net.Listener, max int, name string, registry metrics.Registry) *ClientConnLimitListener {
+	if nil == registry {
+		registry = metrics.DefaultRegistry
+	}
+	c := &ClientConnLimitListener{
+		Listener: l,
+		max:      max,
+		conns:    map[string]int{},
+		rejected: metrics.GetOrRegisterCounter(name+".REJECTED", registry),
+	}
+	return c
+}
+
+// Accept will return the next connection from a client under the cap.
+func (l *ClientConnLimitListener) Accept() (net.Conn, error) {
+	for {
+		c, err := l.Listener.Accept()
+		if err != nil {
+			return nil, err
+		}
+		ip, _, err := net.SplitHostPort(c.RemoteAddr().String())
+		if err != nil {
+			return c, nil
+		}
+
+		l.mu.Lock()
+		if l.conns[ip] >= l.max {
+			l.mu.Unlock()
+			l.rejected.Inc(1)
+			Log.Warnf("rejecting connection from %s: over the limit of %d", ip, l.max)
+			c.Close()
+			continue
+		}
+		l.conns[ip]++
+		l.mu.Unlock()
+
+		return &clientConn{Conn: c, release: func() { l.release(ip) }}, nil
+	}
+}
+
+func (l *ClientConnLimitListener) release(ip string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.conns[ip]--; l.conns[ip] <= 0 {
+		delete(l.conns, ip)
+	}
+}
+
+// clientConn will release its spot with the listener once closed.
+type clientConn struct {
+	net.Conn
+	once    sync.Once
+	release func()
+}
+
+func (c *clientConn) Close() error {
+	err := c.Conn.Close()
+	c.once.Do(c.release)
+	return err
+}
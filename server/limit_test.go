@@ -0,0 +1,132 @@
+package server
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+func TestConcurrencyLimiter(t *testing.T) {
+	tests := []struct {
+		max   int
+		queue int
+		wait  time.Duration
+
+		wantOK       int
+		wantRejected int
+	}{
+		{2, 0, 0, 2, 2},
+		{2, 2, 0, 4, 0},
+		{1, 1, 0, 2, 2},
+		{1, 3, 10 * time.Millisecond, 1, 3},
+	}
+
+	for _, test := range tests {
+		registry := metrics.NewRegistry()
+		release := make(chan struct{})
+		started := make(chan struct{}, 4)
+		l := ConcurrencyLimited(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			started <- struct{}{}
+			<-release
+		}), test.max, test.queue, test.wait, "requests", registry)
+
+		var (
+			wg    sync.WaitGroup
+			mu    sync.Mutex
+			codes = map[int]int{}
+		)
+		for i := 0; i < 4; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				w := httptest.NewRecorder()
+				r, _ := http.NewRequest("GET", "/", nil)
+				l.ServeHTTP(w, r)
+				mu.Lock()
+				codes[w.Code]++
+				mu.Unlock()
+			}()
+		}
+
+		// wait for the limit to fill up and any waits to expire
+		for i := 0; i < test.max; i++ {
+			<-started
+		}
+		time.Sleep(50 * time.Millisecond)
+		close(release)
+		wg.Wait()
+
+		if codes[http.StatusOK] != test.wantOK {
+			t.Errorf("expected %d OK responses, got %d", test.wantOK, codes[http.StatusOK])
+		}
+		if codes[http.StatusServiceUnavailable] != test.wantRejected {
+			t.Errorf("expected %d rejected responses, got %d",
+				test.wantRejected, codes[http.StatusServiceUnavailable])
+		}
+		rejected := registry.Get("requests.REJECTED").(metrics.Counter).Count()
+		if rejected != int64(test.wantRejected) {
+			t.Errorf("expected rejected count of %d, got %d", test.wantRejected, rejected)
+		}
+	}
+}
+
+func TestClientConnLimitListener(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal("unable to listen: ", err)
+	}
+	registry := metrics.NewRegistry()
+	l := ClientConnLimited(ln, 1, "connections", registry)
+	defer l.Close()
+
+	accepted := make(chan net.Conn, 2)
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			accepted <- c
+		}
+	}()
+
+	first, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal("unable to dial: ", err)
+	}
+	defer first.Close()
+	c := <-accepted
+
+	// the second connection should be closed by the listener
+	second, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal("unable to dial: ", err)
+	}
+	second.SetReadDeadline(time.Now().Add(time.Second))
+	if _, err = second.Read(make([]byte, 1)); err == nil {
+		t.Error("expected the second connection to be closed, got none")
+	}
+	second.Close()
+	if got := registry.Get("connections.REJECTED").(metrics.Counter).Count(); got != 1 {
+		t.Errorf("expected 1 rejected connection, got %d", got)
+	}
+
+	// closing the first should let another client in
+	c.Close()
+	third, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal("unable to dial: ", err)
+	}
+	defer third.Close()
+	select {
+	case c = <-accepted:
+		c.Close()
+	case <-time.After(time.Second):
+		t.Error("expected the third connection to be accepted, got none")
+	}
+}
@@ -0,0 +1,307 @@
+package server
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+
+	"github.com/NYTimes/gizmo/config"
+)
+
+const (
+	// ListenFDsEnv is the environment variable used to pass the number of
+	// inherited listeners to a process. It follows the systemd socket activation
+	// protocol so servers can be started by systemd or by a Restart.
+	ListenFDsEnv = "LISTEN_FDS"
+	// ListenPIDEnv is the environment variable systemd uses to identify the process
+	// the inherited listeners were meant for.
+	ListenPIDEnv = "LISTEN_PID"
+
+	// listenFDsStart is the first file descriptor of any inherited listeners.
+	listenFDsStart = 3
+)
+
+// RestartDrainTimeout is the max amount of time Run will wait for in-flight
+// requests to complete after handing its listeners off to a new process.
+var RestartDrainTimeout = 30 * time.Second
+
+var (
+	listenMu sync.Mutex
+	// listeners passed down from a parent process or systemd
+	inherited     []net.Listener
+	inheritedOnce sync.Once
+	// listeners opened via Listen that will be passed on a Restart
+	active []net.Listener
+)
+
+// Listen will return a listener for the given network and address. If a
+// matching listener was inherited from a parent process or via systemd
+// socket activation, it will be used instead of binding a new socket. All
+// listeners returned will be passed on to the new process during a Restart.
+func Listen(network, addr string) (net.Listener, error) {
+	inheritedOnce.Do(inheritListeners)
+
+	listenMu.Lock()
+	defer listenMu.Unlock()
+	for i, l := range inherited {
+		if !addrMatches(l.Addr(), network, addr) {
+			continue
+		}
+		inherited = append(inherited[:i], inherited[i+1:]...)
+		active = append(active, l)
+		Log.Infof("using inherited listener for %s", l.Addr())
+		return l, nil
+	}
+
+	if strings.HasPrefix(network, "unix") {
+		removeStaleSocket(addr)
+	}
+	l, err := net.Listen(network, addr)
+	if err != nil {
+		return nil, err
+	}
+	active = append(active, l)
+	return l, nil
+}
+
+// inheritListeners will pick up any listeners described by the LISTEN_FDS env.
+func inheritListeners() {
+	count, err := strconv.Atoi(os.Getenv(ListenFDsEnv))
+	if err != nil || count < 1 {
+		return
+	}
+	// systemd sets the pid of the intended process, a Restart does not
+	if pid := os.Getenv(ListenPIDEnv); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return
+	}
+	os.Unsetenv(ListenFDsEnv)
+	os.Unsetenv(ListenPIDEnv)
+
+	files := make([]*os.File, count)
+	for i := range files {
+		fd := listenFDsStart + i
+		files[i] = os.NewFile(uintptr(fd), "listener-"+strconv.Itoa(fd))
+	}
+	adoptFiles(files)
+}
+
+// adoptFiles will convert the given files into listeners available to Listen.
+func adoptFiles(files []*os.File) {
+	listenMu.Lock()
+	defer listenMu.Unlock()
+	for _, f := range files {
+		l, err := net.FileListener(f)
+		// FileListener dups the descriptor so we're done with the file either way
+		f.Close()
+		if err != nil {
+			Log.Warnf("unable to use inherited file %s as a listener: %s", f.Name(), err)
+			continue
+		}
+		inherited = append(inherited, l)
+	}
+}
+
+// addrMatches will check if a listener's address satisfies the requested
+// network and address. An empty host will match any host.
+func addrMatches(la net.Addr, network, addr string) bool {
+	switch a := la.(type) {
+	case *net.TCPAddr:
+		if !strings.HasPrefix(network, "tcp") {
+			return false
+		}
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil || port != strconv.Itoa(a.Port) {
+			return false
+		}
+		if host == "" {
+			return true
+		}
+		ip := net.ParseIP(host)
+		return ip != nil && ip.Equal(a.IP)
+	case *net.UnixAddr:
+		return network == a.Net && addr == a.Name
+	}
+	return false
+}
+
+// removeStaleSocket will remove a socket file left behind by a
+// previous process so it can be bound again.
+func removeStaleSocket(path string) {
+	info, err := os.Stat(path)
+	if err != nil || info.Mode()&os.ModeSocket == 0 {
+		return
+	}
+	if err = os.Remove(path); err != nil {
+		Log.Warnf("unable to remove stale socket %s: %s", path, err)
+	}
+}
+
+// httpListeners will bind all of the HTTP listeners in the config. The HTTPPort
+// is always bound and will serve TLS if a cert and key are configured and no
+// HTTPSPort is given. Otherwise, TLS will be served on the HTTPSPort alongside
+// plain HTTP. If an HTTPSocket is configured, plain HTTP will also be served
+// over the unix socket. TCP listeners will be capped to MaxConnsPerClient.
+func httpListeners(cfg *config.Server, registry metrics.Registry) (listeners []net.Listener, err error) {
+	defer func() {
+		if err != nil {
+			for _, l := range listeners {
+				l.Close()
+			}
+			listeners = nil
+		}
+	}()
+
+	var tlsCfg *tls.Config
+	if cfg.TLSCertFile != nil && cfg.TLSKeyFile != nil {
+		cert, err := tls.LoadX509KeyPair(*cfg.TLSCertFile, *cfg.TLSKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsCfg = &tls.Config{
+			Certificates: []tls.Certificate{cert},
+			NextProtos:   []string{"http/1.1"},
+		}
+	}
+	if cfg.HTTPSPort != 0 && tlsCfg == nil {
+		return nil, errors.New("an HTTPS port requires a TLS cert and key")
+	}
+
+	listenTCP := func(port int) (net.Listener, error) {
+		l, err := Listen("tcp", fmt.Sprintf(":%d", port))
+		if err != nil {
+			return nil, err
+		}
+		if tl, ok := l.(*net.TCPListener); ok {
+			l = net.Listener(TCPKeepAliveListener{tl})
+		}
+		if cfg.MaxConnsPerClient > 0 {
+			l = ClientConnLimited(l, cfg.MaxConnsPerClient, "connections", registry)
+		}
+		return l, nil
+	}
+
+	l, err := listenTCP(cfg.HTTPPort)
+	if err != nil {
+		return listeners, err
+	}
+	if tlsCfg != nil && cfg.HTTPSPort == 0 {
+		l = tls.NewListener(l, tlsCfg)
+	}
+	listeners = append(listeners, l)
+
+	if cfg.HTTPSPort != 0 {
+		if l, err = listenTCP(cfg.HTTPSPort); err != nil {
+			return listeners, err
+		}
+		listeners = append(listeners, tls.NewListener(l, tlsCfg))
+	}
+
+	if cfg.HTTPSocket != "" {
+		if l, err = Listen("unix", cfg.HTTPSocket); err != nil {
+			return listeners, err
+		}
+		listeners = append(listeners, l)
+	}
+	return listeners, nil
+}
+
+// Restart will start a new copy of the current process with the same
+// arguments and pass it all of the listeners opened via Listen. The new
+// process will begin accepting connections as soon as it starts so the
+// caller can stop its own server without dropping any connections. The
+// pid of the new process will be returned.
+func Restart() (int, error) {
+	bin, err := exec.LookPath(os.Args[0])
+	if err != nil {
+		return 0, err
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		return 0, err
+	}
+
+	files := listenerFiles()
+	defer func() {
+		for _, f := range files {
+			f.Close()
+		}
+	}()
+	if len(files) == 0 {
+		return 0, errors.New("no listeners to pass to a new process")
+	}
+
+	p, err := os.StartProcess(bin, os.Args, &os.ProcAttr{
+		Dir:   wd,
+		Env:   restartEnv(os.Environ(), len(files)),
+		Files: append([]*os.File{os.Stdin, os.Stdout, os.Stderr}, files...),
+	})
+	if err != nil {
+		return 0, err
+	}
+	return p.Pid, nil
+}
+
+// listenerFiles will return a dup'd file for each open listener.
+func listenerFiles() []*os.File {
+	listenMu.Lock()
+	defer listenMu.Unlock()
+	var files []*os.File
+	for _, l := range active {
+		fl, ok := l.(interface {
+			File() (*os.File, error)
+		})
+		if !ok {
+			continue
+		}
+		// the new process will own any socket files from here on
+		if ul, ok := l.(interface {
+			SetUnlinkOnClose(bool)
+		}); ok {
+			ul.SetUnlinkOnClose(false)
+		}
+		f, err := fl.File()
+		if err != nil {
+			// closed listeners have no file and aren't worth passing on
+			Log.Debugf("skipping listener %s: %s", l.Addr(), err)
+			continue
+		}
+		files = append(files, f)
+	}
+	return files
+}
+
+// restartEnv will replace any socket activation variables in the
+// environment with ones describing the given number of listeners.
+func restartEnv(environ []string, count int) []string {
+	env := make([]string, 0, len(environ)+1)
+	for _, kv := range environ {
+		if strings.HasPrefix(kv, ListenFDsEnv+"=") ||
+			strings.HasPrefix(kv, ListenPIDEnv+"=") ||
+			strings.HasPrefix(kv, "LISTEN_FDNAMES=") {
+			continue
+		}
+		env = append(env, kv)
+	}
+	return append(env, fmt.Sprintf("%s=%d", ListenFDsEnv, count))
+}
+
+// waitForIdle will block until the monitor has no active
+// requests or the timeout passes.
+func waitForIdle(monitor *ActivityMonitor, timeout time.Duration) {
+	deadline := time.Now().Add(timeout)
+	for monitor.Active() && time.Now().Before(deadline) {
+		time.Sleep(50 * time.Millisecond)
+	}
+	if n := monitor.NumActiveRequests(); n > 0 {
+		Log.Warnf("gave up waiting on %d in-flight requests", n)
+	}
+}
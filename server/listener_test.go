@@ -0,0 +1,158 @@
+package server
+
+import (
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/rcrowley/go-metrics"
+
+	"github.com/NYTimes/gizmo/config"
+)
+
+func TestListenInherited(t *testing.T) {
+	orig, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal("unable to listen: ", err)
+	}
+	f, err := orig.(*net.TCPListener).File()
+	if err != nil {
+		t.Fatal("unable to get listener file: ", err)
+	}
+	addr := orig.Addr().String()
+	inheritedOnce.Do(func() {})
+	adoptFiles([]*os.File{f})
+
+	// the original listener is still bound so this will only
+	// succeed if the inherited listener is used
+	l, err := Listen("tcp", addr)
+	if err != nil {
+		t.Fatal("expected the inherited listener, got error: ", err)
+	}
+	defer l.Close()
+	if got := l.Addr().String(); got != addr {
+		t.Errorf("expected listener on %s, got %s", addr, got)
+	}
+	orig.Close()
+
+	// the listener should only be handed out once
+	if l2, err := Listen("tcp", addr); err == nil {
+		l2.Close()
+		t.Error("expected an error listening on a bound address, got none")
+	}
+
+	files := listenerFiles()
+	if len(files) == 0 {
+		t.Error("expected the listener to be available for a restart, got none")
+	}
+	for _, f := range files {
+		f.Close()
+	}
+}
+
+func TestAddrMatches(t *testing.T) {
+	tests := []struct {
+		addr    net.Addr
+		network string
+		given   string
+
+		want bool
+	}{
+		{&net.TCPAddr{IP: net.IPv6zero, Port: 8080}, "tcp", ":8080", true},
+		{&net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 8080}, "tcp", "127.0.0.1:8080", true},
+		{&net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 8080}, "tcp", "10.0.0.1:8080", false},
+		{&net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 8080}, "tcp4", ":8080", true},
+		{&net.TCPAddr{IP: net.IPv6zero, Port: 8080}, "tcp", ":8081", false},
+		{&net.TCPAddr{IP: net.IPv6zero, Port: 8080}, "unix", ":8080", false},
+		{&net.UnixAddr{Name: "/tmp/app.sock", Net: "unix"}, "unix", "/tmp/app.sock", true},
+		{&net.UnixAddr{Name: "/tmp/app.sock", Net: "unix"}, "unix", "/tmp/other.sock", false},
+		{&net.UnixAddr{Name: "/tmp/app.sock", Net: "unix"}, "tcp", ":8080", false},
+	}
+
+	for _, test := range tests {
+		if got := addrMatches(test.addr, test.network, test.given); got != test.want {
+			t.Errorf("expected %t for %s %s against %s, got %t",
+				test.want, test.network, test.given, test.addr, got)
+		}
+	}
+}
+
+func TestRestartEnv(t *testing.T) {
+	got := restartEnv([]string{
+		"HTTP_PORT=8080",
+		"LISTEN_FDS=4",
+		"LISTEN_PID=123",
+		"LISTEN_FDNAMES=http",
+		"APP_LOG=/tmp/app.log",
+	}, 2)
+	want := []string{
+		"HTTP_PORT=8080",
+		"APP_LOG=/tmp/app.log",
+		"LISTEN_FDS=2",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected env %#v, got %#v", want, got)
+	}
+}
+
+func TestHTTPListeners(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gizmo-listeners")
+	if err != nil {
+		t.Fatal("unable to create temp dir: ", err)
+	}
+	defer os.RemoveAll(dir)
+	sock := filepath.Join(dir, "http.sock")
+
+	// leave a stale socket behind to make sure it gets replaced
+	stale, err := net.Listen("unix", sock)
+	if err != nil {
+		t.Fatal("unable to listen on socket: ", err)
+	}
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	stale.Close()
+
+	listeners, err := httpListeners(&config.Server{HTTPSocket: sock}, metrics.NewRegistry())
+	if err != nil {
+		t.Fatal("unable to create listeners: ", err)
+	}
+	if len(listeners) != 2 {
+		t.Fatalf("expected 2 listeners, got %d", len(listeners))
+	}
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "ok")
+	})}
+	for _, l := range listeners {
+		defer l.Close()
+		go srv.Serve(l)
+	}
+
+	clients := map[string]*http.Client{
+		"http://" + listeners[0].Addr().String() + "/": http.DefaultClient,
+		"http://unix/": {Transport: &http.Transport{
+			Dial: func(_, _ string) (net.Conn, error) {
+				return net.Dial("unix", sock)
+			},
+		}},
+	}
+	for url, client := range clients {
+		resp, err := client.Get(url)
+		if err != nil {
+			t.Errorf("unable to request %s: %s", url, err)
+			continue
+		}
+		body, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if string(body) != "ok" {
+			t.Errorf("expected 'ok' from %s, got '%s'", url, body)
+		}
+	}
+
+	if _, err = httpListeners(&config.Server{HTTPSPort: 8443}, metrics.NewRegistry()); err == nil {
+		t.Error("expected an error with an HTTPS port and no TLS config, got none")
+	}
+}
@@ -10,7 +10,9 @@ import (
 )
 
 // JSONToHTTP is the middleware func to convert a JSONEndpoint to
-// an http.HandlerFunc.
+// an http.HandlerFunc. If the request's Accept header prefers protobuf,
+// MessagePack or CBOR, successful responses will be encoded with that codec
+// instead of JSON. Protobuf is only used for responses that are a proto.Message.
 func JSONToHTTP(ep JSONEndpoint) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if r.Body != nil {
@@ -28,6 +30,10 @@ func JSONToHTTP(ep JSONEndpoint) http.Handler {
 
 		// call the func and return err or not
 		code, res, err := ep(r)
+		if c, ok := responseCodec(r, res); ok && err == nil {
+			writeCodec(w, r, c, code, res)
+			return
+		}
 		w.WriteHeader(code)
 		if err != nil {
 			res = err
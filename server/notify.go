@@ -0,0 +1,47 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+	"sync/atomic"
+
+	"github.com/NYTimes/gizmo/notify"
+)
+
+// Notifier is an optional hook that will be sent an event whenever the
+// server's health check changes between healthy and unhealthy.
+var Notifier notify.Notifier
+
+// NotifyHealthChanges will wrap the health check handler so that the
+// Notifier is sent an event whenever its responses change between healthy
+// and unhealthy. Any status code under 400 is considered healthy.
+func NotifyHealthChanges(h http.Handler, n notify.Notifier) http.Handler {
+	// the server is assumed healthy as it starts
+	var unhealthy int32
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mw := newMetricsResponseWriter(w)
+		h.ServeHTTP(mw, r)
+
+		code := mw.StatusCode
+		if code == 0 {
+			code = http.StatusOK
+		}
+		var now int32
+		if code >= 400 {
+			now = 1
+		}
+		if atomic.SwapInt32(&unhealthy, now) == now {
+			return
+		}
+		e := &notify.Event{
+			Title:  Name + " is healthy again",
+			Level:  notify.Info,
+			Fields: map[string]string{"status": strconv.Itoa(code)},
+		}
+		if now == 1 {
+			e.Title = Name + " is unhealthy"
+			e.Level = notify.Warning
+		}
+		n.Notify(e)
+	})
+}
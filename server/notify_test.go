@@ -0,0 +1,34 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/NYTimes/gizmo/notify"
+)
+
+func TestNotifyHealthChanges(t *testing.T) {
+	var (
+		events []*notify.Event
+		code   = http.StatusOK
+	)
+	h := NotifyHealthChanges(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(code)
+	}), notify.NotifierFunc(func(e *notify.Event) {
+		events = append(events, e)
+	}))
+
+	for _, c := range []int{200, 200, 503, 503, 200, 200} {
+		code = c
+		r, _ := http.NewRequest("GET", "/status", nil)
+		h.ServeHTTP(httptest.NewRecorder(), r)
+	}
+
+	if len(events) != 2 {
+		t.Fatalf("expected 2 events for one flap, got %d", len(events))
+	}
+	if events[0].Level != notify.Warning || events[1].Level != notify.Info {
+		t.Errorf("expected a warning then info event, got %s then %s", events[0].Level, events[1].Level)
+	}
+}
@@ -0,0 +1,312 @@
+package server
+
+import (
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/NYTimes/gizmo/config"
+	"github.com/NYTimes/gizmo/web"
+)
+
+var (
+	// OpenAPIPath is the path the OpenAPI document will be served at
+	// by RegisterOpenAPI.
+	OpenAPIPath = "/openapi.json"
+	// SwaggerUIPath is the path the Swagger UI will be served at
+	// by RegisterOpenAPI.
+	SwaggerUIPath = "/openapi/"
+	// SwaggerUIAssets is the base URL used to load the Swagger UI
+	// scripts and styles.
+	SwaggerUIAssets = "https://unpkg.com/swagger-ui-dist@3"
+)
+
+// OpenAPIDocument is an OpenAPI 3 document describing the routes of a server.
+type OpenAPIDocument struct {
+	OpenAPI    string                                  `json:"openapi"`
+	Info       OpenAPIInfo                             `json:"info"`
+	Paths      map[string]map[string]*OpenAPIOperation `json:"paths"`
+	Components OpenAPIComponents                       `json:"components"`
+}
+
+// OpenAPIInfo holds the metadata of an OpenAPIDocument.
+type OpenAPIInfo struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+// OpenAPIComponents holds the schemas referenced by an OpenAPIDocument.
+type OpenAPIComponents struct {
+	Schemas map[string]*OpenAPISchema `json:"schemas"`
+}
+
+// OpenAPIOperation describes a single method on a path.
+type OpenAPIOperation struct {
+	OperationID string                      `json:"operationId,omitempty"`
+	Summary     string                      `json:"summary,omitempty"`
+	Deprecated  bool                        `json:"deprecated,omitempty"`
+	Parameters  []*OpenAPIParameter         `json:"parameters,omitempty"`
+	RequestBody *OpenAPIRequestBody         `json:"requestBody,omitempty"`
+	Responses   map[string]*OpenAPIResponse `json:"responses"`
+}
+
+// OpenAPIParameter describes a path parameter of an operation.
+type OpenAPIParameter struct {
+	Name     string         `json:"name"`
+	In       string         `json:"in"`
+	Required bool           `json:"required"`
+	Schema   *OpenAPISchema `json:"schema"`
+}
+
+// OpenAPIRequestBody describes the request body of an operation.
+type OpenAPIRequestBody struct {
+	Required bool                         `json:"required"`
+	Content  map[string]*OpenAPIMediaType `json:"content"`
+}
+
+// OpenAPIResponse describes a response of an operation.
+type OpenAPIResponse struct {
+	Description string                       `json:"description"`
+	Content     map[string]*OpenAPIMediaType `json:"content,omitempty"`
+}
+
+// OpenAPIMediaType holds the schema of a request or response body.
+type OpenAPIMediaType struct {
+	Schema *OpenAPISchema `json:"schema"`
+}
+
+// OpenAPISchema is the subset of the OpenAPI schema object that can
+// be derived from Go types.
+type OpenAPISchema struct {
+	Ref                  string                    `json:"$ref,omitempty"`
+	Type                 string                    `json:"type,omitempty"`
+	Format               string                    `json:"format,omitempty"`
+	Properties           map[string]*OpenAPISchema `json:"properties,omitempty"`
+	Items                *OpenAPISchema            `json:"items,omitempty"`
+	AdditionalProperties *OpenAPISchema            `json:"additionalProperties,omitempty"`
+}
+
+// NewOpenAPIDocument will build an OpenAPI 3 document from the given routes.
+// Request and response schemas will be derived from the types in each
+// route's EndpointDoc via reflection.
+func NewOpenAPIDocument(title, version string, routes []Route) *OpenAPIDocument {
+	doc := &OpenAPIDocument{
+		OpenAPI: "3.0.0",
+		Info:    OpenAPIInfo{Title: title, Version: version},
+		Paths:   map[string]map[string]*OpenAPIOperation{},
+		Components: OpenAPIComponents{
+			Schemas: map[string]*OpenAPISchema{},
+		},
+	}
+
+	for _, route := range routes {
+		path, params := openAPIPath(route.Path)
+		op := &OpenAPIOperation{
+			Responses: map[string]*OpenAPIResponse{
+				"default": &OpenAPIResponse{Description: "unexpected error"},
+			},
+		}
+		for _, param := range params {
+			op.Parameters = append(op.Parameters, &OpenAPIParameter{
+				Name:     param,
+				In:       "path",
+				Required: true,
+				Schema:   &OpenAPISchema{Type: "string"},
+			})
+		}
+
+		ok := &OpenAPIResponse{Description: http.StatusText(http.StatusOK)}
+		if d := route.Doc; d != nil {
+			op.OperationID = d.Name
+			op.Summary = d.Summary
+			op.Deprecated = d.Deprecated
+			if d.Request != nil {
+				op.RequestBody = &OpenAPIRequestBody{
+					Required: true,
+					Content:  doc.jsonContent(d.Request),
+				}
+			}
+			if d.Response != nil {
+				ok.Content = doc.jsonContent(d.Response)
+			}
+		}
+		op.Responses["200"] = ok
+
+		if doc.Paths[path] == nil {
+			doc.Paths[path] = map[string]*OpenAPIOperation{}
+		}
+		doc.Paths[path][strings.ToLower(route.Method)] = op
+	}
+	return doc
+}
+
+func (d *OpenAPIDocument) jsonContent(v interface{}) map[string]*OpenAPIMediaType {
+	return map[string]*OpenAPIMediaType{
+		"application/json": &OpenAPIMediaType{Schema: d.schema(reflect.TypeOf(v))},
+	}
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// schema will return the schema for the given type. Named structs will be
+// added to the document's components and referenced.
+func (d *OpenAPIDocument) schema(t reflect.Type) *OpenAPISchema {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == timeType {
+		return &OpenAPISchema{Type: "string", Format: "date-time"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &OpenAPISchema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &OpenAPISchema{Type: "integer", Format: "int32"}
+	case reflect.Int64, reflect.Uint64:
+		return &OpenAPISchema{Type: "integer", Format: "int64"}
+	case reflect.Float32:
+		return &OpenAPISchema{Type: "number", Format: "float"}
+	case reflect.Float64:
+		return &OpenAPISchema{Type: "number", Format: "double"}
+	case reflect.String:
+		return &OpenAPISchema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			// encoding/json will base64 encode []byte
+			return &OpenAPISchema{Type: "string", Format: "byte"}
+		}
+		return &OpenAPISchema{Type: "array", Items: d.schema(t.Elem())}
+	case reflect.Map:
+		return &OpenAPISchema{Type: "object", AdditionalProperties: d.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return d.structSchema(t)
+		}
+		if _, ok := d.Components.Schemas[t.Name()]; !ok {
+			// reserve the name first in case the type refers to itself
+			d.Components.Schemas[t.Name()] = nil
+			d.Components.Schemas[t.Name()] = d.structSchema(t)
+		}
+		return &OpenAPISchema{Ref: "#/components/schemas/" + t.Name()}
+	}
+	// interfaces and anything else can be any value
+	return &OpenAPISchema{}
+}
+
+func (d *OpenAPIDocument) structSchema(t reflect.Type) *OpenAPISchema {
+	s := &OpenAPISchema{Type: "object", Properties: map[string]*OpenAPISchema{}}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" && !f.Anonymous {
+			// unexported
+			continue
+		}
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name := strings.Split(tag, ",")[0]
+		ft := f.Type
+		for ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		// embedded structs without a tag are flattened by encoding/json
+		if f.Anonymous && name == "" && ft.Kind() == reflect.Struct {
+			for prop, ps := range d.structSchema(ft).Properties {
+				s.Properties[prop] = ps
+			}
+			continue
+		}
+		if f.PkgPath != "" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		s.Properties[name] = d.schema(f.Type)
+	}
+	return s
+}
+
+// openAPIPath will convert a Gorilla or httprouter style route
+// path into an OpenAPI path and return its parameters.
+func openAPIPath(path string) (string, []string) {
+	segs := strings.Split(path, "/")
+	var params []string
+	for i, seg := range segs {
+		if p := PathParams(seg); len(p) > 0 {
+			segs[i] = "{" + p[0] + "}"
+			params = append(params, p[0])
+		}
+	}
+	return strings.Join(segs, "/"), params
+}
+
+// OpenAPIHandler will serve an OpenAPIDocument of the given routes. The
+// routes func is called on every request so that the document will reflect
+// any services registered after the handler is created.
+func OpenAPIHandler(title, version string, routes func() []Route) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", jsonContentType)
+		err := json.NewEncoder(w).Encode(NewOpenAPIDocument(title, version, routes()))
+		if err != nil {
+			LogWithFields(r).Error("unable to JSON encode OpenAPI document: ", err)
+		}
+	})
+}
+
+// SwaggerUIHandler will serve a Swagger UI page for the OpenAPI document
+// at the given URL.
+func SwaggerUIHandler(specURL string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", web.HTMLContentType)
+		err := swaggerUITmpl.Execute(w, struct {
+			Assets  string
+			SpecURL string
+		}{SwaggerUIAssets, specURL})
+		if err != nil {
+			LogWithFields(r).Error("unable to render Swagger UI: ", err)
+		}
+	})
+}
+
+// RegisterOpenAPI will add the OpenAPIHandler and SwaggerUIHandler to the
+// given router if EnableOpenAPI is set in the config.
+func RegisterOpenAPI(cfg *config.Server, routes func() []Route, mx Router) {
+	if !cfg.EnableOpenAPI {
+		return
+	}
+	title := cfg.OpenAPITitle
+	if title == "" {
+		title = "gizmo service"
+	}
+	version := cfg.OpenAPIVersion
+	if version == "" {
+		version = "1.0.0"
+	}
+	mx.Handle("GET", OpenAPIPath, OpenAPIHandler(title, version, routes))
+	mx.Handle("GET", SwaggerUIPath, SwaggerUIHandler(OpenAPIPath))
+}
+
+var swaggerUITmpl = template.Must(template.New("swagger").Parse(`<!DOCTYPE html>
+<html>
+<head>
+  <title>API Documentation</title>
+  <link rel="stylesheet" href="{{.Assets}}/swagger-ui.css">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="{{.Assets}}/swagger-ui-bundle.js"></script>
+  <script>
+    window.onload = function() {
+      SwaggerUIBundle({url: {{.SpecURL}}, dom_id: "#swagger-ui"});
+    };
+  </script>
+</body>
+</html>
+`))
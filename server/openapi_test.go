@@ -0,0 +1,121 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/NYTimes/gizmo/config"
+)
+
+type testOpenAPICat struct {
+	Name   string           `json:"name"`
+	Secret string           `json:"-"`
+	Tags   []string         `json:"tags,omitempty"`
+	Owner  *testOpenAPICat  `json:"owner"`
+	Extra  map[string]int64 `json:"extra"`
+	hidden bool
+}
+
+func TestNewOpenAPIDocument(t *testing.T) {
+	routes := []Route{
+		{Method: "GET", Path: "/svc/v1/cats/{id:[0-9]+}", Doc: &EndpointDoc{
+			Name:     "GetCat",
+			Summary:  "returns a cat",
+			Response: &testOpenAPICat{},
+		}},
+		{Method: "PUT", Path: "/svc/v1/cats/{id:[0-9]+}", Doc: &EndpointDoc{
+			Request:    &testOpenAPICat{},
+			Deprecated: true,
+		}},
+		{Method: "GET", Path: "/svc/v1/status"},
+	}
+	doc := NewOpenAPIDocument("cats", "1.2.3", routes)
+
+	if doc.Info.Title != "cats" || doc.Info.Version != "1.2.3" {
+		t.Errorf("expected info of cats/1.2.3, got %#v", doc.Info)
+	}
+	ops, ok := doc.Paths["/svc/v1/cats/{id}"]
+	if !ok {
+		t.Fatalf("expected path '/svc/v1/cats/{id}', got %#v", doc.Paths)
+	}
+	get := ops["get"]
+	if get == nil || get.OperationID != "GetCat" || get.Summary != "returns a cat" {
+		t.Fatalf("expected GetCat operation, got %#v", get)
+	}
+	if len(get.Parameters) != 1 || get.Parameters[0].Name != "id" || get.Parameters[0].In != "path" {
+		t.Errorf("expected a single 'id' path parameter, got %#v", get.Parameters)
+	}
+	if got := get.Responses["200"].Content["application/json"].Schema.Ref; got != "#/components/schemas/testOpenAPICat" {
+		t.Errorf("expected response schema ref to testOpenAPICat, got %q", got)
+	}
+	if put := ops["put"]; put == nil || !put.Deprecated || put.RequestBody == nil {
+		t.Errorf("expected a deprecated put operation with a request body, got %#v", put)
+	}
+	if _, ok := doc.Paths["/svc/v1/status"]["get"]; !ok {
+		t.Error("expected undocumented routes to be included")
+	}
+
+	schema := doc.Components.Schemas["testOpenAPICat"]
+	if schema == nil {
+		t.Fatal("expected testOpenAPICat component schema")
+	}
+	tests := []struct {
+		prop string
+
+		wantType string
+		wantRef  string
+	}{
+		{"name", "string", ""},
+		{"tags", "array", ""},
+		{"owner", "", "#/components/schemas/testOpenAPICat"},
+		{"extra", "object", ""},
+	}
+	for _, test := range tests {
+		ps := schema.Properties[test.prop]
+		if ps == nil {
+			t.Errorf("expected property %q, got none", test.prop)
+			continue
+		}
+		if ps.Type != test.wantType || ps.Ref != test.wantRef {
+			t.Errorf("property %q expected type %q and ref %q, got %q and %q",
+				test.prop, test.wantType, test.wantRef, ps.Type, ps.Ref)
+		}
+	}
+	if len(schema.Properties) != len(tests) {
+		t.Errorf("expected %d properties, got %d", len(tests), len(schema.Properties))
+	}
+}
+
+func TestRegisterOpenAPI(t *testing.T) {
+	cfg := &config.Server{EnableOpenAPI: true, OpenAPITitle: "test"}
+	srvr := NewSimpleServer(cfg)
+	if err := srvr.Register(&testMixedService{}); err != nil {
+		t.Fatal("unable to register service: ", err)
+	}
+	RegisterOpenAPI(cfg, srvr.Routes, srvr.mux)
+
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("GET", OpenAPIPath, nil)
+	srvr.ServeHTTP(w, r)
+
+	var doc OpenAPIDocument
+	if err := json.NewDecoder(w.Body).Decode(&doc); err != nil {
+		t.Fatal("unable to decode OpenAPI document: ", err)
+	}
+	if doc.OpenAPI != "3.0.0" || doc.Info.Title != "test" {
+		t.Errorf("expected a 3.0.0 document titled 'test', got %q and %q", doc.OpenAPI, doc.Info.Title)
+	}
+	if len(doc.Paths) != 2 {
+		t.Errorf("expected 2 documented paths, got %d", len(doc.Paths))
+	}
+
+	w = httptest.NewRecorder()
+	r, _ = http.NewRequest("GET", SwaggerUIPath, nil)
+	srvr.ServeHTTP(w, r)
+	if !strings.Contains(w.Body.String(), `"/openapi.json"`) {
+		t.Errorf("expected Swagger UI to reference the OpenAPI document, got %q", w.Body.String())
+	}
+}
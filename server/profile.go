@@ -0,0 +1,160 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"path"
+	"runtime/pprof"
+	"sync/atomic"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+
+	"github.com/NYTimes/gizmo/config"
+)
+
+// DefaultProfileCPUDuration is how long a ProfileCapture will record a
+// CPU profile for if no ProfileCPUDuration is configured.
+var DefaultProfileCPUDuration = 30 * time.Second
+
+// ErrCaptureInProgress is returned by ProfileCapture.Capture
+// if another capture has not finished yet.
+var ErrCaptureInProgress = errors.New("a profile capture is already in progress")
+
+// ProfileCapture will capture heap, goroutine and CPU profiles of the running
+// process and upload them to S3 so production incidents can be debugged
+// without attaching pprof directly. Profiles are written in the pprof
+// format under '<prefix>/<server name>/<UTC timestamp>/<profile>.pprof'.
+type ProfileCapture struct {
+	// CPUDuration is how long the CPU profile will be recorded for.
+	CPUDuration time.Duration
+
+	s3     s3iface.S3API
+	bucket string
+	prefix string
+
+	capturing int32
+}
+
+// NewProfileCapture will initiate the S3 client for the ProfileBucket in the
+// given config. The client will use the AWS_ACCESS_KEY and the AWS_SECRET_KEY
+// environment variables.
+func NewProfileCapture(cfg *config.Server) (*ProfileCapture, error) {
+	if cfg.ProfileBucket == "" {
+		return nil, errors.New("profile S3 bucket is required")
+	}
+	if cfg.ProfileRegion == "" {
+		return nil, errors.New("profile S3 region is required")
+	}
+	dur := cfg.ProfileCPUDuration
+	if dur == 0 {
+		dur = DefaultProfileCPUDuration
+	}
+	return &ProfileCapture{
+		CPUDuration: dur,
+		s3: s3.New(session.New(&aws.Config{
+			Credentials: credentials.NewEnvCredentials(),
+			Region:      &cfg.ProfileRegion,
+		})),
+		bucket: cfg.ProfileBucket,
+		prefix: cfg.ProfilePrefix,
+	}, nil
+}
+
+// Capture will upload heap and goroutine profiles and then a CPU profile
+// recorded over the CPUDuration. It returns the S3 keys of all uploaded
+// profiles. If any profile fails, the rest will still be attempted and the
+// first error will be returned.
+func (p *ProfileCapture) Capture() ([]string, error) {
+	if !atomic.CompareAndSwapInt32(&p.capturing, 0, 1) {
+		return nil, ErrCaptureInProgress
+	}
+	defer atomic.StoreInt32(&p.capturing, 0)
+
+	dir := path.Join(p.prefix, Name, time.Now().UTC().Format("20060102T150405Z"))
+	var (
+		keys     []string
+		firstErr error
+	)
+	upload := func(name string, capture func(*bytes.Buffer) error) {
+		var buf bytes.Buffer
+		err := capture(&buf)
+		if err == nil {
+			key := path.Join(dir, name+".pprof")
+			_, err = p.s3.PutObject(&s3.PutObjectInput{
+				Bucket: &p.bucket,
+				Key:    &key,
+				Body:   bytes.NewReader(buf.Bytes()),
+			})
+			if err == nil {
+				keys = append(keys, key)
+			}
+		}
+		if err != nil {
+			Log.Errorf("unable to capture %s profile: %s", name, err)
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+
+	// snapshots first so they reflect the process when the capture began
+	for _, name := range []string{"heap", "goroutine"} {
+		prof := pprof.Lookup(name)
+		upload(name, func(buf *bytes.Buffer) error {
+			return prof.WriteTo(buf, 0)
+		})
+	}
+	upload("cpu", func(buf *bytes.Buffer) error {
+		if err := pprof.StartCPUProfile(buf); err != nil {
+			return err
+		}
+		time.Sleep(p.CPUDuration)
+		pprof.StopCPUProfile()
+		return nil
+	})
+	return keys, firstErr
+}
+
+// ProfileCaptureResult is the response of a ProfileCapture's ServeHTTP.
+type ProfileCaptureResult struct {
+	Keys  []string `json:"keys"`
+	Error string   `json:"error,omitempty"`
+}
+
+// ServeHTTP will run a Capture and respond with the uploaded keys. If a
+// capture is already in progress, it will respond with a 409.
+func (p *ProfileCapture) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	keys, err := p.Capture()
+	res := ProfileCaptureResult{Keys: keys}
+	status := http.StatusOK
+	if err != nil {
+		res.Error = err.Error()
+		status = http.StatusInternalServerError
+		if err == ErrCaptureInProgress {
+			status = http.StatusConflict
+		}
+	}
+	w.Header().Set("Content-Type", jsonContentType)
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(res); err != nil {
+		LogWithFields(r).Warn("unable to write profile capture result: ", err)
+	}
+}
+
+// captureOnSignal will run a Capture in the background and log the result.
+func (p *ProfileCapture) captureOnSignal() {
+	go func() {
+		keys, err := p.Capture()
+		if err != nil {
+			Log.Error("unable to capture profiles: ", err)
+		}
+		Log.Infof("uploaded %d profiles to s3: %v", len(keys), keys)
+	}()
+}
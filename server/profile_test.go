@@ -0,0 +1,116 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+)
+
+// testS3API will record any uploaded objects. All other
+// S3API methods will panic.
+type testS3API struct {
+	s3iface.S3API
+
+	mu      sync.Mutex
+	objects map[string][]byte
+	err     error
+	// unblock, if set, will hold uploads until it is closed
+	unblock chan struct{}
+}
+
+func (s *testS3API) PutObject(i *s3.PutObjectInput) (*s3.PutObjectOutput, error) {
+	if s.unblock != nil {
+		<-s.unblock
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return nil, s.err
+	}
+	b, _ := ioutil.ReadAll(i.Body)
+	s.objects[*i.Bucket+"/"+*i.Key] = b
+	return &s3.PutObjectOutput{}, nil
+}
+
+func TestProfileCapture(t *testing.T) {
+	store := &testS3API{objects: map[string][]byte{}}
+	p := &ProfileCapture{
+		CPUDuration: 10 * time.Millisecond,
+		s3:          store,
+		bucket:      "debug",
+		prefix:      "profiles",
+	}
+
+	keys, err := p.Capture()
+	if err != nil {
+		t.Fatal("unexpected error capturing profiles: ", err)
+	}
+	if len(keys) != 3 {
+		t.Fatalf("expected 3 profiles, got %v", keys)
+	}
+	for i, name := range []string{"heap", "goroutine", "cpu"} {
+		key := keys[i]
+		if !strings.HasPrefix(key, "profiles/"+Name+"/") || !strings.HasSuffix(key, "/"+name+".pprof") {
+			t.Errorf("expected a timestamped %s profile key, got '%s'", name, key)
+		}
+		if len(store.objects["debug/"+key]) == 0 {
+			t.Errorf("expected a non-empty %s profile to be uploaded", name)
+		}
+	}
+
+	store.err = errors.New("access denied")
+	keys, err = p.Capture()
+	if err != store.err {
+		t.Errorf("expected upload error, got %v", err)
+	}
+	if len(keys) != 0 {
+		t.Errorf("expected no keys, got %v", keys)
+	}
+}
+
+func TestProfileCaptureServeHTTP(t *testing.T) {
+	store := &testS3API{objects: map[string][]byte{}, unblock: make(chan struct{})}
+	p := &ProfileCapture{CPUDuration: time.Millisecond, s3: store, bucket: "debug"}
+
+	first := make(chan *httptest.ResponseRecorder)
+	go func() {
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest("POST", "/admin/profile", nil)
+		p.ServeHTTP(w, r)
+		first <- w
+	}()
+	// wait for the first capture to begin
+	for i := 0; i < 1000 && atomic.LoadInt32(&p.capturing) == 0; i++ {
+		time.Sleep(time.Millisecond)
+	}
+
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("POST", "/admin/profile", nil)
+	p.ServeHTTP(w, r)
+	if w.Code != http.StatusConflict {
+		t.Errorf("expected a concurrent capture to get a 409, got %d", w.Code)
+	}
+
+	close(store.unblock)
+	w = <-first
+	if w.Code != http.StatusOK {
+		t.Errorf("expected a 200, got %d", w.Code)
+	}
+	var res ProfileCaptureResult
+	if err := json.NewDecoder(w.Body).Decode(&res); err != nil {
+		t.Fatal("unable to decode response: ", err)
+	}
+	if len(res.Keys) != 3 || res.Error != "" {
+		t.Errorf("expected 3 keys and no error, got %#v", res)
+	}
+}
@@ -0,0 +1,235 @@
+package server
+
+import (
+	"log"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// DefaultProxyTimeout is the amount of time a ProxyUpstream will wait for
+	// an upstream to respond if no Timeout is given.
+	DefaultProxyTimeout = 10 * time.Second
+	// DefaultProxyBreakerCooldown is how long a ProxyUpstream circuit breaker will stay
+	// open before allowing a trial request through if no BreakerCooldown is given.
+	DefaultProxyBreakerCooldown = 30 * time.Second
+)
+
+// ProxyUpstream describes where and how the routes of a ProxyService
+// should be forwarded.
+type ProxyUpstream struct {
+	// URL is the upstream server to forward requests to. The incoming
+	// request path will be appended to the URL's path.
+	URL *url.URL
+	// StripPrefix will be trimmed from the incoming request path before
+	// it is forwarded.
+	StripPrefix string
+	// SetHeaders will be set on every request sent to the upstream.
+	SetHeaders map[string]string
+	// RemoveHeaders will be removed from every request sent to the upstream.
+	RemoveHeaders []string
+	// Timeout is the max duration to wait for the upstream to connect and
+	// respond with headers on each attempt. Defaults to DefaultProxyTimeout.
+	Timeout time.Duration
+	// Retries is the number of additional attempts that will be made on
+	// safe (GET, HEAD, OPTIONS) requests that fail to connect or receive
+	// a 502, 503 or 504 from the upstream.
+	Retries int
+	// BreakerThreshold is the number of consecutive upstream failures
+	// that will open the circuit breaker. While the breaker is open, requests
+	// will immediately receive a 503. If 0, no circuit breaking will be done.
+	BreakerThreshold int
+	// BreakerCooldown is the amount of time an open circuit breaker will wait
+	// before letting a trial request through. Defaults to DefaultProxyBreakerCooldown.
+	BreakerCooldown time.Duration
+}
+
+// NewProxyHandler will return an http.Handler that forwards all requests to
+// the given upstream.
+func NewProxyHandler(up *ProxyUpstream) http.Handler {
+	timeout := up.Timeout
+	if timeout == 0 {
+		timeout = DefaultProxyTimeout
+	}
+	cooldown := up.BreakerCooldown
+	if cooldown == 0 {
+		cooldown = DefaultProxyBreakerCooldown
+	}
+
+	p := &proxyHandler{
+		breaker: &circuitBreaker{threshold: up.BreakerThreshold, cooldown: cooldown},
+	}
+	p.proxy = &httputil.ReverseProxy{
+		Director: proxyDirector(up),
+		Transport: &proxyTransport{
+			retries: up.Retries,
+			breaker: p.breaker,
+			transport: &http.Transport{
+				Proxy: http.ProxyFromEnvironment,
+				Dial: (&net.Dialer{
+					Timeout:   timeout,
+					KeepAlive: 30 * time.Second,
+				}).Dial,
+				TLSHandshakeTimeout:   timeout,
+				ResponseHeaderTimeout: timeout,
+			},
+		},
+		ErrorLog: log.New(Log.Writer(), "", 0),
+	}
+	return p
+}
+
+type proxyHandler struct {
+	proxy   *httputil.ReverseProxy
+	breaker *circuitBreaker
+}
+
+// ServeHTTP will respond with a 503 if the circuit breaker is open and
+// otherwise hand the request off to the reverse proxy.
+func (p *proxyHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !p.breaker.allow() {
+		http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+		return
+	}
+	p.proxy.ServeHTTP(w, r)
+}
+
+func proxyDirector(up *ProxyUpstream) func(*http.Request) {
+	return func(r *http.Request) {
+		path := strings.TrimPrefix(r.URL.Path, up.StripPrefix)
+		r.URL.Scheme = up.URL.Scheme
+		r.URL.Host = up.URL.Host
+		r.URL.Path = singleJoiningSlash(up.URL.Path, path)
+		if up.URL.RawQuery != "" && r.URL.RawQuery != "" {
+			r.URL.RawQuery = up.URL.RawQuery + "&" + r.URL.RawQuery
+		} else if up.URL.RawQuery != "" {
+			r.URL.RawQuery = up.URL.RawQuery
+		}
+		r.Host = up.URL.Host
+
+		for _, hdr := range up.RemoveHeaders {
+			r.Header.Del(hdr)
+		}
+		for hdr, val := range up.SetHeaders {
+			r.Header.Set(hdr, val)
+		}
+	}
+}
+
+func singleJoiningSlash(a, b string) string {
+	aslash := strings.HasSuffix(a, "/")
+	bslash := strings.HasPrefix(b, "/")
+	switch {
+	case aslash && bslash:
+		return a + b[1:]
+	case !aslash && !bslash:
+		return a + "/" + b
+	}
+	return a + b
+}
+
+// proxyTransport will retry safe requests and record the outcome
+// of each attempt with the circuit breaker.
+type proxyTransport struct {
+	retries   int
+	breaker   *circuitBreaker
+	transport http.RoundTripper
+}
+
+func (t *proxyTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	attempts := 1
+	if isSafeMethod(r.Method) {
+		attempts += t.retries
+	}
+
+	var (
+		resp *http.Response
+		err  error
+	)
+	for i := 0; i < attempts; i++ {
+		resp, err = t.transport.RoundTrip(r)
+		failed := err != nil || isUpstreamFailure(resp.StatusCode)
+		t.breaker.record(!failed)
+		if !failed || i == attempts-1 {
+			break
+		}
+		// discard the failed response before trying again
+		if resp != nil {
+			resp.Body.Close()
+		}
+	}
+	return resp, err
+}
+
+func isSafeMethod(method string) bool {
+	switch method {
+	case "GET", "HEAD", "OPTIONS":
+		return true
+	}
+	return false
+}
+
+func isUpstreamFailure(code int) bool {
+	switch code {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// circuitBreaker will 'open' after a threshold of consecutive failures and
+// reject all requests until the cooldown has passed. Once it has, the breaker
+// is 'half-open' and lets a single trial request through, rejecting the rest
+// until the outcome of that trial is recorded.
+type circuitBreaker struct {
+	threshold int
+	cooldown  time.Duration
+
+	mu       sync.Mutex
+	failures int
+	openedAt time.Time
+	trial    bool
+}
+
+// allow returns true if the breaker is closed or if the cooldown
+// has passed and a trial request should be let through.
+func (b *circuitBreaker) allow() bool {
+	if b.threshold == 0 {
+		return true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.failures < b.threshold {
+		return true
+	}
+	if b.trial || time.Since(b.openedAt) <= b.cooldown {
+		return false
+	}
+	b.trial = true
+	return true
+}
+
+func (b *circuitBreaker) record(success bool) {
+	if b.threshold == 0 {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.trial = false
+	if success {
+		b.failures = 0
+		return
+	}
+	b.failures++
+	if b.failures >= b.threshold {
+		if b.failures == b.threshold {
+			Log.Warnf("proxy circuit breaker opened after %d consecutive failures", b.failures)
+		}
+		b.openedAt = time.Now()
+	}
+}
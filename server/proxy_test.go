@@ -0,0 +1,188 @@
+package server
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/NYTimes/gizmo/config"
+)
+
+func TestProxyService(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "%s|%s|%s|%s", r.URL.Path, r.URL.RawQuery, r.Header.Get("X-Gateway"), r.Header.Get("Cookie"))
+	}))
+	defer upstream.Close()
+
+	upURL, _ := url.Parse(upstream.URL + "/api")
+	srvr := NewSimpleServer(&config.Server{})
+	err := srvr.Register(&testProxyService{&ProxyUpstream{
+		URL:           upURL,
+		StripPrefix:   "/svc/v1/proxy",
+		SetHeaders:    map[string]string{"X-Gateway": "gizmo"},
+		RemoveHeaders: []string{"Cookie"},
+	}})
+	if err != nil {
+		t.Fatal("unable to register proxy service: ", err)
+	}
+
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("GET", "/svc/v1/proxy/cats?q=1", nil)
+	r.RemoteAddr = "0.0.0.0:8080"
+	r.Header.Set("Cookie", "secret")
+	srvr.ServeHTTP(w, r)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("expected 200 response code, got %d", w.Code)
+	}
+	want := "/api/cats|q=1|gizmo|"
+	if got := w.Body.String(); got != want {
+		t.Errorf("expected proxied response of %q, got %q", want, got)
+	}
+}
+
+func TestProxyHandlerRetries(t *testing.T) {
+	var calls int32
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprint(w, "ok")
+	}))
+	defer upstream.Close()
+
+	upURL, _ := url.Parse(upstream.URL)
+	h := NewProxyHandler(&ProxyUpstream{URL: upURL, Retries: 2})
+
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("GET", "/", nil)
+	h.ServeHTTP(w, r)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("expected 200 response code after retries, got %d", w.Code)
+	}
+	if got := atomic.LoadInt32(&calls); got != 3 {
+		t.Errorf("expected 3 upstream calls, got %d", got)
+	}
+
+	// unsafe methods should not be retried
+	atomic.StoreInt32(&calls, 0)
+	w = httptest.NewRecorder()
+	r, _ = http.NewRequest("POST", "/", nil)
+	h.ServeHTTP(w, r)
+
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected 503 response code without retries, got %d", w.Code)
+	}
+	if got := atomic.LoadInt32(&calls); got != 1 {
+		t.Errorf("expected 1 upstream call, got %d", got)
+	}
+}
+
+func TestProxyHandlerCircuitBreaker(t *testing.T) {
+	var calls int32
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer upstream.Close()
+
+	upURL, _ := url.Parse(upstream.URL)
+	h := NewProxyHandler(&ProxyUpstream{URL: upURL, BreakerThreshold: 2})
+
+	for i := 0; i < 4; i++ {
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest("GET", "/", nil)
+		h.ServeHTTP(w, r)
+	}
+
+	if got := atomic.LoadInt32(&calls); got != 2 {
+		t.Errorf("expected the breaker to open after 2 upstream calls, got %d", got)
+	}
+
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("GET", "/", nil)
+	h.ServeHTTP(w, r)
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected 503 response code from an open breaker, got %d", w.Code)
+	}
+	if body, _ := ioutil.ReadAll(w.Body); len(body) == 0 {
+		t.Error("expected a response body from an open breaker")
+	}
+}
+
+func TestCircuitBreakerHalfOpen(t *testing.T) {
+	b := &circuitBreaker{threshold: 2, cooldown: 10 * time.Millisecond}
+	b.record(false)
+	b.record(false)
+	if b.allow() {
+		t.Fatal("expected an open breaker to reject requests")
+	}
+	time.Sleep(20 * time.Millisecond)
+
+	var (
+		allowed int32
+		wg      sync.WaitGroup
+	)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if b.allow() {
+				atomic.AddInt32(&allowed, 1)
+			}
+		}()
+	}
+	wg.Wait()
+	if allowed != 1 {
+		t.Fatalf("expected a single trial request after the cooldown, got %d", allowed)
+	}
+
+	// a failed trial should reopen the breaker for another cooldown
+	b.record(false)
+	if b.allow() {
+		t.Fatal("expected a failed trial to reopen the breaker")
+	}
+	time.Sleep(20 * time.Millisecond)
+	if !b.allow() {
+		t.Fatal("expected a trial request after the second cooldown")
+	}
+	if b.allow() {
+		t.Fatal("expected requests to be rejected while the trial is in flight")
+	}
+
+	// a successful trial should close the breaker
+	b.record(true)
+	for i := 0; i < 3; i++ {
+		if !b.allow() {
+			t.Fatal("expected a closed breaker to allow requests")
+		}
+	}
+}
+
+type testProxyService struct {
+	up *ProxyUpstream
+}
+
+func (s *testProxyService) Prefix() string {
+	return "/svc/v1"
+}
+
+func (s *testProxyService) ProxyEndpoints() map[string]map[string]*ProxyUpstream {
+	return map[string]map[string]*ProxyUpstream{
+		"/proxy/{path:.*}": map[string]*ProxyUpstream{
+			"GET": s.up,
+		},
+	}
+}
+
+func (s *testProxyService) Middleware(h http.Handler) http.Handler {
+	return h
+}
@@ -0,0 +1,19 @@
+/*
+Package pubsubhttp holds the parts of the server package that build on pubsub,
+so binaries that only serve HTTP do not link in every pubsub backend.
+
+A LongPoller will consume a pubsub.Subscriber and hand each message to any
+requests waiting for a matching one, for poll-based client APIs on top of
+queue-driven data.
+
+WithTx offers handlers that write to a database and publish events an
+all-or-nothing unit of work. Wrap the handler with StagePublishes to give each
+request a pubsub.StagedPublisher, publish to it inside WithTx and the messages
+will only be sent once the transaction commits.
+
+Importing the package will also have servers serve the
+pubsub.DefaultMessageTracer at the config's MessageTracePath:
+
+	import _ "github.com/NYTimes/gizmo/server/pubsubhttp"
+*/
+package pubsubhttp
@@ -0,0 +1,146 @@
+package pubsubhttp
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/NYTimes/gizmo/pubsub"
+	"github.com/NYTimes/gizmo/server"
+)
+
+var (
+	// ErrPollTimeout is returned by LongPoller.Wait when no matching message
+	// arrives before the timeout.
+	ErrPollTimeout = errors.New("long poll timed out")
+	// ErrPollerStopped is returned by LongPoller.Wait when the poller's
+	// subscriber stops without an error.
+	ErrPollerStopped = errors.New("long poller stopped")
+)
+
+// LongPoller consumes a pubsub.Subscriber and hands each message to any
+// requests waiting for a matching one, so poll-based client APIs can be built
+// on top of queue-driven data. Messages are marked as done once they have been
+// handed out, and messages that no request is waiting for are dropped.
+type LongPoller struct {
+	sub pubsub.Subscriber
+
+	mu      sync.Mutex
+	waiters map[*pollWaiter]struct{}
+	done    chan struct{}
+}
+
+type pollWaiter struct {
+	match func([]byte) bool
+	msg   chan []byte
+}
+
+// NewLongPoller will start consuming the given subscriber.
+func NewLongPoller(sub pubsub.Subscriber) *LongPoller {
+	p := &LongPoller{
+		sub:     sub,
+		waiters: map[*pollWaiter]struct{}{},
+		done:    make(chan struct{}),
+	}
+	go p.run(sub.Start())
+	return p
+}
+
+func (p *LongPoller) run(msgs <-chan pubsub.SubscriberMessage) {
+	defer close(p.done)
+	for msg := range msgs {
+		body := msg.Message()
+		p.mu.Lock()
+		for w := range p.waiters {
+			if w.match == nil || w.match(body) {
+				w.msg <- body
+				delete(p.waiters, w)
+			}
+		}
+		p.mu.Unlock()
+		if err := msg.Done(); err != nil {
+			server.Log.Warn("unable to mark long poll message as done: ", err)
+		}
+	}
+}
+
+// Wait will block until a message that match returns true for arrives, the
+// timeout passes or the context is done. If match is nil, any message will do.
+func (p *LongPoller) Wait(ctx context.Context, match func([]byte) bool, timeout time.Duration) ([]byte, error) {
+	w := &pollWaiter{match: match, msg: make(chan []byte, 1)}
+	p.mu.Lock()
+	p.waiters[w] = struct{}{}
+	p.mu.Unlock()
+	defer func() {
+		p.mu.Lock()
+		delete(p.waiters, w)
+		p.mu.Unlock()
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case body := <-w.msg:
+		return body, nil
+	case <-timer.C:
+		return nil, ErrPollTimeout
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-p.done:
+		if err := p.sub.Err(); err != nil {
+			return nil, err
+		}
+		return nil, ErrPollerStopped
+	}
+}
+
+// Handler will return an http.Handler that waits up to the timeout for a
+// message matching the request and responds with its raw bytes. If match is
+// nil, any message will do. If no message arrives in time, it will respond
+// with a 204 No Content so clients know to poll again.
+func (p *LongPoller) Handler(timeout time.Duration, match func(*http.Request) func([]byte) bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		// stop waiting if the client goes away
+		if cn, ok := w.(http.CloseNotifier); ok {
+			notify := cn.CloseNotify()
+			go func() {
+				select {
+				case <-notify:
+					cancel()
+				case <-ctx.Done():
+				}
+			}()
+		}
+
+		var m func([]byte) bool
+		if match != nil {
+			m = match(r)
+		}
+		body, err := p.Wait(ctx, m, timeout)
+		switch err {
+		case nil:
+			if _, err = w.Write(body); err != nil {
+				server.LogWithFields(r).Warn("unable to write long poll response: ", err)
+			}
+		case ErrPollTimeout:
+			w.WriteHeader(http.StatusNoContent)
+		case context.Canceled:
+			// the client is gone
+		default:
+			server.LogWithFields(r).Error("unable to long poll: ", err)
+			http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+		}
+	})
+}
+
+// Stop will stop the subscriber and wait for the poller to finish consuming it.
+func (p *LongPoller) Stop() error {
+	err := p.sub.Stop()
+	<-p.done
+	return err
+}
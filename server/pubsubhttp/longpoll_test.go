@@ -0,0 +1,82 @@
+package pubsubhttp
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/NYTimes/gizmo/pubsub"
+	"github.com/NYTimes/gizmo/pubsub/pubsubtest"
+)
+
+// chanSubscriber is a pubsub.Subscriber fed by a channel.
+type chanSubscriber struct {
+	msgs chan pubsub.SubscriberMessage
+}
+
+func (s *chanSubscriber) Start() <-chan pubsub.SubscriberMessage { return s.msgs }
+func (s *chanSubscriber) Err() error                             { return nil }
+
+func (s *chanSubscriber) Stop() error {
+	close(s.msgs)
+	return nil
+}
+
+func TestLongPoller(t *testing.T) {
+	sub := &chanSubscriber{make(chan pubsub.SubscriberMessage)}
+	p := NewLongPoller(sub)
+
+	got := make(chan []byte)
+	go func() {
+		body, err := p.Wait(context.Background(), func(b []byte) bool {
+			return bytes.HasPrefix(b, []byte("cat:"))
+		}, time.Second)
+		if err != nil {
+			t.Error("unexpected error waiting: ", err)
+		}
+		got <- body
+	}()
+	// give the waiter a chance to register
+	time.Sleep(20 * time.Millisecond)
+
+	dog := &pubsubtest.TestSubsMessage{Msg: []byte("dog:rex")}
+	cat := &pubsubtest.TestSubsMessage{Msg: []byte("cat:tom")}
+	sub.msgs <- dog
+	sub.msgs <- cat
+
+	select {
+	case body := <-got:
+		if string(body) != "cat:tom" {
+			t.Errorf("expected 'cat:tom', got %q", body)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected the waiter to get the matching message")
+	}
+	// once the poller takes another message, it is done with the last
+	sub.msgs <- &pubsubtest.TestSubsMessage{Msg: []byte("dog:fido")}
+	if !dog.Doned || !cat.Doned {
+		t.Error("expected both messages to be marked as done")
+	}
+
+	if _, err := p.Wait(context.Background(), nil, 10*time.Millisecond); err != ErrPollTimeout {
+		t.Errorf("expected ErrPollTimeout, got %v", err)
+	}
+
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("GET", "/poll", nil)
+	p.Handler(10*time.Millisecond, nil).ServeHTTP(w, r)
+	if w.Code != http.StatusNoContent {
+		t.Errorf("expected a 204 on timeout, got %d", w.Code)
+	}
+
+	if err := p.Stop(); err != nil {
+		t.Error("unexpected error stopping: ", err)
+	}
+	if _, err := p.Wait(context.Background(), nil, time.Second); err != ErrPollerStopped {
+		t.Errorf("expected ErrPollerStopped, got %v", err)
+	}
+}
@@ -0,0 +1,12 @@
+package pubsubhttp
+
+import (
+	"github.com/NYTimes/gizmo/pubsub"
+	"github.com/NYTimes/gizmo/server"
+)
+
+func init() {
+	if server.MessageTracer == nil {
+		server.MessageTracer = pubsub.DefaultMessageTracer
+	}
+}
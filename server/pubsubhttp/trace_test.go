@@ -0,0 +1,14 @@
+package pubsubhttp
+
+import (
+	"testing"
+
+	"github.com/NYTimes/gizmo/pubsub"
+	"github.com/NYTimes/gizmo/server"
+)
+
+func TestMessageTracer(t *testing.T) {
+	if server.MessageTracer != pubsub.DefaultMessageTracer {
+		t.Errorf("expected the server to serve the pubsub.DefaultMessageTracer, got %v", server.MessageTracer)
+	}
+}
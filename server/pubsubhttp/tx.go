@@ -0,0 +1,72 @@
+package pubsubhttp
+
+import (
+	"database/sql"
+	"net/http"
+
+	"golang.org/x/net/context"
+
+	"github.com/NYTimes/gizmo/pubsub"
+	"github.com/NYTimes/gizmo/server"
+)
+
+// StagePublishes is middleware that will give each request's context a
+// pubsub.StagedPublisher wrapping pub. Handlers can retrieve it with
+// pubsub.StagedPublisherFromContext and use WithTx to publish only once their
+// database writes have been committed. Any messages still staged when the
+// request completes are discarded.
+func StagePublishes(pub pubsub.Publisher, h server.ContextHandler) server.ContextHandler {
+	return server.ContextHandlerFunc(func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		ctx, sp := pubsub.NewStagedContext(ctx, pub)
+		defer sp.Discard()
+		h.ServeHTTPContext(ctx, w, r)
+	})
+}
+
+// WithTx will run fn within a new transaction on db. If fn returns an error or
+// panics, the transaction is rolled back and any messages staged on the
+// context's pubsub.StagedPublisher are discarded. Otherwise the transaction is
+// committed and the staged messages are flushed.
+//
+// Publishing happens after the commit, so if the flush fails the returned
+// error will describe messages that were not sent for data that was written.
+// Those messages remain staged so the caller may retry the flush.
+func WithTx(ctx context.Context, db *sql.DB, fn func(*sql.Tx) error) (err error) {
+	sp, staged := pubsub.StagedPublisherFromContext(ctx)
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			if staged {
+				sp.Discard()
+			}
+			panic(p)
+		}
+	}()
+
+	if err = fn(tx); err != nil {
+		if rerr := tx.Rollback(); rerr != nil {
+			server.Log.Warn("unable to rollback transaction: ", rerr)
+		}
+		if staged {
+			sp.Discard()
+		}
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		if staged {
+			sp.Discard()
+		}
+		return err
+	}
+
+	if staged {
+		return sp.Flush()
+	}
+	return nil
+}
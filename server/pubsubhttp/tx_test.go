@@ -0,0 +1,109 @@
+package pubsubhttp
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"golang.org/x/net/context"
+
+	"github.com/NYTimes/gizmo/pubsub"
+	"github.com/NYTimes/gizmo/pubsub/pubsubtest"
+	"github.com/NYTimes/gizmo/server"
+)
+
+// txDriver is a database/sql driver that counts commits and rollbacks.
+type txDriver struct {
+	mu        sync.Mutex
+	commits   int
+	rollbacks int
+}
+
+func (d *txDriver) Open(string) (driver.Conn, error) { return &txConn{d}, nil }
+
+func (d *txDriver) counts() (int, int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.commits, d.rollbacks
+}
+
+type txConn struct{ d *txDriver }
+
+func (c *txConn) Prepare(string) (driver.Stmt, error) { return nil, errors.New("not supported") }
+func (c *txConn) Close() error                        { return nil }
+func (c *txConn) Begin() (driver.Tx, error)           { return c, nil }
+
+func (c *txConn) Commit() error {
+	c.d.mu.Lock()
+	c.d.commits++
+	c.d.mu.Unlock()
+	return nil
+}
+
+func (c *txConn) Rollback() error {
+	c.d.mu.Lock()
+	c.d.rollbacks++
+	c.d.mu.Unlock()
+	return nil
+}
+
+var testTxDriver = &txDriver{}
+
+func init() {
+	sql.Register("pubsubhttp-tx-test", testTxDriver)
+}
+
+func TestWithTx(t *testing.T) {
+	db, err := sql.Open("pubsubhttp-tx-test", "")
+	if err != nil {
+		t.Fatal("unexpected error opening db: ", err)
+	}
+	defer db.Close()
+
+	tests := []struct {
+		name string
+		err  error
+
+		wantCommits   int
+		wantRollbacks int
+		wantPublished int
+	}{
+		{"success", nil, 1, 0, 1},
+		{"failure", errors.New("nope"), 0, 1, 0},
+	}
+
+	for _, test := range tests {
+		pub := &pubsubtest.TestPublisher{}
+		c0, r0 := testTxDriver.counts()
+
+		h := StagePublishes(pub, server.ContextHandlerFunc(func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+			err := WithTx(ctx, db, func(tx *sql.Tx) error {
+				sp, _ := pubsub.StagedPublisherFromContext(ctx)
+				sp.PublishRaw("cat", []byte("created"))
+				if len(pub.Published) != 0 {
+					t.Errorf("%s: expected nothing published inside the transaction", test.name)
+				}
+				return test.err
+			})
+			if err != test.err {
+				t.Errorf("%s: expected error %v, got %v", test.name, test.err, err)
+			}
+		}))
+		h.ServeHTTPContext(context.Background(), httptest.NewRecorder(), &http.Request{})
+
+		c1, r1 := testTxDriver.counts()
+		if c1-c0 != test.wantCommits {
+			t.Errorf("%s: expected %d commits, got %d", test.name, test.wantCommits, c1-c0)
+		}
+		if r1-r0 != test.wantRollbacks {
+			t.Errorf("%s: expected %d rollbacks, got %d", test.name, test.wantRollbacks, r1-r0)
+		}
+		if len(pub.Published) != test.wantPublished {
+			t.Errorf("%s: expected %d published, got %d", test.name, test.wantPublished, len(pub.Published))
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package server
+
+import (
+	"sort"
+	"strings"
+)
+
+// EndpointDoc describes a JSONEndpoint so that clients and documentation
+// can be generated for it.
+type EndpointDoc struct {
+	// Name is an identifier for the endpoint that will be used as the
+	// method name in generated clients. If empty, one will be derived
+	// from the route and method.
+	Name string
+	// Summary is a short description of what the endpoint does.
+	Summary string
+	// Request is an example value of the type the endpoint expects
+	// in the request body. nil if the endpoint does not accept a body.
+	Request interface{}
+	// Response is an example value of the type the endpoint will
+	// respond with on success.
+	Response interface{}
+	// Deprecated marks an endpoint that callers should stop using.
+	Deprecated bool
+}
+
+// DocumentedService is an optional interface that services can implement
+// to describe their endpoints.
+type DocumentedService interface {
+	// route - method - doc
+	EndpointDocs() map[string]map[string]*EndpointDoc
+}
+
+// Route describes a single method and path registered by a service.
+type Route struct {
+	// Method is the HTTP method of the route.
+	Method string
+	// Path is the full path of the route, including the service prefix.
+	Path string
+	// Doc is the documentation provided by the service for the route, if any.
+	Doc *EndpointDoc
+}
+
+// ServiceRoutes will return all the routes the given service would register,
+// sorted by path and method. Any EndpointDocs provided by a
+// DocumentedService will be attached to their routes and routes of
+// a VersionedService will be returned for each APIVersion.
+func ServiceRoutes(svc Service) []Route {
+	return groupRoutes(svc, "")
+}
+
+func groupRoutes(svc Service, group string) []Route {
+	prefix := group + strings.TrimRight(svc.Prefix(), "/")
+	vs, ok := svc.(VersionedService)
+	if !ok {
+		return serviceRoutes(svc, prefix)
+	}
+
+	var routes []Route
+	versions := vs.Versions()
+	for _, v := range versions {
+		routes = append(routes, v.routes(serviceRoutes(svc, prefix+"/"+v.Name))...)
+	}
+	if def := defaultVersion(versions); def != nil {
+		routes = append(routes, def.routes(serviceRoutes(svc, prefix))...)
+	}
+	return routes
+}
+
+func serviceRoutes(svc Service, prefix string) []Route {
+	var docs map[string]map[string]*EndpointDoc
+	if ds, ok := svc.(DocumentedService); ok {
+		docs = ds.EndpointDocs()
+	}
+
+	var routes []Route
+	add := func(path, method string) {
+		routes = append(routes, Route{
+			Method: method,
+			Path:   prefix + path,
+			Doc:    docs[path][method],
+		})
+	}
+
+	if ss, ok := svc.(SimpleService); ok {
+		for path, epMethods := range ss.Endpoints() {
+			for method := range epMethods {
+				add(path, method)
+			}
+		}
+	}
+	if js, ok := svc.(JSONService); ok {
+		for path, epMethods := range js.JSONEndpoints() {
+			for method := range epMethods {
+				add(path, method)
+			}
+		}
+	}
+	if cs, ok := svc.(ContextService); ok {
+		for path, epMethods := range cs.ContextEndpoints() {
+			for method := range epMethods {
+				add(path, method)
+			}
+		}
+	}
+	if ps, ok := svc.(ProxyService); ok {
+		for path, upMethods := range ps.ProxyEndpoints() {
+			for method := range upMethods {
+				add(path, method)
+			}
+		}
+	}
+
+	if gs, ok := svc.(GraphQLService); ok {
+		path := gs.GraphQL().path()
+		add(path, "GET")
+		add(path, "POST")
+	}
+
+	sort.Sort(routesByPath(routes))
+	return routes
+}
+
+type routesByPath []Route
+
+func (r routesByPath) Len() int      { return len(r) }
+func (r routesByPath) Swap(i, j int) { r[i], r[j] = r[j], r[i] }
+func (r routesByPath) Less(i, j int) bool {
+	if r[i].Path == r[j].Path {
+		return r[i].Method < r[j].Method
+	}
+	return r[i].Path < r[j].Path
+}
+
+// PathParams will return the names of any parameters in the given
+// route path. Both the Gorilla style ({name} or {name:pattern}) and
+// httprouter style (:name or *name) are understood.
+func PathParams(path string) []string {
+	var params []string
+	for _, seg := range strings.Split(path, "/") {
+		switch {
+		case strings.HasPrefix(seg, "{") && strings.HasSuffix(seg, "}"):
+			name := strings.TrimSuffix(strings.TrimPrefix(seg, "{"), "}")
+			if i := strings.Index(name, ":"); i >= 0 {
+				name = name[:i]
+			}
+			params = append(params, name)
+		case strings.HasPrefix(seg, ":"), strings.HasPrefix(seg, "*"):
+			params = append(params, seg[1:])
+		}
+	}
+	return params
+}
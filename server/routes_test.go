@@ -0,0 +1,39 @@
+package server
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestServiceRoutes(t *testing.T) {
+	s := NewSimpleServer(nil)
+	if err := s.Register(&testMixedService{}); err != nil {
+		t.Fatal("unable to register service: ", err)
+	}
+
+	want := []Route{
+		{Method: "GET", Path: "/svc/v1/json"},
+		{Method: "GET", Path: "/svc/v1/simple"},
+	}
+	if got := s.Routes(); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected routes of %#v, got %#v", want, got)
+	}
+}
+
+func TestPathParams(t *testing.T) {
+	tests := []struct {
+		path string
+
+		want []string
+	}{
+		{"/cats", nil},
+		{"/cats/{id}/toys/{toy:[a-z]+}", []string{"id", "toy"}},
+		{"/cats/:id/*rest", []string{"id", "rest"}},
+	}
+
+	for _, test := range tests {
+		if got := PathParams(test.path); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s expected params of %#v, got %#v", test.path, test.want, got)
+		}
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"time"
 
 	"github.com/NYTimes/gizmo/config"
+	"github.com/NYTimes/gizmo/reporting"
 	"github.com/NYTimes/logrotate"
 
 	"github.com/Sirupsen/logrus"
@@ -97,8 +98,6 @@ func (r *RPCServer) Register(svc Service) error {
 		}
 	}
 
-	RegisterProfiler(r.cfg, r.mux)
-
 	return nil
 }
 
@@ -109,7 +108,7 @@ func (r *RPCServer) Start() error {
 
 	// setup RPC
 	registerRPCAccessLogger(r.cfg)
-	rl, err := net.Listen("tcp", fmt.Sprintf(":%d", r.cfg.RPCPort))
+	rl, err := Listen("tcp", fmt.Sprintf(":%d", r.cfg.RPCPort))
 	if err != nil {
 		return err
 	}
@@ -125,6 +124,8 @@ func (r *RPCServer) Start() error {
 	// setup HTTP
 	healthHandler := RegisterHealthHandler(r.cfg, r.monitor, r.mux)
 	r.cfg.HealthCheckPath = healthHandler.Path()
+	RegisterProfiler(r.cfg, r.mux)
+	RegisterMessageTracer(r.cfg, r.mux)
 	srv := http.Server{
 		Handler:        RegisterAccessLogger(r.cfg, r),
 		MaxHeaderBytes: maxHeaderBytes,
@@ -132,7 +133,7 @@ func (r *RPCServer) Start() error {
 		WriteTimeout:   writeTimeout,
 	}
 	var hl net.Listener
-	hl, err = net.Listen("tcp", fmt.Sprintf(":%d", r.cfg.HTTPPort))
+	hl, err = Listen("tcp", fmt.Sprintf(":%d", r.cfg.HTTPPort))
 	if err != nil {
 		return err
 	}
@@ -167,6 +168,10 @@ func (r *RPCServer) Stop() error {
 	return <-ch
 }
 
+func (r *RPCServer) activity() *ActivityMonitor {
+	return r.monitor
+}
+
 // ServeHTTP is RPCServer's hook for metrics and safely executing each request.
 func (r *RPCServer) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 	AddIPToContext(req)
@@ -189,7 +194,9 @@ func (r *RPCServer) safelyExecuteHTTPRequest(w http.ResponseWriter, req *http.Re
 			errCntr.Inc(1)
 
 			// log the panic for all the details later
-			LogWithFields(req).Errorf("rpc server recovered from an HTTP panic\n%v: %v", x, string(debug.Stack()))
+			stack := debug.Stack()
+			LogWithFields(req).Errorf("rpc server recovered from an HTTP panic\n%v: %v", x, string(stack))
+			reportPanic(x, stack, req)
 
 			// give the users our deepest regrets
 			w.WriteHeader(http.StatusInternalServerError)
@@ -234,7 +241,13 @@ func MonitorRPCRequest() func(ctx context.Context, methodName string, err error)
 			errCntr.Inc(1)
 
 			// log the panic for all the details later
-			Log.Warningf("rpc server recovered from a panic\n%v: %v", x, string(debug.Stack()))
+			stack := debug.Stack()
+			Log.Warningf("rpc server recovered from a panic\n%v: %v", x, string(stack))
+			if ErrorReporter != nil {
+				report := reporting.PanicReport(x, stack)
+				report.Tags = map[string]string{"server": Name, "rpc_method": methodName}
+				ErrorReporter.Report(report)
+			}
 
 			// give the users our deepest regrets
 			err = errors.New(string(UnexpectedServerError))
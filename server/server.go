@@ -19,8 +19,10 @@ import (
 	"github.com/gorilla/handlers"
 	"github.com/nu7hatch/gouuid"
 	"github.com/rcrowley/go-metrics"
+	netContext "golang.org/x/net/context"
 
 	"github.com/NYTimes/gizmo/config"
+	"github.com/NYTimes/gizmo/reporting"
 	"github.com/NYTimes/gizmo/web"
 	"github.com/NYTimes/logrotate"
 )
@@ -55,6 +57,17 @@ var (
 	// jsonContentType is the content type that will be used for JSONEndpoints.
 	// It will default to the web.JSONContentType value.
 	jsonContentType = web.JSONContentType
+	// gracefulRestart is used by Run to decide whether to Restart on a signal.
+	gracefulRestart bool
+	// profiler is used by Run to capture profiles on a signal.
+	profiler *ProfileCapture
+	// ErrorReporter is an optional hook that will be sent a report of
+	// any panic recovered while serving a request.
+	ErrorReporter reporting.Reporter
+	// MessageTracer is the optional handler served at the config's
+	// MessageTracePath. Importing the pubsubhttp package will set it to
+	// the pubsub.DefaultMessageTracer.
+	MessageTracer http.Handler
 )
 
 // Init will set up our name, logging, healthchecks and parse flags. If DefaultServer isn't set,
@@ -118,6 +131,14 @@ func Init(name string, scfg *config.Server) {
 	}
 	SetLogLevel(scfg)
 
+	gracefulRestart = scfg.GracefulRestart
+	if scfg.ProfileBucket != "" {
+		p, err := NewProfileCapture(scfg)
+		if err != nil {
+			Log.Fatal("unable to create profile capture: ", err)
+		}
+		profiler = p
+	}
 	server = NewServer(scfg)
 }
 
@@ -127,8 +148,19 @@ func Register(svc Service) error {
 }
 
 // Run will start the DefaultServer and set it up to Stop()
-// on a kill signal.
+// on a kill signal. If GracefulRestart is enabled, a SIGUSR2 will
+// Restart the process and Stop once in-flight requests complete.
+// If a ProfileBucket is configured, a SIGUSR1 will capture profiles
+// and upload them to S3.
 func Run() error {
+	return RunContext(netContext.Background())
+}
+
+// RunContext behaves like Run but will also Stop the DefaultServer once
+// ctx is done, returning the result of Stop. This allows the server to be
+// composed with other long-running components, such as a pubsub.Consumer,
+// that share a single shutdown.
+func RunContext(ctx netContext.Context) error {
 	Log.Infof("Starting new %s server", Name)
 	if err := server.Start(); err != nil {
 		return err
@@ -137,14 +169,54 @@ func Run() error {
 	// parse address for host, port
 	ch := make(chan os.Signal, 1)
 	signal.Notify(ch, syscall.SIGTERM, syscall.SIGINT)
-	Log.Infof("Received signal %s", <-ch)
-	return Stop()
+	if gracefulRestart && restartSignal != nil {
+		signal.Notify(ch, restartSignal)
+	}
+	if profiler != nil && profileSignal != nil {
+		signal.Notify(ch, profileSignal)
+	}
+	defer signal.Stop(ch)
+	for {
+		var sig os.Signal
+		select {
+		case sig = <-ch:
+		case <-ctx.Done():
+			return Stop()
+		}
+		Log.Infof("Received signal %s", sig)
+		if profileSignal != nil && sig == profileSignal {
+			profiler.captureOnSignal()
+			continue
+		}
+		if sig != restartSignal {
+			return Stop()
+		}
+
+		pid, err := Restart()
+		if err != nil {
+			Log.Error("unable to restart server: ", err)
+			continue
+		}
+		Log.Infof("Started new process %d, stopping", pid)
+		Log.Infof("Stopping %s server", Name)
+		err = server.Stop()
+		if a, ok := server.(interface {
+			activity() *ActivityMonitor
+		}); ok {
+			waitForIdle(a.activity(), RestartDrainTimeout)
+		}
+		runStopHooks()
+		return err
+	}
 }
 
-// Stop will stop the default server.
+// Stop will stop the default server and then call any hooks
+// registered with OnStop.
 func Stop() error {
 	Log.Infof("Stopping %s server", Name)
-	return server.Stop()
+	err := server.Stop()
+	runStopHooks()
+	return err
 }
 
 // LogWithFields will feed any request context into a logrus Entry.
@@ -174,6 +246,18 @@ func ContextFields(r *http.Request) map[string]interface{} {
 	return fields
 }
 
+// reportPanic will send a recovered panic to the ErrorReporter, if any,
+// along with the request being served.
+func reportPanic(x interface{}, stack []byte, r *http.Request) {
+	if ErrorReporter == nil {
+		return
+	}
+	report := reporting.PanicReport(x, stack)
+	report.Request = r
+	report.Tags = map[string]string{"server": Name}
+	ErrorReporter.Report(report)
+}
+
 // NewServer will inspect the config and generate
 // the appropriate Server implementation.
 func NewServer(cfg *config.Server) Server {
@@ -190,19 +274,37 @@ func NewServer(cfg *config.Server) Server {
 // NewHealthCheckHandler will inspect the config to generate
 // the appropriate HealthCheckHandler.
 func NewHealthCheckHandler(cfg *config.Server) HealthCheckHandler {
+	var hch HealthCheckHandler
 	switch cfg.HealthCheckType {
 	case "simple":
-		return NewSimpleHealthCheck(cfg.HealthCheckPath)
+		hch = NewSimpleHealthCheck(cfg.HealthCheckPath)
 	case "esx":
-		return NewESXHealthCheck()
+		hch = NewESXHealthCheck()
+	case "custom":
+		hch = NewCustomHealthCheck(cfg.HealthCheckPath, cfg.CustomHealthCheckHandler)
 	default:
-		return NewSimpleHealthCheck("/status.txt")
+		hch = NewSimpleHealthCheck("/status.txt")
+	}
+	if cfg.HealthCheckWarmup > 0 || cfg.LameDuckDuration > 0 || cfg.LameDuckPath != "" {
+		hch = NewLameDuckHealthCheck(hch, cfg.HealthCheckWarmup, cfg.LameDuckDuration)
 	}
+	return hch
 }
 
 // RegisterProfiler will add handlers for pprof endpoints if
-// the config has them enabled.
+// the config has them enabled and a handler for capturing
+// profiles to S3 if a ProfilePath is given.
 func RegisterProfiler(cfg *config.Server, mx Router) {
+	if cfg.ProfilePath != "" {
+		p := profiler
+		if p == nil {
+			var err error
+			if p, err = NewProfileCapture(cfg); err != nil {
+				Log.Fatal("unable to create profile capture: ", err)
+			}
+		}
+		mx.Handle("POST", cfg.ProfilePath, p)
+	}
 	if !cfg.EnablePProf {
 		return
 	}
@@ -218,6 +320,19 @@ func RegisterProfiler(cfg *config.Server, mx Router) {
 	mx.Handle("GET", "/debug/pprof/block", pprof.Handler("block"))
 }
 
+// RegisterMessageTracer will add a handler for the MessageTracer if the config
+// has a MessageTracePath.
+func RegisterMessageTracer(cfg *config.Server, mx Router) {
+	if cfg.MessageTracePath == "" {
+		return
+	}
+	if MessageTracer == nil {
+		Log.Warn("no MessageTracer to serve at ", cfg.MessageTracePath, ", import the pubsubhttp package to serve the pubsub.DefaultMessageTracer")
+		return
+	}
+	mx.Handle("GET", cfg.MessageTracePath, MessageTracer)
+}
+
 // RegisterHealthHandler will create a new HealthCheckHandler from the
 // given config and add a handler to the given router.
 func RegisterHealthHandler(cfg *config.Server, monitor *ActivityMonitor, mx Router) HealthCheckHandler {
@@ -227,7 +342,17 @@ func RegisterHealthHandler(cfg *config.Server, monitor *ActivityMonitor, mx Rout
 	if err != nil {
 		Log.Fatal("unable to start the HealthCheckHandler: ", err)
 	}
-	mx.Handle("GET", hch.Path(), hch)
+	if Notifier != nil {
+		mx.Handle("GET", hch.Path(), NotifyHealthChanges(hch, Notifier))
+	} else {
+		mx.Handle("GET", hch.Path(), hch)
+	}
+	if ld, ok := hch.(*LameDuckHealthCheck); ok && cfg.LameDuckPath != "" {
+		admin := ld.AdminHandler()
+		for _, method := range []string{"GET", "PUT", "POST", "DELETE"} {
+			mx.Handle(method, cfg.LameDuckPath, admin)
+		}
+	}
 	return hch
 }
 
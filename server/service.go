@@ -61,6 +61,24 @@ type RPCService interface {
 	JSONMiddleware(JSONEndpoint) JSONEndpoint
 }
 
+// ProxyService is an interface defining a service that forwards
+// its routes to upstream servers.
+type ProxyService interface {
+	Service
+
+	// route - method - upstream
+	ProxyEndpoints() map[string]map[string]*ProxyUpstream
+}
+
+// GraphQLService is an interface defining a service that serves a GraphQL
+// schema. It may also implement any of the other service interfaces to
+// serve REST endpoints alongside the schema.
+type GraphQLService interface {
+	Service
+
+	GraphQL() *GraphQLEndpoint
+}
+
 // JSONEndpoint is the JSONService equivalent to SimpleService's http.HandlerFunc.
 type JSONEndpoint func(*http.Request) (int, interface{}, error)
 
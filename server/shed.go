@@ -0,0 +1,164 @@
+package server
+
+import (
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+// Priority is a hint for how important it is to serve a route while the
+// server is overloaded.
+type Priority int
+
+const (
+	// PriorityLow routes will be shed first when the server is overloaded.
+	PriorityLow Priority = iota - 1
+	// PriorityNormal is the default priority for all routes.
+	PriorityNormal
+	// PriorityCritical routes will never be shed.
+	PriorityCritical
+)
+
+// PrioritizedService is an optional interface services can implement to
+// give their endpoints Priority hints for load shedding. Any endpoints
+// without a hint will be PriorityNormal.
+type PrioritizedService interface {
+	// route - method - priority
+	Priorities() map[string]map[string]Priority
+}
+
+// DefaultLoadShedInterval is how often a LoadShedder will check its
+// signals and adjust its shed rate.
+var DefaultLoadShedInterval = time.Second
+
+// LoadShedder will reject a growing percentage of low priority requests
+// while the p99 latency or CPU usage of the server is over a threshold and
+// back off once things recover.
+type LoadShedder struct {
+	// Latency is the p99 latency threshold. If 0, latency is not considered.
+	Latency time.Duration
+	// CPU is the CPU usage threshold as a fraction of all cores. If 0, CPU
+	// usage is not considered.
+	CPU float64
+	// CPUUsage returns the current CPU usage as a fraction of all cores.
+	CPUUsage func() float64
+	// Shed is the highest Priority that will be shed. Defaults to PriorityLow.
+	Shed Priority
+	// Interval is how often the signals are checked.
+	Interval time.Duration
+	// Step is how much the shed rate will change on each Interval.
+	Step float64
+	// MaxRate is the highest rate requests will be shed at.
+	MaxRate float64
+
+	mu      sync.Mutex
+	rate    float64
+	checked time.Time
+
+	latencies metrics.Histogram
+	rejected  metrics.Counter
+	rateGauge metrics.GaugeFloat64
+}
+
+// NewLoadShedder will return a LoadShedder with the given p99 latency and CPU
+// thresholds that will shed up to 90% of PriorityLow requests. The shed rate
+// and number of rejected requests will be tracked via go-metrics.
+func NewLoadShedder(latency time.Duration, cpu float64, registry metrics.Registry) *LoadShedder {
+	if nil == registry {
+		registry = metrics.DefaultRegistry
+	}
+	l := &LoadShedder{
+		Latency:   latency,
+		CPU:       cpu,
+		CPUUsage:  NewCPUSampler(),
+		Shed:      PriorityLow,
+		Interval:  DefaultLoadShedInterval,
+		Step:      0.1,
+		MaxRate:   0.9,
+		checked:   time.Now(),
+		latencies: metrics.NewHistogram(metrics.NewExpDecaySample(1028, 0.015)),
+		rejected:  metrics.NewCounter(),
+		rateGauge: metrics.NewGaugeFloat64(),
+	}
+	if err := registry.Register("shed.REJECTED", l.rejected); nil != err {
+		panic(err)
+	}
+	if err := registry.Register("shed.RATE", l.rateGauge); nil != err {
+		panic(err)
+	}
+	return l
+}
+
+// Handler returns an http.Handler that will shed requests to the underlying
+// http.Handler at the current rate if the given Priority is sheddable. The
+// latency of all requests it serves will feed the LoadShedder's p99.
+func (l *LoadShedder) Handler(h http.Handler, p Priority) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if rate := l.Rate(); p <= l.Shed && rate > 0 && rand.Float64() < rate {
+			l.rejected.Inc(1)
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			if _, err := w.Write(OverloadedError); err != nil {
+				LogWithFields(r).Warn("unable to write response: ", err)
+			}
+			return
+		}
+
+		start := time.Now()
+		h.ServeHTTP(w, r)
+		l.latencies.Update(int64(time.Since(start)))
+	})
+}
+
+// Rate will return the current fraction of sheddable requests being rejected.
+func (l *LoadShedder) Rate() float64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if time.Since(l.checked) >= l.Interval {
+		l.checked = time.Now()
+		l.adjust()
+	}
+	return l.rate
+}
+
+// adjust will step the shed rate up while overloaded and down otherwise.
+func (l *LoadShedder) adjust() {
+	var overloaded bool
+	if l.Latency > 0 && l.latencies.Count() > 0 {
+		overloaded = time.Duration(l.latencies.Percentile(0.99)) > l.Latency
+	}
+	if l.CPU > 0 && l.CPUUsage != nil && !overloaded {
+		overloaded = l.CPUUsage() > l.CPU
+	}
+
+	prev := l.rate
+	if overloaded {
+		l.rate += l.Step
+		if l.rate > l.MaxRate {
+			l.rate = l.MaxRate
+		}
+	} else {
+		l.rate -= l.Step
+		if l.rate < 0 {
+			l.rate = 0
+		}
+	}
+	l.rateGauge.Update(l.rate)
+
+	if prev == 0 && l.rate > 0 {
+		Log.Warn("server is overloaded, shedding low priority requests")
+	} else if prev > 0 && l.rate == 0 {
+		Log.Info("server has recovered, no longer shedding requests")
+	}
+}
+
+// withShedding will wrap the handler with the server's LoadShedder if it has one.
+func (s *SimpleServer) withShedding(p Priority, h http.Handler) http.Handler {
+	if s.shedder == nil {
+		return h
+	}
+	return s.shedder.Handler(h, p)
+}
@@ -0,0 +1,89 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+func TestLoadShedder(t *testing.T) {
+	tests := []struct {
+		latency  time.Duration
+		cpu      float64
+		observed time.Duration
+		usage    float64
+
+		wantShed bool
+	}{
+		{0, 0.5, 0, 0.9, true},
+		{0, 0.5, 0, 0.2, false},
+		{100 * time.Millisecond, 0, time.Second, 0, true},
+		{100 * time.Millisecond, 0, time.Millisecond, 0, false},
+		{100 * time.Millisecond, 0.5, time.Millisecond, 0.2, false},
+	}
+
+	for i, test := range tests {
+		l := NewLoadShedder(test.latency, test.cpu, metrics.NewRegistry())
+		l.Interval = 0
+		l.CPUUsage = func() float64 { return test.usage }
+		l.latencies.Update(int64(test.observed))
+
+		h := func(p Priority) http.Handler {
+			return l.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}), p)
+		}
+		codes := map[Priority]map[int]int{}
+		for _, p := range []Priority{PriorityLow, PriorityNormal, PriorityCritical} {
+			codes[p] = map[int]int{}
+			for j := 0; j < 100; j++ {
+				w := httptest.NewRecorder()
+				r, _ := http.NewRequest("GET", "/", nil)
+				h(p).ServeHTTP(w, r)
+				codes[p][w.Code]++
+			}
+		}
+
+		shed := codes[PriorityLow][http.StatusServiceUnavailable]
+		if test.wantShed && shed == 0 {
+			t.Errorf("test %d: expected low priority requests to be shed, got none", i)
+		}
+		if !test.wantShed && shed > 0 {
+			t.Errorf("test %d: expected no requests to be shed, got %d", i, shed)
+		}
+		if shed == 100 {
+			t.Errorf("test %d: expected some low priority requests to get through, got none", i)
+		}
+		for _, p := range []Priority{PriorityNormal, PriorityCritical} {
+			if got := codes[p][http.StatusOK]; got != 100 {
+				t.Errorf("test %d: expected all priority %d requests to be served, got %d", i, p, got)
+			}
+		}
+		if got := l.rejected.Count(); got != int64(shed) {
+			t.Errorf("test %d: expected rejected count of %d, got %d", i, shed, got)
+		}
+	}
+}
+
+func TestLoadShedderRecovery(t *testing.T) {
+	l := NewLoadShedder(0, 0.5, metrics.NewRegistry())
+	l.Interval = 0
+	usage := 0.9
+	l.CPUUsage = func() float64 { return usage }
+
+	for i := 0; i < 20; i++ {
+		l.Rate()
+	}
+	if got := l.Rate(); got != l.MaxRate {
+		t.Errorf("expected rate to reach max of %f, got %f", l.MaxRate, got)
+	}
+
+	usage = 0.1
+	for i := 0; i < 20; i++ {
+		l.Rate()
+	}
+	if got := l.Rate(); got != 0 {
+		t.Errorf("expected rate to recover to 0, got %f", got)
+	}
+}
@@ -0,0 +1,16 @@
+// +build !windows
+
+package server
+
+import (
+	"os"
+	"syscall"
+)
+
+// restartSignal is the signal that will trigger a Restart in Run
+// when GracefulRestart is enabled.
+var restartSignal os.Signal = syscall.SIGUSR2
+
+// profileSignal is the signal that will trigger a profile capture
+// in Run when a ProfileBucket is configured.
+var profileSignal os.Signal = syscall.SIGUSR1
@@ -0,0 +1,11 @@
+package server
+
+import "os"
+
+// restartSignal is nil as listeners cannot be passed between
+// processes on Windows.
+var restartSignal os.Signal
+
+// profileSignal is nil as Windows has no SIGUSR1. Profiles can
+// still be captured via the ProfilePath admin endpoint.
+var profileSignal os.Signal
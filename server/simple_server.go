@@ -1,7 +1,6 @@
 package server
 
 import (
-	"crypto/tls"
 	"errors"
 	"fmt"
 	"net"
@@ -17,7 +16,8 @@ import (
 )
 
 // SimpleServer is a basic http Server implementation for
-// serving SimpleService, JSONService or MixedService implementations.
+// serving SimpleService, JSONService, MixedService, ContextService or
+// ProxyService implementations.
 type SimpleServer struct {
 	cfg *config.Server
 
@@ -35,6 +35,18 @@ type SimpleServer struct {
 
 	// registry for collecting metrics
 	registry metrics.Registry
+
+	// routes registered by services
+	routes []Route
+
+	// optional limit on concurrent requests
+	limiter *ConcurrencyLimiter
+
+	// number of active requests
+	inFlight metrics.Gauge
+
+	// optional shedding of low priority requests
+	shedder *LoadShedder
 }
 
 // NewSimpleServer will init the mux, exit channel and
@@ -52,24 +64,43 @@ func NewSimpleServer(cfg *config.Server) *SimpleServer {
 	if registry == nil {
 		registry = metrics.NewRegistry()
 	}
-	return &SimpleServer{
+	s := &SimpleServer{
 		mux:      mx,
 		cfg:      cfg,
 		exit:     make(chan chan error),
 		monitor:  NewActivityMonitor(),
 		ctx:      netContext.Background(),
 		registry: registry,
+		inFlight: metrics.GetOrRegisterGauge("requests.INFLIGHT", registry),
 	}
+	if cfg.MaxConcurrentRequests > 0 {
+		s.limiter = ConcurrencyLimited(http.HandlerFunc(s.safelyExecuteRequest),
+			cfg.MaxConcurrentRequests, cfg.MaxQueuedRequests, cfg.MaxQueueWait,
+			"requests", registry)
+	}
+	if cfg.LoadShedLatency > 0 || cfg.LoadShedCPU > 0 {
+		s.shedder = NewLoadShedder(cfg.LoadShedLatency, cfg.LoadShedCPU, registry)
+	}
+	return s
 }
 
 // ServeHTTP is SimpleServer's hook for metrics and safely executing each request.
 func (s *SimpleServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	AddIPToContext(r)
 
-	// only count non-LB requests
+	// only count and limit non-LB requests
 	if r.URL.Path != s.cfg.HealthCheckPath {
 		s.monitor.CountRequest()
-		defer s.monitor.UncountRequest()
+		s.inFlight.Update(int64(s.monitor.NumActiveRequests()))
+		defer func() {
+			s.monitor.UncountRequest()
+			s.inFlight.Update(int64(s.monitor.NumActiveRequests()))
+		}()
+
+		if s.limiter != nil {
+			s.limiter.ServeHTTP(w, r)
+			return
+		}
 	}
 
 	s.safelyExecuteRequest(w, r)
@@ -89,7 +120,9 @@ func (s *SimpleServer) safelyExecuteRequest(w http.ResponseWriter, r *http.Reque
 			errCntr.Inc(1)
 
 			// log the panic for all the details later
-			LogWithFields(r).Errorf("simple server recovered from a panic\n%v: %v", x, string(debug.Stack()))
+			stack := debug.Stack()
+			LogWithFields(r).Errorf("simple server recovered from a panic\n%v: %v", x, string(stack))
+			reportPanic(x, stack, r)
 
 			// give the users our deepest regrets
 			w.WriteHeader(http.StatusInternalServerError)
@@ -105,7 +138,7 @@ func (s *SimpleServer) safelyExecuteRequest(w http.ResponseWriter, r *http.Reque
 
 // Start will start the SimpleServer at it's configured address.
 // If they are configured, this will start emitting metrics to Graphite,
-// register profiling, health checks and access logging.
+// register profiling, health checks, OpenAPI docs and access logging.
 func (s *SimpleServer) Start() error {
 
 	StartServerMetrics(s.cfg, s.registry)
@@ -113,6 +146,13 @@ func (s *SimpleServer) Start() error {
 	healthHandler := RegisterHealthHandler(s.cfg, s.monitor, s.mux)
 	s.cfg.HealthCheckPath = healthHandler.Path()
 
+	RegisterOpenAPI(s.cfg, s.Routes, s.mux)
+
+	// admin endpoints are registered once here rather than with each
+	// service, since routers like httprouter panic on duplicate routes
+	RegisterProfiler(s.cfg, s.mux)
+	RegisterMessageTracer(s.cfg, s.mux)
+
 	srv := http.Server{
 		Handler:        RegisterAccessLogger(s.cfg, s),
 		MaxHeaderBytes: maxHeaderBytes,
@@ -120,34 +160,20 @@ func (s *SimpleServer) Start() error {
 		WriteTimeout:   writeTimeout,
 	}
 
-	l, err := net.Listen("tcp", fmt.Sprintf(":%d", s.cfg.HTTPPort))
+	listeners, err := httpListeners(s.cfg, s.registry)
 	if err != nil {
 		return err
 	}
 
-	l = net.Listener(TCPKeepAliveListener{l.(*net.TCPListener)})
-
-	// add TLS if in the configs
-	if s.cfg.TLSCertFile != nil && s.cfg.TLSKeyFile != nil {
-		cert, err := tls.LoadX509KeyPair(*s.cfg.TLSCertFile, *s.cfg.TLSKeyFile)
-		if err != nil {
-			return err
-		}
-		srv.TLSConfig = &tls.Config{
-			Certificates: []tls.Certificate{cert},
-			NextProtos:   []string{"http/1.1"},
-		}
-
-		l = tls.NewListener(l, srv.TLSConfig)
+	for _, l := range listeners {
+		go func(l net.Listener) {
+			if err := srv.Serve(l); err != nil {
+				Log.Error("encountered an error while serving listener: ", err)
+			}
+		}(l)
+		Log.Infof("Listening on %s", l.Addr().String())
 	}
 
-	go func() {
-		if err := srv.Serve(l); err != nil {
-			Log.Error("encountered an error while serving listener: ", err)
-		}
-	}()
-	Log.Infof("Listening on %s", l.Addr().String())
-
 	// join the LB
 	go func() {
 		exit := <-s.exit
@@ -157,8 +183,14 @@ func (s *SimpleServer) Start() error {
 			Log.Warn("health check Stop returned with error: ", err)
 		}
 
-		// stop the listener
-		exit <- l.Close()
+		// stop the listeners
+		var err error
+		for _, l := range listeners {
+			if cerr := l.Close(); cerr != nil && err == nil {
+				err = cerr
+			}
+		}
+		exit <- err
 	}()
 
 	return nil
@@ -172,6 +204,10 @@ func (s *SimpleServer) Stop() error {
 	return <-ch
 }
 
+func (s *SimpleServer) activity() *ActivityMonitor {
+	return s.monitor
+}
+
 func metricName(prefix, path, method string) string {
 	// combine and trim prefix
 	fullpath := strings.TrimPrefix(prefix+path, "/")
@@ -182,16 +218,57 @@ func metricName(prefix, path, method string) string {
 	return fmt.Sprintf("routes.%s-%s", fullpath, method)
 }
 
-// Register will accept and register SimpleServer, JSONService or MixedService implementations.
+// Register will accept and register SimpleService, JSONService, MixedService,
+// ContextService or ProxyService implementations. If the service also implements
+// VersionedService, it will be registered once for each of its APIVersions.
 func (s *SimpleServer) Register(svcI Service) error {
-	prefix := svcI.Prefix()
+	return s.registerGroup(svcI, "", nil)
+}
+
+// registerGroup will register the service under the given group prefix
+// with the group middleware wrapped around each endpoint.
+func (s *SimpleServer) registerGroup(svcI Service, group string, mws []func(http.Handler) http.Handler) error {
 	// quick fix for backwards compatibility
-	prefix = strings.TrimRight(prefix, "/")
+	prefix := group + strings.TrimRight(svcI.Prefix(), "/")
+
+	if vs, ok := svcI.(VersionedService); ok {
+		versions := vs.Versions()
+		for _, v := range versions {
+			if v.Name == "" {
+				return errors.New("versioned services must provide a Name for each APIVersion")
+			}
+			if err := s.register(svcI, prefix+"/"+v.Name, chain(mws, v.Middleware)); err != nil {
+				return err
+			}
+		}
+		// the default version is also available under the bare prefix
+		// and may be overridden by a request header
+		if def := defaultVersion(versions); def != nil {
+			if err := s.register(svcI, prefix, chain(mws, versionSelector(versions, def))); err != nil {
+				return err
+			}
+		}
+	} else if err := s.register(svcI, prefix, chain(mws, nil)); err != nil {
+		return err
+	}
+
+	s.routes = append(s.routes, groupRoutes(svcI, group)...)
+	return nil
+}
+
+// register will add all endpoints of the service to the router under the
+// given prefix. If wrap is not nil, it will be the outermost middleware of
+// each endpoint.
+func (s *SimpleServer) register(svcI Service, prefix string, wrap func(http.Handler) http.Handler) error {
+	if wrap == nil {
+		wrap = func(h http.Handler) http.Handler { return h }
+	}
 
 	var (
 		js JSONService
 		ss SimpleService
 		cs ContextService
+		ps ProxyService
 	)
 
 	switch svc := svcI.(type) {
@@ -204,8 +281,21 @@ func (s *SimpleServer) Register(svcI Service) error {
 		js = svc
 	case ContextService:
 		cs = svc
+	case ProxyService:
+		ps = svc
+	case GraphQLService:
 	default:
-		return errors.New("services for SimpleServers must implement the SimpleService, JSONService or MixedService interfaces")
+		return errors.New("services for SimpleServers must implement the SimpleService, JSONService, MixedService, ContextService, ProxyService or GraphQLService interfaces")
+	}
+	gs, _ := svcI.(GraphQLService)
+
+	var slos map[string]map[string]*SLO
+	if sv, ok := svcI.(SLOService); ok {
+		slos = sv.SLOs()
+	}
+	var priorities map[string]map[string]Priority
+	if pv, ok := svcI.(PrioritizedService); ok {
+		priorities = pv.Priorities()
 	}
 
 	if ss != nil {
@@ -215,7 +305,7 @@ func (s *SimpleServer) Register(svcI Service) error {
 				endpointName := metricName(prefix, path, method)
 				// set the function handle and register it to metrics
 				s.mux.Handle(method, prefix+path, Timed(CountedByStatusXX(
-					func(ep http.HandlerFunc, ss SimpleService) http.Handler {
+					s.withShedding(priorities[path][method], s.withSLO(slos[path][method], endpointName, wrap(func(ep http.HandlerFunc, ss SimpleService) http.Handler {
 						return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 							// is it worth it to always close this?
 							if r.Body != nil {
@@ -229,7 +319,7 @@ func (s *SimpleServer) Register(svcI Service) error {
 							// call the func and return err or not
 							ss.Middleware(ep).ServeHTTP(w, r)
 						})
-					}(ep, ss),
+					}(ep, ss)))),
 					endpointName+".STATUS-COUNT", s.registry),
 					endpointName+".DURATION", s.registry),
 				)
@@ -244,7 +334,7 @@ func (s *SimpleServer) Register(svcI Service) error {
 				endpointName := metricName(prefix, path, method)
 				// set the function handle and register it to metrics
 				s.mux.Handle(method, prefix+path, Timed(CountedByStatusXX(
-					js.Middleware(JSONToHTTP(js.JSONMiddleware(ep))),
+					s.withShedding(priorities[path][method], s.withSLO(slos[path][method], endpointName, wrap(js.Middleware(JSONToHTTP(js.JSONMiddleware(ep)))))),
 					endpointName+".STATUS-COUNT", s.registry),
 					endpointName+".DURATION", s.registry),
 				)
@@ -259,7 +349,7 @@ func (s *SimpleServer) Register(svcI Service) error {
 				endpointName := metricName(prefix, path, method)
 				// set the function handle and register it to metrics
 				s.mux.Handle(method, prefix+path, Timed(CountedByStatusXX(
-					func(ep ContextHandlerFunc, cs ContextService) http.Handler {
+					s.withShedding(priorities[path][method], s.withSLO(slos[path][method], endpointName, wrap(func(ep ContextHandlerFunc, cs ContextService) http.Handler {
 						return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 							// is it worth it to always close this?
 							if r.Body != nil {
@@ -273,7 +363,7 @@ func (s *SimpleServer) Register(svcI Service) error {
 							// call the func and return err or not
 							cs.Middleware(ContextToHTTP(ctx, cs.ContextMiddleware(ep))).ServeHTTP(w, r)
 						})
-					}(ep, cs),
+					}(ep, cs)))),
 					endpointName+".STATUS-COUNT", s.registry),
 					endpointName+".DURATION", s.registry),
 				)
@@ -281,10 +371,47 @@ func (s *SimpleServer) Register(svcI Service) error {
 		}
 	}
 
-	RegisterProfiler(s.cfg, s.mux)
+	if ps != nil {
+		// register all proxy endpoints with our wrapper
+		for path, upMethods := range ps.ProxyEndpoints() {
+			for method, up := range upMethods {
+				endpointName := metricName(prefix, path, method)
+				// set the function handle and register it to metrics
+				s.mux.Handle(method, prefix+path, Timed(CountedByStatusXX(
+					s.withShedding(priorities[path][method], s.withSLO(slos[path][method], endpointName, wrap(ps.Middleware(NewProxyHandler(up))))),
+					endpointName+".STATUS-COUNT", s.registry),
+					endpointName+".DURATION", s.registry),
+				)
+			}
+		}
+	}
+
+	if gs != nil {
+		// register the GraphQL endpoint for queries in GETs and POSTs
+		ep := gs.GraphQL()
+		path := ep.path()
+		for _, method := range []string{"GET", "POST"} {
+			endpointName := metricName(prefix, path, method)
+			s.mux.Handle(method, prefix+path, Timed(CountedByStatusXX(
+				s.withShedding(priorities[path][method], s.withSLO(slos[path][method], endpointName, wrap(gs.Middleware(NewGraphQLHandler(ep))))),
+				endpointName+".STATUS-COUNT", s.registry),
+				endpointName+".DURATION", s.registry),
+			)
+		}
+		if s.cfg.EnableGraphQLPlayground {
+			s.mux.Handle("GET", prefix+path+GraphQLPlaygroundPath, wrap(GraphQLPlaygroundHandler(prefix+path)))
+		}
+	}
+
 	return nil
 }
 
+// Routes will return the routes of all services registered
+// with the server so far.
+func (s *SimpleServer) Routes() []Route {
+	return s.routes
+}
+
 // AddIPToContext will attempt to pull an IP address out of the request and
 // set it into a gorilla context.
 func AddIPToContext(r *http.Request) {
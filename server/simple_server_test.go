@@ -9,6 +9,7 @@ import (
 	"testing"
 
 	"github.com/NYTimes/gizmo/config"
+	"github.com/NYTimes/gizmo/reporting"
 	"github.com/NYTimes/gizmo/web"
 	"github.com/rcrowley/go-metrics"
 	"golang.org/x/net/context"
@@ -436,3 +437,41 @@ func TestCustomMetricsRegistry(t *testing.T) {
 		t.Error("Custom metrics registry is failed to register within simple server")
 	}
 }
+
+func TestSimpleServerReportsPanics(t *testing.T) {
+	var reports []*reporting.Report
+	ErrorReporter = reporting.ReporterFunc(func(r *reporting.Report) {
+		reports = append(reports, r)
+	})
+	defer func() { ErrorReporter = nil }()
+
+	srvr := NewSimpleServer(nil)
+	srvr.mux.Handle("GET", "/panic", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("oh no")
+	}))
+
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("GET", "/panic", nil)
+	r.RemoteAddr = "0.0.0.0:8080"
+	srvr.ServeHTTP(w, r)
+
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("expected a 500, got %d", w.Code)
+	}
+	if len(reports) != 1 {
+		t.Fatalf("expected 1 report, got %d", len(reports))
+	}
+	report := reports[0]
+	if !report.Panic || report.Err.Error() != "oh no" {
+		t.Errorf("expected a report of the panic, got %#v", report)
+	}
+	if report.Request != r {
+		t.Error("expected the report to include the request")
+	}
+	if report.Tags["server"] != Name {
+		t.Errorf("expected server tag '%s', got '%s'", Name, report.Tags["server"])
+	}
+	if len(report.Stack) == 0 {
+		t.Error("expected the report to include a stack trace")
+	}
+}
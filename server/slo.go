@@ -0,0 +1,90 @@
+package server
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+// SLO is a latency objective for a single route, ie 99% of requests
+// should complete within 250ms.
+type SLO struct {
+	// Latency is the duration requests should complete within.
+	Latency time.Duration
+	// Target is the fraction of requests that should complete within
+	// Latency, ie 0.99 for a p99 objective.
+	Target float64
+}
+
+// SLOService is an optional interface that services can implement to declare
+// latency objectives for their routes. Each route with an SLO will emit
+// '.SLO-TOTAL' and '.SLO-BREACH' counters alongside its other metrics and
+// log a warning for every request that breaches its SLO. The SLO Target
+// is also emitted as a '.SLO-TARGET' gauge.
+//
+// The ratio of breaches to total requests divided by the allowed error
+// budget (1 - Target) is the SLO burn rate.
+type SLOService interface {
+	// route - method - SLO
+	SLOs() map[string]map[string]*SLO
+}
+
+// SLOTracker is an http.Handler that counts requests that do
+// not complete within their SLO's latency.
+type SLOTracker struct {
+	slo      *SLO
+	total    metrics.Counter
+	breaches metrics.Counter
+	handler  http.Handler
+}
+
+// SLOTracked returns an http.Handler that passes requests to an underlying
+// http.Handler and counts the requests that have breached the given SLO via
+// go-metrics.
+func SLOTracked(handler http.Handler, slo *SLO, name string, registry metrics.Registry) *SLOTracker {
+	if nil == registry {
+		registry = metrics.DefaultRegistry
+	}
+	t := &SLOTracker{
+		slo:      slo,
+		total:    metrics.NewCounter(),
+		breaches: metrics.NewCounter(),
+		handler:  handler,
+	}
+	if err := registry.Register(name+".SLO-TOTAL", t.total); nil != err {
+		panic(err)
+	}
+	if err := registry.Register(name+".SLO-BREACH", t.breaches); nil != err {
+		panic(err)
+	}
+	target := metrics.NewGaugeFloat64()
+	target.Update(slo.Target)
+	if err := registry.Register(name+".SLO-TARGET", target); nil != err {
+		panic(err)
+	}
+	return t
+}
+
+// ServeHTTP passes the request to the underlying http.Handler and then counts
+// and logs the request if it took longer than the SLO latency.
+func (t *SLOTracker) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	t.handler.ServeHTTP(w, r)
+	took := time.Since(start)
+
+	t.total.Inc(1)
+	if took > t.slo.Latency {
+		t.breaches.Inc(1)
+		LogWithFields(r).Warnf("slow request: %s %s took %s, SLO latency is %s",
+			r.Method, r.URL.Path, took, t.slo.Latency)
+	}
+}
+
+// withSLO will wrap the handler in an SLOTracker if an SLO is given.
+func (s *SimpleServer) withSLO(slo *SLO, endpointName string, h http.Handler) http.Handler {
+	if slo == nil {
+		return h
+	}
+	return SLOTracked(h, slo, endpointName, s.registry)
+}
@@ -0,0 +1,74 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+type testSLOService struct {
+	testMixedService
+}
+
+func (s *testSLOService) Endpoints() map[string]map[string]http.HandlerFunc {
+	return map[string]map[string]http.HandlerFunc{
+		"/slow": map[string]http.HandlerFunc{
+			"GET": func(w http.ResponseWriter, r *http.Request) {
+				time.Sleep(5 * time.Millisecond)
+			},
+		},
+	}
+}
+
+func (s *testSLOService) SLOs() map[string]map[string]*SLO {
+	return map[string]map[string]*SLO{
+		"/slow": map[string]*SLO{
+			"GET": &SLO{Latency: time.Millisecond, Target: 0.99},
+		},
+		"/json": map[string]*SLO{
+			"GET": &SLO{Latency: time.Minute, Target: 0.99},
+		},
+	}
+}
+
+func TestSLOService(t *testing.T) {
+	srvr := NewSimpleServer(nil)
+	if err := srvr.Register(&testSLOService{}); err != nil {
+		t.Fatal("unable to register service: ", err)
+	}
+
+	tests := []struct {
+		path string
+
+		wantBreaches int64
+	}{
+		{"/svc/v1/slow", 1},
+		{"/svc/v1/json", 0},
+	}
+
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest("GET", test.path, nil)
+		srvr.ServeHTTP(w, r)
+
+		name := metricName("", test.path, "GET")
+		total, ok := srvr.registry.Get(name + ".SLO-TOTAL").(metrics.Counter)
+		if !ok {
+			t.Fatalf("%s expected an SLO-TOTAL counter", test.path)
+		}
+		if total.Count() != 1 {
+			t.Errorf("%s expected SLO total of 1, got %d", test.path, total.Count())
+		}
+		breaches := srvr.registry.Get(name + ".SLO-BREACH").(metrics.Counter)
+		if breaches.Count() != test.wantBreaches {
+			t.Errorf("%s expected SLO breaches of %d, got %d", test.path, test.wantBreaches, breaches.Count())
+		}
+		target := srvr.registry.Get(name + ".SLO-TARGET").(metrics.GaugeFloat64)
+		if target.Value() != 0.99 {
+			t.Errorf("%s expected SLO target of 0.99, got %f", test.path, target.Value())
+		}
+	}
+}
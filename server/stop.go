@@ -0,0 +1,32 @@
+package server
+
+import "sync"
+
+var (
+	stopHooksMu sync.Mutex
+	stopHooks   []func() error
+)
+
+// OnStop will register a func to be called when the default server stops,
+// once in-flight requests have completed. It is meant for draining
+// resources that must not lose data at exit, like a
+// pubsub.BufferedPublisher's Close. Hooks are called in the reverse order
+// they were registered and any errors are logged.
+func OnStop(fn func() error) {
+	stopHooksMu.Lock()
+	stopHooks = append(stopHooks, fn)
+	stopHooksMu.Unlock()
+}
+
+// runStopHooks will call and clear all registered stop hooks.
+func runStopHooks() {
+	stopHooksMu.Lock()
+	hooks := stopHooks
+	stopHooks = nil
+	stopHooksMu.Unlock()
+	for i := len(hooks) - 1; i >= 0; i-- {
+		if err := hooks[i](); err != nil {
+			Log.Error("stop hook failed: ", err)
+		}
+	}
+}
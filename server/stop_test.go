@@ -0,0 +1,29 @@
+package server
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestRunStopHooks(t *testing.T) {
+	var order []int
+	OnStop(func() error {
+		order = append(order, 1)
+		return nil
+	})
+	OnStop(func() error {
+		order = append(order, 2)
+		return errors.New("still runs the rest")
+	})
+
+	runStopHooks()
+	if len(order) != 2 || order[0] != 2 || order[1] != 1 {
+		t.Errorf("expected hooks to run in reverse order, got %v", order)
+	}
+
+	// hooks should only run once
+	runStopHooks()
+	if len(order) != 2 {
+		t.Errorf("expected hooks to be cleared, got %v", order)
+	}
+}
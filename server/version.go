@@ -0,0 +1,122 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/context"
+)
+
+// VersionHeader is the request header that can be used to select an
+// APIVersion on a VersionedService's bare prefix. It will also be set on
+// every response from a VersionedService.
+var VersionHeader = "X-API-Version"
+
+// APIVersion describes a version of a VersionedService's API.
+type APIVersion struct {
+	// Name will be appended to the service's prefix for all routes of the
+	// version, ie "v1" for "/svc/cats/v1".
+	Name string
+	// Default will make the version available under the service's bare
+	// prefix when no other version is requested via the VersionHeader.
+	Default bool
+	// Deprecated will add a 'Deprecation' header to all responses
+	// and mark the version's routes as deprecated.
+	Deprecated bool
+	// Sunset is when the version will no longer be available. If set,
+	// it will be added to all responses in a 'Sunset' header.
+	Sunset time.Time
+	// Link is an optional URL with information about the deprecation
+	// or a migration guide. It will be added to all responses of a
+	// deprecated version in a 'Link' header.
+	Link string
+}
+
+// VersionedService is an optional interface a service can implement to have
+// the same endpoints registered under multiple API versions. The version of
+// a request can be retrieved in the handlers with GetAPIVersion.
+type VersionedService interface {
+	Service
+
+	Versions() []*APIVersion
+}
+
+// Middleware will set the version into the request context and add
+// any version, deprecation and sunset headers to the response.
+func (v *APIVersion) Middleware(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		context.Set(r, "api-version", v.Name)
+		w.Header().Set(VersionHeader, v.Name)
+		if v.Deprecated {
+			w.Header().Set("Deprecation", "true")
+			if v.Link != "" {
+				w.Header().Add("Link", fmt.Sprintf("<%s>; rel=\"deprecation\"", v.Link))
+			}
+		}
+		if !v.Sunset.IsZero() {
+			w.Header().Set("Sunset", v.Sunset.UTC().Format(http.TimeFormat))
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// routes will mark the given routes as deprecated if the version is.
+func (v *APIVersion) routes(routes []Route) []Route {
+	if !v.Deprecated {
+		return routes
+	}
+	for i, route := range routes {
+		doc := &EndpointDoc{}
+		if route.Doc != nil {
+			*doc = *route.Doc
+		}
+		doc.Deprecated = true
+		routes[i].Doc = doc
+	}
+	return routes
+}
+
+// GetAPIVersion will return the name of the APIVersion the request was
+// served under. If the request was not served by a VersionedService, an
+// empty string is returned.
+func GetAPIVersion(r *http.Request) string {
+	if v, ok := context.Get(r, "api-version").(string); ok {
+		return v
+	}
+	return ""
+}
+
+func defaultVersion(versions []*APIVersion) *APIVersion {
+	for _, v := range versions {
+		if v.Default {
+			return v
+		}
+	}
+	return nil
+}
+
+// versionSelector will serve the version named in the request's
+// VersionHeader or the default version if none is given.
+func versionSelector(versions []*APIVersion, def *APIVersion) func(http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		handlers := map[string]http.Handler{}
+		for _, v := range versions {
+			handlers[v.Name] = v.Middleware(h)
+		}
+		dh := def.Middleware(h)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			name := r.Header.Get(VersionHeader)
+			if name == "" {
+				dh.ServeHTTP(w, r)
+				return
+			}
+			vh, ok := handlers[name]
+			if !ok {
+				http.Error(w, fmt.Sprintf("unknown API version %q", name), http.StatusBadRequest)
+				return
+			}
+			vh.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,109 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/NYTimes/gizmo/config"
+)
+
+type testVersionedService struct {
+	sunset time.Time
+}
+
+func (s *testVersionedService) Prefix() string {
+	return "/svc/cats"
+}
+
+func (s *testVersionedService) Versions() []*APIVersion {
+	return []*APIVersion{
+		&APIVersion{Name: "v1", Deprecated: true, Sunset: s.sunset, Link: "http://example.com/v2"},
+		&APIVersion{Name: "v2", Default: true},
+	}
+}
+
+func (s *testVersionedService) Endpoints() map[string]map[string]http.HandlerFunc {
+	return map[string]map[string]http.HandlerFunc{
+		"/version": map[string]http.HandlerFunc{
+			"GET": func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(GetAPIVersion(r)))
+			},
+		},
+	}
+}
+
+func (s *testVersionedService) Middleware(h http.Handler) http.Handler {
+	return h
+}
+
+func TestVersionedService(t *testing.T) {
+	sunset := time.Date(2030, time.January, 1, 0, 0, 0, 0, time.UTC)
+	srvr := NewSimpleServer(nil)
+	if err := srvr.Register(&testVersionedService{sunset}); err != nil {
+		t.Fatal("unable to register versioned service: ", err)
+	}
+
+	tests := []struct {
+		path   string
+		header string
+
+		wantCode        int
+		wantBody        string
+		wantDeprecation string
+		wantSunset      string
+	}{
+		{"/svc/cats/v1/version", "", http.StatusOK, "v1", "true", "Tue, 01 Jan 2030 00:00:00 GMT"},
+		{"/svc/cats/v2/version", "", http.StatusOK, "v2", "", ""},
+		{"/svc/cats/version", "", http.StatusOK, "v2", "", ""},
+		{"/svc/cats/version", "v1", http.StatusOK, "v1", "true", "Tue, 01 Jan 2030 00:00:00 GMT"},
+		{"/svc/cats/version", "v3", http.StatusBadRequest, "", "", ""},
+	}
+
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest("GET", test.path, nil)
+		if test.header != "" {
+			r.Header.Set(VersionHeader, test.header)
+		}
+		srvr.ServeHTTP(w, r)
+
+		if w.Code != test.wantCode {
+			t.Errorf("%s expected status code of %d, got %d", test.path, test.wantCode, w.Code)
+		}
+		if test.wantCode != http.StatusOK {
+			continue
+		}
+		if got := w.Body.String(); got != test.wantBody {
+			t.Errorf("%s expected body of %q, got %q", test.path, test.wantBody, got)
+		}
+		if got := w.Header().Get(VersionHeader); got != test.wantBody {
+			t.Errorf("%s expected version header of %q, got %q", test.path, test.wantBody, got)
+		}
+		if got := w.Header().Get("Deprecation"); got != test.wantDeprecation {
+			t.Errorf("%s expected deprecation header of %q, got %q", test.path, test.wantDeprecation, got)
+		}
+		if got := w.Header().Get("Sunset"); got != test.wantSunset {
+			t.Errorf("%s expected sunset header of %q, got %q", test.path, test.wantSunset, got)
+		}
+	}
+
+	routes := srvr.Routes()
+	if len(routes) != 3 {
+		t.Fatalf("expected 3 routes, got %d", len(routes))
+	}
+	if routes[0].Path != "/svc/cats/v1/version" || routes[0].Doc == nil || !routes[0].Doc.Deprecated {
+		t.Errorf("expected v1 route to be deprecated, got %#v", routes[0])
+	}
+}
+
+func TestVersionedServiceWithPProf(t *testing.T) {
+	// registering each version must not add the admin endpoints again,
+	// which httprouter would panic on
+	cfg := &config.Server{RouterType: "fast", EnablePProf: true, ProfilePath: "/debug/profile"}
+	srvr := NewSimpleServer(cfg)
+	if err := srvr.Register(&testVersionedService{}); err != nil {
+		t.Fatal("unable to register versioned service: ", err)
+	}
+}
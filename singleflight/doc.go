@@ -0,0 +1,25 @@
+/*
+Package singleflight coalesces identical concurrent fetches inside handlers
+and consumers, so a burst of requests for the same missing or expired key
+results in a single call to the upstream rather than a thundering herd.
+
+A Group deduplicates calls in flight. Each caller can give up on its own
+context without cancelling the shared call for the others:
+
+	var group singleflight.Group
+
+	func (s *Service) GetCat(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	    id := web.Vars(r)["id"]
+	    cat, _, err := group.Do(ctx, id, func(ctx context.Context) (interface{}, error) {
+	        return s.fetchCat(ctx, id)
+	    })
+	    ...
+	}
+
+A StaleGroup also remembers results, serving them stale while a single
+background call refreshes them:
+
+	cats := singleflight.NewStaleGroup(time.Minute, 10*time.Minute)
+	cat, err := cats.Do(ctx, id, fetchCat)
+*/
+package singleflight
@@ -0,0 +1,88 @@
+package singleflight
+
+import (
+	"sync"
+
+	"golang.org/x/net/context"
+)
+
+// Group deduplicates concurrent calls for the same key, so only one of
+// them does the work and the rest share its result.
+type Group struct {
+	mu    sync.Mutex
+	calls map[string]*call
+}
+
+type call struct {
+	done   chan struct{}
+	val    interface{}
+	err    error
+	dups   int
+	cancel context.CancelFunc
+	// number of callers still waiting for the result
+	waiters int
+}
+
+// Do will call fn for the key unless a call for the key is already in
+// flight, in which case it will wait for and return that call's result.
+// The returned bool reports if the result was shared with other callers.
+//
+// If ctx is done before the result is ready, Do will return ctx.Err()
+// without waiting. The context given to fn is not tied to any one caller
+// and will only be cancelled once every caller waiting on it has given up.
+func (g *Group) Do(ctx context.Context, key string, fn func(ctx context.Context) (interface{}, error)) (v interface{}, shared bool, err error) {
+	g.mu.Lock()
+	if g.calls == nil {
+		g.calls = map[string]*call{}
+	}
+	c, ok := g.calls[key]
+	if ok {
+		c.dups++
+		c.waiters++
+	} else {
+		fctx, cancel := context.WithCancel(context.Background())
+		c = &call{done: make(chan struct{}), cancel: cancel, waiters: 1}
+		g.calls[key] = c
+		go g.run(key, c, fctx, fn)
+	}
+	g.mu.Unlock()
+
+	select {
+	case <-c.done:
+		g.mu.Lock()
+		shared = c.dups > 0
+		g.mu.Unlock()
+		return c.val, shared, c.err
+	case <-ctx.Done():
+		g.mu.Lock()
+		c.waiters--
+		if c.waiters == 0 {
+			// nobody wants it anymore
+			c.cancel()
+			if g.calls[key] == c {
+				delete(g.calls, key)
+			}
+		}
+		g.mu.Unlock()
+		return nil, false, ctx.Err()
+	}
+}
+
+func (g *Group) run(key string, c *call, ctx context.Context, fn func(ctx context.Context) (interface{}, error)) {
+	defer c.cancel()
+	c.val, c.err = fn(ctx)
+	g.mu.Lock()
+	if g.calls[key] == c {
+		delete(g.calls, key)
+	}
+	g.mu.Unlock()
+	close(c.done)
+}
+
+// Forget will make the next call for the key do the work rather than
+// wait for a call already in flight.
+func (g *Group) Forget(key string) {
+	g.mu.Lock()
+	delete(g.calls, key)
+	g.mu.Unlock()
+}
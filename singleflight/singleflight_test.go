@@ -0,0 +1,141 @@
+package singleflight
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+func TestGroupDo(t *testing.T) {
+	var (
+		g       Group
+		calls   int32
+		release = make(chan struct{})
+		wg      sync.WaitGroup
+		shares  int32
+	)
+	fn := func(ctx context.Context) (interface{}, error) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		return "tom", nil
+	}
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			v, shared, err := g.Do(context.Background(), "cat", fn)
+			if err != nil || v != "tom" {
+				t.Errorf("expected 'tom', got %v, %v", v, err)
+			}
+			if shared {
+				atomic.AddInt32(&shares, 1)
+			}
+		}()
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	if calls != 1 {
+		t.Errorf("expected 1 call, got %d", calls)
+	}
+	if shares != 5 {
+		t.Errorf("expected all 5 results to be shared, got %d", shares)
+	}
+
+	// once done, the next call should do the work again
+	errFetch := errors.New("upstream is down")
+	_, shared, err := g.Do(context.Background(), "cat", func(context.Context) (interface{}, error) {
+		return nil, errFetch
+	})
+	if err != errFetch || shared {
+		t.Errorf("expected an unshared error, got %v, %v", shared, err)
+	}
+}
+
+func TestGroupDoContext(t *testing.T) {
+	var g Group
+	started := make(chan struct{})
+	cancelled := make(chan struct{})
+	fn := func(ctx context.Context) (interface{}, error) {
+		close(started)
+		<-ctx.Done()
+		close(cancelled)
+		return nil, ctx.Err()
+	}
+
+	ctx1, cancel1 := context.WithCancel(context.Background())
+	ctx2, cancel2 := context.WithCancel(context.Background())
+	errs := make(chan error, 2)
+	go func() {
+		_, _, err := g.Do(ctx1, "cat", fn)
+		errs <- err
+	}()
+	<-started
+	go func() {
+		_, _, err := g.Do(ctx2, "cat", fn)
+		errs <- err
+	}()
+
+	// one caller giving up should not cancel the call for the other
+	cancel1()
+	if err := <-errs; err != context.Canceled {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+	select {
+	case <-cancelled:
+		t.Fatal("expected the call to continue for the remaining caller")
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	cancel2()
+	<-errs
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		t.Error("expected the call to be cancelled once every caller gave up")
+	}
+}
+
+func TestStaleGroup(t *testing.T) {
+	s := NewStaleGroup(20*time.Millisecond, time.Hour)
+	var calls int32
+	refreshed := make(chan struct{}, 1)
+	fn := func(ctx context.Context) (interface{}, error) {
+		n := atomic.AddInt32(&calls, 1)
+		if n > 1 {
+			refreshed <- struct{}{}
+		}
+		return n, nil
+	}
+
+	for i := 0; i < 3; i++ {
+		if v, err := s.Do(context.Background(), "cat", fn); err != nil || v != int32(1) {
+			t.Fatalf("expected the fresh result, got %v, %v", v, err)
+		}
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	if v, _ := s.Do(context.Background(), "cat", fn); v != int32(1) {
+		t.Errorf("expected the stale result while refreshing, got %v", v)
+	}
+	select {
+	case <-refreshed:
+	case <-time.After(time.Second):
+		t.Fatal("expected a background refresh")
+	}
+	// wait for the refresh to be remembered
+	time.Sleep(5 * time.Millisecond)
+	if v, _ := s.Do(context.Background(), "cat", fn); v != int32(2) {
+		t.Errorf("expected the refreshed result, got %v", v)
+	}
+
+	s.Stale = 0
+	s.Forget("cat")
+	if v, _ := s.Do(context.Background(), "cat", fn); v != int32(3) {
+		t.Errorf("expected a forgotten key to be fetched, got %v", v)
+	}
+}
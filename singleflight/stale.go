@@ -0,0 +1,107 @@
+package singleflight
+
+import (
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// StaleGroup is a Group that remembers each key's result. Results are
+// returned as-is while fresh and, for a while after, returned stale while
+// a single background call refreshes them, so callers are never blocked on
+// a refresh of a recently used key. Errors are not remembered.
+//
+// Results are held until they are looked up after going stale, so a
+// StaleGroup is meant for a bounded set of keys.
+type StaleGroup struct {
+	// Fresh is how long a result will be returned without a refresh.
+	Fresh time.Duration
+	// Stale is how long after going stale a result will still be returned
+	// while it is refreshed. After that, callers will wait for a new result.
+	Stale time.Duration
+	// OnRefreshError is an optional hook that will be called with the error
+	// of any failed background refresh.
+	OnRefreshError func(key string, err error)
+
+	group Group
+
+	mu         sync.Mutex
+	results    map[string]staleResult
+	refreshing map[string]bool
+}
+
+type staleResult struct {
+	val     interface{}
+	fetched time.Time
+}
+
+// NewStaleGroup will return a StaleGroup with the given fresh and stale periods.
+func NewStaleGroup(fresh, stale time.Duration) *StaleGroup {
+	return &StaleGroup{Fresh: fresh, Stale: stale, results: map[string]staleResult{}}
+}
+
+// Do will return the remembered result of the key if it is fresh or stale,
+// refreshing a stale result in the background. Otherwise, it will call fn
+// like Group.Do and remember its result.
+func (s *StaleGroup) Do(ctx context.Context, key string, fn func(ctx context.Context) (interface{}, error)) (interface{}, error) {
+	s.mu.Lock()
+	r, ok := s.results[key]
+	if ok {
+		age := time.Since(r.fetched)
+		if age < s.Fresh {
+			s.mu.Unlock()
+			return r.val, nil
+		}
+		if age < s.Fresh+s.Stale {
+			if !s.refreshing[key] {
+				if s.refreshing == nil {
+					s.refreshing = map[string]bool{}
+				}
+				s.refreshing[key] = true
+				go s.refresh(key, fn)
+			}
+			s.mu.Unlock()
+			return r.val, nil
+		}
+	}
+	s.mu.Unlock()
+
+	v, _, err := s.group.Do(ctx, key, s.fetch(key, fn))
+	return v, err
+}
+
+// Forget will drop the remembered result of the key.
+func (s *StaleGroup) Forget(key string) {
+	s.mu.Lock()
+	delete(s.results, key)
+	s.mu.Unlock()
+}
+
+func (s *StaleGroup) refresh(key string, fn func(ctx context.Context) (interface{}, error)) {
+	// shared with any other refresh or waiting callers of the key
+	_, _, err := s.group.Do(context.Background(), key, s.fetch(key, fn))
+	s.mu.Lock()
+	delete(s.refreshing, key)
+	s.mu.Unlock()
+	if err != nil && s.OnRefreshError != nil {
+		s.OnRefreshError(key, err)
+	}
+}
+
+// fetch will wrap fn to remember its successful results.
+func (s *StaleGroup) fetch(key string, fn func(ctx context.Context) (interface{}, error)) func(ctx context.Context) (interface{}, error) {
+	return func(ctx context.Context) (interface{}, error) {
+		v, err := fn(ctx)
+		if err != nil {
+			return v, err
+		}
+		s.mu.Lock()
+		if s.results == nil {
+			s.results = map[string]staleResult{}
+		}
+		s.results[key] = staleResult{val: v, fetched: time.Now()}
+		s.mu.Unlock()
+		return v, nil
+	}
+}
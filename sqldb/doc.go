@@ -0,0 +1,24 @@
+/*
+Package sqldb opens instrumented *sql.DBs from a config.SQL struct. Every
+statement run through the returned DB is:
+
+  - timed in go-metrics under 'sql.{name}.{verb}.DURATION' with an error counter
+  - traced via the optional StartSpan hook
+  - logged if it is slower than the SlowQueryThreshold
+  - retried with exponential backoff on transient errors like deadlocks,
+    if it is outside of a transaction
+
+The instrumentation wraps the registered driver, so the DB can be used like
+any other:
+
+	import _ "github.com/go-sql-driver/mysql"
+
+	db, err := sqldb.Open(&config.SQL{
+	    Driver:             "mysql",
+	    DSN:                cfg.MySQL.String(),
+	    Name:               "saved-items",
+	    SlowQueryThreshold: 100 * time.Millisecond,
+	    MaxRetries:         2,
+	})
+*/
+package sqldb
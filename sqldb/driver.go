@@ -0,0 +1,124 @@
+package sqldb
+
+import "database/sql/driver"
+
+// instrumentedDriver wraps the connections of another driver
+// with instrumentation.
+type instrumentedDriver struct {
+	driver.Driver
+	ins *instrumentation
+}
+
+func (d *instrumentedDriver) Open(name string) (driver.Conn, error) {
+	c, err := d.Driver.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return &conn{Conn: c, ins: d.ins}, nil
+}
+
+type conn struct {
+	driver.Conn
+	ins *instrumentation
+
+	// set while a transaction is open, when statements are not retried
+	inTx bool
+}
+
+func (c *conn) Prepare(query string) (driver.Stmt, error) {
+	s, err := c.Conn.Prepare(query)
+	if err != nil {
+		return nil, err
+	}
+	return &stmt{Stmt: s, conn: c, query: query}, nil
+}
+
+func (c *conn) Begin() (driver.Tx, error) {
+	t, err := c.Conn.Begin()
+	if err != nil {
+		return nil, err
+	}
+	c.inTx = true
+	return &tx{Tx: t, conn: c}, nil
+}
+
+// Exec will use the underlying connection's Execer, if any, so queries
+// are not prepared unnecessarily.
+func (c *conn) Exec(query string, args []driver.Value) (driver.Result, error) {
+	e, ok := c.Conn.(driver.Execer)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	var res driver.Result
+	err := c.ins.run(query, !c.inTx, func() error {
+		var err error
+		res, err = e.Exec(query, args)
+		return err
+	})
+	return res, err
+}
+
+// Query will use the underlying connection's Queryer, if any, so queries
+// are not prepared unnecessarily.
+func (c *conn) Query(query string, args []driver.Value) (driver.Rows, error) {
+	q, ok := c.Conn.(driver.Queryer)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	var rows driver.Rows
+	err := c.ins.run(query, !c.inTx, func() error {
+		var err error
+		rows, err = q.Query(query, args)
+		return err
+	})
+	return rows, err
+}
+
+type tx struct {
+	driver.Tx
+	conn *conn
+}
+
+func (t *tx) Commit() error {
+	t.conn.inTx = false
+	return t.Tx.Commit()
+}
+
+func (t *tx) Rollback() error {
+	t.conn.inTx = false
+	return t.Tx.Rollback()
+}
+
+type stmt struct {
+	driver.Stmt
+	conn  *conn
+	query string
+}
+
+func (s *stmt) Exec(args []driver.Value) (driver.Result, error) {
+	var res driver.Result
+	err := s.conn.ins.run(s.query, !s.conn.inTx, func() error {
+		var err error
+		res, err = s.Stmt.Exec(args)
+		return err
+	})
+	return res, err
+}
+
+func (s *stmt) Query(args []driver.Value) (driver.Rows, error) {
+	var rows driver.Rows
+	err := s.conn.ins.run(s.query, !s.conn.inTx, func() error {
+		var err error
+		rows, err = s.Stmt.Query(args)
+		return err
+	})
+	return rows, err
+}
+
+// ColumnConverter will use the underlying statement's converters, if any.
+func (s *stmt) ColumnConverter(idx int) driver.ValueConverter {
+	if cc, ok := s.Stmt.(driver.ColumnConverter); ok {
+		return cc.ColumnConverter(idx)
+	}
+	return driver.DefaultParameterConverter
+}
@@ -0,0 +1,181 @@
+package sqldb
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/rcrowley/go-metrics"
+
+	"github.com/NYTimes/gizmo/backoff"
+	"github.com/NYTimes/gizmo/config"
+)
+
+// Log is the structured logger used by the sqldb package.
+var Log = logrus.New()
+
+// DefaultRetryBaseDelay is the initial retry backoff used if none is configured.
+var DefaultRetryBaseDelay = 10 * time.Millisecond
+
+// retryMaxDelay caps the backoff between retries.
+const retryMaxDelay = time.Second
+
+// TransientErrors are substrings of error messages that will be retried
+// if MaxRetries is set. They cover MySQL and Postgres deadlocks, lock wait
+// timeouts and serialization failures.
+var TransientErrors = []string{
+	"deadlock",
+	"Error 1213",
+	"Error 1205",
+	"40001",
+	"40P01",
+	"could not serialize access",
+	"connection reset by peer",
+}
+
+// drivers counts the instrumented drivers registered by Open,
+// as each database gets its own.
+var drivers int64
+
+// Open will open a *sql.DB for the driver and DSN in the config, with each
+// statement timed in metrics, traced via the StartSpan hook, logged if slower
+// than the SlowQueryThreshold and retried on transient errors if outside of
+// a transaction.
+//
+// Statements are timed under 'sql.{name}.{verb}.DURATION', where the verb is
+// the first word of the query, like 'select' or 'insert'.
+func Open(cfg *config.SQL) (*sql.DB, error) {
+	if cfg.Driver == "" {
+		return nil, errors.New("sql driver is required")
+	}
+	// open once to get at the registered driver
+	base, err := sql.Open(cfg.Driver, cfg.DSN)
+	if err != nil {
+		return nil, err
+	}
+	drv := base.Driver()
+	if err = base.Close(); err != nil {
+		return nil, err
+	}
+
+	name := cfg.Name
+	if name == "" {
+		name = cfg.Driver
+	}
+	registry := cfg.MetricsRegistry
+	if registry == nil {
+		registry = metrics.DefaultRegistry
+	}
+	delay := cfg.RetryBaseDelay
+	if delay == 0 {
+		delay = DefaultRetryBaseDelay
+	}
+	ins := &instrumentation{
+		name:       name,
+		slow:       cfg.SlowQueryThreshold,
+		maxRetries: cfg.MaxRetries,
+		retryDelay: delay,
+		startSpan:  cfg.StartSpan,
+		registry:   registry,
+	}
+
+	drvName := fmt.Sprintf("gizmo-sqldb-%s-%d", cfg.Driver, atomic.AddInt64(&drivers, 1))
+	sql.Register(drvName, &instrumentedDriver{Driver: drv, ins: ins})
+	db, err := sql.Open(drvName, cfg.DSN)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.MaxOpenConns > 0 {
+		db.SetMaxOpenConns(cfg.MaxOpenConns)
+	}
+	if cfg.MaxIdleConns > 0 {
+		db.SetMaxIdleConns(cfg.MaxIdleConns)
+	}
+	return db, nil
+}
+
+// IsTransient will return true if the error matches any of the
+// TransientErrors.
+func IsTransient(err error) bool {
+	if err == nil {
+		return false
+	}
+	msg := err.Error()
+	for _, s := range TransientErrors {
+		if strings.Contains(msg, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// instrumentation holds the settings and metrics shared by
+// every connection of a database.
+type instrumentation struct {
+	name       string
+	slow       time.Duration
+	maxRetries int
+	retryDelay time.Duration
+	startSpan  func(name, query string) func(error)
+	registry   metrics.Registry
+}
+
+// run will call fn for the query, retrying it on transient errors if
+// retryable, and record its duration and outcome.
+func (i *instrumentation) run(query string, retryable bool, fn func() error) error {
+	verb := queryVerb(query)
+	prefix := "sql." + i.name + "." + verb
+	var finish func(error)
+	if i.startSpan != nil {
+		finish = i.startSpan(prefix, query)
+	}
+
+	start := time.Now()
+	var err error
+	for attempt := 0; ; attempt++ {
+		if attempt > 0 {
+			metrics.GetOrRegisterCounter(prefix+".RETRY", i.registry).Inc(1)
+			time.Sleep(backoff.Exponential{Base: i.retryDelay, Max: retryMaxDelay}.Delay(attempt))
+		}
+		err = fn()
+		if err == nil || !retryable || attempt >= i.maxRetries || !IsTransient(err) {
+			break
+		}
+	}
+	took := time.Since(start)
+
+	metrics.GetOrRegisterTimer(prefix+".DURATION", i.registry).Update(took)
+	if err != nil {
+		metrics.GetOrRegisterCounter(prefix+".ERROR", i.registry).Inc(1)
+	}
+	if i.slow > 0 && took > i.slow {
+		Log.WithFields(logrus.Fields{
+			"db":       i.name,
+			"query":    query,
+			"duration": took.String(),
+		}).Warn("slow query")
+	}
+	if finish != nil {
+		finish(err)
+	}
+	return err
+}
+
+// queryVerb will return the lowercased first word of the query.
+func queryVerb(query string) string {
+	fields := strings.Fields(query)
+	if len(fields) == 0 {
+		return "unknown"
+	}
+	verb := strings.ToLower(fields[0])
+	for _, r := range verb {
+		if r < 'a' || r > 'z' {
+			return "other"
+		}
+	}
+	return verb
+}
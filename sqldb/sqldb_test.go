@@ -0,0 +1,170 @@
+package sqldb
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+
+	"github.com/NYTimes/gizmo/config"
+)
+
+// testDriver will fail the first failures statements it runs with
+// a deadlock error, then succeed.
+type testDriver struct {
+	mu       sync.Mutex
+	failures int
+	execs    int
+}
+
+func (d *testDriver) Open(string) (driver.Conn, error) {
+	return &testConn{d: d}, nil
+}
+
+func (d *testDriver) exec() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.execs++
+	if d.failures > 0 {
+		d.failures--
+		return errors.New("Error 1213: Deadlock found when trying to get lock")
+	}
+	return nil
+}
+
+func (d *testDriver) setFailures(n int) {
+	d.mu.Lock()
+	d.failures, d.execs = n, 0
+	d.mu.Unlock()
+}
+
+type testConn struct{ d *testDriver }
+
+func (c *testConn) Prepare(query string) (driver.Stmt, error) { return &testStmt{c.d}, nil }
+func (c *testConn) Close() error                              { return nil }
+func (c *testConn) Begin() (driver.Tx, error)                 { return c, nil }
+func (c *testConn) Commit() error                             { return nil }
+func (c *testConn) Rollback() error                           { return nil }
+
+type testStmt struct{ d *testDriver }
+
+func (s *testStmt) Close() error  { return nil }
+func (s *testStmt) NumInput() int { return -1 }
+func (s *testStmt) Exec([]driver.Value) (driver.Result, error) {
+	if err := s.d.exec(); err != nil {
+		return nil, err
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func (s *testStmt) Query([]driver.Value) (driver.Rows, error) {
+	if err := s.d.exec(); err != nil {
+		return nil, err
+	}
+	return &testRows{}, nil
+}
+
+type testRows struct{ done bool }
+
+func (r *testRows) Columns() []string { return []string{"name"} }
+func (r *testRows) Close() error      { return nil }
+func (r *testRows) Next(dest []driver.Value) error {
+	if r.done {
+		return io.EOF
+	}
+	r.done = true
+	dest[0] = []byte("tom")
+	return nil
+}
+
+var testDrv = &testDriver{}
+
+func init() {
+	sql.Register("sqldb-test", testDrv)
+}
+
+func TestOpen(t *testing.T) {
+	registry := metrics.NewRegistry()
+	var (
+		spans []string
+		errs  []error
+	)
+	db, err := Open(&config.SQL{
+		Driver:          "sqldb-test",
+		Name:            "cats",
+		MaxRetries:      2,
+		RetryBaseDelay:  time.Millisecond,
+		MetricsRegistry: registry,
+		StartSpan: func(name, query string) func(error) {
+			spans = append(spans, name)
+			return func(err error) { errs = append(errs, err) }
+		},
+	})
+	if err != nil {
+		t.Fatal("unexpected error opening db: ", err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	testDrv.setFailures(2)
+	var name string
+	if err = db.QueryRow("SELECT name FROM cats WHERE id = ?", 1).Scan(&name); err != nil || name != "tom" {
+		t.Fatalf("expected tom after retries, got %q, %v", name, err)
+	}
+	if testDrv.execs != 3 {
+		t.Errorf("expected 3 attempts, got %d", testDrv.execs)
+	}
+	if got := registry.Get("sql.cats.select.RETRY").(metrics.Counter).Count(); got != 2 {
+		t.Errorf("expected 2 retries recorded, got %d", got)
+	}
+	if got := registry.Get("sql.cats.select.DURATION").(metrics.Timer).Count(); got != 1 {
+		t.Errorf("expected 1 select timed, got %d", got)
+	}
+	if len(spans) != 1 || spans[0] != "sql.cats.select" || errs[0] != nil {
+		t.Errorf("expected a single successful span, got %v, %v", spans, errs)
+	}
+
+	// too many failures should give up
+	testDrv.setFailures(5)
+	if _, err = db.Exec("UPDATE cats SET name = ?", "felix"); err == nil {
+		t.Error("expected an error once retries ran out")
+	}
+	if got := registry.Get("sql.cats.update.ERROR").(metrics.Counter).Count(); got != 1 {
+		t.Errorf("expected 1 update error recorded, got %d", got)
+	}
+
+	// statements in a transaction should not be retried
+	testDrv.setFailures(1)
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal("unexpected error beginning tx: ", err)
+	}
+	if _, err = tx.Exec("INSERT INTO cats VALUES (?)", "tom"); err == nil {
+		t.Error("expected statements in a transaction to not be retried")
+	}
+	tx.Rollback()
+	if testDrv.execs != 1 {
+		t.Errorf("expected 1 attempt in a transaction, got %d", testDrv.execs)
+	}
+}
+
+func TestQueryVerb(t *testing.T) {
+	tests := map[string]string{
+		"SELECT * FROM cats":    "select",
+		"  insert into cats":    "insert",
+		"WITH x AS (SELECT 1)":  "with",
+		"":                      "unknown",
+		"/* hint */ SELECT 1":   "other",
+		"update cats set a = 1": "update",
+	}
+	for query, want := range tests {
+		if got := queryVerb(query); got != want {
+			t.Errorf("expected %q for %q, got %q", want, query, got)
+		}
+	}
+}
@@ -0,0 +1,21 @@
+/*
+Package switches offers a runtime switchboard for putting routes into maintenance
+mode or pausing pubsub consumers without redeploying.
+
+A Board holds a set of named switches. Switches can be flipped via the Board's admin
+handler or by hot-reloading a JSON file of disabled switch names to reasons:
+
+	board := switches.NewBoard()
+	stop, err := board.WatchFile("/etc/myapp/switches.json", 10*time.Second)
+
+	// routes in the group will respond with a 503 while 'admin' is disabled
+	admin := svr.Group("/admin", board.Maintenance("admin"))
+
+	// the consumer will pause while 'ingest' is disabled
+	consumer := pubsub.NewConsumer(sub, handler)
+	consumer.Enabled = board.EnabledFunc("ingest")
+
+	// GET lists disabled switches, PUT {"name":"ingest","disabled":true} flips one
+	mux.Handle("PUT", "/admin/switches", board)
+*/
+package switches
@@ -0,0 +1,213 @@
+package switches
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+)
+
+// Log is the structured logger used throughout the package.
+var Log = logrus.New()
+
+// DefaultRetryAfter is the value of the 'Retry-After' header that will be
+// sent with maintenance responses.
+var DefaultRetryAfter = 60 * time.Second
+
+// Board is a set of named switches that can be flipped at runtime. All
+// switches are enabled until they are disabled.
+type Board struct {
+	mu sync.RWMutex
+	// name - reason
+	disabled map[string]string
+}
+
+// NewBoard will return a Board with all switches enabled.
+func NewBoard() *Board {
+	return &Board{disabled: map[string]string{}}
+}
+
+// Disable will turn off the named switch. The reason will be logged and
+// included in any maintenance responses.
+func (b *Board) Disable(name, reason string) {
+	b.mu.Lock()
+	b.disabled[name] = reason
+	b.mu.Unlock()
+	Log.WithField("switch", name).Warn("switch disabled: ", reason)
+}
+
+// Enable will turn the named switch back on.
+func (b *Board) Enable(name string) {
+	b.mu.Lock()
+	_, ok := b.disabled[name]
+	delete(b.disabled, name)
+	b.mu.Unlock()
+	if ok {
+		Log.WithField("switch", name).Info("switch enabled")
+	}
+}
+
+// Disabled will return true if the named switch is off.
+func (b *Board) Disabled(name string) bool {
+	_, ok := b.Reason(name)
+	return ok
+}
+
+// Reason will return the reason the named switch was disabled and
+// true if the switch is off.
+func (b *Board) Reason(name string) (string, bool) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	reason, ok := b.disabled[name]
+	return reason, ok
+}
+
+// Status will return the reasons for all disabled switches keyed by name.
+func (b *Board) Status() map[string]string {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	status := make(map[string]string, len(b.disabled))
+	for name, reason := range b.disabled {
+		status[name] = reason
+	}
+	return status
+}
+
+// Set will replace the state of the board so only the given switches
+// are disabled.
+func (b *Board) Set(disabled map[string]string) {
+	for name := range b.Status() {
+		if _, ok := disabled[name]; !ok {
+			b.Enable(name)
+		}
+	}
+	current := b.Status()
+	for name, reason := range disabled {
+		if cr, ok := current[name]; !ok || cr != reason {
+			b.Disable(name, reason)
+		}
+	}
+}
+
+// EnabledFunc will return a func that reports whether the named switch is
+// on. This can be used as the Enabled hook of a pubsub.Consumer.
+func (b *Board) EnabledFunc(name string) func() bool {
+	return func() bool {
+		return !b.Disabled(name)
+	}
+}
+
+// Maintenance will return middleware that responds with a 503 while
+// the named switch is disabled.
+func (b *Board) Maintenance(name string) func(http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			reason, off := b.Reason(name)
+			if !off {
+				h.ServeHTTP(w, r)
+				return
+			}
+			if reason == "" {
+				reason = "down for maintenance"
+			}
+			w.Header().Set("Retry-After", fmt.Sprintf("%d", int(DefaultRetryAfter.Seconds())))
+			http.Error(w, reason, http.StatusServiceUnavailable)
+		})
+	}
+}
+
+// SwitchRequest is the payload accepted by the Board's admin handler.
+type SwitchRequest struct {
+	Name     string `json:"name"`
+	Disabled bool   `json:"disabled"`
+	Reason   string `json:"reason"`
+}
+
+// ServeHTTP is an admin endpoint for the Board. A GET will respond with the
+// JSON status of all disabled switches and a PUT or POST of a SwitchRequest
+// will flip a switch.
+func (b *Board) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+	case "PUT", "POST":
+		var req SwitchRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Name == "" {
+			http.Error(w, "a JSON body with a switch name is required", http.StatusBadRequest)
+			return
+		}
+		if req.Disabled {
+			b.Disable(req.Name, req.Reason)
+		} else {
+			b.Enable(req.Name)
+		}
+	default:
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	if err := json.NewEncoder(w).Encode(b.Status()); err != nil {
+		Log.Error("unable to encode switch status: ", err)
+	}
+}
+
+// LoadFile will set the state of the board from a JSON file containing an
+// object of disabled switch names to reasons.
+func (b *Board) LoadFile(path string) error {
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var disabled map[string]string
+	if err = json.Unmarshal(raw, &disabled); err != nil {
+		return err
+	}
+	b.Set(disabled)
+	return nil
+}
+
+// WatchFile will load the given file into the board and reload it whenever
+// its modification time changes, checking on the given interval. The
+// returned func will stop the watch.
+func (b *Board) WatchFile(path string, interval time.Duration) (func(), error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if err = b.LoadFile(path); err != nil {
+		return nil, err
+	}
+
+	stop := make(chan struct{})
+	go func(modTime time.Time) {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+			}
+			info, err := os.Stat(path)
+			if err != nil {
+				Log.Warn("unable to check switch file: ", err)
+				continue
+			}
+			if info.ModTime().Equal(modTime) {
+				continue
+			}
+			if err = b.LoadFile(path); err != nil {
+				Log.Warn("unable to reload switch file: ", err)
+				continue
+			}
+			modTime = info.ModTime()
+		}
+	}(info.ModTime())
+
+	var once sync.Once
+	return func() { once.Do(func() { close(stop) }) }, nil
+}
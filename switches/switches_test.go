@@ -0,0 +1,110 @@
+package switches
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMaintenance(t *testing.T) {
+	board := NewBoard()
+	h := board.Maintenance("cats")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+
+	tests := []struct {
+		disable bool
+		reason  string
+
+		wantCode int
+		wantBody string
+	}{
+		{false, "", http.StatusOK, "ok"},
+		{true, "", http.StatusServiceUnavailable, "down for maintenance\n"},
+		{true, "migrating cats", http.StatusServiceUnavailable, "migrating cats\n"},
+		{false, "", http.StatusOK, "ok"},
+	}
+
+	for _, test := range tests {
+		if test.disable {
+			board.Disable("cats", test.reason)
+		} else {
+			board.Enable("cats")
+		}
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest("GET", "/", nil)
+		h.ServeHTTP(w, r)
+
+		if w.Code != test.wantCode {
+			t.Errorf("expected status code of %d, got %d", test.wantCode, w.Code)
+		}
+		if got := w.Body.String(); got != test.wantBody {
+			t.Errorf("expected body of %q, got %q", test.wantBody, got)
+		}
+	}
+}
+
+func TestBoardHandler(t *testing.T) {
+	board := NewBoard()
+	enabled := board.EnabledFunc("ingest")
+
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("PUT", "/", strings.NewReader(`{"name":"ingest","disabled":true,"reason":"backfill"}`))
+	board.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Errorf("expected status code of 200, got %d", w.Code)
+	}
+	if want := `{"ingest":"backfill"}` + "\n"; w.Body.String() != want {
+		t.Errorf("expected status of %q, got %q", want, w.Body.String())
+	}
+	if enabled() {
+		t.Error("expected ingest switch to be disabled")
+	}
+
+	w = httptest.NewRecorder()
+	r, _ = http.NewRequest("PUT", "/", strings.NewReader(`{}`))
+	board.ServeHTTP(w, r)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status code of 400 without a name, got %d", w.Code)
+	}
+}
+
+func TestWatchFile(t *testing.T) {
+	f, err := ioutil.TempFile("", "gizmo-switches")
+	if err != nil {
+		t.Fatal("unable to create temp file: ", err)
+	}
+	defer os.Remove(f.Name())
+	f.WriteString(`{"cats":"maintenance"}`)
+	f.Close()
+
+	board := NewBoard()
+	stop, err := board.WatchFile(f.Name(), 5*time.Millisecond)
+	if err != nil {
+		t.Fatal("unable to watch file: ", err)
+	}
+	defer stop()
+
+	if !board.Disabled("cats") {
+		t.Error("expected cats to be disabled after initial load")
+	}
+
+	if err = ioutil.WriteFile(f.Name(), []byte(`{"dogs":""}`), 0644); err != nil {
+		t.Fatal("unable to update file: ", err)
+	}
+	// make sure the mod time changes on coarse filesystems
+	later := time.Now().Add(time.Second)
+	os.Chtimes(f.Name(), later, later)
+
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) && !board.Disabled("dogs") {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if board.Disabled("cats") || !board.Disabled("dogs") {
+		t.Errorf("expected only dogs to be disabled after reload, got %v", board.Status())
+	}
+}
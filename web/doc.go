@@ -1,4 +1,5 @@
 /*
-Package web contains a handful of very useful functions for parsing types from request queries and payloads.
+Package web contains a handful of very useful functions for parsing types from request queries and payloads
+and for rendering server-side HTML templates.
 */
 package web
@@ -0,0 +1,174 @@
+package web
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"path/filepath"
+	"sync"
+)
+
+// HTMLContentType is the content type used by Render.
+var HTMLContentType = "text/html; charset=UTF-8"
+
+// Templates manages a set of html/templates loaded from a directory.
+// Every '*.html' file in the root of the directory is treated as a page
+// and is parsed along with all of the files in the 'layouts' and 'partials'
+// subdirectories, so pages can invoke any layout or partial template.
+//
+// A typical page will wrap itself in a layout like so:
+//
+//	{{template "base" .}}
+//	{{define "content"}}<h1>{{.Title}}</h1>{{end}}
+//
+// If reload is set, the templates will be reparsed from disk on every
+// execution. This is handy in development but should be off in production.
+type Templates struct {
+	dir    string
+	reload bool
+
+	mu    sync.RWMutex
+	funcs template.FuncMap
+	pages map[string]*template.Template
+}
+
+// NewTemplates will parse all of the templates found in the given directory
+// with the given function map. It will return an error if any of the
+// templates fail to parse.
+func NewTemplates(dir string, funcs template.FuncMap, reload bool) (*Templates, error) {
+	t := &Templates{
+		dir:    dir,
+		reload: reload,
+		funcs:  template.FuncMap{},
+	}
+	for name, fn := range funcs {
+		t.funcs[name] = fn
+	}
+	return t, t.Load()
+}
+
+// Funcs will add the given functions to the template function map and
+// reparse the templates so they are available to every page.
+func (t *Templates) Funcs(funcs template.FuncMap) error {
+	t.mu.Lock()
+	// replace rather than modify the map, since Load may be parsing with it
+	merged := make(template.FuncMap, len(t.funcs)+len(funcs))
+	for name, fn := range t.funcs {
+		merged[name] = fn
+	}
+	for name, fn := range funcs {
+		merged[name] = fn
+	}
+	t.funcs = merged
+	t.mu.Unlock()
+	return t.Load()
+}
+
+// Load will (re)parse all the templates from disk. It is safe to call while
+// pages are being rendered.
+func (t *Templates) Load() error {
+	// the map is never modified once set, so it can be used unlocked
+	t.mu.RLock()
+	funcs := t.funcs
+	t.mu.RUnlock()
+
+	shared, err := t.glob("layouts", "partials")
+	if err != nil {
+		return err
+	}
+	pages, err := filepath.Glob(filepath.Join(t.dir, "*.html"))
+	if err != nil {
+		return err
+	}
+
+	parsed := map[string]*template.Template{}
+	for _, page := range pages {
+		name := filepath.Base(page)
+		tmpl, err := template.New(name).Funcs(funcs).ParseFiles(append([]string{page}, shared...)...)
+		if err != nil {
+			return fmt.Errorf("unable to parse template '%s': %s", name, err)
+		}
+		parsed[name] = tmpl
+	}
+
+	t.mu.Lock()
+	t.pages = parsed
+	t.mu.Unlock()
+	return nil
+}
+
+func (t *Templates) glob(dirs ...string) ([]string, error) {
+	var files []string
+	for _, dir := range dirs {
+		found, err := filepath.Glob(filepath.Join(t.dir, dir, "*.html"))
+		if err != nil {
+			return files, err
+		}
+		files = append(files, found...)
+	}
+	return files, nil
+}
+
+// Execute will render the named page with the given data into a buffer.
+// Rendering to a buffer first prevents partial responses when a template
+// fails halfway through.
+func (t *Templates) Execute(name string, data interface{}) (*bytes.Buffer, error) {
+	if t.reload {
+		if err := t.Load(); err != nil {
+			return nil, err
+		}
+	}
+
+	t.mu.RLock()
+	tmpl, ok := t.pages[name]
+	t.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("template '%s' does not exist", name)
+	}
+
+	var b bytes.Buffer
+	if err := tmpl.ExecuteTemplate(&b, name, data); err != nil {
+		return nil, err
+	}
+	return &b, nil
+}
+
+// Render will execute the named page and write it to the given
+// http.ResponseWriter with the HTMLContentType. If the template fails to
+// execute, a 500 will be written and the error will be returned.
+func (t *Templates) Render(w http.ResponseWriter, name string, data interface{}) error {
+	b, err := t.Execute(name, data)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return err
+	}
+	w.Header().Set("Content-Type", HTMLContentType)
+	_, err = w.Write(b.Bytes())
+	return err
+}
+
+// defaultTemplates is used by the package level Render function.
+var defaultTemplates *Templates
+
+// LoadTemplates will initialize the Templates used by the package level
+// Render function.
+func LoadTemplates(dir string, funcs template.FuncMap, reload bool) error {
+	t, err := NewTemplates(dir, funcs, reload)
+	if err != nil {
+		return err
+	}
+	defaultTemplates = t
+	return nil
+}
+
+// Render will execute the named page from the Templates set up via
+// LoadTemplates and write it to the response.
+func Render(w http.ResponseWriter, name string, data interface{}) error {
+	if defaultTemplates == nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return errors.New("templates have not been loaded, call LoadTemplates first")
+	}
+	return defaultTemplates.Render(w, name, data)
+}
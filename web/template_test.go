@@ -0,0 +1,118 @@
+package web_test
+
+import (
+	"html/template"
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/NYTimes/gizmo/web"
+)
+
+func writeTestTemplates(t *testing.T, files map[string]string) string {
+	dir, err := ioutil.TempDir("", "gizmo-templates")
+	if err != nil {
+		t.Fatal("unable to create temp dir: ", err)
+	}
+	for name, contents := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal("unable to create template dir: ", err)
+		}
+		if err := ioutil.WriteFile(path, []byte(contents), 0644); err != nil {
+			t.Fatal("unable to write template: ", err)
+		}
+	}
+	return dir
+}
+
+func TestRender(t *testing.T) {
+	dir := writeTestTemplates(t, map[string]string{
+		"layouts/base.html":   `{{define "base"}}<html>{{template "content" .}}</html>{{end}}`,
+		"partials/title.html": `{{define "title"}}<h1>{{shout .}}</h1>{{end}}`,
+		"index.html":          `{{template "base" .}}{{define "content"}}{{template "title" .Title}}{{end}}`,
+	})
+	defer os.RemoveAll(dir)
+
+	err := web.LoadTemplates(dir, template.FuncMap{"shout": strings.ToUpper}, false)
+	if err != nil {
+		t.Fatal("unable to load templates: ", err)
+	}
+
+	w := httptest.NewRecorder()
+	if err = web.Render(w, "index.html", struct{ Title string }{"hi"}); err != nil {
+		t.Fatal("unexpected render error: ", err)
+	}
+
+	want := "<html><h1>HI</h1></html>"
+	if got := w.Body.String(); got != want {
+		t.Errorf("expected body of %q, got %q", want, got)
+	}
+	if got := w.Header().Get("Content-Type"); got != web.HTMLContentType {
+		t.Errorf("expected content type of %q, got %q", web.HTMLContentType, got)
+	}
+
+	w = httptest.NewRecorder()
+	if err = web.Render(w, "nope.html", nil); err == nil {
+		t.Error("expected an error rendering a missing template")
+	}
+	if w.Code != 500 {
+		t.Errorf("expected a 500 status code for a missing template, got %d", w.Code)
+	}
+}
+
+func TestTemplatesReload(t *testing.T) {
+	dir := writeTestTemplates(t, map[string]string{
+		"index.html": `one`,
+	})
+	defer os.RemoveAll(dir)
+
+	tmpls, err := web.NewTemplates(dir, nil, true)
+	if err != nil {
+		t.Fatal("unable to load templates: ", err)
+	}
+
+	if err = ioutil.WriteFile(filepath.Join(dir, "index.html"), []byte("two"), 0644); err != nil {
+		t.Fatal("unable to update template: ", err)
+	}
+
+	got, err := tmpls.Execute("index.html", nil)
+	if err != nil {
+		t.Fatal("unexpected execute error: ", err)
+	}
+	if got.String() != "two" {
+		t.Errorf("expected reloaded template to render 'two', got %q", got.String())
+	}
+}
+
+func TestTemplatesConcurrentFuncs(t *testing.T) {
+	dir := writeTestTemplates(t, map[string]string{
+		"index.html": `{{shout "hi"}}`,
+	})
+	defer os.RemoveAll(dir)
+
+	tmpls, err := web.NewTemplates(dir, template.FuncMap{"shout": strings.ToUpper}, true)
+	if err != nil {
+		t.Fatal("unable to load templates: ", err)
+	}
+
+	// adding funcs while pages render and reload must not race
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 50; i++ {
+			if err := tmpls.Funcs(template.FuncMap{"whisper": strings.ToLower}); err != nil {
+				t.Error("unexpected funcs error: ", err)
+			}
+		}
+	}()
+	for i := 0; i < 50; i++ {
+		if _, err := tmpls.Execute("index.html", nil); err != nil {
+			t.Fatal("unexpected execute error: ", err)
+		}
+	}
+	<-done
+}
@@ -0,0 +1,25 @@
+/*
+Package webhook delivers events to partners' HTTP endpoints. Services publish
+events of a type with a JSON payload over any pubsub backend:
+
+	id, err := webhook.Publish(pub, "order.shipped", order)
+
+A Manager consumes those events and POSTs each payload to the Targets
+registered for its type. Payloads are signed with an HMAC of each target's
+Secret and failed deliveries are retried with a randomized exponential backoff
+before being saved to an optional DeadLetterStore:
+
+	registry := webhook.NewMemoryRegistry()
+	registry.Register("order.shipped", &webhook.Target{
+		ID:     "acme",
+		URL:    "https://acme.example.com/hooks",
+		Secret: secret,
+	})
+	mgr := webhook.NewManager(cfg.Webhook, registry)
+	mgr.DeadLetters = &webhook.PublisherDeadLetters{Publisher: deadPub}
+	consumer := pubsub.NewConsumer(sub, mgr.Handle)
+	go consumer.Run()
+
+Receivers can check the signature in the X-Webhook-Signature header with Verify.
+*/
+package webhook
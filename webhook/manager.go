@@ -0,0 +1,204 @@
+package webhook
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/rcrowley/go-metrics"
+	"golang.org/x/net/context"
+
+	"github.com/NYTimes/gizmo/backoff"
+	"github.com/NYTimes/gizmo/config"
+	"github.com/NYTimes/gizmo/httpclient"
+	"github.com/NYTimes/gizmo/pubsub"
+)
+
+var (
+	// DefaultTimeout is the time limit of each attempt used if none is configured.
+	DefaultTimeout = 10 * time.Second
+	// DefaultMaxAttempts is the number of attempts used if none is configured.
+	DefaultMaxAttempts = 5
+	// DefaultRetryBaseDelay is the initial backoff used if none is configured.
+	DefaultRetryBaseDelay = time.Second
+	// DefaultRetryMaxDelay is the backoff cap used if none is configured.
+	DefaultRetryMaxDelay = time.Minute
+	// DefaultUserAgent is the User-Agent sent if none is configured.
+	DefaultUserAgent = "gizmo-webhook"
+)
+
+// Manager delivers the events consumed from a pubsub.Subscriber to the
+// Targets registered for their type. Each delivery is signed with the
+// target's Secret and retried with a randomized exponential backoff until
+// it succeeds or runs out of attempts, at which point it is saved to the
+// DeadLetters store.
+type Manager struct {
+	// DeadLetters is an optional store for deliveries that fail all of
+	// their attempts. Without it, failed deliveries are only logged.
+	DeadLetters DeadLetterStore
+
+	registry    Registry
+	client      *http.Client
+	maxAttempts int
+	baseDelay   time.Duration
+	maxDelay    time.Duration
+	userAgent   string
+	metrics     metrics.Registry
+}
+
+// NewManager will return a Manager that delivers events to the targets in
+// the given Registry. If the config is nil, all defaults will be used.
+func NewManager(cfg *config.Webhook, registry Registry) *Manager {
+	if cfg == nil {
+		cfg = &config.Webhook{}
+	}
+	m := &Manager{
+		registry:    registry,
+		maxAttempts: cfg.MaxAttempts,
+		baseDelay:   cfg.RetryBaseDelay,
+		maxDelay:    cfg.RetryMaxDelay,
+		userAgent:   cfg.UserAgent,
+		metrics:     cfg.MetricsRegistry,
+	}
+	timeout := cfg.Timeout
+	if timeout == 0 {
+		timeout = DefaultTimeout
+	}
+	if m.maxAttempts == 0 {
+		m.maxAttempts = DefaultMaxAttempts
+	}
+	if m.baseDelay == 0 {
+		m.baseDelay = DefaultRetryBaseDelay
+	}
+	if m.maxDelay == 0 {
+		m.maxDelay = DefaultRetryMaxDelay
+	}
+	if m.userAgent == "" {
+		m.userAgent = DefaultUserAgent
+	}
+	if m.metrics == nil {
+		m.metrics = metrics.DefaultRegistry
+	}
+	m.client = httpclient.New(&config.HTTPClient{
+		Timeout:         timeout,
+		MetricsRegistry: m.metrics,
+	})
+	return m
+}
+
+// Handle is a pubsub.MessageHandler that will deliver the Event in the
+// message to all of its targets. Messages that are not valid Events are
+// logged and dropped. An error is only returned if a failed delivery could
+// not be saved to the DeadLetters store, so the message will be redelivered
+// and targets may receive an event more than once.
+func (m *Manager) Handle(ctx context.Context, msg pubsub.SubscriberMessage) error {
+	var e Event
+	if err := json.Unmarshal(msg.Message(), &e); err != nil {
+		Log.Error("unable to decode webhook event: ", err)
+		return nil
+	}
+	targets, err := m.registry.Targets(e.Type)
+	if err != nil {
+		return err
+	}
+	for _, t := range targets {
+		del := m.Deliver(ctx, t, &e)
+		if del == nil {
+			continue
+		}
+		Log.WithFields(logrus.Fields{
+			"event":    e.ID,
+			"type":     e.Type,
+			"target":   t.ID,
+			"attempts": del.Attempts,
+		}).Warn("webhook delivery failed: ", del.LastError)
+		if m.DeadLetters != nil {
+			if err := m.DeadLetters.Save(del); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Deliver will POST the event's payload to the target, retrying failed
+// attempts. It will return nil if the target accepts the event or a
+// Delivery describing the failure if it does not.
+func (m *Manager) Deliver(ctx context.Context, t *Target, e *Event) *Delivery {
+	prefix := "webhook." + e.Type
+	start := time.Now()
+	del := &Delivery{Event: e, Target: t}
+	for del.Attempts < m.maxAttempts {
+		if del.Attempts > 0 {
+			metrics.GetOrRegisterCounter(prefix+".RETRY", m.metrics).Inc(1)
+			select {
+			case <-ctx.Done():
+				del.LastError = ctx.Err().Error()
+				return m.failed(prefix, del)
+			case <-time.After(backoff.Exponential{Base: m.baseDelay, Max: m.maxDelay}.Delay(del.Attempts)):
+			}
+		}
+		del.Attempts++
+		status, err := m.attempt(t, e)
+		del.LastStatus = status
+		if err == nil {
+			metrics.GetOrRegisterTimer(prefix+".DURATION", m.metrics).UpdateSince(start)
+			metrics.GetOrRegisterCounter(prefix+".DELIVERED", m.metrics).Inc(1)
+			return nil
+		}
+		del.LastError = err.Error()
+		if !retryable(status) {
+			break
+		}
+	}
+	return m.failed(prefix, del)
+}
+
+func (m *Manager) failed(prefix string, del *Delivery) *Delivery {
+	metrics.GetOrRegisterCounter(prefix+".FAILED", m.metrics).Inc(1)
+	del.FailedAt = time.Now().UTC()
+	return del
+}
+
+// attempt will make a single delivery of the event and return the response
+// status code, if any.
+func (m *Manager) attempt(t *Target, e *Event) (int, error) {
+	req, err := http.NewRequest("POST", t.URL, bytes.NewReader(e.Payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", m.userAgent)
+	req.Header.Set(EventTypeHeader, e.Type)
+	req.Header.Set(EventIDHeader, e.ID)
+	req.Header.Set(SignatureHeader, Sign(t.Secret, time.Now(), e.Payload))
+
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	// drain the body so the connection may be reused
+	io.Copy(ioutil.Discard, resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("webhook target responded with %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// retryable will return false for responses that signal the target will
+// never accept the event, like a 400 or 404.
+func retryable(status int) bool {
+	switch {
+	case status == 0, status >= 500:
+		return true
+	case status == http.StatusRequestTimeout, status == 429:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,77 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The headers sent with each delivery.
+const (
+	// SignatureHeader holds the time the payload was signed at and its
+	// signature, like 't=1481060400,v1=5257a8...'.
+	SignatureHeader = "X-Webhook-Signature"
+	// EventTypeHeader holds the Type of the delivered event.
+	EventTypeHeader = "X-Webhook-Event"
+	// EventIDHeader holds the ID of the delivered event.
+	EventIDHeader = "X-Webhook-ID"
+)
+
+var (
+	// ErrInvalidSignature is returned by Verify when the signature does not
+	// match the payload.
+	ErrInvalidSignature = errors.New("webhook: invalid signature")
+	// ErrSignatureExpired is returned by Verify when the payload was signed
+	// longer ago than the tolerance.
+	ErrSignatureExpired = errors.New("webhook: signature expired")
+)
+
+// Sign will return the SignatureHeader value for the body signed at the
+// given time. The signature is a hex HMAC-SHA256 of the unix timestamp,
+// a '.' and the body, keyed by the secret.
+func Sign(secret string, at time.Time, body []byte) string {
+	ts := strconv.FormatInt(at.Unix(), 10)
+	return "t=" + ts + ",v1=" + signature(secret, ts, body)
+}
+
+func signature(secret, ts string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(ts))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify is for receivers of webhooks to check that the SignatureHeader
+// value matches the body and was signed within the tolerance. If the
+// tolerance is 0, the age of the signature is not checked.
+func Verify(secret, header string, body []byte, tolerance time.Duration) error {
+	var ts, sig string
+	for _, part := range strings.Split(header, ",") {
+		kv := strings.SplitN(strings.TrimSpace(part), "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		switch kv[0] {
+		case "t":
+			ts = kv[1]
+		case "v1":
+			sig = kv[1]
+		}
+	}
+	unix, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil || sig == "" {
+		return ErrInvalidSignature
+	}
+	if !hmac.Equal([]byte(sig), []byte(signature(secret, ts, body))) {
+		return ErrInvalidSignature
+	}
+	if tolerance > 0 && time.Since(time.Unix(unix, 0)) > tolerance {
+		return ErrSignatureExpired
+	}
+	return nil
+}
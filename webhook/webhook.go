@@ -0,0 +1,154 @@
+package webhook
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/nu7hatch/gouuid"
+
+	"github.com/NYTimes/gizmo/pubsub"
+)
+
+// Log is the structured logger used throughout the package.
+var Log = logrus.New()
+
+// Event is something that happened that partners may be notified of. It is
+// published as JSON with its Type as the message key.
+type Event struct {
+	// ID is a unique ID assigned when the event is published. It is sent to
+	// targets so they may ignore duplicate deliveries.
+	ID string `json:"id"`
+	// Type is used to pick the Targets the event will be delivered to.
+	Type string `json:"type"`
+	// Payload is the JSON encoded body that will be delivered.
+	Payload json.RawMessage `json:"payload"`
+	// OccurredAt is when the event was published.
+	OccurredAt time.Time `json:"occurred_at"`
+}
+
+// Publish will publish an event of the given type with the JSON encoded
+// payload for a Manager to deliver. It will return the event's ID.
+func Publish(pub pubsub.Publisher, eventType string, payload interface{}) (string, error) {
+	p, err := json.Marshal(payload)
+	if err != nil {
+		return "", err
+	}
+	id, err := uuid.NewV4()
+	if err != nil {
+		return "", err
+	}
+	e := &Event{
+		ID:         id.String(),
+		Type:       eventType,
+		Payload:    p,
+		OccurredAt: time.Now().UTC(),
+	}
+	b, err := json.Marshal(e)
+	if err != nil {
+		return "", err
+	}
+	return e.ID, pub.PublishRaw(eventType, b)
+}
+
+// Target is a URL that events of certain types will be delivered to.
+type Target struct {
+	// ID identifies the target, such as the partner it belongs to.
+	ID string `json:"id"`
+	// URL is where events will be POSTed.
+	URL string `json:"url"`
+	// Secret is the key the payloads delivered to the target are signed with.
+	Secret string `json:"-"`
+}
+
+// Registry is where the Targets of each event type are kept.
+type Registry interface {
+	// Targets will return the targets that events of the given type should
+	// be delivered to.
+	Targets(eventType string) ([]*Target, error)
+}
+
+// MemoryRegistry is a Registry kept in memory, for services with a fixed
+// set of targets.
+type MemoryRegistry struct {
+	mu      sync.RWMutex
+	targets map[string][]*Target
+}
+
+// NewMemoryRegistry will return an empty MemoryRegistry.
+func NewMemoryRegistry() *MemoryRegistry {
+	return &MemoryRegistry{targets: map[string][]*Target{}}
+}
+
+// Register will add the target for events of the given type, replacing any
+// target with the same ID.
+func (r *MemoryRegistry) Register(eventType string, t *Target) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.remove(eventType, t.ID)
+	r.targets[eventType] = append(r.targets[eventType], t)
+}
+
+// Remove will stop delivering events of the given type to the target
+// with the ID.
+func (r *MemoryRegistry) Remove(eventType, id string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.remove(eventType, id)
+}
+
+func (r *MemoryRegistry) remove(eventType, id string) {
+	targets := r.targets[eventType]
+	for i, t := range targets {
+		if t.ID == id {
+			r.targets[eventType] = append(targets[:i:i], targets[i+1:]...)
+			return
+		}
+	}
+}
+
+// Targets will return the targets registered for the event type.
+func (r *MemoryRegistry) Targets(eventType string) ([]*Target, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	targets := make([]*Target, len(r.targets[eventType]))
+	copy(targets, r.targets[eventType])
+	return targets, nil
+}
+
+// Delivery is the record of an event sent to a target that could not be
+// delivered.
+type Delivery struct {
+	Event  *Event  `json:"event"`
+	Target *Target `json:"target"`
+	// Attempts is the number of times delivery was attempted.
+	Attempts int `json:"attempts"`
+	// LastStatus is the HTTP status code of the last attempt, if any.
+	LastStatus int `json:"last_status,omitempty"`
+	// LastError is the error from the last attempt.
+	LastError string `json:"last_error"`
+	// FailedAt is when the delivery was given up on.
+	FailedAt time.Time `json:"failed_at"`
+}
+
+// DeadLetterStore is where deliveries that failed all of their attempts are
+// kept so they may be inspected or replayed.
+type DeadLetterStore interface {
+	Save(*Delivery) error
+}
+
+// PublisherDeadLetters is a DeadLetterStore that publishes failed
+// deliveries as JSON, keyed by their event type.
+type PublisherDeadLetters struct {
+	Publisher pubsub.Publisher
+}
+
+// Save will publish the delivery.
+func (d *PublisherDeadLetters) Save(del *Delivery) error {
+	b, err := json.Marshal(del)
+	if err != nil {
+		return err
+	}
+	return d.Publisher.PublishRaw(del.Event.Type, b)
+}
@@ -0,0 +1,122 @@
+package webhook
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+	"golang.org/x/net/context"
+
+	"github.com/NYTimes/gizmo/config"
+	"github.com/NYTimes/gizmo/pubsub/pubsubtest"
+)
+
+func TestManager(t *testing.T) {
+	tests := []struct {
+		name     string
+		statuses []int
+
+		wantCalls      int32
+		wantDeadLetter bool
+	}{
+		{"success", []int{http.StatusOK}, 1, false},
+		{"retried", []int{http.StatusServiceUnavailable, 429, http.StatusNoContent}, 3, false},
+		{"exhausted", []int{500, 500, 500}, 3, true},
+		{"rejected", []int{http.StatusNotFound}, 1, true},
+	}
+
+	for _, test := range tests {
+		var calls int32
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			n := atomic.AddInt32(&calls, 1)
+			body, _ := ioutil.ReadAll(r.Body)
+			if err := Verify("shh", r.Header.Get(SignatureHeader), body, time.Minute); err != nil {
+				t.Errorf("%s: unable to verify signature: %s", test.name, err)
+			}
+			if got := r.Header.Get(EventTypeHeader); got != "cat.adopted" {
+				t.Errorf("%s: expected event type header, got %q", test.name, got)
+			}
+			if string(body) != `{"name":"tom"}` {
+				t.Errorf("%s: expected payload, got %s", test.name, body)
+			}
+			w.WriteHeader(test.statuses[n-1])
+		}))
+
+		registry := NewMemoryRegistry()
+		registry.Register("cat.adopted", &Target{ID: "shelter", URL: srv.URL, Secret: "shh"})
+		mgr := NewManager(&config.Webhook{
+			MaxAttempts:     3,
+			RetryBaseDelay:  time.Millisecond,
+			RetryMaxDelay:   2 * time.Millisecond,
+			MetricsRegistry: metrics.NewRegistry(),
+		}, registry)
+		dead := &pubsubtest.TestPublisher{}
+		mgr.DeadLetters = &PublisherDeadLetters{Publisher: dead}
+
+		pub := &pubsubtest.TestPublisher{}
+		id, err := Publish(pub, "cat.adopted", map[string]string{"name": "tom"})
+		if err != nil {
+			t.Fatalf("%s: unable to publish: %s", test.name, err)
+		}
+		msg := &pubsubtest.TestSubsMessage{Msg: pub.Published[0].Body}
+		if err = mgr.Handle(context.Background(), msg); err != nil {
+			t.Errorf("%s: unexpected error handling: %s", test.name, err)
+		}
+		srv.Close()
+
+		if calls != test.wantCalls {
+			t.Errorf("%s: expected %d calls, got %d", test.name, test.wantCalls, calls)
+		}
+		if got := len(dead.Published) == 1; got != test.wantDeadLetter {
+			t.Errorf("%s: expected dead letter %t, got %d dead letters", test.name, test.wantDeadLetter, len(dead.Published))
+		}
+		if test.wantDeadLetter && len(dead.Published) == 1 {
+			var del Delivery
+			json.Unmarshal(dead.Published[0].Body, &del)
+			if del.Event.ID != id || del.Target.ID != "shelter" || del.Attempts != int(test.wantCalls) {
+				t.Errorf("%s: unexpected dead letter: %+v", test.name, del)
+			}
+		}
+	}
+}
+
+func TestVerify(t *testing.T) {
+	body := []byte(`{"name":"tom"}`)
+	now := time.Now()
+	sig := Sign("shh", now, body)
+
+	if err := Verify("shh", sig, body, time.Minute); err != nil {
+		t.Error("expected a valid signature, got ", err)
+	}
+	if err := Verify("nope", sig, body, time.Minute); err != ErrInvalidSignature {
+		t.Errorf("expected ErrInvalidSignature for the wrong secret, got %v", err)
+	}
+	if err := Verify("shh", sig, []byte(`{"name":"jerry"}`), time.Minute); err != ErrInvalidSignature {
+		t.Errorf("expected ErrInvalidSignature for a changed body, got %v", err)
+	}
+	old := Sign("shh", now.Add(-time.Hour), body)
+	if err := Verify("shh", old, body, time.Minute); err != ErrSignatureExpired {
+		t.Errorf("expected ErrSignatureExpired, got %v", err)
+	}
+	if err := Verify("shh", "garbage", body, 0); err != ErrInvalidSignature {
+		t.Errorf("expected ErrInvalidSignature for a malformed header, got %v", err)
+	}
+}
+
+func TestMemoryRegistry(t *testing.T) {
+	r := NewMemoryRegistry()
+	r.Register("cat.adopted", &Target{ID: "a", URL: "http://a"})
+	r.Register("cat.adopted", &Target{ID: "b", URL: "http://b"})
+	r.Register("cat.adopted", &Target{ID: "a", URL: "http://a2"})
+	r.Remove("cat.adopted", "b")
+
+	targets, _ := r.Targets("cat.adopted")
+	if len(targets) != 1 || targets[0].URL != "http://a2" {
+		t.Errorf("expected only the replaced target a, got %+v", targets)
+	}
+}